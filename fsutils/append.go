@@ -0,0 +1,235 @@
+package fsutils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// AppendSync decides when an AppendWriter syncs what has been written to disk.
+type AppendSync int
+
+const (
+	// AppendSyncOnClose only syncs when the writer is closed, or when Sync is called. This
+	// is the fastest, but writes can be lost in a crash.
+	AppendSyncOnClose AppendSync = iota
+
+	// AppendSyncEveryWrite flushes and syncs on every write, so that each write is
+	// durable once it returns. This is the slowest.
+	AppendSyncEveryWrite
+
+	// AppendSyncInterval flushes and syncs periodically in the background, so that at most
+	// an interval's worth of writes can be lost in a crash.
+	AppendSyncInterval
+)
+
+// String returns the name of the policy.
+func (as AppendSync) String() string {
+	switch as {
+	case AppendSyncOnClose:
+		return "on close"
+	case AppendSyncEveryWrite:
+		return "every write"
+	case AppendSyncInterval:
+		return "interval"
+	default:
+		return fmt.Sprintf("AppendSync(%d)", int(as))
+	}
+}
+
+// AppendOptions is a struct used by OpenAppend to define certain optional parameters.
+type AppendOptions struct {
+	// Sync decides when writes are synced to disk.
+	Sync AppendSync
+
+	// Interval is how often writes are synced with AppendSyncInterval.
+	Interval time.Duration
+
+	// BufferSize is the size of the buffer that writes are collected in before being
+	// written to the file.
+	BufferSize int
+
+	// Perm is the permissions that the file is created with, before the umask.
+	Perm fs.FileMode
+}
+
+func defaultAppendOptions() AppendOptions {
+	return AppendOptions{
+		Interval:   time.Second,
+		BufferSize: 64 << 10,
+		Perm:       0o644,
+	}
+}
+
+// AppendWriter is a buffered writer that appends to a file, such as a journal or an
+// append-only log, and syncs it to disk according to its AppendSync policy. It is safe
+// for concurrent use.
+type AppendWriter struct {
+	path    string
+	options AppendOptions
+
+	mu     sync.Mutex
+	file   *os.File
+	buffer *bufio.Writer
+	err    error
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// OpenAppend opens the file at path for appending, creating it if it does not exist. On
+// success, the new writer is returned. On failure, an error is returned.
+//
+// This takes a variadic parameter of type AppendOptions. If no AppendOptions are
+// supplied, then the defaults are used. If more than one AppendOptions are supplied
+// then only the first will be used.
+func OpenAppend(path string, opts ...AppendOptions) (*AppendWriter, error) {
+	options := defaultAppendOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaultAppendOptions().Interval
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = defaultAppendOptions().BufferSize
+	}
+	if options.Perm == 0 {
+		options.Perm = defaultAppendOptions().Perm
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, options.Perm)
+	if err != nil {
+		return nil, err
+	}
+
+	aw := &AppendWriter{
+		path:    path,
+		options: options,
+		file:    file,
+		buffer:  bufio.NewWriterSize(file, options.BufferSize),
+	}
+
+	if options.Sync == AppendSyncInterval {
+		aw.stop, aw.done = make(chan struct{}), make(chan struct{})
+		go aw.syncPeriodically()
+	}
+
+	return aw, nil
+}
+
+// Path returns the path of the file.
+func (aw *AppendWriter) Path() string {
+	return aw.path
+}
+
+// Write appends p to the file. If a background sync has failed, its error is returned
+// instead, and p is not written, by this and every later call until the writer is closed.
+func (aw *AppendWriter) Write(p []byte) (int, error) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	if aw.file == nil {
+		return 0, os.ErrClosed
+	}
+	if aw.err != nil {
+		return 0, aw.err
+	}
+
+	n, err := aw.buffer.Write(p)
+	if err == nil && aw.options.Sync == AppendSyncEveryWrite {
+		err = aw.sync()
+	}
+
+	return n, err
+}
+
+// Flush writes any buffered data to the file, without syncing it to disk. If a background
+// sync has failed, its error is returned instead.
+func (aw *AppendWriter) Flush() error {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	if aw.file == nil {
+		return os.ErrClosed
+	}
+	if aw.err != nil {
+		return aw.err
+	}
+
+	return aw.buffer.Flush()
+}
+
+// Sync writes any buffered data to the file and syncs it to disk, regardless of the
+// policy. If a background sync has failed, its error is returned instead.
+func (aw *AppendWriter) Sync() error {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	if aw.file == nil {
+		return os.ErrClosed
+	}
+	if aw.err != nil {
+		return aw.err
+	}
+
+	return aw.sync()
+}
+
+func (aw *AppendWriter) sync() error {
+	if err := aw.buffer.Flush(); err != nil {
+		return err
+	}
+
+	return aw.file.Sync()
+}
+
+// syncPeriodically syncs the file every interval until the writer is closed.
+func (aw *AppendWriter) syncPeriodically() {
+	defer close(aw.done)
+
+	ticker := time.NewTicker(aw.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-aw.stop:
+			return
+		case <-ticker.C:
+		}
+
+		aw.mu.Lock()
+		if aw.file != nil && aw.err == nil {
+			aw.err = aw.sync()
+		}
+		aw.mu.Unlock()
+	}
+}
+
+// Close flushes and syncs any remaining data, and closes the file. Writing to a closed
+// writer fails with os.ErrClosed.
+func (aw *AppendWriter) Close() error {
+	aw.stopOnce.Do(func() {
+		if aw.stop != nil {
+			close(aw.stop)
+			<-aw.done
+		}
+	})
+
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	if aw.file == nil {
+		return nil
+	}
+
+	err := errors.Join(aw.err, aw.sync(), aw.file.Close())
+	aw.file, aw.err = nil, nil
+
+	return err
+}
@@ -0,0 +1,115 @@
+package fsutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	if err := os.WriteFile(path, []byte("existing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	aw, err := OpenAppend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := aw.Write([]byte("buffered\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "existing\n" {
+		t.Errorf("expected the write to be buffered, got %q", data)
+	}
+
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "existing\nbuffered\n" {
+		t.Errorf("unexpected contents %q", data)
+	}
+	if _, err := aw.Write(nil); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected os.ErrClosed, got %v", err)
+	}
+}
+
+func TestOpenAppendSyncPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+
+	aw, err := OpenAppend(path, AppendOptions{Sync: AppendSyncEveryWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := aw.Write([]byte("one\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\n" {
+		t.Errorf("expected the write to reach the file, got %q", data)
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	aw, err = OpenAppend(path, AppendOptions{Sync: AppendSyncInterval, Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer aw.Close()
+
+	if _, err := aw.Write([]byte("two\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for data, _ := os.ReadFile(path); string(data) != "one\ntwo\n"; data, _ = os.ReadFile(path) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the write to be synced, got %q", data)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAppendWriterSyncErrorIsSticky(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+
+	aw, err := OpenAppend(path, AppendOptions{Sync: AppendSyncInterval, Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the file underneath the writer makes the next background sync fail.
+	aw.mu.Lock()
+	_ = aw.file.Close()
+	aw.mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		aw.mu.Lock()
+		failed := aw.err != nil
+		aw.mu.Unlock()
+
+		if failed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the background sync to fail")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for range 2 {
+		if _, err := aw.Write([]byte("lost\n")); err == nil {
+			t.Fatal("writes should keep failing after a background sync failed")
+		}
+	}
+	if err := aw.Sync(); err == nil {
+		t.Error("sync should fail after a background sync failed")
+	}
+	if err := aw.Close(); err == nil {
+		t.Error("close should report the failed background sync")
+	}
+}
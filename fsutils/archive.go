@@ -0,0 +1,665 @@
+package fsutils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the format of an archive written by Archive.
+type ArchiveFormat int
+
+const (
+	// ArchiveTarGz is a tar archive compressed with gzip, usually named with ".tar.gz" or
+	// ".tgz".
+	ArchiveTarGz ArchiveFormat = iota + 1
+
+	// ArchiveZip is a zip archive, with its files compressed with deflate.
+	ArchiveZip
+)
+
+// String returns the name of the format.
+func (af ArchiveFormat) String() string {
+	switch af {
+	case ArchiveTarGz:
+		return "tar.gz"
+	case ArchiveZip:
+		return "zip"
+	default:
+		return fmt.Sprintf("ArchiveFormat(%d)", int(af))
+	}
+}
+
+var (
+	// ErrUnknownArchiveFormat is returned by Extract when the format of an archive is not
+	// recognised, and by Archive when given an unknown ArchiveFormat.
+	ErrUnknownArchiveFormat = errors.New("unknown archive format")
+
+	// ErrUnsafeArchivePath is returned by Extract for entries whose paths, or link
+	// targets, would escape the destination directory, which is known as "zip slip".
+	ErrUnsafeArchivePath = errors.New("archive entry escapes destination directory")
+
+	// ErrArchiveTooLarge is returned by Extract when the files in an archive are larger
+	// in total than ExtractOptions.MaxSize.
+	ErrArchiveTooLarge = errors.New("archive contents exceed maximum size")
+)
+
+// ArchiveOptions is a struct used by Archive to define certain optional parameters.
+type ArchiveOptions struct {
+	// Include, if not empty, restricts the archive to files that match at least one of
+	// these patterns. Exclude leaves out files and whole directories that match any of
+	// these patterns. See CopyDirOptions.Include for the syntax.
+	Include []string
+	Exclude []string
+}
+
+func defaultArchiveOptions() ArchiveOptions {
+	return ArchiveOptions{}
+}
+
+// Archive writes the contents of the directory at dir to an archive at dest, in the given
+// format. Entries are named by their paths relative to dir, with their permissions and
+// modification times. Symbolic links are stored as links. The archive is written
+// atomically, see AtomicWriteFile, and if dest is inside dir it is left out.
+//
+// This takes a variadic parameter of type ArchiveOptions. If no ArchiveOptions are
+// supplied, then the defaults are used. If more than one ArchiveOptions are supplied
+// then only the first will be used.
+func Archive(dir, dest string, format ArchiveFormat, opts ...ArchiveOptions) error {
+	options := defaultArchiveOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if err := validatePatterns(slices.Concat(options.Include, options.Exclude)); err != nil {
+		return err
+	}
+
+	var newWriter func(io.Writer) archiveWriter
+	switch format {
+	case ArchiveTarGz:
+		newWriter = newTarGzWriter
+	case ArchiveZip:
+		newWriter = newZipWriter
+	default:
+		return fmt.Errorf("%w: %v", ErrUnknownArchiveFormat, format)
+	}
+
+	absDest, err := filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+
+	return atomicWrite(dest, 0o666, func(file *os.File) error {
+		absTemp, err := filepath.Abs(file.Name())
+		if err != nil {
+			return err
+		}
+
+		buffered := bufio.NewWriter(file)
+		writer := newWriter(buffered)
+
+		err = filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(dir, name)
+			if err != nil || rel == "." {
+				return err
+			}
+
+			if abs, err := filepath.Abs(name); err != nil {
+				return err
+			} else if abs == absDest || abs == absTemp {
+				return nil
+			}
+
+			if matchesAny(options.Exclude, rel) {
+				return skipDir(entry)
+			}
+			if !entry.IsDir() && len(options.Include) > 0 && !matchesAny(options.Include, rel) {
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+
+			var link string
+			if info.Mode()&fs.ModeSymlink != 0 {
+				if link, err = os.Readlink(name); err != nil {
+					return err
+				}
+			} else if !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
+
+			return writer.add(name, filepath.ToSlash(rel), info, link)
+		})
+
+		return errors.Join(err, writer.Close(), buffered.Flush())
+	})
+}
+
+// archiveWriter adds entries to an archive.
+type archiveWriter interface {
+	// add adds the entry at path, which has info and is named rel in the archive. link
+	// is the target of symbolic links.
+	add(path, rel string, info fs.FileInfo, link string) error
+	Close() error
+}
+
+// tarGzWriter writes tar.gz archives.
+type tarGzWriter struct {
+	gzip *gzip.Writer
+	tar  *tar.Writer
+}
+
+func newTarGzWriter(w io.Writer) archiveWriter {
+	gz := gzip.NewWriter(w)
+	return &tarGzWriter{gzip: gz, tar: tar.NewWriter(gz)}
+}
+
+func (tgw *tarGzWriter) add(path, rel string, info fs.FileInfo, link string) error {
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+
+	header.Name = rel
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err := tgw.tar.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	_, err = appendFile(tgw.tar, path)
+
+	return err
+}
+
+func (tgw *tarGzWriter) Close() error {
+	return errors.Join(tgw.tar.Close(), tgw.gzip.Close())
+}
+
+// zipWriter writes zip archives.
+type zipWriter struct {
+	zip *zip.Writer
+}
+
+func newZipWriter(w io.Writer) archiveWriter {
+	return &zipWriter{zip: zip.NewWriter(w)}
+}
+
+func (zw *zipWriter) add(path, rel string, info fs.FileInfo, link string) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+
+	header.Name = rel
+	if info.IsDir() {
+		header.Name += "/"
+	} else if info.Mode().IsRegular() {
+		header.Method = zip.Deflate
+	}
+
+	writer, err := zw.zip.CreateHeader(header)
+	if err != nil || info.IsDir() {
+		return err
+	}
+
+	// Symbolic links are stored with their target as their contents.
+	if link != "" {
+		_, err = io.WriteString(writer, link)
+		return err
+	}
+
+	_, err = appendFile(writer, path)
+
+	return err
+}
+
+func (zw *zipWriter) Close() error {
+	return zw.zip.Close()
+}
+
+// ExtractOptions is a struct used by Extract to define certain optional parameters.
+type ExtractOptions struct {
+	// Include, if not empty, restricts the extraction to files that match at least one of
+	// these patterns. Exclude leaves out files and whole directories that match any of
+	// these patterns. See CopyDirOptions.Include for the syntax.
+	Include []string
+	Exclude []string
+
+	// MaxSize, if positive, is the total size in bytes that the extracted files may be at
+	// most, which guards against archives that expand to fill the disk. Extracting more
+	// fails with ErrArchiveTooLarge.
+	MaxSize int64
+}
+
+func defaultExtractOptions() ExtractOptions {
+	return ExtractOptions{}
+}
+
+// Extract extracts the tar.gz or zip archive at archive into the directory at destDir,
+// which is created if it does not exist. The format is detected from the contents of the
+// archive. Files are extracted with the permissions and modification times stored in
+// the archive.
+//
+// Entries whose paths are absolute or would escape destDir, such as "../../etc/passwd",
+// and links whose targets would, are rejected with ErrUnsafeArchivePath before anything
+// is written for them. destDir should not contain symbolic links that point outside of
+// it, as these are not checked.
+//
+// This takes a variadic parameter of type ExtractOptions. If no ExtractOptions are
+// supplied, then the defaults are used. If more than one ExtractOptions are supplied
+// then only the first will be used.
+func Extract(archive, destDir string, opts ...ExtractOptions) error {
+	options := defaultExtractOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if err := validatePatterns(slices.Concat(options.Include, options.Exclude)); err != nil {
+		return err
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %w", ErrUnknownArchiveFormat, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(destDir, 0o777); err != nil {
+		return err
+	}
+
+	ex := &extractor{destDir: destDir, options: options}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		err = ex.extractTarGz(file)
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		err = ex.extractZip(file)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownArchiveFormat, archive)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return ex.finishDirs()
+}
+
+// extractor holds the state of a single Extract call.
+type extractor struct {
+	destDir string
+	options ExtractOptions
+	size    int64
+
+	// dirs are the directories that were extracted, whose modes are applied once their
+	// contents have been, in case they are read-only.
+	dirs []extractedDir
+}
+
+type extractedDir struct {
+	path    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// target returns the path in destDir that the entry named name should be extracted to,
+// or an empty path if it should be skipped.
+func (ex *extractor) target(name string, isDir bool) (string, error) {
+	name = strings.TrimSuffix(name, "/")
+	clean := path.Clean(name)
+	if clean == "." {
+		return "", nil
+	}
+
+	local, err := filepath.Localize(clean)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrUnsafeArchivePath, name)
+	}
+
+	if matchesAny(ex.options.Exclude, clean) || ex.excludedParent(clean) {
+		return "", nil
+	}
+	if !isDir && len(ex.options.Include) > 0 && !matchesAny(ex.options.Include, clean) {
+		return "", nil
+	}
+
+	if err := ex.checkParents(name, local); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(ex.destDir, local), nil
+}
+
+// checkParents returns an error if any of the parent directories of local, the path of
+// the entry named name relative to destDir, is a symbolic link. Each link is checked on
+// its own when it is extracted, but a chain of them that were extracted earlier, such as
+// "a" to "." and then "a/b" to "..", can still lead outside of destDir.
+func (ex *extractor) checkParents(name, local string) error {
+	dir := ex.destDir
+	for _, part := range strings.Split(filepath.Dir(local), string(filepath.Separator)) {
+		if part == "." {
+			break
+		}
+
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %q is inside a symbolic link", ErrUnsafeArchivePath, name)
+		}
+	}
+
+	return nil
+}
+
+// excludedParent reports whether any of the parent directories of rel are excluded, as
+// archives need not list directories before their contents.
+func (ex *extractor) excludedParent(rel string) bool {
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if matchesAny(ex.options.Exclude, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkLink returns an error if the symbolic link at target, pointing to link, would
+// point outside of destDir. The link is resolved against what has been extracted so far,
+// so that it cannot escape through links from earlier entries, such as "a" pointing to
+// "." followed by "c" pointing to "a/..".
+func (ex *extractor) checkLink(target, link string) error {
+	hops := 0
+	_, err := ex.resolveLink(filepath.Dir(target), link, &hops)
+
+	return err
+}
+
+// resolveLink resolves link relative to the directory at dir, following the symbolic
+// links that have already been extracted, and returns an error wrapping
+// ErrUnsafeArchivePath if it leaves destDir at any point. hops counts the links that have
+// been followed, so that loops are rejected.
+func (ex *extractor) resolveLink(dir, link string, hops *int) (string, error) {
+	unsafe := fmt.Errorf("%w: link to %q", ErrUnsafeArchivePath, link)
+
+	link = filepath.ToSlash(link)
+	if filepath.IsAbs(link) || strings.HasPrefix(link, "/") {
+		return "", unsafe
+	}
+
+	current := dir
+	for _, part := range strings.Split(link, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			// current has no symbolic links in it, so its parent can be found lexically.
+			current = filepath.Dir(current)
+		default:
+			next := filepath.Join(current, part)
+
+			info, err := os.Lstat(next)
+			switch {
+			case err == nil && info.Mode()&fs.ModeSymlink != 0:
+				if *hops++; *hops > maxSymlinkHops {
+					return "", fmt.Errorf("%w: too many levels of symbolic links", unsafe)
+				}
+
+				inner, err := os.Readlink(next)
+				if err != nil {
+					return "", err
+				}
+				if next, err = ex.resolveLink(current, inner, hops); err != nil {
+					return "", err
+				}
+			case err != nil && !errors.Is(err, fs.ErrNotExist):
+				return "", err
+			}
+
+			current = next
+		}
+
+		if within, err := isWithin(current, ex.destDir); err != nil || !within {
+			return "", unsafe
+		}
+	}
+
+	return current, nil
+}
+
+// dir creates the directory at target, to be given mode once everything is extracted.
+func (ex *extractor) dir(target string, mode fs.FileMode, modTime time.Time) error {
+	if err := os.MkdirAll(target, 0o777); err != nil {
+		return err
+	}
+
+	ex.dirs = append(ex.dirs, extractedDir{target, mode, modTime})
+
+	return nil
+}
+
+// file writes the contents of r to target, with mode and modTime.
+func (ex *extractor) file(target string, r io.Reader, mode fs.FileMode, modTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o777); err != nil {
+		return err
+	}
+
+	err := atomicWrite(target, 0o666, func(file *os.File) error {
+		limited := r
+		if ex.options.MaxSize > 0 {
+			limited = io.LimitReader(r, ex.options.MaxSize-ex.size+1)
+		}
+
+		n, err := io.Copy(file, limited)
+		ex.size += n
+		if err != nil {
+			return err
+		}
+
+		if ex.options.MaxSize > 0 && ex.size > ex.options.MaxSize {
+			return ErrArchiveTooLarge
+		}
+
+		return file.Chmod(mode & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky))
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(target, time.Time{}, modTime)
+}
+
+// symlink creates a symbolic link at target pointing to link.
+func (ex *extractor) symlink(target, link string) error {
+	if err := ex.checkLink(target, link); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o777); err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return os.Symlink(link, target)
+}
+
+// finishDirs applies the modes and modification times of the extracted directories,
+// deepest first.
+func (ex *extractor) finishDirs() error {
+	for _, dir := range slices.Backward(ex.dirs) {
+		if err := os.Chmod(dir.path, dir.mode.Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dir.path, time.Time{}, dir.modTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractTarGz extracts the tar.gz archive read from r.
+func (ex *extractor) extractTarGz(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		target, err := ex.target(header.Name, header.Typeflag == tar.TypeDir)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+
+		info := header.FileInfo()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = ex.dir(target, info.Mode(), header.ModTime)
+		case tar.TypeReg:
+			err = ex.file(target, reader, info.Mode(), header.ModTime)
+		case tar.TypeSymlink:
+			err = ex.symlink(target, header.Linkname)
+		case tar.TypeLink:
+			// Hard links refer to another entry by its name in the archive.
+			var source string
+			if source, err = ex.target(header.Linkname, false); err == nil && source != "" {
+				if err = os.MkdirAll(filepath.Dir(target), 0o777); err == nil {
+					_ = os.Remove(target)
+					err = os.Link(source, target)
+				}
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractZip extracts the zip archive in file.
+func (ex *extractor) extractZip(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range reader.File {
+		isDir := strings.HasSuffix(entry.Name, "/") || entry.Mode().IsDir()
+
+		target, err := ex.target(entry.Name, isDir)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+
+		if isDir {
+			mode := entry.Mode()
+			if mode.Perm() == 0 {
+				mode |= 0o755
+			}
+
+			if err := ex.dir(target, mode, entry.Modified); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := ex.extractZipEntry(entry, target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractZipEntry extracts the file or symbolic link entry to target.
+func (ex *extractor) extractZipEntry(entry *zip.File, target string) error {
+	contents, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer contents.Close()
+
+	mode := entry.Mode()
+	if mode&fs.ModeSymlink != 0 {
+		link, err := io.ReadAll(io.LimitReader(contents, 4096))
+		if err != nil {
+			return err
+		}
+
+		return ex.symlink(target, string(link))
+	}
+
+	if !mode.IsRegular() {
+		return nil
+	}
+
+	// Archives made by some tools have no permissions.
+	if mode.Perm() == 0 {
+		mode |= 0o644
+	}
+
+	return ex.file(target, contents, mode, entry.Modified)
+}
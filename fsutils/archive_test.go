@@ -0,0 +1,174 @@
+package fsutils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestArchiveAndExtract(t *testing.T) {
+	src := t.TempDir()
+
+	for name, contents := range map[string]string{
+		"main.go":      "package main",
+		"sub/util.go":  "package sub",
+		"sub/notes.md": "notes",
+		"tmp/cache":    "cache",
+	} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, format := range []ArchiveFormat{ArchiveTarGz, ArchiveZip} {
+		t.Run(format.String(), func(t *testing.T) {
+			// The archive is written inside of the directory, so must leave itself out.
+			archive := filepath.Join(src, "archive."+format.String())
+			if err := Archive(src, archive, format, ArchiveOptions{Exclude: []string{"tmp"}}); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(archive)
+
+			dst := t.TempDir()
+			if err := Extract(archive, dst, ExtractOptions{Exclude: []string{"*.md"}}); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(dst, "sub", "util.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "package sub" {
+				t.Errorf("read %q, want %q", data, "package sub")
+			}
+
+			info, err := os.Stat(filepath.Join(dst, "main.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if runtime.GOOS != "windows" && info.Mode().Perm() != 0o640 {
+				t.Errorf("expected mode 0640, got %v", info.Mode().Perm())
+			}
+
+			for _, name := range []string{"tmp", filepath.Join("sub", "notes.md"), filepath.Base(archive)} {
+				if PathExists(filepath.Join(dst, name)) {
+					t.Errorf("expected %s to be left out", name)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractZipSlip(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "evil.zip")
+
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := zip.NewWriter(file)
+	if _, err := writer.Create("../evil"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "dst")
+	if err := Extract(archive, dst); !errors.Is(err, ErrUnsafeArchivePath) {
+		t.Fatalf("expected ErrUnsafeArchivePath, got %v", err)
+	}
+	if PathExists(filepath.Join(dir, "evil")) {
+		t.Fatal("expected nothing to be written outside of the destination")
+	}
+}
+
+// writeTestTarGz writes a tar.gz archive of headers without contents to path.
+func writeTestTarGz(t *testing.T, path string, headers []*tar.Header) {
+	t.Helper()
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz := gzip.NewWriter(file)
+	writer := tar.NewWriter(gz)
+	for _, header := range headers {
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractSymlinkChain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need extra privileges on Windows")
+	}
+
+	for name, headers := range map[string][]*tar.Header{
+		"through parent": {
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "b/pwned", Typeflag: tar.TypeReg, Mode: 0o644},
+		},
+		"through target": {
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "c", Typeflag: tar.TypeSymlink, Linkname: "a/.."},
+		},
+		"loop": {
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "b"},
+			{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "a"},
+			{Name: "c", Typeflag: tar.TypeSymlink, Linkname: "a"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, "evil.tar.gz")
+			writeTestTarGz(t, archive, headers)
+
+			dst := filepath.Join(dir, "dst")
+			if err := Extract(archive, dst); !errors.Is(err, ErrUnsafeArchivePath) {
+				t.Fatalf("expected ErrUnsafeArchivePath, got %v", err)
+			}
+			if PathExists(filepath.Join(dir, "pwned")) {
+				t.Fatal("expected nothing to be written outside of the destination")
+			}
+		})
+	}
+
+	// Links through earlier links that stay inside the destination are still allowed.
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "safe.tar.gz")
+	writeTestTarGz(t, archive, []*tar.Header{
+		{Name: "sub/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "sub"},
+		{Name: "c", Typeflag: tar.TypeSymlink, Linkname: "a/.."},
+	})
+	if err := Extract(archive, filepath.Join(dir, "dst")); err != nil {
+		t.Fatal(err)
+	}
+}
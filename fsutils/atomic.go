@@ -0,0 +1,154 @@
+package fsutils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// AtomicWriteFile writes data to the file at path, which is created with perm if it does
+// not exist. As with os.WriteFile, perm is used before the umask, an existing file keeps
+// its permissions, and if path is a symbolic link, the file that it points to is written
+// instead.
+//
+// Unlike os.WriteFile, the data is first written to a temporary file in the same
+// directory, which is synced to disk and then renamed over path. Readers therefore either
+// see the old contents or the new contents in full, and never a partially written file,
+// even if the process crashes partway through. This makes it suitable for persisting
+// state that must not be corrupted. As the file is replaced by a new one, its other
+// metadata, such as its owner and any hard links to it, is not kept.
+func AtomicWriteFile(path string, data []byte, perm fs.FileMode) error {
+	path, info, err := resolveSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	return atomicWrite(path, perm, func(file *os.File) error {
+		if _, err := file.Write(data); err != nil {
+			return err
+		}
+
+		if info != nil {
+			return file.Chmod(info.Mode().Perm())
+		}
+
+		return nil
+	})
+}
+
+// maxSymlinkHops is the amount of symbolic links that are followed when resolving a path
+// before it is considered to loop, matching the limit of most operating systems.
+const maxSymlinkHops = 255
+
+// resolveSymlinks follows the symbolic links at path, if any, and returns the path that
+// they lead to, along with its info if it exists. Unlike filepath.EvalSymlinks, links
+// that lead to files that do not exist yet are followed.
+func resolveSymlinks(path string) (string, fs.FileInfo, error) {
+	for range maxSymlinkHops {
+		info, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return path, nil, nil
+		} else if err != nil {
+			return "", nil, err
+		}
+
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return path, info, nil
+		}
+
+		link, err := os.Readlink(path)
+		if err != nil {
+			return "", nil, err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+
+		path = link
+	}
+
+	return "", nil, &fs.PathError{Op: "readlink", Path: path, Err: errors.New("too many levels of symbolic links")}
+}
+
+// atomicWrite calls write with a temporary file in the directory of path, then syncs it
+// and renames it over path. The temporary file is removed if any step fails.
+func atomicWrite(path string, perm fs.FileMode, write func(file *os.File) error) (err error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	file, err := createTemp(dir, name, perm)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+
+	if err = write(file); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+
+	// The rename is only durable once the directory entry has been synced as well.
+	return syncDir(dir)
+}
+
+// createTemp creates a new file with perm in dir, whose name is based on name. Unlike
+// os.CreateTemp, which always uses 0600, the file is created with perm so that the umask
+// applies to it as it would to any other new file.
+func createTemp(dir, name string, perm fs.FileMode) (*os.File, error) {
+	for range 10000 {
+		var suffix [6]byte
+		_, _ = rand.Read(suffix[:])
+
+		path := filepath.Join(dir, "."+name+".tmp-"+hex.EncodeToString(suffix[:]))
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+
+		return file, err
+	}
+
+	return nil, &fs.PathError{Op: "createtemp", Path: filepath.Join(dir, "."+name+".tmp-*"), Err: fs.ErrExist}
+}
+
+// syncDir syncs the directory at path to disk, so that changes to its entries, such as
+// renames, survive a crash. Directories cannot be synced on Windows, where this does
+// nothing.
+func syncDir(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	err = dir.Sync()
+	if errors.Is(err, errors.ErrUnsupported) {
+		err = nil
+	}
+
+	return errors.Join(err, dir.Close())
+}
@@ -0,0 +1,73 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestAtomicWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	for _, contents := range []string{"first", "second"} {
+		if err := AtomicWriteFile(path, []byte(contents), 0o640); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != contents {
+			t.Fatalf("read %q, want %q", data, contents)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the target file to be left behind, found %d entries", len(entries))
+	}
+
+	if err := AtomicWriteFile(filepath.Join(dir, "missing", "file"), nil, 0o600); err == nil {
+		t.Fatal("expected writing into a missing directory to fail")
+	}
+}
+
+func TestAtomicWriteFileExisting(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("secret", link); err != nil {
+		t.Skip("symbolic links are not supported:", err)
+	}
+
+	if err := AtomicWriteFile(link, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected the symbolic link to be kept, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("expected the target to be written, got %q", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the permissions to be kept, got %v", info.Mode())
+	}
+}
@@ -0,0 +1,157 @@
+package fsutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupNaming decides how WriteFileWithBackup names backups.
+type BackupNaming int
+
+const (
+	// BackupSuffix names the backup of path "path.bak", replacing any previous backup.
+	BackupSuffix BackupNaming = iota
+
+	// BackupTimestamped names backups with the time they were made, such as
+	// "path.2006-01-02T15-04-05.000.bak", with a counter after the time if several are
+	// made within the same millisecond.
+	BackupTimestamped
+
+	// BackupNumbered names backups with increasing version numbers, such as "path.bak.1",
+	// "path.bak.2", and so on.
+	BackupNumbered
+)
+
+// String returns the name of the naming scheme.
+func (bn BackupNaming) String() string {
+	switch bn {
+	case BackupSuffix:
+		return "suffix"
+	case BackupTimestamped:
+		return "timestamped"
+	case BackupNumbered:
+		return "numbered"
+	default:
+		return fmt.Sprintf("BackupNaming(%d)", int(bn))
+	}
+}
+
+// BackupOptions is a struct used by WriteFileWithBackup to define certain optional
+// parameters.
+type BackupOptions struct {
+	// Naming decides how backups are named.
+	Naming BackupNaming
+
+	// Keep is the number of timestamped or numbered backups that are kept, the oldest
+	// being removed first. If it is zero or negative, every backup is kept.
+	Keep int
+}
+
+func defaultBackupOptions() BackupOptions {
+	return BackupOptions{
+		Keep: 5,
+	}
+}
+
+// WriteFileWithBackup writes data to the file at path in the same way as AtomicWriteFile,
+// after keeping a backup of the existing file, if any, so that its previous contents can
+// be recovered if the new ones turn out to be bad. The backup is made with a hard link
+// where possible, or a copy otherwise, so path always exists while it is being replaced.
+// An existing file keeps its permissions, and perm is only used for new files.
+//
+// This takes a variadic parameter of type BackupOptions. If no BackupOptions are
+// supplied, then the defaults are used. If more than one BackupOptions are supplied then
+// only the first will be used.
+func WriteFileWithBackup(path string, data []byte, perm fs.FileMode, opts ...BackupOptions) error {
+	options := defaultBackupOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return AtomicWriteFile(path, data, perm)
+	case err != nil:
+		return err
+	}
+
+	backup, pattern, err := options.backupName(path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(backup); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Link(path, backup); err != nil {
+		if err := CopyFile(path, backup, CopyFileOptions{PreserveMode: true, PreserveTimes: true}); err != nil {
+			return fmt.Errorf("could not back up %q: %w", path, err)
+		}
+	}
+
+	err = atomicWrite(path, perm, func(file *os.File) error {
+		if _, err := file.Write(data); err != nil {
+			return err
+		}
+
+		return file.Chmod(info.Mode().Perm())
+	})
+	if err != nil {
+		return err
+	}
+
+	if pattern != "" && options.Keep > 0 {
+		_, err = PruneDir(filepath.Dir(path), PruneOptions{Pattern: pattern, KeepLast: options.Keep})
+	}
+
+	return err
+}
+
+// backupName returns the path of the next backup of path, and the pattern matching the
+// names of its backups that are subject to retention, if any.
+func (bo BackupOptions) backupName(path string) (string, string, error) {
+	base := filepath.Base(path)
+
+	switch bo.Naming {
+	case BackupSuffix:
+		return path + ".bak", "", nil
+	case BackupTimestamped:
+		// A counter is added for backups made within the same millisecond, which would
+		// otherwise replace each other.
+		stamped := path + "." + time.Now().Format(rotatedTimeFormat)
+		name := stamped
+		for n := 1; PathExists(name + ".bak"); n++ {
+			name = stamped + "-" + strconv.Itoa(n)
+		}
+
+		return name + ".bak", base + ".*.bak", nil
+	case BackupNumbered:
+		entries, err := os.ReadDir(filepath.Dir(path))
+		if err != nil {
+			return "", "", err
+		}
+
+		latest := 0
+		for _, entry := range entries {
+			suffix, ok := strings.CutPrefix(entry.Name(), base+".bak.")
+			if !ok {
+				continue
+			}
+
+			if n, err := strconv.Atoi(suffix); err == nil {
+				latest = max(latest, n)
+			}
+		}
+
+		return path + ".bak." + strconv.Itoa(latest+1), base + ".bak.*", nil
+	default:
+		return "", "", fmt.Errorf("unknown backup naming %v", bo.Naming)
+	}
+}
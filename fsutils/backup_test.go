@@ -0,0 +1,67 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteFileWithBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+
+	for _, contents := range []string{"one", "two"} {
+		if err := WriteFileWithBackup(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "two" {
+		t.Errorf("read %q, want %q", data, "two")
+	}
+	if data, _ := os.ReadFile(path + ".bak"); string(data) != "one" {
+		t.Errorf("expected the backup to hold %q, got %q", "one", data)
+	}
+
+	options := BackupOptions{Naming: BackupNumbered, Keep: 2}
+	for i, contents := range []string{"three", "four", "five"} {
+		// Backups are pruned by modification time, which must differ between them.
+		modTime := time.Now().Add(time.Duration(i-10) * time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := WriteFileWithBackup(path, []byte(contents), 0o644, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if PathExists(path + ".bak.1") {
+		t.Error("expected the oldest numbered backup to be removed")
+	}
+	if data, _ := os.ReadFile(path + ".bak.3"); string(data) != "four" {
+		t.Errorf("expected the latest backup to hold %q, got %q", "four", data)
+	}
+}
+
+func TestWriteFileWithBackupTimestamped(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+
+	// Backups made within the same millisecond must not replace each other.
+	options := BackupOptions{Naming: BackupTimestamped}
+	for _, contents := range []string{"one", "two", "three", "four"} {
+		if err := WriteFileWithBackup(path, []byte(contents), 0o644, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("expected the file and three backups, got %v", entries)
+	}
+}
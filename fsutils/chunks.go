@@ -0,0 +1,51 @@
+package fsutils
+
+import (
+	"errors"
+	"io"
+	"iter"
+	"os"
+)
+
+// DefaultChunkSize is the size of the chunks yielded by ReadChunks when the given size is
+// zero or negative.
+const DefaultChunkSize = 64 * 1024
+
+// ReadChunks returns an iterator over the contents of the file at path in chunks of
+// chunkSize bytes, or DefaultChunkSize if it is zero or negative. Every chunk but the last
+// is full. This allows files of any size to be processed with a fixed amount of memory.
+//
+// The same buffer is reused for every chunk, so a chunk is only valid until the next one
+// is yielded, and must be copied to be kept. If the file cannot be opened or read, the
+// error is yielded and the iteration stops. The file is closed when the iteration
+// finishes or is stopped early.
+func ReadChunks(path string, chunkSize int) iter.Seq2[[]byte, error] {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	return func(yield func([]byte, error) bool) {
+		file, err := os.Open(path)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer file.Close()
+
+		buf := make([]byte, chunkSize)
+		for {
+			n, err := io.ReadFull(file, buf)
+			if n > 0 && !yield(buf[:n], nil) {
+				return
+			}
+
+			switch {
+			case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+				return
+			case err != nil:
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
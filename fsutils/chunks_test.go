@@ -0,0 +1,33 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReadChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("abcdefghij"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var chunks []string
+	for chunk, err := range ReadChunks(path, 4) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chunks = append(chunks, string(chunk))
+	}
+	if want := []string{"abcd", "efgh", "ij"}; !slices.Equal(chunks, want) {
+		t.Errorf("expected %v, got %v", want, chunks)
+	}
+
+	for _, err := range ReadChunks(filepath.Join(t.TempDir(), "missing"), 0) {
+		if err == nil {
+			t.Error("expected an error for a missing file")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package fsutils
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Codec is a compression format used by CompressFile and DecompressFile. Gzip and Zstd
+// are provided, and other formats can be used by defining a Codec for them.
+type Codec struct {
+	// Name is the name of the format.
+	Name string
+
+	// Extension is the file name extension of the format, including the dot.
+	Extension string
+
+	// NewWriter returns a writer that compresses what is written to it into w, and that
+	// must be closed to flush the compressed data.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader that decompresses what is read from r.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// Gzip is the gzip Codec, using compress/gzip.
+var Gzip = Codec{
+	Name:      "gzip",
+	Extension: ".gz",
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+	NewReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+}
+
+// Zstd is the Zstandard Codec. As the standard library has no zstd package, it is
+// implemented by this package. Decompression supports any zstd file, such as those
+// written by the zstd tool, except for ones that need a dictionary, and ones with a
+// window of more than 128 MiB, which the zstd tool also refuses by default. Compression
+// favours speed, with results similar to the fastest levels of the zstd tool.
+var Zstd = Codec{
+	Name:      "zstd",
+	Extension: ".zst",
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) { return newZstdWriter(w), nil },
+	NewReader: func(r io.Reader) (io.ReadCloser, error) { return newZstdReader(r), nil },
+}
+
+// CompressOptions is a struct used by CompressFile and DecompressFile to define certain
+// optional parameters.
+type CompressOptions struct {
+	// Codec is the compression format. By default, Gzip is used.
+	Codec Codec
+
+	// RemoveSource removes the source file once the output has been written
+	// successfully, as gzip and gunzip do by default.
+	RemoveSource bool
+}
+
+func defaultCompressOptions() CompressOptions {
+	return CompressOptions{
+		Codec: Gzip,
+	}
+}
+
+// CompressFile compresses the file at src into the file at dst, or, if dst is empty, into
+// src with the extension of the codec added, such as "app.log.gz". The file is streamed
+// rather than read into memory, and dst is written atomically, see AtomicWriteFile, with
+// the permissions of src.
+//
+// This takes a variadic parameter of type CompressOptions. If no CompressOptions are
+// supplied, then the defaults are used. If more than one CompressOptions are supplied
+// then only the first will be used.
+func CompressFile(src, dst string, opts ...CompressOptions) error {
+	options := defaultCompressOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Codec.NewWriter == nil {
+		options.Codec = Gzip
+	}
+
+	if dst == "" {
+		dst = src + options.Codec.Extension
+	}
+
+	return transformFile(src, dst, options.RemoveSource, func(w io.Writer, r io.Reader) error {
+		compressor, err := options.Codec.NewWriter(w)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(compressor, r)
+
+		return errors.Join(err, compressor.Close())
+	})
+}
+
+// DecompressFile decompresses the file at src into the file at dst, or, if dst is empty,
+// into src with the extension of the codec removed. See CompressFile.
+//
+// This takes a variadic parameter of type CompressOptions. If no CompressOptions are
+// supplied, then the defaults are used. If more than one CompressOptions are supplied
+// then only the first will be used.
+func DecompressFile(src, dst string, opts ...CompressOptions) error {
+	options := defaultCompressOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Codec.NewReader == nil {
+		options.Codec = Gzip
+	}
+
+	if dst == "" {
+		var ok bool
+		if dst, ok = strings.CutSuffix(src, options.Codec.Extension); !ok || options.Codec.Extension == "" || dst == "" {
+			return fmt.Errorf("cannot name the output of %q, which does not have the %s extension %q", src, options.Codec.Name, options.Codec.Extension)
+		}
+	}
+
+	return transformFile(src, dst, options.RemoveSource, func(w io.Writer, r io.Reader) error {
+		decompressor, err := options.Codec.NewReader(r)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(w, decompressor)
+
+		return errors.Join(err, decompressor.Close())
+	})
+}
+
+// transformFile atomically writes the file at src through transform to the file at dst,
+// with the permissions of src, and then removes src if remove is set.
+func transformFile(src, dst string, remove bool, transform func(w io.Writer, r io.Reader) error) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = atomicWrite(dst, 0o666, func(file *os.File) error {
+		if err := transform(file, source); err != nil {
+			return err
+		}
+
+		return file.Chmod(info.Mode().Perm())
+	})
+	if err != nil || !remove {
+		return err
+	}
+
+	_ = source.Close()
+
+	return os.Remove(src)
+}
@@ -0,0 +1,75 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	contents := strings.Repeat("log line\n", 1000)
+
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CompressFile(path, "", CompressOptions{RemoveSource: true}); err != nil {
+		t.Fatal(err)
+	}
+	if PathExists(path) {
+		t.Error("expected the source to be removed")
+	}
+
+	info, err := os.Stat(path + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() >= int64(len(contents)) {
+		t.Errorf("expected the file to be compressed, got %d bytes", info.Size())
+	}
+
+	if err := DecompressFile(path+".gz", ""); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != contents {
+		t.Error("expected the decompressed file to match the original")
+	}
+
+	if err := DecompressFile(path, ""); err == nil {
+		t.Error("expected decompressing a file without the extension to fail")
+	}
+}
+
+func TestCompressFileZstd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	contents := strings.Repeat("log line\n", 1000)
+
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	options := CompressOptions{Codec: Zstd, RemoveSource: true}
+	if err := CompressFile(path, "", options); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path + ".zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() >= int64(len(contents)) {
+		t.Errorf("expected the file to be compressed, got %d bytes", info.Size())
+	}
+
+	if err := DecompressFile(path+".zst", "", options); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != contents {
+		t.Error("expected the decompressed file to match the original")
+	}
+	if PathExists(path + ".zst") {
+		t.Error("expected the compressed file to be removed")
+	}
+}
@@ -0,0 +1,179 @@
+package fsutils
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// OverwritePolicy decides what happens when the destination of a copy already exists.
+type OverwritePolicy int
+
+const (
+	// OverwriteAlways replaces an existing destination.
+	OverwriteAlways OverwritePolicy = iota
+
+	// OverwriteNever refuses to replace an existing destination, and fails with an error
+	// wrapping fs.ErrExist.
+	OverwriteNever
+
+	// OverwriteIfNewer replaces an existing destination only if the source was modified
+	// more recently than it. Otherwise the copy is skipped without an error.
+	OverwriteIfNewer
+)
+
+// String returns the name of the policy.
+func (op OverwritePolicy) String() string {
+	switch op {
+	case OverwriteAlways:
+		return "always"
+	case OverwriteNever:
+		return "never"
+	case OverwriteIfNewer:
+		return "if-newer"
+	default:
+		return fmt.Sprintf("OverwritePolicy(%d)", int(op))
+	}
+}
+
+// CopyFileOptions is a struct used by CopyFile to define certain optional parameters.
+type CopyFileOptions struct {
+	// PreserveMode copies the permission bits, including the setuid, setgid, and sticky
+	// bits, of the source file to the destination. Otherwise the destination is created
+	// with 0666 before the umask, as with os.Create.
+	PreserveMode bool
+
+	// PreserveTimes copies the modification time of the source file to the destination.
+	PreserveTimes bool
+
+	// PreserveOwner copies the user and group of the source file to the destination, where
+	// the operating system permits it. This usually requires elevated privileges, and is
+	// silently skipped when they are missing or on platforms without file ownership.
+	PreserveOwner bool
+
+	// Overwrite decides what happens if the destination already exists.
+	Overwrite OverwritePolicy
+}
+
+func defaultCopyFileOptions() CopyFileOptions {
+	return CopyFileOptions{
+		PreserveMode: true,
+	}
+}
+
+// CopyFile copies the contents of the regular file at src to dst. Symbolic links are
+// followed. The copy is written atomically, see AtomicWriteFile, so dst is never left
+// partially written, even if the copy fails.
+//
+// By default, the permissions of src are preserved, the modification time and
+// ownership are not, and an existing dst is replaced.
+//
+// This takes a variadic parameter of type CopyFileOptions. If no CopyFileOptions are
+// supplied, then the defaults are used. If more than one CopyFileOptions are supplied
+// then only the first will be used.
+func CopyFile(src, dst string, opts ...CopyFileOptions) error {
+	options := defaultCopyFileOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	return copyFileAt(src, dst, options, nil)
+}
+
+// copyFileAt copies the regular file at src to dst according to the options, verifying
+// the copy with h if it is not nil. See copyFile.
+func copyFileAt(src, dst string, options CopyFileOptions, h hash.Hash) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return &fs.PathError{Op: "copy", Path: src, Err: errors.New("not a regular file")}
+	}
+
+	if skip, err := options.skip(info, dst); skip || err != nil {
+		return err
+	}
+
+	return copyFile(source, info, dst, options, h)
+}
+
+// skip reports whether the copy of a file with info to dst should be skipped according to
+// the overwrite policy, or returns an error if it must be refused.
+func (co CopyFileOptions) skip(info fs.FileInfo, dst string) (bool, error) {
+	if co.Overwrite == OverwriteAlways {
+		return false, nil
+	}
+
+	existing, err := os.Lstat(dst)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch co.Overwrite {
+	case OverwriteNever:
+		return false, &fs.PathError{Op: "copy", Path: dst, Err: fs.ErrExist}
+	case OverwriteIfNewer:
+		return !info.ModTime().After(existing.ModTime()), nil
+	default:
+		return false, fmt.Errorf("unknown overwrite policy %v", co.Overwrite)
+	}
+}
+
+// copyFile atomically writes the contents of source, which has info, to dst, preserving
+// its metadata as requested by the options. If h is not nil, source is hashed with it as
+// it is copied, and the copy is read back and compared before it replaces dst.
+func copyFile(source io.Reader, info fs.FileInfo, dst string, options CopyFileOptions, h hash.Hash) error {
+	return atomicWrite(dst, 0o666, func(file *os.File) error {
+		if h != nil {
+			h.Reset()
+			source = io.TeeReader(source, h)
+		}
+
+		if _, err := io.Copy(file, source); err != nil {
+			return err
+		}
+
+		if h != nil {
+			if err := verifyCopy(file, h); err != nil {
+				return err
+			}
+		}
+
+		if options.PreserveOwner {
+			if uid, gid, ok := fileOwner(info); ok {
+				if err := file.Chown(uid, gid); err != nil && !errors.Is(err, fs.ErrPermission) {
+					return err
+				}
+			}
+		}
+
+		// The mode is set after chown, which may clear the setuid and setgid bits.
+		if options.PreserveMode {
+			mode := info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+			if err := file.Chmod(mode); err != nil {
+				return err
+			}
+		}
+
+		if options.PreserveTimes {
+			// A zero access time is left unchanged.
+			return os.Chtimes(file.Name(), time.Time{}, info.ModTime())
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,66 @@
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+
+	if err := os.WriteFile(src, []byte("contents"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(src, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	options := CopyFileOptions{PreserveMode: true, PreserveTimes: true, PreserveOwner: true}
+	if err := CopyFile(src, dst, options); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "contents" {
+		t.Fatalf("read %q, want %q", data, "contents")
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("expected modification time %v, got %v", modTime, info.ModTime())
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	options.Overwrite = OverwriteNever
+	if err := CopyFile(src, dst, options); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected fs.ErrExist, got %v", err)
+	}
+
+	// dst is as new as src, so it should be left alone.
+	if err := os.WriteFile(dst, []byte("changed"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	options.Overwrite = OverwriteIfNewer
+	if err := CopyFile(src, dst, options); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "changed" {
+		t.Errorf("expected the newer destination to be kept, got %q", data)
+	}
+}
@@ -0,0 +1,324 @@
+package fsutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SymlinkPolicy decides how CopyDir handles symbolic links.
+type SymlinkPolicy int
+
+const (
+	// SymlinkCopy recreates symbolic links in the destination with the same target,
+	// without copying what they point to.
+	SymlinkCopy SymlinkPolicy = iota
+
+	// SymlinkFollow copies the files and directories that symbolic links point to, as if
+	// they were in the source tree. Links that form a cycle are reported as an error.
+	SymlinkFollow
+
+	// SymlinkSkip leaves symbolic links out of the copy.
+	SymlinkSkip
+)
+
+// String returns the name of the policy.
+func (sp SymlinkPolicy) String() string {
+	switch sp {
+	case SymlinkCopy:
+		return "copy"
+	case SymlinkFollow:
+		return "follow"
+	case SymlinkSkip:
+		return "skip"
+	default:
+		return fmt.Sprintf("SymlinkPolicy(%d)", int(sp))
+	}
+}
+
+// CopyDirOptions is a struct used by CopyDir to define certain optional parameters.
+type CopyDirOptions struct {
+	// File is used to copy each file, see CopyFile. PreserveMode, PreserveTimes and
+	// PreserveOwner also apply to the directories that are created, and Overwrite also
+	// applies to symbolic links.
+	File CopyFileOptions
+
+	// Include, if not empty, restricts the copy to files that match at least one of these
+	// patterns. Exclude leaves out files and whole directories that match any of these
+	// patterns. Patterns use the syntax of path.Match, and are matched against both the
+	// path relative to the source directory, using forward slashes, and the base name,
+	// so "*.go" matches Go files at any depth while "vendor/*" only matches at the top.
+	Include []string
+	Exclude []string
+
+	// Symlinks decides how symbolic links in the source directory are handled.
+	Symlinks SymlinkPolicy
+
+	// DryRun reports what would be copied without changing anything.
+	DryRun bool
+}
+
+func defaultCopyDirOptions() CopyDirOptions {
+	return CopyDirOptions{
+		File: defaultCopyFileOptions(),
+	}
+}
+
+// CopyDir recursively copies the contents of the directory at src into the directory at
+// dst, which is created if it does not exist. Other files in dst are left alone. Files
+// and links that are skipped by the overwrite policy of the options are not copied.
+//
+// The paths of the files and symbolic links that were copied, relative to src, are
+// returned in the order that they were copied; in a dry run they are the ones that would
+// have been. Directories are not included. If an error occurs, the copy stops and the
+// paths copied so far are returned along with it.
+//
+// This takes a variadic parameter of type CopyDirOptions. If no CopyDirOptions are
+// supplied, then the defaults are used. If more than one CopyDirOptions are supplied
+// then only the first will be used.
+func CopyDir(src, dst string, opts ...CopyDirOptions) ([]string, error) {
+	options := defaultCopyDirOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if err := validatePatterns(slices.Concat(options.Include, options.Exclude)); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "copy", Path: src, Err: errors.New("not a directory")}
+	}
+
+	if within, err := isWithin(dst, src); err != nil {
+		return nil, err
+	} else if within {
+		return nil, fmt.Errorf("cannot copy %q into itself at %q", src, dst)
+	}
+
+	dc := &dirCopy{options: options}
+	err = dc.copyDir(src, dst, "", info)
+
+	return dc.copied, err
+}
+
+// isWithin reports whether path is inside of, or the same as, dir.
+func isWithin(path, dir string) (bool, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false, nil
+	}
+
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))), nil
+}
+
+// dirCopy holds the state of a single CopyDir call.
+type dirCopy struct {
+	options CopyDirOptions
+	copied  []string
+
+	// ancestors are the directories currently being copied, which are used to detect
+	// cycles when following symbolic links.
+	ancestors []fs.FileInfo
+}
+
+// copyDir copies the directory at src, which has info and is at rel relative to the root
+// of the copy, to dst.
+func (dc *dirCopy) copyDir(src, dst, rel string, info fs.FileInfo) error {
+	if slices.ContainsFunc(dc.ancestors, func(ancestor fs.FileInfo) bool { return os.SameFile(ancestor, info) }) {
+		return &fs.PathError{Op: "copy", Path: src, Err: errors.New("symbolic link cycle")}
+	}
+
+	dc.ancestors = append(dc.ancestors, info)
+	defer func() { dc.ancestors = dc.ancestors[:len(dc.ancestors)-1] }()
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	if !dc.options.DryRun {
+		// The directory is made writable until its contents have been copied, in case
+		// the source is read-only.
+		if err := os.MkdirAll(dst, 0o777); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		entryRel := filepath.Join(rel, entry.Name())
+		if err := dc.copyEntry(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()), entryRel, entry); err != nil {
+			return err
+		}
+	}
+
+	if dc.options.DryRun {
+		return nil
+	}
+
+	return dc.copyDirMetadata(dst, info)
+}
+
+// copyEntry copies the directory entry at src to dst.
+func (dc *dirCopy) copyEntry(src, dst, rel string, entry fs.DirEntry) error {
+	if matchesAny(dc.options.Exclude, rel) {
+		return nil
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		switch dc.options.Symlinks {
+		case SymlinkSkip:
+			return nil
+		case SymlinkCopy:
+			return dc.copySymlink(src, dst, rel, info)
+		case SymlinkFollow:
+			if info, err = os.Stat(src); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown symbolic link policy %v", dc.options.Symlinks)
+		}
+	}
+
+	switch {
+	case info.IsDir():
+		return dc.copyDir(src, dst, rel, info)
+	case !info.Mode().IsRegular():
+		// Devices, sockets, and named pipes cannot be copied.
+		return nil
+	case len(dc.options.Include) > 0 && !matchesAny(dc.options.Include, rel):
+		return nil
+	}
+
+	if skip, err := dc.options.File.skip(info, dst); skip || err != nil {
+		return err
+	}
+
+	if !dc.options.DryRun {
+		source, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+
+		err = copyFile(source, info, dst, dc.options.File, nil)
+		_ = source.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	dc.copied = append(dc.copied, rel)
+
+	return nil
+}
+
+// copySymlink recreates the symbolic link at src, which has info, at dst.
+func (dc *dirCopy) copySymlink(src, dst, rel string, info fs.FileInfo) error {
+	if len(dc.options.Include) > 0 && !matchesAny(dc.options.Include, rel) {
+		return nil
+	}
+
+	if skip, err := dc.options.File.skip(info, dst); skip || err != nil {
+		return err
+	}
+
+	if !dc.options.DryRun {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+
+		if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err := os.Symlink(target, dst); err != nil {
+			return err
+		}
+	}
+
+	dc.copied = append(dc.copied, rel)
+
+	return nil
+}
+
+// copyDirMetadata applies the metadata of the source directory with info to dst, as
+// requested by the options.
+func (dc *dirCopy) copyDirMetadata(dst string, info fs.FileInfo) error {
+	options := dc.options.File
+
+	if options.PreserveOwner {
+		if uid, gid, ok := fileOwner(info); ok {
+			if err := os.Chown(dst, uid, gid); err != nil && !errors.Is(err, fs.ErrPermission) {
+				return err
+			}
+		}
+	}
+
+	if options.PreserveMode {
+		mode := info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+		if err := os.Chmod(dst, mode); err != nil {
+			return err
+		}
+	}
+
+	if options.PreserveTimes {
+		return os.Chtimes(dst, time.Time{}, info.ModTime())
+	}
+
+	return nil
+}
+
+// validatePatterns returns an error if any of patterns is malformed.
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// matchesAny reports whether rel, or its base name, matches any of patterns. See
+// CopyDirOptions.Include.
+func matchesAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	base := path.Base(rel)
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,108 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestCopyDir(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "copy")
+
+	for name, contents := range map[string]string{
+		"main.go":            "package main",
+		"README.md":          "readme",
+		"internal/util.go":   "package internal",
+		"vendor/dep/dep.go":  "package dep",
+		"internal/notes.txt": "notes",
+	} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	options := CopyDirOptions{
+		Include: []string{"*.go"},
+		Exclude: []string{"vendor"},
+		DryRun:  true,
+	}
+
+	want := []string{filepath.Join("internal", "util.go"), "main.go"}
+	copied, err := CopyDir(src, dst, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(copied, want) {
+		t.Fatalf("expected a dry run to report %v, got %v", want, copied)
+	}
+	if PathExists(dst) {
+		t.Fatal("expected a dry run not to create the destination")
+	}
+
+	options.DryRun = false
+	if copied, err = CopyDir(src, dst, options); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(copied, want) {
+		t.Fatalf("expected %v to be copied, got %v", want, copied)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "internal", "util.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "package internal" {
+		t.Errorf("read %q, want %q", data, "package internal")
+	}
+	if PathExists(filepath.Join(dst, "vendor")) || PathExists(filepath.Join(dst, "README.md")) {
+		t.Error("expected excluded paths not to be copied")
+	}
+
+	if _, err := CopyDir(src, filepath.Join(src, "internal")); err == nil {
+		t.Error("expected copying a directory into itself to fail")
+	}
+}
+
+func TestCopyDirSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links require extra privileges on Windows")
+	}
+
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file"), []byte("contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(".", filepath.Join(src, "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	if _, err := CopyDir(src, dst, CopyDirOptions{Exclude: []string{"loop"}}); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "link")); err != nil || target != "file" {
+		t.Errorf("expected the link to be recreated, got %q, %v", target, err)
+	}
+
+	dst = t.TempDir()
+	if _, err := CopyDir(src, dst, CopyDirOptions{Symlinks: SymlinkFollow, Exclude: []string{"loop"}}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(filepath.Join(dst, "link")); err != nil || !info.Mode().IsRegular() {
+		t.Errorf("expected the link to be followed, got %v, %v", info, err)
+	}
+
+	if _, err := CopyDir(src, t.TempDir(), CopyDirOptions{Symlinks: SymlinkFollow}); err == nil {
+		t.Error("expected following a symbolic link cycle to fail")
+	}
+}
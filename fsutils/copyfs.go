@@ -0,0 +1,86 @@
+package fsutils
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CopyFSOptions is a struct used by CopyFS to define certain optional parameters.
+type CopyFSOptions struct {
+	// Overwrite decides what happens if a file already exists in the destination
+	// directory. Note that the files of an embed.FS have no modification time, so
+	// OverwriteIfNewer never replaces them.
+	Overwrite OverwritePolicy
+}
+
+func defaultCopyFSOptions() CopyFSOptions {
+	return CopyFSOptions{}
+}
+
+// CopyFS writes the contents of filesystem, such as an embed.FS, to the directory at
+// destDir, which is created if it does not exist, preserving the directory structure.
+// This is useful for extracting embedded assets onto disk.
+//
+// Directories are created with 0777 and files with 0666, or 0777 if they are executable
+// in filesystem, before the umask. Each file is written atomically, see AtomicWriteFile.
+// Files in filesystem that are not regular files or directories are reported as an
+// error.
+//
+// Unlike os.CopyFS, existing files are replaced by default. This takes a variadic
+// parameter of type CopyFSOptions. If no CopyFSOptions are supplied, then the defaults
+// are used. If more than one CopyFSOptions are supplied then only the first will be used.
+func CopyFS(filesystem fs.FS, destDir string, opts ...CopyFSOptions) error {
+	options := defaultCopyFSOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	fileOptions := CopyFileOptions{Overwrite: options.Overwrite}
+
+	return fs.WalkDir(filesystem, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		dst, err := filepath.Localize(path)
+		if err != nil {
+			return err
+		}
+		dst = filepath.Join(destDir, dst)
+
+		if entry.IsDir() {
+			return os.MkdirAll(dst, 0o777)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return &fs.PathError{Op: "copy", Path: path, Err: errors.New("not a regular file")}
+		}
+
+		if skip, err := fileOptions.skip(info, dst); skip || err != nil {
+			return err
+		}
+
+		perm := fs.FileMode(0o666)
+		if info.Mode()&0o111 != 0 {
+			perm = 0o777
+		}
+
+		source, err := filesystem.Open(path)
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+
+		return atomicWrite(dst, perm, func(file *os.File) error {
+			_, err := io.Copy(file, source)
+			return err
+		})
+	})
+}
@@ -0,0 +1,38 @@
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCopyFS(t *testing.T) {
+	filesystem := fstest.MapFS{
+		"index.html":     {Data: []byte("<html>")},
+		"static/app.js":  {Data: []byte("app")},
+		"static/run.sh":  {Data: []byte("#!/bin/sh"), Mode: 0o755},
+		"static/empty/x": {Data: nil},
+	}
+
+	dst := t.TempDir()
+	if err := CopyFS(filesystem, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, file := range filesystem {
+		data, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(file.Data) {
+			t.Errorf("read %q from %s, want %q", data, name, file.Data)
+		}
+	}
+
+	if err := CopyFS(filesystem, dst, CopyFSOptions{Overwrite: OverwriteNever}); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected fs.ErrExist, got %v", err)
+	}
+}
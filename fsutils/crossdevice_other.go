@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package fsutils
+
+// isCrossDevice reports whether err is caused by a rename across filesystems, which
+// cannot be detected on this platform.
+func isCrossDevice(_ error) bool {
+	return false
+}
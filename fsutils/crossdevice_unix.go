@@ -0,0 +1,13 @@
+//go:build unix
+
+package fsutils
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDevice reports whether err is caused by a rename across filesystems.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,15 @@
+package fsutils
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errorNotSameDevice is ERROR_NOT_SAME_DEVICE, which is returned by renames across
+// volumes.
+const errorNotSameDevice syscall.Errno = 17
+
+// isCrossDevice reports whether err is caused by a rename across volumes.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
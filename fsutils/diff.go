@@ -0,0 +1,198 @@
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DirDiffOptions is a struct used by DirDiff to define certain optional parameters.
+type DirDiffOptions struct {
+	// IgnoreMode leaves permission bits out of the metadata comparison.
+	IgnoreMode bool
+
+	// IgnoreModTime leaves modification times out of the metadata comparison.
+	IgnoreModTime bool
+}
+
+func defaultDirDiffOptions() DirDiffOptions {
+	return DirDiffOptions{}
+}
+
+// DirDiffResult describes the differences between two directory trees, a and b, found by
+// DirDiff. Paths are relative to the roots of the trees and are in lexical order. When a
+// directory is only in one of the trees, only the directory itself is listed, and not
+// its contents.
+type DirDiffResult struct {
+	// OnlyInA are the paths that are in a but not in b.
+	OnlyInA []string
+
+	// OnlyInB are the paths that are in b but not in a.
+	OnlyInB []string
+
+	// ContentDiffers are the paths of the files whose contents differ, of the symbolic
+	// links whose targets differ, and of those that are of different types in a and b.
+	ContentDiffers []string
+
+	// MetadataDiffers are the paths that have the same contents but different permission
+	// bits or modification times, to the second.
+	MetadataDiffers []string
+}
+
+// Equal reports whether no differences were found.
+func (ddr DirDiffResult) Equal() bool {
+	return len(ddr.OnlyInA) == 0 && len(ddr.OnlyInB) == 0 && len(ddr.ContentDiffers) == 0 && len(ddr.MetadataDiffers) == 0
+}
+
+// DirDiff compares the directory trees at a and b, and returns the differences between
+// them. Symbolic links are compared by their targets, and are not followed. This can be
+// used to verify backups and copies, or to check the output of tests.
+//
+// This takes a variadic parameter of type DirDiffOptions. If no DirDiffOptions are
+// supplied, then the defaults are used. If more than one DirDiffOptions are supplied
+// then only the first will be used.
+func DirDiff(a, b string, opts ...DirDiffOptions) (DirDiffResult, error) {
+	options := defaultDirDiffOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	var result DirDiffResult
+	for _, root := range []string{a, b} {
+		if info, err := os.Stat(root); err != nil {
+			return result, err
+		} else if !info.IsDir() {
+			return result, &fs.PathError{Op: "diff", Path: root, Err: errors.New("not a directory")}
+		}
+	}
+
+	err := filepath.WalkDir(a, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(a, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		infoA, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		infoB, err := os.Lstat(filepath.Join(b, rel))
+		if errors.Is(err, fs.ErrNotExist) {
+			result.OnlyInA = append(result.OnlyInA, rel)
+			return skipDir(entry)
+		}
+		if err != nil {
+			return err
+		}
+
+		if infoA.Mode().Type() != infoB.Mode().Type() {
+			result.ContentDiffers = append(result.ContentDiffers, rel)
+			return skipDir(entry)
+		}
+
+		same, err := sameEntry(path, infoA, filepath.Join(b, rel), infoB)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !same:
+			result.ContentDiffers = append(result.ContentDiffers, rel)
+		case !options.sameMetadata(infoA, infoB):
+			result.MetadataDiffers = append(result.MetadataDiffers, rel)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	err = filepath.WalkDir(b, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(b, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		infoA, err := os.Lstat(filepath.Join(a, rel))
+		if errors.Is(err, fs.ErrNotExist) {
+			result.OnlyInB = append(result.OnlyInB, rel)
+			return skipDir(entry)
+		}
+		if err != nil {
+			return err
+		}
+
+		// The contents of a directory in b that is something else in a have already been
+		// reported as a difference.
+		if !infoA.IsDir() {
+			return skipDir(entry)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// sameEntry reports whether the entries at a and b, which have infoA and infoB of the
+// same type, have the same contents. Only files and symbolic links are compared.
+func sameEntry(a string, infoA fs.FileInfo, b string, infoB fs.FileInfo) (bool, error) {
+	switch {
+	case infoA.Mode().IsRegular():
+		if infoA.Size() != infoB.Size() {
+			return false, nil
+		}
+
+		return sameContents(a, b)
+	case infoA.Mode()&fs.ModeSymlink != 0:
+		targetA, err := os.Readlink(a)
+		if err != nil {
+			return false, err
+		}
+
+		targetB, err := os.Readlink(b)
+		if err != nil {
+			return false, err
+		}
+
+		return targetA == targetB, nil
+	default:
+		return true, nil
+	}
+}
+
+// sameMetadata reports whether infoA and infoB have the same metadata, ignoring what the
+// options ask to be ignored. Directories are only compared by their permission bits, as
+// their modification times change whenever their contents do.
+func (ddo DirDiffOptions) sameMetadata(infoA, infoB fs.FileInfo) bool {
+	if !ddo.IgnoreMode && infoA.Mode().Perm() != infoB.Mode().Perm() {
+		return false
+	}
+
+	if !ddo.IgnoreModTime && !infoA.IsDir() && infoA.Mode()&fs.ModeSymlink == 0 {
+		return infoA.ModTime().Unix() == infoB.ModTime().Unix()
+	}
+
+	return true
+}
+
+// skipDir returns filepath.SkipDir if entry is a directory, so that its contents are not
+// walked, and nil otherwise.
+func skipDir(entry fs.DirEntry) error {
+	if entry.IsDir() {
+		return filepath.SkipDir
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDirDiff(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+
+	write := func(path, contents string) {
+		t.Helper()
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	modTime := time.Now().Add(-time.Hour)
+	for _, root := range []string{a, b} {
+		write(filepath.Join(root, "same"), "same")
+		write(filepath.Join(root, "changed"), root)
+		write(filepath.Join(root, "touched"), "touched")
+
+		for _, name := range []string{"same", "changed"} {
+			if err := os.Chtimes(filepath.Join(root, name), modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := os.Chtimes(filepath.Join(a, "touched"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Join(a, "dir", "only-a"), "a")
+	write(filepath.Join(b, "only-b"), "b")
+
+	result, err := DirDiff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, check := range map[string]struct{ got, want []string }{
+		"OnlyInA":         {result.OnlyInA, []string{"dir"}},
+		"OnlyInB":         {result.OnlyInB, []string{"only-b"}},
+		"ContentDiffers":  {result.ContentDiffers, []string{"changed"}},
+		"MetadataDiffers": {result.MetadataDiffers, []string{"touched"}},
+	} {
+		if !slices.Equal(check.got, check.want) {
+			t.Errorf("expected %s to be %v, got %v", name, check.want, check.got)
+		}
+	}
+
+	if result, err = DirDiff(a, a); err != nil || !result.Equal() {
+		t.Errorf("expected a directory to equal itself, got %+v, %v", result, err)
+	}
+}
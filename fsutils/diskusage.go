@@ -0,0 +1,25 @@
+package fsutils
+
+// Usage describes the space on a filesystem, as returned by DiskUsage.
+type Usage struct {
+	// Total is the size of the filesystem, in bytes.
+	Total uint64
+
+	// Used is the number of bytes in use.
+	Used uint64
+
+	// Free is the number of bytes not in use, including any that are reserved for
+	// privileged users.
+	Free uint64
+
+	// Available is the number of bytes that are free for unprivileged users, which may be
+	// less than Free.
+	Available uint64
+}
+
+// DiskUsage returns the space on the filesystem that contains path. This uses statfs
+// on Unix and GetDiskFreeSpaceEx on Windows, and fails with an error wrapping
+// errors.ErrUnsupported on other platforms.
+func DiskUsage(path string) (Usage, error) {
+	return diskUsage(path)
+}
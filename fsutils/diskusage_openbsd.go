@@ -0,0 +1,23 @@
+package fsutils
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func diskUsage(path string) (Usage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return Usage{}, &fs.PathError{Op: "statfs", Path: path, Err: err}
+	}
+
+	blockSize := uint64(stat.F_bsize)
+	usage := Usage{
+		Total:     stat.F_blocks * blockSize,
+		Free:      stat.F_bfree * blockSize,
+		Available: uint64(max(stat.F_bavail, 0)) * blockSize,
+	}
+	usage.Used = usage.Total - usage.Free
+
+	return usage, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !openbsd && !windows
+
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+)
+
+func diskUsage(path string) (Usage, error) {
+	return Usage{}, &fs.PathError{Op: "statfs", Path: path, Err: errors.ErrUnsupported}
+}
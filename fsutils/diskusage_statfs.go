@@ -0,0 +1,26 @@
+//go:build linux || darwin || dragonfly || freebsd
+
+package fsutils
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func diskUsage(path string) (Usage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return Usage{}, &fs.PathError{Op: "statfs", Path: path, Err: err}
+	}
+
+	// The types of the fields differ between platforms, so they are all converted.
+	blockSize := uint64(stat.Bsize)
+	usage := Usage{
+		Total:     uint64(stat.Blocks) * blockSize,
+		Free:      uint64(stat.Bfree) * blockSize,
+		Available: uint64(stat.Bavail) * blockSize,
+	}
+	usage.Used = usage.Total - usage.Free
+
+	return usage, nil
+}
@@ -0,0 +1,20 @@
+package fsutils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	usage, err := DiskUsage(t.TempDir())
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage.Total == 0 || usage.Used+usage.Free != usage.Total || usage.Available > usage.Free {
+		t.Errorf("inconsistent usage: %+v", usage)
+	}
+}
@@ -0,0 +1,30 @@
+package fsutils
+
+import (
+	"io/fs"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+func diskUsage(path string) (Usage, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return Usage{}, &fs.PathError{Op: "GetDiskFreeSpaceEx", Path: path, Err: err}
+	}
+
+	var usage Usage
+	ok, _, err := procGetDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(name)),
+		uintptr(unsafe.Pointer(&usage.Available)),
+		uintptr(unsafe.Pointer(&usage.Total)),
+		uintptr(unsafe.Pointer(&usage.Free)),
+	)
+	if ok == 0 {
+		return Usage{}, &fs.PathError{Op: "GetDiskFreeSpaceEx", Path: path, Err: err}
+	}
+	usage.Used = usage.Total - usage.Free
+
+	return usage, nil
+}
@@ -0,0 +1,168 @@
+package fsutils
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// DuplicateSet is a set of files with the same contents, found by FindDuplicates.
+type DuplicateSet struct {
+	// Size is the size of each of the files, in bytes.
+	Size int64
+
+	// Paths are the paths of the files, in lexical order.
+	Paths []string
+}
+
+// Wasted returns the number of bytes that would be freed by keeping only one of the
+// files in the set.
+func (ds DuplicateSet) Wasted() int64 {
+	return ds.Size * int64(len(ds.Paths)-1)
+}
+
+// FindDuplicatesOptions is a struct used by FindDuplicates to define certain optional
+// parameters.
+type FindDuplicatesOptions struct {
+	// Workers is the number of files that are hashed concurrently. If it is zero or
+	// negative, runtime.NumCPU is used.
+	Workers int
+
+	// MinSize is the size, in bytes, that files must be at least to be considered. This
+	// is 1 by default, which leaves out empty files.
+	MinSize int64
+}
+
+func defaultFindDuplicatesOptions() FindDuplicatesOptions {
+	return FindDuplicatesOptions{
+		MinSize: 1,
+	}
+}
+
+// FindDuplicates walks the directory tree at root, and returns the sets of regular files
+// that have the same contents, ordered by how many bytes they waste, largest first.
+// Symbolic links are not followed, and hard links to the same file are only counted
+// once.
+//
+// Files are first grouped by size, and only the files that share a size with another are
+// read and compared by their SHA-256 hashes, which is done concurrently.
+//
+// This takes a variadic parameter of type FindDuplicatesOptions. If no
+// FindDuplicatesOptions are supplied, then the defaults are used. If more than one
+// FindDuplicatesOptions are supplied then only the first will be used.
+func FindDuplicates(root string, opts ...FindDuplicatesOptions) ([]DuplicateSet, error) {
+	options := defaultFindDuplicatesOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	type file struct {
+		path string
+		info fs.FileInfo
+	}
+
+	bySize := make(map[int64][]file)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() < options.MinSize {
+			return nil
+		}
+
+		files := bySize[info.Size()]
+		if !slices.ContainsFunc(files, func(f file) bool { return os.SameFile(f.info, info) }) {
+			bySize[info.Size()] = append(files, file{path, info})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		size int64
+		hash [sha256.Size]byte
+	}
+
+	var (
+		mu     sync.Mutex
+		byHash = make(map[key][]string)
+		errs   []error
+	)
+
+	paths := make(chan file)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for f := range paths {
+				hash, err := sha256File(f.path)
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					k := key{f.info.Size(), hash}
+					byHash[k] = append(byHash[k], f.path)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, files := range bySize {
+		if len(files) < 2 {
+			continue
+		}
+
+		for _, f := range files {
+			paths <- f
+		}
+	}
+	close(paths)
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var sets []DuplicateSet
+	for k, paths := range byHash {
+		if len(paths) < 2 {
+			continue
+		}
+
+		slices.Sort(paths)
+		sets = append(sets, DuplicateSet{Size: k.size, Paths: paths})
+	}
+
+	slices.SortFunc(sets, func(a, b DuplicateSet) int {
+		if c := cmp.Compare(b.Wasted(), a.Wasted()); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Paths[0], b.Paths[0])
+	})
+
+	return sets, nil
+}
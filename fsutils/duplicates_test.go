@@ -0,0 +1,48 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	root := t.TempDir()
+
+	for name, contents := range map[string]string{
+		"a":     "duplicate",
+		"b":     "duplicate",
+		"sub/c": "duplicate",
+		"d":     "different",
+		"e":     "unique",
+		"f":     "xy",
+		"g":     "xy",
+		"empty": "",
+		"blank": "",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sets, err := FindDuplicates(root, FindDuplicatesOptions{Workers: 2, MinSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 2 {
+		t.Fatalf("expected two duplicate sets, got %+v", sets)
+	}
+
+	want := []string{filepath.Join(root, "a"), filepath.Join(root, "b"), filepath.Join(root, "sub", "c")}
+	if !slices.Equal(sets[0].Paths, want) || sets[0].Wasted() != 18 {
+		t.Errorf("expected %v to waste 18 bytes, got %+v", want, sets[0])
+	}
+	if len(sets[1].Paths) != 2 || sets[1].Size != 2 {
+		t.Errorf("expected the second set to be the two-byte files, got %+v", sets[1])
+	}
+}
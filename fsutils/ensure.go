@@ -0,0 +1,50 @@
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotDir is returned by EnsureDir and EnsureParentDir, wrapped in an *fs.PathError
+// naming the offending path, when the directory or one of its ancestors exists but is not
+// a directory.
+var ErrNotDir = errors.New("exists but is not a directory")
+
+// EnsureDir creates the directory at path with perm, before the umask, along with any
+// missing ancestors, and does nothing if it already exists. Unlike os.MkdirAll, a path
+// in the way that is not a directory is reported with an error wrapping ErrNotDir that
+// names it, so that it can be told apart from other failures, such as those wrapping
+// fs.ErrPermission.
+func EnsureDir(path string, perm fs.FileMode) error {
+	err := os.MkdirAll(path, perm)
+	if err == nil {
+		return nil
+	}
+
+	// Find the deepest part of path that exists, which is what stopped it from being
+	// created if it is not a directory.
+	for current := filepath.Clean(path); ; current = filepath.Dir(current) {
+		info, statErr := os.Stat(current)
+		if statErr == nil {
+			if !info.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: current, Err: ErrNotDir}
+			}
+
+			break
+		}
+
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	return err
+}
+
+// EnsureParentDir is like EnsureDir, but creates the directory that the file at
+// filePath is in, so that it can then be created.
+func EnsureParentDir(filePath string, perm fs.FileMode) error {
+	return EnsureDir(filepath.Dir(filePath), perm)
+}
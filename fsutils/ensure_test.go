@@ -0,0 +1,41 @@
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "a", "b")
+
+	for range 2 {
+		if err := EnsureDir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{file, filepath.Join(file, "sub", "dir")} {
+		err := EnsureDir(path, 0o755)
+
+		var pathErr *fs.PathError
+		if !errors.Is(err, ErrNotDir) || !errors.As(err, &pathErr) || pathErr.Path != file {
+			t.Errorf("expected ErrNotDir for %q, got %v", file, err)
+		}
+	}
+
+	if err := EnsureParentDir(filepath.Join(root, "c", "file"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(root, "c")); err != nil || !info.IsDir() {
+		t.Errorf("expected the parent directory to be created, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package fsutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// FileFormat is an encoding used by LoadFile and SaveFile. Only JSON is provided, to avoid
+// depending on packages outside of the standard library, but others can be defined in the
+// same way. For example, YAML using gopkg.in/yaml.v3, or TOML using
+// github.com/BurntSushi/toml, whose functions have the same signatures:
+//
+//	var YAML = fsutils.FileFormat{Name: "yaml", Marshal: yaml.Marshal, Unmarshal: yaml.Unmarshal}
+//	var TOML = fsutils.FileFormat{Name: "toml", Marshal: toml.Marshal, Unmarshal: toml.Unmarshal}
+type FileFormat struct {
+	// Name is the name of the format.
+	Name string
+
+	// Marshal encodes v.
+	Marshal func(v any) ([]byte, error)
+
+	// Unmarshal decodes data into v, which is a pointer.
+	Unmarshal func(data []byte, v any) error
+}
+
+// JSON is the JSON FileFormat, using encoding/json. Values are indented with two spaces
+// and followed by a newline, so that the files are readable and diff well, see SaveJSON
+// for compact output.
+var JSON = FileFormat{
+	Name: "json",
+	Marshal: func(v any) ([]byte, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return append(data, '\n'), nil
+	},
+	Unmarshal: json.Unmarshal,
+}
+
+// SaveOptions is a struct used by SaveFile and SaveJSON to define certain optional
+// parameters.
+type SaveOptions struct {
+	// Perm is the permissions that the file is created with, before the umask.
+	Perm fs.FileMode
+}
+
+func defaultSaveOptions() SaveOptions {
+	return SaveOptions{
+		Perm: 0o644,
+	}
+}
+
+// LoadJSONOptions is a struct used by LoadJSON to define certain optional parameters.
+type LoadJSONOptions struct {
+	// DisallowUnknownFields makes it an error for the file to hold object keys that do not
+	// match any field of T, which catches typos in hand-written files.
+	DisallowUnknownFields bool
+}
+
+func defaultLoadJSONOptions() LoadJSONOptions {
+	return LoadJSONOptions{}
+}
+
+// SaveJSONOptions is a struct used by SaveJSON to define certain optional parameters.
+type SaveJSONOptions struct {
+	SaveOptions
+
+	// Compact writes the value on a single line, without indentation.
+	Compact bool
+}
+
+func defaultSaveJSONOptions() SaveJSONOptions {
+	return SaveJSONOptions{
+		SaveOptions: defaultSaveOptions(),
+	}
+}
+
+// LoadFile reads the file at path and decodes it into a value of type T using format.
+func LoadFile[T any](path string, format FileFormat) (T, error) {
+	var value T
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return value, err
+	}
+
+	if err := format.Unmarshal(data, &value); err != nil {
+		return value, fmt.Errorf("could not decode %q as %s: %w", path, format.Name, err)
+	}
+
+	return value, nil
+}
+
+// SaveFile encodes value using format and writes it to the file at path atomically, see
+// AtomicWriteFile, so that a failed save never leaves a partially written file behind.
+//
+// This takes a variadic parameter of type SaveOptions. If no SaveOptions are supplied,
+// then the defaults are used. If more than one SaveOptions are supplied then only the
+// first will be used.
+func SaveFile[T any](path string, value T, format FileFormat, opts ...SaveOptions) error {
+	options := defaultSaveOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Perm == 0 {
+		options.Perm = defaultSaveOptions().Perm
+	}
+
+	data, err := format.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("could not encode %q as %s: %w", path, format.Name, err)
+	}
+
+	return AtomicWriteFile(path, data, options.Perm)
+}
+
+// LoadJSON reads the JSON file at path into a value of type T.
+//
+// This takes a variadic parameter of type LoadJSONOptions. If no LoadJSONOptions are
+// supplied, then the defaults are used. If more than one LoadJSONOptions are supplied
+// then only the first will be used.
+func LoadJSON[T any](path string, opts ...LoadJSONOptions) (T, error) {
+	options := defaultLoadJSONOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	format := JSON
+	if options.DisallowUnknownFields {
+		format.Unmarshal = func(data []byte, v any) error {
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.DisallowUnknownFields()
+
+			return decoder.Decode(v)
+		}
+	}
+
+	return LoadFile[T](path, format)
+}
+
+// SaveJSON writes value to the file at path as indented JSON, atomically. See SaveFile.
+//
+// This takes a variadic parameter of type SaveJSONOptions. If no SaveJSONOptions are
+// supplied, then the defaults are used. If more than one SaveJSONOptions are supplied
+// then only the first will be used.
+func SaveJSON[T any](path string, value T, opts ...SaveJSONOptions) error {
+	options := defaultSaveJSONOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	format := JSON
+	if options.Compact {
+		format.Marshal = json.Marshal
+	}
+
+	return SaveFile(path, value, format, options.SaveOptions)
+}
@@ -0,0 +1,43 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testConfig struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestSaveLoadJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	if err := SaveJSON(path, testConfig{Name: "example", Count: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"name\": \"example\",\n  \"count\": 3\n}\n"; string(data) != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+
+	config, err := LoadJSON[testConfig](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != (testConfig{Name: "example", Count: 3}) {
+		t.Errorf("unexpected config %+v", config)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"name": "example", "cuont": 3}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadJSON[testConfig](path, LoadJSONOptions{DisallowUnknownFields: true}); err == nil {
+		t.Error("expected an error for the unknown field")
+	}
+}
@@ -0,0 +1,217 @@
+package fsutils
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// GlobAll returns the paths on the system filesystem that match pattern, in lexical
+// order and without duplicates. Unlike filepath.Glob, a path segment of "**" matches any
+// number of directories, including none, so "src/**/*.go" matches "src/main.go" and
+// "src/a/b/util.go", and brace expansion is supported, so "*.{png,jpg}" matches files
+// with either extension. Braces can be nested, and are expanded before anything else
+// is matched. Within segments, the syntax of path.Match is used. "**" does not follow
+// symbolic links to directories, which avoids cycles. As with filepath.Glob, errors
+// reading directories are ignored, and the only possible error is path.ErrBadPattern.
+func GlobAll(pattern string) ([]string, error) {
+	patterns, err := expandBraces(filepath.ToSlash(pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, pattern := range patterns {
+		segments := strings.Split(pattern, "/")
+
+		// The leading segments without any wildcards are the directory to search from.
+		static := 0
+		for static < len(segments) && !hasGlobMeta(segments[static]) {
+			static++
+		}
+
+		if static == len(segments) {
+			if _, err := os.Lstat(filepath.FromSlash(pattern)); err == nil {
+				matches = append(matches, filepath.FromSlash(pattern))
+			}
+
+			continue
+		}
+
+		base := strings.Join(segments[:static], "/")
+		switch {
+		case static > 0 && base == "":
+			base = "/"
+		case base == "":
+			base = "."
+		case strings.HasSuffix(base, ":"):
+			// A Windows volume name such as "C:".
+			base += "/"
+		}
+
+		found, err := globSegments(os.DirFS(base), ".", segments[static:])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range found {
+			matches = append(matches, filepath.Join(filepath.FromSlash(base), filepath.FromSlash(match)))
+		}
+	}
+
+	slices.Sort(matches)
+
+	return slices.Compact(matches), nil
+}
+
+// GlobAllOnFS returns the paths on filesystem that match pattern, which is
+// slash-separated and relative to its root. See GlobAll.
+func GlobAllOnFS(filesystem fs.FS, pattern string) ([]string, error) {
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, pattern := range patterns {
+		found, err := globSegments(filesystem, ".", strings.Split(pattern, "/"))
+		if err != nil {
+			return nil, err
+		}
+
+		matches = append(matches, found...)
+	}
+
+	slices.Sort(matches)
+
+	return slices.Compact(matches), nil
+}
+
+// globSegments returns the paths beneath dir on filesystem that match segments, the
+// slash-separated parts of a pattern without braces.
+func globSegments(filesystem fs.FS, dir string, segments []string) ([]string, error) {
+	if len(segments) == 0 {
+		return []string{dir}, nil
+	}
+
+	segment, rest := segments[0], segments[1:]
+	switch {
+	case segment == "**":
+		// Match no directories, and then every directory with the "**" still in place.
+		matches, err := globSegments(filesystem, dir, rest)
+		if err != nil {
+			return nil, err
+		}
+
+		entries, _ := fs.ReadDir(filesystem, dir)
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				if len(rest) == 0 {
+					matches = append(matches, path.Join(dir, entry.Name()))
+				}
+
+				continue
+			}
+
+			found, err := globSegments(filesystem, path.Join(dir, entry.Name()), segments)
+			if err != nil {
+				return nil, err
+			}
+
+			matches = append(matches, found...)
+		}
+
+		return matches, nil
+	case !hasGlobMeta(segment):
+		name := path.Join(dir, segment)
+		if _, err := fs.Stat(filesystem, name); err != nil {
+			return nil, nil
+		}
+
+		return globSegments(filesystem, name, rest)
+	}
+
+	if _, err := path.Match(segment, ""); err != nil {
+		return nil, err
+	}
+
+	entries, _ := fs.ReadDir(filesystem, dir)
+
+	var matches []string
+	for _, entry := range entries {
+		if ok, _ := path.Match(segment, entry.Name()); !ok {
+			continue
+		}
+
+		found, err := globSegments(filesystem, path.Join(dir, entry.Name()), rest)
+		if err != nil {
+			return nil, err
+		}
+
+		matches = append(matches, found...)
+	}
+
+	return matches, nil
+}
+
+// hasGlobMeta reports whether segment contains any of the special characters of
+// path.Match.
+func hasGlobMeta(segment string) bool {
+	return strings.ContainsAny(segment, `*?[\`)
+}
+
+// expandBraces returns the patterns that pattern expands to, with each group of
+// comma-separated alternatives in braces replaced by each of its alternatives in turn.
+func expandBraces(pattern string) ([]string, error) {
+	start, depth := -1, 0
+	var commas []int
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+				commas = commas[:0]
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				return nil, path.ErrBadPattern
+			}
+
+			depth--
+			if depth > 0 {
+				continue
+			}
+
+			var expanded []string
+			from := start + 1
+			for _, to := range append(commas, i) {
+				alternatives, err := expandBraces(pattern[:start] + pattern[from:to] + pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+
+				expanded = append(expanded, alternatives...)
+				from = to + 1
+			}
+
+			return expanded, nil
+		}
+	}
+
+	if depth != 0 {
+		return nil, path.ErrBadPattern
+	}
+
+	return []string{pattern}, nil
+}
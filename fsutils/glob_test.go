@@ -0,0 +1,72 @@
+package fsutils
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGlobAllOnFS(t *testing.T) {
+	filesystem := fstest.MapFS{
+		"main.go":             {},
+		"README.md":           {},
+		"src/util.go":         {},
+		"src/a/b/deep.go":     {},
+		"src/a/b/image.png":   {},
+		"src/a/photo.jpg":     {},
+		"src/a/notes.txt":     {},
+		"vendor/lib/extra.go": {},
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.go", []string{"main.go"}},
+		{"src/**/*.go", []string{"src/a/b/deep.go", "src/util.go"}},
+		{"**/*.go", []string{"main.go", "src/a/b/deep.go", "src/util.go", "vendor/lib/extra.go"}},
+		{"src/**/*.{png,jpg}", []string{"src/a/b/image.png", "src/a/photo.jpg"}},
+		{"{src,vendor}/*/{b/*.go,*.go}", []string{"src/a/b/deep.go", "vendor/lib/extra.go"}},
+		{"src/a/**", []string{"src/a", "src/a/b", "src/a/b/deep.go", "src/a/b/image.png", "src/a/notes.txt", "src/a/photo.jpg"}},
+		{"missing/**/*.go", nil},
+	}
+
+	for _, test := range tests {
+		matches, err := GlobAllOnFS(filesystem, test.pattern)
+		if err != nil {
+			t.Errorf("%q: %v", test.pattern, err)
+			continue
+		}
+		if !slices.Equal(matches, test.want) {
+			t.Errorf("%q: expected %q, got %q", test.pattern, test.want, matches)
+		}
+	}
+
+	for _, pattern := range []string{"{a,b", "a}", "[a"} {
+		if _, err := GlobAllOnFS(filesystem, pattern); !errors.Is(err, path.ErrBadPattern) {
+			t.Errorf("%q: expected path.ErrBadPattern, got %v", pattern, err)
+		}
+	}
+}
+
+func TestGlobAll(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", filepath.Join("sub", "b.go"), filepath.Join("sub", "c.txt")} {
+		if err := Touch(filepath.Join(dir, name), TouchOptions{CreateParents: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, err := GlobAll(filepath.Join(dir, "**", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "sub", "b.go")}
+	if !slices.Equal(matches, want) {
+		t.Errorf("expected %q, got %q", want, matches)
+	}
+}
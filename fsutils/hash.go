@@ -0,0 +1,58 @@
+package fsutils
+
+import (
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+)
+
+// HashFile returns the digest of the contents of the file at path, computed with h, which
+// can be any hash.Hash, such as one from crypto/sha256, crypto/sha1, crypto/md5, or a
+// third-party xxhash package. The file is streamed through h rather than read into
+// memory. h is reset first, so it can be reused across calls.
+func HashFile(path string, h hash.Hash) ([]byte, error) {
+	sums, err := HashFileMulti(path, h)
+	if err != nil {
+		return nil, err
+	}
+
+	return sums[0], nil
+}
+
+// HashFileMulti returns the digests of the contents of the file at path computed with
+// each of hashes, in the same order, reading the file only once. See HashFile.
+func HashFileMulti(path string, hashes ...hash.Hash) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	writers := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		h.Reset()
+		writers[i] = h
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return nil, err
+	}
+
+	sums := make([][]byte, len(hashes))
+	for i, h := range hashes {
+		sums[i] = h.Sum(nil)
+	}
+
+	return sums, nil
+}
+
+// sha256File returns the SHA-256 hash of the contents of the file at path.
+func sha256File(path string) ([sha256.Size]byte, error) {
+	sum, err := HashFile(path, sha256.New())
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+
+	return [sha256.Size]byte(sum), nil
+}
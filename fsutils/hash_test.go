@@ -0,0 +1,36 @@
+package fsutils
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sha := sha256.New()
+	sum, err := HashFile(path, sha)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const want = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if hex.EncodeToString(sum) != want {
+		t.Errorf("expected %s, got %x", want, sum)
+	}
+
+	sums, err := HashFileMulti(path, sha, md5.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(sums[0]) != want || hex.EncodeToString(sums[1]) != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("unexpected digests %x", sums)
+	}
+}
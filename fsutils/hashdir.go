@@ -0,0 +1,104 @@
+package fsutils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// HashDirOptions is a struct used by HashDir to define certain optional parameters.
+type HashDirOptions struct {
+	// Hash creates the hash.Hash used for the contents of each file and for the tree as a
+	// whole. By default, crypto/sha256 is used.
+	Hash func() hash.Hash
+
+	// Exclude leaves out files and whole directories that match any of these patterns.
+	// See CopyDirOptions.Include for the syntax.
+	Exclude []string
+}
+
+func defaultHashDirOptions() HashDirOptions {
+	return HashDirOptions{
+		Hash: sha256.New,
+	}
+}
+
+// HashDir returns a digest of the directory tree at path, which covers the path relative
+// to the root, type, and permission bits of every entry, the contents of every file, and
+// the target of every symbolic link. Symbolic links are not followed, and modification
+// times and ownership are left out. The digest is the same for the same tree on any
+// machine that reports the same permissions, so it can be used to tell whether anything
+// in a tree has changed, such as for caching or checking deployments.
+//
+// This takes a variadic parameter of type HashDirOptions. If no HashDirOptions are
+// supplied, then the defaults are used. If more than one HashDirOptions are supplied
+// then only the first will be used.
+func HashDir(path string, opts ...HashDirOptions) ([]byte, error) {
+	options := defaultHashDirOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if options.Hash == nil {
+		options.Hash = sha256.New
+	}
+	if err := validatePatterns(options.Exclude); err != nil {
+		return nil, err
+	}
+
+	tree, contents := options.Hash(), options.Hash()
+	err := filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(path, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." && matchesAny(options.Exclude, rel) {
+			return skipDir(entry)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		var digest []byte
+		kind := 'o'
+		switch {
+		case info.IsDir():
+			kind = 'd'
+		case info.Mode().IsRegular():
+			kind = 'f'
+			if digest, err = HashFile(name, contents); err != nil {
+				return err
+			}
+		case info.Mode()&fs.ModeSymlink != 0:
+			kind = 'l'
+
+			target, err := os.Readlink(name)
+			if err != nil {
+				return err
+			}
+			digest = []byte(filepath.ToSlash(target))
+		}
+
+		// Every field is unambiguously delimited, so that different trees cannot produce
+		// the same input to the hash.
+		_, err = fmt.Fprintf(tree, "%c %04o %q %x\n", kind, info.Mode().Perm(), rel, digest)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tree.Sum(nil), nil
+}
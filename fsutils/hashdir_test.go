@@ -0,0 +1,53 @@
+package fsutils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashDir(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+
+	for _, root := range []string{a, b} {
+		if err := os.MkdirAll(filepath.Join(root, "sub"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "sub", "file"), []byte("contents"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hashA, err := HashDir(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashB, err := HashDir(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(hashA, hashB) {
+		t.Fatal("expected identical trees to have the same digest")
+	}
+
+	if err := os.WriteFile(filepath.Join(b, "sub", "file"), []byte("changed!"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if hashB, err = HashDir(b); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(hashA, hashB) {
+		t.Fatal("expected a changed file to change the digest")
+	}
+
+	if hashB, err = HashDir(b, HashDirOptions{Exclude: []string{"file"}}); err != nil {
+		t.Fatal(err)
+	}
+	if hashA, err = HashDir(a, HashDirOptions{Exclude: []string{"file"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(hashA, hashB) {
+		t.Fatal("expected excluded files to be left out of the digest")
+	}
+}
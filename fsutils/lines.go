@@ -0,0 +1,79 @@
+package fsutils
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+)
+
+// ReadLinesOptions is a struct used by ReadLines and ReadLinesOnFS to define certain
+// optional parameters.
+type ReadLinesOptions struct {
+	// MaxLineSize is the length, in bytes, that lines may be at most. A longer line stops
+	// the iteration with bufio.ErrTooLong. If it is zero or negative, 1MiB is used.
+	MaxLineSize int
+}
+
+func defaultReadLinesOptions() ReadLinesOptions {
+	return ReadLinesOptions{
+		MaxLineSize: 1 << 20,
+	}
+}
+
+// ReadLines returns an iterator over the lines of the file at path, without their line
+// endings, which reads the file as it goes rather than loading it all into memory. If
+// the file cannot be opened or read, the error is yielded and the iteration stops. The
+// file is closed when the iteration finishes or is stopped early.
+//
+// This takes a variadic parameter of type ReadLinesOptions. If no ReadLinesOptions are
+// supplied, then the defaults are used. If more than one ReadLinesOptions are supplied
+// then only the first will be used.
+func ReadLines(path string, opts ...ReadLinesOptions) iter.Seq2[string, error] {
+	return readLines(func() (io.ReadCloser, error) { return os.Open(path) }, opts)
+}
+
+// ReadLinesOnFS returns an iterator over the lines of the file at path on filesystem. See
+// ReadLines.
+//
+// This takes a variadic parameter of type ReadLinesOptions. If no ReadLinesOptions are
+// supplied, then the defaults are used. If more than one ReadLinesOptions are supplied
+// then only the first will be used.
+func ReadLinesOnFS(filesystem fs.FS, path string, opts ...ReadLinesOptions) iter.Seq2[string, error] {
+	return readLines(func() (io.ReadCloser, error) { return filesystem.Open(path) }, opts)
+}
+
+// readLines returns an iterator over the lines of the file returned by open, using the
+// first of opts, or the defaults if none are given.
+func readLines(open func() (io.ReadCloser, error), opts []ReadLinesOptions) iter.Seq2[string, error] {
+	options := defaultReadLinesOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.MaxLineSize <= 0 {
+		options.MaxLineSize = 1 << 20
+	}
+
+	return func(yield func(string, error) bool) {
+		file, err := open()
+		if err != nil {
+			yield("", err)
+			return
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, min(options.MaxLineSize, 64*1024)), options.MaxLineSize)
+
+		for scanner.Scan() {
+			if !yield(scanner.Text(), nil) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			yield("", err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package fsutils
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("one\r\ntwo\nthree"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for line, err := range ReadLines(path) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines = append(lines, line)
+	}
+	if want := []string{"one", "two", "three"}; !slices.Equal(lines, want) {
+		t.Errorf("expected %v, got %v", want, lines)
+	}
+
+	filesystem := fstest.MapFS{"long": {Data: []byte(strings.Repeat("x", 100) + "\n")}}
+	for _, err := range ReadLinesOnFS(filesystem, "long", ReadLinesOptions{MaxLineSize: 10}) {
+		if !errors.Is(err, bufio.ErrTooLong) {
+			t.Errorf("expected bufio.ErrTooLong, got %v", err)
+		}
+	}
+}
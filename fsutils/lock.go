@@ -0,0 +1,352 @@
+package fsutils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLocked is returned by TryLock when the lock is held by someone else.
+	ErrLocked = errors.New("file is locked")
+
+	// ErrLockLost is returned by Unlock when a lock file was taken over by someone else,
+	// after being considered stale.
+	ErrLockLost = errors.New("lock was taken over")
+
+	// errSharedLockFile is returned when a shared lock is asked for, but a lock file is
+	// used, which cannot be shared.
+	errSharedLockFile = errors.New("lock files cannot be shared")
+)
+
+// LockOptions is a struct used by Lock and TryLock to define certain optional
+// parameters.
+type LockOptions struct {
+	// Shared takes a shared lock, which can be held by several owners at once, as long as
+	// none hold an exclusive lock. This is not supported by lock files.
+	Shared bool
+
+	// LockFile uses a lock file, created exclusively at the path and holding the process
+	// ID and host name of its owner, instead of a lock provided by the operating system.
+	// This is useful on network filesystems where those are unreliable, and is always
+	// used on platforms that do not have them.
+	LockFile bool
+
+	// StaleAfter is how old a lock file can get before it is considered to have been
+	// abandoned, and is taken over. Lock files owned by processes on this host that are no
+	// longer running are always taken over. If it is zero or negative, lock files are
+	// never considered stale because of their age. It is ignored unless LockFile is set.
+	StaleAfter time.Duration
+
+	// PollInterval is how often Lock tries to take the lock while it is held by someone
+	// else.
+	PollInterval time.Duration
+}
+
+func defaultLockOptions() LockOptions {
+	return LockOptions{
+		PollInterval: 100 * time.Millisecond,
+	}
+}
+
+// FileLock is an advisory lock on a file, taken with Lock or TryLock. It only excludes
+// others that also take the lock, and does not stop the file from being read or written
+// otherwise.
+type FileLock struct {
+	path     string
+	file     *os.File
+	lockFile bool
+	owner    string
+
+	mu       sync.Mutex
+	released bool
+}
+
+// Lock takes the lock on the file at path, creating it if it does not exist, and waits
+// for as long as it is held by someone else, or until ctx is done, in which case the
+// error of ctx is returned. Locks provided by the operating system, which are used
+// unless LockFile is set, are released automatically if the process exits without
+// calling Unlock, so they cannot become stale.
+//
+// This takes a variadic parameter of type LockOptions. If no LockOptions are supplied,
+// then the defaults are used. If more than one LockOptions are supplied then only the
+// first will be used.
+func Lock(ctx context.Context, path string, opts ...LockOptions) (*FileLock, error) {
+	options := defaultLockOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	interval := options.PollInterval
+	if interval <= 0 {
+		interval = defaultLockOptions().PollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		lock, err := options.tryLock(path)
+		if !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// TryLock is like Lock, but returns an error wrapping ErrLocked instead of waiting if the
+// lock is held by someone else.
+//
+// This takes a variadic parameter of type LockOptions. If no LockOptions are supplied,
+// then the defaults are used. If more than one LockOptions are supplied then only the
+// first will be used.
+func TryLock(path string, opts ...LockOptions) (*FileLock, error) {
+	options := defaultLockOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	return options.tryLock(path)
+}
+
+// Path returns the path of the locked file.
+func (fl *FileLock) Path() string {
+	return fl.path
+}
+
+// Unlock releases the lock. A lock file is removed, while a file locked by the operating
+// system is left in place, as removing it would let another owner lock a new file at
+// the same path while the old one is still locked. If a lock file was taken over by
+// someone else, it is left in place, and an error wrapping ErrLockLost is returned.
+// Calling Unlock more than once returns os.ErrClosed.
+func (fl *FileLock) Unlock() error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	if fl.released {
+		return &fs.PathError{Op: "unlock", Path: fl.path, Err: os.ErrClosed}
+	}
+	fl.released = true
+
+	if fl.lockFile {
+		// Only remove the lock file if it is still this lock, and has not been taken
+		// over as stale.
+		current, err := os.ReadFile(fl.path)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && string(current) != fl.owner) {
+			return &fs.PathError{Op: "unlock", Path: fl.path, Err: ErrLockLost}
+		} else if err != nil {
+			return err
+		}
+
+		return os.Remove(fl.path)
+	}
+
+	err := unlockFile(fl.file)
+	if err != nil {
+		err = &fs.PathError{Op: "unlock", Path: fl.path, Err: err}
+	}
+
+	return errors.Join(err, fl.file.Close())
+}
+
+func (lo LockOptions) tryLock(path string) (*FileLock, error) {
+	if !lo.LockFile {
+		lock, err := lockPath(path, lo.Shared)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return lock, err
+		}
+	}
+
+	if lo.Shared {
+		return nil, &fs.PathError{Op: "lock", Path: path, Err: errSharedLockFile}
+	}
+
+	return lo.tryLockFile(path)
+}
+
+// lockPath locks the file at path with a lock provided by the operating system.
+func lockPath(path string, shared bool) (*FileLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(file, shared); err != nil {
+		_ = file.Close()
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil, err
+		}
+
+		return nil, &fs.PathError{Op: "lock", Path: path, Err: err}
+	}
+
+	return &FileLock{path: path, file: file}, nil
+}
+
+// lockTakeoverTimeout is how old the guard file that is held while a stale lock file is
+// replaced can get before it is considered abandoned, which only happens if its owner
+// exited in the middle of replacing the lock.
+const lockTakeoverTimeout = 10 * time.Second
+
+// tryLockFile creates a lock file at path, taking over an existing one if it is stale.
+func (lo LockOptions) tryLockFile(path string) (*FileLock, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	// The token tells this lock apart from any other, including later ones taken by the
+	// same process.
+	var token [16]byte
+	_, _ = rand.Read(token[:])
+	owner := fmt.Sprintf("%d\n%s\n%s\n", os.Getpid(), hostname, hex.EncodeToString(token[:]))
+
+	for attempt := 0; ; attempt++ {
+		err := createLockFile(path, owner)
+		if err == nil {
+			return &FileLock{path: path, lockFile: true, owner: owner}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		existing, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// The lock was released in the meantime.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		description, stale, err := lo.lockFileOwner(path, existing, hostname)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			return nil, err
+		case !stale || attempt > 0:
+			return nil, &fs.PathError{Op: "lock", Path: path, Err: fmt.Errorf("%w by %s", ErrLocked, description)}
+		}
+
+		if err := takeOverLockFile(path, existing, owner); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// createLockFile creates the lock file at path holding owner, or returns an error
+// wrapping fs.ErrExist if it already exists. The file is written in full under a
+// temporary name and then linked into place, so that it is never seen without its owner.
+func createLockFile(path, owner string) error {
+	temp, err := createTemp(filepath.Dir(path), filepath.Base(path), 0o644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.WriteString(owner)
+	if err = errors.Join(err, temp.Close()); err != nil {
+		return err
+	}
+
+	err = os.Link(temp.Name(), path)
+	if err == nil || errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	// Not every filesystem supports hard links, in which case the lock file is created in
+	// place, and is briefly empty.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteString(owner)
+	if err = errors.Join(err, file.Close()); err != nil {
+		_ = os.Remove(path)
+	}
+
+	return err
+}
+
+// takeOverLockFile removes the stale lock file at path, as long as it still holds stale,
+// so that a new one can be created. This is done while holding a guard file, so that if
+// several owners find the same lock stale, only one removes it, and the others do not
+// remove the lock that replaces it.
+func takeOverLockFile(path string, stale []byte, owner string) error {
+	guard := path + ".takeover"
+
+	err := createLockFile(guard, owner)
+	if errors.Is(err, fs.ErrExist) {
+		// Someone else is taking over, and the lock is tried again later.
+		if info, err := os.Stat(guard); err == nil && time.Since(info.ModTime()) > lockTakeoverTimeout {
+			_ = os.Remove(guard)
+		}
+
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer os.Remove(guard)
+
+	current, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case !bytes.Equal(current, stale):
+		// The lock has already been taken over.
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// lockFileOwner describes the owner of the lock file at path, which holds data, and
+// reports whether the lock is stale.
+func (lo LockOptions) lockFileOwner(path string, data []byte, hostname string) (string, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	if lo.StaleAfter > 0 && time.Since(info.ModTime()) > lo.StaleAfter {
+		return "an abandoned owner", true, nil
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		// The owner may still be writing its details.
+		return "an unknown owner", false, nil
+	}
+
+	description := "process " + fields[0] + " on " + fields[1]
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || fields[1] != hostname {
+		return description, false, nil
+	}
+
+	running, known := processRunning(pid)
+
+	return description, known && !running, nil
+}
@@ -0,0 +1,18 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package fsutils
+
+import (
+	"errors"
+	"os"
+)
+
+// Without locks provided by the operating system, lock files are used instead.
+
+func lockFile(*os.File, bool) error {
+	return errors.ErrUnsupported
+}
+
+func unlockFile(*os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,180 @@
+package fsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTryLock(t *testing.T) {
+	for _, options := range []LockOptions{{}, {LockFile: true}} {
+		t.Run(fmt.Sprintf("LockFile=%v", options.LockFile), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.lock")
+
+			lock, err := TryLock(path, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// flock locks conflict between separate open files, even in one process.
+			if _, err := TryLock(path, options); !errors.Is(err, ErrLocked) {
+				t.Fatalf("expected ErrLocked, got %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			options.PollInterval = time.Millisecond
+			if _, err := Lock(ctx, path, options); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected the deadline to be exceeded, got %v", err)
+			}
+
+			if err := lock.Unlock(); err != nil {
+				t.Fatal(err)
+			}
+			if err := lock.Unlock(); !errors.Is(err, os.ErrClosed) {
+				t.Errorf("expected os.ErrClosed, got %v", err)
+			}
+
+			lock, err = Lock(context.Background(), path, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := lock.Unlock(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestTryLockSharedLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.lock")
+
+	if _, err := TryLock(path, LockOptions{Shared: true, LockFile: true}); !errors.Is(err, errSharedLockFile) {
+		t.Fatalf("expected errSharedLockFile, got %v", err)
+	}
+}
+
+func TestLockFileStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.lock")
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A lock file left behind by a process that is no longer running.
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("%d\n%s\n", 1<<22+1, hostname)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if running, known := processRunning(1<<22 + 1); running || !known {
+		t.Skip("cannot tell that the process is not running")
+	}
+
+	lock, err := TryLock(path, LockOptions{LockFile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+
+	// A lock file that is merely old.
+	other := filepath.Join(filepath.Dir(path), "other.lock")
+	if err := os.WriteFile(other, []byte("1\nelsewhere\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TryLock(other, LockOptions{LockFile: true}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(other, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	otherLock, err := TryLock(other, LockOptions{LockFile: true, StaleAfter: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherLock.Unlock()
+}
+
+func TestLockFileConcurrentTakeover(t *testing.T) {
+	if running, known := processRunning(1<<22 + 1); running || !known {
+		t.Skip("cannot tell that the process is not running")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 20 {
+		path := filepath.Join(t.TempDir(), "state.lock")
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("%d\n%s\n", 1<<22+1, hostname)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Every contender finds the same stale lock, but only one may end up holding it.
+		var (
+			wg    sync.WaitGroup
+			held  atomic.Int32
+			locks = make(chan *FileLock, 8)
+		)
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if lock, err := TryLock(path, LockOptions{LockFile: true}); err == nil {
+					held.Add(1)
+					locks <- lock
+				} else if !errors.Is(err, ErrLocked) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		close(locks)
+
+		if held.Load() > 1 {
+			t.Fatalf("expected at most one owner, got %d", held.Load())
+		}
+		for lock := range locks {
+			if err := lock.Unlock(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestLockFileUnlockAfterTakeover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.lock")
+
+	lock, err := TryLock(path, LockOptions{LockFile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := TryLock(path, LockOptions{LockFile: true, StaleAfter: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Unlock()
+
+	if err := lock.Unlock(); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected ErrLockLost, got %v", err)
+	}
+	if !PathExists(path) {
+		t.Error("expected the lock that took over to be left in place")
+	}
+}
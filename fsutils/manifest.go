@@ -0,0 +1,161 @@
+package fsutils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumMismatch is a file that failed verification against a checksum manifest, as
+// returned by VerifyChecksumManifest.
+type ChecksumMismatch struct {
+	// Path is the path of the file as listed in the manifest.
+	Path string
+
+	// Want is the SHA-256 hash listed in the manifest, and Got is the hash of the file,
+	// both in hexadecimal. Got is empty if the file could not be hashed.
+	Want, Got string
+
+	// Err is the reason the file could not be hashed, such as it not existing, if any.
+	Err error
+}
+
+// String returns a description of the mismatch.
+func (cm ChecksumMismatch) String() string {
+	if cm.Err != nil {
+		return fmt.Sprintf("%s: %v", cm.Path, cm.Err)
+	}
+
+	return fmt.Sprintf("%s: checksum %s does not match %s", cm.Path, cm.Got, cm.Want)
+}
+
+// manifestEscaper and manifestUnescaper escape file names in checksum manifests in the
+// same way as sha256sum.
+var (
+	manifestEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+	manifestUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+)
+
+// WriteChecksumManifest writes the SHA-256 hash of every regular file in the directory
+// tree at dir to out, in the format of the SHA256SUMS files written by sha256sum, so it
+// can also be checked with "sha256sum -c" from within dir. Files are listed by their path
+// relative to dir, with forward slashes, in lexical order. Symbolic links are not
+// followed.
+func WriteChecksumManifest(dir string, out io.Writer) error {
+	writer := bufio.NewWriter(out)
+	sha := sha256.New()
+
+	err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+
+		sum, err := HashFile(name, sha)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		if escaped := manifestEscaper.Replace(rel); escaped != rel {
+			_, err = fmt.Fprintf(writer, "\\%x  %s\n", sum, escaped)
+		} else {
+			_, err = fmt.Fprintf(writer, "%x  %s\n", sum, rel)
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+// VerifyChecksumManifest checks the files in the directory at dir against manifest, which
+// is in the format written by WriteChecksumManifest and sha256sum, and returns the files
+// that are missing, cannot be read, or do not match, in the order they are listed.
+// Files in dir that are not listed are ignored, and listed paths that would escape dir
+// are reported as mismatches without being read. An error is only returned if manifest
+// cannot be read or is malformed.
+func VerifyChecksumManifest(dir string, manifest io.Reader) ([]ChecksumMismatch, error) {
+	var mismatches []ChecksumMismatch
+	sha := sha256.New()
+
+	scanner := bufio.NewScanner(manifest)
+	for line := 1; scanner.Scan(); line++ {
+		want, name, err := parseManifestLine(scanner.Bytes())
+		if err != nil {
+			return mismatches, fmt.Errorf("invalid checksum manifest line %d: %w", line, err)
+		}
+		if name == "" {
+			continue
+		}
+
+		mismatch := ChecksumMismatch{Path: name, Want: want}
+
+		local, err := filepath.Localize(path.Clean(name))
+		if err != nil {
+			mismatch.Err = fmt.Errorf("path escapes %q: %w", dir, err)
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+
+		sum, err := HashFile(filepath.Join(dir, local), sha)
+		if err != nil {
+			mismatch.Err = err
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+
+		if mismatch.Got = hex.EncodeToString(sum); mismatch.Got != want {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+
+	return mismatches, scanner.Err()
+}
+
+// parseManifestLine returns the lowercase hexadecimal hash and file name of a checksum
+// manifest line, or an empty name for a blank line.
+func parseManifestLine(line []byte) (sum, name string, err error) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(bytes.TrimSpace(line)) == 0 {
+		return "", "", nil
+	}
+
+	escaped := line[0] == '\\'
+	if escaped {
+		line = line[1:]
+	}
+
+	// Lines are "<hash>  <name>" in text mode or "<hash> *<name>" in binary mode.
+	sumBytes, rest, ok := bytes.Cut(line, []byte(" "))
+	if !ok || len(rest) < 2 || (rest[0] != ' ' && rest[0] != '*') {
+		return "", "", errors.New("expected a hash followed by a file name")
+	}
+
+	sum = strings.ToLower(string(sumBytes))
+	if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != sha256.Size {
+		return "", "", fmt.Errorf("invalid SHA-256 hash %q", sumBytes)
+	}
+
+	name = string(rest[1:])
+	if escaped {
+		name = manifestUnescaper.Replace(name)
+	}
+
+	return sum, name, nil
+}
@@ -0,0 +1,61 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksumManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, contents := range map[string]string{
+		"a":       "a",
+		"sub/b":   "b",
+		"sub/c":   "c",
+		`back\sl`: "d",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var manifest strings.Builder
+	if err := WriteChecksumManifest(dir, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(manifest.String(), "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a\n") {
+		t.Fatalf("unexpected manifest:\n%s", manifest.String())
+	}
+
+	mismatches, err := VerifyChecksumManifest(dir, strings.NewReader(manifest.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Fatalf("expected no mismatches, got %v", mismatches)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "sub", "c")); err != nil {
+		t.Fatal(err)
+	}
+
+	if mismatches, err = VerifyChecksumManifest(dir, strings.NewReader(manifest.String())); err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 2 || mismatches[0].Path != "sub/b" || mismatches[0].Err != nil || mismatches[1].Err == nil {
+		t.Fatalf("expected sub/b to differ and sub/c to be missing, got %v", mismatches)
+	}
+
+	if _, err := VerifyChecksumManifest(dir, strings.NewReader("not a manifest\n")); err == nil {
+		t.Fatal("expected a malformed manifest to fail")
+	}
+}
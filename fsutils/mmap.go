@@ -0,0 +1,115 @@
+package fsutils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrMappedFileClosed is returned when reading from a MappedFile that has been closed.
+var ErrMappedFileClosed = errors.New("mapped file closed")
+
+// MappedFile is a read-only view of the contents of a file that is mapped into memory,
+// as returned by Mmap.
+type MappedFile struct {
+	mu     sync.RWMutex
+	data   []byte
+	unmap  func() error
+	closed bool
+}
+
+// Mmap maps the contents of the file at path into memory, read-only, which allows for
+// fast random access to large files without reading them in full. This uses mmap on
+// Unix and CreateFileMapping on Windows. On other platforms, the file is read into memory
+// instead. On success, the new *MappedFile is returned. On failure, an error is returned.
+//
+// The file can be closed once mapped, but the mapping must be released with Close once
+// it is no longer needed. Changes made to the file while it is mapped may or may not be
+// visible.
+func Mmap(path string) (*MappedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if size != int64(int(size)) {
+		return nil, fmt.Errorf("%q is too large to map into memory", path)
+	}
+	if size == 0 {
+		// Empty files cannot be mapped.
+		return &MappedFile{unmap: func() error { return nil }}, nil
+	}
+
+	data, unmap, err := mmapFile(file, int(size))
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: path, Err: err}
+	}
+
+	return &MappedFile{data: data, unmap: unmap}, nil
+}
+
+// Bytes returns the contents of the file. The slice must not be modified, as the mapping
+// is read-only, and must not be used once the file has been closed, which may crash the
+// program.
+func (mf *MappedFile) Bytes() []byte {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	return mf.data
+}
+
+// Len returns the size of the file, in bytes.
+func (mf *MappedFile) Len() int {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	return len(mf.data)
+}
+
+// ReadAt copies the contents of the file starting at offset into p, implementing
+// io.ReaderAt. Unlike using Bytes, this is safe to call concurrently with Close.
+func (mf *MappedFile) ReadAt(p []byte, offset int64) (int, error) {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	if mf.closed {
+		return 0, ErrMappedFileClosed
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if offset >= int64(len(mf.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, mf.data[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Close releases the mapping. Closing a MappedFile more than once does nothing.
+func (mf *MappedFile) Close() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if mf.closed {
+		return nil
+	}
+
+	mf.closed = true
+	mf.data = nil
+
+	return mf.unmap()
+}
@@ -0,0 +1,18 @@
+//go:build !unix && !windows
+
+package fsutils
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile reads the file into memory, as it cannot be mapped on this platform.
+func mmapFile(file *os.File, size int) ([]byte, func() error, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return nil }, nil
+}
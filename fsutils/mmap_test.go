@@ -0,0 +1,40 @@
+package fsutils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMmap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("mapped contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mapped, err := Mmap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(mapped.Bytes()) != "mapped contents" || mapped.Len() != 15 {
+		t.Errorf("unexpected contents %q", mapped.Bytes())
+	}
+
+	buf := make([]byte, 10)
+	if n, err := mapped.ReadAt(buf, 7); n != 8 || !errors.Is(err, io.EOF) || string(buf[:n]) != "contents" {
+		t.Errorf("unexpected ReadAt result %d, %v, %q", n, err, buf[:n])
+	}
+
+	if err := mapped.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mapped.Close(); err != nil {
+		t.Errorf("expected closing twice to do nothing, got %v", err)
+	}
+	if _, err := mapped.ReadAt(buf, 0); !errors.Is(err, ErrMappedFileClosed) {
+		t.Errorf("expected ErrMappedFileClosed, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package fsutils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func mmapFile(file *os.File, size int) ([]byte, func() error, error) {
+	mapping, err := syscall.CreateFileMapping(syscall.Handle(file.Fd()), nil, syscall.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+
+	addr, err := syscall.MapViewOfFile(mapping, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		_ = syscall.CloseHandle(mapping)
+		return nil, nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+
+	// The address is converted through a pointer to it, as it refers to memory outside of
+	// the Go heap that is not moved or collected.
+	data := unsafe.Slice((*byte)(*(*unsafe.Pointer)(unsafe.Pointer(&addr))), size)
+	unmap := func() error {
+		return errors.Join(
+			os.NewSyscallError("UnmapViewOfFile", syscall.UnmapViewOfFile(addr)),
+			os.NewSyscallError("CloseHandle", syscall.CloseHandle(mapping)),
+		)
+	}
+
+	return data, unmap, nil
+}
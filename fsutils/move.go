@@ -0,0 +1,200 @@
+package fsutils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// MoveOptions is a struct used by Move to define certain optional parameters.
+type MoveOptions struct {
+	// NoOverwrite refuses to replace an existing dst, and fails with an error wrapping
+	// fs.ErrExist instead.
+	NoOverwrite bool
+}
+
+func defaultMoveOptions() MoveOptions {
+	return MoveOptions{}
+}
+
+// Move moves the file, directory, or symbolic link at src to dst. When they are on the
+// same filesystem it is renamed, see os.Rename. Otherwise it is copied, along with its
+// permissions, modification time, and ownership where permitted, the copy is compared
+// with the original, and only then is src removed. If the copy or the comparison fails,
+// the copy is removed and src is left alone.
+//
+// An existing file at dst is replaced, unless NoOverwrite is set. A directory can only be
+// moved to a dst that does not exist.
+//
+// This takes a variadic parameter of type MoveOptions. If no MoveOptions are supplied,
+// then the defaults are used. If more than one MoveOptions are supplied then only the
+// first will be used.
+func Move(src, dst string, opts ...MoveOptions) error {
+	options := defaultMoveOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	if options.NoOverwrite {
+		if _, err := os.Lstat(dst); err == nil {
+			return &fs.PathError{Op: "move", Path: dst, Err: fs.ErrExist}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	err = os.Rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		err = moveDir(src, dst)
+	case info.Mode()&fs.ModeSymlink != 0:
+		err = moveSymlink(src, dst)
+	default:
+		err = moveFile(src, dst)
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not move %q to %q: %w", src, dst, err)
+	}
+
+	return nil
+}
+
+// moveFileOptions are the options used to copy files across filesystems.
+var moveFileOptions = CopyFileOptions{
+	PreserveMode:  true,
+	PreserveTimes: true,
+	PreserveOwner: true,
+}
+
+// moveFile copies the file at src to dst, checks the copy, and removes src.
+func moveFile(src, dst string) error {
+	if err := CopyFile(src, dst, moveFileOptions); err != nil {
+		return err
+	}
+
+	if err := compareFiles(src, dst); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// moveSymlink recreates the symbolic link at src at dst, and removes src.
+func moveSymlink(src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Symlink(target, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// moveDir copies the directory at src to dst, checks the copy, and removes src.
+func moveDir(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return &fs.PathError{Op: "move", Path: dst, Err: fs.ErrExist}
+	}
+
+	copied, err := CopyDir(src, dst, CopyDirOptions{File: moveFileOptions})
+	if err == nil {
+		err = compareCopiedFiles(src, dst, copied)
+	}
+
+	if err != nil {
+		_ = os.RemoveAll(dst)
+		return err
+	}
+
+	return os.RemoveAll(src)
+}
+
+// compareCopiedFiles compares each of the regular files at the paths in copied, which
+// are relative to src, with the copy in dst.
+func compareCopiedFiles(src, dst string, copied []string) error {
+	for _, rel := range copied {
+		info, err := os.Lstat(filepath.Join(src, rel))
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		if err := compareFiles(filepath.Join(src, rel), filepath.Join(dst, rel)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compareFiles returns an error if the contents of the files at a and b differ.
+func compareFiles(a, b string) error {
+	same, err := sameContents(a, b)
+	if err == nil && !same {
+		err = fmt.Errorf("%q and %q differ", a, b)
+	}
+
+	return err
+}
+
+// sameContents reports whether the files at a and b have the same contents.
+func sameContents(a, b string) (bool, error) {
+	fileA, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fileA.Close()
+
+	fileB, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fileB.Close()
+
+	bufA, bufB := make([]byte, 32*1024), make([]byte, 32*1024)
+
+	for {
+		n, errA := io.ReadFull(fileA, bufA)
+		m, errB := io.ReadFull(fileB, bufB)
+
+		if !bytes.Equal(bufA[:n], bufB[:m]) {
+			return false, nil
+		}
+
+		switch {
+		case errors.Is(errA, io.EOF) || errors.Is(errA, io.ErrUnexpectedEOF):
+			if errB != nil && !errors.Is(errB, io.EOF) && !errors.Is(errB, io.ErrUnexpectedEOF) {
+				return false, errB
+			}
+
+			return true, nil
+		case errA != nil:
+			return false, errA
+		case errB != nil && !errors.Is(errB, io.EOF) && !errors.Is(errB, io.ErrUnexpectedEOF):
+			return false, errB
+		}
+	}
+}
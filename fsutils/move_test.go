@@ -0,0 +1,65 @@
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMove(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+
+	if err := os.WriteFile(src, []byte("contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Move(src, dst, MoveOptions{NoOverwrite: true}); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected fs.ErrExist, got %v", err)
+	}
+	if err := Move(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if PathExists(src) {
+		t.Error("expected the source to be removed")
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "contents" {
+		t.Errorf("read %q, want %q", data, "contents")
+	}
+}
+
+func TestMoveAcrossDevices(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "moved")
+
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "file"), []byte("contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fallback is tested directly, as the temporary directories are usually on the
+	// same filesystem.
+	if err := moveDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if PathExists(src) {
+		t.Error("expected the source to be removed")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "sub", "file")); string(data) != "contents" {
+		t.Errorf("read %q, want %q", data, "contents")
+	}
+
+	if err := compareFiles(filepath.Join(dst, "sub", "file"), filepath.Join(dst, "sub")); err == nil {
+		t.Error("expected comparing a file with a directory to fail")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package fsutils
+
+import "io/fs"
+
+// fileOwner reports that file ownership is unavailable on this platform.
+func fileOwner(_ fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
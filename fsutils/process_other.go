@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package fsutils
+
+// processRunning reports whether the process with the given ID is running on this host,
+// and whether that could be determined, which it cannot be on this platform.
+func processRunning(int) (running, known bool) {
+	return false, false
+}
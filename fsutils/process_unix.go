@@ -0,0 +1,22 @@
+//go:build unix
+
+package fsutils
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processRunning reports whether the process with the given ID is running on this host,
+// and whether that could be determined.
+func processRunning(pid int) (running, known bool) {
+	err := syscall.Kill(pid, 0)
+	switch {
+	case err == nil, errors.Is(err, syscall.EPERM):
+		return true, true
+	case errors.Is(err, syscall.ESRCH):
+		return false, true
+	default:
+		return false, false
+	}
+}
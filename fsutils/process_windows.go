@@ -0,0 +1,35 @@
+package fsutils
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+
+	errorInvalidParameter syscall.Errno = 87
+)
+
+// processRunning reports whether the process with the given ID is running on this host,
+// and whether that could be determined.
+func processRunning(pid int) (running, known bool) {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	switch {
+	case errors.Is(err, errorInvalidParameter):
+		return false, true
+	case errors.Is(err, syscall.ERROR_ACCESS_DENIED):
+		return true, true
+	case err != nil:
+		return false, false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return false, false
+	}
+
+	return code == stillActive, true
+}
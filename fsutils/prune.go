@@ -0,0 +1,114 @@
+package fsutils
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// PruneOptions is a struct used by PruneDir to define certain optional parameters. At
+// least one of KeepLast and OlderThan must be set.
+type PruneOptions struct {
+	// Pattern selects the files that may be removed, using the syntax of path.Match
+	// against their names, such as "app-*.log". If it is empty, every file is selected.
+	Pattern string
+
+	// Match, if not nil, further selects the files that may be removed by their names,
+	// for when they cannot be described by a pattern alone.
+	Match func(name string) bool
+
+	// KeepLast is the number of the newest selected files that are always kept.
+	KeepLast int
+
+	// OlderThan, if positive, only removes selected files that were last modified longer
+	// ago than this. Otherwise every file beyond the newest KeepLast is removed.
+	OlderThan time.Duration
+}
+
+func defaultPruneOptions() PruneOptions {
+	return PruneOptions{}
+}
+
+// PruneDir removes old files from the directory at dir, such as rotated logs or
+// snapshots, and returns the paths of the files that were removed. Files are ordered by
+// their modification time, and those beyond the newest KeepLast that are older than
+// OlderThan are removed. Subdirectories and the files in them are left alone. If a file
+// cannot be removed, the others are still removed, and the errors are returned together.
+//
+// This takes a variadic parameter of type PruneOptions. If no PruneOptions are supplied,
+// then the defaults are used. If more than one PruneOptions are supplied then only the
+// first will be used.
+func PruneDir(dir string, opts ...PruneOptions) ([]string, error) {
+	options := defaultPruneOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if options.KeepLast <= 0 && options.OlderThan <= 0 {
+		return nil, errors.New("pruning requires KeepLast or OlderThan to be set, to avoid removing every file")
+	}
+	if options.Pattern == "" {
+		options.Pattern = "*"
+	}
+	if err := validatePatterns([]string{options.Pattern}); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []fs.FileInfo
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !matchesAny([]string{options.Pattern}, entry.Name()) {
+			continue
+		}
+		if options.Match != nil && !options.Match(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		files = append(files, info)
+	}
+
+	// Newest first, with names breaking ties so that the order is stable.
+	slices.SortFunc(files, func(a, b fs.FileInfo) int {
+		if c := b.ModTime().Compare(a.ModTime()); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(b.Name(), a.Name())
+	})
+
+	var (
+		removed []string
+		errs    []error
+		cutoff  = time.Now().Add(-options.OlderThan)
+	)
+	for _, info := range files[min(max(options.KeepLast, 0), len(files)):] {
+		if options.OlderThan > 0 && !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		path := filepath.Join(dir, info.Name())
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+
+		removed = append(removed, path)
+	}
+
+	return removed, errors.Join(errs...)
+}
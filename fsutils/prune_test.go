@@ -0,0 +1,55 @@
+package fsutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneDir(t *testing.T) {
+	dir := t.TempDir()
+
+	now := time.Now()
+	for i := range 5 {
+		path := filepath.Join(dir, fmt.Sprintf("app-%d.log", i))
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		// app-0.log is the oldest, at four days old.
+		modTime := now.Add(-time.Duration(4-i) * 24 * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := PruneDir(dir, PruneOptions{Pattern: "app-*.log", KeepLast: 1, OlderThan: 36 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 3 {
+		t.Fatalf("expected the three files older than 36 hours to be removed, got %v", removed)
+	}
+
+	if removed, err = PruneDir(dir, PruneOptions{Pattern: "app-*.log", KeepLast: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || filepath.Base(removed[0]) != "app-3.log" {
+		t.Fatalf("expected only app-3.log to be removed, got %v", removed)
+	}
+
+	for _, name := range []string{"app-4.log", "other.txt"} {
+		if !PathExists(filepath.Join(dir, name)) {
+			t.Errorf("expected %s to be kept", name)
+		}
+	}
+
+	if _, err := PruneDir(dir); err == nil {
+		t.Error("expected pruning without limits to fail")
+	}
+}
@@ -0,0 +1,63 @@
+package fsutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// RemoveContentsOptions is a struct used by RemoveContents to define certain optional
+// parameters.
+type RemoveContentsOptions struct {
+	// Keep leaves the entries of the directory whose names match any of these patterns,
+	// such as ".gitkeep", in place. Patterns use the syntax of path.Match.
+	Keep []string
+}
+
+func defaultRemoveContentsOptions() RemoveContentsOptions {
+	return RemoveContentsOptions{}
+}
+
+// RemoveContents removes everything inside the directory at dir, but not dir itself, and
+// returns the names of the entries that were removed. Unlike removing the directory and
+// creating it again, this keeps its permissions, ownership, and any open handles to it.
+// If an entry cannot be removed, the others are still removed, and the errors are
+// returned together.
+//
+// This takes a variadic parameter of type RemoveContentsOptions. If no
+// RemoveContentsOptions are supplied, then the defaults are used. If more than one
+// RemoveContentsOptions are supplied then only the first will be used.
+func RemoveContents(dir string, opts ...RemoveContentsOptions) ([]string, error) {
+	options := defaultRemoveContentsOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if err := validatePatterns(options.Keep); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		removed []string
+		errs    []error
+	)
+	for _, entry := range entries {
+		if matchesAny(options.Keep, entry.Name()) {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		removed = append(removed, entry.Name())
+	}
+
+	return removed, errors.Join(errs...)
+}
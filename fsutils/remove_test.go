@@ -0,0 +1,38 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRemoveContents(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"a", "sub/b", ".gitkeep"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := RemoveContents(dir, RemoveContentsOptions{Keep: []string{".git*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "sub"}; !slices.Equal(removed, want) {
+		t.Errorf("expected %v to be removed, got %v", want, removed)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != ".gitkeep" {
+		t.Errorf("expected only .gitkeep to be kept, got %v", entries)
+	}
+}
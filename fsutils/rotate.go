@@ -0,0 +1,236 @@
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat is the format of the timestamps in the names of rotated files. It
+// sorts chronologically and contains no characters that are invalid in file names.
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingWriterOptions is a struct used by NewRotatingWriter to define certain optional
+// parameters.
+type RotatingWriterOptions struct {
+	// MaxSize is the size, in bytes, after which the file is rotated. A write is never
+	// split, so a file can exceed MaxSize by up to the size of one write. If it is zero
+	// or negative, the file is not rotated by size.
+	MaxSize int64
+
+	// RotateEvery is how long a file is written to before it is rotated. If it is zero
+	// or negative, the file is not rotated by time.
+	RotateEvery time.Duration
+
+	// MaxBackups is the number of rotated files that are kept, the oldest being removed
+	// first. If it is zero or negative, every rotated file is kept.
+	MaxBackups int
+
+	// Compress compresses rotated files with gzip.
+	Compress bool
+
+	// Perm is the permissions that the file is created with, before the umask.
+	Perm fs.FileMode
+}
+
+func defaultRotatingWriterOptions() RotatingWriterOptions {
+	return RotatingWriterOptions{
+		MaxSize:    100 << 20,
+		MaxBackups: 5,
+		Perm:       0o644,
+	}
+}
+
+// RotatingWriter is an io.WriteCloser that appends to a file, and rotates it once it
+// grows too large or too old, which makes it suitable as the output of a logger. Rotated
+// files are renamed with a timestamp before their extension, such as
+// "app-2006-01-02T15-04-05.000.log", with a counter after the timestamp if several are
+// rotated within the same millisecond, optionally compressed, and removed once there are
+// too many. It is safe for concurrent use.
+type RotatingWriter struct {
+	path    string
+	options RotatingWriterOptions
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// NewRotatingWriter creates a new *RotatingWriter that writes to the file at path,
+// appending to it if it already exists. On success, the new writer is returned. On
+// failure, an error is returned.
+//
+// This takes a variadic parameter of type RotatingWriterOptions. If no
+// RotatingWriterOptions are supplied, then the defaults are used. If more than one
+// RotatingWriterOptions are supplied then only the first will be used.
+func NewRotatingWriter(path string, opts ...RotatingWriterOptions) (*RotatingWriter, error) {
+	options := defaultRotatingWriterOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Perm == 0 {
+		options.Perm = 0o644
+	}
+
+	rw := &RotatingWriter{path: path, options: options}
+	if err := rw.open(); err != nil {
+		return nil, err
+	}
+
+	return rw, nil
+}
+
+// open opens the file for appending.
+func (rw *RotatingWriter) open() error {
+	file, err := os.OpenFile(rw.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, rw.options.Perm)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	rw.file, rw.size, rw.opened = file, info.Size(), time.Now()
+
+	return nil
+}
+
+// Write appends p to the file, rotating it first if needed.
+func (rw *RotatingWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	bySize := rw.options.MaxSize > 0 && rw.size > 0 && rw.size+int64(len(p)) > rw.options.MaxSize
+	byTime := rw.options.RotateEvery > 0 && time.Since(rw.opened) >= rw.options.RotateEvery
+	if bySize || byTime {
+		if err := rw.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rw.file.Write(p)
+	rw.size += int64(n)
+
+	return n, err
+}
+
+// Rotate rotates the file immediately, such as in response to SIGHUP.
+func (rw *RotatingWriter) Rotate() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.file == nil {
+		return os.ErrClosed
+	}
+
+	return rw.rotate()
+}
+
+// rotate renames the current file, opens a new one, and then compresses and removes old
+// rotated files as configured. Compression is done before returning, so that a
+// rotated file is never left half-written.
+func (rw *RotatingWriter) rotate() error {
+	if err := rw.file.Close(); err != nil {
+		return err
+	}
+	rw.file = nil
+
+	ext := filepath.Ext(rw.path)
+	stem := strings.TrimSuffix(rw.path, ext)
+	rotated := rotatedName(stem, ext)
+
+	renameErr := os.Rename(rw.path, rotated)
+	if err := rw.open(); err != nil {
+		return errors.Join(renameErr, err)
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	if rw.options.Compress {
+		if err := CompressFile(rotated, "", CompressOptions{RemoveSource: true}); err != nil {
+			return err
+		}
+	}
+
+	if rw.options.MaxBackups > 0 {
+		base := filepath.Base(stem)
+		_, err := PruneDir(filepath.Dir(rw.path), PruneOptions{
+			Match:    func(name string) bool { return isRotatedName(name, base, ext) },
+			KeepLast: rw.options.MaxBackups,
+		})
+
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the file. Writing to a closed writer fails with os.ErrClosed.
+func (rw *RotatingWriter) Close() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.file == nil {
+		return nil
+	}
+
+	err := rw.file.Close()
+	rw.file = nil
+
+	return err
+}
+
+// rotatedName returns the name that the file at stem+ext is rotated to, which has the
+// current time added to stem, and a counter after it if a file from the same millisecond
+// already exists, compressed or not.
+func rotatedName(stem, ext string) string {
+	name := stem + "-" + time.Now().Format(rotatedTimeFormat)
+	for n := 0; ; n++ {
+		rotated := name + ext
+		if n > 0 {
+			rotated = name + "-" + strconv.Itoa(n) + ext
+		}
+
+		if !PathExists(rotated) && !PathExists(rotated+Gzip.Extension) {
+			return rotated
+		}
+	}
+}
+
+// isRotatedName reports whether name is that of a file rotated from base+ext by
+// rotatedName, optionally compressed, so that pruning leaves unrelated files such as
+// "app-worker.log" alone when rotating "app.log".
+func isRotatedName(name, base, ext string) bool {
+	rest, ok := strings.CutPrefix(name, base+"-")
+	if !ok || len(rest) < len(rotatedTimeFormat) {
+		return false
+	}
+	if _, err := time.Parse(rotatedTimeFormat, rest[:len(rotatedTimeFormat)]); err != nil {
+		return false
+	}
+	rest = rest[len(rotatedTimeFormat):]
+
+	if counter, ok := strings.CutPrefix(rest, "-"); ok {
+		digits := len(counter) - len(strings.TrimLeft(counter, "0123456789"))
+		if digits == 0 {
+			return false
+		}
+		rest = counter[digits:]
+	}
+
+	return rest == ext || rest == ext+Gzip.Extension
+}
@@ -0,0 +1,126 @@
+package fsutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	writer, err := NewRotatingWriter(path, RotatingWriterOptions{MaxSize: 10, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	for range 4 {
+		if _, err := writer.Write([]byte("12345678\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		// Rotated files are named to the millisecond.
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var backups int
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "app-") && strings.HasSuffix(entry.Name(), ".log.gz") {
+			backups++
+		}
+	}
+	if len(entries) != 3 || backups != 2 {
+		t.Errorf("expected the file and two compressed backups, got %v", entries)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "12345678\n" {
+		t.Errorf("expected the current file to hold the last write, got %q", data)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected os.ErrClosed, got %v", err)
+	}
+}
+
+func TestRotatingWriterNamesAreUnique(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// Unrelated files that share the prefix must survive pruning.
+	if err := os.WriteFile(filepath.Join(dir, "app-worker.log"), []byte("worker"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := NewRotatingWriter(path, RotatingWriterOptions{MaxSize: 10, MaxBackups: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Many rotations within the same millisecond must not overwrite each other.
+	for range 50 {
+		if _, err := writer.Write([]byte("123456789\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	size, _, err := DirSize(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(500 + len("worker")); size != want {
+		t.Errorf("expected %d bytes to be kept, got %d", want, size)
+	}
+
+	writer, err = NewRotatingWriter(path, RotatingWriterOptions{MaxSize: 10, MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	if _, err := writer.Write([]byte("123456789\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !PathExists(filepath.Join(dir, "app-worker.log")) {
+		t.Error("expected app-worker.log to be left alone")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("expected the file, one backup, and app-worker.log, got %v", entries)
+	}
+}
+
+func TestIsRotatedName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"app-2024-01-02T03-04-05.678.log":      true,
+		"app-2024-01-02T03-04-05.678-12.log":   true,
+		"app-2024-01-02T03-04-05.678.log.gz":   true,
+		"app-2024-01-02T03-04-05.678-1.log.gz": true,
+		"app-worker.log":                       false,
+		"app-2024-01-02T03-04-05.678-.log":     false,
+		"app-2024-01-02T03-04-05.678.txt":      false,
+		"app.log":                              false,
+	} {
+		if got := isRotatedName(name, "app", ".log"); got != want {
+			t.Errorf("%q: expected %v, got %v", name, want, got)
+		}
+	}
+}
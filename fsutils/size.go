@@ -0,0 +1,184 @@
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// DirSizeOptions is a struct used by DirSize and DirSizeOnFS to define certain optional
+// parameters.
+type DirSizeOptions struct {
+	// FollowSymlinks counts the files and directories that symbolic links point to.
+	// Otherwise symbolic links are not counted. Links that form a cycle are reported as
+	// an error.
+	FollowSymlinks bool
+
+	// Exclude leaves out files and whole directories that match any of these patterns.
+	// See CopyDirOptions.Include for the syntax.
+	Exclude []string
+
+	// Workers is the number of directories that are read concurrently. If it is zero or
+	// negative, runtime.NumCPU is used.
+	Workers int
+}
+
+func defaultDirSizeOptions() DirSizeOptions {
+	return DirSizeOptions{}
+}
+
+// DirSize returns the total size, in bytes, and the number of regular files in the
+// directory tree at path. Directories are read concurrently by a bounded pool of
+// workers.
+//
+// This takes a variadic parameter of type DirSizeOptions. If no DirSizeOptions are
+// supplied, then the defaults are used. If more than one DirSizeOptions are supplied
+// then only the first will be used.
+func DirSize(path string, opts ...DirSizeOptions) (size int64, files int, err error) {
+	return DirSizeOnFS(os.DirFS(path), ".", opts...)
+}
+
+// DirSizeOnFS returns the total size, in bytes, and the number of regular files in the
+// directory tree at path on filesystem. See DirSize.
+//
+// This takes a variadic parameter of type DirSizeOptions. If no DirSizeOptions are
+// supplied, then the defaults are used. If more than one DirSizeOptions are supplied
+// then only the first will be used.
+func DirSizeOnFS(filesystem fs.FS, path string, opts ...DirSizeOptions) (size int64, files int, err error) {
+	options := defaultDirSizeOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if err := validatePatterns(options.Exclude); err != nil {
+		return 0, 0, err
+	}
+
+	info, err := fs.Stat(filesystem, path)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !info.IsDir() {
+		return 0, 0, &fs.PathError{Op: "size", Path: path, Err: errors.New("not a directory")}
+	}
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	sw := &sizeWalker{
+		filesystem: filesystem,
+		options:    options,
+		workers:    make(chan struct{}, workers-1),
+	}
+	sw.walk(path, ".", []fs.FileInfo{info})
+	sw.wg.Wait()
+
+	return sw.size.Load(), int(sw.files.Load()), sw.err()
+}
+
+// sizeWalker holds the state of a single DirSizeOnFS call.
+type sizeWalker struct {
+	filesystem fs.FS
+	options    DirSizeOptions
+
+	// workers limits the number of extra goroutines reading directories.
+	workers chan struct{}
+	wg      sync.WaitGroup
+
+	size, files atomic.Int64
+
+	mu       sync.Mutex
+	firstErr error
+}
+
+// fail records err, if it is the first error.
+func (sw *sizeWalker) fail(err error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.firstErr == nil {
+		sw.firstErr = err
+	}
+}
+
+// err returns the first error, if any.
+func (sw *sizeWalker) err() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	return sw.firstErr
+}
+
+// walk adds up the sizes of the files in the directory at name, which is at rel relative
+// to the root of the walk and is the last of ancestors. Subdirectories are walked by
+// another goroutine if a worker is free, or in this one if not.
+func (sw *sizeWalker) walk(name, rel string, ancestors []fs.FileInfo) {
+	if sw.err() != nil {
+		return
+	}
+
+	entries, err := fs.ReadDir(sw.filesystem, name)
+	if err != nil {
+		sw.fail(err)
+		return
+	}
+
+	for _, entry := range entries {
+		entryName := path.Join(name, entry.Name())
+		entryRel := path.Join(rel, entry.Name())
+		if matchesAny(sw.options.Exclude, entryRel) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			if !sw.options.FollowSymlinks {
+				continue
+			}
+
+			info, err = fs.Stat(sw.filesystem, entryName)
+		}
+		if err != nil {
+			sw.fail(err)
+			return
+		}
+
+		if !info.IsDir() {
+			if info.Mode().IsRegular() {
+				sw.size.Add(info.Size())
+				sw.files.Add(1)
+			}
+
+			continue
+		}
+
+		if slices.ContainsFunc(ancestors, func(ancestor fs.FileInfo) bool { return os.SameFile(ancestor, info) }) {
+			sw.fail(&fs.PathError{Op: "size", Path: entryName, Err: errors.New("symbolic link cycle")})
+			return
+		}
+
+		entryAncestors := append(slices.Clip(ancestors), info)
+
+		select {
+		case sw.workers <- struct{}{}:
+			sw.wg.Add(1)
+			go func() {
+				defer func() {
+					<-sw.workers
+					sw.wg.Done()
+				}()
+
+				sw.walk(entryName, entryRel, entryAncestors)
+			}()
+		default:
+			sw.walk(entryName, entryRel, entryAncestors)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDirSize(t *testing.T) {
+	root := t.TempDir()
+
+	for name, contents := range map[string]string{
+		"a":         "12345",
+		"sub/b":     "123",
+		"sub/c/d":   "1",
+		"skip/e":    "1234567890",
+		"sub/f.tmp": "1234567890",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	size, files, err := DirSize(root, DirSizeOptions{Exclude: []string{"skip", "*.tmp"}, Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 9 || files != 3 {
+		t.Errorf("expected 9 bytes in 3 files, got %d bytes in %d files", size, files)
+	}
+
+	filesystem := fstest.MapFS{
+		"assets/a.txt":   {Data: []byte("abc")},
+		"assets/b/c.txt": {Data: []byte("de")},
+		"other.txt":      {Data: []byte("fghij")},
+	}
+	if size, files, err = DirSizeOnFS(filesystem, "assets"); err != nil {
+		t.Fatal(err)
+	}
+	if size != 5 || files != 2 {
+		t.Errorf("expected 5 bytes in 2 files, got %d bytes in %d files", size, files)
+	}
+}
@@ -0,0 +1,196 @@
+package fsutils
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// DirSnapshot is the contents and metadata of a directory tree, captured in memory by
+// Snapshot so that the tree can later be put back the way it was with Restore.
+type DirSnapshot struct {
+	// paths are the slash-separated paths of the entries, relative to the root of the
+	// tree, in lexical order, so that directories come before their contents.
+	paths   []string
+	entries map[string]snapshotEntry
+}
+
+// snapshotEntry is a single file, directory, or symbolic link in a DirSnapshot.
+type snapshotEntry struct {
+	mode    fs.FileMode
+	modTime time.Time
+	data    []byte
+	target  string
+}
+
+// Paths returns the slash-separated paths of the entries in the snapshot, relative to the
+// root of the tree and in lexical order. The root itself is ".".
+func (ds *DirSnapshot) Paths() []string {
+	return slices.Clone(ds.paths)
+}
+
+// Size returns the total size of the contents of the files in the snapshot, which is
+// roughly the memory it holds.
+func (ds *DirSnapshot) Size() int64 {
+	var size int64
+	for _, entry := range ds.entries {
+		size += int64(len(entry.data))
+	}
+
+	return size
+}
+
+// Snapshot reads the whole directory tree at dir into memory, along with the
+// permissions and modification times of its entries, so that it can be put back with
+// Restore. Symbolic links are kept as links, and are not followed. Every file is read,
+// so this is meant for small trees such as test fixtures. Other types of file, such as
+// sockets and devices, cannot be captured and cause an error.
+func Snapshot(dir string) (*DirSnapshot, error) {
+	snapshot := &DirSnapshot{entries: make(map[string]snapshotEntry)}
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		captured := snapshotEntry{mode: info.Mode(), modTime: info.ModTime()}
+		switch {
+		case info.Mode().IsRegular():
+			if captured.data, err = os.ReadFile(path); err != nil {
+				return err
+			}
+		case info.Mode()&fs.ModeSymlink != 0:
+			if captured.target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !info.IsDir():
+			return &fs.PathError{Op: "snapshot", Path: path, Err: errors.ErrUnsupported}
+		}
+
+		rel = filepath.ToSlash(rel)
+		snapshot.paths = append(snapshot.paths, rel)
+		snapshot.entries[rel] = captured
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Restore puts the directory tree at dir back the way it was when snapshot was taken,
+// which need not have been of dir itself. Entries that were added since are removed,
+// those that were changed or removed are written again, and the permissions and
+// modification times of every entry are reset. Files whose contents are unchanged are
+// left in place, so restoring a tree that was barely touched is cheap.
+func Restore(snapshot *DirSnapshot, dir string) error {
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return err
+	}
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		captured, ok := snapshot.entries[filepath.ToSlash(rel)]
+		if ok && captured.mode.Type() == entry.Type() {
+			return nil
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range snapshot.paths {
+		if err := restoreEntry(filepath.Join(dir, filepath.FromSlash(rel)), snapshot.entries[rel]); err != nil {
+			return err
+		}
+	}
+
+	// Metadata is applied in reverse, so that the modification time of each directory is
+	// set after its contents have been restored, and read-only directories are only made
+	// so once they are complete.
+	for _, rel := range slices.Backward(snapshot.paths) {
+		captured := snapshot.entries[rel]
+		if captured.mode&fs.ModeSymlink != 0 {
+			continue
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.Chmod(path, captured.mode&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+			return err
+		}
+		if err := os.Chtimes(path, time.Time{}, captured.modTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreEntry writes captured to path, unless it is already there. Any entry at path is
+// of the same type as captured, as those of other types were removed first.
+func restoreEntry(path string, captured snapshotEntry) error {
+	switch {
+	case captured.mode.IsDir():
+		if err := os.Mkdir(path, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+
+		return nil
+	case captured.mode&fs.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		switch {
+		case err == nil && target == captured.target:
+			return nil
+		case err == nil:
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		case !errors.Is(err, fs.ErrNotExist):
+			return err
+		}
+
+		return os.Symlink(captured.target, path)
+	default:
+		if data, err := os.ReadFile(path); err == nil && bytes.Equal(data, captured.data) {
+			return nil
+		}
+
+		return atomicWrite(path, 0o600, func(file *os.File) error {
+			_, err := file.Write(captured.data)
+			return err
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	dir := t.TempDir()
+	reference := t.TempDir()
+
+	for _, root := range []string{dir, reference} {
+		if err := os.MkdirAll(filepath.Join(root, "sub", "nested"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "file"), []byte("contents"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "sub", "script"), []byte("#!/bin/sh"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("file", filepath.Join(root, "link")); err != nil {
+			t.Skip("symbolic links are not supported:", err)
+		}
+	}
+
+	before, err := os.Stat(filepath.Join(dir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := Snapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Size() != int64(len("contents")+len("#!/bin/sh")) {
+		t.Errorf("unexpected snapshot size %d", snapshot.Size())
+	}
+
+	// Mutate the tree in every way that Restore handles.
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("changed"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := before.ModTime().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "file"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "added"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "link"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(snapshot, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.Stat(filepath.Join(dir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("expected the modification time %v to be restored, got %v", before.ModTime(), after.ModTime())
+	}
+
+	diff, err := DirDiff(dir, reference, DirDiffOptions{IgnoreModTime: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Equal() {
+		t.Errorf("expected the restored tree to match, got %+v", diff)
+	}
+}
@@ -0,0 +1,175 @@
+package fsutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// SplitFileOptions is a struct used by SplitFile to define certain optional parameters.
+type SplitFileOptions struct {
+	// Dir is the directory that the parts are written to. If it is empty, the parts are
+	// written next to the file being split.
+	Dir string
+}
+
+func defaultSplitFileOptions() SplitFileOptions {
+	return SplitFileOptions{}
+}
+
+// SplitResult describes the parts written by SplitFile, and is everything needed to
+// reassemble and verify them with JoinFiles.
+type SplitResult struct {
+	// Parts are the paths of the parts, in order.
+	Parts []string
+
+	// Size is the size of the original file, in bytes.
+	Size int64
+
+	// SHA256 is the SHA-256 hash of the original file, in hexadecimal.
+	SHA256 string
+}
+
+// JoinOptions returns the JoinFilesOptions that verify the result of joining the parts
+// against the original file.
+func (sr SplitResult) JoinOptions() JoinFilesOptions {
+	return JoinFilesOptions{Size: sr.Size, SHA256: sr.SHA256}
+}
+
+// SplitFile splits the file at path into parts of partSize bytes, the last of which may
+// be smaller. The parts are named after the file with a numbered suffix, such as
+// "archive.tar.001", "archive.tar.002", and so on, so that they sort in order. An empty
+// file is split into a single empty part. This is useful for uploading large files in
+// chunks or sending them over connections with limited message sizes.
+//
+// This takes a variadic parameter of type SplitFileOptions. If no SplitFileOptions are
+// supplied, then the defaults are used. If more than one SplitFileOptions are supplied
+// then only the first will be used.
+func SplitFile(path string, partSize int64, opts ...SplitFileOptions) (SplitResult, error) {
+	options := defaultSplitFileOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if partSize <= 0 {
+		return SplitResult{}, fmt.Errorf("invalid part size %d", partSize)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return SplitResult{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return SplitResult{}, err
+	}
+
+	dir := options.Dir
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+
+	count := max((info.Size()+partSize-1)/partSize, 1)
+	digits := max(3, len(strconv.FormatInt(count, 10)))
+
+	result := SplitResult{Size: info.Size()}
+	sha := sha256.New()
+	source := io.TeeReader(file, sha)
+
+	for i := range count {
+		part := filepath.Join(dir, fmt.Sprintf("%s.%0*d", filepath.Base(path), digits, i+1))
+
+		err := atomicWrite(part, 0o666, func(file *os.File) error {
+			_, err := io.CopyN(file, source, partSize)
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+
+		result.Parts = append(result.Parts, part)
+	}
+
+	result.SHA256 = hex.EncodeToString(sha.Sum(nil))
+
+	return result, nil
+}
+
+// JoinFilesOptions is a struct used by JoinFiles to define certain optional parameters.
+type JoinFilesOptions struct {
+	// Size, if positive, is the size in bytes that the joined file must have.
+	Size int64
+
+	// SHA256, if not empty, is the SHA-256 hash, in hexadecimal, that the joined file must
+	// have.
+	SHA256 string
+}
+
+func defaultJoinFilesOptions() JoinFilesOptions {
+	return JoinFilesOptions{}
+}
+
+// JoinFiles concatenates the files at parts, in order, into the file at dst, such as to
+// reassemble the parts written by SplitFile. The file is written atomically, see
+// AtomicWriteFile, and if it does not have the size or hash given in the options, dst is
+// left untouched and an error is returned, which wraps ErrChecksumMismatch for a hash
+// mismatch.
+//
+// This takes a variadic parameter of type JoinFilesOptions. If no JoinFilesOptions are
+// supplied, then the defaults are used. If more than one JoinFilesOptions are supplied
+// then only the first will be used.
+func JoinFiles(parts []string, dst string, opts ...JoinFilesOptions) error {
+	options := defaultJoinFilesOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	return atomicWrite(dst, 0o666, func(file *os.File) error {
+		sha := sha256.New()
+		writer := io.MultiWriter(file, sha)
+
+		var size int64
+		for _, part := range parts {
+			n, err := appendFile(writer, part)
+			size += n
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if options.Size > 0 && size != options.Size {
+			return fmt.Errorf("joined file %q is %d bytes, expected %d", dst, size, options.Size)
+		}
+
+		if options.SHA256 != "" {
+			if got := hex.EncodeToString(sha.Sum(nil)); got != options.SHA256 {
+				return fmt.Errorf("joined file %q: %w: expected %s, got %s", dst, ErrChecksumMismatch, options.SHA256, got)
+			}
+		}
+
+		return nil
+	})
+}
+
+// appendFile copies the contents of the file at path to w.
+func appendFile(w io.Writer, path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return io.Copy(w, file)
+}
@@ -0,0 +1,44 @@
+package fsutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitAndJoinFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	contents := strings.Repeat("a", 50) + strings.Repeat("b", 50)
+
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := SplitFile(path, 30, SplitFileOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Parts) != 4 || filepath.Base(result.Parts[3]) != "file.004" || result.Size != 100 {
+		t.Fatalf("unexpected split result %+v", result)
+	}
+
+	joined := filepath.Join(dir, "joined")
+	if err := JoinFiles(result.Parts, joined, result.JoinOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(joined); string(data) != contents {
+		t.Errorf("expected the joined file to match the original, got %q", data)
+	}
+
+	// Joining the parts out of order gives the right size but the wrong hash.
+	parts := []string{result.Parts[1], result.Parts[0], result.Parts[2], result.Parts[3]}
+	if err := JoinFiles(parts, joined, result.JoinOptions()); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if data, _ := os.ReadFile(joined); string(data) != contents {
+		t.Error("expected a failed join to leave the destination untouched")
+	}
+}
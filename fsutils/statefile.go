@@ -0,0 +1,169 @@
+package fsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// StateValue describes a type that can be stored in a StateFile, using the same
+// Marshal/Unmarshal methods as netutils.Convertable, which therefore satisfies it, so the
+// same types can be both sent over typed connections and persisted.
+type StateValue interface {
+	// Takes the current state of the implementing value, and marshals it into a format
+	// chosen by the implementer.
+	Marshal() (data []byte, err error)
+
+	// Unmarshals the given data passed as parameter into the implementer's type.
+	Unmarshal(v any, data []byte) error
+}
+
+// StateFileOptions is a struct used by NewStateFile to define certain optional
+// parameters.
+type StateFileOptions struct {
+	// Perm is the permissions that the state file is created with, before the umask.
+	Perm fs.FileMode
+
+	// Lock is used to lock the state file, see Lock. It is taken on a separate lock file
+	// alongside the state file, whose path has ".lock" appended, as the state file itself
+	// is replaced on every save.
+	Lock LockOptions
+}
+
+func defaultStateFileOptions() StateFileOptions {
+	return StateFileOptions{
+		Perm: 0o644,
+		Lock: defaultLockOptions(),
+	}
+}
+
+// StateFile is a value of type T persisted to a file, which can be loaded, saved, and
+// updated safely by several goroutines and processes at once. Every access is done under
+// a lock, and saves are atomic, so the file always holds either the old value or the
+// new one.
+type StateFile[T StateValue] struct {
+	path    string
+	options StateFileOptions
+
+	mu sync.RWMutex
+}
+
+// NewStateFile creates a new *StateFile that stores its value at path. The file is not
+// accessed until it is first used.
+//
+// This takes a variadic parameter of type StateFileOptions. If no StateFileOptions are
+// supplied, then the defaults are used. If more than one StateFileOptions are supplied
+// then only the first will be used.
+func NewStateFile[T StateValue](path string, opts ...StateFileOptions) *StateFile[T] {
+	options := defaultStateFileOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Perm == 0 {
+		options.Perm = defaultStateFileOptions().Perm
+	}
+
+	return &StateFile[T]{path: path, options: options}
+}
+
+// Path returns the path of the state file.
+func (sf *StateFile[T]) Path() string {
+	return sf.path
+}
+
+// Load reads the value from the state file. If the file does not exist yet, the zero
+// value of T is returned.
+func (sf *StateFile[T]) Load() (T, error) {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+
+	lock, err := sf.lock(true)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	value, err := sf.load()
+
+	return value, errors.Join(err, lock.Unlock())
+}
+
+// Save writes value to the state file, replacing the previous value.
+func (sf *StateFile[T]) Save(value T) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	lock, err := sf.lock(false)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(sf.save(value), lock.Unlock())
+}
+
+// Update replaces the value in the state file with the result of calling update with the
+// current value, or the zero value of T if the file does not exist yet, and returns the
+// new value. The lock is held throughout, so no other update can happen in between.
+func (sf *StateFile[T]) Update(update func(T) T) (T, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	lock, err := sf.lock(false)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	value, err := sf.load()
+	if err == nil {
+		value = update(value)
+		err = sf.save(value)
+	}
+
+	return value, errors.Join(err, lock.Unlock())
+}
+
+// lock takes the lock on the state file, which is shared if shared is set and the lock
+// can be shared. Lock files cannot be shared, so readers exclude each other when they
+// are used, including on platforms without locks provided by the operating system.
+func (sf *StateFile[T]) lock(shared bool) (*FileLock, error) {
+	options := sf.options.Lock
+	options.Shared = shared && !options.LockFile
+
+	lock, err := Lock(context.Background(), sf.path+".lock", options)
+	if options.Shared && errors.Is(err, errSharedLockFile) {
+		options.Shared = false
+		return Lock(context.Background(), sf.path+".lock", options)
+	}
+
+	return lock, err
+}
+
+func (sf *StateFile[T]) load() (T, error) {
+	var value T
+
+	data, err := os.ReadFile(sf.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return value, nil
+	} else if err != nil {
+		return value, err
+	}
+
+	if err := value.Unmarshal(&value, data); err != nil {
+		return value, fmt.Errorf("could not unmarshal state from %q: %w", sf.path, err)
+	}
+
+	return value, nil
+}
+
+func (sf *StateFile[T]) save(value T) error {
+	data, err := value.Marshal()
+	if err != nil {
+		return fmt.Errorf("could not marshal state for %q: %w", sf.path, err)
+	}
+
+	return AtomicWriteFile(sf.path, data, sf.options.Perm)
+}
@@ -0,0 +1,67 @@
+package fsutils
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type counterState struct {
+	Count int `json:"count"`
+}
+
+func (cs counterState) Marshal() ([]byte, error)           { return json.Marshal(cs) }
+func (cs counterState) Unmarshal(v any, data []byte) error { return json.Unmarshal(data, v) }
+
+func TestStateFileUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// Separate StateFiles stand in for separate processes, which only share the lock.
+	var wg sync.WaitGroup
+	for range 4 {
+		state := NewStateFile[counterState](path)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for range 10 {
+				if _, err := state.Update(func(cs counterState) counterState {
+					cs.Count++
+					return cs
+				}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, err := NewStateFile[counterState](path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.Count != 40 {
+		t.Errorf("expected a count of 40, got %d", value.Count)
+	}
+}
+
+func TestStateFileLoadWithLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// Lock files cannot be shared, so loading takes an exclusive lock instead of failing,
+	// as it does on platforms without locks provided by the operating system.
+	state := NewStateFile[counterState](path, StateFileOptions{Lock: LockOptions{LockFile: true}})
+	if err := state.Save(counterState{Count: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := state.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.Count != 3 {
+		t.Errorf("expected a count of 3, got %d", value.Count)
+	}
+}
@@ -0,0 +1,98 @@
+package fsutils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// SwapDirOptions is a struct used by SwapDir to define certain optional parameters.
+type SwapDirOptions struct {
+	// Backup, if not empty, is the path that the previous contents of the target directory
+	// are moved to, instead of being removed, so that they can be rolled back to. It must
+	// be on the same filesystem as the target, and must not exist.
+	Backup string
+}
+
+func defaultSwapDirOptions() SwapDirOptions {
+	return SwapDirOptions{}
+}
+
+// SwapDir replaces the directory at targetDir with the directory at newDir, which is
+// typically a staging directory that has been fully built beforehand, such as a new
+// version of a site being deployed. The previous target directory, if any, is renamed
+// out of the way, newDir is renamed into its place, and then the previous directory is
+// removed. If newDir cannot be put in place, the previous directory is restored.
+//
+// Both renames happen within the parent of targetDir, so newDir must be on the same
+// filesystem, which is most easily done by creating it alongside targetDir. There is a
+// brief moment between the renames when targetDir does not exist, so readers that must
+// never see its absence should go through a symbolic link that is swapped instead.
+//
+// This takes a variadic parameter of type SwapDirOptions. If no SwapDirOptions are
+// supplied, then the defaults are used. If more than one SwapDirOptions are supplied
+// then only the first will be used.
+func SwapDir(newDir, targetDir string, opts ...SwapDirOptions) error {
+	options := defaultSwapDirOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	info, err := os.Stat(newDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "swap", Path: newDir, Err: errors.New("not a directory")}
+	}
+
+	parent := filepath.Dir(targetDir)
+
+	info, err = os.Lstat(targetDir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if err := os.Rename(newDir, targetDir); err != nil {
+			return err
+		}
+
+		return syncDir(parent)
+	case err != nil:
+		return err
+	case !info.IsDir():
+		return &fs.PathError{Op: "swap", Path: targetDir, Err: errors.New("not a directory")}
+	}
+
+	previous := options.Backup
+	if previous == "" {
+		var suffix [6]byte
+		_, _ = rand.Read(suffix[:])
+
+		previous = filepath.Join(parent, "."+filepath.Base(targetDir)+".old-"+hex.EncodeToString(suffix[:]))
+	}
+
+	if err := os.Rename(targetDir, previous); err != nil {
+		return err
+	}
+
+	if err := os.Rename(newDir, targetDir); err != nil {
+		if restoreErr := os.Rename(previous, targetDir); restoreErr != nil {
+			return errors.Join(err, fmt.Errorf("could not restore %q from %q: %w", targetDir, previous, restoreErr))
+		}
+
+		return err
+	}
+
+	if err := syncDir(parent); err != nil {
+		return err
+	}
+
+	if options.Backup == "" {
+		return os.RemoveAll(previous)
+	}
+
+	return nil
+}
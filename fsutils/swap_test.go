@@ -0,0 +1,46 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSwapDir(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "site")
+
+	for i, version := range []string{"one", "two", "three"} {
+		staging := filepath.Join(root, "staging")
+		if err := os.Mkdir(staging, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(staging, "version"), []byte(version), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		var options SwapDirOptions
+		if i == 2 {
+			options.Backup = filepath.Join(root, "previous")
+		}
+
+		if err := SwapDir(staging, target, options); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(target, "version")); string(data) != version {
+			t.Fatalf("expected version %q, got %q", version, data)
+		}
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(root, "previous", "version")); string(data) != "two" {
+		t.Errorf("expected the backup to hold version %q, got %q", "two", data)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected only the target and backup to be left, got %v", entries)
+	}
+}
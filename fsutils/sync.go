@@ -0,0 +1,304 @@
+package fsutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CompareMode decides how files are compared to find the ones that have changed.
+type CompareMode int
+
+const (
+	// CompareSizeAndModTime treats files as unchanged if they have the same size and
+	// modification time, to the second. This is fast, but misses changes that keep both.
+	CompareSizeAndModTime CompareMode = iota
+
+	// CompareContents treats files as unchanged if they have the same size and the same
+	// SHA-256 hash, which requires reading both of them in full.
+	CompareContents
+)
+
+// String returns the name of the mode.
+func (cm CompareMode) String() string {
+	switch cm {
+	case CompareSizeAndModTime:
+		return "size-and-mod-time"
+	case CompareContents:
+		return "contents"
+	default:
+		return fmt.Sprintf("CompareMode(%d)", int(cm))
+	}
+}
+
+// SyncAction is a change made by SyncDirs.
+type SyncAction int
+
+const (
+	// SyncCopy is the copy of a new or changed file, symbolic link, or directory.
+	SyncCopy SyncAction = iota
+
+	// SyncDelete is the removal of something in the destination that is not in the
+	// source.
+	SyncDelete
+)
+
+// String returns the name of the action.
+func (sa SyncAction) String() string {
+	switch sa {
+	case SyncCopy:
+		return "copy"
+	case SyncDelete:
+		return "delete"
+	default:
+		return fmt.Sprintf("SyncAction(%d)", int(sa))
+	}
+}
+
+// SyncOptions is a struct used by SyncDirs to define certain optional parameters.
+type SyncOptions struct {
+	// Delete removes files and directories in the destination that are not in the
+	// source.
+	Delete bool
+
+	// Compare decides how files are compared to find the ones that have changed.
+	Compare CompareMode
+
+	// OnProgress, if not nil, is called before each change is made, with the path
+	// relative to the directories being synchronised.
+	OnProgress func(action SyncAction, path string)
+}
+
+func defaultSyncOptions() SyncOptions {
+	return SyncOptions{}
+}
+
+// SyncResult describes the changes made by SyncDirs. Paths are relative to the
+// directories being synchronised.
+type SyncResult struct {
+	// Copied are the new or changed files, symbolic links, and directories that were
+	// copied.
+	Copied []string
+
+	// Deleted are the files and directories that were removed from the destination.
+	Deleted []string
+
+	// Unchanged is the number of files and symbolic links that were already up to date.
+	Unchanged int
+}
+
+// SyncDirs makes the directory at dst, which is created if it does not exist, mirror
+// the directory at src. New and changed files are copied along with their permissions
+// and modification times, and symbolic links are recreated. If an error occurs, the
+// synchronisation stops and the changes made so far are returned along with it. dst
+// must not be inside src, and, when Delete is set, src must not be inside dst either.
+//
+// This takes a variadic parameter of type SyncOptions. If no SyncOptions are supplied,
+// then the defaults are used. If more than one SyncOptions are supplied then only the
+// first will be used.
+func SyncDirs(src, dst string, opts ...SyncOptions) (SyncResult, error) {
+	options := defaultSyncOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	if !info.IsDir() {
+		return SyncResult{}, &fs.PathError{Op: "sync", Path: src, Err: errors.New("not a directory")}
+	}
+
+	if within, err := isWithin(dst, src); err != nil {
+		return SyncResult{}, err
+	} else if within {
+		return SyncResult{}, fmt.Errorf("cannot synchronise %q into itself at %q", src, dst)
+	}
+
+	// Deleting from a dst that contains src would delete src too, as it is not in itself.
+	if options.Delete {
+		if within, err := isWithin(src, dst); err != nil {
+			return SyncResult{}, err
+		} else if within {
+			return SyncResult{}, fmt.Errorf("cannot synchronise %q with deletion into %q, which contains it", src, dst)
+		}
+	}
+
+	ds := &dirSync{src: src, dst: dst, options: options}
+	if err := os.MkdirAll(dst, 0o777); err != nil {
+		return ds.result, err
+	}
+
+	if err := filepath.WalkDir(src, ds.syncEntry); err != nil {
+		return ds.result, err
+	}
+
+	if options.Delete {
+		err = filepath.WalkDir(dst, ds.deleteEntry)
+	}
+
+	return ds.result, err
+}
+
+// dirSync holds the state of a single SyncDirs call.
+type dirSync struct {
+	src, dst string
+	options  SyncOptions
+	result   SyncResult
+}
+
+// progress calls the progress callback, if any, and records the change.
+func (ds *dirSync) progress(action SyncAction, rel string) {
+	if ds.options.OnProgress != nil {
+		ds.options.OnProgress(action, rel)
+	}
+
+	switch action {
+	case SyncCopy:
+		ds.result.Copied = append(ds.result.Copied, rel)
+	case SyncDelete:
+		ds.result.Deleted = append(ds.result.Deleted, rel)
+	}
+}
+
+// syncEntry brings the copy of the entry at path in the source directory up to date.
+func (ds *dirSync) syncEntry(path string, entry fs.DirEntry, err error) error {
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(ds.src, path)
+	if err != nil || rel == "." {
+		return err
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(ds.dst, rel)
+	existing, err := os.Lstat(target)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if existing != nil && existing.Mode().Type() != info.Mode().Type() {
+		ds.progress(SyncDelete, rel)
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		existing = nil
+	}
+
+	switch {
+	case info.IsDir():
+		if existing != nil {
+			return nil
+		}
+
+		ds.progress(SyncCopy, rel)
+		return os.Mkdir(target, info.Mode().Perm()|0o700)
+	case info.Mode()&fs.ModeSymlink != 0:
+		return ds.syncSymlink(path, target, rel, existing != nil)
+	case !info.Mode().IsRegular():
+		return nil
+	}
+
+	if existing != nil {
+		same, err := ds.same(path, info, target, existing)
+		if err != nil {
+			return err
+		}
+		if same {
+			ds.result.Unchanged++
+			return nil
+		}
+	}
+
+	ds.progress(SyncCopy, rel)
+
+	return CopyFile(path, target, CopyFileOptions{PreserveMode: true, PreserveTimes: true})
+}
+
+// syncSymlink recreates the symbolic link at path as target, unless it already exists
+// with the same destination.
+func (ds *dirSync) syncSymlink(path, target, rel string, exists bool) error {
+	link, err := os.Readlink(path)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		if existing, err := os.Readlink(target); err == nil && existing == link {
+			ds.result.Unchanged++
+			return nil
+		}
+
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+
+	ds.progress(SyncCopy, rel)
+
+	return os.Symlink(link, target)
+}
+
+// same reports whether the file at a, which has infoA, is the same as the file at b,
+// which has infoB, according to the compare mode of the options.
+func (ds *dirSync) same(a string, infoA fs.FileInfo, b string, infoB fs.FileInfo) (bool, error) {
+	if infoA.Size() != infoB.Size() {
+		return false, nil
+	}
+
+	switch ds.options.Compare {
+	case CompareSizeAndModTime:
+		return infoA.ModTime().Unix() == infoB.ModTime().Unix(), nil
+	case CompareContents:
+		hashA, err := sha256File(a)
+		if err != nil {
+			return false, err
+		}
+
+		hashB, err := sha256File(b)
+		if err != nil {
+			return false, err
+		}
+
+		return hashA == hashB, nil
+	default:
+		return false, fmt.Errorf("unknown compare mode %v", ds.options.Compare)
+	}
+}
+
+// deleteEntry removes the entry at path in the destination directory if it is not in the
+// source directory.
+func (ds *dirSync) deleteEntry(path string, entry fs.DirEntry, err error) error {
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(ds.dst, path)
+	if err != nil || rel == "." {
+		return err
+	}
+
+	if _, err := os.Lstat(filepath.Join(ds.src, rel)); !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	ds.progress(SyncDelete, rel)
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+
+	if entry.IsDir() {
+		return filepath.SkipDir
+	}
+
+	return nil
+}
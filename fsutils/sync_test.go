@@ -0,0 +1,80 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSyncDirs(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	write := func(path, contents string) {
+		t.Helper()
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(filepath.Join(src, "a"), "a")
+	write(filepath.Join(src, "sub", "b"), "b")
+	write(filepath.Join(dst, "extra", "c"), "c")
+
+	var progress []string
+	options := SyncOptions{
+		Delete:     true,
+		Compare:    CompareContents,
+		OnProgress: func(action SyncAction, path string) { progress = append(progress, action.String()+" "+path) },
+	}
+
+	result, err := SyncDirs(src, dst, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"a", "sub", filepath.Join("sub", "b")}
+	if !slices.Equal(result.Copied, want) {
+		t.Errorf("expected %v to be copied, got %v", want, result.Copied)
+	}
+	if !slices.Equal(result.Deleted, []string{"extra"}) {
+		t.Errorf("expected the extra directory to be deleted, got %v", result.Deleted)
+	}
+	if len(progress) != 4 {
+		t.Errorf("expected four progress callbacks, got %v", progress)
+	}
+
+	write(filepath.Join(src, "a"), "A")
+	if result, err = SyncDirs(src, dst, options); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Copied, []string{"a"}) || result.Unchanged != 1 {
+		t.Errorf("expected only the changed file to be copied, got %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "a")); string(data) != "A" {
+		t.Errorf("read %q, want %q", data, "A")
+	}
+}
+
+func TestSyncDirsIntoAncestor(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "b")
+	if err := os.Mkdir(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "precious.txt"), []byte("precious"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SyncDirs(src, root, SyncOptions{Delete: true}); err == nil {
+		t.Error("expected an error when deleting from a directory that contains the source")
+	}
+	if data, _ := os.ReadFile(filepath.Join(src, "precious.txt")); string(data) != "precious" {
+		t.Errorf("expected the source to be left alone, got %q", data)
+	}
+}
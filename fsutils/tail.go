@@ -0,0 +1,247 @@
+package fsutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"time"
+)
+
+// TailOptions is a struct used by Tail to define certain optional parameters.
+type TailOptions struct {
+	// Lines is the number of lines already in the file that are delivered before any
+	// appended ones, as with "tail -n". It is ignored if FromStart is set.
+	Lines int
+
+	// FromStart delivers every line already in the file, rather than starting at its
+	// end.
+	FromStart bool
+
+	// PollInterval is how often the file is checked for appended data, truncation, and
+	// rotation. If it is zero or negative, 250 milliseconds is used.
+	PollInterval time.Duration
+
+	// MaxLineSize is the length, in bytes, that lines may be at most. A longer line stops
+	// the tail with bufio.ErrTooLong. If it is zero or negative, 1MiB is used.
+	MaxLineSize int
+}
+
+func defaultTailOptions() TailOptions {
+	return TailOptions{
+		PollInterval: 250 * time.Millisecond,
+		MaxLineSize:  1 << 20,
+	}
+}
+
+// Tail returns an iterator over the lines appended to the file at path, like "tail -F",
+// until ctx is done, in which case ctx.Err() is yielded as the final error. Lines are
+// yielded without their line endings, and a final line is only yielded once it has been
+// terminated by a newline.
+//
+// If the file is truncated, it is read again from the start. If it is rotated, by being
+// renamed or removed and replaced with a new file, the rest of the old file is read,
+// including an unterminated final line, and then the new file is followed from its
+// start. If the file does not exist, Tail waits for it to be created.
+//
+// This takes a variadic parameter of type TailOptions. If no TailOptions are supplied,
+// then the defaults are used. If more than one TailOptions are supplied then only the
+// first will be used.
+func Tail(ctx context.Context, path string, opts ...TailOptions) iter.Seq2[string, error] {
+	options := defaultTailOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = 250 * time.Millisecond
+	}
+	if options.MaxLineSize <= 0 {
+		options.MaxLineSize = 1 << 20
+	}
+
+	return func(yield func(string, error) bool) {
+		ticker := time.NewTicker(options.PollInterval)
+		defer ticker.Stop()
+
+		wait := func() bool {
+			select {
+			case <-ticker.C:
+				return true
+			case <-ctx.Done():
+				yield("", ctx.Err())
+				return false
+			}
+		}
+
+		var (
+			file *os.File
+			err  error
+		)
+		for file, err = os.Open(path); errors.Is(err, fs.ErrNotExist); file, err = os.Open(path) {
+			if !wait() {
+				return
+			}
+
+			// A file that did not exist when the tail started is read in full.
+			options.FromStart = true
+		}
+		if err != nil {
+			yield("", err)
+			return
+		}
+		defer func() { _ = file.Close() }()
+
+		offset, err := tailStart(file, options)
+		if err != nil {
+			yield("", err)
+			return
+		}
+
+		var pending []byte
+		buf := make([]byte, 32*1024)
+
+		// lines yields the complete lines in pending, leaving any final fragment without a
+		// newline, and reports whether the caller wants more.
+		lines := func() bool {
+			line := 0
+			for {
+				end := bytes.IndexByte(pending[line:], '\n')
+				if end < 0 {
+					break
+				}
+
+				text := bytes.TrimSuffix(pending[line:line+end], []byte("\r"))
+				if !yield(string(text), nil) {
+					return false
+				}
+
+				line += end + 1
+			}
+			pending = append(pending[:0], pending[line:]...)
+
+			return true
+		}
+
+		for {
+			n, err := file.Read(buf)
+			offset += int64(n)
+			pending = append(pending, buf[:n]...)
+
+			if !lines() {
+				return
+			}
+
+			if len(pending) > options.MaxLineSize {
+				yield("", bufio.ErrTooLong)
+				return
+			}
+
+			if err != nil && !errors.Is(err, io.EOF) {
+				yield("", err)
+				return
+			}
+			if n > 0 {
+				continue
+			}
+
+			if !wait() {
+				return
+			}
+
+			// Once the file has been read to its end, check whether it was truncated or
+			// replaced.
+			current, err := os.Stat(path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				yield("", err)
+				return
+			}
+
+			opened, err := file.Stat()
+			if err != nil {
+				yield("", err)
+				return
+			}
+
+			switch {
+			case current != nil && !os.SameFile(current, opened):
+				replacement, err := os.Open(path)
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				} else if err != nil {
+					yield("", err)
+					return
+				}
+
+				// Any data written to the old file since it was last read is read before
+				// switching, as it may have been rotated while still being written.
+				if rest, err := io.ReadAll(file); err == nil && len(rest) > 0 {
+					pending = append(pending, rest...)
+				}
+				if !lines() {
+					_ = replacement.Close()
+					return
+				}
+
+				// A final line of the old file that has no newline is yielded as it is, as
+				// nothing more will be written to it.
+				if len(pending) > 0 && !yield(string(bytes.TrimSuffix(pending, []byte("\r"))), nil) {
+					_ = replacement.Close()
+					return
+				}
+
+				_ = file.Close()
+				file, offset, pending = replacement, 0, pending[:0]
+			case opened.Size() < offset:
+				if _, err := file.Seek(0, io.SeekStart); err != nil {
+					yield("", err)
+					return
+				}
+
+				offset, pending = 0, pending[:0]
+			}
+		}
+	}
+}
+
+// tailStart seeks file to where the tail should start according to options, and returns
+// the offset.
+func tailStart(file *os.File, options TailOptions) (int64, error) {
+	if options.FromStart {
+		return 0, nil
+	}
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil || options.Lines <= 0 {
+		return size, err
+	}
+
+	// Read backwards from the end until enough newlines have been found, not counting a
+	// newline that ends the file.
+	buf := make([]byte, 4096)
+	lines := 0
+	for end := size; end > 0; {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := file.ReadAt(chunk, start); err != nil {
+			return 0, err
+		}
+
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' || start+int64(i) == size-1 {
+				continue
+			}
+
+			if lines++; lines == options.Lines {
+				return file.Seek(start+int64(i)+1, io.SeekStart)
+			}
+		}
+
+		end = start
+	}
+
+	return file.Seek(0, io.SeekStart)
+}
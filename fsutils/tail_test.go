@@ -0,0 +1,119 @@
+package fsutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	appendLine := func(line string) {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer file.Close()
+
+		_, _ = file.WriteString(line)
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		appendLine("fo")
+		time.Sleep(30 * time.Millisecond)
+		appendLine("ur\r\n")
+
+		// Rotate the log.
+		time.Sleep(30 * time.Millisecond)
+		_ = os.Rename(path, path+".1")
+		_ = os.WriteFile(path, []byte("five\n"), 0o644)
+
+		// Truncate it.
+		time.Sleep(30 * time.Millisecond)
+		_ = os.WriteFile(path, []byte("six\n"), 0o644)
+	}()
+
+	want := []string{"two", "three", "four", "five", "six"}
+	var got []string
+	for line, err := range Tail(ctx, path, TailOptions{Lines: 2, PollInterval: 5 * time.Millisecond}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, line)
+		if len(got) == len(want) {
+			break
+		}
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	cancel()
+	for _, err := range Tail(ctx, path) {
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	}
+}
+
+func TestTailRotationSplitsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+
+		// Write and rotate between two polls, so that the lines are only read once the
+		// rotation has been noticed.
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer file.Close()
+
+		_, _ = file.WriteString("one\ntwo\nthree\nfragment")
+		_ = os.Rename(path, path+".1")
+		_ = os.WriteFile(path, []byte("four\n"), 0o644)
+	}()
+
+	want := []string{"one", "two", "three", "fragment", "four"}
+	var got []string
+	for line, err := range Tail(ctx, path, TailOptions{PollInterval: 100 * time.Millisecond}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, line)
+		if len(got) == len(want) {
+			break
+		}
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+}
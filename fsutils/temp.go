@@ -0,0 +1,115 @@
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// CleanupRegistrar is the part of testing.TB used by TempDir and TempFile to clean up
+// after a test, so that this package does not need to import testing. It is satisfied by
+// *testing.T, *testing.B, and *testing.F.
+type CleanupRegistrar interface {
+	Cleanup(func())
+	Errorf(format string, args ...any)
+}
+
+// TempOptions is a struct used by TempDir and TempFile to define certain optional
+// parameters.
+type TempOptions struct {
+	// Dir is the directory the temporary file or directory is created in. If it is empty,
+	// the default directory for temporary files is used, see os.TempDir.
+	Dir string
+
+	// Prefix and Suffix are put either side of the random part of the name.
+	Prefix, Suffix string
+
+	// TB, if not nil, has the cleanup function registered with it, so that it is run when
+	// the test and its subtests complete. A failure to clean up is reported with Errorf.
+	TB CleanupRegistrar
+}
+
+func defaultTempOptions() TempOptions {
+	return TempOptions{}
+}
+
+// TempDir creates a new temporary directory and returns its path, along with a function
+// that removes it and everything in it. The cleanup function is safe to call more than
+// once, and only removes the directory the first time.
+//
+// This takes a variadic parameter of type TempOptions. If no TempOptions are supplied,
+// then the defaults are used. If more than one TempOptions are supplied then only the
+// first will be used.
+func TempDir(opts ...TempOptions) (string, func() error, error) {
+	options := defaultTempOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	dir, err := os.MkdirTemp(options.Dir, options.pattern())
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanup := options.register(func() error {
+		return os.RemoveAll(dir)
+	})
+
+	return dir, cleanup, nil
+}
+
+// TempFile creates and opens a new temporary file for reading and writing, and returns
+// it along with a function that closes and removes it. The cleanup function is safe to
+// call more than once, including after the file has already been closed, and only
+// removes the file the first time.
+//
+// This takes a variadic parameter of type TempOptions. If no TempOptions are supplied,
+// then the defaults are used. If more than one TempOptions are supplied then only the
+// first will be used.
+func TempFile(opts ...TempOptions) (*os.File, func() error, error) {
+	options := defaultTempOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	file, err := os.CreateTemp(options.Dir, options.pattern())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := options.register(func() error {
+		err := file.Close()
+		if errors.Is(err, os.ErrClosed) {
+			err = nil
+		}
+
+		if removeErr := os.Remove(file.Name()); !errors.Is(removeErr, fs.ErrNotExist) {
+			err = errors.Join(err, removeErr)
+		}
+
+		return err
+	})
+
+	return file, cleanup, nil
+}
+
+// pattern returns the pattern given to os.MkdirTemp and os.CreateTemp.
+func (to TempOptions) pattern() string {
+	return to.Prefix + "*" + to.Suffix
+}
+
+// register makes cleanup run only once, and registers it with TB if it is set.
+func (to TempOptions) register(cleanup func() error) func() error {
+	cleanup = sync.OnceValue(cleanup)
+
+	if to.TB != nil {
+		to.TB.Cleanup(func() {
+			if err := cleanup(); err != nil {
+				to.TB.Errorf("could not clean up temporary path: %v", err)
+			}
+		})
+	}
+
+	return cleanup
+}
@@ -0,0 +1,52 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTempDir(t *testing.T) {
+	parent := t.TempDir()
+
+	dir, cleanup, err := TempDir(TempOptions{Dir: parent, Prefix: "build-", Suffix: ".d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Base(dir)
+	if filepath.Dir(dir) != parent || !strings.HasPrefix(name, "build-") || !strings.HasSuffix(name, ".d") {
+		t.Errorf("unexpected temporary directory %q", dir)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := cleanup(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if PathExists(dir) {
+		t.Error("expected the temporary directory to be removed")
+	}
+}
+
+func TestTempFileRegistersCleanup(t *testing.T) {
+	var name string
+
+	t.Run("file", func(t *testing.T) {
+		file, _, err := TempFile(TempOptions{Dir: t.TempDir(), TB: t})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		name = file.Name()
+	})
+
+	if PathExists(name) {
+		t.Error("expected the temporary file to be removed when the test completed")
+	}
+}
@@ -0,0 +1,56 @@
+package fsutils
+
+import (
+	"os"
+	"time"
+)
+
+// TouchOptions is a struct used by Touch to define certain optional parameters.
+type TouchOptions struct {
+	// CreateParents creates any missing parent directories of the file, see
+	// EnsureParentDir.
+	CreateParents bool
+
+	// Time is the access and modification time that is set. If it is zero, the current
+	// time is used.
+	Time time.Time
+}
+
+func defaultTouchOptions() TouchOptions {
+	return TouchOptions{}
+}
+
+// Touch creates an empty file at path if it does not exist, and sets its access and
+// modification times to the current time, like the touch command. The contents of an
+// existing file are left untouched.
+//
+// This takes a variadic parameter of type TouchOptions. If no TouchOptions are supplied,
+// then the defaults are used. If more than one TouchOptions are supplied then only the
+// first will be used.
+func Touch(path string, opts ...TouchOptions) error {
+	options := defaultTouchOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if options.CreateParents {
+		if err := EnsureParentDir(path, 0o777); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o666)
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	now := options.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	return os.Chtimes(path, now, now)
+}
@@ -0,0 +1,39 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTouch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a", "b", "file")
+
+	if err := Touch(path, TouchOptions{CreateParents: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if err := Touch(path, TouchOptions{Time: modTime}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("expected the modification time %v, got %v", modTime, info.ModTime())
+	}
+	if data, _ := os.ReadFile(path); string(data) != "contents" {
+		t.Errorf("expected the contents to be kept, got %q", data)
+	}
+
+	if err := Touch(filepath.Join(t.TempDir(), "missing", "file")); err == nil {
+		t.Error("expected an error without CreateParents")
+	}
+}
@@ -0,0 +1,83 @@
+package fsutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrTrashUnavailable is returned by SafeRemove when a path cannot be moved to the trash,
+// such as on platforms without one, or when the trash is on another filesystem.
+var ErrTrashUnavailable = errors.New("trash unavailable")
+
+// TrashFallback decides what SafeRemove does when a path cannot be moved to the trash.
+type TrashFallback int
+
+const (
+	// TrashFallbackFail leaves the path alone and returns an error wrapping
+	// ErrTrashUnavailable.
+	TrashFallbackFail TrashFallback = iota
+
+	// TrashFallbackRemove removes the path permanently, as os.RemoveAll does.
+	TrashFallbackRemove
+)
+
+// String returns the name of the fallback.
+func (tf TrashFallback) String() string {
+	switch tf {
+	case TrashFallbackFail:
+		return "fail"
+	case TrashFallbackRemove:
+		return "remove"
+	default:
+		return fmt.Sprintf("TrashFallback(%d)", int(tf))
+	}
+}
+
+// SafeRemoveOptions is a struct used by SafeRemove to define certain optional parameters.
+type SafeRemoveOptions struct {
+	// Fallback decides what happens when the path cannot be moved to the trash.
+	Fallback TrashFallback
+}
+
+func defaultSafeRemoveOptions() SafeRemoveOptions {
+	return SafeRemoveOptions{}
+}
+
+// SafeRemove moves the file or directory at path to the trash of the operating system,
+// so that it can be restored by the user, rather than removing it permanently. This is
+// intended for user-facing tools.
+//
+// On Linux and other Unix platforms, the XDG trash specification is followed, which is
+// used by most desktop environments: paths are moved to the trash in $XDG_DATA_HOME, or
+// to a ".Trash-$UID" directory at the root of the filesystem they are on if that is a
+// different one. On macOS, paths are moved to ~/.Trash, and on Windows to the Recycle
+// Bin.
+//
+// This takes a variadic parameter of type SafeRemoveOptions. If no SafeRemoveOptions are
+// supplied, then the defaults are used. If more than one SafeRemoveOptions are supplied
+// then only the first will be used.
+func SafeRemove(path string, opts ...SafeRemoveOptions) error {
+	options := defaultSafeRemoveOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if _, err := os.Lstat(path); err != nil {
+		return err
+	}
+
+	err := trash(path)
+	if err == nil || !errors.Is(err, ErrTrashUnavailable) {
+		return err
+	}
+
+	switch options.Fallback {
+	case TrashFallbackFail:
+		return err
+	case TrashFallbackRemove:
+		return os.RemoveAll(path)
+	default:
+		return fmt.Errorf("unknown trash fallback %v", options.Fallback)
+	}
+}
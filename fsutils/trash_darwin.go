@@ -0,0 +1,46 @@
+package fsutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func trash(path string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrTrashUnavailable, err)
+	}
+
+	dir := filepath.Join(home, ".Trash")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("%w: %w", ErrTrashUnavailable, err)
+	}
+
+	// Names in use are made unique in the same way as Finder, with a counter before the
+	// extension.
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+
+	name := base
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(filepath.Join(dir, name)); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+
+		name = stem + " " + strconv.Itoa(i) + ext
+	}
+
+	err = os.Rename(path, filepath.Join(dir, name))
+	if errors.Is(err, syscall.EXDEV) {
+		return fmt.Errorf("%w: %w", ErrTrashUnavailable, err)
+	}
+
+	return err
+}
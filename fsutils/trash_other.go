@@ -0,0 +1,9 @@
+//go:build !unix && !(windows && (amd64 || arm64))
+
+package fsutils
+
+import "fmt"
+
+func trash(path string) error {
+	return fmt.Errorf("%w: no trash on this platform for %q", ErrTrashUnavailable, path)
+}
@@ -0,0 +1,51 @@
+package fsutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSafeRemove(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the trash is only isolated from the user's own on Linux")
+	}
+
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+
+	path := filepath.Join(dir, "file")
+	for range 2 {
+		if err := os.WriteFile(path, []byte("contents"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := SafeRemove(path); err != nil {
+			t.Fatal(err)
+		}
+		if PathExists(path) {
+			t.Fatal("expected the file to be moved")
+		}
+	}
+
+	trash := filepath.Join(dir, "data", "Trash")
+	for _, name := range []string{"files/file", "files/file.2", "info/file.trashinfo", "info/file.2.trashinfo"} {
+		if !PathExists(filepath.Join(trash, filepath.FromSlash(name))) {
+			t.Errorf("expected %s to be in the trash", name)
+		}
+	}
+
+	info, err := os.ReadFile(filepath.Join(trash, "info", "file.trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(info), "Path="+path+"\n") {
+		t.Errorf("expected the original path to be recorded, got:\n%s", info)
+	}
+
+	if err := SafeRemove(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build windows && (amd64 || arm64)
+
+package fsutils
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+var procSHFileOperation = syscall.NewLazyDLL("shell32.dll").NewProc("SHFileOperationW")
+
+const (
+	foDelete          = 0x0003
+	fofSilent         = 0x0004
+	fofNoConfirmation = 0x0010
+	fofAllowUndo      = 0x0040
+	fofNoErrorUI      = 0x0400
+)
+
+// shFileOpStruct is SHFILEOPSTRUCTW, which is naturally aligned on 64-bit Windows.
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted int32
+	hNameMappings         uintptr
+	lpszProgressTitle     *uint16
+}
+
+func trash(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	// The paths are a list terminated by an extra NUL.
+	from, err := syscall.UTF16FromString(path)
+	if err != nil {
+		return err
+	}
+	from = append(from, 0)
+
+	op := shFileOpStruct{
+		wFunc:  foDelete,
+		pFrom:  &from[0],
+		fFlags: fofAllowUndo | fofNoConfirmation | fofSilent | fofNoErrorUI,
+	}
+
+	if code, _, _ := procSHFileOperation.Call(uintptr(unsafe.Pointer(&op))); code != 0 {
+		return fmt.Errorf("%w: SHFileOperation failed with code %#x", ErrTrashUnavailable, code)
+	}
+	if op.fAnyOperationsAborted != 0 {
+		return fmt.Errorf("%w: moving %q to the Recycle Bin was aborted", ErrTrashUnavailable, path)
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+//go:build unix && !darwin
+
+package fsutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+func trash(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	dir, err := xdgTrashDir(path, info)
+	if err != nil {
+		return err
+	}
+
+	return trashTo(dir, path)
+}
+
+// xdgTrashDir returns the trash directory that the path with info should be moved to,
+// which is the home trash if it is on the same filesystem, and a trash at the top of the
+// filesystem of path otherwise.
+func xdgTrashDir(path string, info fs.FileInfo) (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrTrashUnavailable, err)
+		}
+
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	home := filepath.Join(dataHome, "Trash")
+	if err := os.MkdirAll(home, 0o700); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTrashUnavailable, err)
+	}
+
+	homeInfo, err := os.Stat(home)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTrashUnavailable, err)
+	}
+
+	device, ok := fileDevice(info)
+	if homeDevice, homeOK := fileDevice(homeInfo); !ok || !homeOK || device == homeDevice {
+		return home, nil
+	}
+
+	// Find the top of the filesystem by walking up until the device changes.
+	top := filepath.Dir(path)
+	for parent := filepath.Dir(top); parent != top; parent = filepath.Dir(top) {
+		parentInfo, err := os.Stat(parent)
+		if err != nil {
+			break
+		}
+		if parentDevice, _ := fileDevice(parentInfo); parentDevice != device {
+			break
+		}
+
+		top = parent
+	}
+
+	return filepath.Join(top, ".Trash-"+strconv.Itoa(os.Getuid())), nil
+}
+
+// trashTo moves the path, which is absolute, to the trash directory dir, recording where
+// it came from so that it can be restored.
+func trashTo(dir, path string) error {
+	files, infos := filepath.Join(dir, "files"), filepath.Join(dir, "info")
+	for _, sub := range []string{files, infos} {
+		if err := os.MkdirAll(sub, 0o700); err != nil {
+			return fmt.Errorf("%w: %w", ErrTrashUnavailable, err)
+		}
+	}
+
+	// The name is reserved by creating its info file exclusively.
+	base := filepath.Base(path)
+	name := base
+	var info *os.File
+	for i := 2; ; i++ {
+		var err error
+		info, err = os.OpenFile(filepath.Join(infos, name+".trashinfo"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%w: %w", ErrTrashUnavailable, err)
+		}
+
+		name = base + "." + strconv.Itoa(i)
+	}
+
+	_, err := fmt.Fprintf(info, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: path}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	err = errors.Join(err, info.Close())
+
+	if err == nil {
+		err = os.Rename(path, filepath.Join(files, name))
+		if errors.Is(err, syscall.EXDEV) {
+			err = fmt.Errorf("%w: %w", ErrTrashUnavailable, err)
+		}
+	}
+
+	if err != nil {
+		_ = os.Remove(info.Name())
+		return err
+	}
+
+	return nil
+}
+
+// fileDevice returns the ID of the device that the file described by info is on.
+func fileDevice(info fs.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	// The type of the field differs between platforms.
+	return uint64(stat.Dev), true
+}
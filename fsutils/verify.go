@@ -0,0 +1,57 @@
+package fsutils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// ErrChecksumMismatch is returned by CopyFileVerified when the copy does not match the
+// source.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// CopyFileVerified copies the file at src to dst in the same way as CopyFile, but also
+// computes the SHA-256 hash of src as it is read, then syncs the copy to disk and reads
+// it back to check that it has the same hash. If it does not, dst is left untouched and
+// an error wrapping ErrChecksumMismatch is returned. This guards against corruption when
+// copying to unreliable media or network mounts.
+//
+// This takes a variadic parameter of type CopyFileOptions. If no CopyFileOptions are
+// supplied, then the defaults are used. If more than one CopyFileOptions are supplied
+// then only the first will be used.
+func CopyFileVerified(src, dst string, opts ...CopyFileOptions) error {
+	options := defaultCopyFileOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	return copyFileAt(src, dst, options, sha256.New())
+}
+
+// verifyCopy syncs file to disk, reads it back from the start, and checks that it has
+// the digest already accumulated in h.
+func verifyCopy(file *os.File, h hash.Hash) error {
+	want := h.Sum(nil)
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	h.Reset()
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+
+	if got := h.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("copy of %q: %w: expected %x, got %x", file.Name(), ErrChecksumMismatch, want, got)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package fsutils
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyFileVerified(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+
+	if err := os.WriteFile(src, []byte("contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyFileVerified(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "contents" {
+		t.Errorf("read %q, want %q", data, "contents")
+	}
+
+	file, err := os.Create(filepath.Join(dir, "corrupt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	_, _ = h.Write([]byte("expected"))
+	_, _ = file.Write([]byte("corrupted"))
+
+	if err := verifyCopy(file, h); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package fsutils
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WaitForPathOptions is a struct used by WaitForPath and WaitForPathRemoved to define
+// certain optional parameters.
+type WaitForPathOptions struct {
+	// PollInterval is how often the path is checked. If it is zero or negative, 100
+	// milliseconds is used. Where a native Watcher is available, the path is usually
+	// noticed sooner, and polling only catches what the watcher cannot, such as the
+	// parent directory not existing yet.
+	PollInterval time.Duration
+}
+
+func defaultWaitForPathOptions() WaitForPathOptions {
+	return WaitForPathOptions{
+		PollInterval: 100 * time.Millisecond,
+	}
+}
+
+// WaitForPath blocks until path exists, or until ctx is done, in which case ctx.Err() is
+// returned. It returns immediately if path already exists. This is useful for waiting on
+// files made by other processes, such as sockets, lock files, or completion markers. Use
+// context.WithTimeout to wait for a limited time.
+//
+// This takes a variadic parameter of type WaitForPathOptions. If no WaitForPathOptions
+// are supplied, then the defaults are used. If more than one WaitForPathOptions are
+// supplied then only the first will be used.
+func WaitForPath(ctx context.Context, path string, opts ...WaitForPathOptions) error {
+	return waitForPath(ctx, path, true, opts)
+}
+
+// WaitForPathRemoved blocks until path does not exist, or until ctx is done, in which
+// case ctx.Err() is returned. See WaitForPath.
+//
+// This takes a variadic parameter of type WaitForPathOptions. If no WaitForPathOptions
+// are supplied, then the defaults are used. If more than one WaitForPathOptions are
+// supplied then only the first will be used.
+func WaitForPathRemoved(ctx context.Context, path string, opts ...WaitForPathOptions) error {
+	return waitForPath(ctx, path, false, opts)
+}
+
+// waitForPath blocks until whether path exists is exists, using the first of opts, or
+// the defaults if none are given.
+func waitForPath(ctx context.Context, path string, exists bool, opts []WaitForPathOptions) error {
+	options := defaultWaitForPathOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if options.PollInterval <= 0 {
+		options.PollInterval = 100 * time.Millisecond
+	}
+
+	done := func() (bool, error) {
+		_, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return !exists, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		return exists, nil
+	}
+
+	if ok, err := done(); ok || err != nil {
+		return err
+	}
+
+	// The watcher is only used if it is native, as polling is done here anyway.
+	var (
+		events <-chan Event
+		errs   <-chan error
+	)
+	if w, err := NewWatcher(WatcherOptions{}); err == nil {
+		defer w.Close()
+
+		if !w.Polling() && w.Add(filepath.Dir(path)) == nil {
+			events, errs = w.Events(), w.Errors()
+		}
+	}
+
+	ticker := time.NewTicker(options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-events:
+		case <-errs:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if ok, err := done(); ok || err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package fsutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWaitForPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ready")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = os.WriteFile(path, nil, 0o644)
+	}()
+
+	if err := WaitForPath(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = os.Remove(path)
+	}()
+
+	if err := WaitForPathRemoved(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := WaitForPath(short, filepath.Join(path, "missing", "parent")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package fsutils
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventOp describes what happened to a path in an Event. Several operations can be
+// combined in one event when they are coalesced.
+type EventOp uint32
+
+const (
+	// EventCreate is the creation of a path, or the move of something to it.
+	EventCreate EventOp = 1 << iota
+
+	// EventWrite is a change to the contents of a file.
+	EventWrite
+
+	// EventRemove is the removal of a path.
+	EventRemove
+
+	// EventRename is the move of something away from a path. It is reported as an
+	// EventRemove when polling, which cannot tell the two apart.
+	EventRename
+
+	// EventChmod is a change to the metadata of a path, such as its permissions.
+	EventChmod
+)
+
+// String returns the names of the operations in op, separated by "|".
+func (op EventOp) String() string {
+	var names []string
+	for _, name := range []struct {
+		op   EventOp
+		name string
+	}{
+		{EventCreate, "CREATE"},
+		{EventWrite, "WRITE"},
+		{EventRemove, "REMOVE"},
+		{EventRename, "RENAME"},
+		{EventChmod, "CHMOD"},
+	} {
+		if op&name.op != 0 {
+			names = append(names, name.name)
+		}
+	}
+
+	return strings.Join(names, "|")
+}
+
+// Has reports whether op includes all of the operations in other.
+func (op EventOp) Has(other EventOp) bool {
+	return op&other == other
+}
+
+// Event is a change to a path that is being watched by a Watcher.
+type Event struct {
+	// Path is the path that changed, which is the path given to Watcher.Add joined with
+	// the path of the entry within it, if any.
+	Path string
+
+	// Op is what happened to Path.
+	Op EventOp
+}
+
+// String returns a description of the event.
+func (e Event) String() string {
+	return e.Op.String() + " " + e.Path
+}
+
+var (
+	// ErrEventOverflow is reported by a Watcher when the operating system dropped events
+	// because they were not read quickly enough.
+	ErrEventOverflow = errors.New("file system events were dropped")
+
+	// ErrWatcherClosed is returned when using a Watcher that has been closed.
+	ErrWatcherClosed = errors.New("watcher closed")
+)
+
+// WatcherOptions is a struct used by NewWatcher to define certain optional parameters.
+type WatcherOptions struct {
+	// Recursive also watches every directory under the directories that are added,
+	// including ones that are created later.
+	Recursive bool
+
+	// Debounce is how long events are gathered before they are delivered, during which
+	// events for the same path are coalesced into one, with their operations combined.
+	// This keeps a single save from being reported as a burst of events. A zero Debounce
+	// delivers each event as it happens.
+	Debounce time.Duration
+
+	// Poll watches by comparing snapshots of the watched paths every PollInterval,
+	// instead of using the notifications of the operating system. Polling is slower and
+	// costlier, but works on every platform and filesystem, including network mounts.
+	Poll bool
+
+	// PollInterval is how often watched paths are checked when polling. If it is zero or
+	// negative, 1 second is used.
+	PollInterval time.Duration
+}
+
+func defaultWatcherOptions() WatcherOptions {
+	return WatcherOptions{
+		Debounce:     100 * time.Millisecond,
+		PollInterval: time.Second,
+	}
+}
+
+// watcherBackend is the source of the events of a Watcher.
+type watcherBackend interface {
+	add(path string) error
+	remove(path string) error
+	close() error
+}
+
+// Watcher reports changes to files and directories. When a directory is watched, changes
+// to the entries in it are reported, and, if Recursive is set, changes anywhere beneath
+// it.
+//
+// Changes are found using inotify on Linux, kqueue on macOS and the BSDs, and
+// ReadDirectoryChangesW on Windows. As kqueue needs a file descriptor for every watched
+// file and directory, watching large trees there may run into the limit on open files.
+// On every other platform, or if the native backend is unavailable or Poll is set, the
+// watched paths are polled, and changes are only noticed once every PollInterval.
+//
+// Events are delivered on Events, and errors that occur while watching on Errors, both
+// of which must be received from for the watcher to make progress. Alternatively, Run
+// calls a function for each event.
+type Watcher struct {
+	options WatcherOptions
+	backend watcherBackend
+	polling bool
+
+	raw    chan Event
+	events chan Event
+	errors chan error
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+	wg        sync.WaitGroup
+}
+
+// NewWatcher creates a new *Watcher, which does not watch anything until paths are given
+// to Add. On success, the new watcher is returned. On failure, an error is returned.
+//
+// This takes a variadic parameter of type WatcherOptions. If no WatcherOptions are
+// supplied, then the defaults are used. If more than one WatcherOptions are supplied
+// then only the first will be used.
+func NewWatcher(opts ...WatcherOptions) (*Watcher, error) {
+	options := defaultWatcherOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+
+	w := &Watcher{
+		options: options,
+		raw:     make(chan Event),
+		events:  make(chan Event),
+		errors:  make(chan error),
+		done:    make(chan struct{}),
+	}
+
+	if !options.Poll {
+		var err error
+		if w.backend, err = newNativeBackend(w); err != nil {
+			// Fall back to polling, such as when there are no inotify instances left.
+			w.backend = nil
+		}
+	}
+	if w.backend == nil {
+		w.backend = newPollBackend(w)
+		w.polling = true
+	}
+
+	w.wg.Add(1)
+	go w.dispatch()
+
+	return w, nil
+}
+
+// Add starts watching path, which must exist. See Watcher.
+func (w *Watcher) Add(path string) error {
+	select {
+	case <-w.done:
+		return ErrWatcherClosed
+	default:
+	}
+
+	return w.backend.add(filepath.Clean(path))
+}
+
+// Remove stops watching path, which must have been given to Add.
+func (w *Watcher) Remove(path string) error {
+	return w.backend.remove(filepath.Clean(path))
+}
+
+// Polling reports whether the watcher polls for changes, rather than using the
+// notifications of the operating system.
+func (w *Watcher) Polling() bool {
+	return w.polling
+}
+
+// Events returns the channel on which events are delivered, which is closed when the
+// watcher is.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Errors returns the channel on which errors that occur while watching are delivered,
+// such as ErrEventOverflow, which is closed when the watcher is.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Run calls handle for each event until ctx is done, in which case ctx.Err() is returned,
+// the watcher is closed, in which case nil is returned, or an error occurs while
+// watching, in which case it is returned. The watcher is not closed when Run returns.
+func (w *Watcher) Run(ctx context.Context, handle func(Event)) error {
+	for {
+		select {
+		case event, ok := <-w.events:
+			if !ok {
+				return nil
+			}
+
+			handle(event)
+		case err, ok := <-w.errors:
+			if !ok {
+				return nil
+			}
+
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close stops watching every path, and closes the channels returned by Events and
+// Errors. Any events that have not yet been delivered are dropped.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.closeErr = w.backend.close()
+		w.wg.Wait()
+
+		close(w.events)
+		close(w.errors)
+	})
+
+	return w.closeErr
+}
+
+// emit passes event from the backend to be delivered.
+func (w *Watcher) emit(event Event) {
+	select {
+	case w.raw <- event:
+	case <-w.done:
+	}
+}
+
+// fail passes err from the backend to be delivered.
+func (w *Watcher) fail(err error) {
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	}
+}
+
+// dispatch coalesces the events from the backend and delivers them.
+func (w *Watcher) dispatch() {
+	defer w.wg.Done()
+
+	var (
+		pending []Event
+		index   = make(map[string]int)
+		timer   *time.Timer
+		flush   <-chan time.Time
+	)
+
+	for {
+		select {
+		case event := <-w.raw:
+			if i, ok := index[event.Path]; ok {
+				pending[i].Op |= event.Op
+			} else {
+				index[event.Path] = len(pending)
+				pending = append(pending, event)
+			}
+
+			if w.options.Debounce > 0 {
+				if timer == nil {
+					timer = time.NewTimer(w.options.Debounce)
+					flush = timer.C
+				}
+
+				continue
+			}
+		case <-flush:
+			timer, flush = nil, nil
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+
+			return
+		}
+
+		for _, event := range pending {
+			select {
+			case w.events <- event:
+			case <-w.done:
+				return
+			}
+		}
+
+		pending = pending[:0]
+		clear(index)
+	}
+}
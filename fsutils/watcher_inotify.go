@@ -0,0 +1,239 @@
+//go:build linux
+
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask is the set of inotify events that are watched for.
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_DELETE | syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO | syscall.IN_ATTRIB | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyBackend finds changes using inotify.
+type inotifyBackend struct {
+	watcher *Watcher
+	fd      int
+	file    *os.File
+
+	mu    sync.Mutex
+	paths map[int32]string
+	wds   map[string]int32
+}
+
+func newNativeBackend(w *Watcher) (watcherBackend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	ib := &inotifyBackend{
+		watcher: w,
+		fd:      fd,
+		// As the descriptor is non-blocking, reads from the file use the runtime poller
+		// and so are interrupted when it is closed.
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		paths: make(map[int32]string),
+		wds:   make(map[string]int32),
+	}
+
+	w.wg.Add(1)
+	go ib.run()
+
+	return ib, nil
+}
+
+func (ib *inotifyBackend) add(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if err := ib.addWatch(path); err != nil {
+		return err
+	}
+
+	if info.IsDir() && ib.watcher.options.Recursive {
+		return ib.addTree(path, false)
+	}
+
+	return nil
+}
+
+// addWatch adds an inotify watch for path.
+func (ib *inotifyBackend) addWatch(path string) error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	wd, err := syscall.InotifyAddWatch(ib.fd, path, inotifyMask)
+	if err != nil {
+		return &fs.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+	}
+
+	ib.paths[int32(wd)] = path
+	ib.wds[path] = int32(wd)
+
+	return nil
+}
+
+// addTree adds watches for the directories beneath root. If emit is set, a create event
+// is also emitted for everything beneath root, which is used for directories that were
+// created after their parent was watched, as their contents may have been created before
+// the watch was added.
+func (ib *inotifyBackend) addTree(root string, emit bool) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil || path == root {
+			return err
+		}
+
+		if emit {
+			ib.watcher.emit(Event{Path: path, Op: EventCreate})
+		}
+
+		if entry.IsDir() {
+			if err := ib.addWatch(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (ib *inotifyBackend) remove(path string) error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	wd, ok := ib.wds[path]
+	if !ok {
+		return &fs.PathError{Op: "unwatch", Path: path, Err: errors.New("not watched")}
+	}
+
+	// The watches of the directories beneath path are removed along with it.
+	prefix := path + string(filepath.Separator)
+	remove := []int32{wd}
+	for watched, wd := range ib.wds {
+		if strings.HasPrefix(watched, prefix) {
+			remove = append(remove, wd)
+		}
+	}
+
+	var errs []error
+	for _, wd := range remove {
+		if _, err := syscall.InotifyRmWatch(ib.fd, uint32(wd)); err != nil && !errors.Is(err, syscall.EINVAL) {
+			errs = append(errs, os.NewSyscallError("inotify_rm_watch", err))
+		}
+
+		delete(ib.wds, ib.paths[wd])
+		delete(ib.paths, wd)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (ib *inotifyBackend) close() error {
+	return ib.file.Close()
+}
+
+// run reads events from inotify until the watcher is closed.
+func (ib *inotifyBackend) run() {
+	defer ib.watcher.wg.Done()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := ib.file.Read(buf)
+		if errors.Is(err, os.ErrClosed) {
+			return
+		}
+		if err != nil {
+			ib.watcher.fail(err)
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			offset += syscall.SizeofInotifyEvent
+
+			var name string
+			if raw.Len > 0 {
+				name = strings.TrimRight(string(buf[offset:offset+int(raw.Len)]), "\x00")
+				offset += int(raw.Len)
+			}
+
+			ib.handle(raw.Wd, raw.Mask, name)
+		}
+	}
+}
+
+// handle translates a single inotify event into an Event.
+func (ib *inotifyBackend) handle(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		ib.watcher.fail(ErrEventOverflow)
+		return
+	}
+
+	ib.mu.Lock()
+	path, ok := ib.paths[wd]
+	if ok && mask&syscall.IN_IGNORED != 0 {
+		// The watch was removed, either explicitly or because the path was deleted.
+		delete(ib.paths, wd)
+		if ib.wds[path] == wd {
+			delete(ib.wds, path)
+		}
+	}
+	ib.mu.Unlock()
+
+	if !ok || mask&syscall.IN_IGNORED != 0 {
+		return
+	}
+
+	if name != "" {
+		path = filepath.Join(path, name)
+	}
+
+	var op EventOp
+	if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		op |= EventCreate
+	}
+	if mask&syscall.IN_MODIFY != 0 {
+		op |= EventWrite
+	}
+	if mask&(syscall.IN_DELETE|syscall.IN_DELETE_SELF) != 0 {
+		op |= EventRemove
+	}
+	if mask&(syscall.IN_MOVED_FROM|syscall.IN_MOVE_SELF) != 0 {
+		op |= EventRename
+	}
+	if mask&syscall.IN_ATTRIB != 0 {
+		op |= EventChmod
+	}
+
+	if op == 0 {
+		return
+	}
+
+	ib.watcher.emit(Event{Path: path, Op: op})
+
+	if op.Has(EventCreate) && mask&syscall.IN_ISDIR != 0 && ib.watcher.options.Recursive {
+		if err := ib.addWatch(path); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				ib.watcher.fail(err)
+			}
+
+			return
+		}
+
+		if err := ib.addTree(path, true); err != nil {
+			ib.watcher.fail(err)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// kqueueFflags is the set of vnode events that are watched for.
+const kqueueFflags = syscall.NOTE_DELETE | syscall.NOTE_WRITE | syscall.NOTE_EXTEND | syscall.NOTE_ATTRIB |
+	syscall.NOTE_RENAME
+
+// kqueueWatch is a file or directory that is open for kqueue to watch.
+type kqueueWatch struct {
+	fd   int
+	path string
+	dir  bool
+}
+
+// kqueueBackend finds changes using kqueue. As kqueue only reports that a directory has
+// changed, and not what changed in it, the entries of each watched directory are
+// remembered, and compared with its new entries whenever it changes.
+type kqueueBackend struct {
+	watcher *Watcher
+	kq      int
+	// wake is a pipe that is written to in order to stop run, which is otherwise blocked
+	// waiting for events.
+	wake [2]int
+
+	mu      sync.Mutex
+	closed  bool
+	fds     map[int]*kqueueWatch
+	watches map[string]*kqueueWatch
+	entries map[string]map[string]struct{}
+}
+
+func newNativeBackend(w *Watcher) (watcherBackend, error) {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return nil, os.NewSyscallError("kqueue", err)
+	}
+	syscall.CloseOnExec(kq)
+
+	kb := &kqueueBackend{
+		watcher: w,
+		kq:      kq,
+		fds:     make(map[int]*kqueueWatch),
+		watches: make(map[string]*kqueueWatch),
+		entries: make(map[string]map[string]struct{}),
+	}
+
+	if err := syscall.Pipe(kb.wake[:]); err != nil {
+		_ = syscall.Close(kq)
+		return nil, os.NewSyscallError("pipe", err)
+	}
+	syscall.CloseOnExec(kb.wake[0])
+	syscall.CloseOnExec(kb.wake[1])
+
+	var change syscall.Kevent_t
+	syscall.SetKevent(&change, kb.wake[0], syscall.EVFILT_READ, syscall.EV_ADD)
+	if _, err := syscall.Kevent(kq, []syscall.Kevent_t{change}, nil, nil); err != nil {
+		kb.shutdown()
+		return nil, os.NewSyscallError("kevent", err)
+	}
+
+	w.wg.Add(1)
+	go kb.run()
+
+	return kb, nil
+}
+
+func (kb *kqueueBackend) add(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if err := kb.watch(path, info.IsDir()); err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return kb.addDir(path, false)
+	}
+
+	return nil
+}
+
+// watch opens path and registers it with kqueue, unless it is already watched.
+func (kb *kqueueBackend) watch(path string, dir bool) error {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	if kb.closed {
+		return ErrWatcherClosed
+	}
+	if _, ok := kb.watches[path]; ok {
+		return nil
+	}
+
+	fd, err := syscall.Open(path, kqueueOpenFlags|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	var change syscall.Kevent_t
+	syscall.SetKevent(&change, fd, syscall.EVFILT_VNODE, syscall.EV_ADD|syscall.EV_CLEAR)
+	change.Fflags = kqueueFflags
+	if _, err := syscall.Kevent(kb.kq, []syscall.Kevent_t{change}, nil, nil); err != nil {
+		_ = syscall.Close(fd)
+		return &fs.PathError{Op: "kevent", Path: path, Err: err}
+	}
+
+	watch := &kqueueWatch{fd: fd, path: path, dir: dir}
+	kb.fds[fd] = watch
+	kb.watches[path] = watch
+
+	return nil
+}
+
+// addDir remembers the entries of dir, which must already be watched, and watches each
+// of them, as well as the directories beneath dir if Recursive is set. If emit is set, a
+// create event is also emitted for each entry, which is used for directories that were
+// created after their parent was watched, as their contents may have been created before
+// dir was watched.
+func (kb *kqueueBackend) addDir(dir string, emit bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		names[entry.Name()] = struct{}{}
+	}
+
+	kb.mu.Lock()
+	kb.entries[dir] = names
+	kb.mu.Unlock()
+
+	for _, entry := range entries {
+		if err := kb.addEntry(filepath.Join(dir, entry.Name()), entry, emit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addEntry watches path, which is an entry of a watched directory. Only regular files and
+// directories are opened, as opening anything else, such as a FIFO, could have side
+// effects; changes to other entries are only noticed through their directories.
+func (kb *kqueueBackend) addEntry(path string, entry fs.DirEntry, emit bool) error {
+	if entry.Type().IsRegular() || entry.IsDir() {
+		if err := kb.watch(path, entry.IsDir()); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if emit {
+		kb.watcher.emit(Event{Path: path, Op: EventCreate})
+	}
+
+	if entry.IsDir() && kb.watcher.options.Recursive {
+		if err := kb.addDir(path, emit); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (kb *kqueueBackend) remove(path string) error {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	if _, ok := kb.watches[path]; !ok {
+		return &fs.PathError{Op: "unwatch", Path: path, Err: errors.New("not watched")}
+	}
+
+	kb.unwatch(path)
+
+	return nil
+}
+
+// unwatch stops watching path and everything beneath it. kb.mu must be held.
+func (kb *kqueueBackend) unwatch(path string) {
+	prefix := path + string(filepath.Separator)
+	for watched, watch := range kb.watches {
+		if watched == path || strings.HasPrefix(watched, prefix) {
+			kb.closeWatch(watch)
+		}
+	}
+}
+
+// closeWatch closes the file descriptor of watch, which also removes it from kqueue.
+// kb.mu must be held.
+func (kb *kqueueBackend) closeWatch(watch *kqueueWatch) {
+	_ = syscall.Close(watch.fd)
+
+	delete(kb.fds, watch.fd)
+	if kb.watches[watch.path] == watch {
+		delete(kb.watches, watch.path)
+		delete(kb.entries, watch.path)
+	}
+}
+
+func (kb *kqueueBackend) close() error {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	if kb.closed {
+		return nil
+	}
+
+	_, err := syscall.Write(kb.wake[1], []byte{0})
+
+	return os.NewSyscallError("write", err)
+}
+
+// shutdown closes every file descriptor of the backend.
+func (kb *kqueueBackend) shutdown() {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	kb.closed = true
+	for fd := range kb.fds {
+		_ = syscall.Close(fd)
+	}
+	clear(kb.fds)
+	clear(kb.watches)
+	clear(kb.entries)
+
+	_ = syscall.Close(kb.kq)
+	_ = syscall.Close(kb.wake[0])
+	_ = syscall.Close(kb.wake[1])
+}
+
+// run reads events from kqueue until the watcher is closed.
+func (kb *kqueueBackend) run() {
+	defer kb.watcher.wg.Done()
+	defer kb.shutdown()
+
+	events := make([]syscall.Kevent_t, 64)
+	for {
+		n, err := syscall.Kevent(kb.kq, nil, events, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			kb.watcher.fail(os.NewSyscallError("kevent", err))
+			return
+		}
+
+		for _, event := range events[:n] {
+			if int(event.Ident) == kb.wake[0] {
+				return
+			}
+
+			kb.handle(int(event.Ident), event.Fflags)
+		}
+	}
+}
+
+// handle translates the vnode events of a single watch into Events.
+func (kb *kqueueBackend) handle(fd int, fflags uint32) {
+	kb.mu.Lock()
+	watch, ok := kb.fds[fd]
+	var listed bool
+	if ok {
+		_, listed = kb.entries[watch.path]
+	}
+	kb.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	var op EventOp
+	if fflags&syscall.NOTE_DELETE != 0 {
+		op |= EventRemove
+	}
+	if fflags&syscall.NOTE_RENAME != 0 {
+		op |= EventRename
+	}
+	if fflags&(syscall.NOTE_WRITE|syscall.NOTE_EXTEND) != 0 && !watch.dir {
+		op |= EventWrite
+	}
+	if fflags&syscall.NOTE_ATTRIB != 0 {
+		op |= EventChmod
+	}
+
+	if op != 0 {
+		kb.watcher.emit(Event{Path: watch.path, Op: op})
+	}
+
+	if op&(EventRemove|EventRename) != 0 {
+		// The path no longer refers to what was opened, so the watch is dropped, and the
+		// entry is forgotten by its directory. Something else may have been moved to the
+		// path, such as when a file is replaced by renaming another over it, which the
+		// directory would otherwise not list as new.
+		parent := filepath.Dir(watch.path)
+
+		kb.mu.Lock()
+		if kb.watches[watch.path] == watch {
+			kb.unwatch(watch.path)
+		} else if kb.fds[watch.fd] == watch {
+			// A newer watch of the path has taken over, which is left alone.
+			kb.closeWatch(watch)
+		}
+		names, ok := kb.entries[parent]
+		if ok {
+			delete(names, filepath.Base(watch.path))
+		}
+		kb.mu.Unlock()
+
+		if ok {
+			kb.rescan(parent)
+		}
+
+		return
+	}
+
+	if listed && fflags&syscall.NOTE_WRITE != 0 {
+		kb.rescan(watch.path)
+	}
+}
+
+// rescan compares the entries of the watched directory dir with those that it had
+// before, and emits events for the entries that were created or removed.
+func (kb *kqueueBackend) rescan(dir string) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		// The directory was removed, which its own watch reports.
+		return
+	} else if err != nil {
+		kb.watcher.fail(err)
+		return
+	}
+
+	var (
+		names   = make(map[string]struct{}, len(entries))
+		created []fs.DirEntry
+		removed []string
+	)
+
+	kb.mu.Lock()
+	previous, ok := kb.entries[dir]
+	if !ok {
+		kb.mu.Unlock()
+		return
+	}
+
+	for _, entry := range entries {
+		names[entry.Name()] = struct{}{}
+		if _, ok := previous[entry.Name()]; !ok {
+			created = append(created, entry)
+		}
+	}
+	for name := range previous {
+		// Entries that are watched report their own removal.
+		path := filepath.Join(dir, name)
+		if _, ok := names[name]; !ok && kb.watches[path] == nil {
+			removed = append(removed, path)
+		}
+	}
+	kb.entries[dir] = names
+	kb.mu.Unlock()
+
+	for _, path := range removed {
+		kb.watcher.emit(Event{Path: path, Op: EventRemove})
+	}
+
+	for _, entry := range created {
+		if err := kb.addEntry(filepath.Join(dir, entry.Name()), entry, true); err != nil {
+			kb.watcher.fail(err)
+		}
+	}
+}
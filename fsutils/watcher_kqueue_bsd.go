@@ -0,0 +1,8 @@
+//go:build dragonfly || freebsd || netbsd || openbsd
+
+package fsutils
+
+import "syscall"
+
+// kqueueOpenFlags opens watched paths for reading, which kqueue needs to watch them.
+const kqueueOpenFlags = syscall.O_RDONLY
@@ -0,0 +1,7 @@
+package fsutils
+
+import "syscall"
+
+// kqueueOpenFlags opens watched paths only to be notified of events, which does not keep
+// the volume that they are on from being unmounted.
+const kqueueOpenFlags = syscall.O_EVTONLY
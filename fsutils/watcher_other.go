@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package fsutils
+
+import "errors"
+
+// newNativeBackend reports that there is no native backend on this platform, so polling
+// is used instead.
+func newNativeBackend(_ *Watcher) (watcherBackend, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,208 @@
+package fsutils
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// pollState is what the polling backend remembers about a path.
+type pollState struct {
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+}
+
+// pollBackend finds changes by comparing snapshots of the watched paths.
+type pollBackend struct {
+	watcher *Watcher
+
+	mu    sync.Mutex
+	roots map[string]map[string]pollState
+}
+
+func newPollBackend(w *Watcher) *pollBackend {
+	pb := &pollBackend{
+		watcher: w,
+		roots:   make(map[string]map[string]pollState),
+	}
+
+	w.wg.Add(1)
+	go pb.run()
+
+	return pb
+}
+
+func (pb *pollBackend) add(path string) error {
+	if _, err := os.Lstat(path); err != nil {
+		return err
+	}
+
+	snapshot, err := pb.snapshot(path)
+	if err != nil {
+		return err
+	}
+
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	if _, ok := pb.roots[path]; !ok {
+		pb.roots[path] = snapshot
+	}
+
+	return nil
+}
+
+func (pb *pollBackend) remove(path string) error {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	if _, ok := pb.roots[path]; !ok {
+		return &fs.PathError{Op: "unwatch", Path: path, Err: errors.New("not watched")}
+	}
+
+	delete(pb.roots, path)
+
+	return nil
+}
+
+func (pb *pollBackend) close() error {
+	return nil
+}
+
+// run polls the watched paths until the watcher is closed.
+func (pb *pollBackend) run() {
+	defer pb.watcher.wg.Done()
+
+	ticker := time.NewTicker(pb.watcher.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-pb.watcher.done:
+			return
+		}
+
+		pb.mu.Lock()
+		roots := make([]string, 0, len(pb.roots))
+		for root := range pb.roots {
+			roots = append(roots, root)
+		}
+		pb.mu.Unlock()
+
+		slices.Sort(roots)
+		for _, root := range roots {
+			pb.poll(root)
+		}
+	}
+}
+
+// poll takes a new snapshot of root and reports how it differs from the last.
+func (pb *pollBackend) poll(root string) {
+	snapshot, err := pb.snapshot(root)
+	if err != nil {
+		pb.watcher.fail(err)
+		return
+	}
+
+	pb.mu.Lock()
+	previous, ok := pb.roots[root]
+	if ok {
+		pb.roots[root] = snapshot
+	}
+	pb.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	var events []Event
+	for path, state := range snapshot {
+		old, existed := previous[path]
+
+		var op EventOp
+		switch {
+		case !existed:
+			op = EventCreate
+		case old.mode.Type() != state.mode.Type():
+			op = EventRemove | EventCreate
+		default:
+			if !state.mode.IsDir() && (old.size != state.size || !old.modTime.Equal(state.modTime)) {
+				op |= EventWrite
+			}
+			if old.mode != state.mode {
+				op |= EventChmod
+			}
+		}
+
+		if op != 0 {
+			events = append(events, Event{Path: path, Op: op})
+		}
+	}
+
+	for path := range previous {
+		if _, ok := snapshot[path]; !ok {
+			events = append(events, Event{Path: path, Op: EventRemove})
+		}
+	}
+
+	slices.SortFunc(events, func(a, b Event) int { return cmp.Compare(a.Path, b.Path) })
+	for _, event := range events {
+		pb.watcher.emit(event)
+	}
+}
+
+// snapshot returns the state of root and, if it is a directory, of its entries, and of
+// everything beneath them if the watcher is recursive. A root that does not exist has an
+// empty snapshot.
+func (pb *pollBackend) snapshot(root string) (map[string]pollState, error) {
+	snapshot := make(map[string]pollState)
+
+	info, err := os.Lstat(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return snapshot, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot[root] = pollState{info.Mode(), info.Size(), info.ModTime()}
+	if !info.IsDir() {
+		return snapshot, nil
+	}
+
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			// The entry was removed during the walk.
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if path == root {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		snapshot[path] = pollState{info.Mode(), info.Size(), info.ModTime()}
+		if entry.IsDir() && !pb.watcher.options.Recursive {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return snapshot, err
+}
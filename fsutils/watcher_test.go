@@ -0,0 +1,118 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// expectEvent waits for an event for path that includes op, skipping any others.
+func expectEvent(t *testing.T, w *Watcher, path string, op EventOp) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-w.Events():
+			if event.Path == path && event.Op.Has(op) {
+				return
+			}
+		case err := <-w.Errors():
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v %s", op, path)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		t.Run(map[bool]string{false: "native", true: "poll"}[poll], func(t *testing.T) {
+			dir := t.TempDir()
+
+			w, err := NewWatcher(WatcherOptions{
+				Recursive:    true,
+				Debounce:     20 * time.Millisecond,
+				Poll:         poll,
+				PollInterval: 20 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+
+			if err := w.Add(dir); err != nil {
+				t.Fatal(err)
+			}
+
+			sub := filepath.Join(dir, "sub")
+			if err := os.Mkdir(sub, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			expectEvent(t, w, sub, EventCreate)
+
+			file := filepath.Join(sub, "file")
+			if err := os.WriteFile(file, []byte("contents"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			expectEvent(t, w, file, EventCreate)
+
+			if err := os.WriteFile(file, []byte("changed contents"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			expectEvent(t, w, file, EventWrite)
+
+			if err := os.Remove(file); err != nil {
+				t.Fatal(err)
+			}
+			expectEvent(t, w, file, EventRemove)
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := <-w.Events(); ok {
+				t.Error("expected the events channel to be closed")
+			}
+			if err := w.Add(dir); err != ErrWatcherClosed {
+				t.Errorf("expected ErrWatcherClosed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWatcherDebounce(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWatcher(WatcherOptions{Debounce: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "file")
+	for range 5 {
+		if err := os.WriteFile(file, []byte("contents"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case event := <-w.Events():
+		if event.Path != file || !event.Op.Has(EventCreate|EventWrite) {
+			t.Errorf("expected the writes to be coalesced with the create, got %v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+
+	select {
+	case event := <-w.Events():
+		t.Errorf("expected a single event, got another: %v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,336 @@
+package fsutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// windowsNotifyFilter is the set of changes that ReadDirectoryChangesW is asked to report.
+const windowsNotifyFilter = syscall.FILE_NOTIFY_CHANGE_FILE_NAME | syscall.FILE_NOTIFY_CHANGE_DIR_NAME |
+	syscall.FILE_NOTIFY_CHANGE_ATTRIBUTES | syscall.FILE_NOTIFY_CHANGE_SIZE |
+	syscall.FILE_NOTIFY_CHANGE_LAST_WRITE | syscall.FILE_NOTIFY_CHANGE_CREATION
+
+// windowsWatch is a directory that is open for ReadDirectoryChangesW to watch.
+type windowsWatch struct {
+	key    uint32
+	handle syscall.Handle
+	closed bool
+
+	// path is the path that was given to Add. Only directories can be watched, so when
+	// path is a file, its directory is watched instead, and only changes to name are
+	// reported.
+	path string
+	name string
+
+	overlapped syscall.Overlapped
+	buf        []byte
+}
+
+// windowsBackend finds changes using ReadDirectoryChangesW, with the reads of every watch
+// completing on a single I/O completion port. Recursive watches ask for changes to the
+// whole tree beneath the directory, so directories that are created later are covered
+// without being watched themselves.
+type windowsBackend struct {
+	watcher *Watcher
+	port    syscall.Handle
+
+	mu      sync.Mutex
+	closed  bool
+	nextKey uint32
+	keys    map[uint32]*windowsWatch
+	paths   map[string]*windowsWatch
+}
+
+func newNativeBackend(w *Watcher) (watcherBackend, error) {
+	port, err := syscall.CreateIoCompletionPort(syscall.InvalidHandle, 0, 0, 1)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateIoCompletionPort", err)
+	}
+
+	wb := &windowsBackend{
+		watcher: w,
+		port:    port,
+		keys:    make(map[uint32]*windowsWatch),
+		paths:   make(map[string]*windowsWatch),
+	}
+
+	w.wg.Add(1)
+	go wb.run()
+
+	return wb, nil
+}
+
+func (wb *windowsBackend) add(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	dir, name := path, ""
+	if !info.IsDir() {
+		dir, name = filepath.Dir(path), filepath.Base(path)
+	}
+
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	if wb.closed {
+		return ErrWatcherClosed
+	}
+	if _, ok := wb.paths[path]; ok {
+		return nil
+	}
+
+	pathp, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: dir, Err: err}
+	}
+
+	handle, err := syscall.CreateFile(pathp, syscall.FILE_LIST_DIRECTORY,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil, syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return &fs.PathError{Op: "CreateFile", Path: dir, Err: err}
+	}
+
+	wb.nextKey++
+	watch := &windowsWatch{
+		key:    wb.nextKey,
+		handle: handle,
+		path:   path,
+		name:   name,
+		buf:    make([]byte, 64*1024),
+	}
+
+	if _, err := syscall.CreateIoCompletionPort(handle, wb.port, watch.key, 0); err != nil {
+		_ = syscall.CloseHandle(handle)
+		return &fs.PathError{Op: "CreateIoCompletionPort", Path: dir, Err: err}
+	}
+	if err := wb.read(watch); err != nil {
+		_ = syscall.CloseHandle(handle)
+		return &fs.PathError{Op: "ReadDirectoryChanges", Path: dir, Err: err}
+	}
+
+	wb.keys[watch.key] = watch
+	wb.paths[path] = watch
+
+	return nil
+}
+
+// read starts waiting for the next changes to the directory of watch. wb.mu must be held.
+func (wb *windowsBackend) read(watch *windowsWatch) error {
+	watch.overlapped = syscall.Overlapped{}
+	subtree := watch.name == "" && wb.watcher.options.Recursive
+
+	return syscall.ReadDirectoryChanges(watch.handle, &watch.buf[0], uint32(len(watch.buf)), subtree,
+		windowsNotifyFilter, nil, &watch.overlapped, 0)
+}
+
+func (wb *windowsBackend) remove(path string) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	watch, ok := wb.paths[path]
+	if !ok {
+		return &fs.PathError{Op: "unwatch", Path: path, Err: errors.New("not watched")}
+	}
+
+	delete(wb.paths, path)
+
+	return wb.closeWatch(watch)
+}
+
+// closeWatch closes the handle of watch, which cancels its pending read. The watch is
+// only forgotten once the cancelled read has completed, as the system may write to its
+// buffer until then. wb.mu must be held.
+func (wb *windowsBackend) closeWatch(watch *windowsWatch) error {
+	if watch.closed {
+		return nil
+	}
+	watch.closed = true
+
+	return os.NewSyscallError("CloseHandle", syscall.CloseHandle(watch.handle))
+}
+
+// forget closes watch, if it is not already closed, and forgets about it. It must only be
+// called when watch has no pending read. wb.mu must be held.
+func (wb *windowsBackend) forget(watch *windowsWatch) {
+	_ = wb.closeWatch(watch)
+
+	delete(wb.keys, watch.key)
+	if wb.paths[watch.path] == watch {
+		delete(wb.paths, watch.path)
+	}
+}
+
+func (wb *windowsBackend) close() error {
+	return os.NewSyscallError("PostQueuedCompletionStatus", syscall.PostQueuedCompletionStatus(wb.port, 0, 0, nil))
+}
+
+// shutdown closes every watch, and then the completion port once their cancelled reads
+// have completed.
+func (wb *windowsBackend) shutdown() {
+	wb.mu.Lock()
+	wb.closed = true
+	for _, watch := range wb.keys {
+		_ = wb.closeWatch(watch)
+	}
+	pending := len(wb.keys)
+	clear(wb.keys)
+	clear(wb.paths)
+	wb.mu.Unlock()
+
+	for ; pending > 0; pending-- {
+		var (
+			n, key     uint32
+			overlapped *syscall.Overlapped
+		)
+
+		// Give up if nothing completes within a second, rather than hang the close.
+		_ = syscall.GetQueuedCompletionStatus(wb.port, &n, &key, &overlapped, 1000)
+		if overlapped == nil {
+			break
+		}
+	}
+
+	_ = syscall.CloseHandle(wb.port)
+}
+
+// run waits for the reads of the watches to complete until the watcher is closed.
+func (wb *windowsBackend) run() {
+	defer wb.watcher.wg.Done()
+	defer wb.shutdown()
+
+	for {
+		var (
+			n, key     uint32
+			overlapped *syscall.Overlapped
+		)
+
+		err := syscall.GetQueuedCompletionStatus(wb.port, &n, &key, &overlapped, syscall.INFINITE)
+		if overlapped == nil {
+			// Either close woke this up, or the completion port itself failed.
+			if err != nil {
+				wb.watcher.fail(os.NewSyscallError("GetQueuedCompletionStatus", err))
+			}
+
+			return
+		}
+
+		wb.complete(key, n, err)
+	}
+}
+
+// complete handles the completed read of the watch with the given key, which filled n
+// bytes of its buffer or failed with err, and starts its next read.
+func (wb *windowsBackend) complete(key, n uint32, err error) {
+	wb.mu.Lock()
+	watch, ok := wb.keys[key]
+	if ok && watch.closed {
+		// The read was cancelled when the watch was removed.
+		wb.forget(watch)
+	}
+	wb.mu.Unlock()
+
+	if !ok || watch.closed {
+		return
+	}
+
+	var events []Event
+	switch {
+	case err != nil:
+		wb.mu.Lock()
+		wb.forget(watch)
+		wb.mu.Unlock()
+
+		if _, statErr := os.Lstat(watch.path); errors.Is(statErr, fs.ErrNotExist) {
+			// The watched directory itself was removed.
+			wb.watcher.emit(Event{Path: watch.path, Op: EventRemove})
+		} else {
+			wb.watcher.fail(&fs.PathError{Op: "ReadDirectoryChanges", Path: watch.path, Err: err})
+		}
+
+		return
+	case n == 0:
+		// The changes did not fit into the buffer, so they were dropped.
+		wb.watcher.fail(ErrEventOverflow)
+	default:
+		events = wb.parse(watch, watch.buf[:n])
+	}
+
+	// The buffer is reused by the next read, so it must have been parsed by now.
+	wb.mu.Lock()
+	if watch.closed {
+		wb.forget(watch)
+	} else if err := wb.read(watch); err != nil {
+		wb.forget(watch)
+		wb.mu.Unlock()
+
+		wb.watcher.fail(&fs.PathError{Op: "ReadDirectoryChanges", Path: watch.path, Err: err})
+
+		return
+	}
+	wb.mu.Unlock()
+
+	for _, event := range events {
+		wb.watcher.emit(event)
+	}
+}
+
+// parse translates the FILE_NOTIFY_INFORMATION records in buf into Events.
+func (wb *windowsBackend) parse(watch *windowsWatch, buf []byte) []Event {
+	var events []Event
+
+	headerSize := int(unsafe.Offsetof(syscall.FileNotifyInformation{}.FileName))
+	for offset := 0; offset+headerSize <= len(buf); {
+		raw := (*syscall.FileNotifyInformation)(unsafe.Pointer(&buf[offset]))
+		name := syscall.UTF16ToString(unsafe.Slice(&raw.FileName, raw.FileNameLength/2))
+
+		if event, ok := windowsEvent(watch, raw.Action, name); ok {
+			events = append(events, event)
+		}
+
+		if raw.NextEntryOffset == 0 {
+			break
+		}
+		offset += int(raw.NextEntryOffset)
+	}
+
+	return events
+}
+
+// windowsEvent translates a single change to name, which is relative to the watched
+// directory, into an Event. It returns false if the change should not be reported.
+func windowsEvent(watch *windowsWatch, action uint32, name string) (Event, bool) {
+	path := filepath.Join(watch.path, name)
+	if watch.name != "" {
+		// Paths on Windows are case-insensitive.
+		if !strings.EqualFold(name, watch.name) {
+			return Event{}, false
+		}
+
+		path = watch.path
+	}
+
+	var op EventOp
+	switch action {
+	case syscall.FILE_ACTION_ADDED, syscall.FILE_ACTION_RENAMED_NEW_NAME:
+		op = EventCreate
+	case syscall.FILE_ACTION_REMOVED:
+		op = EventRemove
+	case syscall.FILE_ACTION_MODIFIED:
+		op = EventWrite
+	case syscall.FILE_ACTION_RENAMED_OLD_NAME:
+		op = EventRename
+	default:
+		return Event{}, false
+	}
+
+	return Event{Path: path, Op: op}, true
+}
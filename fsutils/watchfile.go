@@ -0,0 +1,117 @@
+package fsutils
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WatchFileOptions is a struct used by WatchFile to define certain optional parameters.
+type WatchFileOptions struct {
+	// Watcher configures the Watcher used to watch the file. Recursive is ignored.
+	Watcher WatcherOptions
+}
+
+func defaultWatchFileOptions() WatchFileOptions {
+	return WatchFileOptions{
+		Watcher: defaultWatcherOptions(),
+	}
+}
+
+// WatchFile calls onChange with the contents of the file at path when it starts, if the
+// file exists, and then whenever the contents change, until ctx is done, in which case
+// ctx.Err() is returned. This is intended for hot-reloading configuration and state
+// files.
+//
+// The directory containing path is watched rather than the file itself, so that saves by
+// editors that write a new file and rename it over the old one, or that remove and
+// recreate it, are still noticed. onChange is only called when the contents differ from
+// the last contents it was given, which skips events that do not change anything, such
+// as touching the file. The file being removed is not reported. If onChange returns an
+// error, watching stops and the error is returned.
+//
+// This takes a variadic parameter of type WatchFileOptions. If no WatchFileOptions are
+// supplied, then the defaults are used. If more than one WatchFileOptions are supplied
+// then only the first will be used.
+func WatchFile(ctx context.Context, path string, onChange func(data []byte) error, opts ...WatchFileOptions) error {
+	options := defaultWatchFileOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	watcherOptions := options.Watcher
+	watcherOptions.Recursive = false
+
+	w, err := NewWatcher(watcherOptions)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	path = filepath.Clean(path)
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	var (
+		last    [sha256.Size]byte
+		hasLast bool
+	)
+
+	check := func() error {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		if hasLast && sum == last {
+			return nil
+		}
+
+		last, hasLast = sum, true
+
+		return onChange(data)
+	}
+
+	if err := check(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case event, ok := <-w.Events():
+			if !ok {
+				return ErrWatcherClosed
+			}
+
+			if event.Path == path {
+				if err := check(); err != nil {
+					return err
+				}
+			}
+		case err, ok := <-w.Errors():
+			if !ok {
+				return ErrWatcherClosed
+			}
+
+			// Events may have been missed, so the file is checked regardless.
+			if errors.Is(err, ErrEventOverflow) {
+				if err := check(); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
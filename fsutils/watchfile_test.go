@@ -0,0 +1,66 @@
+package fsutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	if err := os.WriteFile(path, []byte("one"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan string, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchFile(ctx, path, func(data []byte) error {
+			changes <- string(data)
+			return nil
+		}, WatchFileOptions{Watcher: WatcherOptions{Debounce: 20 * time.Millisecond, PollInterval: 20 * time.Millisecond}})
+	}()
+
+	expect := func(want string) {
+		t.Helper()
+
+		select {
+		case got := <-changes:
+			if got != want {
+				t.Fatalf("expected %q, got %q", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+
+	expect("one")
+
+	// Editors often save by writing a temporary file and renaming it over the original.
+	if err := AtomicWriteFile(path, []byte("two"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expect("two")
+
+	// Rewriting the same contents is not a change.
+	if err := os.WriteFile(path, []byte("two"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("three"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expect("three")
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package fsutils
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// ZipFS writes the contents of filesystem, such as an embed.FS, as a zip archive to w,
+// without writing anything to disk first. Entries are named by their paths within
+// filesystem, with their permissions and modification times, and files are compressed
+// with deflate. Directories are included so that empty ones are kept. Files that are not
+// regular files or directories are reported as an error.
+func ZipFS(filesystem fs.FS, w io.Writer) error {
+	writer := zip.NewWriter(w)
+
+	err := fs.WalkDir(filesystem, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return &fs.PathError{Op: "zip", Path: name, Err: errors.New("not a regular file")}
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+
+		contents, err := writer.CreateHeader(header)
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		file, err := filesystem.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(contents, file)
+
+		return err
+	})
+
+	return errors.Join(err, writer.Close())
+}
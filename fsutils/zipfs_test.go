@@ -0,0 +1,43 @@
+package fsutils
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+func TestZipFS(t *testing.T) {
+	filesystem := fstest.MapFS{
+		"index.html":    {Data: []byte("<html>")},
+		"static/app.js": {Data: []byte("app")},
+	}
+
+	var buf bytes.Buffer
+	if err := ZipFS(filesystem, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := reader.Open("static/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "app" {
+		t.Errorf("read %q, want %q", data, "app")
+	}
+	if len(reader.File) != 3 {
+		t.Errorf("expected two files and a directory, got %d entries", len(reader.File))
+	}
+}
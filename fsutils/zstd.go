@@ -0,0 +1,626 @@
+package fsutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// This file, zstd_reader.go, and zstd_writer.go implement the Zstandard format, as
+// specified by RFC 8878, for the Zstd Codec.
+
+const (
+	zstdMagic          = 0xFD2FB528
+	zstdSkippableMagic = 0x184D2A50
+	zstdSkippableMask  = 0xFFFFFFF0
+
+	// zstdMaxBlockSize is the most that a single block may decompress to.
+	zstdMaxBlockSize = 128 << 10
+
+	// zstdMaxWindowSize is the largest window that is decompressed, which is also the
+	// default limit of the zstd tool. Larger windows need more memory than is reasonable
+	// to use without being asked to.
+	zstdMaxWindowSize = 1 << 27
+
+	zstdBlockRaw        = 0
+	zstdBlockRLE        = 1
+	zstdBlockCompressed = 2
+
+	zstdLiteralsRaw        = 0
+	zstdLiteralsRLE        = 1
+	zstdLiteralsCompressed = 2
+	zstdLiteralsTreeless   = 3
+
+	zstdModePredefined = 0
+	zstdModeRLE        = 1
+	zstdModeCompressed = 2
+	zstdModeRepeat     = 3
+
+	// zstdMaxHuffmanBits is the longest Huffman code of a literal.
+	zstdMaxHuffmanBits = 11
+)
+
+// errZstdCorrupt is returned when decompressing data that is not valid Zstandard.
+var errZstdCorrupt = errors.New("zstd: corrupt input")
+
+// The predefined distributions of the literal length, match length, and offset codes,
+// along with the accuracy log of each.
+var (
+	zstdLiteralLengthDistribution = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	zstdMatchLengthDistribution = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1, -1, -1,
+	}
+	zstdOffsetDistribution = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+)
+
+const (
+	zstdLiteralLengthLog = 6
+	zstdMatchLengthLog   = 6
+	zstdOffsetLog        = 5
+)
+
+// zstdCode is the value that a literal or match length code stands for, to which the
+// given number of bits, read after the code, are added.
+type zstdCode struct {
+	base uint32
+	bits uint8
+}
+
+var zstdLiteralLengthCodes = [...]zstdCode{
+	{0, 0},
+	{1, 0},
+	{2, 0},
+	{3, 0},
+	{4, 0},
+	{5, 0},
+	{6, 0},
+	{7, 0},
+	{8, 0},
+	{9, 0},
+	{10, 0},
+	{11, 0},
+	{12, 0},
+	{13, 0},
+	{14, 0},
+	{15, 0},
+	{16, 1},
+	{18, 1},
+	{20, 1},
+	{22, 1},
+	{24, 2},
+	{28, 2},
+	{32, 3},
+	{40, 3},
+	{48, 4},
+	{64, 6},
+	{128, 7},
+	{256, 8},
+	{512, 9},
+	{1024, 10},
+	{2048, 11},
+	{4096, 12},
+	{8192, 13},
+	{16384, 14},
+	{32768, 15},
+	{65536, 16},
+}
+
+var zstdMatchLengthCodes = [...]zstdCode{
+	{3, 0},
+	{4, 0},
+	{5, 0},
+	{6, 0},
+	{7, 0},
+	{8, 0},
+	{9, 0},
+	{10, 0},
+	{11, 0},
+	{12, 0},
+	{13, 0},
+	{14, 0},
+	{15, 0},
+	{16, 0},
+	{17, 0},
+	{18, 0},
+	{19, 0},
+	{20, 0},
+	{21, 0},
+	{22, 0},
+	{23, 0},
+	{24, 0},
+	{25, 0},
+	{26, 0},
+	{27, 0},
+	{28, 0},
+	{29, 0},
+	{30, 0},
+	{31, 0},
+	{32, 0},
+	{33, 0},
+	{34, 0},
+	{35, 1},
+	{37, 1},
+	{39, 1},
+	{41, 1},
+	{43, 2},
+	{47, 2},
+	{51, 3},
+	{59, 3},
+	{67, 4},
+	{83, 4},
+	{99, 5},
+	{131, 7},
+	{259, 8},
+	{515, 9},
+	{1027, 10},
+	{2051, 11},
+	{4099, 12},
+	{8195, 13},
+	{16387, 14},
+	{32771, 15},
+	{65539, 16},
+}
+
+// zstdSpread returns the symbol of each state of an FSE table with the given normalised
+// distribution, as laid out by the format.
+func zstdSpread(distribution []int16, log uint8) []uint8 {
+	size := 1 << log
+	symbols := make([]uint8, size)
+
+	// Symbols with a probability of less than one take a single state each at the end.
+	high := size - 1
+	for symbol, count := range distribution {
+		if count == -1 {
+			symbols[high] = uint8(symbol)
+			high--
+		}
+	}
+
+	position, step, mask := 0, size>>1+size>>3+3, size-1
+	for symbol, count := range distribution {
+		for range max(count, 0) {
+			symbols[position] = uint8(symbol)
+
+			position = (position + step) & mask
+			for position > high {
+				position = (position + step) & mask
+			}
+		}
+	}
+
+	return symbols
+}
+
+// zstdDecodingState is a state of an FSE decoding table. The next state is baseline plus
+// the value of the next bits bits.
+type zstdDecodingState struct {
+	symbol   uint8
+	bits     uint8
+	baseline uint16
+}
+
+// zstdDecodingTable builds the FSE table that decodes the given normalised distribution.
+// The distribution must already be valid; see zstdReadDistribution.
+func zstdDecodingTable(distribution []int16, log uint8) []zstdDecodingState {
+	size := 1 << log
+	table := make([]zstdDecodingState, size)
+
+	next := make([]uint16, len(distribution))
+	for symbol, count := range distribution {
+		next[symbol] = uint16(max(count, 1))
+	}
+
+	for state, symbol := range zstdSpread(distribution, log) {
+		n := next[symbol]
+		next[symbol]++
+
+		nbits := log - uint8(bits.Len16(n)-1)
+		table[state] = zstdDecodingState{
+			symbol:   symbol,
+			bits:     nbits,
+			baseline: uint16(int(n)<<nbits - size),
+		}
+	}
+
+	return table
+}
+
+// zstdRLETable returns the FSE decoding table that always decodes symbol.
+func zstdRLETable(symbol uint8) []zstdDecodingState {
+	return []zstdDecodingState{{symbol: symbol}}
+}
+
+// zstdEncodingTable is an FSE table used to encode symbols, in the layout of the
+// reference implementation: states are kept between size and twice size.
+type zstdEncodingTable struct {
+	log     uint8
+	states  []uint16
+	symbols []zstdEncodingSymbol
+}
+
+type zstdEncodingSymbol struct {
+	deltaBits  uint32
+	deltaState int32
+}
+
+// newZstdEncodingTable builds the FSE table that encodes the given normalised
+// distribution.
+func newZstdEncodingTable(distribution []int16, log uint8) *zstdEncodingTable {
+	size := 1 << log
+	table := &zstdEncodingTable{
+		log:     log,
+		states:  make([]uint16, size),
+		symbols: make([]zstdEncodingSymbol, len(distribution)),
+	}
+
+	cumulative := make([]int, len(distribution)+1)
+	for symbol, count := range distribution {
+		cumulative[symbol+1] = cumulative[symbol] + int(count)
+		if count == -1 {
+			cumulative[symbol+1] += 2
+		}
+	}
+
+	for state, symbol := range zstdSpread(distribution, log) {
+		table.states[cumulative[symbol]] = uint16(size + state)
+		cumulative[symbol]++
+	}
+
+	total := int32(0)
+	for symbol, count := range distribution {
+		switch count {
+		case 0:
+		case -1, 1:
+			table.symbols[symbol] = zstdEncodingSymbol{
+				deltaBits:  uint32(log)<<16 - uint32(size),
+				deltaState: total - 1,
+			}
+			total++
+		default:
+			maxBits := uint32(log) - uint32(bits.Len16(uint16(count-1))-1)
+			table.symbols[symbol] = zstdEncodingSymbol{
+				deltaBits:  maxBits<<16 - uint32(count)<<maxBits,
+				deltaState: total - int32(count),
+			}
+			total += int32(count)
+		}
+	}
+
+	return table
+}
+
+// init returns the first state of encoding symbol, which is the last symbol that the
+// decoder reads.
+func (zet *zstdEncodingTable) init(symbol uint8) uint32 {
+	s := zet.symbols[symbol]
+	nbits := (s.deltaBits + 1<<15) >> 16
+	value := nbits<<16 - s.deltaBits
+
+	return uint32(zet.states[int32(value>>nbits)+s.deltaState])
+}
+
+// encode writes the bits that lead the decoder from the state that decodes symbol to
+// state, and returns the state that decodes symbol.
+func (zet *zstdEncodingTable) encode(bw *zstdBitWriter, state uint32, symbol uint8) uint32 {
+	s := zet.symbols[symbol]
+	nbits := (state + s.deltaBits) >> 16
+	bw.write(uint64(state), uint(nbits))
+
+	return uint32(zet.states[int32(state>>nbits)+s.deltaState])
+}
+
+// flush writes state as the initial state of the decoder.
+func (zet *zstdEncodingTable) flush(bw *zstdBitWriter, state uint32) {
+	bw.write(uint64(state), uint(zet.log))
+}
+
+// zstdBitWriter writes a bitstream that is read backwards by zstdBackwardReader.
+type zstdBitWriter struct {
+	buf   []byte
+	bits  uint64
+	count uint
+}
+
+// write writes the low n bits of value, where n is at most 32.
+func (bw *zstdBitWriter) write(value uint64, n uint) {
+	bw.bits |= (value & (1<<n - 1)) << bw.count
+	bw.count += n
+
+	for bw.count >= 8 {
+		bw.buf = append(bw.buf, byte(bw.bits))
+		bw.bits >>= 8
+		bw.count -= 8
+	}
+}
+
+// close marks the end of the bitstream, which the reader starts from, and returns it.
+func (bw *zstdBitWriter) close() []byte {
+	bw.write(1, 1)
+	if bw.count > 0 {
+		bw.buf = append(bw.buf, byte(bw.bits))
+		bw.bits, bw.count = 0, 0
+	}
+
+	return bw.buf
+}
+
+// zstdBackwardReader reads a bitstream backwards, from its last byte, as sequences and
+// Huffman-coded literals are.
+type zstdBackwardReader struct {
+	data  []byte
+	bits  uint64
+	count uint
+	// overread is how many bits were read from before the start of the stream, which
+	// are read as zeros.
+	overread uint
+}
+
+func newZstdBackwardReader(data []byte) (*zstdBackwardReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		// The last byte must hold the marker of where the stream starts.
+		return nil, errZstdCorrupt
+	}
+
+	last := data[len(data)-1]
+	count := uint(bits.Len8(last) - 1)
+
+	return &zstdBackwardReader{
+		data:  data[:len(data)-1],
+		bits:  uint64(last) & (1<<count - 1),
+		count: count,
+	}, nil
+}
+
+func (br *zstdBackwardReader) fill() {
+	for br.count <= 56 && len(br.data) > 0 {
+		br.bits = br.bits<<8 | uint64(br.data[len(br.data)-1])
+		br.data = br.data[:len(br.data)-1]
+		br.count += 8
+	}
+}
+
+// peek returns the next n bits, where n is at most 32, without reading them.
+func (br *zstdBackwardReader) peek(n uint) uint64 {
+	if br.count < n {
+		br.fill()
+	}
+	if br.count < n {
+		return (br.bits << (n - br.count)) & (1<<n - 1)
+	}
+
+	return (br.bits >> (br.count - n)) & (1<<n - 1)
+}
+
+// skip reads n bits that have been peeked.
+func (br *zstdBackwardReader) skip(n uint) {
+	if br.count < n {
+		br.overread += n - br.count
+		br.bits, br.count = 0, 0
+
+		return
+	}
+
+	br.count -= n
+	br.bits &= 1<<br.count - 1
+}
+
+// read reads the next n bits, where n is at most 32.
+func (br *zstdBackwardReader) read(n uint) uint64 {
+	value := br.peek(n)
+	br.skip(n)
+
+	return value
+}
+
+// done reports whether the whole stream has been read, and no more.
+func (br *zstdBackwardReader) done() bool {
+	return br.count == 0 && len(br.data) == 0 && br.overread == 0
+}
+
+// zstdForwardReader reads a bitstream from its first byte, as FSE table descriptions are.
+type zstdForwardReader struct {
+	data     []byte
+	position uint
+}
+
+// read reads the next n bits, where n is at most 32, reading zeros past the end of the
+// data.
+func (fr *zstdForwardReader) read(n uint) uint32 {
+	value := fr.peek(n)
+	fr.position += n
+
+	return value
+}
+
+func (fr *zstdForwardReader) peek(n uint) uint32 {
+	var value uint64
+	for i := uint(0); i < (n+fr.position%8+7)/8; i++ {
+		byteIndex := fr.position/8 + i
+		if byteIndex < uint(len(fr.data)) {
+			value |= uint64(fr.data[byteIndex]) << (8 * i)
+		}
+	}
+
+	return uint32(value>>(fr.position%8)) & (1<<n - 1)
+}
+
+// zstdReadDistribution reads the description of an FSE table from data, allowing at
+// most symbols symbols and an accuracy log of at most maxLog, and returns the normalised
+// distribution, its accuracy log, and the number of bytes of data that were read.
+func zstdReadDistribution(data []byte, symbols int, maxLog uint8) ([]int16, uint8, int, error) {
+	if len(data) == 0 {
+		return nil, 0, 0, errZstdCorrupt
+	}
+
+	fr := &zstdForwardReader{data: data}
+	log := uint8(fr.read(4)) + 5
+	if log > maxLog {
+		return nil, 0, 0, errZstdCorrupt
+	}
+
+	var (
+		distribution = make([]int16, 0, symbols)
+		remaining    = 1<<log + 1
+		threshold    = 1 << log
+		nbits        = uint(log) + 1
+	)
+	for remaining > 1 {
+		if len(distribution) >= symbols {
+			return nil, 0, 0, errZstdCorrupt
+		}
+
+		limit := uint32(2*threshold - 1 - remaining)
+		var value uint32
+		if low := fr.peek(nbits-1) & uint32(threshold-1); low < limit {
+			value = low
+			fr.position += nbits - 1
+		} else {
+			value = fr.read(nbits)
+			if value >= uint32(threshold) {
+				value -= limit
+			}
+		}
+
+		count := int16(value) - 1
+		if count == -1 {
+			remaining--
+		} else {
+			remaining -= int(count)
+		}
+		distribution = append(distribution, count)
+
+		if count == 0 {
+			// A zero is followed by the number of further zeros, in repeating pairs of
+			// bits where 3 means that another pair follows.
+			for {
+				repeat := fr.read(2)
+				for range repeat {
+					distribution = append(distribution, 0)
+				}
+				if repeat != 3 {
+					break
+				}
+			}
+			if len(distribution) > symbols {
+				return nil, 0, 0, errZstdCorrupt
+			}
+		}
+
+		for remaining < threshold && threshold > 1 {
+			threshold >>= 1
+			nbits--
+		}
+	}
+
+	read := int((fr.position + 7) / 8)
+	if remaining != 1 || read > len(data) {
+		return nil, 0, 0, errZstdCorrupt
+	}
+
+	return distribution, log, read, nil
+}
+
+// xxhash64 is the 64-bit xxHash, with a seed of zero, which Zstandard uses for the
+// checksums of frames.
+type xxhash64 struct {
+	v     [4]uint64
+	buf   [32]byte
+	n     int
+	total uint64
+}
+
+const (
+	xxhashPrime1 uint64 = 11400714785074694791
+	xxhashPrime2 uint64 = 14029467366897019727
+	xxhashPrime3 uint64 = 1609587929392839161
+	xxhashPrime4 uint64 = 9650029242287828579
+	xxhashPrime5 uint64 = 2870177450012600261
+)
+
+func newXXHash64() *xxhash64 {
+	xh := &xxhash64{}
+	xh.reset()
+
+	return xh
+}
+
+func (xh *xxhash64) reset() {
+	var seed uint64
+	xh.v = [4]uint64{seed + xxhashPrime1 + xxhashPrime2, seed + xxhashPrime2, seed, seed - xxhashPrime1}
+	xh.n = 0
+	xh.total = 0
+}
+
+func xxhashRound(acc, input uint64) uint64 {
+	return bits.RotateLeft64(acc+input*xxhashPrime2, 31) * xxhashPrime1
+}
+
+func (xh *xxhash64) stripe(data []byte) {
+	for i := range xh.v {
+		xh.v[i] = xxhashRound(xh.v[i], binary.LittleEndian.Uint64(data[8*i:]))
+	}
+}
+
+func (xh *xxhash64) write(data []byte) {
+	xh.total += uint64(len(data))
+
+	if xh.n > 0 {
+		copied := copy(xh.buf[xh.n:], data)
+		xh.n += copied
+		data = data[copied:]
+		if xh.n < len(xh.buf) {
+			return
+		}
+
+		xh.stripe(xh.buf[:])
+		xh.n = 0
+	}
+
+	for ; len(data) >= 32; data = data[32:] {
+		xh.stripe(data)
+	}
+	xh.n = copy(xh.buf[:], data)
+}
+
+func (xh *xxhash64) sum() uint64 {
+	var h uint64
+	if xh.total >= 32 {
+		h = bits.RotateLeft64(xh.v[0], 1) + bits.RotateLeft64(xh.v[1], 7) +
+			bits.RotateLeft64(xh.v[2], 12) + bits.RotateLeft64(xh.v[3], 18)
+		for _, v := range xh.v {
+			h = (h^xxhashRound(0, v))*xxhashPrime1 + xxhashPrime4
+		}
+	} else {
+		h = xxhashPrime5
+	}
+	h += xh.total
+
+	data := xh.buf[:xh.n]
+	for ; len(data) >= 8; data = data[8:] {
+		h ^= xxhashRound(0, binary.LittleEndian.Uint64(data))
+		h = bits.RotateLeft64(h, 27)*xxhashPrime1 + xxhashPrime4
+	}
+	if len(data) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(data)) * xxhashPrime1
+		h = bits.RotateLeft64(h, 23)*xxhashPrime2 + xxhashPrime3
+		data = data[4:]
+	}
+	for _, b := range data {
+		h ^= uint64(b) * xxhashPrime5
+		h = bits.RotateLeft64(h, 11) * xxhashPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxhashPrime2
+	h ^= h >> 29
+	h *= xxhashPrime3
+	h ^= h >> 32
+
+	return h
+}
@@ -0,0 +1,772 @@
+package fsutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// zstdReader decompresses a stream of Zstandard frames. Frames that use a dictionary are
+// not supported, as there is nowhere to supply one from.
+type zstdReader struct {
+	r   io.Reader
+	err error
+
+	// out is the decompressed data that has yet to be read, which is the end of window.
+	out    []byte
+	window []byte
+
+	inFrame    bool
+	lastBlock  bool
+	windowSize int
+	checksum   bool
+	hash       *xxhash64
+	block      []byte
+
+	// The entropy tables and offsets that later blocks of a frame may reuse.
+	huffman        []zstdHuffmanEntry
+	huffmanBits    uint8
+	literalLengths []zstdDecodingState
+	offsets        []zstdDecodingState
+	matchLengths   []zstdDecodingState
+	repeats        [3]uint32
+
+	literals []byte
+}
+
+func newZstdReader(r io.Reader) *zstdReader {
+	return &zstdReader{r: r, hash: newXXHash64()}
+}
+
+func (zr *zstdReader) Read(p []byte) (int, error) {
+	for len(zr.out) == 0 {
+		if zr.err != nil {
+			return 0, zr.err
+		}
+
+		zr.err = zr.next()
+	}
+
+	n := copy(p, zr.out)
+	zr.out = zr.out[n:]
+
+	return n, nil
+}
+
+func (zr *zstdReader) Close() error {
+	return nil
+}
+
+// next decompresses the next block, reading the header of the next frame first if need
+// be.
+func (zr *zstdReader) next() error {
+	if !zr.inFrame {
+		return zr.readFrameHeader()
+	}
+
+	if zr.lastBlock {
+		zr.inFrame = false
+		if !zr.checksum {
+			return nil
+		}
+
+		var sum [4]byte
+		if _, err := io.ReadFull(zr.r, sum[:]); err != nil {
+			return zstdUnexpectedEOF(err)
+		}
+		if binary.LittleEndian.Uint32(sum[:]) != uint32(zr.hash.sum()) {
+			return errors.New("zstd: checksum mismatch")
+		}
+
+		return nil
+	}
+
+	// Only the window needs to be kept for later blocks, and it is only trimmed once it
+	// has grown to twice its size, so that it is not copied for every block.
+	if len(zr.window) > 2*zr.windowSize {
+		zr.window = zr.window[:copy(zr.window, zr.window[len(zr.window)-zr.windowSize:])]
+	}
+
+	start := len(zr.window)
+	if err := zr.readBlock(); err != nil {
+		return err
+	}
+
+	zr.out = zr.window[start:]
+	if zr.checksum {
+		zr.hash.write(zr.out)
+	}
+
+	return nil
+}
+
+func zstdUnexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// readFrameHeader reads the header of the next frame, skipping any skippable frames
+// before it. It returns io.EOF if there are no more frames.
+func (zr *zstdReader) readFrameHeader() error {
+	var header [14]byte
+	for {
+		if _, err := io.ReadFull(zr.r, header[:4]); err != nil {
+			return err
+		}
+
+		magic := binary.LittleEndian.Uint32(header[:4])
+		if magic == zstdMagic {
+			break
+		}
+		if magic&zstdSkippableMask != zstdSkippableMagic {
+			return errors.New("zstd: invalid magic number")
+		}
+
+		if _, err := io.ReadFull(zr.r, header[:4]); err != nil {
+			return zstdUnexpectedEOF(err)
+		}
+		size := int64(binary.LittleEndian.Uint32(header[:4]))
+		if n, err := io.CopyN(io.Discard, zr.r, size); n < size {
+			return zstdUnexpectedEOF(err)
+		}
+	}
+
+	if _, err := io.ReadFull(zr.r, header[:1]); err != nil {
+		return zstdUnexpectedEOF(err)
+	}
+
+	descriptor := header[0]
+	if descriptor&0x08 != 0 {
+		return errZstdCorrupt
+	}
+
+	singleSegment := descriptor&0x20 != 0
+	zr.checksum = descriptor&0x04 != 0
+
+	dictionarySize := [...]int{0, 1, 2, 4}[descriptor&0x03]
+	contentSize := [...]int{0, 2, 4, 8}[descriptor>>6]
+	if singleSegment && contentSize == 0 {
+		contentSize = 1
+	}
+	windowSize := 0
+	if !singleSegment {
+		windowSize = 1
+	}
+
+	rest := header[:windowSize+dictionarySize+contentSize]
+	if _, err := io.ReadFull(zr.r, rest); err != nil {
+		return zstdUnexpectedEOF(err)
+	}
+
+	if !singleSegment {
+		exponent, mantissa := uint(rest[0]>>3), uint64(rest[0]&0x07)
+		base := uint64(1) << (10 + exponent)
+		if size := base + base/8*mantissa; size <= zstdMaxWindowSize {
+			zr.windowSize = int(size)
+		} else {
+			return fmt.Errorf("zstd: window of %d bytes is larger than the maximum of %d", size, zstdMaxWindowSize)
+		}
+		rest = rest[1:]
+	}
+
+	var dictionary uint32
+	for i, b := range rest[:dictionarySize] {
+		dictionary |= uint32(b) << (8 * i)
+	}
+	if dictionary != 0 {
+		return errors.New("zstd: dictionaries are not supported")
+	}
+	rest = rest[dictionarySize:]
+
+	if singleSegment {
+		var size uint64
+		for i, b := range rest {
+			size |= uint64(b) << (8 * i)
+		}
+		if contentSize == 2 {
+			size += 256
+		}
+		if size > zstdMaxWindowSize {
+			return fmt.Errorf("zstd: window of %d bytes is larger than the maximum of %d", size, zstdMaxWindowSize)
+		}
+		zr.windowSize = int(size)
+	}
+
+	zr.inFrame = true
+	zr.lastBlock = false
+	zr.window = zr.window[:0]
+	zr.hash.reset()
+	zr.huffman = nil
+	zr.literalLengths, zr.offsets, zr.matchLengths = nil, nil, nil
+	zr.repeats = [3]uint32{1, 4, 8}
+
+	return nil
+}
+
+// readBlock reads the next block and appends what it decompresses to the window.
+func (zr *zstdReader) readBlock() error {
+	var header [3]byte
+	if _, err := io.ReadFull(zr.r, header[:]); err != nil {
+		return zstdUnexpectedEOF(err)
+	}
+
+	value := uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16
+	zr.lastBlock = value&1 != 0
+	size := int(value >> 3)
+
+	maxSize := min(zr.windowSize, zstdMaxBlockSize)
+	switch kind := (value >> 1) & 0x03; kind {
+	case zstdBlockRaw:
+		if size > maxSize {
+			return errZstdCorrupt
+		}
+
+		start := len(zr.window)
+		zr.window = append(zr.window, make([]byte, size)...)
+		if _, err := io.ReadFull(zr.r, zr.window[start:]); err != nil {
+			return zstdUnexpectedEOF(err)
+		}
+
+		return nil
+	case zstdBlockRLE:
+		if size > maxSize {
+			return errZstdCorrupt
+		}
+
+		var b [1]byte
+		if _, err := io.ReadFull(zr.r, b[:]); err != nil {
+			return zstdUnexpectedEOF(err)
+		}
+		for range size {
+			zr.window = append(zr.window, b[0])
+		}
+
+		return nil
+	case zstdBlockCompressed:
+		if size > zstdMaxBlockSize {
+			return errZstdCorrupt
+		}
+
+		if cap(zr.block) < size {
+			zr.block = make([]byte, size)
+		}
+		zr.block = zr.block[:size]
+		if _, err := io.ReadFull(zr.r, zr.block); err != nil {
+			return zstdUnexpectedEOF(err)
+		}
+
+		return zr.decompressBlock(zr.block, maxSize)
+	default:
+		return errZstdCorrupt
+	}
+}
+
+// decompressBlock decompresses the compressed block in data onto the window.
+func (zr *zstdReader) decompressBlock(data []byte, maxSize int) error {
+	n, err := zr.readLiterals(data, maxSize)
+	if err != nil {
+		return err
+	}
+	data = data[n:]
+
+	if len(data) == 0 {
+		return errZstdCorrupt
+	}
+
+	sequences := int(data[0])
+	switch {
+	case sequences == 0:
+		if len(data) != 1 {
+			return errZstdCorrupt
+		}
+
+		return zr.appendWindow(zr.literals, maxSize, len(zr.window))
+	case sequences < 128:
+		data = data[1:]
+	case sequences < 255:
+		if len(data) < 2 {
+			return errZstdCorrupt
+		}
+		sequences = (sequences-128)<<8 | int(data[1])
+		data = data[2:]
+	default:
+		if len(data) < 3 {
+			return errZstdCorrupt
+		}
+		sequences = (int(data[1]) | int(data[2])<<8) + 0x7F00
+		data = data[3:]
+	}
+
+	if len(data) == 0 {
+		return errZstdCorrupt
+	}
+	modes := data[0]
+	if modes&0x03 != 0 {
+		return errZstdCorrupt
+	}
+	data = data[1:]
+
+	for _, table := range []struct {
+		mode         byte
+		table        *[]zstdDecodingState
+		distribution []int16
+		log          uint8
+		symbols      int
+		maxLog       uint8
+	}{
+		{modes >> 6, &zr.literalLengths, zstdLiteralLengthDistribution, zstdLiteralLengthLog, len(zstdLiteralLengthCodes), 9},
+		{(modes >> 4) & 0x03, &zr.offsets, zstdOffsetDistribution, zstdOffsetLog, 32, 8},
+		{(modes >> 2) & 0x03, &zr.matchLengths, zstdMatchLengthDistribution, zstdMatchLengthLog, len(zstdMatchLengthCodes), 9},
+	} {
+		switch table.mode {
+		case zstdModePredefined:
+			*table.table = zstdDecodingTable(table.distribution, table.log)
+		case zstdModeRLE:
+			if len(data) == 0 || int(data[0]) >= table.symbols {
+				return errZstdCorrupt
+			}
+			*table.table = zstdRLETable(data[0])
+			data = data[1:]
+		case zstdModeCompressed:
+			distribution, log, n, err := zstdReadDistribution(data, table.symbols, table.maxLog)
+			if err != nil {
+				return err
+			}
+			*table.table = zstdDecodingTable(distribution, log)
+			data = data[n:]
+		case zstdModeRepeat:
+			if *table.table == nil {
+				return errZstdCorrupt
+			}
+		}
+	}
+
+	return zr.executeSequences(data, sequences, maxSize)
+}
+
+// executeSequences decodes the sequences in data, and appends the literals and matches
+// that they describe to the window.
+func (zr *zstdReader) executeSequences(data []byte, sequences, maxSize int) error {
+	br, err := newZstdBackwardReader(data)
+	if err != nil {
+		return err
+	}
+
+	start := len(zr.window)
+	literals := zr.literals
+
+	literalLengthState := uint(br.read(zstdTableLog(zr.literalLengths)))
+	offsetState := uint(br.read(zstdTableLog(zr.offsets)))
+	matchLengthState := uint(br.read(zstdTableLog(zr.matchLengths)))
+
+	for i := range sequences {
+		literalLengthCode := zr.literalLengths[literalLengthState].symbol
+		offsetCode := zr.offsets[offsetState].symbol
+		matchLengthCode := zr.matchLengths[matchLengthState].symbol
+		if int(literalLengthCode) >= len(zstdLiteralLengthCodes) || int(matchLengthCode) >= len(zstdMatchLengthCodes) ||
+			offsetCode > 31 {
+			return errZstdCorrupt
+		}
+
+		offsetValue := uint32(1)<<offsetCode + uint32(br.read(uint(offsetCode)))
+
+		code := zstdMatchLengthCodes[matchLengthCode]
+		matchLength := int(code.base) + int(br.read(uint(code.bits)))
+
+		code = zstdLiteralLengthCodes[literalLengthCode]
+		literalLength := int(code.base) + int(br.read(uint(code.bits)))
+
+		offset, err := zr.offset(offsetValue, literalLength)
+		if err != nil {
+			return err
+		}
+
+		if literalLength > len(literals) {
+			return errZstdCorrupt
+		}
+		if err := zr.appendWindow(literals[:literalLength], maxSize, start); err != nil {
+			return err
+		}
+		literals = literals[literalLength:]
+
+		if err := zr.appendMatch(int(offset), matchLength, maxSize, start); err != nil {
+			return err
+		}
+
+		if i < sequences-1 {
+			literalLengthState = zstdNextState(br, zr.literalLengths[literalLengthState])
+			matchLengthState = zstdNextState(br, zr.matchLengths[matchLengthState])
+			offsetState = zstdNextState(br, zr.offsets[offsetState])
+		}
+
+		if br.overread > 0 {
+			return errZstdCorrupt
+		}
+	}
+
+	if !br.done() {
+		return errZstdCorrupt
+	}
+
+	return zr.appendWindow(literals, maxSize, start)
+}
+
+func zstdTableLog(table []zstdDecodingState) uint {
+	return uint(bits.Len(uint(len(table))) - 1)
+}
+
+func zstdNextState(br *zstdBackwardReader, state zstdDecodingState) uint {
+	return uint(state.baseline) + uint(br.read(uint(state.bits)))
+}
+
+// offset returns the offset of a match from its offset value, which is either an offset
+// or refers to one of the three most recent offsets, and updates the recent offsets.
+func (zr *zstdReader) offset(value uint32, literalLength int) (uint32, error) {
+	reps := &zr.repeats
+	if value > 3 {
+		offset := value - 3
+		*reps = [3]uint32{offset, reps[0], reps[1]}
+
+		return offset, nil
+	}
+
+	index := value - 1
+	if literalLength == 0 {
+		index++
+	}
+
+	switch index {
+	case 0:
+		return reps[0], nil
+	case 1:
+		*reps = [3]uint32{reps[1], reps[0], reps[2]}
+	case 2:
+		*reps = [3]uint32{reps[2], reps[0], reps[1]}
+	default:
+		offset := reps[0] - 1
+		if offset == 0 {
+			return 0, errZstdCorrupt
+		}
+		*reps = [3]uint32{offset, reps[0], reps[1]}
+	}
+
+	return reps[0], nil
+}
+
+// appendWindow appends data to the window, checking that the block that started at start
+// does not decompress to more than maxSize bytes.
+func (zr *zstdReader) appendWindow(data []byte, maxSize, start int) error {
+	if len(zr.window)+len(data)-start > maxSize {
+		return errZstdCorrupt
+	}
+
+	zr.window = append(zr.window, data...)
+
+	return nil
+}
+
+// appendMatch appends length bytes, copied from offset bytes back in the window, to the
+// window.
+func (zr *zstdReader) appendMatch(offset, length, maxSize, start int) error {
+	if offset > len(zr.window) || offset > zr.windowSize || len(zr.window)+length-start > maxSize {
+		return errZstdCorrupt
+	}
+
+	// The match may overlap what it is appending, in which case it repeats.
+	from := len(zr.window) - offset
+	for length > 0 {
+		n := min(length, offset)
+		zr.window = append(zr.window, zr.window[from:from+n]...)
+		from += n
+		length -= n
+	}
+
+	return nil
+}
+
+// zstdHuffmanEntry is an entry of a Huffman decoding table, which is indexed by the next
+// bits of the stream.
+type zstdHuffmanEntry struct {
+	symbol uint8
+	bits   uint8
+}
+
+// readLiterals reads the literals section at the start of data into zr.literals, and
+// returns its size.
+func (zr *zstdReader) readLiterals(data []byte, maxSize int) (int, error) {
+	if len(data) == 0 {
+		return 0, errZstdCorrupt
+	}
+
+	kind := data[0] & 0x03
+	format := (data[0] >> 2) & 0x03
+
+	if kind == zstdLiteralsRaw || kind == zstdLiteralsRLE {
+		var size, header int
+		switch format {
+		case 0, 2:
+			size, header = int(data[0]>>3), 1
+		case 1:
+			if len(data) < 2 {
+				return 0, errZstdCorrupt
+			}
+			size, header = int(data[0]>>4)|int(data[1])<<4, 2
+		default:
+			if len(data) < 3 {
+				return 0, errZstdCorrupt
+			}
+			size, header = int(data[0]>>4)|int(data[1])<<4|int(data[2])<<12, 3
+		}
+		if size > maxSize {
+			return 0, errZstdCorrupt
+		}
+
+		if kind == zstdLiteralsRaw {
+			if len(data) < header+size {
+				return 0, errZstdCorrupt
+			}
+			zr.literals = append(zr.literals[:0], data[header:header+size]...)
+
+			return header + size, nil
+		}
+
+		if len(data) < header+1 {
+			return 0, errZstdCorrupt
+		}
+		zr.literals = zr.literals[:0]
+		for range size {
+			zr.literals = append(zr.literals, data[header])
+		}
+
+		return header + 1, nil
+	}
+
+	var (
+		header, sizeBits int
+		streams          = 4
+	)
+	switch format {
+	case 0:
+		header, sizeBits, streams = 3, 10, 1
+	case 1:
+		header, sizeBits = 3, 10
+	case 2:
+		header, sizeBits = 4, 14
+	default:
+		header, sizeBits = 5, 18
+	}
+	if len(data) < header {
+		return 0, errZstdCorrupt
+	}
+
+	var value uint64
+	for i, b := range data[:header] {
+		value |= uint64(b) << (8 * i)
+	}
+	mask := uint64(1)<<sizeBits - 1
+	size := int((value >> 4) & mask)
+	compressedSize := int((value >> (4 + sizeBits)) & mask)
+	if size > maxSize || len(data) < header+compressedSize {
+		return 0, errZstdCorrupt
+	}
+
+	compressed := data[header : header+compressedSize]
+	if kind == zstdLiteralsCompressed {
+		n, err := zr.readHuffmanTable(compressed)
+		if err != nil {
+			return 0, err
+		}
+		compressed = compressed[n:]
+	} else if zr.huffman == nil {
+		return 0, errZstdCorrupt
+	}
+
+	if cap(zr.literals) < size {
+		zr.literals = make([]byte, size)
+	}
+	zr.literals = zr.literals[:size]
+
+	if streams == 1 {
+		if err := zr.decodeHuffman(zr.literals, compressed); err != nil {
+			return 0, err
+		}
+
+		return header + compressedSize, nil
+	}
+
+	if len(compressed) < 6 {
+		return 0, errZstdCorrupt
+	}
+
+	var (
+		jump      = compressed[6:]
+		remaining = len(jump)
+		segment   = (size + 3) / 4
+	)
+	for i := range 4 {
+		streamSize := remaining
+		if i < 3 {
+			streamSize = int(binary.LittleEndian.Uint16(compressed[2*i:]))
+		}
+		if streamSize > remaining {
+			return 0, errZstdCorrupt
+		}
+
+		out := zr.literals[min(i*segment, size):min((i+1)*segment, size)]
+		if err := zr.decodeHuffman(out, jump[:streamSize]); err != nil {
+			return 0, err
+		}
+
+		jump = jump[streamSize:]
+		remaining -= streamSize
+	}
+
+	return header + compressedSize, nil
+}
+
+// readHuffmanTable reads the description of a Huffman table from the start of data, and
+// returns its size.
+func (zr *zstdReader) readHuffmanTable(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, errZstdCorrupt
+	}
+
+	var (
+		weights [257]uint8
+		count   int
+		size    int
+	)
+	if header := int(data[0]); header >= 128 {
+		// The weights are written directly, in four bits each.
+		count = header - 127
+		size = 1 + (count+1)/2
+		if len(data) < size {
+			return 0, errZstdCorrupt
+		}
+
+		for i := range count {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				weights[i] = b >> 4
+			} else {
+				weights[i] = b & 0x0F
+			}
+		}
+	} else {
+		// The weights are compressed with FSE, using two interleaved states.
+		size = 1 + header
+		if len(data) < size {
+			return 0, errZstdCorrupt
+		}
+
+		distribution, log, n, err := zstdReadDistribution(data[1:size], 256, 6)
+		if err != nil {
+			return 0, err
+		}
+		table := zstdDecodingTable(distribution, log)
+
+		br, err := newZstdBackwardReader(data[1+n : size])
+		if err != nil {
+			return 0, err
+		}
+
+		states := [2]uint{uint(br.read(uint(log))), uint(br.read(uint(log)))}
+		for i := 0; ; i ^= 1 {
+			if count >= 255 {
+				return 0, errZstdCorrupt
+			}
+			weights[count] = table[states[i]].symbol
+			count++
+
+			states[i] = zstdNextState(br, table[states[i]])
+			if br.overread > 0 {
+				// The other state holds the last weight.
+				weights[count] = table[states[i^1]].symbol
+				count++
+
+				break
+			}
+		}
+	}
+
+	if count > 255 {
+		return 0, errZstdCorrupt
+	}
+
+	// The weight of the last symbol is left out, as it is whatever completes the tree.
+	var total uint32
+	for _, weight := range weights[:count] {
+		if weight > zstdMaxHuffmanBits {
+			return 0, errZstdCorrupt
+		}
+		if weight > 0 {
+			total += 1 << (weight - 1)
+		}
+	}
+	if total == 0 {
+		return 0, errZstdCorrupt
+	}
+
+	maxBits := uint8(bits.Len32(total))
+	rest := uint32(1)<<maxBits - total
+	if maxBits > zstdMaxHuffmanBits || rest&(rest-1) != 0 {
+		return 0, errZstdCorrupt
+	}
+	weights[count] = uint8(bits.Len32(rest))
+	count++
+
+	// Codes are assigned to symbols in order of increasing weight, and then of symbol.
+	var starts [zstdMaxHuffmanBits + 2]uint32
+	for _, weight := range weights[:count] {
+		if weight > 0 {
+			starts[weight+1] += 1 << (weight - 1)
+		}
+	}
+	for weight := 2; weight < len(starts); weight++ {
+		starts[weight] += starts[weight-1]
+	}
+
+	table := make([]zstdHuffmanEntry, 1<<maxBits)
+	for symbol, weight := range weights[:count] {
+		if weight == 0 {
+			continue
+		}
+
+		entry := zstdHuffmanEntry{symbol: uint8(symbol), bits: maxBits + 1 - weight}
+		start := starts[weight]
+		end := start + 1<<(weight-1)
+		for i := start; i < end; i++ {
+			table[i] = entry
+		}
+		starts[weight] = end
+	}
+
+	zr.huffman, zr.huffmanBits = table, maxBits
+
+	return size, nil
+}
+
+// decodeHuffman decodes the Huffman-coded stream in data to fill out.
+func (zr *zstdReader) decodeHuffman(out, data []byte) error {
+	br, err := newZstdBackwardReader(data)
+	if err != nil {
+		return err
+	}
+
+	for i := range out {
+		entry := zr.huffman[br.peek(uint(zr.huffmanBits))]
+		br.skip(uint(entry.bits))
+		out[i] = entry.symbol
+	}
+
+	if !br.done() {
+		return errZstdCorrupt
+	}
+
+	return nil
+}
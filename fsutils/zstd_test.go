@@ -0,0 +1,189 @@
+package fsutils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// zstdReferenceFrame is zstdReferenceText compressed by the zstd tool at level 19, which
+// codes literals with Huffman codes and sequences with tables of its own.
+const zstdReferenceFrame = `
+KLUv/WTBYAUiAHpMTAkZcDksB1D6Q+kPpY///9VKKZNMKZGOT+8eDqcAhACEAGN+LGN2JT+poLZiiJvKX2J71ZquhcRQyWk4
+RLcI6aLulG6LU/1EHvY0uVsVYs1kQqpDMlNzIWn5pZEdOfw60TbS71JkshYSbRiVF1HIuF7LynZfrIwLxlRLUsN/FXM6prnG
+RuVTed4L5XPV3ABQMOBAQYVDHBBAKHDQsEFAgwkGLkA4UIjgwMKCQwYIBw4PMECYMODAgIACCxIOBhJUOASIg4EHChoEixLW
+S+XxP5La7Jg0Y9BaPElN+xjhXOmL9lYxdbnoIXS5DE1UC8kVxfeS0jZC/4VstjR+rRChqiGZ8Tsi45oKsbWHacUSefqfZLO0
+O4tGRi1FRMORnyTErtZczQ6+qkLcxLwVKfNduXFi4zKpM7ovZb8VMr5VxFyjmN1S/irku3KUXyNq/Cdld4wtylX0jfCvlO13
+xM4MGtcMcdbzIuW2MeRWiXmVTaup75IQU+UcjihuKZKKZr9UNgv1J3lsCK1OrRF6jcjY7VBmNWGhatGHdlbI7ltymzS6VxGR
+YzmRDC0vMWSuYF0rsuf1inCOaUup8bG1gjgzvin1qD7LeyMorI9q/El4NWsPVRqN/7K8FvZjzVGroI1f8pmEfVmeHzSqkjnj
+zwi9lqfU2piY4qgyr9+Wr/y6GDKKy2tIRGM5KUXeK+U2oZC/yMQO7ZeWheqqw52SoZoRektuWlmS10dPtZF09hcJaUtJxOHk
+QaoQmmvqr8T2q6uQW0ysLSniZ30Ix+Oa7GJ0iPpV5XckgxioIgj29ms3Am9RSh0SSBBYAiUV/P/+dCTr8sV9Wzjc+/3+fn6f
+t2n1avUy/eArf46eeKomw8J8zHX3ipNfd+AFuW6IT771+L2dX+fzxsnsYHUNzz+vF6QgD3UrVf/faZ+efl6OnE6tHqf7+sT0
+/Agv4XW/3hmfLyzNV3PPCTpHS8OvnOQDDZ0qK4TWVZC4Xz98Mt7Op4MLmh3Mr1cTOJXr6C9N/LY8OS3vBoazmcH2iz9reL2M
+XG+Ll7P7aWo6ExO8OhjfFtY2rl2fgNu/b08HgV/boyrtsjsxvd9O32BG7/u2+/32fL7vDKY3vOw+5qN6ssmyOoYHdc6fyet8
+Xd377+70/q3tN55vnzE5s+H1Z/i8PRkWBpef3q0RvWFnl/HIcDAfed2tDqanY77g8uZvcAS1w37tXR++df3Z3fcny8H8wOt0
+CY86n+cGhxevw+F0PLnMc3A0+q4XXw7G560zUOA9ZW23H4xf/uGkg/nLh0xeM3J8808T9TdydjwL/ybPvAZODH7E79Xr07Nf
+AxPDJ/+MzAyemacJMDE88nf4rC/nLs7AVT8c3/iwtmJ08WF54ezeO8Bnujy6fbF2MP7yQbYOh1Zxcxt4f4ifVDjAuvA37Oih
+x20AduTgNy53if4wQGsBCLcCR5WRWw==
+`
+
+func zstdReferenceText() []byte {
+	var text bytes.Buffer
+	for i := range 400 {
+		fmt.Fprintf(&text, "line %d: the quick brown fox jumps over the lazy dog %d times\n", i, i*i%97)
+	}
+
+	return text.Bytes()
+}
+
+func zstdCompress(t *testing.T, data []byte, chunk int) []byte {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	writer := newZstdWriter(&compressed)
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		if _, err := writer.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return compressed.Bytes()
+}
+
+func TestZstdReaderReference(t *testing.T) {
+	frame, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(zstdReferenceFrame, "\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(newZstdReader(bytes.NewReader(frame)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, zstdReferenceText()) {
+		t.Error("expected the frame to decompress to the reference text")
+	}
+}
+
+func TestZstdRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewPCG(1, 2))
+
+	noise := make([]byte, 300_000)
+	for i := range noise {
+		noise[i] = byte(random.Uint32())
+	}
+
+	// Skewed bytes that use every symbol, with repeats both within and beyond a block.
+	skewed := make([]byte, 500_000)
+	for i := range skewed {
+		skewed[i] = byte(random.ExpFloat64() * 16)
+	}
+	skewed = append(skewed, skewed[:200_000]...)
+
+	// Repeats that are further apart than the window cannot be matched, but must still
+	// be compressed correctly.
+	var distant []byte
+	for range 3 {
+		distant = append(distant, noise[:200_000]...)
+		distant = append(distant, bytes.Repeat([]byte("padding "), 200_000)...)
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":   nil,
+		"small":   []byte("hello, world\n"),
+		"text":    bytes.Repeat(zstdReferenceText(), 20),
+		"zeros":   make([]byte, 1_000_000),
+		"noise":   noise,
+		"skewed":  skewed,
+		"distant": distant,
+	} {
+		for _, chunk := range []int{len(data) + 1, 1000, zstdMaxBlockSize} {
+			compressed := zstdCompress(t, data, chunk)
+
+			decompressed, err := io.ReadAll(newZstdReader(bytes.NewReader(compressed)))
+			if err != nil {
+				t.Fatalf("%s in chunks of %d: %v", name, chunk, err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Fatalf("%s in chunks of %d: expected the data to survive compression", name, chunk)
+			}
+		}
+	}
+
+	if compressed := zstdCompress(t, zstdReferenceText(), 4096); len(compressed) > len(zstdReferenceText())/4 {
+		t.Errorf("expected text to compress to under a quarter of its size, got %d of %d bytes",
+			len(compressed), len(zstdReferenceText()))
+	}
+}
+
+func TestZstdReaderFrames(t *testing.T) {
+	var stream []byte
+	stream = append(stream, zstdCompress(t, []byte("first "), 1)...)
+
+	// Skippable frames are ignored.
+	stream = binary.LittleEndian.AppendUint32(stream, zstdSkippableMagic+3)
+	stream = binary.LittleEndian.AppendUint32(stream, 4)
+	stream = append(stream, "skip"...)
+
+	stream = append(stream, zstdCompress(t, []byte("second"), 1)...)
+
+	data, err := io.ReadAll(newZstdReader(bytes.NewReader(stream)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first second" {
+		t.Errorf("expected every frame to be decompressed, got %q", data)
+	}
+}
+
+func TestZstdReaderErrors(t *testing.T) {
+	frame := zstdCompress(t, zstdReferenceText(), 4096)
+
+	corrupt := bytes.Clone(frame)
+	corrupt[len(corrupt)-1] ^= 0xFF
+	if _, err := io.ReadAll(newZstdReader(bytes.NewReader(corrupt))); err == nil ||
+		!strings.Contains(err.Error(), "checksum") {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+
+	if _, err := io.ReadAll(newZstdReader(bytes.NewReader(frame[:len(frame)/2]))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected a truncated frame to fail with io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	if _, err := io.ReadAll(newZstdReader(strings.NewReader("not zstd"))); err == nil {
+		t.Error("expected data that is not zstd to fail")
+	}
+
+	// Flipping the bits of the compressed data must fail rather than panic.
+	for i := range min(len(frame), 512) {
+		corrupt := bytes.Clone(frame)
+		corrupt[i] ^= 0x55
+		_, _ = io.ReadAll(newZstdReader(bytes.NewReader(corrupt)))
+	}
+}
+
+func TestXXHash64(t *testing.T) {
+	for input, expected := range map[string]uint64{
+		"":    0xEF46DB3751D8E999,
+		"a":   0xD24EC4F1A98C6E5B,
+		"abc": 0x44BC2CF5AD770999,
+		"Nobody inspects the spammish repetition": 0xFBCEA83C8A378BF1,
+	} {
+		hash := newXXHash64()
+		hash.write([]byte(input))
+		if sum := hash.sum(); sum != expected {
+			t.Errorf("xxhash64(%q) should be %#x, got %#x", input, expected, sum)
+		}
+	}
+}
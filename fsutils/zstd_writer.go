@@ -0,0 +1,903 @@
+package fsutils
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// zstdWriterWindowLog is the log of the window that zstdWriter finds matches in.
+	zstdWriterWindowLog  = 20
+	zstdWriterWindowSize = 1 << zstdWriterWindowLog
+
+	zstdWriterHashLog = 16
+	zstdMinMatch      = 4
+)
+
+// The tables that encode the predefined distributions, which zstdWriter uses unless
+// describing tables of its own is worth it.
+var (
+	zstdLiteralLengthEncoding = newZstdEncodingTable(zstdLiteralLengthDistribution, zstdLiteralLengthLog)
+	zstdMatchLengthEncoding   = newZstdEncodingTable(zstdMatchLengthDistribution, zstdMatchLengthLog)
+	zstdOffsetEncoding        = newZstdEncodingTable(zstdOffsetDistribution, zstdOffsetLog)
+)
+
+// errZstdWriterClosed is returned when writing to a zstdWriter that has been closed.
+var errZstdWriterClosed = errors.New("zstd: write to closed writer")
+
+// zstdSequence is a run of literals followed by a match. The offset of the match is
+// coded as the decoder reads it, so either as one of the recent offsets, or as the offset
+// plus three.
+type zstdSequence struct {
+	literalLength uint32
+	matchLength   uint32
+	offsetValue   uint32
+}
+
+// zstdWriter compresses what is written to it into a single Zstandard frame. It finds
+// matches greedily using hash tables, and codes literals with Huffman codes and sequences
+// with FSE, which trades some compression for speed and simplicity, much like the
+// fastest levels of the zstd tool.
+type zstdWriter struct {
+	w   io.Writer
+	err error
+
+	// history holds the window that matches are found in, followed by the data that
+	// has yet to be compressed, which starts at pending.
+	history    []byte
+	pending    int
+	shortTable []int32
+	longTable  []int32
+	recent     [3]uint32
+
+	hash      *xxhash64
+	started   bool
+	literals  []byte
+	sequences []zstdSequence
+	block     []byte
+}
+
+func newZstdWriter(w io.Writer) *zstdWriter {
+	return &zstdWriter{
+		w:          w,
+		history:    make([]byte, 0, 2*zstdWriterWindowSize+zstdMaxBlockSize),
+		shortTable: make([]int32, 1<<zstdWriterHashLog),
+		longTable:  make([]int32, 1<<zstdWriterHashLog),
+		recent:     [3]uint32{1, 4, 8},
+		hash:       newXXHash64(),
+	}
+}
+
+func (zw *zstdWriter) Write(p []byte) (int, error) {
+	if zw.err != nil {
+		return 0, zw.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full block is only written once more data follows it, as the last block of
+		// the frame must be marked as such when the writer is closed.
+		if len(zw.history)-zw.pending == zstdMaxBlockSize {
+			if zw.err = zw.writeBlock(false); zw.err != nil {
+				return written, zw.err
+			}
+		}
+		if len(zw.history) == cap(zw.history) {
+			zw.slide()
+		}
+
+		n := min(len(p), cap(zw.history)-len(zw.history), zstdMaxBlockSize-(len(zw.history)-zw.pending))
+		zw.history = append(zw.history, p[:n]...)
+		zw.hash.write(p[:n])
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close writes the rest of the data, and the end of the frame. It does not close the
+// underlying writer.
+func (zw *zstdWriter) Close() error {
+	if zw.err != nil {
+		if errors.Is(zw.err, errZstdWriterClosed) {
+			return nil
+		}
+
+		return zw.err
+	}
+
+	if zw.err = zw.writeBlock(true); zw.err != nil {
+		return zw.err
+	}
+
+	var checksum [4]byte
+	binary.LittleEndian.PutUint32(checksum[:], uint32(zw.hash.sum()))
+	if _, zw.err = zw.w.Write(checksum[:]); zw.err != nil {
+		return zw.err
+	}
+
+	zw.err = errZstdWriterClosed
+
+	return nil
+}
+
+// slide drops the history that is too old to be matched, to make room for more data.
+func (zw *zstdWriter) slide() {
+	drop := zw.pending - zstdWriterWindowSize
+	if drop <= 0 {
+		return
+	}
+
+	zw.history = zw.history[:copy(zw.history, zw.history[drop:])]
+	zw.pending -= drop
+
+	for _, table := range [][]int32{zw.shortTable, zw.longTable} {
+		for i, position := range table {
+			table[i] = max(position-int32(drop), 0)
+		}
+	}
+}
+
+// writeBlock compresses the pending data into a block, along with the frame header
+// before the first block.
+func (zw *zstdWriter) writeBlock(last bool) error {
+	zw.block = zw.block[:0]
+	if !zw.started {
+		// The frame has a checksum, and its window size is given rather than its
+		// content size, which is not known in advance.
+		zw.block = binary.LittleEndian.AppendUint32(zw.block, zstdMagic)
+		zw.block = append(zw.block, 0x04, (zstdWriterWindowLog-10)<<3)
+		zw.started = true
+	}
+
+	data := zw.history[zw.pending:]
+	header := len(zw.block)
+	zw.block = append(zw.block, 0, 0, 0)
+
+	kind := zstdBlockCompressed
+	zw.block = zw.compressBlock(zw.block)
+	if len(zw.block)-header-3 >= len(data) {
+		kind = zstdBlockRaw
+		zw.block = append(zw.block[:header+3], data...)
+	}
+
+	value := uint32(len(zw.block)-header-3)<<3 | uint32(kind)<<1
+	if last {
+		value |= 1
+	}
+	zw.block[header] = byte(value)
+	zw.block[header+1] = byte(value >> 8)
+	zw.block[header+2] = byte(value >> 16)
+
+	zw.pending = len(zw.history)
+
+	_, err := zw.w.Write(zw.block)
+
+	return err
+}
+
+// compressBlock appends the pending data, compressed, to dst.
+func (zw *zstdWriter) compressBlock(dst []byte) []byte {
+	zw.findSequences()
+
+	dst = zstdAppendLiterals(dst, zw.literals)
+
+	switch n := len(zw.sequences); {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7F00:
+		dst = append(dst, byte(n>>8+128), byte(n))
+	default:
+		dst = append(dst, 255, byte(n-0x7F00), byte((n-0x7F00)>>8))
+	}
+	if len(zw.sequences) == 0 {
+		return dst
+	}
+
+	return zstdAppendSequences(dst, zw.sequences)
+}
+
+func zstdHash(value uint32) uint32 {
+	return (value * 2654435761) >> (32 - zstdWriterHashLog)
+}
+
+func zstdLongHash(value uint64) uint32 {
+	return uint32((value * 0x9E3779B185EBCA87) >> (64 - zstdWriterHashLog))
+}
+
+// findSequences splits the pending data into literals and sequences of matches. At each
+// position, the most recent offset is tried first, then a match of at least 8 bytes, and
+// then a match of at least zstdMinMatch bytes.
+func (zw *zstdWriter) findSequences() {
+	zw.literals = zw.literals[:0]
+	zw.sequences = zw.sequences[:0]
+
+	var (
+		history      = zw.history
+		end          = len(history)
+		position     = zw.pending
+		literalStart = position
+	)
+	for position+8 <= end {
+		value := binary.LittleEndian.Uint32(history[position:])
+		longValue := binary.LittleEndian.Uint64(history[position:])
+
+		shortHash, longHash := zstdHash(value), zstdLongHash(longValue)
+		shortCandidate, longCandidate := int(zw.shortTable[shortHash])-1, int(zw.longTable[longHash])-1
+		zw.shortTable[shortHash], zw.longTable[longHash] = int32(position+1), int32(position+1)
+
+		candidate := -1
+		if recent := position - int(zw.recent[0]); position > literalStart && recent >= 0 &&
+			binary.LittleEndian.Uint32(history[recent:]) == value {
+			candidate = recent
+		} else if longCandidate >= 0 && position-longCandidate < zstdWriterWindowSize &&
+			binary.LittleEndian.Uint64(history[longCandidate:]) == longValue {
+			candidate = longCandidate
+		} else if shortCandidate >= 0 && position-shortCandidate < zstdWriterWindowSize &&
+			binary.LittleEndian.Uint32(history[shortCandidate:]) == value {
+			candidate = shortCandidate
+		}
+
+		if candidate < 0 {
+			// Skip ahead faster the longer it has been since the last match, so that
+			// incompressible data is not searched byte by byte.
+			position += 1 + (position-literalStart)>>6
+			continue
+		}
+
+		length := zstdMinMatch
+		for position+length < end && history[candidate+length] == history[position+length] {
+			length++
+		}
+		for position > literalStart && candidate > 0 && history[position-1] == history[candidate-1] {
+			position--
+			candidate--
+			length++
+		}
+
+		zw.literals = append(zw.literals, history[literalStart:position]...)
+		zw.sequences = append(zw.sequences, zstdSequence{
+			literalLength: uint32(position - literalStart),
+			matchLength:   uint32(length),
+			offsetValue:   zw.offsetValue(uint32(position-candidate), position == literalStart),
+		})
+
+		// Remember positions within the match too, which helps to find the next one.
+		for _, inside := range [...]int{position + 1, position + length - 2} {
+			if inside+8 <= end {
+				zw.shortTable[zstdHash(binary.LittleEndian.Uint32(history[inside:]))] = int32(inside + 1)
+				zw.longTable[zstdLongHash(binary.LittleEndian.Uint64(history[inside:]))] = int32(inside + 1)
+			}
+		}
+
+		position += length
+		literalStart = position
+	}
+
+	zw.literals = append(zw.literals, history[literalStart:end]...)
+}
+
+// offsetValue returns the value that codes offset, and updates the recent offsets the same
+// way that the decoder does. When a sequence has no literals, the meaning of the values of
+// the recent offsets is shifted by one, as the most recent offset would be pointless.
+func (zw *zstdWriter) offsetValue(offset uint32, noLiterals bool) uint32 {
+	recent := &zw.recent
+	switch {
+	case !noLiterals && offset == recent[0]:
+		return 1
+	case offset == recent[1]:
+		*recent = [3]uint32{recent[1], recent[0], recent[2]}
+		if noLiterals {
+			return 1
+		}
+
+		return 2
+	case offset == recent[2]:
+		*recent = [3]uint32{recent[2], recent[0], recent[1]}
+		if noLiterals {
+			return 2
+		}
+
+		return 3
+	case noLiterals && offset == recent[0]-1:
+		*recent = [3]uint32{offset, recent[0], recent[1]}
+		return 3
+	default:
+		*recent = [3]uint32{offset, recent[0], recent[1]}
+		return offset + 3
+	}
+}
+
+// zstdAppendLiterals appends a literals section holding literals to dst, coding them
+// with Huffman codes if that makes them smaller.
+func zstdAppendLiterals(dst, literals []byte) []byte {
+	var counts [256]int
+	for _, literal := range literals {
+		counts[literal]++
+	}
+	if len(literals) > 1 && counts[literals[0]] == len(literals) {
+		dst = zstdAppendLiteralsHeader(dst, zstdLiteralsRLE, len(literals))
+		return append(dst, literals[0])
+	}
+
+	if compressed := zstdCompressLiterals(dst, literals, &counts); compressed != nil {
+		return compressed
+	}
+
+	dst = zstdAppendLiteralsHeader(dst, zstdLiteralsRaw, len(literals))
+
+	return append(dst, literals...)
+}
+
+// zstdAppendLiteralsHeader appends the header of a raw or RLE literals section to dst.
+func zstdAppendLiteralsHeader(dst []byte, kind byte, size int) []byte {
+	switch {
+	case size < 32:
+		return append(dst, kind|byte(size)<<3)
+	case size < 4096:
+		return append(dst, kind|1<<2|byte(size)<<4, byte(size>>4))
+	default:
+		return append(dst, kind|3<<2|byte(size)<<4, byte(size>>4), byte(size>>12))
+	}
+}
+
+// zstdCompressLiterals appends a literals section holding literals, coded with Huffman
+// codes, to dst. It returns nil if that would not be smaller than the raw literals.
+func zstdCompressLiterals(dst, literals []byte, counts *[256]int) []byte {
+	if len(literals) < 64 {
+		return nil
+	}
+
+	lengths := zstdHuffmanLengths(counts)
+	last := 255
+	for lengths[last] == 0 {
+		last--
+	}
+
+	maxBits := slices.Max(lengths[:])
+	codes := zstdHuffmanCodes(&lengths, maxBits)
+
+	var sizeBits, header, streams int
+	switch {
+	case len(literals) < 1024:
+		sizeBits, header, streams = 10, 3, 1
+	case len(literals) < 16384:
+		sizeBits, header, streams = 14, 4, 4
+	default:
+		sizeBits, header, streams = 18, 5, 4
+	}
+
+	start := len(dst)
+	dst = append(dst, make([]byte, header)...)
+
+	weights := make([]byte, last)
+	for symbol := range weights {
+		weights[symbol] = zstdHuffmanWeight(lengths[symbol], maxBits)
+	}
+	if dst = zstdAppendHuffmanWeights(dst, weights); dst == nil {
+		return nil
+	}
+
+	if streams == 1 {
+		dst = append(dst, zstdEncodeHuffman(literals, &codes, &lengths)...)
+	} else {
+		jump := len(dst)
+		dst = append(dst, make([]byte, 6)...)
+
+		segment := (len(literals) + 3) / 4
+		for i := range 4 {
+			stream := zstdEncodeHuffman(literals[min(i*segment, len(literals)):min((i+1)*segment, len(literals))],
+				&codes, &lengths)
+			if i < 3 {
+				if len(stream) > 0xFFFF {
+					return nil
+				}
+				binary.LittleEndian.PutUint16(dst[jump+2*i:], uint16(len(stream)))
+			}
+			dst = append(dst, stream...)
+		}
+	}
+
+	compressedSize := len(dst) - start - header
+	if compressedSize >= len(literals) || compressedSize >= 1<<sizeBits {
+		return nil
+	}
+
+	format := uint64(2)
+	switch {
+	case streams == 1:
+		format = 0
+	case sizeBits == 10:
+		format = 1
+	case sizeBits == 18:
+		format = 3
+	}
+	value := zstdLiteralsCompressed | format<<2 | uint64(len(literals))<<4 | uint64(compressedSize)<<(4+sizeBits)
+	for i := range header {
+		dst[start+i] = byte(value >> (8 * i))
+	}
+
+	return dst
+}
+
+// zstdAppendHuffmanWeights appends the description of a Huffman table to dst, which is
+// the weights of every symbol but the last, as the last weight is implied. It returns nil
+// if the weights cannot be described.
+func zstdAppendHuffmanWeights(dst, weights []byte) []byte {
+	if len(weights) <= 128 {
+		// The weights are written directly, in four bits each.
+		dst = append(dst, byte(127+len(weights)))
+		for i := 0; i < len(weights); i += 2 {
+			b := weights[i] << 4
+			if i+1 < len(weights) {
+				b |= weights[i+1]
+			}
+			dst = append(dst, b)
+		}
+
+		return dst
+	}
+
+	// Otherwise, they are compressed with FSE, using two interleaved states.
+	var counts [zstdMaxHuffmanBits + 1]int
+	last, distinct := 0, 0
+	for _, weight := range weights {
+		if counts[weight] == 0 {
+			distinct++
+		}
+		counts[weight]++
+		last = max(last, int(weight))
+	}
+	if distinct < 2 {
+		return nil
+	}
+
+	// No weight is given more than half of the states, so that moving from any state
+	// reads at least one bit, which is how the decoder finds the end of the weights.
+	const log = 6
+	distribution := zstdNormalize(counts[:last+1], len(weights), log, 1<<(log-1))
+	table := newZstdEncodingTable(distribution, log)
+
+	header := len(dst)
+	dst = zstdAppendDistribution(append(dst, 0), distribution, log)
+
+	// The decoder alternates between its states from the first weight, so the weights
+	// are written from last to first, starting with whichever state holds the last.
+	bw := &zstdBitWriter{buf: dst}
+	n := len(weights)
+	var first, second uint32
+	i := n - 3
+	if n%2 == 1 {
+		first, second = table.init(weights[n-1]), table.init(weights[n-2])
+		first = table.encode(bw, first, weights[n-3])
+		i = n - 4
+	} else {
+		first, second = table.init(weights[n-2]), table.init(weights[n-1])
+	}
+	for ; i > 0; i -= 2 {
+		second = table.encode(bw, second, weights[i])
+		first = table.encode(bw, first, weights[i-1])
+	}
+	table.flush(bw, second)
+	table.flush(bw, first)
+
+	dst = bw.close()
+	if size := len(dst) - header - 1; size < 128 {
+		dst[header] = byte(size)
+		return dst
+	}
+
+	return nil
+}
+
+func zstdHuffmanWeight(length, maxBits uint8) byte {
+	if length == 0 {
+		return 0
+	}
+
+	return maxBits + 1 - length
+}
+
+// zstdHuffmanCodes assigns the codes of the given lengths in the order that the decoder
+// expects: by increasing weight, which is by decreasing length, and then by symbol.
+func zstdHuffmanCodes(lengths *[256]uint8, maxBits uint8) [256]uint16 {
+	var starts [zstdMaxHuffmanBits + 2]uint32
+	for _, length := range lengths {
+		if length > 0 {
+			weight := maxBits + 1 - length
+			starts[weight+1] += 1 << (weight - 1)
+		}
+	}
+	for weight := 2; weight < len(starts); weight++ {
+		starts[weight] += starts[weight-1]
+	}
+
+	var codes [256]uint16
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+
+		weight := maxBits + 1 - length
+		codes[symbol] = uint16(starts[weight] >> (maxBits - length))
+		starts[weight] += 1 << (weight - 1)
+	}
+
+	return codes
+}
+
+// zstdEncodeHuffman codes literals as a single Huffman-coded stream.
+func zstdEncodeHuffman(literals []byte, codes *[256]uint16, lengths *[256]uint8) []byte {
+	// The stream is read backwards, so the literals are written from last to first.
+	bw := &zstdBitWriter{buf: make([]byte, 0, len(literals)+8)}
+	for i := len(literals) - 1; i >= 0; i-- {
+		bw.write(uint64(codes[literals[i]]), uint(lengths[literals[i]]))
+	}
+
+	return bw.close()
+}
+
+// zstdHuffmanNode is a node of the tree that zstdHuffmanLengths builds.
+type zstdHuffmanNode struct {
+	count       int
+	symbol      int
+	left, right *zstdHuffmanNode
+}
+
+type zstdHuffmanHeap []*zstdHuffmanNode
+
+func (zhh zstdHuffmanHeap) Len() int           { return len(zhh) }
+func (zhh zstdHuffmanHeap) Less(i, j int) bool { return zhh[i].count < zhh[j].count }
+func (zhh zstdHuffmanHeap) Swap(i, j int)      { zhh[i], zhh[j] = zhh[j], zhh[i] }
+func (zhh *zstdHuffmanHeap) Push(x any)        { *zhh = append(*zhh, x.(*zstdHuffmanNode)) }
+
+func (zhh *zstdHuffmanHeap) Pop() any {
+	old := *zhh
+	node := old[len(old)-1]
+	*zhh = old[:len(old)-1]
+
+	return node
+}
+
+// zstdHuffmanLengths returns the length of the Huffman code of each symbol with the given
+// counts, of which there must be at least two that are not zero, limited to
+// zstdMaxHuffmanBits.
+func zstdHuffmanLengths(counts *[256]int) [256]uint8 {
+	nodes := make(zstdHuffmanHeap, 0, 256)
+	for symbol, count := range counts {
+		if count > 0 {
+			nodes = append(nodes, &zstdHuffmanNode{count: count, symbol: symbol})
+		}
+	}
+	heap.Init(&nodes)
+
+	for nodes.Len() > 1 {
+		left := heap.Pop(&nodes).(*zstdHuffmanNode)
+		right := heap.Pop(&nodes).(*zstdHuffmanNode)
+		heap.Push(&nodes, &zstdHuffmanNode{count: left.count + right.count, symbol: -1, left: left, right: right})
+	}
+
+	var lengths [256]uint8
+	var walk func(node *zstdHuffmanNode, depth int)
+	walk = func(node *zstdHuffmanNode, depth int) {
+		if node.left == nil {
+			lengths[node.symbol] = uint8(min(depth, 255))
+			return
+		}
+
+		walk(node.left, depth+1)
+		walk(node.right, depth+1)
+	}
+	walk(nodes[0], 0)
+
+	zstdLimitLengths(&lengths, counts)
+
+	return lengths
+}
+
+// zstdLimitLengths shortens the codes that are longer than zstdMaxHuffmanBits, and then
+// lengthens the codes of the rarest symbols that are shorter until the code is complete
+// again, as the decoder requires.
+func zstdLimitLengths(lengths *[256]uint8, counts *[256]int) {
+	const limit = zstdMaxHuffmanBits
+
+	// Kraft sums the lengths of the codes, in units of the shortest code that is allowed.
+	kraft := 0
+	for symbol, length := range lengths {
+		if length > limit {
+			lengths[symbol] = limit
+		}
+		if length > 0 {
+			kraft += 1 << (limit - lengths[symbol])
+		}
+	}
+	if kraft == 1<<limit {
+		return
+	}
+
+	symbols := make([]int, 0, 256)
+	for symbol, length := range lengths {
+		if length > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	slices.SortFunc(symbols, func(a, b int) int { return counts[a] - counts[b] })
+
+	// Lengthen the codes of the rarest symbols until the code is no longer too full.
+	for kraft > 1<<limit {
+		for _, symbol := range symbols {
+			if lengths[symbol] < limit {
+				kraft -= 1 << (limit - lengths[symbol] - 1)
+				lengths[symbol]++
+
+				break
+			}
+		}
+	}
+
+	// Then shorten the codes of the most common symbols while the code has room.
+	for i := len(symbols) - 1; i >= 0 && kraft < 1<<limit; {
+		symbol := symbols[i]
+		if gain := 1 << (limit - lengths[symbol]); lengths[symbol] > 1 && kraft+gain <= 1<<limit {
+			kraft += gain
+			lengths[symbol]--
+			continue
+		}
+		i--
+	}
+}
+
+// zstdSequenceCodes is a sequence split into the code of each of its fields, and the
+// extra bits that follow each code.
+type zstdSequenceCodes struct {
+	literalLength, matchLength, offset                uint8
+	literalLengthBits, matchLengthBits, offsetBits    uint8
+	literalLengthExtra, matchLengthExtra, offsetExtra uint32
+}
+
+// zstdAppendSequences appends the sequences section, without its leading count of
+// sequences, holding sequences to dst.
+func zstdAppendSequences(dst []byte, sequences []zstdSequence) []byte {
+	var (
+		coded                                       = make([]zstdSequenceCodes, len(sequences))
+		literalLengths, matchLengths, offsetsCounts = make([]int, len(zstdLiteralLengthCodes)), make([]int, len(zstdMatchLengthCodes)), make([]int, 32)
+	)
+	for i, sequence := range sequences {
+		c := &coded[i]
+
+		c.literalLength = zstdFindCode(zstdLiteralLengthCodes[:], sequence.literalLength)
+		code := zstdLiteralLengthCodes[c.literalLength]
+		c.literalLengthBits, c.literalLengthExtra = code.bits, sequence.literalLength-code.base
+
+		c.matchLength = zstdFindCode(zstdMatchLengthCodes[:], sequence.matchLength)
+		code = zstdMatchLengthCodes[c.matchLength]
+		c.matchLengthBits, c.matchLengthExtra = code.bits, sequence.matchLength-code.base
+
+		c.offset = uint8(bits.Len32(sequence.offsetValue) - 1)
+		c.offsetBits, c.offsetExtra = c.offset, sequence.offsetValue-1<<c.offset
+
+		literalLengths[c.literalLength]++
+		matchLengths[c.matchLength]++
+		offsetsCounts[c.offset]++
+	}
+
+	literalLengthMode, literalLengthTable, literalLengthDescription := zstdChooseTable(literalLengths,
+		zstdLiteralLengthDistribution, zstdLiteralLengthLog, zstdLiteralLengthEncoding, 9)
+	offsetMode, offsetTable, offsetDescription := zstdChooseTable(offsetsCounts,
+		zstdOffsetDistribution, zstdOffsetLog, zstdOffsetEncoding, 8)
+	matchLengthMode, matchLengthTable, matchLengthDescription := zstdChooseTable(matchLengths,
+		zstdMatchLengthDistribution, zstdMatchLengthLog, zstdMatchLengthEncoding, 9)
+
+	dst = append(dst, literalLengthMode<<6|offsetMode<<4|matchLengthMode<<2)
+	dst = append(dst, literalLengthDescription...)
+	dst = append(dst, offsetDescription...)
+	dst = append(dst, matchLengthDescription...)
+
+	// The decoder reads the bitstream backwards, so the sequences are written from last
+	// to first, with the fields of each in the opposite order to how they are read.
+	bw := &zstdBitWriter{buf: dst}
+	writeExtra := func(c zstdSequenceCodes) {
+		bw.write(uint64(c.literalLengthExtra), uint(c.literalLengthBits))
+		bw.write(uint64(c.matchLengthExtra), uint(c.matchLengthBits))
+		bw.write(uint64(c.offsetExtra), uint(c.offsetBits))
+	}
+
+	last := coded[len(coded)-1]
+	matchLengthState := matchLengthTable.init(last.matchLength)
+	offsetState := offsetTable.init(last.offset)
+	literalLengthState := literalLengthTable.init(last.literalLength)
+	writeExtra(last)
+
+	for i := len(coded) - 2; i >= 0; i-- {
+		c := coded[i]
+		offsetState = offsetTable.encode(bw, offsetState, c.offset)
+		matchLengthState = matchLengthTable.encode(bw, matchLengthState, c.matchLength)
+		literalLengthState = literalLengthTable.encode(bw, literalLengthState, c.literalLength)
+		writeExtra(c)
+	}
+
+	matchLengthTable.flush(bw, matchLengthState)
+	offsetTable.flush(bw, offsetState)
+	literalLengthTable.flush(bw, literalLengthState)
+
+	return bw.close()
+}
+
+// zstdChooseTable chooses how to code the symbols with the given counts: with the
+// predefined table, with the only symbol that occurs, or with a table of their own that
+// is described in the block. It returns the mode, the table, and the description of the
+// table to write in the block.
+func zstdChooseTable(counts []int, predefined []int16, predefinedLog uint8, predefinedTable *zstdEncodingTable,
+	maxLog uint8,
+) (byte, *zstdEncodingTable, []byte) {
+	total, distinct, last := 0, 0, 0
+	for symbol, count := range counts {
+		if count > 0 {
+			total += count
+			distinct++
+			last = symbol
+		}
+	}
+
+	if distinct == 1 {
+		distribution := make([]int16, last+1)
+		distribution[last] = 1
+
+		return zstdModeRLE, newZstdEncodingTable(distribution, 0), []byte{byte(last)}
+	}
+
+	predefinedCost, ok := zstdCost(counts, predefined, predefinedLog)
+	if total < 64 && ok {
+		return zstdModePredefined, predefinedTable, nil
+	}
+
+	log := min(uint8(max(bits.Len(uint(total))-2, 5, bits.Len(uint(distinct)))), maxLog)
+	distribution := zstdNormalize(counts[:last+1], total, log, 0)
+	description := zstdAppendDistribution(nil, distribution, log)
+
+	if cost, _ := zstdCost(counts, distribution, log); ok && predefinedCost <= cost+float64(8*len(description)) {
+		return zstdModePredefined, predefinedTable, nil
+	}
+
+	return zstdModeCompressed, newZstdEncodingTable(distribution, log), description
+}
+
+// zstdCost estimates the number of bits that coding symbols with the given counts takes
+// with the given table. It returns false if the table cannot code every symbol.
+func zstdCost(counts []int, distribution []int16, log uint8) (float64, bool) {
+	var cost float64
+	for symbol, count := range counts {
+		if count == 0 {
+			continue
+		}
+		if symbol >= len(distribution) || distribution[symbol] == 0 {
+			return 0, false
+		}
+
+		cost += float64(count) * (float64(log) - math.Log2(float64(max(distribution[symbol], 1))))
+	}
+
+	return cost, true
+}
+
+// zstdNormalize scales counts, which sum to total, to sum to 1<<log instead, giving every
+// symbol that occurs at least one state. If limit is positive, no symbol is given more
+// than limit states.
+func zstdNormalize(counts []int, total int, log uint8, limit int) []int16 {
+	size := 1 << log
+	if limit <= 0 {
+		limit = size
+	}
+
+	distribution := make([]int16, len(counts))
+	sum := 0
+	for symbol, count := range counts {
+		if count > 0 {
+			n := min(max((count*size+total/2)/total, 1), limit)
+			distribution[symbol] = int16(n)
+			sum += n
+		}
+	}
+
+	// Rounding leaves the sum a little off, which is corrected using the most common
+	// symbols, as the relative change to them is the smallest.
+	for sum != size {
+		best := -1
+		for symbol, count := range counts {
+			n := int(distribution[symbol])
+			if count == 0 || (sum > size && n <= 1) || (sum < size && n >= limit) {
+				continue
+			}
+			if best == -1 || count > counts[best] {
+				best = symbol
+			}
+		}
+
+		if sum > size {
+			distribution[best]--
+			sum--
+		} else {
+			distribution[best]++
+			sum++
+		}
+	}
+
+	return distribution
+}
+
+// zstdAppendDistribution appends the description of an FSE table with the given
+// normalised distribution to dst, in the form that zstdReadDistribution reads.
+func zstdAppendDistribution(dst []byte, distribution []int16, log uint8) []byte {
+	bw := &zstdBitWriter{buf: dst}
+	bw.write(uint64(log-5), 4)
+
+	var (
+		remaining = 1<<log + 1
+		threshold = 1 << log
+		nbits     = uint(log) + 1
+		zero      bool
+	)
+	for symbol := 0; symbol < len(distribution) && remaining > 1; {
+		if zero {
+			// A zero is followed by the number of further zeros, in pairs of bits where
+			// 3 means that another pair follows.
+			run := 0
+			for symbol < len(distribution) && distribution[symbol] == 0 {
+				run++
+				symbol++
+			}
+			for ; run >= 3; run -= 3 {
+				bw.write(3, 2)
+			}
+			bw.write(uint64(run), 2)
+		}
+
+		count := int(distribution[symbol])
+		symbol++
+
+		limit := 2*threshold - 1 - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+
+		value := count + 1
+		if value >= threshold {
+			value += limit
+		}
+		if value < limit {
+			bw.write(uint64(value), nbits-1)
+		} else {
+			bw.write(uint64(value), nbits)
+		}
+		zero = count == 0
+
+		for remaining < threshold {
+			threshold >>= 1
+			nbits--
+		}
+	}
+
+	if bw.count > 0 {
+		bw.buf = append(bw.buf, byte(bw.bits))
+	}
+
+	return bw.buf
+}
+
+// zstdFindCode returns the last code whose base is at most value.
+func zstdFindCode(codes []zstdCode, value uint32) uint8 {
+	code := len(codes) - 1
+	for codes[code].base > value {
+		code--
+	}
+
+	return uint8(code)
+}
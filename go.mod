@@ -1,4 +1,4 @@
 module github.com/STBoyden/goutils
 
-go 1.22
+go 1.23
 
@@ -0,0 +1,143 @@
+package netutils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// SplitAddress normalises address into a host and a port that can be passed to DialTCP,
+// DialUDP, or net.JoinHostPort. The following forms of address are accepted:
+//
+//   - a string of the form "host:port", "[ipv6]:port", or ":port";
+//   - an integer port, in which case the host is empty, meaning the local system;
+//   - a *net.TCPAddr or *net.UDPAddr, or their non-pointer forms;
+//   - a netip.AddrPort;
+//   - any other net.Addr, whose String is parsed as "host:port".
+//
+// IPv6 hosts are returned without brackets, and ports are validated to be either in the
+// range 0 to 65535 or a service name such as "http".
+func SplitAddress(address any) (host, port string, err error) {
+	switch address := address.(type) {
+	case string:
+		host, port, err = net.SplitHostPort(address)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid address %q: %w", address, err)
+		}
+	case int:
+		port = strconv.Itoa(address)
+	case uint16:
+		port = strconv.Itoa(int(address))
+	case *net.TCPAddr:
+		if address == nil {
+			return "", "", errors.New("address must not be nil")
+		}
+
+		return splitIPAddress(address.IP, address.Zone, address.Port)
+	case net.TCPAddr:
+		return splitIPAddress(address.IP, address.Zone, address.Port)
+	case *net.UDPAddr:
+		if address == nil {
+			return "", "", errors.New("address must not be nil")
+		}
+
+		return splitIPAddress(address.IP, address.Zone, address.Port)
+	case net.UDPAddr:
+		return splitIPAddress(address.IP, address.Zone, address.Port)
+	case netip.AddrPort:
+		if !address.IsValid() {
+			return "", "", errors.New("invalid netip.AddrPort")
+		}
+
+		return address.Addr().String(), strconv.Itoa(int(address.Port())), nil
+	case net.Addr:
+		return SplitAddress(address.String())
+	case nil:
+		return "", "", errors.New("address must not be nil")
+	default:
+		return "", "", fmt.Errorf("unsupported address type %T", address)
+	}
+
+	return normaliseHostPort(host, port)
+}
+
+// JoinAddress is the inverse of SplitAddress, returning address in the "host:port" form,
+// with IPv6 hosts correctly bracketed.
+func JoinAddress(address any) (string, error) {
+	host, port, err := SplitAddress(address)
+	if err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+func splitIPAddress(ip net.IP, zone string, port int) (string, string, error) {
+	host := ""
+	if ip != nil {
+		host = ip.String()
+		if zone != "" {
+			host += "%" + zone
+		}
+	}
+
+	return normaliseHostPort(host, strconv.Itoa(port))
+}
+
+// normaliseHostPort validates and normalises a host and port given separately. For
+// convenience, a host that is itself of the form "host:port" is split if port is empty,
+// and brackets around IPv6 hosts are removed so that net.JoinHostPort does not double
+// them up.
+func normaliseHostPort(host, port string) (string, string, error) {
+	if port == "" && strings.Contains(host, ":") {
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			host, port = h, p
+		}
+	}
+
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	if strings.ContainsAny(host, "[]") {
+		return "", "", fmt.Errorf("invalid host %q", host)
+	}
+
+	if port == "" {
+		return "", "", fmt.Errorf("missing port for host %q", host)
+	}
+
+	if n, err := strconv.Atoi(port); err == nil {
+		if n < 0 || n > 65535 {
+			return "", "", fmt.Errorf("port %d is out of range", n)
+		}
+	} else if strings.ContainsAny(port, " :/[]") {
+		return "", "", fmt.Errorf("invalid port %q", port)
+	}
+
+	return host, port, nil
+}
+
+// DialTCPAddr is like DialTCP, but takes an address in any of the forms accepted by
+// SplitAddress.
+func DialTCPAddr[T Convertable](address any, opts ...DialOptions) (*TCPTypedConnection[T], error) {
+	host, port, err := SplitAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return DialTCP[T](host, port, opts...)
+}
+
+// DialUDPAddr is like DialUDP, but takes an address in any of the forms accepted by
+// SplitAddress.
+func DialUDPAddr[T Convertable](address any, opts ...DialOptions) (*UDPTypedConnection[T], error) {
+	host, port, err := SplitAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return DialUDP[T](host, port, opts...)
+}
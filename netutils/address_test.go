@@ -0,0 +1,64 @@
+package netutils
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestSplitAddress(t *testing.T) {
+	tests := []struct {
+		address    any
+		host, port string
+	}{
+		{"localhost:8080", "localhost", "8080"},
+		{"[::1]:80", "::1", "80"},
+		{":http", "", "http"},
+		{8080, "", "8080"},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}, "2001:db8::1", "443"},
+		{net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}, "127.0.0.1", "53"},
+		{netip.MustParseAddrPort("[fe80::1%eth0]:22"), "fe80::1%eth0", "22"},
+	}
+
+	for _, test := range tests {
+		host, port, err := SplitAddress(test.address)
+		if err != nil {
+			t.Errorf("SplitAddress(%v): %v", test.address, err)
+			continue
+		}
+
+		if host != test.host || port != test.port {
+			t.Errorf("SplitAddress(%v): expected %q %q, got %q %q", test.address, test.host, test.port, host, port)
+		}
+	}
+
+	for _, address := range []any{"localhost", "[::1]", 70000, -1, "host:99999", nil, 1.5} {
+		if _, _, err := SplitAddress(address); err == nil {
+			t.Errorf("SplitAddress(%v): expected an error", address)
+		}
+	}
+}
+
+func TestNormaliseHostPort(t *testing.T) {
+	tests := []struct {
+		host, port         string
+		wantHost, wantPort string
+	}{
+		{"[::1]", "80", "::1", "80"},
+		{"::1", "80", "::1", "80"},
+		{"example.com:443", "", "example.com", "443"},
+		{"[2001:db8::1]:443", "", "2001:db8::1", "443"},
+	}
+
+	for _, test := range tests {
+		host, port, err := normaliseHostPort(test.host, test.port)
+		if err != nil {
+			t.Errorf("normaliseHostPort(%q, %q): %v", test.host, test.port, err)
+			continue
+		}
+
+		if host != test.wantHost || port != test.wantPort {
+			t.Errorf("normaliseHostPort(%q, %q): expected %q %q, got %q %q", test.host, test.port, test.wantHost, test.wantPort, host, port)
+		}
+	}
+}
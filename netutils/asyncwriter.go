@@ -0,0 +1,335 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+)
+
+// ErrQueueFull is returned from AsyncWriter.Send when the queue is full and the
+// QueuePolicy is QueuePolicyError.
+var ErrQueueFull = errors.New("send queue is full")
+
+// Sender is implemented by every typed connection in this package that sends values of
+// type S as frames, such as *TypedConnection[S] and *TCPTypedConnection[S].
+type Sender[S Convertable] interface {
+	Send(data S) (int, error)
+	Close() error
+
+	writeFrame(frame []byte) (int, error)
+}
+
+// QueuePolicy decides what an AsyncWriter does when its queue is full.
+type QueuePolicy int
+
+const (
+	// QueuePolicyBlock makes Send wait until there is room in the queue.
+	QueuePolicyBlock QueuePolicy = iota
+	// QueuePolicyDropOldest discards the oldest queued value to make room.
+	QueuePolicyDropOldest
+	// QueuePolicyError makes Send fail with ErrQueueFull.
+	QueuePolicyError
+)
+
+func (qp QueuePolicy) String() string {
+	switch qp {
+	case QueuePolicyBlock:
+		return "block"
+	case QueuePolicyDropOldest:
+		return "drop-oldest"
+	case QueuePolicyError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Priority orders the values queued by an AsyncWriter. Values with a higher priority are
+// written before any values with a lower priority, regardless of when they were queued,
+// and values with the same priority are written in the order that they were queued.
+type Priority int
+
+const (
+	// PriorityLow is for bulk data that can wait, such as state snapshots.
+	PriorityLow Priority = -1
+	// PriorityNormal is the priority used by AsyncWriter.Send.
+	PriorityNormal Priority = 0
+	// PriorityHigh is for latency-critical values, such as input events.
+	PriorityHigh Priority = 1
+)
+
+type queuedFrame struct {
+	frame    []byte
+	priority Priority
+}
+
+// AsyncWriterOptions is a struct used by NewAsyncWriter to define certain optional
+// parameters.
+type AsyncWriterOptions struct {
+	// QueueSize is the maximum amount of values waiting to be written.
+	QueueSize int
+
+	// Policy decides what happens when the queue is full. With QueuePolicyDropOldest,
+	// the oldest value of the lowest priority in the queue is discarded; if the value
+	// being sent has a lower priority than everything in the queue, it is discarded
+	// instead.
+	Policy QueuePolicy
+
+	// OnError, if not nil, is called from the background goroutine when a write fails.
+	// After a failed write, the AsyncWriter stops writing and all further calls to Send
+	// return the error.
+	OnError func(err error)
+}
+
+func defaultAsyncWriterOptions() AsyncWriterOptions {
+	return AsyncWriterOptions{
+		QueueSize: 1024,
+		Policy:    QueuePolicyBlock,
+	}
+}
+
+// AsyncWriter queues values and writes them to a connection from a background goroutine,
+// so that a slow peer does not block the caller of Send until the queue fills up. What
+// happens then is decided by the QueuePolicy.
+//
+// Values can be queued with a Priority using SendPriority, so that latency-critical values
+// jump ahead of bulk data. Values are marshalled when they are queued, so marshalling
+// errors are returned from Send directly. An AsyncWriter is safe for concurrent use.
+type AsyncWriter[S Convertable] struct {
+	conn    Sender[S]
+	options AsyncWriterOptions
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []queuedFrame
+	writing bool
+	closed  bool
+	err     error
+	done    chan struct{}
+}
+
+// NewAsyncWriter creates a new *AsyncWriter that writes to conn, and starts its
+// background goroutine.
+//
+// This takes a variadic parameter of type AsyncWriterOptions. If no AsyncWriterOptions
+// are supplied, then the defaults are used. If more than one AsyncWriterOptions are
+// supplied then only the first will be used.
+func NewAsyncWriter[S Convertable](conn Sender[S], opts ...AsyncWriterOptions) *AsyncWriter[S] {
+	options := defaultAsyncWriterOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = 1
+	}
+
+	aw := &AsyncWriter[S]{
+		conn:    conn,
+		options: options,
+		done:    make(chan struct{}),
+	}
+	aw.cond = sync.NewCond(&aw.mu)
+
+	go aw.run()
+
+	return aw
+}
+
+func (aw *AsyncWriter[S]) run() {
+	defer close(aw.done)
+
+	for {
+		aw.mu.Lock()
+		for len(aw.queue) == 0 && !aw.closed {
+			aw.cond.Wait()
+		}
+		if len(aw.queue) == 0 || aw.err != nil {
+			aw.mu.Unlock()
+			return
+		}
+
+		frame := aw.queue[0].frame
+		aw.queue[0] = queuedFrame{}
+		aw.queue = aw.queue[1:]
+		aw.writing = true
+		aw.cond.Broadcast()
+		aw.mu.Unlock()
+
+		_, err := aw.conn.writeFrame(frame)
+
+		aw.mu.Lock()
+		aw.writing = false
+		if err != nil {
+			aw.err = err
+			aw.queue = nil
+		}
+		aw.cond.Broadcast()
+		aw.mu.Unlock()
+
+		if err != nil {
+
+			if aw.options.OnError != nil {
+				aw.options.OnError(err)
+			}
+
+			return
+		}
+	}
+}
+
+// Send marshals value and queues it to be written with PriorityNormal. If the queue is
+// full, the QueuePolicy applies.
+func (aw *AsyncWriter[S]) Send(value S) error {
+	return aw.SendPriority(value, PriorityNormal)
+}
+
+// SendPriority marshals value and queues it to be written ahead of any queued values with
+// a lower priority. If the queue is full, the QueuePolicy applies.
+func (aw *AsyncWriter[S]) SendPriority(value S, priority Priority) error {
+	frame, err := marshalFrame(value)
+	if err != nil {
+		return err
+	}
+
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	for {
+		switch {
+		case aw.err != nil:
+			return aw.err
+		case aw.closed:
+			return net.ErrClosed
+		case len(aw.queue) < aw.options.QueueSize:
+			aw.insert(queuedFrame{frame: frame, priority: priority})
+			aw.cond.Broadcast()
+
+			return nil
+		}
+
+		switch aw.options.Policy {
+		case QueuePolicyDropOldest:
+			if !aw.dropOldest(priority) {
+				return nil
+			}
+		case QueuePolicyError:
+			return ErrQueueFull
+		default:
+			aw.cond.Wait()
+		}
+	}
+}
+
+// insert adds qf to the queue after every value with the same or a higher priority.
+// aw.mu must be held.
+func (aw *AsyncWriter[S]) insert(qf queuedFrame) {
+	i := len(aw.queue)
+	for i > 0 && aw.queue[i-1].priority < qf.priority {
+		i--
+	}
+
+	aw.queue = slices.Insert(aw.queue, i, qf)
+}
+
+// dropOldest discards the oldest value of the lowest priority in the queue, unless that
+// priority is higher than priority, in which case false is returned and nothing is
+// discarded. aw.mu must be held.
+func (aw *AsyncWriter[S]) dropOldest(priority Priority) bool {
+	lowest := aw.queue[len(aw.queue)-1].priority
+	if priority < lowest {
+		return false
+	}
+
+	i := len(aw.queue) - 1
+	for i > 0 && aw.queue[i-1].priority == lowest {
+		i--
+	}
+
+	aw.queue = slices.Delete(aw.queue, i, i+1)
+
+	return true
+}
+
+// Len returns the amount of values waiting to be written.
+func (aw *AsyncWriter[S]) Len() int {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	return len(aw.queue)
+}
+
+// Flush blocks until the queue is empty and the last value taken from it has been
+// written, or ctx is done. If a write fails, its error is returned, and if the writer is
+// closed while values are still queued, net.ErrClosed is returned.
+func (aw *AsyncWriter[S]) Flush(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		aw.mu.Lock()
+		aw.cond.Broadcast()
+		aw.mu.Unlock()
+	})
+	defer stop()
+
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	for {
+		switch {
+		case aw.err != nil:
+			return aw.err
+		case len(aw.queue) == 0 && !aw.writing:
+			return nil
+		case aw.closed && aw.queue == nil:
+			return net.ErrClosed
+		case ctx.Err() != nil:
+			return ctx.Err()
+		}
+
+		aw.cond.Wait()
+	}
+}
+
+// Shutdown stops accepting new values, waits for the values that are already queued to be
+// written, and then closes the underlying connection, so that the last values sent before
+// closing are not lost. If ctx is done first, the remaining values are discarded, the
+// connection is closed, and ctx.Err() is returned. If a write fails, its error is
+// returned.
+func (aw *AsyncWriter[S]) Shutdown(ctx context.Context) error {
+	aw.mu.Lock()
+	aw.closed = true
+	aw.cond.Broadcast()
+	aw.mu.Unlock()
+
+	var err error
+	select {
+	case <-aw.done:
+		aw.mu.Lock()
+		err = aw.err
+		aw.mu.Unlock()
+	case <-ctx.Done():
+		err = ctx.Err()
+
+		aw.mu.Lock()
+		aw.queue = nil
+		aw.cond.Broadcast()
+		aw.mu.Unlock()
+	}
+
+	return errors.Join(err, aw.Close())
+}
+
+// Close stops the background goroutine, discarding any values that have not been written
+// yet, and closes the underlying connection. Use Shutdown to write them first.
+func (aw *AsyncWriter[S]) Close() error {
+	aw.mu.Lock()
+	aw.closed = true
+	aw.queue = nil
+	aw.cond.Broadcast()
+	aw.mu.Unlock()
+
+	err := aw.conn.Close()
+	<-aw.done
+
+	return err
+}
@@ -0,0 +1,114 @@
+package netutils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// gatedSender signals on entered when a write starts, holds it up until a value is sent
+// on gate, and signals on written once it is done.
+type gatedSender struct {
+	*MockConnection[testMessage, testMessage]
+
+	entered, gate, written chan struct{}
+}
+
+func (gs *gatedSender) writeFrame(frame []byte) (int, error) {
+	gs.entered <- struct{}{}
+	<-gs.gate
+	defer func() { gs.written <- struct{}{} }()
+
+	return gs.MockConnection.writeFrame(frame)
+}
+
+func TestAsyncWriterPriority(t *testing.T) {
+	sender := &gatedSender{
+		MockConnection: NewMockConnection[testMessage, testMessage](),
+		entered:        make(chan struct{}),
+		gate:           make(chan struct{}),
+		written:        make(chan struct{}),
+	}
+	writer := NewAsyncWriter[testMessage](sender, AsyncWriterOptions{QueueSize: 4, Policy: QueuePolicyDropOldest})
+
+	// The first value is taken by the background goroutine, which then waits on the gate
+	// while the rest are queued.
+	if err := writer.Send(testMessage{Text: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	<-sender.entered
+
+	sends := []struct {
+		text     string
+		priority Priority
+	}{
+		{"snapshot 1", PriorityLow},
+		{"snapshot 2", PriorityLow},
+		{"chat", PriorityNormal},
+		{"input 1", PriorityHigh},
+		{"input 2", PriorityHigh}, // The queue is full, so "snapshot 1" is dropped.
+		{"snapshot 3", PriorityLow},
+	}
+	for _, send := range sends {
+		if err := writer.SendPriority(testMessage{Text: send.text}, send.priority); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"first", "input 1", "input 2", "chat", "snapshot 3"}
+	for i := range want {
+		if i > 0 {
+			<-sender.entered
+		}
+		sender.gate <- struct{}{}
+		<-sender.written
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := sender.Sent()
+	if len(sent) != len(want) {
+		t.Fatalf("sent %v, want %v", sent, want)
+	}
+	for i, message := range sent {
+		if message.Text != want[i] {
+			t.Fatalf("sent %v, want %v", sent, want)
+		}
+	}
+}
+
+func TestAsyncWriterShutdown(t *testing.T) {
+	mock := NewMockConnection[testMessage, testMessage]()
+	mock.SetDelay(10 * time.Millisecond)
+	writer := NewAsyncWriter[testMessage](mock)
+
+	for _, text := range []string{"one", "two", "three"} {
+		if err := writer.Send(testMessage{Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := writer.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if sent := len(mock.Sent()); sent != 3 {
+		t.Fatalf("flushed %d values, want 3", sent)
+	}
+
+	if err := writer.Send(testMessage{Text: "last"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if sent := mock.Sent(); len(sent) != 4 || sent[3].Text != "last" {
+		t.Fatalf("sent %v, want the last value to be written before closing", sent)
+	}
+	if err := writer.Send(testMessage{Text: "after"}); err == nil {
+		t.Fatal("send after shutdown should fail")
+	}
+}
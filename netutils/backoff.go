@@ -0,0 +1,49 @@
+package netutils
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff describes an exponential backoff with jitter, used for spacing out repeated
+// attempts at an operation such as re-dialling a connection.
+type Backoff struct {
+	// Initial is the delay before the first retry.
+	Initial time.Duration
+	// Max caps the delay between attempts.
+	Max time.Duration
+	// Multiplier is the factor that the delay grows by after each attempt. Values below
+	// 1 are treated as 1.
+	Multiplier float64
+	// Jitter is the fraction, between 0 and 1, of each delay that is randomised, which
+	// keeps many clients from retrying in lockstep.
+	Jitter float64
+}
+
+func defaultBackoff() Backoff {
+	return Backoff{
+		Initial:    100 * time.Millisecond,
+		Max:        30 * time.Second,
+		Multiplier: 2,
+		Jitter:     0.2,
+	}
+}
+
+// Delay returns how long to wait before the given attempt, where attempt 0 is the first
+// retry.
+func (b Backoff) Delay(attempt int) time.Duration {
+	multiplier := math.Max(b.Multiplier, 1)
+	delay := float64(b.Initial) * math.Pow(multiplier, float64(attempt))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if delay > math.MaxInt64 {
+		delay = math.MaxInt64
+	}
+
+	jitter := math.Min(math.Max(b.Jitter, 0), 1)
+	delay -= delay * jitter * rand.Float64()
+
+	return time.Duration(delay)
+}
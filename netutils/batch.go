@@ -0,0 +1,84 @@
+package netutils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// WriteBatch marshals all of the given values and writes them to the connection in a
+// single operation, using vectored I/O (writev) where the platform supports it. Each
+// value is written as its own frame in the same format as Send, so the receiving side
+// reads them one at a time using Receive. This is useful for bursts of messages, such as
+// a full state snapshot, as it saves a system call per message. As with Send, payloads
+// are not copied behind their length prefixes.
+//
+// Each value passes through the write interceptors, see UseWrite, as it is added to the
+// batch, and the amount of bytes that the interceptors see is the size of its frame. If
+// any value fails to marshal or is rejected by an interceptor, nothing is written. On
+// success, it returns the total amount of bytes that were written, including the length
+// prefixes. On failure, it returns an error.
+func (tc *AsymmetricTypedConnection[S, R]) WriteBatch(values []S) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	buffers := make(net.Buffers, 0, 2*len(values))
+	total := 0
+
+	add := tc.interceptWrite(func(value S) (int, error) {
+		var (
+			size int
+			err  error
+		)
+
+		buffers, size, err = appendFrameBuffers(buffers, value)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+
+		return size, nil
+	})
+
+	for i, value := range values {
+		if _, err := add(value); err != nil {
+			return 0, errors.Join(fmt.Errorf("could not add value %d to batch", i), err)
+		}
+	}
+
+	n, err := tc.writeBuffers(buffers, total)
+	tc.recordStats(writeDirection, len(values), int(n), err)
+
+	return n, err
+}
+
+// writeBuffers writes buffers to the connection in a single vectored write, applying the
+// write limit and recording activity for the idle timeout.
+func (tc *AsymmetricTypedConnection[S, R]) writeBuffers(buffers net.Buffers, total int) (int64, error) {
+	tc.waitWrite(total)
+
+	// WriteTo consumes buffers, and a frame may be split across several of them, so
+	// keep hold of a joined copy for the dump.
+	var frames []byte
+	if tc.dumper() != nil {
+		frames = bytes.Join(buffers, nil)
+	}
+
+	// Connections that are not backed by a socket, such as TLS connections and pipes,
+	// write each buffer separately, so the lock keeps the frame in one piece.
+	unlock := tc.lockWrite()
+	n, err := buffers.WriteTo(tc.conn)
+	unlock()
+	if err != nil {
+		return n, tc.wrapError(err)
+	}
+	tc.touch()
+
+	if frames != nil {
+		tc.dumpFrames(writeDirection, frames)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,116 @@
+package netutils
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// batchMessage fails to marshal if Fail is set.
+type batchMessage struct {
+	Text string `json:"text"`
+	Fail bool   `json:"fail"`
+}
+
+func (bm batchMessage) String() string { return bm.Text }
+
+func (bm batchMessage) Marshal() ([]byte, error) {
+	if bm.Fail {
+		return nil, errors.New("marshal failed")
+	}
+
+	return json.Marshal(bm)
+}
+
+func (batchMessage) Unmarshal(v any, data []byte) error { return json.Unmarshal(data, v) }
+
+func TestWriteBatch(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	client := NewTypedConnection[batchMessage](a, ConnectionTypeTCP)
+	server := NewTypedConnection[batchMessage](b, ConnectionTypeTCP)
+
+	if n, err := client.WriteBatch(nil); n != 0 || err != nil {
+		t.Errorf("empty batch should write nothing, got %d, %v", n, err)
+	}
+
+	// Nothing is written if any value fails to marshal.
+	if _, err := client.WriteBatch([]batchMessage{{Text: "a"}, {Fail: true}}); err == nil {
+		t.Error("batch with a value that fails to marshal should fail")
+	}
+
+	values := []batchMessage{{Text: "a"}, {Text: "b"}, {Text: "c"}}
+	written := make(chan int64, 1)
+	go func() {
+		n, err := client.WriteBatch(values)
+		if err != nil {
+			t.Error(err)
+		}
+		written <- n
+	}()
+
+	var total int64
+	for _, expected := range values {
+		var message batchMessage
+		n, err := server.Receive(&message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message.Text != expected.Text {
+			t.Errorf("batch should be received in order, expected %q, got %q", expected.Text, message.Text)
+		}
+		total += int64(frameHeaderSize + n)
+	}
+
+	if n := <-written; n != total {
+		t.Errorf("expected %d bytes to be written including the length prefixes, got %d", total, n)
+	}
+	if stats := client.Stats(); stats.MessagesWritten != uint64(len(values)) || stats.BytesWritten != uint64(total) {
+		t.Errorf("expected %d messages and %d bytes to be recorded, got %+v", len(values), total, stats)
+	}
+}
+
+func TestConcurrentSendsAreNotInterleaved(t *testing.T) {
+	// Pipes write each buffer of a frame separately, unlike sockets.
+	client, server := Pipe[testMessage]()
+
+	const senders, messages = 4, 50
+
+	// Closing the connections unblocks the senders if the test fails early.
+	var wg sync.WaitGroup
+	defer func() {
+		_ = client.Close()
+		_ = server.Close()
+		wg.Wait()
+	}()
+
+	for i := range senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			text := strings.Repeat(string(rune('a'+i)), 100)
+			for range messages {
+				// A failed send shows up as a missing message on the other side.
+				if _, err := client.Send(testMessage{Text: text}); err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	for range senders * messages {
+		var message testMessage
+		if _, err := server.Receive(&message); err != nil {
+			t.Fatal(err)
+		}
+		if len(message.Text) != 100 || strings.Trim(message.Text, message.Text[:1]) != "" {
+			t.Fatalf("received a corrupted message %q", message.Text)
+		}
+	}
+}
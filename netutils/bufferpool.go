@@ -0,0 +1,149 @@
+package netutils
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// BufferPool provides the buffers that a connection reads frames into and marshals frames
+// into, allowing them to be reused between messages instead of being allocated for every
+// call. See AsymmetricTypedConnection.SetBufferPool.
+type BufferPool interface {
+	// Get returns a buffer with a length of size. Its contents are undefined.
+	Get(size int) []byte
+	// Put returns a buffer obtained from Get to the pool. The buffer must not be used
+	// afterwards.
+	Put(buffer []byte)
+}
+
+const (
+	minPooledBufferShift = 8
+	maxPooledBufferShift = 20
+
+	// pooledFrameSize is the initial size of the buffer that Send marshals into when
+	// pooling is enabled. The size of a value is not known until it has been marshalled,
+	// so later sends use the size of the largest frame sent so far, up to the size of the
+	// largest pooled buffer, so that a single large frame does not stop the buffers of
+	// every later one from being pooled.
+	pooledFrameSize = 4 << 10
+)
+
+// sizedBufferPool is a BufferPool backed by a sync.Pool per power-of-two size class.
+// Buffers larger than 1 MiB are not pooled.
+type sizedBufferPool struct {
+	classes [maxPooledBufferShift - minPooledBufferShift + 1]sync.Pool
+}
+
+// NewBufferPool creates a new BufferPool backed by sync.Pools, which keeps buffers of up
+// to 1 MiB in power-of-two size classes. Larger buffers are allocated as normal.
+func NewBufferPool() BufferPool {
+	return &sizedBufferPool{}
+}
+
+// DefaultBufferPool is a BufferPool that can be shared between connections.
+var DefaultBufferPool = NewBufferPool()
+
+// sizeClass returns the index of the smallest class that fits size, or -1 if size is too
+// large to be pooled.
+func sizeClass(size int) int {
+	if size <= 1<<minPooledBufferShift {
+		return 0
+	}
+
+	shift := bits.Len(uint(size - 1))
+	if shift > maxPooledBufferShift {
+		return -1
+	}
+
+	return shift - minPooledBufferShift
+}
+
+func (sbp *sizedBufferPool) Get(size int) []byte {
+	class := sizeClass(size)
+	if class < 0 {
+		return make([]byte, size)
+	}
+
+	if buffer, ok := sbp.classes[class].Get().(*[]byte); ok {
+		return (*buffer)[:size]
+	}
+
+	return make([]byte, size, 1<<(class+minPooledBufferShift))
+}
+
+func (sbp *sizedBufferPool) Put(buffer []byte) {
+	capacity := cap(buffer)
+	if capacity < 1<<minPooledBufferShift {
+		return
+	}
+
+	// A buffer is filed under the largest class that it can fully serve, so that Get can
+	// hand it out for any size within that class.
+	class := bits.Len(uint(capacity)) - 1 - minPooledBufferShift
+	if class >= len(sbp.classes) {
+		return
+	}
+
+	buffer = buffer[:0]
+	sbp.classes[class].Put(&buffer)
+}
+
+// SetBufferPool makes the connection take the buffers for the frames it reads from pool,
+// as well as the buffers that values implementing AppendMarshaler are marshalled into
+// when they are sent. This reduces allocations and garbage collector pressure on
+// connections with a high message rate. Passing nil, the default, allocates a new buffer
+// for every call.
+//
+// Buffers are returned to the pool once Unmarshal returns, so this must only be enabled
+// if the Unmarshal method of R does not keep a reference to the data it is given.
+func (tc *AsymmetricTypedConnection[S, R]) SetBufferPool(pool BufferPool) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	tc.state.bufferPool = pool
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) bufferPool() BufferPool {
+	if tc.state == nil {
+		return nil
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	return tc.state.bufferPool
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) sendSizeHint() int {
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	return max(tc.state.sendSize, pooledFrameSize)
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) setSendSizeHint(size int) {
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	tc.state.sendSize = min(max(tc.state.sendSize, size), 1<<maxPooledBufferShift)
+}
+
+// getBuffer returns a buffer of the given size from pool, or a new one if pool is nil.
+func getBuffer(pool BufferPool, size int) []byte {
+	if pool == nil {
+		return make([]byte, size)
+	}
+
+	return pool.Get(size)
+}
+
+// putBuffer returns buffer to pool, if pool is not nil.
+func putBuffer(pool BufferPool, buffer []byte) {
+	if pool != nil {
+		pool.Put(buffer)
+	}
+}
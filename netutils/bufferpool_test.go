@@ -0,0 +1,103 @@
+package netutils
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// loopConn is a net.Conn that endlessly reads the same data and discards all writes.
+type loopConn struct {
+	net.Conn
+
+	data   []byte
+	reader bytes.Reader
+}
+
+func (lc *loopConn) Read(p []byte) (int, error) {
+	if lc.reader.Len() == 0 {
+		lc.reader.Reset(lc.data)
+	}
+
+	return lc.reader.Read(p)
+}
+
+func (*loopConn) Write(p []byte) (int, error) { return len(p), nil }
+
+func benchmarkReceive(b *testing.B, pool BufferPool) {
+	frame, err := marshalFrame(testMessage{Text: benchmarkText})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	conn := NewTypedConnection[testMessage](&loopConn{data: frame}, ConnectionTypeTCP)
+	conn.SetBufferPool(pool)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(frame)))
+
+	for range b.N {
+		var message testMessage
+		if _, err := conn.Receive(&message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReceive(b *testing.B)       { benchmarkReceive(b, nil) }
+func BenchmarkReceivePooled(b *testing.B) { benchmarkReceive(b, NewBufferPool()) }
+
+func benchmarkSend[T Convertable](b *testing.B, message T, pool BufferPool) {
+	conn := NewTypedConnection[T](&loopConn{}, ConnectionTypeTCP)
+	conn.SetBufferPool(pool)
+
+	b.ReportAllocs()
+
+	for range b.N {
+		if _, err := conn.Send(message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+var benchmarkText = string(bytes.Repeat([]byte("x"), 4096))
+
+func BenchmarkSend(b *testing.B) { benchmarkSend(b, testMessage{Text: benchmarkText}, nil) }
+
+func BenchmarkSendAppend(b *testing.B) {
+	benchmarkSend(b, appendMessage{Text: benchmarkText}, nil)
+}
+
+func BenchmarkSendAppendPooled(b *testing.B) {
+	benchmarkSend(b, appendMessage{Text: benchmarkText}, NewBufferPool())
+}
+
+func TestBufferPoolSizes(t *testing.T) {
+	pool := NewBufferPool()
+
+	for _, size := range []int{0, 1, 256, 257, 4096, 1 << 20, 1<<20 + 1} {
+		buffer := pool.Get(size)
+		if len(buffer) != size {
+			t.Errorf("Get(%d) returned a buffer of length %d", size, len(buffer))
+		}
+		pool.Put(buffer)
+	}
+}
+
+func TestSendSizeHintIsCapped(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer client.Close()
+	defer server.Close()
+
+	client.SetBufferPool(NewBufferPool())
+
+	// A frame larger than any pooled buffer only raises the hint as far as the largest
+	// pooled size, so that later frames can still be pooled.
+	client.setSendSizeHint(4 << 20)
+	if hint := client.sendSizeHint(); hint != 1<<maxPooledBufferShift {
+		t.Errorf("send size hint should be capped at %d, got %d", 1<<maxPooledBufferShift, hint)
+	}
+	if sizeClass(client.sendSizeHint()) < 0 {
+		t.Error("send size hint should fit a pooled size class")
+	}
+}
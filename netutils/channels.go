@@ -0,0 +1,170 @@
+package netutils
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Duplex is implemented by every typed connection in this package that sends values of
+// type S and receives values of type R as frames, such as *TypedConnection[T] (with
+// S = R = T) and *AsymmetricTypedConnection[S, R].
+type Duplex[S, R Convertable] interface {
+	Sender[S]
+	Receiver[R]
+}
+
+// ChannelAdapterOptions is a struct used by NewChannelAdapter to define certain optional
+// parameters.
+type ChannelAdapterOptions struct {
+	// SendBuffer and RecvBuffer are the buffer sizes of the Send and Recv channels.
+	SendBuffer int
+	RecvBuffer int
+
+	// ReadOptions are passed to every Receive on the underlying connection.
+	ReadOptions ReadOptions
+}
+
+func defaultChannelAdapterOptions() ChannelAdapterOptions {
+	return ChannelAdapterOptions{
+		SendBuffer:  16,
+		RecvBuffer:  16,
+		ReadOptions: defaultReadOptions(),
+	}
+}
+
+// ChannelAdapter turns a typed connection into a pair of channels, for use in
+// select-based code. Values sent on Send are written to the connection, and values
+// received from the connection are delivered on Recv. Both directions are pumped by
+// background goroutines which are managed by the adapter.
+//
+// Recv is closed once the connection can no longer be read from. The first error hit by
+// either pump is delivered on Errors, after which the adapter shuts down. A clean close
+// by the peer is not reported as an error.
+//
+// Send is never closed by the adapter, as it is owned by the caller; code sending on it
+// should also select on Done to avoid blocking forever once the adapter has shut down.
+// Closing Send stops the writing pump, but leaves the receiving side running.
+type ChannelAdapter[S, R Convertable] struct {
+	Send   chan<- S
+	Recv   <-chan R
+	Errors <-chan error
+
+	conn   Duplex[S, R]
+	send   chan S
+	errors chan error
+	done   chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+}
+
+// NewChannelAdapter creates a new *ChannelAdapter over conn and starts its pumps.
+//
+// This takes a variadic parameter of type ChannelAdapterOptions. If no
+// ChannelAdapterOptions are supplied, then the defaults are used. If more than one
+// ChannelAdapterOptions are supplied then only the first will be used.
+func NewChannelAdapter[S, R Convertable](conn Duplex[S, R], opts ...ChannelAdapterOptions) *ChannelAdapter[S, R] {
+	options := defaultChannelAdapterOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	send := make(chan S, max(options.SendBuffer, 0))
+	recv := make(chan R, max(options.RecvBuffer, 0))
+	errs := make(chan error, 1)
+
+	ca := &ChannelAdapter[S, R]{
+		Send:   send,
+		Recv:   recv,
+		Errors: errs,
+		conn:   conn,
+		send:   send,
+		errors: errs,
+		done:   make(chan struct{}),
+	}
+
+	ca.wg.Add(2)
+	go ca.pumpSend()
+	go ca.pumpRecv(recv, options.ReadOptions)
+
+	return ca
+}
+
+func (ca *ChannelAdapter[S, R]) fail(err error) {
+	select {
+	case ca.errors <- err:
+	default:
+	}
+
+	ca.shutdown()
+}
+
+func (ca *ChannelAdapter[S, R]) shutdown() {
+	ca.once.Do(func() {
+		close(ca.done)
+		_ = ca.conn.Close()
+	})
+}
+
+func (ca *ChannelAdapter[S, R]) pumpSend() {
+	defer ca.wg.Done()
+
+	for {
+		select {
+		case <-ca.done:
+			return
+		case value, ok := <-ca.send:
+			if !ok {
+				return
+			}
+
+			if _, err := ca.conn.Send(value); err != nil {
+				ca.fail(err)
+				return
+			}
+		}
+	}
+}
+
+func (ca *ChannelAdapter[S, R]) pumpRecv(recv chan R, readOptions ReadOptions) {
+	defer ca.wg.Done()
+	defer close(recv)
+
+	for {
+		var value R
+		if _, err := ca.conn.Receive(&value, WithReadOptions(readOptions)); err != nil {
+			select {
+			case <-ca.done:
+				// The adapter was closed, so the error is most likely a result of that.
+			default:
+				if errors.Is(err, io.EOF) {
+					ca.shutdown()
+				} else {
+					ca.fail(err)
+				}
+			}
+
+			return
+		}
+
+		select {
+		case recv <- value:
+		case <-ca.done:
+			return
+		}
+	}
+}
+
+// Done returns a channel that is closed once the adapter has shut down.
+func (ca *ChannelAdapter[S, R]) Done() <-chan struct{} {
+	return ca.done
+}
+
+// Close shuts down the adapter, closes the underlying connection, and waits for both
+// pumps to exit. Values still buffered in Send are discarded.
+func (ca *ChannelAdapter[S, R]) Close() error {
+	ca.shutdown()
+	ca.wg.Wait()
+
+	return nil
+}
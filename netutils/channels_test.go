@@ -0,0 +1,97 @@
+package netutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestChannelAdapter(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer server.Close()
+
+	adapter := NewChannelAdapter[testMessage, testMessage](client)
+	defer adapter.Close()
+
+	adapter.Send <- testMessage{Text: "ping"}
+
+	var message testMessage
+	if _, err := server.Receive(&message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Text != "ping" {
+		t.Errorf("expected ping, got %q", message.Text)
+	}
+
+	if _, err := server.Send(testMessage{Text: "pong"}); err != nil {
+		t.Fatal(err)
+	}
+	if message := <-adapter.Recv; message.Text != "pong" {
+		t.Errorf("expected pong, got %q", message.Text)
+	}
+
+	// A clean close by the peer shuts the adapter down without an error.
+	_ = server.Close()
+	select {
+	case <-adapter.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("adapter should shut down once the peer closes")
+	}
+	if _, ok := <-adapter.Recv; ok {
+		t.Error("Recv should be closed after shutting down")
+	}
+	select {
+	case err := <-adapter.Errors:
+		t.Errorf("a clean close should not be reported, got %v", err)
+	default:
+	}
+}
+
+func TestChannelAdapterError(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	client := NewTypedConnection[testMessage](a, ConnectionTypeTCP)
+	adapter := NewChannelAdapter[testMessage, testMessage](&client)
+	defer adapter.Close()
+
+	// A frame that does not unmarshal into a testMessage.
+	frame := binary.BigEndian.AppendUint32(nil, 1)
+	if _, err := b.Write(append(frame, '{')); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-adapter.Errors:
+		if !errors.Is(err, ErrUnmarshal) {
+			t.Errorf("expected ErrUnmarshal, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read error should be reported")
+	}
+	<-adapter.Done()
+}
+
+func TestChannelAdapterClose(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer server.Close()
+
+	adapter := NewChannelAdapter[testMessage, testMessage](client)
+	if err := adapter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-adapter.Done():
+	default:
+		t.Error("Done should be closed after Close")
+	}
+	if _, ok := <-adapter.Recv; ok {
+		t.Error("Recv should be closed after Close")
+	}
+	if _, err := client.Send(testMessage{Text: "hello"}); err == nil {
+		t.Error("closing the adapter should close the connection")
+	}
+}
@@ -0,0 +1,176 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// rememberDeadline records t as the deadline set by the user for dir, so that it can be
+// restored after a context-bound operation.
+func (tc *AsymmetricTypedConnection[S, R]) rememberDeadline(dir direction, t time.Time) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	if dir == readDirection {
+		tc.state.readDeadline = t
+	} else {
+		tc.state.writeDeadline = t
+	}
+}
+
+// userDeadline returns the deadline set by the user for dir, or the zero time if there is
+// none.
+func (tc *AsymmetricTypedConnection[S, R]) userDeadline(dir direction) time.Time {
+	if tc.state == nil {
+		return time.Time{}
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	if dir == readDirection {
+		return tc.state.readDeadline
+	}
+
+	return tc.state.writeDeadline
+}
+
+// withContext runs operation with the deadline of ctx applied to the dir side of the
+// connection, and interrupts it if ctx is done. The deadline set by the user is kept if
+// it is earlier, and is restored once operation returns.
+func (tc *AsymmetricTypedConnection[S, R]) withContext(ctx context.Context, dir direction, operation func() (int, error)) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	setDeadline := tc.conn.SetWriteDeadline
+	var (
+		previous time.Time
+		lock     *sync.Mutex
+	)
+
+	if tc.state != nil {
+		lock = &tc.state.writeCtxMu
+		if dir == readDirection {
+			lock = &tc.state.readCtxMu
+		}
+		lock.Lock()
+		defer lock.Unlock()
+
+		previous = tc.userDeadline(dir)
+	}
+	if dir == readDirection {
+		setDeadline = tc.conn.SetReadDeadline
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline && (previous.IsZero() || deadline.Before(previous)) {
+		if err := setDeadline(deadline); err != nil {
+			return 0, err
+		}
+		defer func() { _ = setDeadline(previous) }()
+	}
+
+	if ctx.Done() != nil {
+		interrupted := make(chan struct{})
+		stop := context.AfterFunc(ctx, func() {
+			defer close(interrupted)
+			_ = setDeadline(time.Unix(1, 0))
+		})
+		defer func() {
+			if !stop() {
+				<-interrupted
+				_ = setDeadline(previous)
+			}
+		}()
+	}
+
+	n, err := operation()
+	if err != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = errors.Join(ctxErr, err)
+		} else if hasDeadline && !time.Now().Before(deadline) {
+			// The connection's deadline can pass just before the context's timer fires.
+			err = errors.Join(context.DeadlineExceeded, err)
+		}
+	}
+
+	return n, err
+}
+
+// withReadTimeout runs operation bounded by ctx and timeout, if either of them can end
+// it, and directly otherwise.
+func (tc *AsymmetricTypedConnection[S, R]) withReadTimeout(ctx context.Context, timeout time.Duration, operation func() (int, error)) (int, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if ctx.Done() == nil {
+		return operation()
+	}
+
+	return tc.withContext(ctx, readDirection, operation)
+}
+
+// ReadContext is like Read, but the deadline of ctx is applied to the read, and it is
+// interrupted if ctx is done, in which case the returned error wraps ctx.Err(). Any read
+// deadline set with SetReadDeadline still applies if it is earlier, and is restored
+// afterwards, so timeouts from both compose.
+//
+// Context-bound reads are serialised with each other, but not with plain reads, whose
+// deadlines they would affect.
+func (tc *AsymmetricTypedConnection[S, R]) ReadContext(ctx context.Context, data *R, opts ...ReadOption) (int, error) {
+	options, err := tc.readOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return tc.withReadTimeout(ctx, options.Timeout, func() (int, error) {
+		return tc.observe(ctx, "read", readDirection, func() (int, error) {
+			return tc.interceptRead(func(data *R) (int, error) { return tc.read(data, options) })(data)
+		})
+	})
+}
+
+// ReceiveContext is like Receive, with the deadline of ctx applied as for ReadContext. If
+// ctx interrupts a frame partway through, the connection can no longer be read from and
+// should be closed.
+func (tc *AsymmetricTypedConnection[S, R]) ReceiveContext(ctx context.Context, data *R, opts ...ReadOption) (int, error) {
+	options, err := tc.readOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return tc.withReadTimeout(ctx, options.Timeout, func() (int, error) {
+		return tc.observe(ctx, "receive", readDirection, func() (int, error) {
+			return tc.interceptRead(func(data *R) (int, error) { return tc.receive(data, options) })(data)
+		})
+	})
+}
+
+// WriteContext is like Write, but the deadline of ctx is applied to the write, and it is
+// interrupted if ctx is done, in which case the returned error wraps ctx.Err(). Any write
+// deadline set with SetWriteDeadline still applies if it is earlier, and is restored
+// afterwards, so timeouts from both compose.
+//
+// Context-bound writes are serialised with each other, but not with plain writes, whose
+// deadlines they would affect.
+func (tc *AsymmetricTypedConnection[S, R]) WriteContext(ctx context.Context, data S) (int, error) {
+	return tc.withContext(ctx, writeDirection, func() (int, error) { return tc.writeContext(ctx, data) })
+}
+
+// SendContext is like Send, with the deadline of ctx applied as for WriteContext. If ctx
+// interrupts a frame partway through, the peer can no longer read from the connection
+// and it should be closed.
+func (tc *AsymmetricTypedConnection[S, R]) SendContext(ctx context.Context, data S) (int, error) {
+	return tc.withContext(ctx, writeDirection, func() (int, error) { return tc.sendContext(ctx, data) })
+}
@@ -0,0 +1,101 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReceiveContext(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	conn := NewTypedConnection[testMessage](a, ConnectionTypeTCP)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var message testMessage
+	if _, err := conn.ReceiveContext(ctx, &message); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := conn.ReceiveContext(cancelled, &message); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	// The deadlines of the contexts must not outlive the calls.
+	go func() {
+		peer := NewTypedConnection[testMessage](b, ConnectionTypeTCP)
+		time.Sleep(50 * time.Millisecond)
+		_, _ = peer.Send(testMessage{Text: "late"})
+	}()
+	if _, err := conn.Receive(&message); err != nil || message.Text != "late" {
+		t.Fatalf("received %v, %v", message, err)
+	}
+
+	// An earlier deadline set on the connection still applies, and is kept afterwards.
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ReceiveContext(context.Background(), &message); errors.Is(err, context.DeadlineExceeded) || err == nil {
+		t.Fatalf("expected the connection's own deadline to be hit, got %v", err)
+	}
+}
+
+func TestReadOptions(t *testing.T) {
+	if _, err := NewReadOptions(WithBufferSize(0), WithMaxSize(-1)); err == nil {
+		t.Fatal("expected invalid sizes to be rejected")
+	}
+	if err := (ReadOptions{MaxFrameSize: 8}).Validate(); err == nil {
+		t.Fatal("expected zero sizes to be rejected")
+	}
+
+	options, err := NewReadOptions(WithMaxSize(8), WithTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := net.Pipe()
+	defer b.Close()
+
+	conn := NewTypedConnection[testMessage](a, ConnectionTypeTCP)
+	defer conn.Close()
+
+	if err := conn.SetReadOptions(options); err != nil {
+		t.Fatal(err)
+	}
+
+	// The connection's timeout applies without any ReadOptions being passed.
+	var message testMessage
+	if _, err := conn.Receive(&message); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	if _, err := conn.Receive(&message, WithTimeout(-time.Second)); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the invalid timeout to be rejected before reading, got %v", err)
+	}
+
+	go func() {
+		peer := NewTypedConnection[testMessage](b, ConnectionTypeTCP)
+		_, _ = peer.Send(testMessage{Text: "longer than eight bytes"})
+		_, _ = peer.Send(testMessage{Text: "longer than eight bytes"})
+	}()
+
+	// Options that are passed to a read override the connection's, which it otherwise
+	// keeps; zero fields of WithReadOptions are left alone.
+	if _, err := conn.Receive(&message, WithReadOptions(ReadOptions{MaxFrameSize: 1024})); err != nil {
+		t.Fatal(err)
+	}
+	if message.Text != "longer than eight bytes" {
+		t.Errorf("unexpected message %q", message.Text)
+	}
+	if _, err := conn.Receive(&message); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected %v, got %v", ErrFrameTooLarge, err)
+	}
+}
@@ -0,0 +1,200 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// DialOptions is a struct used by the Dial functions to define certain optional
+// parameters.
+type DialOptions struct {
+	// Timeout is the maximum amount of time a dial will wait for a connection to be
+	// established. A zero Timeout means that there is no timeout, although the operating
+	// system may impose its own.
+	Timeout time.Duration
+
+	// KeepAlive is the period between TCP keep-alive probes. A zero KeepAlive uses the
+	// default of the net package, and a negative KeepAlive disables keep-alive probes.
+	// It is ignored for UDP.
+	KeepAlive time.Duration
+
+	// LocalAddress is the local address to bind to when dialing, either as "host:port"
+	// or as a bare host, in which case the port is chosen by the operating system. An
+	// empty LocalAddress lets the operating system choose.
+	LocalAddress string
+
+	// ConnectionAttemptDelay enables Happy Eyeballs for TCP dials, as described by RFC
+	// 8305: when a host resolves to several addresses, such as both IPv4 and IPv6 ones,
+	// connection attempts are raced starting this far apart, and whichever connects first
+	// is used. This keeps dials from hanging on a broken IPv6 path. A zero or negative
+	// ConnectionAttemptDelay leaves the choice of address to the net package.
+	ConnectionAttemptDelay time.Duration
+
+	// Dialer, if not nil, is a pre-configured *net.Dialer that is used instead of one
+	// built from KeepAlive and LocalAddress, which are then ignored. This
+	// allows for settings such as a custom Resolver or Control function.
+	Dialer *net.Dialer
+
+	// Resolver, if not nil, is used to look up the addresses of host names, instead of
+	// the resolver of the net package. This allows for a custom *net.Resolver, for
+	// lookups to be cached across dials with a shared *CachingResolver, or for them to be
+	// overridden in tests with a ResolverFunc. The resolved addresses are tried in turn,
+	// or raced if ConnectionAttemptDelay is set, and are also passed to DialFunc.
+	Resolver Resolver
+
+	// DialFunc, if not nil, is used to open connections instead of a *net.Dialer, which
+	// allows for custom routing, in-memory test fakes, or platform-specific sockets. It
+	// takes precedence over Dialer. Timeout is applied to the context it is given.
+	DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// Logger, if not nil, is used to log the dial and is attached to the resulting
+	// connection. See AsymmetricTypedConnection.SetLogger.
+	Logger *slog.Logger
+}
+
+func defaultDialOptions() DialOptions {
+	return DialOptions{
+		Timeout:                30 * time.Second,
+		ConnectionAttemptDelay: DefaultConnectionAttemptDelay,
+	}
+}
+
+// dialer builds a *net.Dialer for the given network from the options.
+func (do DialOptions) dialer(network string) (*net.Dialer, error) {
+	dialer := &net.Dialer{
+		Timeout:   do.Timeout,
+		KeepAlive: do.KeepAlive,
+	}
+
+	if do.LocalAddress != "" {
+		address := do.LocalAddress
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, "0")
+		}
+
+		var err error
+		switch network {
+		case "tcp", "tcp4", "tcp6":
+			dialer.LocalAddr, err = net.ResolveTCPAddr(network, address)
+		case "udp", "udp4", "udp6":
+			dialer.LocalAddr, err = net.ResolveUDPAddr(network, address)
+		default:
+			err = fmt.Errorf("unsupported network %q", network)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve local address %q: %w", do.LocalAddress, err)
+		}
+	}
+
+	return dialer, nil
+}
+
+// dial connects to host:port on the given network using the first of opts, or the
+// defaults if none are given. host and port are normalised first, see
+// normaliseHostPort.
+func dial(network, host, port string, opts []DialOptions) (net.Conn, error) {
+	options := defaultDialOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	host, port, err := normaliseHostPort(host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	address := net.JoinHostPort(host, port)
+	conn, err := options.dial(network, host, port)
+	logDial(options.Logger, network, address, conn, err)
+
+	return conn, err
+}
+
+// dial connects to host:port using the DialFunc, Dialer, or dialer built from the
+// options.
+func (do DialOptions) dial(network, host, port string) (net.Conn, error) {
+	ctx := context.Background()
+	if do.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, do.Timeout)
+		defer cancel()
+	}
+
+	resolver := do.Resolver
+	dial := dialContextFunc(do.DialFunc)
+	if dial == nil {
+		dialer := do.Dialer
+		if dialer == nil {
+			var err error
+			if dialer, err = do.dialer(network); err != nil {
+				return nil, err
+			}
+		}
+
+		dial = dialer.DialContext
+		if resolver == nil && dialer.Resolver != nil {
+			resolver = dialer.Resolver
+		}
+	} else if resolver == nil {
+		// DialFunc is given the host name to resolve itself.
+		return dial(ctx, network, net.JoinHostPort(host, port))
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		if do.ConnectionAttemptDelay > 0 {
+			if resolver == nil {
+				resolver = net.DefaultResolver
+			}
+
+			return dialHappyEyeballs(ctx, dial, resolver, network, host, port, do.ConnectionAttemptDelay)
+		}
+	}
+
+	if do.Resolver == nil {
+		return dial(ctx, network, net.JoinHostPort(host, port))
+	}
+
+	return dialSequential(ctx, dial, do.Resolver, network, host, port)
+}
+
+// dialContextFunc is the signature of net.Dialer.DialContext and DialOptions.DialFunc.
+type dialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// dialSequential resolves host with resolver and tries each of its addresses in turn,
+// returning the first connection to be established.
+func dialSequential(ctx context.Context, dial dialContextFunc, resolver Resolver, network, host, port string) (net.Conn, error) {
+	addrs, err := resolveAddresses(ctx, resolver, network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := dial(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// dialLogger returns the Logger of the first of opts, if any.
+func dialLogger(opts []DialOptions) *slog.Logger {
+	if opts == nil {
+		return nil
+	}
+
+	return opts[0].Logger
+}
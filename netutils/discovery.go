@@ -0,0 +1,519 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const mdnsPort = 5353
+
+// mdnsLegacyTTL is the maximum TTL given in responses to queries that were not sent from
+// the multicast DNS port, as recommended by RFC 6762.
+const mdnsLegacyTTL = 10
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: mdnsPort}
+
+// ServiceInfo describes a service that is advertised on the local network with Advertise
+// and found with Browse, using multicast DNS and DNS-based service discovery as
+// described by RFC 6762 and RFC 6763.
+type ServiceInfo struct {
+	// Instance is the human-readable name of this instance of the service, such as
+	// "Alice's lobby". It must not contain dots.
+	Instance string
+
+	// Service is the type of the service, of the form "_name._tcp" or "_name._udp".
+	Service string
+
+	// Domain is the domain that the service is advertised in. If empty, "local" is used.
+	Domain string
+
+	// Host is the host name that the service is running on. If empty when advertising,
+	// the name of the local system with a ".local" suffix is used.
+	Host string
+
+	// Port is the port that the service is listening on.
+	Port int
+
+	// IPs are the addresses of Host. If empty when advertising, the addresses of all of
+	// the network interfaces that are up are used.
+	IPs []net.IP
+
+	// Text holds optional "key=value" strings describing the service.
+	Text []string
+}
+
+// Address returns a host and port that can be passed to DialTCP or DialUDP to connect to
+// the service. An IPv4 address is preferred over an IPv6 one, and Host is used if there
+// are no addresses.
+func (si ServiceInfo) Address() (host, port string) {
+	port = strconv.Itoa(si.Port)
+
+	for _, ip := range si.IPs {
+		if ip.To4() != nil {
+			return ip.String(), port
+		}
+	}
+	if len(si.IPs) > 0 {
+		return si.IPs[0].String(), port
+	}
+
+	return strings.TrimSuffix(si.Host, "."), port
+}
+
+func (si ServiceInfo) serviceName() string {
+	domain := si.Domain
+	if domain == "" {
+		domain = "local"
+	}
+
+	return canonicalDNSName(si.Service + "." + domain)
+}
+
+func (si ServiceInfo) instanceName() string {
+	return canonicalDNSName(si.Instance + "." + si.serviceName())
+}
+
+// AdvertiseOptions is a struct used by Advertise to define certain optional parameters.
+type AdvertiseOptions struct {
+	// TTL is how long peers may cache the advertisement for.
+	TTL time.Duration
+
+	// Interface is the network interface to advertise on. If nil, the system default is
+	// used.
+	Interface *net.Interface
+}
+
+func defaultAdvertiseOptions() AdvertiseOptions {
+	return AdvertiseOptions{TTL: 2 * time.Minute}
+}
+
+// Advertisement is a service that is being advertised on the local network. It is
+// created with Advertise, and the service stays discoverable until Close is called.
+type Advertisement struct {
+	conn    *net.UDPConn
+	service ServiceInfo
+	ttl     uint32
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// Advertise starts answering multicast DNS queries for service on the local network, so
+// that it can be found by Browse, or by any other DNS-based service discovery browser
+// such as Avahi or Bonjour. The service is announced once when Advertise is called.
+// Only IPv4 multicast is used.
+//
+// This takes a variadic parameter of type AdvertiseOptions. If no AdvertiseOptions are
+// supplied, then the defaults are used. If more than one AdvertiseOptions are supplied
+// then only the first will be used.
+func Advertise(service ServiceInfo, opts ...AdvertiseOptions) (*Advertisement, error) {
+	options := defaultAdvertiseOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.TTL <= 0 {
+		options.TTL = defaultAdvertiseOptions().TTL
+	}
+
+	if service.Instance == "" || strings.Contains(service.Instance, ".") {
+		return nil, fmt.Errorf("invalid service instance name %q", service.Instance)
+	}
+	if !strings.HasPrefix(service.Service, "_") || !(strings.HasSuffix(service.Service, "._tcp") || strings.HasSuffix(service.Service, "._udp")) {
+		return nil, fmt.Errorf("invalid service type %q, expected \"_name._tcp\" or \"_name._udp\"", service.Service)
+	}
+	if service.Port <= 0 || service.Port > 65535 {
+		return nil, fmt.Errorf("invalid service port %d", service.Port)
+	}
+
+	if service.Host == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("could not get host name: %w", err)
+		}
+
+		hostname, _, _ = strings.Cut(hostname, ".")
+		service.Host = hostname + ".local"
+	}
+	service.Host = canonicalDNSName(service.Host)
+
+	if len(service.IPs) == 0 {
+		ips, err := interfaceIPs(options.Interface)
+		if err != nil {
+			return nil, err
+		}
+
+		service.IPs = ips
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", options.Interface, mdnsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("could not join multicast DNS group: %w", err)
+	}
+
+	a := &Advertisement{
+		conn:    conn,
+		service: service,
+		ttl:     uint32(options.TTL / time.Second),
+	}
+
+	if err := a.announce(a.ttl); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	a.wg.Add(1)
+	go a.serve()
+
+	return a, nil
+}
+
+// interfaceIPs returns the addresses of iface, or of every interface that is up if iface
+// is nil. Loopback addresses are only included if there are no others.
+func interfaceIPs(iface *net.Interface) ([]net.IP, error) {
+	var ifaces []net.Interface
+	if iface != nil {
+		ifaces = []net.Interface{*iface}
+	} else {
+		var err error
+		if ifaces, err = net.Interfaces(); err != nil {
+			return nil, fmt.Errorf("could not list network interfaces: %w", err)
+		}
+	}
+
+	var ips, loopback []net.IP
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case ipNet.IP.IsLoopback():
+				loopback = append(loopback, ipNet.IP)
+			case ipNet.IP.IsGlobalUnicast() || ipNet.IP.IsLinkLocalUnicast():
+				ips = append(ips, ipNet.IP)
+			}
+		}
+	}
+
+	if len(ips) == 0 {
+		ips = loopback
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("no network addresses to advertise")
+	}
+
+	return ips, nil
+}
+
+// records returns the records that answer a question for name of type qtype, with
+// additional records that a browser will need to follow up on them.
+func (a *Advertisement) records(name string, qtype uint16, ttl uint32) []dnsRecord {
+	service := a.service
+	instance := service.instanceName()
+
+	ptr := dnsRecord{name: service.serviceName(), rtype: dnsTypePTR, ttl: ttl, target: instance}
+	srv := dnsRecord{name: instance, rtype: dnsTypeSRV, flush: true, ttl: ttl, target: service.Host, port: uint16(service.Port)}
+	txt := dnsRecord{name: instance, rtype: dnsTypeTXT, flush: true, ttl: ttl, text: service.Text}
+
+	var addresses []dnsRecord
+	for _, ip := range service.IPs {
+		rtype := dnsTypeAAAA
+		if ip.To4() != nil {
+			rtype = dnsTypeA
+		}
+
+		if qtype == dnsTypeANY || qtype == rtype || qtype == dnsTypePTR || qtype == dnsTypeSRV {
+			addresses = append(addresses, dnsRecord{name: service.Host, rtype: rtype, flush: true, ttl: ttl, ip: ip})
+		}
+	}
+
+	var records []dnsRecord
+	switch dnsKey(name) {
+	case dnsKey(ptr.name):
+		if qtype == dnsTypePTR || qtype == dnsTypeANY {
+			records = append(records, ptr, srv, txt)
+			records = append(records, addresses...)
+		}
+	case dnsKey(instance):
+		switch qtype {
+		case dnsTypeANY:
+			records = append(records, srv, txt)
+			records = append(records, addresses...)
+		case dnsTypeSRV:
+			records = append(records, srv)
+			records = append(records, addresses...)
+		case dnsTypeTXT:
+			records = append(records, txt)
+		}
+	case dnsKey(service.Host):
+		if qtype == dnsTypeA || qtype == dnsTypeAAAA || qtype == dnsTypeANY {
+			records = append(records, addresses...)
+		}
+	}
+
+	return records
+}
+
+func (a *Advertisement) announce(ttl uint32) error {
+	message := dnsMessage{response: true, records: a.records(a.service.serviceName(), dnsTypeANY, ttl)}
+
+	packet, err := message.marshal()
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.conn.WriteToUDP(packet, mdnsGroup); err != nil {
+		return fmt.Errorf("could not announce service: %w", err)
+	}
+
+	return nil
+}
+
+func (a *Advertisement) serve() {
+	defer a.wg.Done()
+
+	buffer := make([]byte, 9000)
+	for {
+		n, from, err := a.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			continue
+		}
+
+		query, err := unmarshalDNSMessage(buffer[:n])
+		if err != nil || query.response {
+			continue
+		}
+
+		legacy := from.Port != mdnsPort
+		ttl := a.ttl
+		if legacy {
+			ttl = min(ttl, mdnsLegacyTTL)
+		}
+
+		response := dnsMessage{response: true}
+		unicast := legacy
+		for _, question := range query.questions {
+			records := a.records(question.name, question.qtype, ttl)
+			if len(records) == 0 {
+				continue
+			}
+
+			response.records = append(response.records, records...)
+			unicast = unicast || question.unicast
+		}
+		if len(response.records) == 0 {
+			continue
+		}
+
+		// Legacy queriers expect a conventional DNS response, which echoes the ID and
+		// questions of the query.
+		if legacy {
+			response.id = query.id
+			response.questions = query.questions
+		}
+
+		packet, err := response.marshal()
+		if err != nil {
+			continue
+		}
+
+		to := mdnsGroup
+		if unicast {
+			to = from
+		}
+		_, _ = a.conn.WriteToUDP(packet, to)
+	}
+}
+
+// Close stops advertising the service, telling peers that it has gone away.
+func (a *Advertisement) Close() error {
+	var err error
+	a.closeOnce.Do(func() {
+		goodbye := a.announce(0)
+		err = errors.Join(goodbye, a.conn.Close())
+		a.wg.Wait()
+	})
+
+	return err
+}
+
+// BrowseOptions is a struct used by Browse to define certain optional parameters.
+type BrowseOptions struct {
+	// Domain is the domain to browse in. If empty, "local" is used.
+	Domain string
+
+	// Interval is the time between each query that is sent while browsing.
+	Interval time.Duration
+}
+
+func defaultBrowseOptions() BrowseOptions {
+	return BrowseOptions{Interval: time.Second}
+}
+
+// Browse searches the local network for instances of service, which is of the form
+// "_name._tcp" or "_name._udp", until ctx is done. It then returns every instance that
+// was found, sorted by name. As Browse only returns once ctx is done, ctx should usually
+// have a timeout of a few seconds; its expiry is not treated as an error.
+//
+// This takes a variadic parameter of type BrowseOptions. If no BrowseOptions are
+// supplied, then the defaults are used. If more than one BrowseOptions are supplied then
+// only the first will be used.
+func Browse(ctx context.Context, service string, opts ...BrowseOptions) ([]ServiceInfo, error) {
+	options := defaultBrowseOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaultBrowseOptions().Interval
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	name := ServiceInfo{Service: service, Domain: options.Domain}.serviceName()
+	query := dnsMessage{
+		id:        uint16(rand.Uint32()),
+		questions: []dnsQuestion{{name: name, qtype: dnsTypePTR, unicast: true}},
+	}
+	packet, err := query.marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		ticker := time.NewTicker(options.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := conn.WriteToUDP(packet, mdnsGroup); err != nil {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	results := newBrowseResults(service, options.Domain)
+
+	buffer := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return results.services(name), nil
+			}
+
+			return nil, err
+		}
+
+		if response, err := unmarshalDNSMessage(buffer[:n]); err == nil && response.response {
+			results.add(response.records)
+		}
+	}
+}
+
+type browseResults struct {
+	service, domain string
+
+	// Each map is keyed by dnsKey. instances maps to the name as it was received.
+	instances map[string]string
+	srv       map[string]dnsRecord
+	text      map[string][]string
+	ips       map[string][]net.IP
+}
+
+func newBrowseResults(service, domain string) *browseResults {
+	return &browseResults{
+		service:   service,
+		domain:    domain,
+		instances: make(map[string]string),
+		srv:       make(map[string]dnsRecord),
+		text:      make(map[string][]string),
+		ips:       make(map[string][]net.IP),
+	}
+}
+
+func (br *browseResults) add(records []dnsRecord) {
+	for _, r := range records {
+		name := dnsKey(r.name)
+
+		switch r.rtype {
+		case dnsTypePTR:
+			if r.ttl == 0 {
+				delete(br.instances, dnsKey(r.target))
+			} else {
+				br.instances[dnsKey(r.target)] = canonicalDNSName(r.target)
+			}
+		case dnsTypeSRV:
+			br.srv[name] = r
+		case dnsTypeTXT:
+			br.text[name] = r.text
+		case dnsTypeA, dnsTypeAAAA:
+			if !slices.ContainsFunc(br.ips[name], r.ip.Equal) {
+				br.ips[name] = append(br.ips[name], r.ip)
+			}
+		}
+	}
+}
+
+func (br *browseResults) services(serviceName string) []ServiceInfo {
+	suffix := "." + dnsKey(serviceName)
+
+	var services []ServiceInfo
+	for key, instance := range br.instances {
+		srv, ok := br.srv[key]
+		if !ok || !strings.HasSuffix(key, suffix) {
+			continue
+		}
+
+		// Instance names cannot contain dots, so the instance is always the first label.
+		name, _, _ := strings.Cut(instance, ".")
+
+		services = append(services, ServiceInfo{
+			Instance: name,
+			Service:  br.service,
+			Domain:   br.domain,
+			Host:     srv.target,
+			Port:     int(srv.port),
+			IPs:      br.ips[dnsKey(srv.target)],
+			Text:     br.text[key],
+		})
+	}
+
+	slices.SortFunc(services, func(a, b ServiceInfo) int { return strings.Compare(a.Instance, b.Instance) })
+
+	return services
+}
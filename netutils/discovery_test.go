@@ -0,0 +1,94 @@
+package netutils
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDNSMessageRoundTrip(t *testing.T) {
+	message := dnsMessage{
+		id:        42,
+		response:  true,
+		questions: []dnsQuestion{{name: "_game._udp.local.", qtype: dnsTypePTR, unicast: true}},
+		records: []dnsRecord{
+			{name: "_game._udp.local.", rtype: dnsTypePTR, ttl: 120, target: "Lobby._game._udp.local."},
+			{name: "Lobby._game._udp.local.", rtype: dnsTypeSRV, flush: true, ttl: 120, target: "host.local.", port: 4000},
+			{name: "Lobby._game._udp.local.", rtype: dnsTypeTXT, ttl: 120, text: []string{"players=3"}},
+			{name: "host.local.", rtype: dnsTypeA, ttl: 120, ip: net.IPv4(192, 0, 2, 1).To4()},
+		},
+	}
+
+	packet, err := message.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := unmarshalDNSMessage(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.id != message.id || !decoded.response || len(decoded.questions) != 1 || len(decoded.records) != 4 {
+		t.Fatalf("unexpected message %+v", decoded)
+	}
+	if !decoded.questions[0].unicast || decoded.records[1].port != 4000 || !decoded.records[1].flush {
+		t.Errorf("unexpected message %+v", decoded)
+	}
+	if !slices.Equal(decoded.records[2].text, []string{"players=3"}) || !decoded.records[3].ip.Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Errorf("unexpected records %+v", decoded.records)
+	}
+}
+
+func TestReadDNSNameCompression(t *testing.T) {
+	msg := []byte{5, 'l', 'o', 'c', 'a', 'l', 0, 4, 'h', 'o', 's', 't', 0xC0, 0}
+
+	name, next, err := readDNSName(msg, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "host.local." || next != len(msg) {
+		t.Errorf("expected host.local. ending at %d, got %q ending at %d", len(msg), name, next)
+	}
+
+	if _, _, err := readDNSName([]byte{0xC0, 0}, 0); err == nil {
+		t.Error("expected an error for a pointer loop")
+	}
+}
+
+func TestAdvertiseBrowse(t *testing.T) {
+	advertisement, err := Advertise(ServiceInfo{
+		Instance: "Test Lobby",
+		Service:  "_netutils-test._udp",
+		Host:     "netutils-test.local",
+		Port:     4000,
+		IPs:      []net.IP{net.IPv4(127, 0, 0, 1)},
+		Text:     []string{"players=3"},
+	})
+	if err != nil {
+		t.Skipf("multicast is unavailable: %v", err)
+	}
+	defer advertisement.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	services, err := Browse(ctx, "_netutils-test._udp", BrowseOptions{Interval: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) == 0 {
+		t.Skip("no multicast DNS responses were received")
+	}
+
+	service := services[0]
+	if service.Instance != "Test Lobby" || service.Port != 4000 || !slices.Equal(service.Text, []string{"players=3"}) {
+		t.Fatalf("unexpected service %+v", service)
+	}
+
+	if host, port := service.Address(); host != "127.0.0.1" || port != "4000" {
+		t.Errorf("expected 127.0.0.1:4000, got %s:%s", host, port)
+	}
+}
@@ -0,0 +1,308 @@
+package netutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// A minimal encoder and decoder for the DNS wire format of RFC 1035, supporting only the
+// record types needed for DNS-based service discovery over multicast DNS.
+
+const (
+	dnsTypeA    uint16 = 1
+	dnsTypePTR  uint16 = 12
+	dnsTypeTXT  uint16 = 16
+	dnsTypeAAAA uint16 = 28
+	dnsTypeSRV  uint16 = 33
+	dnsTypeANY  uint16 = 255
+
+	dnsClassIN uint16 = 1
+
+	// mdnsClassFlag is the top bit of the class of a question or record, which means
+	// "unicast response requested" for questions and "cache flush" for records.
+	mdnsClassFlag uint16 = 1 << 15
+
+	dnsFlagResponse  uint16 = 1 << 15
+	dnsFlagAuthority uint16 = 1 << 10
+)
+
+var errMalformedDNSMessage = errors.New("malformed DNS message")
+
+type dnsQuestion struct {
+	name  string
+	qtype uint16
+	// unicast is the QU bit of multicast DNS.
+	unicast bool
+}
+
+type dnsRecord struct {
+	name  string
+	rtype uint16
+	// flush is the cache flush bit of multicast DNS.
+	flush bool
+	ttl   uint32
+
+	// target is the data of PTR records and the target host of SRV records.
+	target string
+	port   uint16
+	text   []string
+	ip     net.IP
+}
+
+type dnsMessage struct {
+	id        uint16
+	response  bool
+	questions []dnsQuestion
+	// records holds the answer, authority, and additional records of the message. All
+	// of them are written as answers.
+	records []dnsRecord
+}
+
+// canonicalDNSName makes sure that name ends in a single dot.
+func canonicalDNSName(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+// dnsKey returns name in a form that can be compared to others, as DNS names are case
+// insensitive.
+func dnsKey(name string) string {
+	return strings.ToLower(canonicalDNSName(name))
+}
+
+func appendDNSName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if label == "" || len(label) > 63 {
+				return nil, fmt.Errorf("invalid DNS label %q in %q", label, name)
+			}
+
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+
+	return append(b, 0), nil
+}
+
+func (m *dnsMessage) marshal() ([]byte, error) {
+	var flags uint16
+	if m.response {
+		flags |= dnsFlagResponse | dnsFlagAuthority
+	}
+
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.id)
+	binary.BigEndian.PutUint16(b[2:], flags)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.records)))
+
+	var err error
+	for _, q := range m.questions {
+		if b, err = appendDNSName(b, q.name); err != nil {
+			return nil, err
+		}
+
+		class := dnsClassIN
+		if q.unicast {
+			class |= mdnsClassFlag
+		}
+		b = binary.BigEndian.AppendUint16(b, q.qtype)
+		b = binary.BigEndian.AppendUint16(b, class)
+	}
+
+	for _, r := range m.records {
+		if b, err = appendDNSName(b, r.name); err != nil {
+			return nil, err
+		}
+
+		class := dnsClassIN
+		if r.flush {
+			class |= mdnsClassFlag
+		}
+		b = binary.BigEndian.AppendUint16(b, r.rtype)
+		b = binary.BigEndian.AppendUint16(b, class)
+		b = binary.BigEndian.AppendUint32(b, r.ttl)
+
+		lengthAt := len(b)
+		b = append(b, 0, 0)
+
+		switch r.rtype {
+		case dnsTypeA:
+			b = append(b, r.ip.To4()...)
+		case dnsTypeAAAA:
+			b = append(b, r.ip.To16()...)
+		case dnsTypePTR:
+			b, err = appendDNSName(b, r.target)
+		case dnsTypeSRV:
+			b = binary.BigEndian.AppendUint16(b, 0) // priority
+			b = binary.BigEndian.AppendUint16(b, 0) // weight
+			b = binary.BigEndian.AppendUint16(b, r.port)
+			b, err = appendDNSName(b, r.target)
+		case dnsTypeTXT:
+			if len(r.text) == 0 {
+				b = append(b, 0)
+			}
+			for _, text := range r.text {
+				if len(text) > 255 {
+					return nil, fmt.Errorf("TXT string of %d bytes is too long", len(text))
+				}
+
+				b = append(b, byte(len(text)))
+				b = append(b, text...)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported DNS record type %d", r.rtype)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		binary.BigEndian.PutUint16(b[lengthAt:], uint16(len(b)-lengthAt-2))
+	}
+
+	return b, nil
+}
+
+// readDNSName reads a possibly compressed name starting at offset, returning it and the
+// offset just past it.
+func readDNSName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	end := -1
+
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, errMalformedDNSMessage
+		}
+
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+
+			return strings.Join(labels, ".") + ".", end, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) || jumps > 16 {
+				return "", 0, errMalformedDNSMessage
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3FFF)
+			jumps++
+		case length&0xC0 != 0:
+			return "", 0, errMalformedDNSMessage
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, errMalformedDNSMessage
+			}
+
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+func unmarshalDNSMessage(msg []byte) (*dnsMessage, error) {
+	if len(msg) < 12 {
+		return nil, errMalformedDNSMessage
+	}
+
+	m := &dnsMessage{
+		id:       binary.BigEndian.Uint16(msg[0:]),
+		response: binary.BigEndian.Uint16(msg[2:])&dnsFlagResponse != 0,
+	}
+
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	records := 0
+	for i := 6; i < 12; i += 2 {
+		records += int(binary.BigEndian.Uint16(msg[i:]))
+	}
+
+	offset := 12
+	for range questions {
+		name, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(msg) {
+			return nil, errMalformedDNSMessage
+		}
+
+		class := binary.BigEndian.Uint16(msg[next+2:])
+		m.questions = append(m.questions, dnsQuestion{
+			name:    name,
+			qtype:   binary.BigEndian.Uint16(msg[next:]),
+			unicast: class&mdnsClassFlag != 0,
+		})
+		offset = next + 4
+	}
+
+	for range records {
+		name, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, errMalformedDNSMessage
+		}
+
+		r := dnsRecord{
+			name:  name,
+			rtype: binary.BigEndian.Uint16(msg[next:]),
+			flush: binary.BigEndian.Uint16(msg[next+2:])&mdnsClassFlag != 0,
+			ttl:   binary.BigEndian.Uint32(msg[next+4:]),
+		}
+
+		start := next + 10
+		end := start + int(binary.BigEndian.Uint16(msg[next+8:]))
+		if end > len(msg) {
+			return nil, errMalformedDNSMessage
+		}
+		data := msg[start:end]
+		offset = end
+
+		switch r.rtype {
+		case dnsTypeA, dnsTypeAAAA:
+			if len(data) != net.IPv4len && len(data) != net.IPv6len {
+				return nil, errMalformedDNSMessage
+			}
+			r.ip = net.IP(append([]byte(nil), data...))
+		case dnsTypePTR:
+			if r.target, _, err = readDNSName(msg, start); err != nil {
+				return nil, err
+			}
+		case dnsTypeSRV:
+			if len(data) < 7 {
+				return nil, errMalformedDNSMessage
+			}
+			r.port = binary.BigEndian.Uint16(data[4:])
+			if r.target, _, err = readDNSName(msg, start+6); err != nil {
+				return nil, err
+			}
+		case dnsTypeTXT:
+			for len(data) > 0 {
+				length := int(data[0])
+				if 1+length > len(data) {
+					return nil, errMalformedDNSMessage
+				}
+				if length > 0 {
+					r.text = append(r.text, string(data[1:1+length]))
+				}
+				data = data[1+length:]
+			}
+		default:
+			continue
+		}
+
+		m.records = append(m.records, r)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,120 @@
+package netutils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// frameDumper serialises dumps from concurrent reads and writes to the same writer.
+type frameDumper struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// SetFrameDump enables a debug mode in which every frame read or written by Send,
+// Receive, and WriteBatch is written to w as a human-readable dump. Each dump contains
+// the direction and size of the frame, a hex dump of its payload, and a summary of the
+// decoded value as given by its String method, or the error that decoding failed with.
+// This is useful for diagnosing mismatched codecs between peers. Passing nil disables
+// the dump.
+//
+// Dumping decodes every frame a second time, so it should not be used in production.
+func (tc *AsymmetricTypedConnection[S, R]) SetFrameDump(w io.Writer) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	if w == nil {
+		tc.state.dump = nil
+	} else {
+		tc.state.dump = &frameDumper{w: w}
+	}
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) dumper() *frameDumper {
+	if tc.state == nil {
+		return nil
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	return tc.state.dump
+}
+
+// dumpFrames dumps every complete frame in data, which includes the length prefixes.
+func (tc *AsymmetricTypedConnection[S, R]) dumpFrames(dir direction, data []byte) {
+	dumper := tc.dumper()
+	if dumper == nil {
+		return
+	}
+
+	for {
+		frame, control, ok := nextFrame(data)
+		if !ok {
+			return
+		}
+		data = data[len(frame):]
+
+		tc.dumpPayload(dumper, dir, control, frame[frameHeaderSize:])
+	}
+}
+
+// dumpFrame dumps the payload of a single frame that has already had its length prefix
+// removed.
+func (tc *AsymmetricTypedConnection[S, R]) dumpFrame(dir direction, control bool, payload []byte) {
+	if dumper := tc.dumper(); dumper != nil {
+		tc.dumpPayload(dumper, dir, control, payload)
+	}
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) dumpPayload(dumper *frameDumper, dir direction, control bool, payload []byte) {
+	var b strings.Builder
+
+	arrow, from, to := "-->", tc.conn.LocalAddr(), tc.conn.RemoteAddr()
+	if dir == readDirection {
+		arrow, from, to = "<--", to, from
+	}
+
+	kind := "frame"
+	if control {
+		kind = "control frame"
+	}
+
+	fmt.Fprintf(&b, "%s %s of %d bytes (%s %v -> %v, conn %d)\n", arrow, kind, len(payload), tc.connectionType, from, to, tc.ID())
+	b.WriteString(hex.Dump(payload))
+
+	if !control {
+		var summary string
+		if dir == readDirection {
+			summary = decodeSummary[R](payload)
+		} else {
+			summary = decodeSummary[S](payload)
+		}
+		b.WriteString(summary)
+		b.WriteByte('\n')
+	}
+
+	dumper.mu.Lock()
+	defer dumper.mu.Unlock()
+
+	_, _ = io.WriteString(dumper.w, b.String())
+}
+
+func decodeSummary[T Convertable](payload []byte) string {
+	name := reflect.TypeFor[T]().String()
+
+	var value T
+	if err := value.Unmarshal(&value, payload); err != nil {
+		return fmt.Sprintf("could not decode as %s: %v", name, err)
+	}
+
+	return fmt.Sprintf("decoded as %s: %s", name, value.String())
+}
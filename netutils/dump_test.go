@@ -0,0 +1,86 @@
+package netutils
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestFrameDump(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer client.Close()
+	defer server.Close()
+
+	var sent, received strings.Builder
+	client.SetFrameDump(&sent)
+	server.SetFrameDump(&received)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.WriteBatch([]testMessage{{Text: "hello"}, {Text: "world"}})
+		done <- err
+	}()
+
+	for range 2 {
+		var message testMessage
+		if _, err := server.Receive(&message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	payload := `{"text":"hello"}`
+	for name, dump := range map[string]string{"sent": sent.String(), "received": received.String()} {
+		for _, expected := range []string{
+			hex.Dump([]byte(payload)),
+			"decoded as netutils.testMessage: hello\n",
+			"decoded as netutils.testMessage: world\n",
+		} {
+			if !strings.Contains(dump, expected) {
+				t.Errorf("%s dump should contain %q, got:\n%s", name, expected, dump)
+			}
+		}
+	}
+	if !strings.HasPrefix(sent.String(), "--> frame of 16 bytes") {
+		t.Errorf("sent dump should start with the direction and size, got:\n%s", sent.String())
+	}
+	if !strings.HasPrefix(received.String(), "<-- frame of 16 bytes") {
+		t.Errorf("received dump should start with the direction and size, got:\n%s", received.String())
+	}
+}
+
+func TestFrameDumpDecodeError(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	conn := NewTypedConnection[testMessage](a, ConnectionTypeTCP)
+	defer conn.Close()
+
+	var dump strings.Builder
+	conn.SetFrameDump(&dump)
+
+	go func() { _, _ = b.Write(append(binary.BigEndian.AppendUint32(nil, 1), '{')) }()
+
+	var message testMessage
+	if _, err := conn.Receive(&message); err == nil {
+		t.Fatal("receiving a malformed frame should fail")
+	}
+	if !strings.Contains(dump.String(), "could not decode as netutils.testMessage") {
+		t.Errorf("dump should contain the decode error, got:\n%s", dump.String())
+	}
+
+	// Disabling the dump stops anything more from being written.
+	conn.SetFrameDump(nil)
+	length := dump.Len()
+	go func() { _, _ = b.Write(append(binary.BigEndian.AppendUint32(nil, 2), "{}"...)) }()
+	if _, err := conn.Receive(&message); err != nil {
+		t.Fatal(err)
+	}
+	if dump.Len() != length {
+		t.Errorf("disabled dump should not be written to, got:\n%s", dump.String()[length:])
+	}
+}
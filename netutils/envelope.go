@@ -0,0 +1,196 @@
+package netutils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Well-known header keys used by Envelope.
+const (
+	HeaderMessageID   = "message-id"
+	HeaderTimestamp   = "timestamp"
+	HeaderContentType = "content-type"
+
+	// HeaderNoPayload marks an envelope that only carries headers, such as a control
+	// message, when set to any value. Its Payload is neither marshalled nor
+	// unmarshalled, and is left as the zero value of T, which need not be marshalable,
+	// as is the case for nil pointers.
+	HeaderNoPayload = "no-payload"
+)
+
+// Envelope wraps a payload of type T alongside a set of key/value headers, which are
+// transmitted with the payload. This allows applications to attach metadata such as
+// message IDs, timestamps, and content types for tracing and routing without adding
+// those fields to their own types.
+//
+// Envelope[T] itself implements Convertable, so it can be used directly as the type
+// parameter of any typed connection, i.e. TCPTypedConnection[Envelope[T]].
+type Envelope[T Convertable] struct {
+	Headers map[string]string
+	Payload T
+}
+
+// NewEnvelope creates a new Envelope around payload, with the HeaderMessageID and
+// HeaderTimestamp headers already populated.
+func NewEnvelope[T Convertable](payload T) Envelope[T] {
+	return Envelope[T]{
+		Headers: map[string]string{
+			HeaderMessageID: newMessageID(),
+			HeaderTimestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		},
+		Payload: payload,
+	}
+}
+
+// newMessageID generates a random, hex-encoded 128-bit identifier.
+func newMessageID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// Header returns the value of the header with the given key, or an empty string if it is
+// not set.
+func (e Envelope[T]) Header(key string) string {
+	return e.Headers[key]
+}
+
+// SetHeader sets the header with the given key to value, allocating the header map if
+// needed.
+func (e *Envelope[T]) SetHeader(key, value string) {
+	if e.Headers == nil {
+		e.Headers = make(map[string]string)
+	}
+
+	e.Headers[key] = value
+}
+
+// MessageID returns the value of the HeaderMessageID header.
+func (e Envelope[T]) MessageID() string {
+	return e.Header(HeaderMessageID)
+}
+
+// ContentType returns the value of the HeaderContentType header.
+func (e Envelope[T]) ContentType() string {
+	return e.Header(HeaderContentType)
+}
+
+// Timestamp parses the HeaderTimestamp header. If the header is missing or malformed,
+// false is returned.
+func (e Envelope[T]) Timestamp() (time.Time, bool) {
+	timestamp, err := time.Parse(time.RFC3339Nano, e.Header(HeaderTimestamp))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return timestamp, true
+}
+
+// Marshal encodes the headers followed by the marshalled payload. Headers are encoded in
+// key order so that the output is deterministic.
+func (e Envelope[T]) Marshal() ([]byte, error) {
+	if len(e.Headers) > math.MaxUint16 {
+		return nil, fmt.Errorf("envelope cannot hold more than %d headers", math.MaxUint16)
+	}
+
+	var payload []byte
+	if !e.headerOnly() {
+		var err error
+		if payload, err = e.Payload.Marshal(); err != nil {
+			return nil, errors.Join(errors.New("could not marshal envelope payload"), err)
+		}
+	}
+
+	keys := make([]string, 0, len(e.Headers))
+	for key := range e.Headers {
+		if len(key) > math.MaxUint16 {
+			return nil, fmt.Errorf("header key must be at most %d bytes long", math.MaxUint16)
+		}
+
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	buffer := binary.BigEndian.AppendUint16(nil, uint16(len(keys)))
+	for _, key := range keys {
+		value := e.Headers[key]
+
+		buffer = binary.BigEndian.AppendUint16(buffer, uint16(len(key)))
+		buffer = append(buffer, key...)
+		buffer = binary.BigEndian.AppendUint32(buffer, uint32(len(value)))
+		buffer = append(buffer, value...)
+	}
+
+	return append(buffer, payload...), nil
+}
+
+// Unmarshal decodes data into v, which must be a *Envelope[T].
+func (e Envelope[T]) Unmarshal(v any, data []byte) error {
+	envelope, ok := v.(*Envelope[T])
+	if !ok {
+		return fmt.Errorf("cannot unmarshal an Envelope into %T", v)
+	}
+
+	errTruncated := errors.New("envelope is truncated")
+
+	if len(data) < 2 {
+		return errTruncated
+	}
+	count := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+
+	headers := make(map[string]string, count)
+	for range count {
+		if len(data) < 2 {
+			return errTruncated
+		}
+		keyLength := int(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if len(data) < keyLength+4 {
+			return errTruncated
+		}
+		key := string(data[:keyLength])
+		data = data[keyLength:]
+
+		valueLength := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(len(data)) < uint64(valueLength) {
+			return errTruncated
+		}
+		headers[key] = string(data[:valueLength])
+		data = data[valueLength:]
+	}
+
+	var payload T
+	if _, ok := headers[HeaderNoPayload]; !ok {
+		if err := payload.Unmarshal(&payload, data); err != nil {
+			return errors.Join(errors.New("could not unmarshal envelope payload"), err)
+		}
+	}
+
+	envelope.Headers = headers
+	envelope.Payload = payload
+
+	return nil
+}
+
+// headerOnly reports whether the HeaderNoPayload header is set.
+func (e Envelope[T]) headerOnly() bool {
+	_, ok := e.Headers[HeaderNoPayload]
+	return ok
+}
+
+func (e Envelope[T]) String() string {
+	if e.headerOnly() {
+		return fmt.Sprintf("Envelope(%v)", e.Headers)
+	}
+
+	return fmt.Sprintf("Envelope(%v, %s)", e.Headers, e.Payload.String())
+}
@@ -0,0 +1,108 @@
+package netutils
+
+import (
+	"encoding/binary"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	envelope := NewEnvelope(testMessage{Text: "hello"})
+	envelope.SetHeader(HeaderContentType, "application/json")
+	envelope.SetHeader("empty", "")
+
+	data, err := envelope.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Envelope[testMessage]
+	if err := decoded.Unmarshal(&decoded, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !maps.Equal(decoded.Headers, envelope.Headers) {
+		t.Errorf("headers should be %v, got %v", envelope.Headers, decoded.Headers)
+	}
+	if decoded.Payload != envelope.Payload {
+		t.Errorf("payload should be %v, got %v", envelope.Payload, decoded.Payload)
+	}
+	if decoded.MessageID() == "" || decoded.ContentType() != "application/json" {
+		t.Errorf("well-known headers should survive, got %v", decoded.Headers)
+	}
+	if _, ok := decoded.Timestamp(); !ok {
+		t.Error("timestamp should survive")
+	}
+
+	again, err := decoded.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Error("marshalling should be deterministic")
+	}
+}
+
+func TestEnvelopeNoPayload(t *testing.T) {
+	envelope := Envelope[*pointerMessage]{Headers: map[string]string{HeaderNoPayload: "true"}}
+
+	data, err := envelope.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Envelope[*pointerMessage]
+	if err := decoded.Unmarshal(&decoded, data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Payload != nil || decoded.Header(HeaderNoPayload) != "true" {
+		t.Errorf("header-only envelope should round trip, got %v", decoded)
+	}
+}
+
+func TestEnvelopeTruncated(t *testing.T) {
+	envelope := NewEnvelope(testMessage{Text: "hello"})
+
+	data, err := envelope.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := range len(data) {
+		var decoded Envelope[testMessage]
+		if err := decoded.Unmarshal(&decoded, data[:n]); err == nil {
+			t.Errorf("unmarshalling %d of %d bytes should fail", n, len(data))
+		}
+	}
+
+	// A header claiming to be longer than the rest of the input.
+	oversized := binary.BigEndian.AppendUint16(nil, 1)
+	oversized = binary.BigEndian.AppendUint16(oversized, 1)
+	oversized = append(oversized, 'k')
+	oversized = binary.BigEndian.AppendUint32(oversized, math.MaxUint32)
+	oversized = append(oversized, "value"...)
+
+	var decoded Envelope[testMessage]
+	if err := decoded.Unmarshal(&decoded, oversized); err == nil {
+		t.Error("unmarshalling a header longer than the input should fail")
+	}
+}
+
+func TestEnvelopeOversized(t *testing.T) {
+	var envelope Envelope[testMessage]
+	envelope.SetHeader(strings.Repeat("k", math.MaxUint16+1), "value")
+	if _, err := envelope.Marshal(); err == nil {
+		t.Error("marshalling a header key that is too long should fail")
+	}
+
+	envelope = Envelope[testMessage]{}
+	for i := range math.MaxUint16 + 1 {
+		envelope.SetHeader(strconv.Itoa(i), "")
+	}
+	if _, err := envelope.Marshal(); err == nil {
+		t.Error("marshalling too many headers should fail")
+	}
+}
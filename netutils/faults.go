@@ -0,0 +1,151 @@
+package netutils
+
+import (
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// FaultOptions is a struct used by NewFaultyConn to define certain optional parameters.
+// Each probability is a number between 0 and 1 that is rolled independently for every
+// frame.
+type FaultOptions struct {
+	// DropProbability is the chance of a frame not being written at all.
+	DropProbability float64
+	// DuplicateProbability is the chance of a frame being written twice in a row.
+	DuplicateProbability float64
+	// CorruptProbability is the chance of a single bit being flipped in the payload of a
+	// frame. The length prefix is never corrupted, so the stream stays in sync.
+	CorruptProbability float64
+
+	// DelayProbability is the chance of a frame being held back for Delay before it is
+	// written. As frames are written in order, this also delays every frame behind it.
+	DelayProbability float64
+	Delay            time.Duration
+
+	// Unframed treats the data of each Write call as a single frame instead of parsing
+	// the length-prefixed frames written by Send. This should be used with connections
+	// that are written to with Write, such as datagram connections.
+	Unframed bool
+
+	// Seed seeds the random source, so that a run can be reproduced. If zero, a random
+	// seed is used.
+	Seed uint64
+}
+
+// FaultyConn is a net.Conn that injects faults into the frames written to it, for
+// testing how protocols built on this package cope with an unreliable network. It is
+// used by wrapping a connection before creating a typed connection over it:
+//
+//	conn := netutils.NewTypedConnection[T](netutils.NewFaultyConn(raw, options), netutils.ConnectionTypeTCP)
+//
+// Faults are only applied to data written to the FaultyConn; wrap both ends of a
+// connection to inject faults in both directions. Control frames, such as heartbeats,
+// are passed through untouched.
+type FaultyConn struct {
+	net.Conn
+
+	options FaultOptions
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	pending []byte
+}
+
+// NewFaultyConn creates a new *FaultyConn that writes to conn.
+//
+// This takes a variadic parameter of type FaultOptions. If no FaultOptions are supplied,
+// then no faults are injected. If more than one FaultOptions are supplied then only the
+// first will be used.
+func NewFaultyConn(conn net.Conn, opts ...FaultOptions) *FaultyConn {
+	var options FaultOptions
+	if opts != nil {
+		options = opts[0]
+	}
+
+	seed := options.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+
+	return &FaultyConn{
+		Conn:    conn,
+		options: options,
+		rand:    rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+// Write writes p to the underlying connection, injecting faults into every complete
+// frame within it. Incomplete frames are held back until the rest of the frame has been
+// written. Dropped frames are reported as written.
+func (fc *FaultyConn) Write(p []byte) (int, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if fc.options.Unframed {
+		if err := fc.deliver(p, 0); err != nil {
+			return 0, err
+		}
+
+		return len(p), nil
+	}
+
+	fc.pending = append(fc.pending, p...)
+
+	for {
+		frame, control, ok := nextFrame(fc.pending)
+		if !ok {
+			break
+		}
+
+		var err error
+		if control {
+			_, err = fc.Conn.Write(frame)
+		} else {
+			err = fc.deliver(frame, frameHeaderSize)
+		}
+
+		fc.pending = fc.pending[len(frame):]
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	fc.pending = append([]byte(nil), fc.pending...)
+
+	return len(p), nil
+}
+
+func (fc *FaultyConn) roll(probability float64) bool {
+	return probability > 0 && fc.rand.Float64() < probability
+}
+
+// deliver writes frame to the underlying connection with faults applied. Only the bytes
+// after offset are eligible for corruption.
+func (fc *FaultyConn) deliver(frame []byte, offset int) error {
+	if fc.roll(fc.options.DropProbability) {
+		return nil
+	}
+
+	if fc.roll(fc.options.DelayProbability) {
+		time.Sleep(fc.options.Delay)
+	}
+
+	if len(frame) > offset && fc.roll(fc.options.CorruptProbability) {
+		frame = append([]byte(nil), frame...)
+		frame[offset+fc.rand.IntN(len(frame)-offset)] ^= 1 << fc.rand.IntN(8)
+	}
+
+	if _, err := fc.Conn.Write(frame); err != nil {
+		return err
+	}
+
+	if fc.roll(fc.options.DuplicateProbability) {
+		if _, err := fc.Conn.Write(frame); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
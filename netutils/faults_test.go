@@ -0,0 +1,35 @@
+package netutils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFaultyConnDuplicatesAndDrops(t *testing.T) {
+	a, b := net.Pipe()
+	faulty := NewFaultyConn(a, FaultOptions{DuplicateProbability: 1})
+	sender := NewTypedConnection[testMessage](faulty, ConnectionTypeTCP)
+	receiver := NewTypedConnection[testMessage](b, ConnectionTypeTCP)
+	defer sender.Close()
+	defer receiver.Close()
+
+	go func() {
+		_, _ = sender.Send(testMessage{Text: "twice"})
+
+		faulty.options = FaultOptions{DropProbability: 1}
+		_, _ = sender.Send(testMessage{Text: "dropped"})
+
+		faulty.options = FaultOptions{}
+		_, _ = sender.Send(testMessage{Text: "last"})
+	}()
+
+	for _, want := range []string{"twice", "twice", "last"} {
+		var message testMessage
+		if _, err := receiver.Receive(&message); err != nil {
+			t.Fatal(err)
+		}
+		if message.Text != want {
+			t.Fatalf("expected %q, got %q", want, message.Text)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package netutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"slices"
+)
+
+// DefaultMaxFrameSize is the largest frame that Receive will accept when no MaxFrameSize
+// has been given in the ReadOptions.
+const DefaultMaxFrameSize = 16 << 20
+
+// frameHeaderSize is the size of the big-endian length prefix written before each frame.
+const frameHeaderSize = 4
+
+// controlFrameFlag is set in the length prefix of frames used internally by the package,
+// such as heartbeats, which are handled by Receive rather than being returned to the
+// caller. This limits the size of a regular frame to math.MaxInt32 bytes.
+const (
+	controlFrameFlag    = 1 << 31
+	maxControlFrameSize = 64
+)
+
+// ErrFrameTooLarge is returned when an incoming or outgoing frame is larger than the
+// maximum allowed frame size.
+var ErrFrameTooLarge = errors.New("frame exceeds the maximum frame size")
+
+// appendFrame appends payload to dst, prefixed with its length.
+func appendFrame(dst, payload []byte) ([]byte, error) {
+	if uint64(len(payload)) > math.MaxInt32 {
+		return nil, ErrFrameTooLarge
+	}
+
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	return append(dst, payload...), nil
+}
+
+// appendControlFrame appends payload to dst as a control frame.
+func appendControlFrame(dst, payload []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload))|controlFrameFlag)
+	return append(dst, payload...)
+}
+
+// maxFrameSize returns the MaxFrameSize of the options, or DefaultMaxFrameSize if it has
+// not been set.
+func (ro ReadOptions) maxFrameSize() int {
+	if ro.MaxFrameSize > 0 {
+		return ro.MaxFrameSize
+	}
+
+	return DefaultMaxFrameSize
+}
+
+// marshalFrame marshals value and wraps the result in a single length-prefixed frame.
+func marshalFrame[T Convertable](value T) ([]byte, error) {
+	return appendValueFrame(nil, value)
+}
+
+// appendFrameBuffers marshals value as a single frame and appends it to buffers,
+// returning the extended buffers and the size of the frame. Values that marshal directly,
+// see marshalsDirectly, are marshalled into one buffer behind their length prefix. For
+// other values, the length prefix and the payload returned by Marshal are appended as
+// separate buffers, so that the payload does not have to be copied; writing the buffers
+// with net.Buffers.WriteTo then sends both in a single writev call.
+func appendFrameBuffers[T Convertable](buffers net.Buffers, value T) (net.Buffers, int, error) {
+	if marshalsDirectly(value) {
+		frame, err := appendValueFrame(nil, value)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return append(buffers, frame), len(frame), nil
+	}
+
+	payload, err := value.Marshal()
+	if err != nil {
+		return nil, 0, errors.Join(errors.New("could not marshal data to send"), err)
+	}
+	if uint64(len(payload)) > math.MaxInt32 {
+		return nil, 0, ErrFrameTooLarge
+	}
+
+	header := binary.BigEndian.AppendUint32(make([]byte, 0, frameHeaderSize), uint32(len(payload)))
+
+	return append(buffers, header, payload), frameHeaderSize + len(payload), nil
+}
+
+// marshalsDirectly returns whether value can be marshalled straight into a buffer, as it
+// implements AppendMarshaler or io.WriterTo.
+func marshalsDirectly(value any) bool {
+	switch value.(type) {
+	case AppendMarshaler, io.WriterTo:
+		return true
+	default:
+		return false
+	}
+}
+
+// appendValueFrame marshals value and appends it to dst as a single length-prefixed
+// frame. See AppendMarshaler for how values can avoid an intermediate copy.
+func appendValueFrame[T Convertable](dst []byte, value T) ([]byte, error) {
+	if marshalsDirectly(value) {
+		start := len(dst)
+		dst = append(dst, make([]byte, frameHeaderSize)...)
+
+		dst, err := appendMarshal(dst, value)
+		if err != nil {
+			return nil, errors.Join(errors.New("could not marshal data to send"), err)
+		}
+
+		size := len(dst) - start - frameHeaderSize
+		if uint64(size) > math.MaxInt32 {
+			return nil, ErrFrameTooLarge
+		}
+		binary.BigEndian.PutUint32(dst[start:], uint32(size))
+
+		return dst, nil
+	}
+
+	payload, err := value.Marshal()
+	if err != nil {
+		return nil, errors.Join(errors.New("could not marshal data to send"), err)
+	}
+
+	return appendFrame(slices.Grow(dst, frameHeaderSize+len(payload)), payload)
+}
+
+// readFrame reads a single length-prefixed frame from r, returning its payload and
+// whether it is a control frame. Frames larger than maxSize are rejected with
+// ErrFrameTooLarge. The payload is taken from pool, if it is not nil.
+func readFrame(r io.Reader, maxSize int, pool BufferPool) ([]byte, bool, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, false, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	control := size&controlFrameFlag != 0
+	size &^= controlFrameFlag
+
+	if control && size > maxControlFrameSize {
+		return nil, false, fmt.Errorf("%w: control frame of %d bytes", ErrFrameTooLarge, size)
+	}
+	if !control && uint64(size) > uint64(maxSize) {
+		return nil, false, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, size, maxSize)
+	}
+
+	payload := getBuffer(pool, int(size))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		putBuffer(pool, payload)
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, false, err
+	}
+
+	return payload, control, nil
+}
+
+// nextFrame returns the first complete frame in buffer, including its length prefix, and
+// whether it is a control frame. If buffer does not yet hold a complete frame, ok is
+// false.
+func nextFrame(buffer []byte) (frame []byte, control, ok bool) {
+	if len(buffer) < frameHeaderSize {
+		return nil, false, false
+	}
+
+	size := binary.BigEndian.Uint32(buffer)
+	control = size&controlFrameFlag != 0
+	size &^= controlFrameFlag
+
+	end := uint64(frameHeaderSize) + uint64(size)
+	if uint64(len(buffer)) < end {
+		return nil, false, false
+	}
+
+	return buffer[:end], control, true
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package netutils
+
+import (
+	"errors"
+	"net"
+)
+
+func defaultGateway() (net.IP, error) {
+	return nil, errors.New("finding the default gateway is not supported on this platform")
+}
@@ -0,0 +1,147 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+)
+
+// errHalfCloseUnsupported is returned when the underlying connection cannot be
+// half-closed.
+var errHalfCloseUnsupported = errors.New("conn does not support half-closing")
+
+// CloseWrite shuts down the writing side of the connection, which signals the end of the
+// stream to the peer: once it has received everything that was written before, its next
+// read returns io.EOF. The connection can still be read from. This is a wrapper over
+// net.TCPConn.CloseWrite(), and also works with other connections that implement it,
+// such as *tls.Conn.
+func (ttc *TCPTypedConnection[T]) CloseWrite() error {
+	conn, ok := ttc.conn.(interface{ CloseWrite() error })
+	if !ok {
+		return errHalfCloseUnsupported
+	}
+
+	return conn.CloseWrite()
+}
+
+// CloseRead shuts down the reading side of the connection. The connection can still be
+// written to. This is a wrapper over net.TCPConn.CloseRead().
+func (ttc *TCPTypedConnection[T]) CloseRead() error {
+	conn, ok := ttc.conn.(interface{ CloseRead() error })
+	if !ok {
+		return errHalfCloseUnsupported
+	}
+
+	return conn.CloseRead()
+}
+
+// Split splits the connection into independent reading and writing halves, which can be
+// handed to different goroutines. The halves share the connection, so its limits,
+// heartbeat, and statistics apply to both. Closing a half only half-closes the
+// connection; the connection is fully closed once both halves have been closed, or when
+// it is closed directly.
+func (ttc *TCPTypedConnection[T]) Split() (*TypedReader[T], *TypedWriter[T]) {
+	halves := &splitHalves{}
+
+	return &TypedReader[T]{conn: ttc, halves: halves}, &TypedWriter[T]{conn: ttc, halves: halves}
+}
+
+// splitHalves tracks which halves of a split connection are still open.
+type splitHalves struct {
+	mu                      sync.Mutex
+	readClosed, writeClosed bool
+}
+
+// close marks one half as closed, returning whether both halves are now closed.
+func (sh *splitHalves) close(write bool) (both, already bool) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if write {
+		already, sh.writeClosed = sh.writeClosed, true
+	} else {
+		already, sh.readClosed = sh.readClosed, true
+	}
+
+	return sh.readClosed && sh.writeClosed, already
+}
+
+// TypedReader is the reading half of a TCPTypedConnection, as returned by Split.
+type TypedReader[T Convertable] struct {
+	conn   *TCPTypedConnection[T]
+	halves *splitHalves
+}
+
+// Read is a wrapper over AsymmetricTypedConnection.Read.
+func (tr *TypedReader[T]) Read(data *T, opts ...ReadOption) (int, error) {
+	return tr.conn.Read(data, opts...)
+}
+
+// Receive is a wrapper over AsymmetricTypedConnection.Receive.
+func (tr *TypedReader[T]) Receive(data *T, opts ...ReadOption) (int, error) {
+	return tr.conn.Receive(data, opts...)
+}
+
+// Messages is a wrapper over AsymmetricTypedConnection.Messages.
+func (tr *TypedReader[T]) Messages(ctx context.Context, opts ...ReadOption) iter.Seq2[T, error] {
+	return tr.conn.Messages(ctx, opts...)
+}
+
+// Close shuts down the reading side of the connection, closing the connection entirely
+// if the writing half has also been closed.
+func (tr *TypedReader[T]) Close() error {
+	both, already := tr.halves.close(false)
+	if already {
+		return nil
+	}
+	if both {
+		return tr.conn.Close()
+	}
+
+	return tr.conn.CloseRead()
+}
+
+// Ensure that TypedWriter can be used wherever a Sender is expected.
+var _ Sender[Message] = (*TypedWriter[Message])(nil)
+
+// TypedWriter is the writing half of a TCPTypedConnection, as returned by Split. It
+// implements Sender, so it can be used with an AsyncWriter.
+type TypedWriter[T Convertable] struct {
+	conn   *TCPTypedConnection[T]
+	halves *splitHalves
+}
+
+// Write is a wrapper over AsymmetricTypedConnection.Write.
+func (tw *TypedWriter[T]) Write(data T) (int, error) {
+	return tw.conn.Write(data)
+}
+
+// Send is a wrapper over AsymmetricTypedConnection.Send.
+func (tw *TypedWriter[T]) Send(data T) (int, error) {
+	return tw.conn.Send(data)
+}
+
+// WriteBatch is a wrapper over AsymmetricTypedConnection.WriteBatch.
+func (tw *TypedWriter[T]) WriteBatch(values []T) (int64, error) {
+	return tw.conn.WriteBatch(values)
+}
+
+func (tw *TypedWriter[T]) writeFrame(frame []byte) (int, error) {
+	return tw.conn.writeFrame(frame)
+}
+
+// Close shuts down the writing side of the connection, signalling the end of the stream
+// to the peer, and closes the connection entirely if the reading half has also been
+// closed.
+func (tw *TypedWriter[T]) Close() error {
+	both, already := tw.halves.close(true)
+	if already {
+		return nil
+	}
+	if both {
+		return tw.conn.Close()
+	}
+
+	return tw.conn.CloseWrite()
+}
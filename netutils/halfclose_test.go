@@ -0,0 +1,70 @@
+package netutils
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestSplitHalfClose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		server := NewTCPTypedConnection[testMessage](conn)
+		defer server.Close()
+
+		// Echo everything until the client half-closes, then say goodbye.
+		for {
+			var message testMessage
+			if _, err := server.Receive(&message); err != nil {
+				break
+			}
+			_, _ = server.Send(message)
+		}
+		_, _ = server.Send(testMessage{Text: "bye"})
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewTCPTypedConnection[testMessage](conn)
+	reader, writer := client.Split()
+
+	if _, err := writer.Send(testMessage{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var texts []string
+	for {
+		var message testMessage
+		if _, err := reader.Receive(&message); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatal(err)
+			}
+
+			break
+		}
+		texts = append(texts, message.Text)
+	}
+
+	if len(texts) != 2 || texts[0] != "hello" || texts[1] != "bye" {
+		t.Errorf("expected [hello bye], got %v", texts)
+	}
+	if err := reader.Close(); err != nil {
+		t.Error(err)
+	}
+}
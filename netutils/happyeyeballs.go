@@ -0,0 +1,152 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultConnectionAttemptDelay is the delay between connection attempts recommended by
+// RFC 8305, and the one used when no DialOptions are supplied.
+const DefaultConnectionAttemptDelay = 250 * time.Millisecond
+
+// interleaveAddresses orders addrs as described by RFC 8305 section 4, alternating
+// between IPv6 and IPv4 addresses, starting with IPv6.
+func interleaveAddresses(addrs []net.IPAddr) []net.IPAddr {
+	var v6, v4 []net.IPAddr
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			v4 = append(v4, addr)
+		} else {
+			v6 = append(v6, addr)
+		}
+	}
+
+	ordered := make([]net.IPAddr, 0, len(addrs))
+	for i := 0; i < len(v6) || i < len(v4); i++ {
+		if i < len(v6) {
+			ordered = append(ordered, v6[i])
+		}
+		if i < len(v4) {
+			ordered = append(ordered, v4[i])
+		}
+	}
+
+	return ordered
+}
+
+// filterAddresses removes the addresses that cannot be used on network.
+func filterAddresses(network string, addrs []net.IPAddr) []net.IPAddr {
+	filtered := addrs[:0:0]
+	for _, addr := range addrs {
+		isV4 := addr.IP.To4() != nil
+		if strings.HasSuffix(network, "4") && !isV4 || strings.HasSuffix(network, "6") && isV4 {
+			continue
+		}
+
+		filtered = append(filtered, addr)
+	}
+
+	return filtered
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// resolveAddresses returns the addresses of host that can be used on network, looking
+// them up with resolver unless host is already an IP address. As with net.Dial, an empty
+// host is the local system, which is reached through the loopback addresses.
+func resolveAddresses(ctx context.Context, resolver Resolver, network, host string) ([]net.IPAddr, error) {
+	var addrs []net.IPAddr
+	if host == "" {
+		addrs = []net.IPAddr{{IP: net.IPv6loopback}, {IP: net.IPv4(127, 0, 0, 1)}}
+	} else if ip := net.ParseIP(host); ip != nil {
+		addrs = []net.IPAddr{{IP: ip}}
+	} else {
+		var err error
+		if addrs, err = resolver.LookupIPAddr(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+
+	addrs = filterAddresses(network, addrs)
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no %s addresses found for %s", network, host)
+	}
+
+	return addrs, nil
+}
+
+// dialHappyEyeballs connects to host:port by racing connection attempts to every address
+// of host, as described by RFC 8305. Attempts are started delay apart, in the order given
+// by interleaveAddresses, or straight away once the previous attempt has failed. The
+// first connection to be established is returned and every other attempt is cancelled.
+func dialHappyEyeballs(ctx context.Context, dial dialContextFunc, resolver Resolver, network, host, port string, delay time.Duration) (net.Conn, error) {
+	addrs, err := resolveAddresses(ctx, resolver, network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs = interleaveAddresses(addrs)
+	if len(addrs) == 1 {
+		return dial(ctx, network, net.JoinHostPort(addrs[0].String(), port))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult)
+	attempt := func(addr net.IPAddr) {
+		conn, err := dial(ctx, network, net.JoinHostPort(addr.String(), port))
+
+		select {
+		case results <- dialResult{conn, err}:
+		case <-ctx.Done():
+			if conn != nil {
+				_ = conn.Close()
+			}
+		}
+	}
+
+	var errs []error
+	started, finished := 0, 0
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	startNext := func() {
+		go attempt(addrs[started])
+		started++
+		timer.Reset(delay)
+	}
+	startNext()
+
+	for finished < len(addrs) {
+		select {
+		case result := <-results:
+			finished++
+			if result.err == nil {
+				return result.conn, nil
+			}
+			errs = append(errs, result.err)
+
+			// The attempt failed, so don't wait for the delay to try the next address.
+			if started < len(addrs) {
+				startNext()
+			}
+		case <-timer.C:
+			if started < len(addrs) {
+				startNext()
+			}
+		case <-ctx.Done():
+			return nil, errors.Join(append(errs, ctx.Err())...)
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
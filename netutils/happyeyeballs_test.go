@@ -0,0 +1,73 @@
+package netutils
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestInterleaveAddresses(t *testing.T) {
+	var addrs []net.IPAddr
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "2001:db8::1", "192.0.2.3", "2001:db8::2"} {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	var got []string
+	for _, addr := range interleaveAddresses(addrs) {
+		got = append(got, addr.String())
+	}
+
+	want := []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2", "192.0.2.3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestDialEmptyHost(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failing := ResolverFunc(func(_ context.Context, host string) ([]net.IPAddr, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	})
+
+	// As with net.Dial, an empty host is the local system, and is not looked up.
+	for name, opts := range map[string]DialOptions{
+		"happy eyeballs": {ConnectionAttemptDelay: DefaultConnectionAttemptDelay},
+		"sequential":     {Resolver: failing},
+	} {
+		conn, err := DialTCP[testMessage]("", port, opts)
+		if err != nil {
+			t.Errorf("%s: dialling an empty host should reach the local system, got %v", name, err)
+			continue
+		}
+		_ = conn.Close()
+	}
+
+	addrs, err := resolveAddresses(context.Background(), failing, "tcp4", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || !addrs[0].IP.IsLoopback() {
+		t.Errorf("empty host should resolve to the IPv4 loopback address on tcp4, got %v", addrs)
+	}
+}
@@ -0,0 +1,174 @@
+package netutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrHeartbeatTimeout is surfaced by operations on a connection that was closed because
+// its peer failed to answer too many heartbeat pings in a row.
+var ErrHeartbeatTimeout = errors.New("peer failed to answer heartbeat pings")
+
+const (
+	controlPing byte = iota + 1
+	controlPong
+)
+
+// HeartbeatOptions is a struct used by StartHeartbeat to define certain optional
+// parameters.
+type HeartbeatOptions struct {
+	// Interval is the time between each ping.
+	Interval time.Duration
+	// MaxMissed is the amount of consecutive pings that can go unanswered before the
+	// connection is closed.
+	MaxMissed int
+}
+
+func defaultHeartbeatOptions() HeartbeatOptions {
+	return HeartbeatOptions{
+		Interval:  15 * time.Second,
+		MaxMissed: 3,
+	}
+}
+
+// StartHeartbeat starts periodically pinging the peer in the background. Pings are sent
+// as control frames, which are answered automatically by Receive on the other side and
+// are never returned to the caller, so heartbeats are only usable on connections that
+// exchange values using Send and Receive. As pongs are also processed by Receive, the
+// connection must be read from regularly for them to be counted.
+//
+// If MaxMissed pings in a row go unanswered, the connection is closed and subsequent
+// operations return an error wrapping ErrHeartbeatTimeout. The heartbeat stops when the
+// connection is closed or StopHeartbeat is called.
+//
+// This takes a variadic parameter of type HeartbeatOptions. If no HeartbeatOptions are
+// supplied, then the defaults are used. If more than one HeartbeatOptions are supplied
+// then only the first will be used.
+func (tc *AsymmetricTypedConnection[S, R]) StartHeartbeat(opts ...HeartbeatOptions) error {
+	options := defaultHeartbeatOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Interval <= 0 {
+		return fmt.Errorf("heartbeat interval must be positive, got %s", options.Interval)
+	}
+	if options.MaxMissed <= 0 {
+		return fmt.Errorf("heartbeat max missed must be positive, got %d", options.MaxMissed)
+	}
+	if tc.state == nil {
+		return errors.New("connection was not created with a constructor")
+	}
+
+	tc.state.mu.Lock()
+	if tc.state.closed {
+		tc.state.mu.Unlock()
+		return net.ErrClosed
+	}
+	if tc.state.heartbeatStop != nil {
+		tc.state.mu.Unlock()
+		return errors.New("heartbeat has already been started")
+	}
+	stop := make(chan struct{})
+	tc.state.heartbeatStop = stop
+	tc.state.outstandingPings = 0
+	tc.state.mu.Unlock()
+
+	tc.OnClose(tc.StopHeartbeat)
+
+	go tc.heartbeat(options, stop)
+
+	return nil
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) heartbeat(options HeartbeatOptions, stop chan struct{}) {
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		tc.state.mu.Lock()
+		missed := tc.state.outstandingPings >= options.MaxMissed
+		tc.state.outstandingPings++
+		tc.state.mu.Unlock()
+
+		if missed {
+			tc.closeWithError(ErrHeartbeatTimeout)
+			return
+		}
+
+		if err := tc.writeControlFrame(controlPing, time.Now().UnixNano()); err != nil {
+			return
+		}
+	}
+}
+
+// StopHeartbeat stops the heartbeat started by StartHeartbeat, if any.
+func (tc *AsymmetricTypedConnection[S, R]) StopHeartbeat() {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	if tc.state.heartbeatStop != nil {
+		close(tc.state.heartbeatStop)
+		tc.state.heartbeatStop = nil
+	}
+}
+
+// RTT returns the round-trip time measured by the most recently answered heartbeat ping,
+// or zero if no ping has been answered yet.
+func (tc *AsymmetricTypedConnection[S, R]) RTT() time.Duration {
+	if tc.state == nil {
+		return 0
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	return tc.state.rtt
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) writeControlFrame(kind byte, stamp int64) error {
+	payload := binary.BigEndian.AppendUint64([]byte{kind}, uint64(stamp))
+
+	unlock := tc.lockWrite()
+	defer unlock()
+
+	_, err := tc.conn.Write(appendControlFrame(nil, payload))
+	return err
+}
+
+// handleControlFrame answers pings and records the round-trip time of pongs.
+func (tc *AsymmetricTypedConnection[S, R]) handleControlFrame(payload []byte) error {
+	if len(payload) != 9 {
+		return fmt.Errorf("malformed control frame of %d bytes", len(payload))
+	}
+
+	stamp := int64(binary.BigEndian.Uint64(payload[1:]))
+
+	switch payload[0] {
+	case controlPing:
+		return tc.writeControlFrame(controlPong, stamp)
+	case controlPong:
+		if tc.state != nil {
+			tc.state.mu.Lock()
+			tc.state.rtt = time.Since(time.Unix(0, stamp))
+			tc.state.outstandingPings = 0
+			tc.state.mu.Unlock()
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown control frame kind %d", payload[0])
+	}
+}
@@ -0,0 +1,103 @@
+package netutils
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatRTT(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer client.Close()
+	defer server.Close()
+
+	if client.RTT() != 0 {
+		t.Errorf("RTT should be zero before any ping is answered, got %s", client.RTT())
+	}
+
+	// Pings are answered by Receive on the server, and pongs are processed by Receive on
+	// the client, neither of which return them.
+	go func() {
+		var message testMessage
+		_, _ = server.Receive(&message)
+	}()
+	received := make(chan error, 1)
+	go func() {
+		var message testMessage
+		_, err := client.Receive(&message)
+		received <- err
+	}()
+
+	if err := client.StartHeartbeat(HeartbeatOptions{Interval: 10 * time.Millisecond, MaxMissed: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StartHeartbeat(); err == nil {
+		t.Error("starting a second heartbeat should fail")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for client.RTT() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no ping was answered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	client.StopHeartbeat()
+
+	if _, err := server.Send(testMessage{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-received; err != nil {
+		t.Errorf("control frames should not be returned from Receive, got %v", err)
+	}
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	// The peer reads everything, but never answers a ping.
+	go func() { _, _ = io.Copy(io.Discard, b) }()
+
+	client := NewTypedConnection[testMessage](a, ConnectionTypeTCP)
+	defer client.Close()
+
+	closed := make(chan struct{})
+	client.OnClose(func() { close(closed) })
+
+	if err := client.StartHeartbeat(HeartbeatOptions{Interval: 10 * time.Millisecond, MaxMissed: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection should be closed after too many missed pings")
+	}
+
+	if _, err := client.Send(testMessage{Text: "hello"}); !errors.Is(err, ErrHeartbeatTimeout) {
+		t.Errorf("expected ErrHeartbeatTimeout, got %v", err)
+	}
+}
+
+func TestHeartbeatOptionsValidation(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer client.Close()
+	defer server.Close()
+
+	for _, options := range []HeartbeatOptions{
+		{Interval: 0, MaxMissed: 3},
+		{Interval: time.Second, MaxMissed: 0},
+	} {
+		if err := client.StartHeartbeat(options); err == nil {
+			t.Errorf("starting a heartbeat with %+v should fail", options)
+		}
+	}
+
+	_ = client.Close()
+	if err := client.StartHeartbeat(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("starting a heartbeat on a closed connection should fail with net.ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package netutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultContentType is the content type used for Convertable payloads sent over HTTP
+// when no other is given. Convertable types choose their own encoding, so nothing more
+// specific can be assumed.
+const DefaultContentType = "application/octet-stream"
+
+// HTTPOptions is a struct used by PostTyped and PostTypedContext to define certain
+// optional parameters.
+type HTTPOptions struct {
+	// Client is used to send the request. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// ContentType is sent as the Content-Type header of the request.
+	ContentType string
+
+	// Header holds any extra headers to send with the request.
+	Header http.Header
+}
+
+func defaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		Client:      http.DefaultClient,
+		ContentType: DefaultContentType,
+	}
+}
+
+// PostTyped marshals value using its Convertable implementation and sends it as the body
+// of a POST request to url, so that services can expose HTTP endpoints that take the same
+// types as their sockets. The response is returned as-is, as with http.Post; its body can
+// be decoded with ReadTypedBody, and must be closed by the caller.
+//
+// This takes a variadic parameter of type HTTPOptions. If no HTTPOptions are supplied,
+// then the defaults are used. If more than one HTTPOptions are supplied then only the
+// first will be used.
+func PostTyped[T Convertable](url string, value T, opts ...HTTPOptions) (*http.Response, error) {
+	return PostTypedContext(context.Background(), url, value, opts...)
+}
+
+// PostTypedContext is like PostTyped, but the request is cancelled if ctx is done before
+// it completes.
+func PostTypedContext[T Convertable](ctx context.Context, url string, value T, opts ...HTTPOptions) (*http.Response, error) {
+	options := defaultHTTPOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	if options.ContentType == "" {
+		options.ContentType = DefaultContentType
+	}
+
+	body, err := appendMarshal(nil, value)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range options.Header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Content-Type", options.ContentType)
+
+	return options.Client.Do(request)
+}
+
+// ReadTypedBody reads body, up to maxSize bytes, and unmarshals it into a T. If maxSize
+// is zero or less, DefaultMaxFrameSize is used. If the body is larger, ErrFrameTooLarge
+// is returned. If the body cannot be unmarshalled, the error is joined with ErrUnmarshal.
+// body is not closed.
+func ReadTypedBody[T Convertable](body io.Reader, maxSize int) (T, error) {
+	var value T
+
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFrameSize
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, int64(maxSize)+1))
+	if err != nil {
+		return value, err
+	}
+	if len(data) > maxSize {
+		return value, ErrFrameTooLarge
+	}
+
+	if err := value.Unmarshal(&value, data); err != nil {
+		return value, errors.Join(ErrUnmarshal, err)
+	}
+
+	return value, nil
+}
+
+// WriteTyped marshals value and writes it as the body of an HTTP response with the given
+// status code and content type. If contentType is empty, DefaultContentType is used.
+func WriteTyped[T Convertable](w http.ResponseWriter, status int, contentType string, value T) error {
+	body, err := appendMarshal(nil, value)
+	if err != nil {
+		return err
+	}
+
+	if contentType == "" {
+		contentType = DefaultContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+
+	_, err = w.Write(body)
+
+	return err
+}
+
+// TypedHandlerOptions is a struct used by TypedHandler to define certain optional
+// parameters.
+type TypedHandlerOptions struct {
+	// MaxBodySize is the largest request body that is accepted. If zero,
+	// DefaultMaxFrameSize is used.
+	MaxBodySize int
+}
+
+func defaultTypedHandlerOptions() TypedHandlerOptions {
+	return TypedHandlerOptions{MaxBodySize: DefaultMaxFrameSize}
+}
+
+// TypedHandler returns an http.Handler that decodes the body of each POST, PUT, or PATCH
+// request into a T and passes it to handle, which writes the response, for example with
+// WriteTyped. Requests with other methods are answered with 405 Method Not Allowed,
+// bodies that are too large with 413 Request Entity Too Large, and bodies that cannot be
+// unmarshalled with 400 Bad Request, without handle being called.
+//
+// This takes a variadic parameter of type TypedHandlerOptions. If no TypedHandlerOptions
+// are supplied, then the defaults are used. If more than one TypedHandlerOptions are
+// supplied then only the first will be used.
+func TypedHandler[T Convertable](handle func(w http.ResponseWriter, r *http.Request, value T), opts ...TypedHandlerOptions) http.Handler {
+	options := defaultTypedHandlerOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			w.Header().Set("Allow", "POST, PUT, PATCH")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		value, err := ReadTypedBody[T](r.Body, options.MaxBodySize)
+		switch {
+		case errors.Is(err, ErrFrameTooLarge):
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		handle(w, r, value)
+	})
+}
@@ -0,0 +1,38 @@
+package netutils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPBridge(t *testing.T) {
+	server := httptest.NewServer(TypedHandler(func(w http.ResponseWriter, _ *http.Request, value testMessage) {
+		_ = WriteTyped(w, http.StatusOK, "application/json", otherTestMessage{Number: len(value.Text)})
+	}))
+	defer server.Close()
+
+	response, err := PostTyped(server.URL, testMessage{Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	reply, err := ReadTypedBody[otherTestMessage](response.Body, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Number != 5 {
+		t.Fatalf("received %d, want 5", reply.Number)
+	}
+
+	invalid, err := http.Post(server.URL, DefaultContentType, strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = invalid.Body.Close()
+	if invalid.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid body got status %d, want %d", invalid.StatusCode, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,197 @@
+package netutils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type hubConnection[T Convertable] struct {
+	mu   sync.Mutex
+	conn *TCPTypedConnection[T]
+}
+
+func (hc *hubConnection[T]) write(frame []byte) error {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	_, err := hc.conn.writeFrame(frame)
+	return err
+}
+
+// Hub tracks a set of TCPTypedConnections, usually those accepted by a server, and
+// allows for values to be broadcast to all of them at once. Values are written as frames
+// using the same format as Send, so clients should read them with Receive.
+//
+// Writes to each connection are serialised, so a Hub is safe for concurrent use.
+// Connections that fail to be written to are closed and removed from the Hub.
+type Hub[T Convertable] struct {
+	mu    sync.RWMutex
+	conns map[ConnectionID]*hubConnection[T]
+}
+
+// NewHub creates a new, empty *Hub.
+func NewHub[T Convertable]() *Hub[T] {
+	return &Hub[T]{conns: make(map[ConnectionID]*hubConnection[T])}
+}
+
+// Add starts tracking conn, returning its ID. The connection is removed from the Hub
+// once it is closed, even if it is closed elsewhere.
+func (h *Hub[T]) Add(conn *TCPTypedConnection[T]) ConnectionID {
+	id := conn.ID()
+	hc := &hubConnection[T]{conn: conn}
+
+	h.mu.Lock()
+	h.conns[id] = hc
+	h.mu.Unlock()
+
+	// This is registered without holding the lock, as it is called immediately if the
+	// connection has already been closed.
+	conn.OnClose(func() { h.remove(id, hc) })
+
+	return id
+}
+
+// Accept accepts a connection from listener and adds it to the Hub. On success, the new
+// connection and its ID are returned. On failure, an error is returned.
+func (h *Hub[T]) Accept(listener *TCPSocketListener[T]) (ConnectionID, *TCPTypedConnection[T], error) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return h.Add(conn), conn, nil
+}
+
+// Remove stops tracking the connection with the given ID without closing it. It returns
+// false if no such connection was being tracked.
+func (h *Hub[T]) Remove(id ConnectionID) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, ok := h.conns[id]
+	delete(h.conns, id)
+
+	return ok
+}
+
+// Len returns the amount of connections tracked by the Hub.
+func (h *Hub[T]) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.conns)
+}
+
+// Send writes value to the connection with the given ID. If the write fails, the
+// connection is closed and removed from the Hub.
+func (h *Hub[T]) Send(id ConnectionID, value T) error {
+	h.mu.RLock()
+	hc, ok := h.conns[id]
+	h.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("no connection with id %d", id)
+	}
+
+	frame, err := marshalFrame(value)
+	if err != nil {
+		return err
+	}
+
+	if err := hc.write(frame); err != nil {
+		h.prune(id, hc)
+		return err
+	}
+
+	return nil
+}
+
+// Broadcast writes value to every connection tracked by the Hub. See BroadcastExcept.
+func (h *Hub[T]) Broadcast(value T) (int, error) {
+	return h.broadcast(value, nil)
+}
+
+// BroadcastExcept writes value to every connection tracked by the Hub apart from the one
+// with the given ID, which is useful for relaying a message from one client to all other
+// clients. Connections are written to concurrently, so one slow peer does not hold up
+// the others. It returns the amount of connections that were successfully written to;
+// an error is only returned if value could not be marshalled.
+func (h *Hub[T]) BroadcastExcept(id ConnectionID, value T) (int, error) {
+	return h.broadcast(value, &id)
+}
+
+func (h *Hub[T]) broadcast(value T, except *ConnectionID) (int, error) {
+	frame, err := marshalFrame(value)
+	if err != nil {
+		return 0, err
+	}
+
+	h.mu.RLock()
+	targets := make(map[ConnectionID]*hubConnection[T], len(h.conns))
+	for id, hc := range h.conns {
+		if except == nil || id != *except {
+			targets[id] = hc
+		}
+	}
+	h.mu.RUnlock()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		delivered int
+	)
+
+	for id, hc := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := hc.write(frame); err != nil {
+				h.prune(id, hc)
+				return
+			}
+
+			mu.Lock()
+			delivered++
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return delivered, nil
+}
+
+func (h *Hub[T]) prune(id ConnectionID, hc *hubConnection[T]) {
+	h.remove(id, hc)
+	_ = hc.conn.Close()
+}
+
+// remove stops tracking hc, as long as it has not been replaced by another connection
+// with the same ID.
+func (h *Hub[T]) remove(id ConnectionID, hc *hubConnection[T]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conns[id] == hc {
+		delete(h.conns, id)
+	}
+}
+
+// Close closes and removes every connection tracked by the Hub.
+func (h *Hub[T]) Close() error {
+	h.mu.Lock()
+	conns := h.conns
+	h.conns = make(map[ConnectionID]*hubConnection[T])
+	h.mu.Unlock()
+
+	var errs []error
+	for _, hc := range conns {
+		if err := hc.conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+package netutils
+
+import (
+	"net"
+	"testing"
+)
+
+// newTestHub creates a Hub tracking count connections, and returns the client side of
+// each one.
+func newTestHub(t *testing.T, count int) (*Hub[testMessage], []ConnectionID, []*TCPTypedConnection[testMessage]) {
+	t.Helper()
+
+	hub := NewHub[testMessage]()
+	t.Cleanup(func() { _ = hub.Close() })
+
+	ids := make([]ConnectionID, 0, count)
+	clients := make([]*TCPTypedConnection[testMessage], 0, count)
+	for range count {
+		a, b := net.Pipe()
+		server := NewTCPTypedConnection[testMessage](a)
+		client := NewTCPTypedConnection[testMessage](b)
+		t.Cleanup(func() { _ = client.Close() })
+
+		ids = append(ids, hub.Add(&server))
+		clients = append(clients, &client)
+	}
+
+	return hub, ids, clients
+}
+
+// receiveAll reads one message from each client concurrently, as writes to a pipe block
+// until they are read, and returns the texts received, or an empty string for clients
+// whose read failed.
+func receiveAll(clients []*TCPTypedConnection[testMessage]) []string {
+	texts := make([]string, len(clients))
+	done := make(chan struct{}, len(clients))
+	for i, client := range clients {
+		go func() {
+			defer func() { done <- struct{}{} }()
+
+			var message testMessage
+			if _, err := client.Receive(&message); err == nil {
+				texts[i] = message.Text
+			}
+		}()
+	}
+
+	for range clients {
+		<-done
+	}
+
+	return texts
+}
+
+func TestHubBroadcast(t *testing.T) {
+	hub, _, clients := newTestHub(t, 3)
+
+	received := make(chan []string)
+	go func() { received <- receiveAll(clients) }()
+
+	delivered, err := hub.Broadcast(testMessage{Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != len(clients) {
+		t.Errorf("broadcast should be delivered to %d connections, got %d", len(clients), delivered)
+	}
+
+	for i, text := range <-received {
+		if text != "hello" {
+			t.Errorf("client %d should receive hello, got %q", i, text)
+		}
+	}
+}
+
+func TestHubBroadcastExcept(t *testing.T) {
+	hub, ids, clients := newTestHub(t, 3)
+
+	received := make(chan []string)
+	go func() { received <- receiveAll(clients[1:]) }()
+
+	delivered, err := hub.BroadcastExcept(ids[0], testMessage{Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != len(clients)-1 {
+		t.Errorf("broadcast should be delivered to %d connections, got %d", len(clients)-1, delivered)
+	}
+
+	for i, text := range <-received {
+		if text != "hello" {
+			t.Errorf("client %d should receive hello, got %q", i+1, text)
+		}
+	}
+}
+
+func TestHubPrune(t *testing.T) {
+	hub, ids, clients := newTestHub(t, 2)
+
+	// A connection that cannot be written to is closed and removed.
+	_ = clients[0].Close()
+	if err := hub.Send(ids[0], testMessage{Text: "hello"}); err == nil {
+		t.Error("sending to a closed peer should fail")
+	}
+	if hub.Len() != 1 {
+		t.Errorf("failed connection should be removed, got %d connections", hub.Len())
+	}
+
+	// A connection closed elsewhere is removed without being written to.
+	a, b := net.Pipe()
+	defer b.Close()
+
+	server := NewTCPTypedConnection[testMessage](a)
+	hub.Add(&server)
+	if hub.Len() != 2 {
+		t.Fatalf("hub should have 2 connections, got %d", hub.Len())
+	}
+
+	_ = server.Close()
+	if hub.Len() != 1 {
+		t.Errorf("closed connection should be removed, got %d connections", hub.Len())
+	}
+
+	// Adding a connection that is already closed does not track it.
+	hub.Add(&server)
+	if hub.Len() != 1 {
+		t.Errorf("already closed connection should not be tracked, got %d connections", hub.Len())
+	}
+}
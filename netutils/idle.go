@@ -0,0 +1,75 @@
+package netutils
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrIdleTimeout is surfaced by operations on a connection that was closed because no
+// values were read from or written to it within its idle timeout.
+var ErrIdleTimeout = errors.New("connection was idle for too long")
+
+// SetIdleTimeout sets the idle timeout of the connection. If no values are read or
+// written within timeout, the connection is closed and subsequent operations return an
+// error wrapping ErrIdleTimeout. Heartbeat frames do not count as activity. A zero or
+// negative timeout disables the idle timeout.
+//
+// Setting the idle timeout counts as activity, so the timeout starts from when this
+// function is called.
+func (tc *AsymmetricTypedConnection[S, R]) SetIdleTimeout(timeout time.Duration) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	if tc.state.idleTimer != nil {
+		tc.state.idleTimer.Stop()
+		tc.state.idleTimer = nil
+	}
+
+	tc.state.idleTimeout = timeout
+	tc.state.lastActivity = time.Now()
+
+	if timeout <= 0 || tc.state.closed {
+		return
+	}
+
+	tc.state.idleTimer = time.AfterFunc(timeout, tc.checkIdle)
+}
+
+// checkIdle closes the connection if it has been idle for longer than its idle timeout,
+// and otherwise schedules itself to run again when the timeout would next elapse.
+func (tc *AsymmetricTypedConnection[S, R]) checkIdle() {
+	tc.state.mu.Lock()
+
+	if tc.state.idleTimer == nil || tc.state.closed {
+		tc.state.mu.Unlock()
+		return
+	}
+
+	remaining := tc.state.idleTimeout - time.Since(tc.state.lastActivity)
+	if remaining > 0 {
+		tc.state.idleTimer.Reset(remaining)
+		tc.state.mu.Unlock()
+
+		return
+	}
+
+	tc.state.idleTimer = nil
+	tc.state.mu.Unlock()
+
+	tc.closeWithError(ErrIdleTimeout)
+}
+
+// touch records activity on the connection for the idle timeout.
+func (tc *AsymmetricTypedConnection[S, R]) touch() {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	tc.state.lastActivity = time.Now()
+	tc.state.mu.Unlock()
+}
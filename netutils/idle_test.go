@@ -0,0 +1,24 @@
+package netutils
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	tc := NewTypedConnection[testMessage](client, ConnectionTypeTCP)
+	tc.SetIdleTimeout(20 * time.Millisecond)
+
+	var message testMessage
+	if _, err := tc.Receive(&message); !errors.Is(err, ErrIdleTimeout) {
+		t.Errorf("receive on an idle connection should return ErrIdleTimeout, got %v", err)
+	}
+	if _, err := tc.Send(testMessage{}); !errors.Is(err, ErrIdleTimeout) {
+		t.Errorf("send after an idle timeout should return ErrIdleTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package netutils
+
+import (
+	"slices"
+	"sync"
+)
+
+// ReadHandler reads a single value from a connection into data, returning the amount of
+// bytes read.
+type ReadHandler[R Convertable] func(data *R) (int, error)
+
+// WriteHandler writes a single value to a connection, returning the amount of bytes
+// written.
+type WriteHandler[S Convertable] func(value S) (int, error)
+
+// ReadInterceptor wraps a ReadHandler, for example to log, validate, or modify every value
+// that is read. An interceptor decides whether and when to call next, so it can also
+// short-circuit a read by returning an error.
+type ReadInterceptor[R Convertable] func(next ReadHandler[R]) ReadHandler[R]
+
+// WriteInterceptor wraps a WriteHandler, for example to log, validate, or modify every
+// value that is written. An interceptor decides whether and when to call next, so it can
+// also reject a write by returning an error without calling next.
+type WriteInterceptor[S Convertable] func(next WriteHandler[S]) WriteHandler[S]
+
+type interceptors[S, R Convertable] struct {
+	mu    sync.RWMutex
+	read  []ReadInterceptor[R]
+	write []WriteInterceptor[S]
+}
+
+// UseRead appends interceptors to the chain that wraps every Read, Receive, and ReadFrom
+// on the connection. Interceptors compose like HTTP middleware: the first interceptor to be
+// registered is the outermost, so it sees a read first and its result last.
+func (tc *AsymmetricTypedConnection[S, R]) UseRead(interceptors ...ReadInterceptor[R]) {
+	if tc.interceptors == nil {
+		return
+	}
+
+	tc.interceptors.mu.Lock()
+	defer tc.interceptors.mu.Unlock()
+
+	tc.interceptors.read = append(tc.interceptors.read, interceptors...)
+}
+
+// UseWrite appends interceptors to the chain that wraps every Write, Send, and WriteTo on
+// the connection, as well as each value of a WriteBatch. Interceptors compose like HTTP
+// middleware: the first interceptor to be registered is the outermost, so it sees a
+// write first and its result last.
+//
+// Values that have already been marshalled into frames bypass the chain. These are the
+// values written by an AsyncWriter, which marshals them when they are queued, and by
+// Hub.Broadcast and SendToRoom, which marshal each value once for every connection.
+func (tc *AsymmetricTypedConnection[S, R]) UseWrite(interceptors ...WriteInterceptor[S]) {
+	if tc.interceptors == nil {
+		return
+	}
+
+	tc.interceptors.mu.Lock()
+	defer tc.interceptors.mu.Unlock()
+
+	tc.interceptors.write = append(tc.interceptors.write, interceptors...)
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) interceptRead(handler ReadHandler[R]) ReadHandler[R] {
+	if tc.interceptors == nil {
+		return handler
+	}
+
+	tc.interceptors.mu.RLock()
+	chain := slices.Clone(tc.interceptors.read)
+	tc.interceptors.mu.RUnlock()
+
+	for _, interceptor := range slices.Backward(chain) {
+		handler = interceptor(handler)
+	}
+
+	return handler
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) interceptWrite(handler WriteHandler[S]) WriteHandler[S] {
+	if tc.interceptors == nil {
+		return handler
+	}
+
+	tc.interceptors.mu.RLock()
+	chain := slices.Clone(tc.interceptors.write)
+	tc.interceptors.mu.RUnlock()
+
+	for _, interceptor := range slices.Backward(chain) {
+		handler = interceptor(handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,136 @@
+package netutils
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// suffix returns interceptors that append text to the text of every value.
+func suffix(text string) (ReadInterceptor[testMessage], WriteInterceptor[testMessage]) {
+	read := func(next ReadHandler[testMessage]) ReadHandler[testMessage] {
+		return func(data *testMessage) (int, error) {
+			n, err := next(data)
+			data.Text += text
+			return n, err
+		}
+	}
+	write := func(next WriteHandler[testMessage]) WriteHandler[testMessage] {
+		return func(value testMessage) (int, error) {
+			value.Text += text
+			return next(value)
+		}
+	}
+
+	return read, write
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	tc := NewTypedConnection[testMessage](client, ConnectionTypeTCP)
+
+	var order []string
+	tag := func(name string) WriteInterceptor[testMessage] {
+		return func(next WriteHandler[testMessage]) WriteHandler[testMessage] {
+			return func(value testMessage) (int, error) {
+				order = append(order, name)
+				value.Text += name
+				return next(value)
+			}
+		}
+	}
+	tc.UseWrite(tag("a"), tag("b"))
+
+	go func() { _, _ = tc.Send(testMessage{Text: ">"}) }()
+
+	peer := NewTypedConnection[testMessage](server, ConnectionTypeTCP)
+
+	var message testMessage
+	if _, err := peer.Receive(&message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Text != ">ab" {
+		t.Errorf("interceptors should run in registration order, got %q", message.Text)
+	}
+}
+
+func TestInterceptorsWrapBatch(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer client.Close()
+	defer server.Close()
+
+	_, write := suffix("!")
+	client.UseWrite(write)
+
+	go func() { _, _ = client.WriteBatch([]testMessage{{Text: "a"}, {Text: "b"}}) }()
+
+	for _, expected := range []string{"a!", "b!"} {
+		var message testMessage
+		if _, err := server.Receive(&message); err != nil {
+			t.Fatal(err)
+		}
+		if message.Text != expected {
+			t.Errorf("batched values should be intercepted, expected %q, got %q", expected, message.Text)
+		}
+	}
+
+	// Rejecting any value of a batch stops all of it from being written.
+	rejected := errors.New("rejected")
+	client.UseWrite(func(next WriteHandler[testMessage]) WriteHandler[testMessage] {
+		return func(value testMessage) (int, error) {
+			if value.Text == "b!" {
+				return 0, rejected
+			}
+			return next(value)
+		}
+	})
+	if n, err := client.WriteBatch([]testMessage{{Text: "a"}, {Text: "b"}}); !errors.Is(err, rejected) || n != 0 {
+		t.Errorf("rejected batch should not be written, got %d, %v", n, err)
+	}
+}
+
+func TestInterceptorsWrapUDP(t *testing.T) {
+	receiving, err := ListenUDP[testMessage]("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := receiving.Conn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	sending, err := ListenUDP[testMessage]("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := sending.Conn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	_, write := suffix("<")
+	sender.UseWrite(write)
+	read, _ := suffix(">")
+	receiver.UseRead(read)
+
+	if _, err := sender.WriteTo(testMessage{Text: "udp"}, receiver.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	var message testMessage
+	_, addr, err := receiver.ReadFrom(&message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Text != "udp<>" {
+		t.Errorf("WriteTo and ReadFrom should be intercepted, got %q", message.Text)
+	}
+	if addr == nil || addr.String() != sender.LocalAddr().String() {
+		t.Errorf("ReadFrom should return the address of the sender, got %v", addr)
+	}
+}
@@ -0,0 +1,250 @@
+package netutils
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ParseIP extracts the IP address from address, which is useful for filtering peers by
+// their RemoteAddr. The following forms of address are accepted:
+//
+//   - a string holding an IP address, or an address of the form "ip:port" or
+//     "[ipv6]:port";
+//   - a net.IP or netip.Addr;
+//   - a netip.AddrPort;
+//   - a *net.TCPAddr, *net.UDPAddr, or *net.IPAddr;
+//   - any other net.Addr, whose String is parsed as above.
+//
+// IPv4 addresses mapped into IPv6 are unmapped, so that they compare equal to their plain
+// IPv4 forms. Host names are not resolved.
+func ParseIP(address any) (netip.Addr, error) {
+	var ip netip.Addr
+
+	switch address := address.(type) {
+	case string:
+		host := address
+		if h, _, err := net.SplitHostPort(address); err == nil {
+			host = h
+		} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1]
+		}
+
+		parsed, err := netip.ParseAddr(host)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("invalid IP address %q: %w", address, err)
+		}
+		ip = parsed
+	case net.IP:
+		parsed, ok := netip.AddrFromSlice(address)
+		if !ok {
+			return netip.Addr{}, fmt.Errorf("invalid IP address %v", address)
+		}
+		ip = parsed
+	case netip.Addr:
+		ip = address
+	case netip.AddrPort:
+		ip = address.Addr()
+	case *net.TCPAddr:
+		if address == nil {
+			return netip.Addr{}, errors.New("address must not be nil")
+		}
+
+		return ParseIP(address.IP)
+	case *net.UDPAddr:
+		if address == nil {
+			return netip.Addr{}, errors.New("address must not be nil")
+		}
+
+		return ParseIP(address.IP)
+	case *net.IPAddr:
+		if address == nil {
+			return netip.Addr{}, errors.New("address must not be nil")
+		}
+
+		return ParseIP(address.IP)
+	case net.Addr:
+		return ParseIP(address.String())
+	case nil:
+		return netip.Addr{}, errors.New("address must not be nil")
+	default:
+		return netip.Addr{}, fmt.Errorf("unsupported address type %T", address)
+	}
+
+	if !ip.IsValid() {
+		return netip.Addr{}, errors.New("invalid IP address")
+	}
+
+	return ip.Unmap(), nil
+}
+
+// IsPrivate reports whether address, in any form accepted by ParseIP, is in a private
+// range: 10.0.0.0/8, 172.16.0.0/12, and 192.168.0.0/16 for IPv4, as defined by RFC 1918,
+// and fc00::/7 for IPv6, as defined by RFC 4193. It returns false if address cannot be
+// parsed.
+func IsPrivate(address any) bool {
+	ip, err := ParseIP(address)
+	return err == nil && ip.IsPrivate()
+}
+
+// IsLoopback reports whether address, in any form accepted by ParseIP, is a loopback
+// address, such as 127.0.0.1 or ::1. It returns false if address cannot be parsed.
+func IsLoopback(address any) bool {
+	ip, err := ParseIP(address)
+	return err == nil && ip.IsLoopback()
+}
+
+// CIDRContains reports whether address, in any form accepted by ParseIP, is within the
+// network given in CIDR notation, such as "192.168.0.0/16". An error is returned if
+// either cannot be parsed.
+func CIDRContains(cidr string, address any) (bool, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return false, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+	}
+
+	ip, err := ParseIP(address)
+	if err != nil {
+		return false, err
+	}
+
+	return prefix.Masked().Contains(ip), nil
+}
+
+// PrefixAddrs returns an iterator over every address in prefix, in order, starting with
+// its network address. For large IPv6 prefixes, the caller should stop iterating rather
+// than expect it to end.
+func PrefixAddrs(prefix netip.Prefix) iter.Seq[netip.Addr] {
+	return func(yield func(netip.Addr) bool) {
+		if !prefix.IsValid() {
+			return
+		}
+
+		prefix = prefix.Masked()
+		for ip := prefix.Addr(); ip.IsValid() && prefix.Contains(ip); ip = ip.Next() {
+			if !yield(ip) {
+				return
+			}
+		}
+	}
+}
+
+// AddrRange returns an iterator over every address from first to last inclusive, in
+// order. Nothing is yielded if first comes after last, or if they are of different
+// families.
+func AddrRange(first, last netip.Addr) iter.Seq[netip.Addr] {
+	return func(yield func(netip.Addr) bool) {
+		if !first.IsValid() || !last.IsValid() || first.BitLen() != last.BitLen() {
+			return
+		}
+
+		for ip := first; ip.IsValid() && ip.Compare(last) <= 0; ip = ip.Next() {
+			if !yield(ip) {
+				return
+			}
+		}
+	}
+}
+
+// InterfaceAddr is an address assigned to a network interface of the local system.
+type InterfaceAddr struct {
+	// Interface is the name of the interface, such as "eth0".
+	Interface string
+
+	// Prefix holds the address and the length of its network.
+	Prefix netip.Prefix
+}
+
+// Addr returns the address itself.
+func (ia InterfaceAddr) Addr() netip.Addr {
+	return ia.Prefix.Addr()
+}
+
+// InterfaceAddrsOptions is a struct used by InterfaceAddrs to define certain optional
+// parameters.
+type InterfaceAddrsOptions struct {
+	// Network is "ip4" or "ip6" to only return addresses of that family, or "ip" for
+	// both.
+	Network string
+
+	// Loopback includes the addresses of loopback interfaces.
+	Loopback bool
+
+	// Down includes the addresses of interfaces that are not up.
+	Down bool
+}
+
+func defaultInterfaceAddrsOptions() InterfaceAddrsOptions {
+	return InterfaceAddrsOptions{Network: "ip"}
+}
+
+// InterfaceAddrs returns the addresses assigned to the network interfaces of the local
+// system, which can be used to pick an address to bind to or to advertise to peers. By
+// default, only the addresses of interfaces that are up and are not loopback interfaces
+// are returned.
+//
+// This takes a variadic parameter of type InterfaceAddrsOptions. If no
+// InterfaceAddrsOptions are supplied, then the defaults are used. If more than one
+// InterfaceAddrsOptions are supplied then only the first will be used.
+func InterfaceAddrs(opts ...InterfaceAddrsOptions) ([]InterfaceAddr, error) {
+	options := defaultInterfaceAddrsOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	switch options.Network {
+	case "", "ip", "ip4", "ip6":
+	default:
+		return nil, fmt.Errorf("unsupported network %q", options.Network)
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []InterfaceAddr
+	for _, ifi := range interfaces {
+		if ifi.Flags&net.FlagUp == 0 && !options.Down {
+			continue
+		}
+		if ifi.Flags&net.FlagLoopback != 0 && !options.Loopback {
+			continue
+		}
+
+		ifaddrs, err := ifi.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("could not get addresses of %s: %w", ifi.Name, err)
+		}
+
+		for _, ifaddr := range ifaddrs {
+			ipnet, ok := ifaddr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+
+			ip, err := ParseIP(ipnet.IP)
+			if err != nil {
+				continue
+			}
+			if (options.Network == "ip4" && !ip.Is4()) || (options.Network == "ip6" && !ip.Is6()) {
+				continue
+			}
+
+			ones, _ := ipnet.Mask.Size()
+			if ip.Is4() && ones > 32 {
+				ones -= 96
+			}
+
+			addrs = append(addrs, InterfaceAddr{
+				Interface: ifi.Name,
+				Prefix:    netip.PrefixFrom(ip, ones),
+			})
+		}
+	}
+
+	return addrs, nil
+}
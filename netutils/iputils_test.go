@@ -0,0 +1,85 @@
+package netutils
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestParseIP(t *testing.T) {
+	tests := []struct {
+		address any
+		want    string
+	}{
+		{"10.0.0.1", "10.0.0.1"},
+		{"10.0.0.1:80", "10.0.0.1"},
+		{"[::1]:80", "::1"},
+		{"[fe80::1]", "fe80::1"},
+		{"::ffff:192.168.1.1", "192.168.1.1"},
+		{net.IPv4(192, 168, 1, 1), "192.168.1.1"},
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 80}, "127.0.0.1"},
+		{netip.MustParseAddrPort("[2001:db8::1]:443"), "2001:db8::1"},
+	}
+
+	for _, test := range tests {
+		ip, err := ParseIP(test.address)
+		if err != nil {
+			t.Errorf("ParseIP(%v) returned %v", test.address, err)
+			continue
+		}
+		if ip.String() != test.want {
+			t.Errorf("ParseIP(%v) = %s, want %s", test.address, ip, test.want)
+		}
+	}
+
+	if _, err := ParseIP("example.com"); err == nil {
+		t.Error("host names should not be accepted")
+	}
+}
+
+func TestIPHelpers(t *testing.T) {
+	if !IsPrivate("192.168.1.10:5000") || !IsPrivate("fd00::1") || IsPrivate("8.8.8.8") {
+		t.Error("IsPrivate classified an address incorrectly")
+	}
+	if !IsLoopback(&net.UDPAddr{IP: net.IPv6loopback}) || IsLoopback("10.0.0.1") {
+		t.Error("IsLoopback classified an address incorrectly")
+	}
+
+	if ok, err := CIDRContains("10.1.0.0/16", "10.1.255.3:22"); err != nil || !ok {
+		t.Errorf("CIDRContains returned %v, %v", ok, err)
+	}
+	if ok, err := CIDRContains("10.1.0.0/16", "10.2.0.1"); err != nil || ok {
+		t.Errorf("CIDRContains returned %v, %v", ok, err)
+	}
+
+	got := slices.Collect(PrefixAddrs(netip.MustParsePrefix("192.168.0.5/30")))
+	want := []netip.Addr{
+		netip.MustParseAddr("192.168.0.4"), netip.MustParseAddr("192.168.0.5"),
+		netip.MustParseAddr("192.168.0.6"), netip.MustParseAddr("192.168.0.7"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("PrefixAddrs yielded %v, want %v", got, want)
+	}
+
+	got = slices.Collect(AddrRange(netip.MustParseAddr("10.0.0.254"), netip.MustParseAddr("10.0.1.1")))
+	if len(got) != 4 || got[2] != netip.MustParseAddr("10.0.1.0") {
+		t.Errorf("AddrRange yielded %v", got)
+	}
+}
+
+func TestInterfaceAddrs(t *testing.T) {
+	addrs, err := InterfaceAddrs(InterfaceAddrsOptions{Network: "ip4", Loopback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, addr := range addrs {
+		if !addr.Addr().Is4() {
+			t.Errorf("%s of %s is not an IPv4 address", addr.Prefix, addr.Interface)
+		}
+		if addr.Addr().IsLoopback() && addr.Prefix.Bits() != 8 {
+			t.Errorf("loopback address has prefix %s, want a /8", addr.Prefix)
+		}
+	}
+}
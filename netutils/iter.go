@@ -0,0 +1,71 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"time"
+)
+
+// Messages returns an iterator over the values received from the connection using
+// Receive, for use with range:
+//
+//	for message, err := range conn.Messages(ctx) {
+//		if err != nil {
+//			// handle err
+//			break
+//		}
+//		// use message
+//	}
+//
+// Iteration ends silently when the peer closes the connection. Any other error is
+// yielded once, after which iteration ends. If ctx is done while waiting for a value, the
+// pending read is interrupted and ctx.Err() is yielded. A read deadline set with
+// SetReadDeadline is restored afterwards.
+//
+// This takes a variadic parameter of type ReadOption, which is passed to Receive.
+func (tc *AsymmetricTypedConnection[S, R]) Messages(ctx context.Context, opts ...ReadOption) iter.Seq2[R, error] {
+	return func(yield func(R, error) bool) {
+		interrupted := make(chan struct{})
+		stop := context.AfterFunc(ctx, func() {
+			defer close(interrupted)
+			_ = tc.conn.SetReadDeadline(time.Unix(1, 0))
+		})
+		defer func() {
+			// Only the deadline used to interrupt the read is undone, leaving any that
+			// was set with SetReadDeadline in place.
+			if !stop() {
+				<-interrupted
+				_ = tc.conn.SetReadDeadline(tc.userDeadline(readDirection))
+			}
+		}()
+
+		for {
+			if err := ctx.Err(); err != nil {
+				var zero R
+				yield(zero, err)
+
+				return
+			}
+
+			var message R
+			if _, err := tc.Receive(&message, opts...); err != nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				} else if errors.Is(err, io.EOF) {
+					return
+				}
+
+				var zero R
+				yield(zero, err)
+
+				return
+			}
+
+			if !yield(message, nil) {
+				return
+			}
+		}
+	}
+}
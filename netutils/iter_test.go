@@ -0,0 +1,63 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMessages(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer server.Close()
+
+	go func() {
+		for _, text := range []string{"one", "two", "three"} {
+			_, _ = client.Send(testMessage{Text: text})
+		}
+		_ = client.Close()
+	}()
+
+	var received []string
+	for message, err := range server.Messages(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, message.Text)
+	}
+
+	if len(received) != 3 || received[0] != "one" || received[2] != "three" {
+		t.Errorf("expected every message until the peer closed, got %v", received)
+	}
+}
+
+func TestMessagesCancelKeepsReadDeadline(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer client.Close()
+	defer server.Close()
+
+	deadline := time.Now().Add(150 * time.Millisecond)
+	if err := server.SetReadDeadline(deadline); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	for _, err := range server.Messages(ctx) {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	}
+
+	// The read that follows must neither fail at once, from the deadline that interrupted
+	// Messages, nor block past the deadline that was set beforehand.
+	var message testMessage
+	if _, err := server.Receive(&message); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", os.ErrDeadlineExceeded, err)
+	}
+	if now := time.Now(); now.Before(deadline) {
+		t.Errorf("expected the read to wait until %s, returned at %s", deadline, now)
+	}
+}
@@ -0,0 +1,96 @@
+package netutils
+
+import (
+	"context"
+	"log/slog"
+	"net"
+)
+
+// ListenOptions is a struct used by ListenTCP and ListenUDP to define certain optional
+// parameters.
+type ListenOptions struct {
+	// ListenConfig, if not nil, is used to bind the socket, which allows for socket
+	// options to be set before binding.
+	ListenConfig *net.ListenConfig
+
+	// SocketOptions, if not nil, are applied to every connection accepted by a TCP
+	// listener. See TCPSocketListener.SetSocketOptions.
+	SocketOptions *SocketOptions
+
+	// Logger, if not nil, is used by the listener and the connections that it creates.
+	Logger *slog.Logger
+}
+
+func defaultListenOptions() ListenOptions {
+	return ListenOptions{}
+}
+
+func (lo ListenOptions) config() *net.ListenConfig {
+	if lo.ListenConfig != nil {
+		return lo.ListenConfig
+	}
+
+	return &net.ListenConfig{}
+}
+
+// ListenTCP resolves and binds host:port, and returns a new *TCPSocketListener for it.
+// host and port are normalised in the same way as for DialTCP, so an empty host listens
+// on all interfaces and port "0" picks a free port, which can be found with Addr. On
+// failure, an error is returned.
+//
+// This takes a variadic parameter of type ListenOptions. If no ListenOptions are
+// supplied, then the defaults are used. If more than one ListenOptions are supplied then
+// only the first will be used.
+func ListenTCP[T Convertable](host, port string, opts ...ListenOptions) (*TCPSocketListener[T], error) {
+	options := defaultListenOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	host, port, err := normaliseHostPort(host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := options.config().Listen(context.Background(), "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	tsl := NewTypedListener[T](listener)
+	tsl.SetLogger(options.Logger)
+	if options.SocketOptions != nil {
+		tsl.SetSocketOptions(options.SocketOptions)
+	}
+
+	return tsl, nil
+}
+
+// ListenUDP resolves and binds host:port, and returns a new *UDPSocketListener for it.
+// Unlike NewTypedUDPSocketListener, which always binds 0.0.0.0, host may be any local
+// address, including an IPv6 one. See ListenTCP.
+//
+// This takes a variadic parameter of type ListenOptions. If no ListenOptions are
+// supplied, then the defaults are used. If more than one ListenOptions are supplied then
+// only the first will be used.
+func ListenUDP[T Convertable](host, port string, opts ...ListenOptions) (*UDPSocketListener[T], error) {
+	options := defaultListenOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	host, port, err := normaliseHostPort(host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := options.config().ListenPacket(context.Background(), "udp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	usl := NewTypedUDPSocketListenerFromConn[T](conn.(net.Conn))
+	usl.connection.SetLogger(options.Logger)
+
+	return usl, nil
+}
@@ -0,0 +1,67 @@
+package netutils
+
+import (
+	"testing"
+)
+
+func TestListenTCPAndUDP(t *testing.T) {
+	tcp, err := ListenTCP[testMessage]("127.0.0.1", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+
+	go func() {
+		conn, err := tcp.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var message testMessage
+		if _, err := conn.Receive(&message); err == nil {
+			_, _ = conn.Send(message)
+		}
+	}()
+
+	client, err := DialTCPAddr[testMessage](tcp.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var reply testMessage
+	if _, err := client.Send(testMessage{Text: "tcp"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Receive(&reply); err != nil || reply.Text != "tcp" {
+		t.Fatalf("received %v, %v", reply, err)
+	}
+
+	udp, err := ListenUDP[testMessage]("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := udp.Conn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	other, err := ListenUDP[testMessage]("127.0.0.1", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := other.Conn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	if _, err := sender.WriteTo(testMessage{Text: "udp"}, server.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := server.ReadFrom(&reply); err != nil || reply.Text != "udp" {
+		t.Fatalf("received %v, %v", reply, err)
+	}
+}
@@ -0,0 +1,125 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+)
+
+// ConnectionID is the process-unique identifier of a typed connection.
+type ConnectionID uint64
+
+// connectionIDs hands out the process-unique IDs of typed connections.
+var connectionIDs atomic.Uint64
+
+// ID returns the process-unique identifier that was assigned to the connection when it
+// was created. This is the ID that is attached to log records as "conn_id".
+func (tc *AsymmetricTypedConnection[S, R]) ID() ConnectionID {
+	if tc.state == nil {
+		return 0
+	}
+
+	return tc.state.id
+}
+
+// SetLogger attaches logger to the connection, which is then used to log closes and
+// errors, and, at debug level, the size of every value that is read or written. Each
+// record carries the connection ID and its local and remote addresses. Passing nil
+// detaches the current logger.
+func (tc *AsymmetricTypedConnection[S, R]) SetLogger(logger *slog.Logger) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	tc.state.logger = logger
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) logger() *slog.Logger {
+	if tc.state == nil {
+		return nil
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	return tc.state.logger
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) log(level slog.Level, message string, attrs ...slog.Attr) {
+	logger := tc.logger()
+	if logger == nil || !logger.Enabled(context.Background(), level) {
+		return
+	}
+
+	attrs = append(attrs,
+		slog.Uint64("conn_id", uint64(tc.ID())),
+		slog.String("network", tc.connectionType.String()),
+		slog.Any("local_addr", tc.conn.LocalAddr()),
+		slog.Any("remote_addr", tc.conn.RemoteAddr()),
+	)
+
+	logger.LogAttrs(context.Background(), level, message, attrs...)
+}
+
+// logTransfer logs the outcome of a single read or write operation.
+func (tc *AsymmetricTypedConnection[S, R]) logTransfer(operation string, n int, err error) {
+	switch {
+	case err == nil:
+		tc.log(slog.LevelDebug, "netutils: "+operation, slog.Int("bytes", n))
+	case errors.Is(err, io.EOF):
+		tc.log(slog.LevelDebug, "netutils: peer closed connection", slog.String("op", operation))
+	default:
+		tc.log(slog.LevelError, "netutils: "+operation+" failed", slog.Any("error", err))
+	}
+}
+
+// logDial logs the outcome of dialing address.
+func logDial(logger *slog.Logger, network, address string, conn net.Conn, err error) {
+	if logger == nil {
+		return
+	}
+
+	if err != nil {
+		logger.LogAttrs(context.Background(), slog.LevelError, "netutils: dial failed",
+			slog.String("network", network),
+			slog.String("address", address),
+			slog.Any("error", err),
+		)
+
+		return
+	}
+
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "netutils: dialled",
+		slog.String("network", network),
+		slog.Any("local_addr", conn.LocalAddr()),
+		slog.Any("remote_addr", conn.RemoteAddr()),
+	)
+}
+
+// SetLogger attaches logger to the listener, which is then used to log accepts, accept
+// errors, and closes. Connections returned by Accept inherit the logger. Passing nil
+// detaches the current logger.
+func (tsl *TCPSocketListener[T]) SetLogger(logger *slog.Logger) {
+	tsl.logger = logger
+}
+
+func (tsl *TCPSocketListener[T]) log(level slog.Level, message string, attrs ...slog.Attr) {
+	if tsl.logger == nil {
+		return
+	}
+
+	attrs = append(attrs, slog.Any("listen_addr", tsl.listener.Addr()))
+	tsl.logger.LogAttrs(context.Background(), level, message, attrs...)
+}
+
+// SetLogger attaches logger to the connection of the listener. See
+// AsymmetricTypedConnection.SetLogger.
+func (usl *UDPSocketListener[T]) SetLogger(logger *slog.Logger) {
+	usl.connection.SetLogger(logger)
+}
@@ -0,0 +1,131 @@
+package netutils
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+)
+
+// testLogHandler records every log record given to it.
+type testLogHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (*testLogHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *testLogHandler) WithAttrs([]slog.Attr) slog.Handler     { return h }
+func (h *testLogHandler) WithGroup(string) slog.Handler          { return h }
+
+func (h *testLogHandler) Handle(_ context.Context, record slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, record.Clone())
+
+	return nil
+}
+
+// find returns the attributes of the first record with the given message.
+func (h *testLogHandler) find(message string) (map[string]slog.Value, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, record := range h.records {
+		if record.Message != message {
+			continue
+		}
+
+		attrs := make(map[string]slog.Value)
+		record.Attrs(func(attr slog.Attr) bool {
+			attrs[attr.Key] = attr.Value
+			return true
+		})
+
+		return attrs, true
+	}
+
+	return nil, false
+}
+
+func TestConnectionLogging(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer server.Close()
+
+	handler := &testLogHandler{}
+	client.SetLogger(slog.New(handler))
+
+	go func() {
+		var message testMessage
+		_, _ = server.Receive(&message)
+	}()
+	if _, err := client.Send(testMessage{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+
+	attrs, ok := handler.find("netutils: send")
+	if !ok {
+		t.Fatalf("expected the send to be logged, got %v", handler.records)
+	}
+	if attrs["conn_id"].Uint64() != uint64(client.ID()) || attrs["bytes"].Int64() == 0 {
+		t.Errorf("send record should carry the connection ID and size, got %v", attrs)
+	}
+	if _, ok := handler.find("netutils: closed"); !ok {
+		t.Errorf("expected the close to be logged, got %v", handler.records)
+	}
+
+	// Detaching the logger stops logging.
+	client.SetLogger(nil)
+	records := len(handler.records)
+	_, _ = client.Send(testMessage{Text: "hello"})
+	if len(handler.records) != records {
+		t.Errorf("detached logger should not be used, got %v", handler.records[records:])
+	}
+}
+
+func TestListenerLogging(t *testing.T) {
+	listener := newTestTCPListener(t)
+
+	handler := &testLogHandler{}
+	listener.SetLogger(slog.New(handler))
+
+	client, err := DialTCPAddr[testMessage](listener.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Accepted connections inherit the logger of the listener.
+	attrs, ok := handler.find("netutils: accepted")
+	if !ok {
+		t.Fatalf("expected the accept to be logged, got %v", handler.records)
+	}
+	if attrs["conn_id"].Uint64() != uint64(conn.ID()) {
+		t.Errorf("accept record should carry the connection ID %d, got %v", conn.ID(), attrs)
+	}
+
+	_ = listener.Close()
+	if _, ok := handler.find("netutils: listener closed"); !ok {
+		t.Errorf("expected the listener close to be logged, got %v", handler.records)
+	}
+
+	// Accept errors are logged with the address of the listener.
+	if _, err := listener.Accept(); err == nil {
+		t.Fatal("accepting on a closed listener should fail")
+	}
+	attrs, ok = handler.find("netutils: accept failed")
+	if !ok {
+		t.Fatalf("expected the accept error to be logged, got %v", handler.records)
+	}
+	if addr, ok := attrs["listen_addr"].Any().(net.Addr); !ok || addr.String() != listener.Addr().String() {
+		t.Errorf("accept error should carry the listen address, got %v", attrs)
+	}
+}
@@ -0,0 +1,150 @@
+package netutils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ConnectionManager maps application-assigned IDs, such as usernames or player IDs, to
+// live TCPTypedConnections. Connections are removed from the manager automatically when
+// they are closed, and when a Send to them fails.
+//
+// Connections can also be grouped into named rooms; see Join.
+//
+// A ConnectionManager is safe for concurrent use.
+type ConnectionManager[T Convertable] struct {
+	mu    sync.RWMutex
+	conns map[string]*TCPTypedConnection[T]
+	rooms map[string]map[string]struct{}
+}
+
+// NewConnectionManager creates a new, empty *ConnectionManager.
+func NewConnectionManager[T Convertable]() *ConnectionManager[T] {
+	return &ConnectionManager[T]{
+		conns: make(map[string]*TCPTypedConnection[T]),
+		rooms: make(map[string]map[string]struct{}),
+	}
+}
+
+// Add registers conn under id. If id is already in use, an error is returned and conn is
+// not registered.
+func (cm *ConnectionManager[T]) Add(id string, conn *TCPTypedConnection[T]) error {
+	cm.mu.Lock()
+	if _, ok := cm.conns[id]; ok {
+		cm.mu.Unlock()
+		return fmt.Errorf("a connection with id %q already exists", id)
+	}
+
+	cm.conns[id] = conn
+	cm.mu.Unlock()
+
+	// This is registered without holding the lock, as it is called immediately if the
+	// connection has already been closed.
+	conn.OnClose(func() { cm.remove(id, conn) })
+
+	return nil
+}
+
+func (cm *ConnectionManager[T]) remove(id string, conn *TCPTypedConnection[T]) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.conns[id] == conn {
+		delete(cm.conns, id)
+		cm.leaveAll(id)
+	}
+}
+
+// Get returns the connection registered under id, if any.
+func (cm *ConnectionManager[T]) Get(id string) (*TCPTypedConnection[T], bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	conn, ok := cm.conns[id]
+	return conn, ok
+}
+
+// Remove unregisters the connection with the given id without closing it, which also
+// removes it from all of its rooms. It returns false if no such connection was
+// registered.
+func (cm *ConnectionManager[T]) Remove(id string) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	_, ok := cm.conns[id]
+	delete(cm.conns, id)
+	cm.leaveAll(id)
+
+	return ok
+}
+
+// Send writes value to the connection registered under id using Send. If the write
+// fails, the connection is closed and removed from the manager.
+func (cm *ConnectionManager[T]) Send(id string, value T) error {
+	conn, ok := cm.Get(id)
+	if !ok {
+		return fmt.Errorf("no connection with id %q", id)
+	}
+
+	if _, err := conn.Send(value); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Len returns the amount of registered connections.
+func (cm *ConnectionManager[T]) Len() int {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return len(cm.conns)
+}
+
+// IDs returns the sorted IDs of all registered connections.
+func (cm *ConnectionManager[T]) IDs() []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	ids := make([]string, 0, len(cm.conns))
+	for id := range cm.conns {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
+// Range calls f for each registered connection until f returns false. f is called on a
+// snapshot of the manager, so it is safe for f to add, remove, or close connections.
+func (cm *ConnectionManager[T]) Range(f func(id string, conn *TCPTypedConnection[T]) bool) {
+	cm.mu.RLock()
+	snapshot := make(map[string]*TCPTypedConnection[T], len(cm.conns))
+	for id, conn := range cm.conns {
+		snapshot[id] = conn
+	}
+	cm.mu.RUnlock()
+
+	for id, conn := range snapshot {
+		if !f(id, conn) {
+			return
+		}
+	}
+}
+
+// Close closes every registered connection, which also removes them from the manager.
+func (cm *ConnectionManager[T]) Close() error {
+	var errs []error
+	cm.Range(func(_ string, conn *TCPTypedConnection[T]) bool {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+
+		return true
+	})
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+package netutils
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestManagerConn creates a connection for a ConnectionManager, and returns the
+// client side of it.
+func newTestManagerConn(t *testing.T) (*TCPTypedConnection[testMessage], *TCPTypedConnection[testMessage]) {
+	t.Helper()
+
+	a, b := net.Pipe()
+	server := NewTCPTypedConnection[testMessage](a)
+	client := NewTCPTypedConnection[testMessage](b)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	return &server, &client
+}
+
+func TestConnectionManager(t *testing.T) {
+	cm := NewConnectionManager[testMessage]()
+
+	alice, aliceClient := newTestManagerConn(t)
+	bob, _ := newTestManagerConn(t)
+
+	if err := cm.Add("alice", alice); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.Add("bob", bob); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.Add("alice", bob); err == nil {
+		t.Error("adding a duplicate id should fail")
+	}
+	if ids := cm.IDs(); !slices.Equal(ids, []string{"alice", "bob"}) {
+		t.Errorf("ids should be [alice bob], got %v", ids)
+	}
+
+	received := make(chan string, 1)
+	go func() {
+		var message testMessage
+		if _, err := aliceClient.Receive(&message); err == nil {
+			received <- message.Text
+		}
+	}()
+	if err := cm.Send("alice", testMessage{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if text := <-received; text != "hello" {
+		t.Errorf("alice should receive hello, got %q", text)
+	}
+
+	// Closing a connection removes it.
+	_ = bob.Close()
+	if _, ok := cm.Get("bob"); ok {
+		t.Error("closed connection should be removed")
+	}
+
+	// A failed send closes and removes the connection.
+	_ = aliceClient.Close()
+	if err := cm.Send("alice", testMessage{Text: "hello"}); err == nil {
+		t.Error("sending to a closed peer should fail")
+	}
+	if cm.Len() != 0 {
+		t.Errorf("manager should be empty, got %v", cm.IDs())
+	}
+}
+
+func TestConnectionManagerAddClosed(t *testing.T) {
+	cm := NewConnectionManager[testMessage]()
+
+	conn, _ := newTestManagerConn(t)
+	_ = conn.Close()
+
+	// Adding a connection that has already been closed must not deadlock.
+	done := make(chan error, 1)
+	go func() { done <- cm.Add("closed", conn) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("adding a closed connection deadlocked")
+	}
+
+	if cm.Len() != 0 {
+		t.Errorf("closed connection should not be registered, got %v", cm.IDs())
+	}
+}
+
+func TestConnectionManagerClose(t *testing.T) {
+	cm := NewConnectionManager[testMessage]()
+
+	for _, id := range []string{"a", "b", "c"} {
+		conn, _ := newTestManagerConn(t)
+		if err := cm.Add(id, conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Len() != 0 {
+		t.Errorf("closing the manager should remove every connection, got %v", cm.IDs())
+	}
+}
@@ -0,0 +1,46 @@
+package netutils
+
+import "io"
+
+// AppendMarshaler can optionally be implemented by a Convertable type to marshal itself
+// directly into the buffer that is written to the connection, instead of returning a new
+// slice from Marshal that then has to be copied behind the length prefix of a frame. This
+// saves an allocation and a full copy per message, which matters for large payloads.
+//
+// Types can alternatively implement io.WriterTo, in which case WriteTo is given a writer
+// that appends to the frame. If a type implements both, AppendMarshal is used. Either way,
+// the encoding must be the same as the one produced by Marshal.
+type AppendMarshaler interface {
+	// AppendMarshal appends the marshalled form of the value to dst and returns the
+	// extended buffer.
+	AppendMarshal(dst []byte) ([]byte, error)
+}
+
+// appendWriter is an io.Writer that appends to a byte slice.
+type appendWriter []byte
+
+func (aw *appendWriter) Write(p []byte) (int, error) {
+	*aw = append(*aw, p...)
+	return len(p), nil
+}
+
+// appendMarshal appends the marshalled form of value to dst, using AppendMarshal or
+// WriteTo if value implements them, and Marshal otherwise.
+func appendMarshal[T Convertable](dst []byte, value T) ([]byte, error) {
+	switch v := any(value).(type) {
+	case AppendMarshaler:
+		return v.AppendMarshal(dst)
+	case io.WriterTo:
+		w := appendWriter(dst)
+		_, err := v.WriteTo(&w)
+
+		return w, err
+	default:
+		payload, err := value.Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		return append(dst, payload...), nil
+	}
+}
@@ -0,0 +1,42 @@
+package netutils
+
+import (
+	"errors"
+	"testing"
+)
+
+// appendMessage is marshalled as its raw text, through AppendMarshal only.
+type appendMessage struct {
+	Text string
+}
+
+func (am appendMessage) String() string { return am.Text }
+
+func (appendMessage) Marshal() ([]byte, error) {
+	return nil, errors.New("Marshal should not be called when AppendMarshal is implemented")
+}
+
+func (am appendMessage) AppendMarshal(dst []byte) ([]byte, error) {
+	return append(dst, am.Text...), nil
+}
+
+func (appendMessage) Unmarshal(v any, data []byte) error {
+	v.(*appendMessage).Text = string(data)
+	return nil
+}
+
+func TestAppendMarshal(t *testing.T) {
+	a, b := Pipe[appendMessage]()
+	defer a.Close()
+	defer b.Close()
+
+	go func() { _, _ = a.Send(appendMessage{Text: "appended"}) }()
+
+	var message appendMessage
+	if _, err := b.Receive(&message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Text != "appended" {
+		t.Errorf("expected %q, got %q", "appended", message.Text)
+	}
+}
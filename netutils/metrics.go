@@ -0,0 +1,321 @@
+package netutils
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives events from typed connections, allowing them to be exported to a
+// monitoring system. Every method is passed the network of the connection, such as "tcp"
+// or "udp". Implementations must be safe for concurrent use. See ExpvarMetrics for the
+// implementation provided by this package.
+type Metrics interface {
+	// ConnectionOpened is called when Metrics are attached to an open connection, and
+	// ConnectionClosed is called when that connection is closed.
+	ConnectionOpened(network string)
+	ConnectionClosed(network string)
+
+	// MessageRead is called after each value that is successfully read, with the size of
+	// its encoded form and the time the read took, including the time spent waiting for
+	// the value to arrive.
+	MessageRead(network string, size int, latency time.Duration)
+	// MessageWritten is called after each value that is successfully written, with the
+	// amount of bytes that were written.
+	MessageWritten(network string, size int)
+	// UnmarshalError is called when a value that was read could not be unmarshalled.
+	UnmarshalError(network string)
+}
+
+// SetMetrics attaches metrics to the connection, which then reports every value that is
+// read or written and the closing of the connection to it. Passing nil detaches the
+// current Metrics, which are told that the connection was closed.
+func (tc *AsymmetricTypedConnection[S, R]) SetMetrics(metrics Metrics) {
+	if tc.state == nil {
+		return
+	}
+
+	network := tc.connectionType.String()
+
+	tc.state.mu.Lock()
+	previous := tc.state.metrics
+	closed := tc.state.closed
+	if !closed {
+		tc.state.metrics = metrics
+	}
+	tc.state.mu.Unlock()
+
+	if closed {
+		return
+	}
+	if previous != nil {
+		previous.ConnectionClosed(network)
+	}
+	if metrics != nil {
+		metrics.ConnectionOpened(network)
+	}
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) metrics() Metrics {
+	if tc.state == nil {
+		return nil
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	return tc.state.metrics
+}
+
+// recordMetrics reports the outcome of a single read or write operation that started at
+// start.
+func (tc *AsymmetricTypedConnection[S, R]) recordMetrics(dir direction, start time.Time, n int, err error) {
+	metrics := tc.metrics()
+	if metrics == nil {
+		return
+	}
+
+	network := tc.connectionType.String()
+
+	switch {
+	case errors.Is(err, ErrUnmarshal):
+		metrics.UnmarshalError(network)
+	case err != nil:
+	case dir == readDirection:
+		metrics.MessageRead(network, n, time.Since(start))
+	default:
+		metrics.MessageWritten(network, n)
+	}
+}
+
+// Histogram is a cumulative histogram with fixed bucket boundaries, in the style of a
+// Prometheus histogram. It implements expvar.Var, and is safe for concurrent use.
+type Histogram struct {
+	bounds []float64
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Uint64
+}
+
+// NewHistogram creates a new *Histogram with the given upper bounds for its buckets. A
+// final bucket for values larger than every bound is always present.
+func NewHistogram(bounds ...float64) *Histogram {
+	bounds = slices.Clone(bounds)
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]atomic.Uint64, len(bounds)+1),
+	}
+}
+
+// Observe records v in the histogram.
+func (h *Histogram) Observe(v float64) {
+	i, _ := slices.BinarySearch(h.bounds, v)
+	h.counts[i].Add(1)
+	h.count.Add(1)
+
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Count returns the amount of values that have been observed.
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+// Sum returns the sum of all values that have been observed.
+func (h *Histogram) Sum() float64 {
+	return math.Float64frombits(h.sum.Load())
+}
+
+// Buckets returns the upper bounds of the buckets alongside the cumulative amount of
+// values that were less than or equal to each bound.
+func (h *Histogram) Buckets() ([]float64, []uint64) {
+	cumulative := make([]uint64, len(h.bounds))
+
+	var total uint64
+	for i := range h.bounds {
+		total += h.counts[i].Load()
+		cumulative[i] = total
+	}
+
+	return slices.Clone(h.bounds), cumulative
+}
+
+// String returns the histogram as a JSON object, as required by expvar.Var.
+func (h *Histogram) String() string {
+	bounds, counts := h.Buckets()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `{"count": %d, "sum": %s, "buckets": {`, h.Count(), formatFloat(h.Sum()))
+	for i, bound := range bounds {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, `"%s": %d`, formatFloat(bound), counts[i])
+	}
+	b.WriteString("}}")
+
+	return b.String()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Default bucket boundaries used by ExpvarMetrics.
+var (
+	DefaultSizeBuckets    = []float64{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+	DefaultLatencyBuckets = []float64{.0005, .001, .005, .01, .05, .1, .5, 1, 5, 10}
+)
+
+// ExpvarMetrics is the Metrics implementation provided by this package. Counters are kept
+// per network in expvar.Maps, and sizes and latencies are kept in Histograms. The metrics
+// are published through the "expvar" package, and can also be served in the Prometheus
+// text format through ServeHTTP or WritePrometheus.
+type ExpvarMetrics struct {
+	name string
+
+	MessagesRead      *expvar.Map
+	MessagesWritten   *expvar.Map
+	BytesRead         *expvar.Map
+	BytesWritten      *expvar.Map
+	UnmarshalErrors   *expvar.Map
+	ActiveConnections *expvar.Map
+
+	ReadSize    *Histogram
+	WriteSize   *Histogram
+	ReadLatency *Histogram
+}
+
+// NewExpvarMetrics creates a new *ExpvarMetrics and publishes it with expvar under name,
+// which is also used as the prefix of the Prometheus metric names. Like expvar.Publish,
+// it panics if name has already been published.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	em := &ExpvarMetrics{
+		name:              name,
+		MessagesRead:      new(expvar.Map),
+		MessagesWritten:   new(expvar.Map),
+		BytesRead:         new(expvar.Map),
+		BytesWritten:      new(expvar.Map),
+		UnmarshalErrors:   new(expvar.Map),
+		ActiveConnections: new(expvar.Map),
+		ReadSize:          NewHistogram(DefaultSizeBuckets...),
+		WriteSize:         NewHistogram(DefaultSizeBuckets...),
+		ReadLatency:       NewHistogram(DefaultLatencyBuckets...),
+	}
+
+	vars := new(expvar.Map)
+	for _, counter := range em.counters() {
+		vars.Set(counter.name, counter.values)
+	}
+	vars.Set("read_size_bytes", em.ReadSize)
+	vars.Set("write_size_bytes", em.WriteSize)
+	vars.Set("read_latency_seconds", em.ReadLatency)
+	expvar.Publish(name, vars)
+
+	return em
+}
+
+type namedCounter struct {
+	name, kind string
+	values     *expvar.Map
+}
+
+func (em *ExpvarMetrics) counters() []namedCounter {
+	return []namedCounter{
+		{"messages_read_total", "counter", em.MessagesRead},
+		{"messages_written_total", "counter", em.MessagesWritten},
+		{"bytes_read_total", "counter", em.BytesRead},
+		{"bytes_written_total", "counter", em.BytesWritten},
+		{"unmarshal_errors_total", "counter", em.UnmarshalErrors},
+		{"active_connections", "gauge", em.ActiveConnections},
+	}
+}
+
+// ConnectionOpened implements Metrics.
+func (em *ExpvarMetrics) ConnectionOpened(network string) {
+	em.ActiveConnections.Add(network, 1)
+}
+
+// ConnectionClosed implements Metrics.
+func (em *ExpvarMetrics) ConnectionClosed(network string) {
+	em.ActiveConnections.Add(network, -1)
+}
+
+// MessageRead implements Metrics.
+func (em *ExpvarMetrics) MessageRead(network string, size int, latency time.Duration) {
+	em.MessagesRead.Add(network, 1)
+	em.BytesRead.Add(network, int64(size))
+	em.ReadSize.Observe(float64(size))
+	em.ReadLatency.Observe(latency.Seconds())
+}
+
+// MessageWritten implements Metrics.
+func (em *ExpvarMetrics) MessageWritten(network string, size int) {
+	em.MessagesWritten.Add(network, 1)
+	em.BytesWritten.Add(network, int64(size))
+	em.WriteSize.Observe(float64(size))
+}
+
+// UnmarshalError implements Metrics.
+func (em *ExpvarMetrics) UnmarshalError(network string) {
+	em.UnmarshalErrors.Add(network, 1)
+}
+
+// WritePrometheus writes every metric to w in the Prometheus text exposition format.
+func (em *ExpvarMetrics) WritePrometheus(w io.Writer) error {
+	var b strings.Builder
+
+	for _, counter := range em.counters() {
+		name := em.name + "_" + counter.name
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, counter.kind)
+		counter.values.Do(func(kv expvar.KeyValue) {
+			fmt.Fprintf(&b, "%s{network=%q} %s\n", name, kv.Key, kv.Value)
+		})
+	}
+
+	for _, histogram := range []struct {
+		name string
+		*Histogram
+	}{
+		{"read_size_bytes", em.ReadSize},
+		{"write_size_bytes", em.WriteSize},
+		{"read_latency_seconds", em.ReadLatency},
+	} {
+		name := em.name + "_" + histogram.name
+		bounds, counts := histogram.Buckets()
+
+		fmt.Fprintf(&b, "# TYPE %s histogram\n", name)
+		for i, bound := range bounds {
+			fmt.Fprintf(&b, "%s_bucket{le=%q} %d\n", name, formatFloat(bound), counts[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{le=\"+Inf\"} %d\n", name, histogram.Count())
+		fmt.Fprintf(&b, "%s_sum %s\n", name, formatFloat(histogram.Sum()))
+		fmt.Fprintf(&b, "%s_count %d\n", name, histogram.Count())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format, allowing the
+// ExpvarMetrics to be used as a scrape target.
+func (em *ExpvarMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = em.WritePrometheus(w)
+}
@@ -0,0 +1,53 @@
+package netutils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var expvarTestRuns atomic.Int64
+
+func TestExpvarMetrics(t *testing.T) {
+	// expvar names can only be published once, so each run needs its own.
+	name := fmt.Sprintf("netutils_test_%d", expvarTestRuns.Add(1))
+	metrics := NewExpvarMetrics(name)
+
+	clientConn, serverConn := net.Pipe()
+	client := NewTypedConnection[testMessage](clientConn, ConnectionTypeTCP)
+	server := NewTypedConnection[testMessage](serverConn, ConnectionTypeTCP)
+	client.SetMetrics(metrics)
+	server.SetMetrics(metrics)
+	defer server.Close()
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		_, _ = client.Send(testMessage{Text: "hello"})
+	}()
+
+	var message testMessage
+	if _, err := server.Receive(&message); err != nil {
+		t.Fatal(err)
+	}
+	<-sent
+	_ = client.Close()
+
+	var b strings.Builder
+	if err := metrics.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		name + `_messages_read_total{network="tcp"} 1`,
+		name + `_messages_written_total{network="tcp"} 1`,
+		name + `_active_connections{network="tcp"} 1`,
+		name + `_read_latency_seconds_count 1`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("expected %q in:\n%s", line, b.String())
+		}
+	}
+}
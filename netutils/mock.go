@@ -0,0 +1,237 @@
+package netutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+type mockReceive[R Convertable] struct {
+	value R
+	err   error
+}
+
+// MockConnection is a scripted test double for typed connections, allowing application
+// code written against Duplex, Sender, or the Send/Receive methods to be tested
+// deterministically without any networking.
+//
+// Values that Receive should return are queued beforehand with QueueReceive, and errors
+// with QueueReceiveError. Once the script has been exhausted, Receive returns io.EOF,
+// as if the peer had closed the connection. Everything that is sent is recorded and can
+// be inspected with Sent. Values are marshalled and unmarshalled on the way in and out,
+// so codec failures surface just as they would on a real connection.
+//
+// A MockConnection is safe for concurrent use.
+type MockConnection[S, R Convertable] struct {
+	mu       sync.Mutex
+	receives []mockReceive[R]
+	sent     []S
+	sendErr  error
+	delay    time.Duration
+	closed   bool
+	closeErr error
+}
+
+// Ensure that MockConnection can stand in for the typed connections of this package.
+var _ Duplex[Message, Message] = (*MockConnection[Message, Message])(nil)
+
+// NewMockConnection creates a new *MockConnection with an empty script.
+func NewMockConnection[S, R Convertable]() *MockConnection[S, R] {
+	return &MockConnection[S, R]{}
+}
+
+// QueueReceive appends values to the script, to be returned by Receive in order.
+func (mc *MockConnection[S, R]) QueueReceive(values ...R) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	for _, value := range values {
+		mc.receives = append(mc.receives, mockReceive[R]{value: value})
+	}
+}
+
+// QueueReceiveError appends err to the script, to be returned by Receive once the values
+// queued before it have been received.
+func (mc *MockConnection[S, R]) QueueReceiveError(err error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.receives = append(mc.receives, mockReceive[R]{err: err})
+}
+
+// SetSendError makes every subsequent Send fail with err, without recording the value.
+// Passing nil makes Send succeed again.
+func (mc *MockConnection[S, R]) SetSendError(err error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.sendErr = err
+}
+
+// SetCloseError makes Close return err.
+func (mc *MockConnection[S, R]) SetCloseError(err error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.closeErr = err
+}
+
+// SetDelay makes every Send and Receive wait for delay before doing anything, to
+// simulate a slow peer.
+func (mc *MockConnection[S, R]) SetDelay(delay time.Duration) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.delay = delay
+}
+
+func (mc *MockConnection[S, R]) wait() {
+	mc.mu.Lock()
+	delay := mc.delay
+	mc.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// Send records data, returning the size it would have had as a frame.
+func (mc *MockConnection[S, R]) Send(data S) (int, error) {
+	mc.wait()
+
+	frame, err := marshalFrame(data)
+	if err != nil {
+		return 0, err
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if err := mc.sendable(); err != nil {
+		return 0, err
+	}
+	mc.sent = append(mc.sent, data)
+
+	return len(frame), nil
+}
+
+// sendable returns the error a send should fail with, if any. mc.mu must be held.
+func (mc *MockConnection[S, R]) sendable() error {
+	if mc.closed {
+		return net.ErrClosed
+	}
+
+	return mc.sendErr
+}
+
+// writeFrame decodes and records already marshalled frames, such as those written by
+// Hub and AsyncWriter.
+func (mc *MockConnection[S, R]) writeFrame(frame []byte) (int, error) {
+	mc.wait()
+
+	var values []S
+	for reader := bytes.NewReader(frame); reader.Len() > 0; {
+		payload, control, err := readFrame(reader, math.MaxInt32, nil)
+		if err != nil {
+			return 0, err
+		}
+		if control {
+			continue
+		}
+
+		var value S
+		if err := value.Unmarshal(&value, payload); err != nil {
+			return 0, errors.Join(ErrUnmarshal, err)
+		}
+		values = append(values, value)
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if err := mc.sendable(); err != nil {
+		return 0, err
+	}
+	mc.sent = append(mc.sent, values...)
+
+	return len(frame), nil
+}
+
+// Receive returns the next value or error in the script, or io.EOF if the script has
+// been exhausted. Options are accepted for compatibility and ignored.
+func (mc *MockConnection[S, R]) Receive(data *R, _ ...ReadOption) (int, error) {
+	if data == nil {
+		return 0, errors.New("data pointer was nil")
+	}
+
+	mc.wait()
+
+	mc.mu.Lock()
+	if mc.closed {
+		mc.mu.Unlock()
+		return 0, net.ErrClosed
+	}
+	if len(mc.receives) == 0 {
+		mc.mu.Unlock()
+		return 0, io.EOF
+	}
+	next := mc.receives[0]
+	mc.receives = mc.receives[1:]
+	mc.mu.Unlock()
+
+	if next.err != nil {
+		return 0, next.err
+	}
+
+	payload, err := next.value.Marshal()
+	if err != nil {
+		return 0, errors.Join(errors.New("could not marshal scripted value"), err)
+	}
+
+	var value R
+	if err := value.Unmarshal(&value, payload); err != nil {
+		return 0, errors.Join(ErrUnmarshal, err)
+	}
+	*data = value
+
+	return len(payload), nil
+}
+
+// Sent returns a copy of every value that has been sent so far, in order.
+func (mc *MockConnection[S, R]) Sent() []S {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return append([]S(nil), mc.sent...)
+}
+
+// Pending returns the amount of entries left in the script.
+func (mc *MockConnection[S, R]) Pending() int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return len(mc.receives)
+}
+
+// Close marks the connection as closed, causing all further operations to fail with
+// net.ErrClosed.
+func (mc *MockConnection[S, R]) Close() error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.closed = true
+
+	return mc.closeErr
+}
+
+// Closed returns whether Close has been called.
+func (mc *MockConnection[S, R]) Closed() bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return mc.closed
+}
@@ -0,0 +1,39 @@
+package netutils
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestMockConnection(t *testing.T) {
+	mock := NewMockConnection[testMessage, testMessage]()
+	failure := errors.New("scripted failure")
+	mock.QueueReceive(testMessage{Text: "one"})
+	mock.QueueReceiveError(failure)
+
+	var message testMessage
+	if _, err := mock.Receive(&message); err != nil || message.Text != "one" {
+		t.Fatalf("expected first scripted value, got %v, %v", message, err)
+	}
+	if _, err := mock.Receive(&message); !errors.Is(err, failure) {
+		t.Fatalf("expected scripted error, got %v", err)
+	}
+	if _, err := mock.Receive(&message); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF once the script is exhausted, got %v", err)
+	}
+
+	writer := NewAsyncWriter[testMessage](mock)
+	if err := writer.Send(testMessage{Text: "queued"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mock.Send(testMessage{Text: "direct"}); err == nil {
+		t.Error("send after close should fail")
+	}
+	if !mock.Closed() {
+		t.Error("closing the writer should close the connection")
+	}
+}
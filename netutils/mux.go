@@ -0,0 +1,585 @@
+package netutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrMuxClosed is returned by operations on a Mux, and its streams, once it has been
+// closed.
+var ErrMuxClosed = errors.New("mux is closed")
+
+const (
+	muxFrameOpen byte = iota + 1
+	muxFrameData
+	muxFrameWindow
+	muxFrameClose
+)
+
+const (
+	muxHeaderSize   = 9
+	muxMaxFrameSize = 16 * 1024
+)
+
+// MuxOptions is a struct used by NewMuxClient and NewMuxServer to define certain optional
+// parameters.
+type MuxOptions struct {
+	// WindowSize is the amount of bytes that the peer may send on a stream before they
+	// have been read. This stops a stream that is not being read from from holding up
+	// the others. Each side tells the other its own WindowSize when a stream is opened,
+	// so the two sides need not agree on it. If it is zero or negative, 256KiB is used.
+	WindowSize int
+
+	// AcceptBacklog is the amount of streams opened by the peer that can be waiting to
+	// be accepted. Streams that are opened beyond this are closed straight away. If it is
+	// zero or negative, 64 is used.
+	AcceptBacklog int
+}
+
+func defaultMuxOptions() MuxOptions {
+	return MuxOptions{
+		WindowSize:    256 * 1024,
+		AcceptBacklog: 64,
+	}
+}
+
+// Mux carries many independent streams over a single connection, so that a client does
+// not need to open a connection for each kind of traffic that it exchanges with a peer.
+// Each stream is a net.Conn, so it can be wrapped in a typed connection of any type,
+// such as with OpenTypedStream. Streams are flow controlled separately, so a stream
+// that is not being read from does not hold up the others.
+//
+// One side of the connection must create its Mux with NewMuxClient, and the other with
+// NewMuxServer. Either side may open streams, and the peer accepts them with
+// AcceptStream. A Mux is safe for concurrent use.
+type Mux struct {
+	conn    net.Conn
+	options MuxOptions
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	streams map[uint32]*MuxStream
+	nextID  uint32
+	err     error
+
+	accept chan *MuxStream
+	done   chan struct{}
+}
+
+// NewMuxClient creates a new *Mux over conn for the side of the connection that dialled
+// it. See Mux.
+//
+// This takes a variadic parameter of type MuxOptions. If no MuxOptions are supplied,
+// then the defaults are used. If more than one MuxOptions are supplied then only the
+// first will be used.
+func NewMuxClient(conn net.Conn, opts ...MuxOptions) *Mux {
+	return newMux(conn, 1, opts)
+}
+
+// NewMuxServer creates a new *Mux over conn for the side of the connection that accepted
+// it. See NewMuxClient.
+func NewMuxServer(conn net.Conn, opts ...MuxOptions) *Mux {
+	return newMux(conn, 2, opts)
+}
+
+func newMux(conn net.Conn, firstID uint32, opts []MuxOptions) *Mux {
+	options := defaultMuxOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.WindowSize <= 0 {
+		options.WindowSize = defaultMuxOptions().WindowSize
+	}
+	if options.AcceptBacklog <= 0 {
+		options.AcceptBacklog = defaultMuxOptions().AcceptBacklog
+	}
+
+	mux := &Mux{
+		conn:    conn,
+		options: options,
+		streams: make(map[uint32]*MuxStream),
+		nextID:  firstID,
+		accept:  make(chan *MuxStream, options.AcceptBacklog),
+		done:    make(chan struct{}),
+	}
+	go mux.readLoop()
+
+	return mux
+}
+
+func (m *Mux) writeFrame(kind byte, id uint32, payload []byte) error {
+	header := make([]byte, muxHeaderSize, muxHeaderSize+len(payload))
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], id)
+	binary.BigEndian.PutUint32(header[5:], uint32(len(payload)))
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	select {
+	case <-m.done:
+		return m.closedErr()
+	default:
+	}
+
+	buffers := net.Buffers{header, payload}
+	if _, err := buffers.WriteTo(m.conn); err != nil {
+		m.fail(err)
+		return err
+	}
+
+	return nil
+}
+
+// newStream creates a stream that may send sendWindow bytes before the peer grants it
+// more.
+func (m *Mux) newStream(id uint32, name string, sendWindow int) *MuxStream {
+	stream := &MuxStream{
+		mux:        m,
+		id:         id,
+		name:       name,
+		sendWindow: sendWindow,
+	}
+	stream.cond = sync.NewCond(&stream.mu)
+
+	return stream
+}
+
+// OpenStream opens a new stream to the peer, who receives it from AcceptStream. name is
+// passed to the peer alongside the stream, which is usually used to tell it what the
+// stream carries.
+func (m *Mux) OpenStream(name string) (*MuxStream, error) {
+	m.mu.Lock()
+	if m.err != nil {
+		defer m.mu.Unlock()
+		return nil, m.closedErr()
+	}
+
+	// Nothing can be sent until the peer has told us its window, which it grants once it
+	// has received the open frame. The open frame tells the peer our window in turn.
+	id := m.nextID
+	m.nextID += 2
+	stream := m.newStream(id, name, 0)
+	m.streams[id] = stream
+	m.mu.Unlock()
+
+	payload := binary.BigEndian.AppendUint32(nil, uint32(m.options.WindowSize))
+	if err := m.writeFrame(muxFrameOpen, id, append(payload, name...)); err != nil {
+		m.remove(id)
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// AcceptStream blocks until the peer opens a stream, and returns it. Stream.Name can be
+// used to tell what kind of stream it is.
+func (m *Mux) AcceptStream() (*MuxStream, error) {
+	select {
+	case stream := <-m.accept:
+		return stream, nil
+	case <-m.done:
+		return nil, m.closedErr()
+	}
+}
+
+func (m *Mux) closedErr() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil && !errors.Is(m.err, ErrMuxClosed) {
+		return errors.Join(ErrMuxClosed, m.err)
+	}
+
+	return ErrMuxClosed
+}
+
+func (m *Mux) remove(id uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.streams, id)
+}
+
+func (m *Mux) stream(id uint32) *MuxStream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.streams[id]
+}
+
+func (m *Mux) readLoop() {
+	header := make([]byte, muxHeaderSize)
+	for {
+		if _, err := io.ReadFull(m.conn, header); err != nil {
+			m.fail(err)
+			return
+		}
+
+		kind := header[0]
+		id := binary.BigEndian.Uint32(header[1:])
+		length := binary.BigEndian.Uint32(header[5:])
+		if length > muxMaxFrameSize {
+			m.fail(fmt.Errorf("mux frame of %d bytes exceeds the maximum of %d", length, muxMaxFrameSize))
+			return
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(m.conn, payload); err != nil {
+			m.fail(err)
+			return
+		}
+
+		if err := m.handleFrame(kind, id, payload); err != nil {
+			m.fail(err)
+			return
+		}
+	}
+}
+
+func (m *Mux) handleFrame(kind byte, id uint32, payload []byte) error {
+	switch kind {
+	case muxFrameOpen:
+		if len(payload) < 4 {
+			return errors.New("malformed mux open frame")
+		}
+		window, name := binary.BigEndian.Uint32(payload), string(payload[4:])
+
+		m.mu.Lock()
+		if _, ok := m.streams[id]; ok || id%2 == m.nextID%2 {
+			m.mu.Unlock()
+			return fmt.Errorf("peer opened invalid stream %d", id)
+		}
+		stream := m.newStream(id, name, int(window))
+		m.streams[id] = stream
+		m.mu.Unlock()
+
+		select {
+		case m.accept <- stream:
+			// The grant is written separately, so that the read loop is not held up by
+			// a peer that is itself busy writing.
+			grant := binary.BigEndian.AppendUint32(nil, uint32(m.options.WindowSize))
+			go func() { _ = m.writeFrame(muxFrameWindow, id, grant) }()
+		default:
+			_ = stream.Close()
+		}
+	case muxFrameData:
+		if stream := m.stream(id); stream != nil {
+			return stream.push(payload)
+		}
+	case muxFrameWindow:
+		if len(payload) != 4 {
+			return errors.New("malformed mux window update")
+		}
+		if stream := m.stream(id); stream != nil {
+			stream.grant(int(binary.BigEndian.Uint32(payload)))
+		}
+	case muxFrameClose:
+		if stream := m.stream(id); stream != nil {
+			stream.remoteClose()
+		}
+	default:
+		return fmt.Errorf("unknown mux frame type %d", kind)
+	}
+
+	return nil
+}
+
+// fail closes the mux and all of its streams because of err.
+func (m *Mux) fail(err error) {
+	m.mu.Lock()
+	if m.err != nil {
+		m.mu.Unlock()
+		return
+	}
+
+	m.err = err
+	streams := m.streams
+	m.streams = make(map[uint32]*MuxStream)
+	m.mu.Unlock()
+
+	close(m.done)
+	_ = m.conn.Close()
+
+	for _, stream := range streams {
+		stream.abort()
+	}
+}
+
+// Close closes the mux, its streams, and the underlying connection.
+func (m *Mux) Close() error {
+	m.fail(ErrMuxClosed)
+	return nil
+}
+
+// NumStreams returns the amount of streams that are currently open.
+func (m *Mux) NumStreams() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.streams)
+}
+
+// MuxStream is a single stream of a Mux. It implements net.Conn.
+type MuxStream struct {
+	mux  *Mux
+	id   uint32
+	name string
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	buffer    []byte
+	consumed  int
+	remoteEOF bool
+	closed    bool
+	aborted   bool
+
+	sendWindow    int
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+// ID returns the identifier of the stream, which is unique within its Mux.
+func (ms *MuxStream) ID() uint32 {
+	return ms.id
+}
+
+// Name returns the name that the stream was opened with.
+func (ms *MuxStream) Name() string {
+	return ms.name
+}
+
+func (ms *MuxStream) push(payload []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if len(ms.buffer)+len(payload) > ms.mux.options.WindowSize {
+		return fmt.Errorf("peer overran the window of stream %d", ms.id)
+	}
+
+	if !ms.closed {
+		ms.buffer = append(ms.buffer, payload...)
+		ms.cond.Broadcast()
+	}
+
+	return nil
+}
+
+func (ms *MuxStream) grant(n int) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.sendWindow += n
+	ms.cond.Broadcast()
+}
+
+func (ms *MuxStream) remoteClose() {
+	ms.mu.Lock()
+	ms.remoteEOF = true
+	closed := ms.closed
+	ms.cond.Broadcast()
+	ms.mu.Unlock()
+
+	if closed {
+		ms.mux.remove(ms.id)
+	}
+}
+
+func (ms *MuxStream) abort() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.aborted = true
+	ms.cond.Broadcast()
+}
+
+// wait blocks on the condition variable until it is signalled or deadline passes, in
+// which case os.ErrDeadlineExceeded is returned. ms.mu must be held.
+func (ms *MuxStream) wait(deadline time.Time) error {
+	if !deadline.IsZero() {
+		until := time.Until(deadline)
+		if until <= 0 {
+			return os.ErrDeadlineExceeded
+		}
+
+		timer := time.AfterFunc(until, func() {
+			ms.mu.Lock()
+			ms.cond.Broadcast()
+			ms.mu.Unlock()
+		})
+		defer timer.Stop()
+	}
+
+	ms.cond.Wait()
+
+	return nil
+}
+
+// Read implements net.Conn.
+func (ms *MuxStream) Read(b []byte) (int, error) {
+	ms.mu.Lock()
+	for len(ms.buffer) == 0 {
+		switch {
+		case ms.closed:
+			ms.mu.Unlock()
+			return 0, net.ErrClosed
+		case ms.remoteEOF:
+			ms.mu.Unlock()
+			return 0, io.EOF
+		case ms.aborted:
+			ms.mu.Unlock()
+			return 0, ms.mux.closedErr()
+		}
+
+		if err := ms.wait(ms.readDeadline); err != nil {
+			ms.mu.Unlock()
+			return 0, err
+		}
+	}
+
+	n := copy(b, ms.buffer)
+	ms.buffer = ms.buffer[n:]
+	if len(ms.buffer) == 0 {
+		ms.buffer = nil
+	}
+
+	// Give the window back to the peer in batches, rather than after every read.
+	ms.consumed += n
+	var update int
+	if ms.consumed >= ms.mux.options.WindowSize/2 {
+		update, ms.consumed = ms.consumed, 0
+	}
+	ms.mu.Unlock()
+
+	if update > 0 {
+		_ = ms.mux.writeFrame(muxFrameWindow, ms.id, binary.BigEndian.AppendUint32(nil, uint32(update)))
+	}
+
+	return n, nil
+}
+
+// Write implements net.Conn. It blocks while the peer's window for the stream is full.
+func (ms *MuxStream) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		ms.mu.Lock()
+		for ms.sendWindow <= 0 || ms.closed || ms.remoteEOF || ms.aborted {
+			switch {
+			case ms.closed:
+				ms.mu.Unlock()
+				return written, net.ErrClosed
+			case ms.remoteEOF:
+				ms.mu.Unlock()
+				return written, io.ErrClosedPipe
+			case ms.aborted:
+				ms.mu.Unlock()
+				return written, ms.mux.closedErr()
+			}
+
+			if err := ms.wait(ms.writeDeadline); err != nil {
+				ms.mu.Unlock()
+				return written, err
+			}
+		}
+
+		n := min(len(b)-written, ms.sendWindow, muxMaxFrameSize)
+		ms.sendWindow -= n
+		ms.mu.Unlock()
+
+		if err := ms.mux.writeFrame(muxFrameData, ms.id, b[written:written+n]); err != nil {
+			return written, err
+		}
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close closes the stream, telling the peer. Data that has been written is still
+// delivered, but any data that has not yet been read is discarded.
+func (ms *MuxStream) Close() error {
+	ms.mu.Lock()
+	if ms.closed {
+		ms.mu.Unlock()
+		return nil
+	}
+
+	ms.closed = true
+	ms.buffer = nil
+	remoteEOF, aborted := ms.remoteEOF, ms.aborted
+	ms.cond.Broadcast()
+	ms.mu.Unlock()
+
+	if aborted {
+		return nil
+	}
+	if remoteEOF {
+		ms.mux.remove(ms.id)
+	}
+
+	return ms.mux.writeFrame(muxFrameClose, ms.id, nil)
+}
+
+// LocalAddr returns the local address of the connection underlying the mux.
+func (ms *MuxStream) LocalAddr() net.Addr {
+	return ms.mux.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the connection underlying the mux.
+func (ms *MuxStream) RemoteAddr() net.Addr {
+	return ms.mux.conn.RemoteAddr()
+}
+
+// SetDeadline implements net.Conn.
+func (ms *MuxStream) SetDeadline(t time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.readDeadline, ms.writeDeadline = t, t
+	ms.cond.Broadcast()
+
+	return nil
+}
+
+// SetReadDeadline implements net.Conn.
+func (ms *MuxStream) SetReadDeadline(t time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.readDeadline = t
+	ms.cond.Broadcast()
+
+	return nil
+}
+
+// SetWriteDeadline implements net.Conn.
+func (ms *MuxStream) SetWriteDeadline(t time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.writeDeadline = t
+	ms.cond.Broadcast()
+
+	return nil
+}
+
+// OpenTypedStream opens a new stream on mux and wraps it in a TypedConnection[T]. The
+// peer should wrap the stream that it accepts with NewTypedConnection using the same T,
+// which it can choose based on the stream's name.
+func OpenTypedStream[T Convertable](mux *Mux, name string) (*TypedConnection[T], error) {
+	stream, err := mux.OpenStream(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := NewTypedConnection[T](stream, ConnectionTypeTCP)
+
+	return &tc, nil
+}
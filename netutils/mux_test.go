@@ -0,0 +1,174 @@
+package netutils
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func newTestMux(t *testing.T, opts ...MuxOptions) (client, server *Mux) {
+	t.Helper()
+
+	a, b := net.Pipe()
+	client, server = NewMuxClient(a, opts...), NewMuxServer(b, opts...)
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	return client, server
+}
+
+func TestMuxTypedStreams(t *testing.T) {
+	client, server := newTestMux(t)
+
+	go func() {
+		for {
+			stream, err := server.AcceptStream()
+			if err != nil {
+				return
+			}
+
+			// Echo each stream back with the type that its name describes.
+			switch stream.Name() {
+			case "text":
+				conn := NewTypedConnection[testMessage](stream, ConnectionTypeTCP)
+				var message testMessage
+				if _, err := conn.Receive(&message); err == nil {
+					_, _ = conn.Send(message)
+				}
+			case "number":
+				conn := NewTypedConnection[otherTestMessage](stream, ConnectionTypeTCP)
+				var message otherTestMessage
+				if _, err := conn.Receive(&message); err == nil {
+					_, _ = conn.Send(message)
+				}
+			}
+		}
+	}()
+
+	text, err := OpenTypedStream[testMessage](client, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	number, err := OpenTypedStream[otherTestMessage](client, "number")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := number.Send(otherTestMessage{Number: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := text.Send(testMessage{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotText testMessage
+	if _, err := text.Receive(&gotText); err != nil || gotText.Text != "hello" {
+		t.Fatalf("text stream received %+v, %v", gotText, err)
+	}
+	var gotNumber otherTestMessage
+	if _, err := number.Receive(&gotNumber); err != nil || gotNumber.Number != 42 {
+		t.Fatalf("number stream received %+v, %v", gotNumber, err)
+	}
+}
+
+func TestMuxFlowControl(t *testing.T) {
+	client, server := newTestMux(t, MuxOptions{WindowSize: 1024, AcceptBacklog: 4})
+
+	slow, err := client.OpenStream("slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := client.OpenStream("fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Filling the window of a stream that is never read must not stop other streams.
+	go func() { _, _ = slow.Write(make([]byte, 64*1024)) }()
+
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	go func() {
+		_, _ = fast.Write(data)
+		_ = fast.Close()
+	}()
+
+	for {
+		stream, err := server.AcceptStream()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stream.Name() != "fast" {
+			continue
+		}
+
+		got, err := io.ReadAll(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("received %d bytes, want %d", len(got), len(data))
+		}
+
+		return
+	}
+}
+
+func TestMuxWindowNegotiation(t *testing.T) {
+	a, b := net.Pipe()
+	client := NewMuxClient(a, MuxOptions{WindowSize: 1 << 20})
+	server := NewMuxServer(b)
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	// The client must not send more than the server's smaller window.
+	data := bytes.Repeat([]byte("0123456789"), 100*1024)
+	go func() {
+		stream, err := client.OpenStream("big")
+		if err != nil {
+			return
+		}
+
+		_, _ = stream.Write(data)
+		_ = stream.Close()
+	}()
+
+	stream, err := server.AcceptStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("received %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestMuxAcceptBacklogDefault(t *testing.T) {
+	// Options that leave AcceptBacklog unset still queue streams until they are
+	// accepted.
+	client, server := newTestMux(t, MuxOptions{WindowSize: 1024})
+
+	for _, name := range []string{"one", "two", "three"} {
+		if _, err := client.OpenStream(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"one", "two", "three"} {
+		stream, err := server.AcceptStream()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stream.Name() != name {
+			t.Errorf("stream should be %q, got %q", name, stream.Name())
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package netutils
+
+import "net"
+
+// Pipe creates two TypedConnections that are connected to each other in memory using
+// net.Pipe, so that anything sent on one is received on the other. This allows codecs,
+// framing, and handlers to be exercised in tests without opening real sockets.
+//
+// As with net.Pipe, the connections are synchronous and unbuffered: each write blocks
+// until the other side has read all of it. The connections report themselves as TCP
+// connections, as they are reliable streams.
+func Pipe[T Convertable]() (*TypedConnection[T], *TypedConnection[T]) {
+	a, b := net.Pipe()
+
+	left := NewTypedConnection[T](a, ConnectionTypeTCP)
+	right := NewTypedConnection[T](b, ConnectionTypeTCP)
+
+	return &left, &right
+}
+
+// PipeAsymmetric is like Pipe, but for protocols where each side speaks a different
+// message type. The first connection sends values of type S and receives values of type
+// R, and the second connection the reverse.
+func PipeAsymmetric[S, R Convertable]() (*AsymmetricTypedConnection[S, R], *AsymmetricTypedConnection[R, S]) {
+	a, b := net.Pipe()
+
+	left := NewAsymmetricTypedConnection[S, R](a, ConnectionTypeTCP)
+	right := NewAsymmetricTypedConnection[R, S](b, ConnectionTypeTCP)
+
+	return &left, &right
+}
@@ -0,0 +1,263 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned from Pool.Get once the pool has been closed.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// PoolOptions is a struct used by NewPool to define certain optional parameters.
+type PoolOptions[T Convertable] struct {
+	// MaxSize is the maximum amount of connections, both idle and in use, that the pool
+	// will have open at once. Get blocks once this limit has been reached. A zero or
+	// negative MaxSize means that there is no limit.
+	MaxSize int
+
+	// IdleTimeout is how long a connection may sit idle in the pool before it is closed.
+	// A zero or negative IdleTimeout means that idle connections are never expired.
+	IdleTimeout time.Duration
+
+	// HealthCheck is called on an idle connection before it is handed out by Get. If it
+	// returns an error, the connection is closed and another one is used instead. If
+	// nil, no health check is performed.
+	HealthCheck func(conn *TCPTypedConnection[T]) error
+}
+
+func defaultPoolOptions[T Convertable]() PoolOptions[T] {
+	return PoolOptions[T]{
+		MaxSize:     16,
+		IdleTimeout: 90 * time.Second,
+		HealthCheck: ProbeConnection[T],
+	}
+}
+
+// ProbeConnection is the default health check used by Pool. It checks that the peer has
+// not closed the connection by briefly attempting to read from it. As idle connections
+// should not be receiving anything, any data that is read is also treated as unhealthy.
+func ProbeConnection[T Convertable](conn *TCPTypedConnection[T]) error {
+	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	var buffer [1]byte
+	_, err := conn.conn.Read(buffer[:])
+
+	switch {
+	case err == nil:
+		return errors.New("unexpected data on idle connection")
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return nil
+	default:
+		return err
+	}
+}
+
+type pooledConnection[T Convertable] struct {
+	conn     *TCPTypedConnection[T]
+	idleFrom time.Time
+}
+
+// Pool is a client-side pool of TCPTypedConnections to a single destination. Connections
+// are taken from the pool with Get and must be handed back with either Put, if they can
+// be reused, or Discard, if they are broken.
+//
+// A Pool is safe for concurrent use.
+type Pool[T Convertable] struct {
+	dial    func() (*TCPTypedConnection[T], error)
+	options PoolOptions[T]
+
+	mu     sync.Mutex
+	idle   []pooledConnection[T]
+	open   int
+	closed bool
+	notify chan struct{}
+}
+
+// NewPool creates a new *Pool which uses dial to open new connections.
+//
+// This takes a variadic parameter of type PoolOptions. If no PoolOptions are supplied,
+// then the defaults are used. If more than one PoolOptions are supplied then only the
+// first will be used.
+func NewPool[T Convertable](dial func() (*TCPTypedConnection[T], error), opts ...PoolOptions[T]) *Pool[T] {
+	options := defaultPoolOptions[T]()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	return &Pool[T]{
+		dial:    dial,
+		options: options,
+		notify:  make(chan struct{}),
+	}
+}
+
+// NewTCPPool creates a new *Pool of connections to the TCP socket at host:port. See
+// NewPool.
+func NewTCPPool[T Convertable](host, port string, opts ...PoolOptions[T]) *Pool[T] {
+	return NewPool(func() (*TCPTypedConnection[T], error) {
+		conn, err := dial("tcp", host, port, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		tc := NewTCPTypedConnection[T](conn)
+
+		return &tc, nil
+	}, opts...)
+}
+
+// wake wakes up every Get that is waiting for a connection. p.mu must be held.
+func (p *Pool[T]) wake() {
+	close(p.notify)
+	p.notify = make(chan struct{})
+}
+
+// Get returns an idle connection from the pool, or dials a new one if there are no idle
+// connections. If the pool is at its maximum size, Get blocks until a connection is
+// returned to the pool or ctx is done.
+func (p *Pool[T]) Get(ctx context.Context) (*TCPTypedConnection[T], error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		if n := len(p.idle); n > 0 {
+			pooled := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+
+			if p.expired(pooled) || !p.healthy(pooled.conn) {
+				p.Discard(pooled.conn)
+				continue
+			}
+
+			return pooled.conn, nil
+		}
+
+		if p.options.MaxSize <= 0 || p.open < p.options.MaxSize {
+			p.open++
+			p.mu.Unlock()
+
+			conn, err := p.dial()
+			if err != nil {
+				p.mu.Lock()
+				p.open--
+				p.wake()
+				p.mu.Unlock()
+
+				return nil, err
+			}
+
+			return conn, nil
+		}
+
+		notify := p.notify
+		p.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *Pool[T]) expired(pooled pooledConnection[T]) bool {
+	return p.options.IdleTimeout > 0 && time.Since(pooled.idleFrom) > p.options.IdleTimeout
+}
+
+func (p *Pool[T]) healthy(conn *TCPTypedConnection[T]) bool {
+	return p.options.HealthCheck == nil || p.options.HealthCheck(conn) == nil
+}
+
+// Put returns conn to the pool so that it can be reused by a later Get. If the pool has
+// been closed, conn is closed instead.
+func (p *Pool[T]) Put(conn *TCPTypedConnection[T]) {
+	p.mu.Lock()
+	if p.closed {
+		p.open--
+		p.mu.Unlock()
+
+		_ = conn.Close()
+
+		return
+	}
+
+	var expired []*TCPTypedConnection[T]
+	live := p.idle[:0]
+	for _, pooled := range p.idle {
+		if p.expired(pooled) {
+			expired = append(expired, pooled.conn)
+			continue
+		}
+
+		live = append(live, pooled)
+	}
+	p.idle = append(live, pooledConnection[T]{conn: conn, idleFrom: time.Now()})
+	p.open -= len(expired)
+	p.wake()
+	p.mu.Unlock()
+
+	// Connections are closed without holding the lock, as closing can block on the
+	// network.
+	for _, conn := range expired {
+		_ = conn.Close()
+	}
+}
+
+// Discard closes conn and frees its slot in the pool. This should be used instead of Put
+// for connections that have failed.
+func (p *Pool[T]) Discard(conn *TCPTypedConnection[T]) {
+	_ = conn.Close()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.open--
+	p.wake()
+}
+
+// Len returns the amount of open connections, both idle and in use.
+func (p *Pool[T]) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.open
+}
+
+// Idle returns the amount of idle connections in the pool.
+func (p *Pool[T]) Idle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.idle)
+}
+
+// Close closes all idle connections and causes all future calls to Get to fail with
+// ErrPoolClosed. Connections that are in use are closed when they are handed back.
+func (p *Pool[T]) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.open -= len(idle)
+	p.closed = true
+	p.wake()
+	p.mu.Unlock()
+
+	var errs []error
+	for _, pooled := range idle {
+		if err := pooled.conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,157 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testDialer dials connections over pipes, keeping the server side of each one.
+type testDialer struct {
+	mu      sync.Mutex
+	servers []net.Conn
+}
+
+func (td *testDialer) dial() (*TCPTypedConnection[testMessage], error) {
+	a, b := net.Pipe()
+
+	td.mu.Lock()
+	td.servers = append(td.servers, b)
+	td.mu.Unlock()
+
+	conn := NewTCPTypedConnection[testMessage](a)
+
+	return &conn, nil
+}
+
+func (td *testDialer) dialled() int {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	return len(td.servers)
+}
+
+func (td *testDialer) close() {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	for _, server := range td.servers {
+		_ = server.Close()
+	}
+}
+
+func TestPoolMaxSize(t *testing.T) {
+	dialer := &testDialer{}
+	defer dialer.close()
+
+	pool := NewPool(dialer.dial, PoolOptions[testMessage]{MaxSize: 1})
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get should block while the pool is full, got %v", err)
+	}
+
+	got := make(chan *TCPTypedConnection[testMessage], 1)
+	go func() {
+		conn, err := pool.Get(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+
+		got <- conn
+	}()
+
+	pool.Put(first)
+	if conn := <-got; conn != first {
+		t.Error("waiting Get should receive the connection that was put back")
+	}
+	if dialer.dialled() != 1 {
+		t.Errorf("pool should only dial once, dialled %d times", dialer.dialled())
+	}
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	dialer := &testDialer{}
+	defer dialer.close()
+
+	pool := NewPool(dialer.dial, PoolOptions[testMessage]{IdleTimeout: 10 * time.Millisecond})
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.Put(first)
+	time.Sleep(20 * time.Millisecond)
+
+	// Putting a connection back closes those that have been idle for too long.
+	pool.Put(second)
+	if pool.Idle() != 1 || pool.Len() != 1 {
+		t.Errorf("expired connection should be closed, got %d idle of %d", pool.Idle(), pool.Len())
+	}
+	if err := first.SetReadDeadline(time.Time{}); err == nil {
+		t.Error("expired connection should be closed")
+	}
+
+	// Getting a connection skips those that have been idle for too long.
+	time.Sleep(20 * time.Millisecond)
+	conn, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn == second || dialer.dialled() != 3 {
+		t.Error("expired connection should not be handed out")
+	}
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	dialer := &testDialer{}
+	defer dialer.close()
+
+	pool := NewPool(dialer.dial, PoolOptions[testMessage]{HealthCheck: ProbeConnection[testMessage]})
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(first)
+
+	// A healthy idle connection is reused.
+	conn, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn != first {
+		t.Fatal("healthy connection should be reused")
+	}
+	pool.Put(conn)
+
+	// One whose peer has gone away is replaced.
+	dialer.close()
+	conn, err = pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn == first || dialer.dialled() != 2 {
+		t.Error("unhealthy connection should be replaced")
+	}
+	if pool.Len() != 1 {
+		t.Errorf("unhealthy connection should be discarded, got %d open", pool.Len())
+	}
+}
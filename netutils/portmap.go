@@ -0,0 +1,364 @@
+package netutils
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// NATPMPPort is the port that NAT-PMP gateways listen on.
+const NATPMPPort = "5351"
+
+// ErrNoPortMapper is returned by MapPort if the gateway supports neither NAT-PMP nor
+// UPnP.
+var ErrNoPortMapper = errors.New("gateway supports neither NAT-PMP nor UPnP")
+
+// PortMappingOptions is a struct used by MapPort to define certain optional parameters.
+type PortMappingOptions struct {
+	// ExternalPort is the port to request on the gateway. If zero, the internal port is
+	// requested. The gateway may choose a different port; see PortMapping.ExternalPort.
+	ExternalPort int
+
+	// Lifetime is how long the gateway keeps the mapping for. The mapping is renewed
+	// automatically until PortMapping.Close is called.
+	Lifetime time.Duration
+
+	// Description is a human-readable description of the mapping, shown by some
+	// gateways. It is only used by UPnP.
+	Description string
+
+	// Gateway is the address of the NAT-PMP gateway, as "host:port" or a bare host in
+	// which case NATPMPPort is used. If empty, the default gateway of the system is
+	// used where it can be found.
+	Gateway string
+
+	// DisableNATPMP and DisableUPnP stop MapPort from trying the respective protocol.
+	DisableNATPMP bool
+	DisableUPnP   bool
+}
+
+func defaultPortMappingOptions() PortMappingOptions {
+	return PortMappingOptions{
+		Lifetime:    time.Hour,
+		Description: "netutils",
+	}
+}
+
+// portMapper is a protocol that can ask a gateway for port mappings.
+type portMapper interface {
+	addMapping(ctx context.Context, protocol string, internal, external int, lifetime time.Duration, description string) (int, error)
+	removeMapping(ctx context.Context, protocol string, internal, external int) error
+	externalIP(ctx context.Context) (net.IP, error)
+	String() string
+}
+
+// PortMapping is a port forward on the local gateway, created by MapPort. It is renewed
+// in the background until Close is called.
+type PortMapping struct {
+	mapper   portMapper
+	protocol string
+	internal int
+	options  PortMappingOptions
+
+	mu       sync.Mutex
+	external int
+	err      error
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// MapPort asks the local gateway to forward port on its external address to port on this
+// host, so that peers outside of the local network can connect to a server listening on
+// it. network is either "tcp" or "udp". NAT-PMP, as described by RFC 6886, is tried
+// first, followed by UPnP Internet Gateway Device port mappings. If neither are
+// available before ctx is done, an error wrapping ErrNoPortMapper is returned.
+//
+// The mapping should be removed with PortMapping.Close when it is no longer needed,
+// usually when the server is shut down.
+//
+// This takes a variadic parameter of type PortMappingOptions. If no PortMappingOptions
+// are supplied, then the defaults are used. If more than one PortMappingOptions are
+// supplied then only the first will be used.
+func MapPort(ctx context.Context, network string, port int, opts ...PortMappingOptions) (*PortMapping, error) {
+	options := defaultPortMappingOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Lifetime <= 0 {
+		options.Lifetime = defaultPortMappingOptions().Lifetime
+	}
+	if options.ExternalPort == 0 {
+		options.ExternalPort = port
+	}
+
+	switch network {
+	case "tcp", "udp":
+	default:
+		return nil, fmt.Errorf("unsupported network %q", network)
+	}
+	if port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", port)
+	}
+
+	var errs []error
+	for _, mapper := range portMappers(options, &errs) {
+		external, err := mapper.addMapping(ctx, network, port, options.ExternalPort, options.Lifetime, options.Description)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", mapper, err))
+			continue
+		}
+
+		pm := &PortMapping{
+			mapper:   mapper,
+			protocol: network,
+			internal: port,
+			options:  options,
+			external: external,
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+		go pm.renew()
+
+		return pm, nil
+	}
+
+	return nil, errors.Join(append([]error{ErrNoPortMapper}, errs...)...)
+}
+
+// portMappers returns the mappers to try in order, appending the reasons for any that
+// could not be set up to errs.
+func portMappers(options PortMappingOptions, errs *[]error) []portMapper {
+	var mappers []portMapper
+
+	if !options.DisableNATPMP {
+		gateway := options.Gateway
+		if gateway == "" {
+			if ip, err := defaultGateway(); err == nil {
+				gateway = ip.String()
+			} else {
+				*errs = append(*errs, fmt.Errorf("NAT-PMP: %w", err))
+			}
+		}
+
+		if gateway != "" {
+			if _, _, err := net.SplitHostPort(gateway); err != nil {
+				gateway = net.JoinHostPort(gateway, NATPMPPort)
+			}
+
+			mappers = append(mappers, natpmpMapper{gateway: gateway})
+		}
+	}
+
+	if !options.DisableUPnP {
+		mappers = append(mappers, &lazyUPnPMapper{})
+	}
+
+	return mappers
+}
+
+func (pm *PortMapping) renew() {
+	defer close(pm.done)
+
+	interval := pm.options.Lifetime / 2
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-pm.stop:
+			return
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval/2)
+		pm.mu.Lock()
+		requested := pm.external
+		pm.mu.Unlock()
+
+		external, err := pm.mapper.addMapping(ctx, pm.protocol, pm.internal, requested, pm.options.Lifetime, pm.options.Description)
+		cancel()
+
+		pm.mu.Lock()
+		if err == nil {
+			pm.external = external
+		}
+		pm.err = err
+		pm.mu.Unlock()
+
+		// Retry failed renewals sooner, while the previous mapping may still be live.
+		next := interval
+		if err != nil {
+			next = min(interval, 30*time.Second)
+		}
+		timer.Reset(next)
+	}
+}
+
+// ExternalPort returns the port that the gateway forwards to this host. This may change
+// if the gateway picks a different port when the mapping is renewed.
+func (pm *PortMapping) ExternalPort() int {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	return pm.external
+}
+
+// ExternalIP asks the gateway for its external address.
+func (pm *PortMapping) ExternalIP(ctx context.Context) (net.IP, error) {
+	return pm.mapper.externalIP(ctx)
+}
+
+// Err returns the error of the most recent renewal, or nil if it succeeded.
+func (pm *PortMapping) Err() error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	return pm.err
+}
+
+// Close stops renewing the mapping and asks the gateway to remove it.
+func (pm *PortMapping) Close() error {
+	var err error
+	pm.closeOnce.Do(func() {
+		close(pm.stop)
+		<-pm.done
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err = pm.mapper.removeMapping(ctx, pm.protocol, pm.internal, pm.ExternalPort())
+	})
+
+	return err
+}
+
+// natpmpMapper maps ports using NAT-PMP.
+type natpmpMapper struct {
+	gateway string
+}
+
+func (natpmpMapper) String() string { return "NAT-PMP" }
+
+const (
+	natpmpOpExternalAddress byte = 0
+	natpmpOpMapUDP          byte = 1
+	natpmpOpMapTCP          byte = 2
+
+	// RFC 6886 allows for up to 9 attempts, but that takes over two minutes to give up
+	// on gateways without NAT-PMP, which holds up trying UPnP instead.
+	natpmpInitialTimeout = 250 * time.Millisecond
+	natpmpMaxAttempts    = 4
+)
+
+var natpmpResults = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized or refused",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// request sends request to the gateway, retransmitting as described by RFC 6886, and
+// returns the response, which must be at least size bytes long.
+func (nm natpmpMapper) request(ctx context.Context, request []byte, size int) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp4", nm.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buffer := make([]byte, 16)
+	timeout := natpmpInitialTimeout
+	for range natpmpMaxAttempts {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+
+		for {
+			n, err := conn.Read(buffer)
+			if err != nil {
+				if !errors.Is(err, os.ErrDeadlineExceeded) {
+					return nil, err
+				}
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+
+				break
+			}
+
+			response := buffer[:n]
+			if n < 4 || response[0] != 0 || response[1] != 128+request[1] {
+				continue
+			}
+			if result := binary.BigEndian.Uint16(response[2:]); result != 0 {
+				reason, ok := natpmpResults[result]
+				if !ok {
+					reason = fmt.Sprintf("result code %d", result)
+				}
+
+				return nil, fmt.Errorf("gateway refused request: %s", reason)
+			}
+			if n < size {
+				return nil, errors.New("truncated NAT-PMP response")
+			}
+
+			return response, nil
+		}
+
+		timeout *= 2
+	}
+
+	return nil, errors.New("no response from NAT-PMP gateway")
+}
+
+func (nm natpmpMapper) addMapping(ctx context.Context, protocol string, internal, external int, lifetime time.Duration, _ string) (int, error) {
+	op := natpmpOpMapTCP
+	if protocol == "udp" {
+		op = natpmpOpMapUDP
+	}
+
+	request := make([]byte, 12)
+	request[1] = op
+	binary.BigEndian.PutUint16(request[4:], uint16(internal))
+	binary.BigEndian.PutUint16(request[6:], uint16(external))
+	binary.BigEndian.PutUint32(request[8:], uint32(lifetime/time.Second))
+
+	response, err := nm.request(ctx, request, 16)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(binary.BigEndian.Uint16(response[10:])), nil
+}
+
+func (nm natpmpMapper) removeMapping(ctx context.Context, protocol string, internal, _ int) error {
+	// A mapping is removed by requesting it again with no lifetime and no external port.
+	_, err := nm.addMapping(ctx, protocol, internal, 0, 0, "")
+	return err
+}
+
+func (nm natpmpMapper) externalIP(ctx context.Context) (net.IP, error) {
+	response, err := nm.request(ctx, []byte{0, natpmpOpExternalAddress}, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.IP(append([]byte(nil), response[8:12]...)), nil
+}
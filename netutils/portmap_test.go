@@ -0,0 +1,184 @@
+package netutils
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serveNATPMP answers NAT-PMP requests on conn, mapping every port to itself plus 1000.
+func serveNATPMP(conn net.PacketConn, requests chan<- []byte) {
+	buffer := make([]byte, 64)
+	for {
+		n, from, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		request := append([]byte(nil), buffer[:n]...)
+		requests <- request
+
+		switch request[1] {
+		case natpmpOpExternalAddress:
+			response := []byte{0, 128, 0, 0, 0, 0, 0, 1, 203, 0, 113, 7}
+			_, _ = conn.WriteTo(response, from)
+		case natpmpOpMapTCP, natpmpOpMapUDP:
+			response := make([]byte, 16)
+			response[1] = 128 + request[1]
+			copy(response[8:10], request[4:6])
+			external := binary.BigEndian.Uint16(request[6:])
+			if external != 0 {
+				external += 1000
+			}
+			binary.BigEndian.PutUint16(response[10:], external)
+			copy(response[12:], request[8:12])
+			_, _ = conn.WriteTo(response, from)
+		}
+	}
+}
+
+func TestMapPortNATPMP(t *testing.T) {
+	gateway, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	requests := make(chan []byte, 16)
+	go serveNATPMP(gateway, requests)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mapping, err := MapPort(ctx, "tcp", 8080, PortMappingOptions{
+		Lifetime:    time.Hour,
+		Gateway:     gateway.LocalAddr().String(),
+		DisableUPnP: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if port := mapping.ExternalPort(); port != 9080 {
+		t.Errorf("expected external port 9080, got %d", port)
+	}
+
+	request := <-requests
+	if request[1] != natpmpOpMapTCP || binary.BigEndian.Uint16(request[4:]) != 8080 || binary.BigEndian.Uint32(request[8:]) != 3600 {
+		t.Errorf("unexpected map request %v", request)
+	}
+
+	ip, err := mapping.ExternalIP(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.IPv4(203, 0, 113, 7)) {
+		t.Errorf("expected external address 203.0.113.7, got %s", ip)
+	}
+	<-requests
+
+	if err := mapping.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	request = <-requests
+	if binary.BigEndian.Uint16(request[6:]) != 0 || binary.BigEndian.Uint32(request[8:]) != 0 {
+		t.Errorf("expected a removal request, got %v", request)
+	}
+}
+
+func TestUPnPMapper(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		actions []string
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList><device><deviceList><device>
+      <serviceList><service>
+        <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+        <controlURL>/ctl/IPConn</controlURL>
+      </service></serviceList>
+    </device></deviceList></device></deviceList>
+  </device>
+</root>`)
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		actions = append(actions, r.Header.Get("SOAPAction"))
+		mu.Unlock()
+
+		switch {
+		case strings.Contains(string(body), "GetExternalIPAddress"):
+			_, _ = io.WriteString(w, `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>
+<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+<NewExternalIPAddress>198.51.100.4</NewExternalIPAddress>
+</u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+		case strings.Contains(string(body), "<NewExternalPort>1</NewExternalPort>"):
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><detail>
+<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>718</errorCode><errorDescription>ConflictInMappingEntry</errorDescription></UPnPError>
+</detail></s:Fault></s:Body></s:Envelope>`)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mapper, err := newUPnPMapper(ctx, server.URL+"/rootDesc.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if port, err := mapper.addMapping(ctx, "udp", 4000, 4000, time.Hour, "test"); err != nil || port != 4000 {
+		t.Fatalf("expected port 4000, got %d: %v", port, err)
+	}
+
+	if _, err := mapper.addMapping(ctx, "udp", 4000, 1, time.Hour, "test"); err == nil || !strings.Contains(err.Error(), "718") {
+		t.Errorf("expected UPnP error 718, got %v", err)
+	}
+
+	ip, err := mapper.externalIP(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.IPv4(198, 51, 100, 4)) {
+		t.Errorf("expected external address 198.51.100.4, got %s", ip)
+	}
+
+	if err := mapper.removeMapping(ctx, "udp", 4000, 4000); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	const service = "urn:schemas-upnp-org:service:WANIPConnection:1"
+	want := []string{
+		`"` + service + `#AddPortMapping"`,
+		`"` + service + `#AddPortMapping"`,
+		`"` + service + `#GetExternalIPAddress"`,
+		`"` + service + `#DeletePortMapping"`,
+	}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Errorf("expected actions %v, got %v", want, actions)
+	}
+}
@@ -0,0 +1,155 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// GetFreePort asks the operating system for a free TCP port on the loopback interface.
+// The port is found by binding to it and then releasing it, so there is a small window
+// in which another process may take it; use ListenFreePort to avoid this where the
+// listener can be handed over directly.
+func GetFreePort() (int, error) {
+	ports, err := GetFreePorts(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return ports[0], nil
+}
+
+// GetFreePorts is like GetFreePort, but returns n distinct free ports. All of the ports
+// are held at once before any are released, so none of them are repeated.
+func GetFreePorts(n int) ([]int, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("amount of ports must not be negative, got %d", n)
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	defer func() {
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+	}()
+
+	ports := make([]int, 0, n)
+	for range n {
+		listener, port, err := ListenFreePort()
+		if err != nil {
+			return nil, err
+		}
+
+		listeners = append(listeners, listener)
+		ports = append(ports, port)
+	}
+
+	return ports, nil
+}
+
+// ListenFreePort binds a TCP listener to a free port on the loopback interface, returning
+// the listener and its port. Unlike GetFreePort, the port stays reserved for as long as
+// the listener is open. The listener can be used for a typed server with
+// NewTypedListener.
+func ListenFreePort() (net.Listener, int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not find a free port: %w", err)
+	}
+
+	return listener, listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// DefaultWaitInterval is the interval used by WaitForPort if a zero or negative interval
+// is given.
+const DefaultWaitInterval = 100 * time.Millisecond
+
+// WaitForPort polls address on the given network every interval until it accepts a
+// connection, returning nil once it does. If ctx is done first, an error wrapping both
+// ctx.Err() and the error of the last attempt is returned. This is useful for waiting on
+// a server started by a test, or on a service that another depends on.
+//
+// For TCP networks, the port is ready once a connection can be established. As UDP is
+// connectionless, a UDP port is considered ready once an empty datagram sent to it is not
+// rejected with an ICMP port unreachable message, which is surfaced as a refused
+// connection. This is only reliable for hosts that send such messages, such as the
+// local system.
+func WaitForPort(ctx context.Context, network, address string, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultWaitInterval
+	}
+
+	var probe func(ctx context.Context) error
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		probe = func(ctx context.Context) error {
+			return probeTCP(ctx, network, address)
+		}
+	case "udp", "udp4", "udp6":
+		probe = func(ctx context.Context) error {
+			return probeUDP(ctx, network, address, interval)
+		}
+	default:
+		return fmt.Errorf("unsupported network %q", network)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := probe(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s %s did not become ready: %w", network, address, errors.Join(ctx.Err(), err))
+		case <-ticker.C:
+		}
+	}
+}
+
+func probeTCP(ctx context.Context, network, address string) error {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// probeUDP sends an empty datagram to address and waits up to timeout for a refusal.
+func probeUDP(ctx context.Context, network, address string, timeout time.Duration) error {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(nil); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+
+	var buffer [1]byte
+	if _, err := conn.Read(buffer[:]); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package netutils
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWaitForPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := WaitForPort(ctx, "tcp", address, 10*time.Millisecond); err == nil {
+		t.Fatal("expected an error for a closed port")
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { _ = listener.Close() })
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := WaitForPort(ctx, "tcp", address, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForPortUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := WaitForPort(ctx, "udp", conn.LocalAddr().String(), 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetFreePorts(t *testing.T) {
+	ports, err := GetFreePorts(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[int]bool)
+	for _, port := range ports {
+		if port <= 0 || seen[port] {
+			t.Fatalf("expected distinct positive ports, got %v", ports)
+		}
+		seen[port] = true
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(ports[0])))
+	if err != nil {
+		t.Fatalf("port %d is not free: %v", ports[0], err)
+	}
+	_ = listener.Close()
+}
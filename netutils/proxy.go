@@ -0,0 +1,413 @@
+package netutils
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProxyOptions is a struct used by DialTCPViaProxy to define certain optional parameters.
+type ProxyOptions struct {
+	// Proxy is the URL of the proxy to connect through. The supported schemes are "http"
+	// and "https", which use HTTP CONNECT, and "socks5" and "socks5h", which use SOCKS5;
+	// with "socks5" the destination is resolved locally, and with "socks5h" it is
+	// resolved by the proxy. Credentials in the URL are used to authenticate with the
+	// proxy.
+	//
+	// If nil, the proxy is taken from the first of the HTTPS_PROXY, ALL_PROXY, and
+	// HTTP_PROXY environment variables (or their lowercase forms) to be set, honouring
+	// NO_PROXY. If no proxy is configured for the destination, it is dialled directly.
+	Proxy *url.URL
+
+	// DialOptions are used to dial the proxy. The Timeout also covers the handshake with
+	// the proxy.
+	DialOptions DialOptions
+}
+
+func defaultProxyOptions() ProxyOptions {
+	return ProxyOptions{DialOptions: defaultDialOptions()}
+}
+
+// DialTCPViaProxy attempts to connect to a given TCP socket at host:port through an HTTP
+// CONNECT or SOCKS5 proxy, and creates a new *TCPTypedConnection on success. On failure,
+// an error is returned.
+//
+// This takes a variadic parameter of type ProxyOptions. If no ProxyOptions are supplied,
+// then the defaults are used, which take the proxy from the environment. If more than
+// one ProxyOptions are supplied then only the first will be used.
+func DialTCPViaProxy[T Convertable](host, port string, opts ...ProxyOptions) (*TCPTypedConnection[T], error) {
+	options := defaultProxyOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	conn, err := dialViaProxy(host, port, options)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := NewTCPTypedConnection[T](conn)
+	tc.SetLogger(options.DialOptions.Logger)
+
+	return &tc, nil
+}
+
+// proxyFromEnvironment returns the proxy configured in the environment for connections
+// to host, or nil if there is none.
+func proxyFromEnvironment(host string) (*url.URL, error) {
+	if excludedByNoProxy(host) {
+		return nil, nil
+	}
+
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy", "HTTP_PROXY", "http_proxy"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+
+		// Proxies are commonly given without a scheme, in which case HTTP is assumed.
+		if !strings.Contains(value, "://") {
+			value = "http://" + value
+		}
+
+		proxy, err := url.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return proxy, nil
+	}
+
+	return nil, nil
+}
+
+// excludedByNoProxy reports whether the NO_PROXY environment variable excludes host. It
+// holds a comma-separated list of host names, which also match their subdomains, IP
+// addresses, and CIDR ranges, or "*" to exclude every host.
+func excludedByNoProxy(host string) bool {
+	value := os.Getenv("NO_PROXY")
+	if value == "" {
+		value = os.Getenv("no_proxy")
+	}
+
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+
+			continue
+		}
+
+		if entryHost, _, err := net.SplitHostPort(entry); err == nil {
+			entry = entryHost
+		}
+		entry = strings.TrimPrefix(entry, ".")
+
+		host := strings.ToLower(host)
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func dialViaProxy(host, port string, options ProxyOptions) (net.Conn, error) {
+	address := net.JoinHostPort(host, port)
+
+	proxy := options.Proxy
+	if proxy == nil {
+		var err error
+		if proxy, err = proxyFromEnvironment(host); err != nil {
+			return nil, err
+		}
+	}
+
+	if proxy == nil {
+		return dial("tcp", host, port, []DialOptions{options.DialOptions})
+	}
+
+	proxyPort := proxy.Port()
+	if proxyPort == "" {
+		switch proxy.Scheme {
+		case "http":
+			proxyPort = "80"
+		case "https":
+			proxyPort = "443"
+		default:
+			proxyPort = "1080"
+		}
+	}
+
+	conn, err := dial("tcp", proxy.Hostname(), proxyPort, []DialOptions{options.DialOptions})
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to proxy: %w", err)
+	}
+
+	if options.DialOptions.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(options.DialOptions.Timeout))
+	}
+
+	switch proxy.Scheme {
+	case "http":
+		conn, err = connectHTTP(conn, proxy, address)
+	case "https":
+		conn = tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+		conn, err = connectHTTP(conn, proxy, address)
+	case "socks5", "socks5h":
+		err = connectSOCKS5(conn, proxy, host, port)
+	default:
+		err = fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+	}
+
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose first reads are served from a bufio.Reader, which
+// may hold data that the peer sent straight after the end of a handshake.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (bc *bufferedConn) Read(p []byte) (int, error) {
+	return bc.reader.Read(p)
+}
+
+// connectHTTP asks the HTTP proxy on conn to open a tunnel to address.
+func connectHTTP(conn net.Conn, proxy *url.URL, address string) (net.Conn, error) {
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		request.SetBasicAuth(proxy.User.Username(), password)
+		request.Header.Set("Proxy-Authorization", request.Header.Get("Authorization"))
+		request.Header.Del("Authorization")
+	}
+
+	if err := request.Write(conn); err != nil {
+		return nil, fmt.Errorf("could not send CONNECT request to proxy: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CONNECT response from proxy: %w", err)
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", address, response.Status)
+	}
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+const (
+	socks5Version          = 5
+	socks5NoAuth           = 0
+	socks5PasswordAuth     = 2
+	socks5NoAcceptableAuth = 0xff
+	socks5Connect          = 1
+	socks5IPv4             = 1
+	socks5Domain           = 3
+	socks5IPv6             = 4
+)
+
+// connectSOCKS5 asks the SOCKS5 proxy on conn to connect to host:port, as described by
+// RFC 1928, authenticating with a username and password as described by RFC 1929 if the
+// proxy URL has credentials.
+func connectSOCKS5(conn net.Conn, proxy *url.URL, host, port string) error {
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		if portNumber, err = lookupPort(port); err != nil {
+			return err
+		}
+	}
+
+	methods := []byte{socks5NoAuth}
+	if proxy.User != nil {
+		methods = append(methods, socks5PasswordAuth)
+	}
+
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return fmt.Errorf("could not send SOCKS5 greeting: %w", err)
+	}
+
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return fmt.Errorf("could not read SOCKS5 greeting: %w", err)
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("proxy is not a SOCKS5 proxy, got version %d", reply[0])
+	}
+
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5PasswordAuth:
+		if err := authenticateSOCKS5(conn, proxy.User); err != nil {
+			return err
+		}
+	case socks5NoAcceptableAuth:
+		return errors.New("proxy accepted none of the offered SOCKS5 authentication methods")
+	default:
+		return fmt.Errorf("proxy chose unsupported SOCKS5 authentication method %d", reply[1])
+	}
+
+	request := []byte{socks5Version, socks5Connect, 0}
+
+	ip := net.ParseIP(host)
+	if ip == nil && proxy.Scheme == "socks5" {
+		addrs, err := net.LookupIP(host)
+		if err != nil {
+			return fmt.Errorf("could not resolve %s: %w", host, err)
+		}
+		ip = addrs[0]
+	}
+
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q is too long for SOCKS5", host)
+		}
+		request = append(request, socks5Domain, byte(len(host)))
+		request = append(request, host...)
+	case ip.To4() != nil:
+		request = append(append(request, socks5IPv4), ip.To4()...)
+	default:
+		request = append(append(request, socks5IPv6), ip.To16()...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(portNumber))
+
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("could not send SOCKS5 connect request: %w", err)
+	}
+
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return fmt.Errorf("could not read SOCKS5 connect reply: %w", err)
+	}
+	if header[1] != 0 {
+		return fmt.Errorf("proxy refused SOCKS5 connect to %s: %s", net.JoinHostPort(host, port), socks5ReplyText(header[1]))
+	}
+
+	// Discard the bound address, whose length depends on its type.
+	var skip int
+	switch header[3] {
+	case socks5IPv4:
+		skip = net.IPv4len
+	case socks5IPv6:
+		skip = net.IPv6len
+	case socks5Domain:
+		var length [1]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return fmt.Errorf("could not read SOCKS5 connect reply: %w", err)
+		}
+		skip = int(length[0])
+	default:
+		return fmt.Errorf("unknown SOCKS5 address type %d", header[3])
+	}
+
+	if _, err := io.CopyN(io.Discard, conn, int64(skip+2)); err != nil {
+		return fmt.Errorf("could not read SOCKS5 connect reply: %w", err)
+	}
+
+	return nil
+}
+
+func authenticateSOCKS5(conn net.Conn, user *url.Userinfo) error {
+	if user == nil {
+		return errors.New("proxy requires SOCKS5 authentication but no credentials were given")
+	}
+
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("SOCKS5 credentials must be at most 255 bytes long")
+	}
+
+	request := append([]byte{1, byte(len(username))}, username...)
+	request = append(append(request, byte(len(password))), password...)
+
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("could not send SOCKS5 credentials: %w", err)
+	}
+
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return fmt.Errorf("could not read SOCKS5 authentication reply: %w", err)
+	}
+	if reply[1] != 0 {
+		return errors.New("proxy rejected SOCKS5 credentials")
+	}
+
+	return nil
+}
+
+func socks5ReplyText(code byte) string {
+	switch code {
+	case 1:
+		return "general failure"
+	case 2:
+		return "connection not allowed by ruleset"
+	case 3:
+		return "network unreachable"
+	case 4:
+		return "host unreachable"
+	case 5:
+		return "connection refused"
+	case 6:
+		return "TTL expired"
+	case 7:
+		return "command not supported"
+	case 8:
+		return "address type not supported"
+	default:
+		return fmt.Sprintf("unknown error %d", code)
+	}
+}
+
+func lookupPort(service string) (uint64, error) {
+	port, err := net.LookupPort("tcp", service)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q: %w", service, err)
+	}
+
+	return uint64(port), nil
+}
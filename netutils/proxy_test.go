@@ -0,0 +1,122 @@
+package netutils
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// serveEcho accepts one connection on listener and echoes every frame back.
+func serveEcho(t *testing.T) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	return listener
+}
+
+// serveConnectProxy is a minimal HTTP CONNECT proxy that requires the given credentials.
+func serveConnectProxy(t *testing.T, username, password string) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		request, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+
+		check := &http.Request{Header: http.Header{"Authorization": request.Header["Proxy-Authorization"]}}
+		if user, pass, ok := check.BasicAuth(); !ok || user != username || pass != password {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return
+		}
+
+		target, err := net.Dial("tcp", request.Host)
+		if err != nil {
+			_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+			return
+		}
+		defer target.Close()
+
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+		go func() { _, _ = io.Copy(target, conn) }()
+		_, _ = io.Copy(conn, target)
+	}()
+
+	return listener
+}
+
+func TestDialTCPViaHTTPProxy(t *testing.T) {
+	echo := serveEcho(t)
+	defer echo.Close()
+
+	proxy := serveConnectProxy(t, "user", "secret")
+	defer proxy.Close()
+
+	host, port, _ := net.SplitHostPort(echo.Addr().String())
+
+	options := defaultProxyOptions()
+	options.Proxy = &url.URL{Scheme: "http", Host: proxy.Addr().String(), User: url.UserPassword("user", "secret")}
+
+	conn, err := DialTCPViaProxy[testMessage](host, port, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Send(testMessage{Text: "through the proxy"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var message testMessage
+	if _, err := conn.Receive(&message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Text != "through the proxy" {
+		t.Errorf("expected the message to be echoed, got %q", message.Text)
+	}
+}
+
+func TestExcludedByNoProxy(t *testing.T) {
+	t.Setenv("NO_PROXY", "example.com, 10.0.0.0/8,.internal")
+
+	for host, excluded := range map[string]bool{
+		"example.com":     true,
+		"api.example.com": true,
+		"example.org":     false,
+		"10.1.2.3":        true,
+		"11.1.2.3":        false,
+		"db.internal":     true,
+	} {
+		if got := excludedByNoProxy(host); got != excluded {
+			t.Errorf("excludedByNoProxy(%q) = %v, want %v", host, got, excluded)
+		}
+	}
+}
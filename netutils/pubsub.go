@@ -0,0 +1,399 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+)
+
+// Header keys used by the pub/sub protocol spoken between Broker and PubSubClient.
+const (
+	headerPubSubOperation = "pubsub-op"
+	headerPubSubTopic     = "pubsub-topic"
+)
+
+const (
+	pubSubSubscribe   = "subscribe"
+	pubSubUnsubscribe = "unsubscribe"
+	pubSubPublish     = "publish"
+	pubSubMessage     = "message"
+)
+
+// SlowConsumerPolicy decides what a Broker does when a subscriber's outgoing queue is
+// full.
+type SlowConsumerPolicy int
+
+const (
+	// SlowConsumerDrop drops the message for the slow subscriber only.
+	SlowConsumerDrop SlowConsumerPolicy = iota
+	// SlowConsumerDisconnect closes the connection of the slow subscriber.
+	SlowConsumerDisconnect
+	// SlowConsumerBlock waits until the slow subscriber has room in its queue, which
+	// slows down the publisher.
+	SlowConsumerBlock
+)
+
+func (scp SlowConsumerPolicy) String() string {
+	switch scp {
+	case SlowConsumerDrop:
+		return "drop"
+	case SlowConsumerDisconnect:
+		return "disconnect"
+	case SlowConsumerBlock:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// BrokerOptions is a struct used by NewBroker to define certain optional parameters.
+type BrokerOptions struct {
+	// QueueSize is the amount of messages that can be queued for each subscriber before
+	// the SlowConsumerPolicy applies.
+	QueueSize          int
+	SlowConsumerPolicy SlowConsumerPolicy
+
+	// OnTopicCreated is called when a topic gains its first subscriber, and
+	// OnTopicRemoved is called when a topic loses its last subscriber. Either may be nil.
+	OnTopicCreated func(topic string)
+	OnTopicRemoved func(topic string)
+
+	// ReadOptions are passed to every Receive on client connections.
+	ReadOptions ReadOptions
+}
+
+func defaultBrokerOptions() BrokerOptions {
+	return BrokerOptions{
+		QueueSize:          64,
+		SlowConsumerPolicy: SlowConsumerDrop,
+		ReadOptions:        defaultReadOptions(),
+	}
+}
+
+type subscriber[T Convertable] struct {
+	conn   TypedConnection[Envelope[T]]
+	queue  chan Envelope[T]
+	done   chan struct{}
+	once   sync.Once
+	topics map[string]struct{}
+}
+
+func (s *subscriber[T]) close() {
+	s.once.Do(func() {
+		close(s.done)
+		_ = s.conn.Close()
+	})
+}
+
+// Broker is a topic-based publish/subscribe hub for connected PubSubClients. Clients can
+// subscribe to and publish on any topic; the Broker fans each published message out to
+// every subscriber of its topic. Topics exist for as long as they have at least one
+// subscriber.
+type Broker[T Convertable] struct {
+	mu      sync.RWMutex
+	topics  map[string]map[*subscriber[T]]struct{}
+	options BrokerOptions
+}
+
+// NewBroker creates a new *Broker with no topics.
+//
+// This takes a variadic parameter of type BrokerOptions. If no BrokerOptions are
+// supplied, then the defaults are used. If more than one BrokerOptions are supplied then
+// only the first will be used.
+func NewBroker[T Convertable](opts ...BrokerOptions) *Broker[T] {
+	options := defaultBrokerOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.QueueSize < 0 {
+		options.QueueSize = 0
+	}
+
+	return &Broker[T]{
+		topics:  make(map[string]map[*subscriber[T]]struct{}),
+		options: options,
+	}
+}
+
+// Topics returns the sorted names of all topics that currently have subscribers.
+func (b *Broker[T]) Topics() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	topics := make([]string, 0, len(b.topics))
+	for topic := range b.topics {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+
+	return topics
+}
+
+// Subscribers returns the amount of subscribers of topic.
+func (b *Broker[T]) Subscribers(topic string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.topics[topic])
+}
+
+func (b *Broker[T]) subscribe(sub *subscriber[T], topic string) {
+	b.mu.Lock()
+	subscribers, ok := b.topics[topic]
+	if !ok {
+		subscribers = make(map[*subscriber[T]]struct{})
+		b.topics[topic] = subscribers
+	}
+	subscribers[sub] = struct{}{}
+	sub.topics[topic] = struct{}{}
+	b.mu.Unlock()
+
+	if !ok && b.options.OnTopicCreated != nil {
+		b.options.OnTopicCreated(topic)
+	}
+}
+
+func (b *Broker[T]) unsubscribe(sub *subscriber[T], topic string) {
+	b.mu.Lock()
+	removed := false
+	if subscribers, ok := b.topics[topic]; ok {
+		delete(subscribers, sub)
+		if len(subscribers) == 0 {
+			delete(b.topics, topic)
+			removed = true
+		}
+	}
+	delete(sub.topics, topic)
+	b.mu.Unlock()
+
+	if removed && b.options.OnTopicRemoved != nil {
+		b.options.OnTopicRemoved(topic)
+	}
+}
+
+// Publish sends value to every subscriber of topic, returning the amount of subscribers
+// the message was queued for.
+func (b *Broker[T]) Publish(topic string, value T) int {
+	envelope := NewEnvelope(value)
+	return b.publish(topic, envelope)
+}
+
+func (b *Broker[T]) publish(topic string, envelope Envelope[T]) int {
+	envelope.SetHeader(headerPubSubOperation, pubSubMessage)
+	envelope.SetHeader(headerPubSubTopic, topic)
+
+	b.mu.RLock()
+	subscribers := make([]*subscriber[T], 0, len(b.topics[topic]))
+	for sub := range b.topics[topic] {
+		subscribers = append(subscribers, sub)
+	}
+	b.mu.RUnlock()
+
+	delivered := 0
+	for _, sub := range subscribers {
+		if b.deliver(sub, envelope) {
+			delivered++
+		}
+	}
+
+	return delivered
+}
+
+func (b *Broker[T]) deliver(sub *subscriber[T], envelope Envelope[T]) bool {
+	if b.options.SlowConsumerPolicy == SlowConsumerBlock {
+		select {
+		case sub.queue <- envelope:
+			return true
+		case <-sub.done:
+			return false
+		}
+	}
+
+	select {
+	case sub.queue <- envelope:
+		return true
+	case <-sub.done:
+		return false
+	default:
+		if b.options.SlowConsumerPolicy == SlowConsumerDisconnect {
+			sub.close()
+		}
+
+		return false
+	}
+}
+
+// Serve accepts connections from listener and serves each one in its own goroutine until
+// ctx is done or the listener fails. The listener is closed when Serve returns.
+func (b *Broker[T]) Serve(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { _ = listener.Close() })
+	defer stop()
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		go func() { _ = b.ServeConn(ctx, conn) }()
+	}
+}
+
+// ServeConn handles the subscribe, unsubscribe, and publish requests of a single client
+// until ctx is done or the connection fails. The client is unsubscribed from all of its
+// topics and the connection is closed when ServeConn returns.
+func (b *Broker[T]) ServeConn(ctx context.Context, conn net.Conn) error {
+	sub := &subscriber[T]{
+		conn:   NewTypedConnection[Envelope[T]](conn, ConnectionTypeTCP),
+		queue:  make(chan Envelope[T], b.options.QueueSize),
+		done:   make(chan struct{}),
+		topics: make(map[string]struct{}),
+	}
+
+	stop := context.AfterFunc(ctx, sub.close)
+	defer stop()
+	defer func() {
+		sub.close()
+
+		b.mu.RLock()
+		topics := make([]string, 0, len(sub.topics))
+		for topic := range sub.topics {
+			topics = append(topics, topic)
+		}
+		b.mu.RUnlock()
+
+		for _, topic := range topics {
+			b.unsubscribe(sub, topic)
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case envelope := <-sub.queue:
+				if _, err := sub.conn.Send(envelope); err != nil {
+					sub.close()
+					return
+				}
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+
+	for {
+		var request Envelope[T]
+		if _, err := sub.conn.Receive(&request, WithReadOptions(b.options.ReadOptions)); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		topic := request.Header(headerPubSubTopic)
+		if topic == "" {
+			continue
+		}
+
+		switch request.Header(headerPubSubOperation) {
+		case pubSubSubscribe:
+			b.subscribe(sub, topic)
+		case pubSubUnsubscribe:
+			b.unsubscribe(sub, topic)
+		case pubSubPublish:
+			b.publish(topic, request)
+		}
+	}
+}
+
+// PubSubClient is a client of a Broker.
+type PubSubClient[T Convertable] struct {
+	conn        TypedConnection[Envelope[T]]
+	readOptions ReadOptions
+}
+
+// NewPubSubClient creates a new *PubSubClient that talks to a Broker over conn.
+func NewPubSubClient[T Convertable](conn net.Conn) *PubSubClient[T] {
+	return &PubSubClient[T]{
+		conn:        NewTypedConnection[Envelope[T]](conn, ConnectionTypeTCP),
+		readOptions: defaultReadOptions(),
+	}
+}
+
+// DialPubSub attempts to connect to a Broker listening at host:port, and creates a new
+// *PubSubClient on success. On failure, an error is returned.
+func DialPubSub[T Convertable](host, port string) (*PubSubClient[T], error) {
+	conn, err := dial("tcp", host, port, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPubSubClient[T](conn), nil
+}
+
+func (psc *PubSubClient[T]) request(operation, topic string, envelope Envelope[T]) error {
+	if topic == "" {
+		return errors.New("topic must not be empty")
+	}
+
+	envelope.SetHeader(headerPubSubOperation, operation)
+	envelope.SetHeader(headerPubSubTopic, topic)
+
+	if _, err := psc.conn.Send(envelope); err != nil {
+		return fmt.Errorf("could not send %s request: %w", operation, err)
+	}
+
+	return nil
+}
+
+// Subscribe asks the broker to start sending messages published on topic.
+func (psc *PubSubClient[T]) Subscribe(topic string) error {
+	return psc.request(pubSubSubscribe, topic, controlEnvelope[T]())
+}
+
+// Unsubscribe asks the broker to stop sending messages published on topic.
+func (psc *PubSubClient[T]) Unsubscribe(topic string) error {
+	return psc.request(pubSubUnsubscribe, topic, controlEnvelope[T]())
+}
+
+// controlEnvelope returns an envelope for a request that has no payload, so that the zero
+// value of T, which may be a nil pointer, is never marshalled.
+func controlEnvelope[T Convertable]() Envelope[T] {
+	return Envelope[T]{Headers: map[string]string{HeaderNoPayload: "true"}}
+}
+
+// Publish sends value to every subscriber of topic, which includes this client if it is
+// subscribed to topic.
+func (psc *PubSubClient[T]) Publish(topic string, value T) error {
+	return psc.request(pubSubPublish, topic, NewEnvelope(value))
+}
+
+// Receive blocks until a message is received on any subscribed topic, returning the
+// topic alongside the value. On failure, an error is returned.
+func (psc *PubSubClient[T]) Receive() (string, T, error) {
+	for {
+		var envelope Envelope[T]
+		if _, err := psc.conn.Receive(&envelope, WithReadOptions(psc.readOptions)); err != nil {
+			var zero T
+			return "", zero, err
+		}
+
+		if envelope.Header(headerPubSubOperation) == pubSubMessage {
+			return envelope.Header(headerPubSubTopic), envelope.Payload, nil
+		}
+	}
+}
+
+// Close closes the connection to the broker.
+func (psc *PubSubClient[T]) Close() error {
+	return psc.conn.Close()
+}
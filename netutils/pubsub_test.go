@@ -0,0 +1,151 @@
+package netutils
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// pointerMessage is a Convertable with pointer receivers, whose zero value, a nil
+// pointer, cannot be marshalled.
+type pointerMessage struct {
+	Text string
+}
+
+func (pm *pointerMessage) String() string { return pm.Text }
+
+func (pm *pointerMessage) Marshal() ([]byte, error) { return []byte(pm.Text), nil }
+
+func (pm *pointerMessage) Unmarshal(v any, data []byte) error {
+	*v.(**pointerMessage) = &pointerMessage{Text: string(data)}
+	return nil
+}
+
+func newTestPubSubClient[T Convertable](t *testing.T, broker *Broker[T]) *PubSubClient[T] {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	go func() { _ = broker.ServeConn(context.Background(), serverConn) }()
+
+	client := NewPubSubClient[T](clientConn)
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+// waitForSubscribers waits until topic has count subscribers, as subscribing is not
+// acknowledged by the broker.
+func waitForSubscribers[T Convertable](t *testing.T, broker *Broker[T], topic string, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for broker.Subscribers(topic) != count {
+		if time.Now().After(deadline) {
+			t.Fatalf("topic %q should have %d subscribers, got %d", topic, count, broker.Subscribers(topic))
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPubSubFanOut(t *testing.T) {
+	broker := NewBroker[testMessage]()
+
+	subscribers := []*PubSubClient[testMessage]{
+		newTestPubSubClient(t, broker),
+		newTestPubSubClient(t, broker),
+		newTestPubSubClient(t, broker),
+	}
+	for _, sub := range subscribers {
+		if err := sub.Subscribe("news"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForSubscribers(t, broker, "news", len(subscribers))
+
+	publisher := newTestPubSubClient(t, broker)
+	if err := publisher.Publish("news", testMessage{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, sub := range subscribers {
+		topic, message, err := sub.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if topic != "news" || message.Text != "hello" {
+			t.Errorf("subscriber %d should receive hello on news, got %q on %q", i, message.Text, topic)
+		}
+	}
+
+	if err := subscribers[0].Unsubscribe("news"); err != nil {
+		t.Fatal(err)
+	}
+	waitForSubscribers(t, broker, "news", len(subscribers)-1)
+
+	if delivered := broker.Publish("news", testMessage{Text: "again"}); delivered != len(subscribers)-1 {
+		t.Errorf("message should be delivered to %d subscribers, got %d", len(subscribers)-1, delivered)
+	}
+}
+
+func TestPubSubPointerPayload(t *testing.T) {
+	broker := NewBroker[*pointerMessage]()
+	client := newTestPubSubClient(t, broker)
+
+	if err := client.Subscribe("news"); err != nil {
+		t.Fatal(err)
+	}
+	waitForSubscribers(t, broker, "news", 1)
+
+	broker.Publish("news", &pointerMessage{Text: "hello"})
+	if _, message, err := client.Receive(); err != nil {
+		t.Fatal(err)
+	} else if message.Text != "hello" {
+		t.Errorf("message should be hello, got %q", message.Text)
+	}
+
+	if err := client.Unsubscribe("news"); err != nil {
+		t.Fatal(err)
+	}
+	waitForSubscribers(t, broker, "news", 0)
+}
+
+func TestPubSubSlowConsumer(t *testing.T) {
+	t.Run("drop", func(t *testing.T) {
+		broker := NewBroker[testMessage](BrokerOptions{QueueSize: 1, SlowConsumerPolicy: SlowConsumerDrop})
+		client := newTestPubSubClient(t, broker)
+
+		if err := client.Subscribe("news"); err != nil {
+			t.Fatal(err)
+		}
+		waitForSubscribers(t, broker, "news", 1)
+
+		// The client never reads, so at most one message is being sent and one is queued.
+		delivered := 0
+		for range 10 {
+			delivered += broker.Publish("news", testMessage{Text: "hello"})
+		}
+		if delivered > 2 {
+			t.Errorf("at most 2 messages should be delivered to a slow consumer, got %d", delivered)
+		}
+		if broker.Subscribers("news") != 1 {
+			t.Error("slow consumer should stay subscribed")
+		}
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		broker := NewBroker[testMessage](BrokerOptions{QueueSize: 1, SlowConsumerPolicy: SlowConsumerDisconnect})
+		client := newTestPubSubClient(t, broker)
+
+		if err := client.Subscribe("news"); err != nil {
+			t.Fatal(err)
+		}
+		waitForSubscribers(t, broker, "news", 1)
+
+		for range 10 {
+			broker.Publish("news", testMessage{Text: "hello"})
+		}
+		waitForSubscribers(t, broker, "news", 0)
+	})
+}
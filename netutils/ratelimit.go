@@ -0,0 +1,143 @@
+package netutils
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimit describes a limit on the rate of messages and bytes transferred over a
+// connection. Zero-valued rates are unlimited.
+type RateLimit struct {
+	MessagesPerSecond float64
+	BytesPerSecond    float64
+
+	// MessageBurst and ByteBurst are the amount of messages and bytes that may be
+	// transferred at once before the limit kicks in. If zero, one second's worth of the
+	// corresponding rate is used.
+	MessageBurst float64
+	ByteBurst    float64
+}
+
+// tokenBucket is a token bucket that allows itself to go into debt, so that a single
+// message larger than the burst still goes through, but delays those after it.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// reserve takes n tokens from the bucket, returning how long the caller must wait before
+// they are available.
+func (tb *tokenBucket) reserve(n float64) time.Duration {
+	if tb == nil {
+		return 0
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := time.Now()
+	tb.tokens = min(tb.burst, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+	tb.last = now
+	tb.tokens -= n
+
+	if tb.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+type rateLimiter struct {
+	messages *tokenBucket
+	bytes    *tokenBucket
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	limiter := &rateLimiter{
+		messages: newTokenBucket(limit.MessagesPerSecond, limit.MessageBurst),
+		bytes:    newTokenBucket(limit.BytesPerSecond, limit.ByteBurst),
+	}
+	if limiter.messages == nil && limiter.bytes == nil {
+		return nil
+	}
+
+	return limiter
+}
+
+// wait blocks until a single message of the given size is allowed through.
+func (rl *rateLimiter) wait(size int) {
+	if rl == nil {
+		return
+	}
+
+	delay := max(rl.messages.reserve(1), rl.bytes.reserve(float64(size)))
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// SetReadLimit limits the rate at which values are read from the connection by Read and
+// Receive. Once the limit is exceeded, reads are delayed, which in turn applies
+// backpressure to the peer. Passing a zero RateLimit removes the limit.
+func (tc *AsymmetricTypedConnection[S, R]) SetReadLimit(limit RateLimit) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	tc.state.readLimiter = newRateLimiter(limit)
+}
+
+// SetWriteLimit limits the rate at which values are written to the connection by Write
+// and Send. Once the limit is exceeded, writes block until they are allowed through.
+// Passing a zero RateLimit removes the limit.
+func (tc *AsymmetricTypedConnection[S, R]) SetWriteLimit(limit RateLimit) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	tc.state.writeLimiter = newRateLimiter(limit)
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) waitRead(size int) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	limiter := tc.state.readLimiter
+	tc.state.mu.Unlock()
+
+	limiter.wait(size)
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) waitWrite(size int) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	limiter := tc.state.writeLimiter
+	tc.state.mu.Unlock()
+
+	limiter.wait(size)
+}
@@ -0,0 +1,83 @@
+package netutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(10, 2)
+
+	// The burst goes through straight away.
+	for range 2 {
+		if delay := bucket.reserve(1); delay != 0 {
+			t.Fatalf("burst should not be delayed, got %s", delay)
+		}
+	}
+
+	// The bucket goes into debt, so each reservation waits for those before it.
+	if delay := bucket.reserve(1); delay < 50*time.Millisecond || delay > 100*time.Millisecond {
+		t.Errorf("expected a delay of about 100ms, got %s", delay)
+	}
+	if delay := bucket.reserve(1); delay < 150*time.Millisecond || delay > 200*time.Millisecond {
+		t.Errorf("expected a delay of about 200ms, got %s", delay)
+	}
+
+	if newTokenBucket(0, 10) != nil || newRateLimiter(RateLimit{}) != nil {
+		t.Error("zero rates should be unlimited")
+	}
+}
+
+// sendLimited sends count messages from client to server, and returns how long it took
+// for all of them to be received.
+func sendLimited(t *testing.T, client, server *TypedConnection[testMessage], count int) time.Duration {
+	t.Helper()
+
+	start := time.Now()
+	go func() {
+		for range count {
+			if _, err := client.Send(testMessage{Text: "hello"}); err != nil {
+				return
+			}
+		}
+	}()
+
+	for range count {
+		var message testMessage
+		if _, err := server.Receive(&message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return time.Since(start)
+}
+
+func TestSetWriteLimit(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer client.Close()
+	defer server.Close()
+
+	client.SetWriteLimit(RateLimit{MessagesPerSecond: 20, MessageBurst: 1})
+	if elapsed := sendLimited(t, client, server, 3); elapsed < 90*time.Millisecond {
+		t.Errorf("3 writes at 20 per second should take at least 100ms, took %s", elapsed)
+	}
+
+	// Removing the limit lets the messages through at once.
+	client.SetWriteLimit(RateLimit{})
+	if elapsed := sendLimited(t, client, server, 3); elapsed >= 90*time.Millisecond {
+		t.Errorf("unlimited writes should not be delayed, took %s", elapsed)
+	}
+}
+
+func TestSetReadLimit(t *testing.T) {
+	client, server := Pipe[testMessage]()
+	defer client.Close()
+	defer server.Close()
+
+	// Each message is around 20 bytes, so reading three of them at 1000 bytes per second
+	// takes around 50ms.
+	server.SetReadLimit(RateLimit{BytesPerSecond: 1000, ByteBurst: 1})
+	if elapsed := sendLimited(t, client, server, 3); elapsed < 20*time.Millisecond {
+		t.Errorf("reads should be delayed by the byte rate, took %s", elapsed)
+	}
+}
@@ -0,0 +1,195 @@
+package netutils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReadOption sets a single field of a ReadOptions, validating its value. See
+// NewReadOptions.
+type ReadOption func(options *ReadOptions) error
+
+// WithBufferSize sets the size of the buffer that Read accumulates a value into. size
+// must be greater than zero.
+func WithBufferSize(size int) ReadOption {
+	return func(options *ReadOptions) error {
+		if size <= 0 {
+			return fmt.Errorf("buffer size must be greater than zero, got %d", size)
+		}
+
+		options.BufferSize = size
+
+		return nil
+	}
+}
+
+// WithChunkSize sets the amount of bytes that Read asks the connection for at a time.
+// size must be greater than zero.
+func WithChunkSize(size int) ReadOption {
+	return func(options *ReadOptions) error {
+		if size <= 0 {
+			return fmt.Errorf("chunk size must be greater than zero, got %d", size)
+		}
+
+		options.ChunkSize = size
+
+		return nil
+	}
+}
+
+// WithMaxSize sets the largest frame that Receive will accept. size must be greater than
+// zero.
+func WithMaxSize(size int) ReadOption {
+	return func(options *ReadOptions) error {
+		if size <= 0 {
+			return fmt.Errorf("maximum frame size must be greater than zero, got %d", size)
+		}
+
+		options.MaxFrameSize = size
+
+		return nil
+	}
+}
+
+// WithTimeout bounds each Read and Receive by timeout. timeout must be greater than zero.
+func WithTimeout(timeout time.Duration) ReadOption {
+	return func(options *ReadOptions) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be greater than zero, got %s", timeout)
+		}
+
+		options.Timeout = timeout
+
+		return nil
+	}
+}
+
+// NewReadOptions returns the default ReadOptions with opts applied in order. If any of
+// opts are given an invalid value, the errors for all of them are returned.
+func NewReadOptions(opts ...ReadOption) (ReadOptions, error) {
+	return applyReadOptions(defaultReadOptions(), opts)
+}
+
+// applyReadOptions returns options with opts applied in order. If any of opts are given an
+// invalid value, the errors for all of them are returned.
+func applyReadOptions(options ReadOptions, opts []ReadOption) (ReadOptions, error) {
+	var errs []error
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return ReadOptions{}, errors.Join(errs...)
+	}
+
+	return options, nil
+}
+
+// WithReadOptions sets every field of a ReadOptions to the matching field of options
+// that is not zero, which allows options that are held as a struct, such as those of a
+// TypedServer, to be passed to a read. No field of options may be negative.
+func WithReadOptions(options ReadOptions) ReadOption {
+	return func(ro *ReadOptions) error {
+		var opts []ReadOption
+		if options.BufferSize != 0 {
+			opts = append(opts, WithBufferSize(options.BufferSize))
+		}
+		if options.ChunkSize != 0 {
+			opts = append(opts, WithChunkSize(options.ChunkSize))
+		}
+		if options.MaxFrameSize != 0 {
+			opts = append(opts, WithMaxSize(options.MaxFrameSize))
+		}
+		if options.Timeout != 0 {
+			opts = append(opts, WithTimeout(options.Timeout))
+		}
+
+		applied, err := applyReadOptions(*ro, opts)
+		if err != nil {
+			return err
+		}
+
+		*ro = applied
+
+		return nil
+	}
+}
+
+// Validate returns an error if any size in ro is not greater than zero, or if its
+// timeout is negative. A zero timeout is valid, and leaves reads unbounded.
+func (ro ReadOptions) Validate() error {
+	var errs []error
+	if ro.BufferSize <= 0 {
+		errs = append(errs, fmt.Errorf("buffer size must be greater than zero, got %d", ro.BufferSize))
+	}
+	if ro.ChunkSize <= 0 {
+		errs = append(errs, fmt.Errorf("chunk size must be greater than zero, got %d", ro.ChunkSize))
+	}
+	if ro.MaxFrameSize <= 0 {
+		errs = append(errs, fmt.Errorf("maximum frame size must be greater than zero, got %d", ro.MaxFrameSize))
+	}
+	if ro.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout must not be negative, got %s", ro.Timeout))
+	}
+
+	return errors.Join(errs...)
+}
+
+// withDefaults returns ro with its zero fields set to their default values.
+func (ro ReadOptions) withDefaults() ReadOptions {
+	defaults := defaultReadOptions()
+	if ro.BufferSize == 0 {
+		ro.BufferSize = defaults.BufferSize
+	}
+	if ro.ChunkSize == 0 {
+		ro.ChunkSize = defaults.ChunkSize
+	}
+	if ro.MaxFrameSize == 0 {
+		ro.MaxFrameSize = defaults.MaxFrameSize
+	}
+
+	return ro
+}
+
+// SetReadOptions sets the ReadOptions used by reads on the connection that are not given
+// any, so that they can be set once rather than on every call. If options is invalid, an
+// error is returned and the defaults are left unchanged. See ReadOptions.Validate, and
+// NewReadOptions for building options from the defaults.
+func (tc *AsymmetricTypedConnection[S, R]) SetReadOptions(options ReadOptions) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	if tc.state == nil {
+		return errors.New("connection has no state to hold its read options")
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	tc.state.readOptions = &options
+
+	return nil
+}
+
+// DefaultReadOptions returns the ReadOptions used by reads on the connection that are not
+// given any.
+func (tc *AsymmetricTypedConnection[S, R]) DefaultReadOptions() ReadOptions {
+	if tc.state != nil {
+		tc.state.mu.Lock()
+		defer tc.state.mu.Unlock()
+
+		if tc.state.readOptions != nil {
+			return *tc.state.readOptions
+		}
+	}
+
+	return defaultReadOptions()
+}
+
+// readOptions returns the defaults of the connection with opts applied in order.
+func (tc *AsymmetricTypedConnection[S, R]) readOptions(opts []ReadOption) (ReadOptions, error) {
+	return applyReadOptions(tc.DefaultReadOptions(), opts)
+}
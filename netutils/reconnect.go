@@ -0,0 +1,409 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDisconnected is returned from ReconnectingConnection.Send while the connection
+	// is down and the WritePolicy is WritePolicyFail.
+	ErrDisconnected = errors.New("connection is disconnected")
+
+	// ErrWriteBufferFull is returned from ReconnectingConnection.Send while the
+	// connection is down and no more writes can be buffered.
+	ErrWriteBufferFull = errors.New("write buffer is full")
+)
+
+// ConnectionState describes the state of a ReconnectingConnection.
+type ConnectionState int
+
+const (
+	ConnectionStateConnected ConnectionState = iota
+	ConnectionStateDisconnected
+	ConnectionStateConnecting
+	ConnectionStateClosed
+)
+
+func (cs ConnectionState) String() string {
+	switch cs {
+	case ConnectionStateConnected:
+		return "connected"
+	case ConnectionStateDisconnected:
+		return "disconnected"
+	case ConnectionStateConnecting:
+		return "connecting"
+	case ConnectionStateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// WritePolicy decides what a ReconnectingConnection does with writes while it is
+// disconnected.
+type WritePolicy int
+
+const (
+	// WritePolicyBuffer buffers writes until the connection has been re-established, at
+	// which point they are written in order.
+	WritePolicyBuffer WritePolicy = iota
+	// WritePolicyFail fails writes with ErrDisconnected.
+	WritePolicyFail
+)
+
+// ReconnectOptions is a struct used by NewReconnectingConnection to define certain
+// optional parameters.
+type ReconnectOptions struct {
+	// Backoff spaces out the dials. If it is the zero Backoff, the default is used.
+	Backoff Backoff
+
+	// MaxAttempts is the amount of consecutive failed dials after which the connection
+	// gives up and closes itself. A zero or negative MaxAttempts retries forever.
+	MaxAttempts int
+
+	WritePolicy WritePolicy
+	// BufferSize is the amount of writes that are buffered while disconnected when
+	// using WritePolicyBuffer. If it is zero or negative, 256 is used.
+	BufferSize int
+
+	// OnStateChange, if not nil, is called every time the state of the connection
+	// changes. err holds the error that caused the change, if any. It is called without
+	// any locks held, so it may use the connection, but it should not block for long.
+	OnStateChange func(from, to ConnectionState, err error)
+
+	// ReadOptions are passed to every Receive on the underlying connection.
+	ReadOptions ReadOptions
+
+	// Retry, if not nil, makes Send retry writes that fail with a transient error, such
+	// as ErrDisconnected when using WritePolicyFail, which allows a write to ride out a
+	// short reconnect window instead of failing straight away.
+	Retry *RetryPolicy
+}
+
+func defaultReconnectOptions() ReconnectOptions {
+	return ReconnectOptions{
+		Backoff:     defaultBackoff(),
+		WritePolicy: WritePolicyBuffer,
+		BufferSize:  256,
+		ReadOptions: defaultReadOptions(),
+	}
+}
+
+// withDefaults fills in the fields of ro that have been left at their zero value, so that
+// a partially filled in ReconnectOptions does not redial in a hot loop or fail every
+// buffered write.
+func (ro ReconnectOptions) withDefaults() ReconnectOptions {
+	defaults := defaultReconnectOptions()
+	if ro.Backoff == (Backoff{}) {
+		ro.Backoff = defaults.Backoff
+	}
+	if ro.BufferSize <= 0 {
+		ro.BufferSize = defaults.BufferSize
+	}
+	ro.ReadOptions = ro.ReadOptions.withDefaults()
+
+	return ro
+}
+
+// ReconnectingConnection is a wrapper over a TypedConnection that transparently re-dials
+// whenever the underlying connection fails. Values are sent and received as frames using
+// Send and Receive.
+//
+// A ReconnectingConnection is safe for concurrent use.
+type ReconnectingConnection[T Convertable] struct {
+	dial    func() (net.Conn, error)
+	options ReconnectOptions
+
+	mu      sync.Mutex
+	conn    *TypedConnection[T]
+	state   ConnectionState
+	err     error
+	pending [][]byte
+	notify  chan struct{}
+}
+
+// NewReconnectingConnection creates a new *ReconnectingConnection which uses dial to open
+// connections. The first connection is dialled immediately, and its error is returned
+// if it fails.
+//
+// This takes a variadic parameter of type ReconnectOptions. If no ReconnectOptions are
+// supplied, then the defaults are used. If more than one ReconnectOptions are supplied
+// then only the first will be used.
+func NewReconnectingConnection[T Convertable](dial func() (net.Conn, error), opts ...ReconnectOptions) (*ReconnectingConnection[T], error) {
+	options := defaultReconnectOptions()
+	if opts != nil {
+		options = opts[0].withDefaults()
+	}
+
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	tc := NewTypedConnection[T](conn, ConnectionTypeTCP)
+
+	return &ReconnectingConnection[T]{
+		dial:    dial,
+		options: options,
+		conn:    &tc,
+		state:   ConnectionStateConnected,
+		notify:  make(chan struct{}),
+	}, nil
+}
+
+// DialTCPReconnecting attempts to connect to a given TCP socket at host:port, and creates
+// a new *ReconnectingConnection that re-dials the same address whenever the connection
+// fails. See NewReconnectingConnection.
+func DialTCPReconnecting[T Convertable](host, port string, opts ...ReconnectOptions) (*ReconnectingConnection[T], error) {
+	return NewReconnectingConnection[T](func() (net.Conn, error) {
+		return dial("tcp", host, port, nil)
+	}, opts...)
+}
+
+// setState changes the state and wakes up anyone waiting on it. rc.mu must be held. The
+// returned function calls OnStateChange, and must be called once rc.mu has been released.
+func (rc *ReconnectingConnection[T]) setState(state ConnectionState, err error) func() {
+	from := rc.state
+	rc.state = state
+
+	close(rc.notify)
+	rc.notify = make(chan struct{})
+
+	if from == state || rc.options.OnStateChange == nil {
+		return func() {}
+	}
+
+	return func() { rc.options.OnStateChange(from, state, err) }
+}
+
+// State returns the current state of the connection.
+func (rc *ReconnectingConnection[T]) State() ConnectionState {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return rc.state
+}
+
+// disconnected marks conn as failed and starts reconnecting, unless this has already
+// happened for conn.
+func (rc *ReconnectingConnection[T]) disconnected(conn *TypedConnection[T], err error) {
+	rc.mu.Lock()
+	if rc.conn != conn || rc.state != ConnectionStateConnected {
+		rc.mu.Unlock()
+		return
+	}
+
+	_ = conn.Close()
+	rc.conn = nil
+	changed := rc.setState(ConnectionStateDisconnected, err)
+	rc.mu.Unlock()
+
+	changed()
+	go rc.reconnect()
+}
+
+func (rc *ReconnectingConnection[T]) reconnect() {
+	for attempt := 0; ; attempt++ {
+		rc.mu.Lock()
+		if rc.state == ConnectionStateClosed {
+			rc.mu.Unlock()
+			return
+		}
+		changed := rc.setState(ConnectionStateConnecting, nil)
+		rc.mu.Unlock()
+		changed()
+
+		conn, err := rc.dial()
+		if err == nil {
+			tc := NewTypedConnection[T](conn, ConnectionTypeTCP)
+			if err = rc.flush(&tc); err == nil {
+				return
+			}
+		}
+
+		rc.mu.Lock()
+		if rc.state == ConnectionStateClosed {
+			rc.mu.Unlock()
+			return
+		}
+		if rc.options.MaxAttempts > 0 && attempt+1 >= rc.options.MaxAttempts {
+			rc.err = errors.Join(errors.New("gave up reconnecting"), err)
+			rc.pending = nil
+			changed := rc.setState(ConnectionStateClosed, rc.err)
+			rc.mu.Unlock()
+			changed()
+
+			return
+		}
+		changed = rc.setState(ConnectionStateDisconnected, err)
+		notify := rc.notify
+		rc.mu.Unlock()
+		changed()
+
+		timer := time.NewTimer(rc.options.Backoff.Delay(attempt))
+		select {
+		case <-timer.C:
+		case <-notify:
+			timer.Stop()
+		}
+	}
+}
+
+// flush writes all buffered frames to the new connection before marking it as
+// connected, so that buffered writes keep their order.
+func (rc *ReconnectingConnection[T]) flush(tc *TypedConnection[T]) error {
+	for {
+		rc.mu.Lock()
+		if rc.state == ConnectionStateClosed {
+			rc.mu.Unlock()
+			_ = tc.Close()
+
+			return net.ErrClosed
+		}
+
+		pending := rc.pending
+		rc.pending = nil
+
+		if len(pending) == 0 {
+			rc.conn = tc
+			changed := rc.setState(ConnectionStateConnected, nil)
+			rc.mu.Unlock()
+			changed()
+
+			return nil
+		}
+		rc.mu.Unlock()
+
+		for i, frame := range pending {
+			if _, err := tc.writeFrame(frame); err != nil {
+				_ = tc.Close()
+
+				rc.mu.Lock()
+				rc.pending = append(pending[i:], rc.pending...)
+				rc.mu.Unlock()
+
+				return err
+			}
+		}
+	}
+}
+
+// Send writes value to the connection as a single frame. If the connection is down, the
+// write is either buffered or failed according to the WritePolicy, and then retried
+// according to the Retry policy, if any.
+func (rc *ReconnectingConnection[T]) Send(value T) error {
+	frame, err := marshalFrame(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = retry(context.Background(), rc.options.Retry, func() (struct{}, error) {
+		return struct{}{}, rc.send(frame)
+	})
+
+	return err
+}
+
+func (rc *ReconnectingConnection[T]) send(frame []byte) error {
+	for {
+		rc.mu.Lock()
+		switch rc.state {
+		case ConnectionStateClosed:
+			err := rc.err
+			rc.mu.Unlock()
+
+			return errors.Join(net.ErrClosed, err)
+		case ConnectionStateConnected:
+			conn := rc.conn
+			rc.mu.Unlock()
+
+			if _, err := conn.writeFrame(frame); err != nil {
+				rc.disconnected(conn, err)
+				continue
+			}
+
+			return nil
+		default:
+			defer rc.mu.Unlock()
+
+			if rc.options.WritePolicy == WritePolicyFail {
+				return ErrDisconnected
+			}
+			if len(rc.pending) >= rc.options.BufferSize {
+				return ErrWriteBufferFull
+			}
+
+			rc.pending = append(rc.pending, frame)
+
+			return nil
+		}
+	}
+}
+
+// Receive blocks until a value is received, waiting for the connection to be
+// re-established if it is currently down. Errors from unmarshalling a received value are
+// returned without dropping the connection. On success, the data pointer is populated
+// with the received value. On failure, an error is returned and the data pointer is
+// left untouched.
+func (rc *ReconnectingConnection[T]) Receive(data *T) error {
+	for {
+		rc.mu.Lock()
+		switch rc.state {
+		case ConnectionStateClosed:
+			err := rc.err
+			rc.mu.Unlock()
+
+			return errors.Join(net.ErrClosed, err)
+		case ConnectionStateConnected:
+			conn := rc.conn
+			rc.mu.Unlock()
+
+			payload, err := conn.receiveFrame(rc.options.ReadOptions.maxFrameSize(), nil)
+			if err != nil {
+				rc.disconnected(conn, err)
+				continue
+			}
+
+			var value T
+			if err := value.Unmarshal(&value, payload); err != nil {
+				return errors.Join(ErrUnmarshal, err)
+			}
+
+			*data = value
+
+			return nil
+		default:
+			notify := rc.notify
+			rc.mu.Unlock()
+
+			<-notify
+		}
+	}
+}
+
+// Close closes the connection and stops any reconnection attempts. Buffered writes are
+// dropped.
+func (rc *ReconnectingConnection[T]) Close() error {
+	rc.mu.Lock()
+	if rc.state == ConnectionStateClosed {
+		rc.mu.Unlock()
+		return nil
+	}
+
+	var err error
+	if rc.conn != nil {
+		err = rc.conn.Close()
+		rc.conn = nil
+	}
+	rc.pending = nil
+	changed := rc.setState(ConnectionStateClosed, nil)
+	rc.mu.Unlock()
+
+	changed()
+
+	return err
+}
@@ -0,0 +1,187 @@
+package netutils
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testReconnectDialer dials net.Pipe connections for a ReconnectingConnection, and hands
+// out the server side of each one.
+type testReconnectDialer struct {
+	failing atomic.Bool
+	servers chan *TypedConnection[testMessage]
+}
+
+func newTestReconnectDialer(t *testing.T) *testReconnectDialer {
+	t.Helper()
+
+	dialer := &testReconnectDialer{servers: make(chan *TypedConnection[testMessage], 16)}
+	t.Cleanup(func() {
+		close(dialer.servers)
+		for server := range dialer.servers {
+			_ = server.Close()
+		}
+	})
+
+	return dialer
+}
+
+func (d *testReconnectDialer) dial() (net.Conn, error) {
+	if d.failing.Load() {
+		return nil, errors.New("dial failed")
+	}
+
+	client, server := net.Pipe()
+	tc := NewTypedConnection[testMessage](server, ConnectionTypeTCP)
+	d.servers <- &tc
+
+	return client, nil
+}
+
+func TestReconnectingConnection(t *testing.T) {
+	dialer := newTestReconnectDialer(t)
+
+	var rc *ReconnectingConnection[testMessage]
+	states := make(chan ConnectionState, 16)
+
+	options := defaultReconnectOptions()
+	options.Backoff = Backoff{Initial: time.Millisecond}
+	options.OnStateChange = func(_, to ConnectionState, _ error) {
+		// The callback must be able to use the connection without deadlocking.
+		_ = rc.State()
+		states <- to
+	}
+
+	rc, err := NewReconnectingConnection[testMessage](dialer.dial, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	server := <-dialer.servers
+	go func() { _ = rc.Send(testMessage{Text: "hello"}) }()
+
+	var message testMessage
+	if _, err := server.Receive(&message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Text != "hello" {
+		t.Errorf("expected hello, got %q", message.Text)
+	}
+
+	// Receive notices the broken connection and waits for it to be re-dialled.
+	_ = server.Close()
+	received := make(chan error, 1)
+	go func() { received <- rc.Receive(&message) }()
+
+	server = <-dialer.servers
+	if _, err := server.Send(testMessage{Text: "again"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-received; err != nil {
+		t.Fatal(err)
+	}
+	if message.Text != "again" {
+		t.Errorf("expected again, got %q", message.Text)
+	}
+
+	for _, expected := range []ConnectionState{
+		ConnectionStateDisconnected,
+		ConnectionStateConnecting,
+		ConnectionStateConnected,
+	} {
+		if state := <-states; state != expected {
+			t.Errorf("expected the state to change to %v, got %v", expected, state)
+		}
+	}
+}
+
+func TestReconnectingConnectionBuffersWrites(t *testing.T) {
+	dialer := newTestReconnectDialer(t)
+
+	options := defaultReconnectOptions()
+	options.Backoff = Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond}
+
+	rc, err := NewReconnectingConnection[testMessage](dialer.dial, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	dialer.failing.Store(true)
+	_ = (<-dialer.servers).Close()
+
+	// The failed write marks the connection as down, and is then buffered.
+	if err := rc.Send(testMessage{Text: "buffered"}); err != nil {
+		t.Fatal(err)
+	}
+	if state := rc.State(); state == ConnectionStateConnected {
+		t.Errorf("connection should be down, got %v", state)
+	}
+
+	dialer.failing.Store(false)
+
+	var message testMessage
+	if _, err := (<-dialer.servers).Receive(&message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Text != "buffered" {
+		t.Errorf("expected the buffered write to be flushed, got %q", message.Text)
+	}
+}
+
+func TestReconnectingConnectionGivesUp(t *testing.T) {
+	dialer := newTestReconnectDialer(t)
+
+	closed := make(chan error, 1)
+	options := defaultReconnectOptions()
+	options.Backoff = Backoff{Initial: time.Millisecond}
+	options.MaxAttempts = 3
+	options.OnStateChange = func(_, to ConnectionState, err error) {
+		if to == ConnectionStateClosed {
+			closed <- err
+		}
+	}
+
+	rc, err := NewReconnectingConnection[testMessage](dialer.dial, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	dialer.failing.Store(true)
+	_ = (<-dialer.servers).Close()
+
+	var message testMessage
+	if err := rc.Receive(&message); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("receiving after giving up should fail with net.ErrClosed, got %v", err)
+	}
+	if err := <-closed; err == nil {
+		t.Error("giving up should report the dial error")
+	}
+	if err := rc.Send(testMessage{Text: "hello"}); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("sending after giving up should fail with net.ErrClosed, got %v", err)
+	}
+}
+
+func TestReconnectOptionsDefaults(t *testing.T) {
+	// A partially filled in ReconnectOptions keeps its own fields, and defaults the rest.
+	options := ReconnectOptions{MaxAttempts: 5, WritePolicy: WritePolicyFail}.withDefaults()
+
+	defaults := defaultReconnectOptions()
+	if options.Backoff != defaults.Backoff {
+		t.Errorf("zero backoff should default to %v, got %v", defaults.Backoff, options.Backoff)
+	}
+	if options.BufferSize != defaults.BufferSize {
+		t.Errorf("zero buffer size should default to %d, got %d", defaults.BufferSize, options.BufferSize)
+	}
+	if options.ReadOptions.MaxFrameSize != defaults.ReadOptions.MaxFrameSize {
+		t.Errorf("zero read options should be defaulted, got %+v", options.ReadOptions)
+	}
+	if options.MaxAttempts != 5 || options.WritePolicy != WritePolicyFail {
+		t.Errorf("set fields should be kept, got %+v", options)
+	}
+}
@@ -0,0 +1,294 @@
+package netutils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// FrameDirection is whether a recorded frame was read from or written to a connection.
+type FrameDirection byte
+
+const (
+	// FrameInbound frames were read from the connection, i.e. sent by the peer.
+	FrameInbound FrameDirection = iota + 1
+	// FrameOutbound frames were written to the connection.
+	FrameOutbound
+)
+
+func (fd FrameDirection) String() string {
+	switch fd {
+	case FrameInbound:
+		return "inbound"
+	case FrameOutbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
+
+// recordingMagic starts every recording, so that other files are rejected early.
+const recordingMagic = "NUREC001"
+
+// ErrNotARecording is returned when reading a recording that does not start with the
+// header written by RecordingConn.
+var ErrNotARecording = errors.New("data is not a netutils recording")
+
+// RecordedFrame is a single frame of a recording.
+type RecordedFrame struct {
+	Time      time.Time
+	Direction FrameDirection
+	// Control is whether the frame is a control frame, such as a heartbeat.
+	Control bool
+	Payload []byte
+}
+
+// RecordingConn is a net.Conn that records every frame read from and written to it,
+// alongside when and in which direction it was seen. The recording can later be fed back
+// into a handler with NewReplayConn, which is useful for reproducing protocol bugs. It is
+// used by wrapping a connection before creating a typed connection over it:
+//
+//	conn := netutils.NewTypedConnection[T](netutils.NewRecordingConn(raw, file), netutils.ConnectionTypeTCP)
+//
+// Only length-prefixed frames, as written by Send and read by Receive, are recorded.
+type RecordingConn struct {
+	net.Conn
+
+	mu       sync.Mutex
+	w        io.Writer
+	err      error
+	inbound  []byte
+	outbound []byte
+}
+
+// NewRecordingConn creates a new *RecordingConn that records the frames on conn to w. w
+// is not closed when the connection is closed.
+func NewRecordingConn(conn net.Conn, w io.Writer) *RecordingConn {
+	rc := &RecordingConn{Conn: conn, w: w}
+	_, rc.err = io.WriteString(w, recordingMagic)
+
+	return rc
+}
+
+// Read reads from the underlying connection, recording every frame that is completed by
+// the data read.
+func (rc *RecordingConn) Read(p []byte) (int, error) {
+	n, err := rc.Conn.Read(p)
+	if n > 0 {
+		rc.record(FrameInbound, p[:n])
+	}
+
+	return n, err
+}
+
+// Write writes to the underlying connection, recording every frame that is completed by
+// the data written.
+func (rc *RecordingConn) Write(p []byte) (int, error) {
+	n, err := rc.Conn.Write(p)
+	if n > 0 {
+		rc.record(FrameOutbound, p[:n])
+	}
+
+	return n, err
+}
+
+// Err returns the first error that occurred while writing the recording, if any. Once
+// an error has occurred, nothing more is recorded.
+func (rc *RecordingConn) Err() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return rc.err
+}
+
+func (rc *RecordingConn) record(direction FrameDirection, data []byte) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	pending := &rc.inbound
+	if direction == FrameOutbound {
+		pending = &rc.outbound
+	}
+	*pending = append(*pending, data...)
+
+	for {
+		frame, _, ok := nextFrame(*pending)
+		if !ok {
+			break
+		}
+		*pending = (*pending)[len(frame):]
+
+		if rc.err != nil {
+			continue
+		}
+
+		var header [9]byte
+		header[0] = byte(direction)
+		binary.BigEndian.PutUint64(header[1:], uint64(time.Now().UnixNano()))
+
+		if _, rc.err = rc.w.Write(header[:]); rc.err == nil {
+			_, rc.err = rc.w.Write(frame)
+		}
+	}
+
+	*pending = append([]byte(nil), *pending...)
+}
+
+// ReadRecording reads every frame of a recording written by RecordingConn.
+func ReadRecording(r io.Reader) ([]RecordedFrame, error) {
+	reader := bufio.NewReader(r)
+
+	magic := make([]byte, len(recordingMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != recordingMagic {
+		return nil, ErrNotARecording
+	}
+
+	var frames []RecordedFrame
+	for {
+		var header [9]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return frames, nil
+			}
+
+			return frames, fmt.Errorf("truncated recording: %w", err)
+		}
+
+		payload, control, err := readFrame(reader, math.MaxInt32, nil)
+		if err != nil {
+			return frames, fmt.Errorf("truncated recording: %w", err)
+		}
+
+		frames = append(frames, RecordedFrame{
+			Time:      time.Unix(0, int64(binary.BigEndian.Uint64(header[1:]))),
+			Direction: FrameDirection(header[0]),
+			Control:   control,
+			Payload:   payload,
+		})
+	}
+}
+
+// ReplayOptions is a struct used by NewReplayConn to define certain optional parameters.
+type ReplayOptions struct {
+	// Direction is which frames of the recording are replayed. By default, the inbound
+	// frames are replayed, so that a handler sees exactly what it was sent.
+	Direction FrameDirection
+	// Timing reproduces the gaps between the frames as they were recorded, instead of
+	// replaying them as fast as they are read.
+	Timing bool
+}
+
+func defaultReplayOptions() ReplayOptions {
+	return ReplayOptions{Direction: FrameInbound}
+}
+
+type replayAddr struct{}
+
+func (replayAddr) Network() string { return "replay" }
+func (replayAddr) String() string  { return "replay" }
+
+// ReplayConn is a net.Conn that replays the frames of a recording, as read by
+// ReadRecording, when it is read from. Anything written to it is discarded. Once every
+// frame has been read, Read returns io.EOF. Deadlines are accepted but ignored.
+type ReplayConn struct {
+	options ReplayOptions
+
+	mu       sync.Mutex
+	frames   []RecordedFrame
+	buffer   bytes.Reader
+	previous time.Time
+	closed   bool
+}
+
+// NewReplayConn creates a new *ReplayConn which replays frames.
+//
+// This takes a variadic parameter of type ReplayOptions. If no ReplayOptions are
+// supplied, then the defaults are used. If more than one ReplayOptions are supplied then
+// only the first will be used.
+func NewReplayConn(frames []RecordedFrame, opts ...ReplayOptions) *ReplayConn {
+	options := defaultReplayOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	selected := make([]RecordedFrame, 0, len(frames))
+	for _, frame := range frames {
+		if frame.Direction == options.Direction {
+			selected = append(selected, frame)
+		}
+	}
+
+	return &ReplayConn{options: options, frames: selected}
+}
+
+// Read reads the next replayed frames into p.
+func (rc *ReplayConn) Read(p []byte) (int, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.closed {
+		return 0, net.ErrClosed
+	}
+
+	if rc.buffer.Len() == 0 {
+		if len(rc.frames) == 0 {
+			return 0, io.EOF
+		}
+
+		frame := rc.frames[0]
+		rc.frames = rc.frames[1:]
+
+		if rc.options.Timing && !rc.previous.IsZero() {
+			time.Sleep(frame.Time.Sub(rc.previous))
+		}
+		rc.previous = frame.Time
+
+		var data []byte
+		if frame.Control {
+			data = appendControlFrame(nil, frame.Payload)
+		} else {
+			var err error
+			if data, err = appendFrame(nil, frame.Payload); err != nil {
+				return 0, err
+			}
+		}
+		rc.buffer.Reset(data)
+	}
+
+	return rc.buffer.Read(p)
+}
+
+// Write discards p.
+func (rc *ReplayConn) Write(p []byte) (int, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.closed {
+		return 0, net.ErrClosed
+	}
+
+	return len(p), nil
+}
+
+// Close closes the connection, causing all further reads and writes to fail.
+func (rc *ReplayConn) Close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.closed = true
+
+	return nil
+}
+
+func (*ReplayConn) LocalAddr() net.Addr              { return replayAddr{} }
+func (*ReplayConn) RemoteAddr() net.Addr             { return replayAddr{} }
+func (*ReplayConn) SetDeadline(time.Time) error      { return nil }
+func (*ReplayConn) SetReadDeadline(time.Time) error  { return nil }
+func (*ReplayConn) SetWriteDeadline(time.Time) error { return nil }
@@ -0,0 +1,58 @@
+package netutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	var recording bytes.Buffer
+
+	a, b := net.Pipe()
+	recorder := NewRecordingConn(b, &recording)
+	client := NewTypedConnection[testMessage](a, ConnectionTypeTCP)
+	server := NewTypedConnection[testMessage](recorder, ConnectionTypeTCP)
+
+	go func() {
+		_, _ = client.Send(testMessage{Text: "one"})
+		_, _ = client.Send(testMessage{Text: "two"})
+		_ = client.Close()
+	}()
+
+	for {
+		var message testMessage
+		if _, err := server.Receive(&message); err != nil {
+			break
+		}
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := ReadRecording(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 recorded frames, got %d", len(frames))
+	}
+
+	replay := NewTypedConnection[testMessage](NewReplayConn(frames), ConnectionTypeTCP)
+	for _, want := range []string{"one", "two"} {
+		var message testMessage
+		if _, err := replay.Receive(&message); err != nil {
+			t.Fatal(err)
+		}
+		if message.Text != want {
+			t.Errorf("expected %q, got %q", want, message.Text)
+		}
+	}
+
+	var message testMessage
+	if _, err := replay.Receive(&message); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF at the end of the replay, got %v", err)
+	}
+}
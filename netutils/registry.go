@@ -0,0 +1,213 @@
+package netutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+)
+
+var (
+	// ErrUnregisteredType is returned when encoding a value whose type has not been
+	// registered with the MessageRegistry in use.
+	ErrUnregisteredType = errors.New("type has not been registered")
+
+	// ErrUnknownMessageTag is returned when decoding a message whose type tag has not been
+	// registered with the MessageRegistry in use.
+	ErrUnknownMessageTag = errors.New("unknown message type tag")
+)
+
+type registeredMessage struct {
+	typ    reflect.Type
+	decode func(data []byte) (Convertable, error)
+}
+
+// MessageRegistry maps type tags to Convertable types, which allows for a single
+// connection to carry values of several different types. Each encoded message is prefixed
+// with the tag of its type, which is then used on the receiving side to pick the correct
+// type to unmarshal into.
+//
+// A MessageRegistry is safe for concurrent use.
+type MessageRegistry struct {
+	mu     sync.RWMutex
+	byTag  map[string]registeredMessage
+	byType map[reflect.Type]string
+}
+
+// NewMessageRegistry creates a new, empty *MessageRegistry.
+func NewMessageRegistry() *MessageRegistry {
+	return &MessageRegistry{
+		byTag:  make(map[string]registeredMessage),
+		byType: make(map[reflect.Type]string),
+	}
+}
+
+// DefaultMessageRegistry is the registry used by Message and RegisterMessage.
+var DefaultMessageRegistry = NewMessageRegistry()
+
+// Register registers T with the given registry under tag. Both the tag and the type must
+// be unique within the registry, otherwise an error is returned.
+func Register[T Convertable](registry *MessageRegistry, tag string) error {
+	if tag == "" {
+		return errors.New("tag must not be empty")
+	}
+	if len(tag) > math.MaxUint16 {
+		return fmt.Errorf("tag must be at most %d bytes long", math.MaxUint16)
+	}
+
+	typ := reflect.TypeFor[T]()
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.byTag[tag]; ok {
+		return fmt.Errorf("tag %q has already been registered", tag)
+	}
+	if existing, ok := registry.byType[typ]; ok {
+		return fmt.Errorf("%s has already been registered with tag %q", typ, existing)
+	}
+
+	registry.byTag[tag] = registeredMessage{
+		typ: typ,
+		decode: func(data []byte) (Convertable, error) {
+			var value T
+			if err := value.Unmarshal(&value, data); err != nil {
+				return nil, err
+			}
+
+			return value, nil
+		},
+	}
+	registry.byType[typ] = tag
+
+	return nil
+}
+
+// RegisterMessage registers T with DefaultMessageRegistry under tag. See Register.
+func RegisterMessage[T Convertable](tag string) error {
+	return Register[T](DefaultMessageRegistry, tag)
+}
+
+// Tag returns the tag that the type of value was registered with, if any.
+func (mr *MessageRegistry) Tag(value Convertable) (string, bool) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	tag, ok := mr.byType[reflect.TypeOf(value)]
+	return tag, ok
+}
+
+// Encode marshals value and prefixes it with the tag of its type. On failure, an error is
+// returned, which will wrap ErrUnregisteredType if the type of value is unknown.
+func (mr *MessageRegistry) Encode(value Convertable) ([]byte, error) {
+	if value == nil {
+		return nil, errors.New("cannot encode a nil value")
+	}
+
+	tag, ok := mr.Tag(value)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnregisteredType, reflect.TypeOf(value))
+	}
+
+	payload, err := value.Marshal()
+	if err != nil {
+		return nil, errors.Join(errors.New("could not marshal message"), err)
+	}
+
+	buffer := make([]byte, 2, 2+len(tag)+len(payload))
+	binary.BigEndian.PutUint16(buffer, uint16(len(tag)))
+	buffer = append(buffer, tag...)
+	buffer = append(buffer, payload...)
+
+	return buffer, nil
+}
+
+// Decode reads the tag from data and unmarshals the remainder into the type registered
+// under that tag. On success, the decoded value and its concrete type are returned. On
+// failure, an error is returned, which will wrap ErrUnknownMessageTag if the tag is
+// unknown.
+func (mr *MessageRegistry) Decode(data []byte) (Convertable, reflect.Type, error) {
+	if len(data) < 2 {
+		return nil, nil, errors.New("message is too short to contain a type tag")
+	}
+
+	tagLength := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+tagLength {
+		return nil, nil, errors.New("message is too short to contain its type tag")
+	}
+
+	tag := string(data[2 : 2+tagLength])
+
+	mr.mu.RLock()
+	registered, ok := mr.byTag[tag]
+	mr.mu.RUnlock()
+
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnknownMessageTag, tag)
+	}
+
+	value, err := registered.decode(data[2+tagLength:])
+	if err != nil {
+		return nil, nil, errors.Join(fmt.Errorf("could not unmarshal message into %s", registered.typ), err)
+	}
+
+	return value, registered.typ, nil
+}
+
+// Message is a Convertable that can hold a value of any type registered with
+// DefaultMessageRegistry, which lets a TypedConnection[Message] carry heterogeneous
+// messages. After a successful Read, Value holds the decoded value and Type reports its
+// concrete type.
+type Message struct {
+	Value Convertable
+}
+
+// Ensure that Message implements Convertable correctly.
+var _ Convertable = Message{}
+
+// Type returns the concrete type of the held value, or nil if there is no value.
+func (m Message) Type() reflect.Type {
+	if m.Value == nil {
+		return nil
+	}
+
+	return reflect.TypeOf(m.Value)
+}
+
+// Marshal encodes the held value using DefaultMessageRegistry.
+func (m Message) Marshal() ([]byte, error) {
+	return DefaultMessageRegistry.Encode(m.Value)
+}
+
+// Unmarshal decodes data using DefaultMessageRegistry into v, which must be a *Message.
+func (m Message) Unmarshal(v any, data []byte) error {
+	message, ok := v.(*Message)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal a Message into %T", v)
+	}
+
+	value, _, err := DefaultMessageRegistry.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	message.Value = value
+
+	return nil
+}
+
+func (m Message) String() string {
+	if m.Value == nil {
+		return "Message(<nil>)"
+	}
+
+	return fmt.Sprintf("Message(%s)", m.Value.String())
+}
+
+// MessageAs returns the value held by message as a T, if it is one.
+func MessageAs[T Convertable](message Message) (T, bool) {
+	value, ok := message.Value.(T)
+	return value, ok
+}
@@ -0,0 +1,56 @@
+package netutils
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type testMessage struct {
+	Text string `json:"text"`
+}
+
+func (tm testMessage) String() string                     { return tm.Text }
+func (tm testMessage) Marshal() ([]byte, error)           { return json.Marshal(tm) }
+func (tm testMessage) Unmarshal(v any, data []byte) error { return json.Unmarshal(data, v) }
+
+type otherTestMessage struct {
+	Number int `json:"number"`
+}
+
+func (otm otherTestMessage) String() string                     { return "other" }
+func (otm otherTestMessage) Marshal() ([]byte, error)           { return json.Marshal(otm) }
+func (otm otherTestMessage) Unmarshal(v any, data []byte) error { return json.Unmarshal(data, v) }
+
+func TestMessageRegistryRoundTrip(t *testing.T) {
+	registry := NewMessageRegistry()
+	if err := Register[testMessage](registry, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register[otherTestMessage](registry, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register[otherTestMessage](registry, "duplicate"); err == nil {
+		t.Error("registering the same type twice should fail")
+	}
+
+	data, err := registry.Encode(otherTestMessage{Number: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, typ, err := registry.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ.Name() != "otherTestMessage" {
+		t.Errorf("decoded type should be otherTestMessage, got %s", typ)
+	}
+	if value.(otherTestMessage).Number != 42 {
+		t.Errorf("decoded value should be 42, got %v", value)
+	}
+
+	if _, err := NewMessageRegistry().Encode(testMessage{}); !errors.Is(err, ErrUnregisteredType) {
+		t.Errorf("encoding an unregistered type should return ErrUnregisteredType, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Receiver is implemented by every typed connection in this package that receives values
+// of type R as frames, such as *TypedConnection[R] and *TCPTypedConnection[R].
+type Receiver[R Convertable] interface {
+	Receive(data *R, opts ...ReadOption) (int, error)
+	Close() error
+}
+
+// RelayFunc is called by RelayTransform for every value received from the source. It
+// returns the value to send to the destination, and whether it should be sent at all,
+// so that it can be used to filter as well as transform. If it returns an error, the
+// relay stops with that error.
+type RelayFunc[In, Out Convertable] func(value In) (Out, bool, error)
+
+// RelayOptions is a struct used by Relay, RelayTransform, and RelayDuplex to define
+// certain optional parameters.
+type RelayOptions struct {
+	// ReadOptions are passed to every Receive on the source.
+	ReadOptions ReadOptions
+}
+
+func defaultRelayOptions() RelayOptions {
+	return RelayOptions{ReadOptions: defaultReadOptions()}
+}
+
+// Relay receives values from src and sends them to dst until src is closed by its peer,
+// an error occurs, or ctx is done, returning the amount of values sent. If filter is not
+// nil, only the values for which it returns true are sent. This is useful for building
+// proxies, gateways, and bots that sit between two peers.
+//
+// A clean close of src by its peer is not reported as an error. If ctx is done, src is
+// closed to interrupt the pending read and ctx.Err() is returned. Otherwise, neither
+// connection is closed by Relay.
+//
+// This takes a variadic parameter of type RelayOptions. If no RelayOptions are supplied,
+// then the defaults are used. If more than one RelayOptions are supplied then only the
+// first will be used.
+func Relay[T Convertable](ctx context.Context, src Receiver[T], dst Sender[T], filter func(value T) bool, opts ...RelayOptions) (int, error) {
+	return RelayTransform(ctx, src, dst, func(value T) (T, bool, error) {
+		return value, filter == nil || filter(value), nil
+	}, opts...)
+}
+
+// RelayTransform is like Relay, but passes each value received from src through
+// transform before it is sent to dst, which allows the two connections to carry
+// different types. See RelayFunc.
+//
+// This takes a variadic parameter of type RelayOptions. If no RelayOptions are supplied,
+// then the defaults are used. If more than one RelayOptions are supplied then only the
+// first will be used.
+func RelayTransform[In, Out Convertable](ctx context.Context, src Receiver[In], dst Sender[Out], transform RelayFunc[In, Out], opts ...RelayOptions) (int, error) {
+	options := defaultRelayOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = src.Close() })
+	defer stop()
+
+	sent := 0
+	for {
+		var value In
+		if _, err := src.Receive(&value, WithReadOptions(options.ReadOptions)); err != nil {
+			if ctx.Err() != nil {
+				return sent, ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return sent, nil
+			}
+
+			return sent, err
+		}
+
+		out, ok, err := transform(value)
+		if err != nil {
+			return sent, err
+		}
+		if !ok {
+			continue
+		}
+
+		if _, err := dst.Send(out); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+}
+
+// RelayDuplex relays values in both directions between a and b, as with Relay, until
+// either direction stops. Both connections are then closed, and the first error hit by
+// either direction is returned. The filters may be nil, in which case every value is
+// relayed in that direction.
+//
+// This takes a variadic parameter of type RelayOptions. If no RelayOptions are supplied,
+// then the defaults are used. If more than one RelayOptions are supplied then only the
+// first will be used.
+func RelayDuplex[T Convertable](ctx context.Context, a, b Duplex[T, T], filterAB, filterBA func(value T) bool, opts ...RelayOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		err  error
+	)
+
+	relay := func(src, dst Duplex[T, T], filter func(value T) bool) {
+		defer wg.Done()
+
+		_, relayErr := Relay(ctx, src, dst, filter, opts...)
+		once.Do(func() {
+			err = relayErr
+			_ = a.Close()
+			_ = b.Close()
+		})
+	}
+
+	wg.Add(2)
+	go relay(a, b, filterAB)
+	go relay(b, a, filterBA)
+	wg.Wait()
+
+	return err
+}
@@ -0,0 +1,71 @@
+package netutils
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func newTestPipe[S, R Convertable]() (*AsymmetricTypedConnection[S, R], *AsymmetricTypedConnection[R, S]) {
+	a, b := net.Pipe()
+	left := NewAsymmetricTypedConnection[S, R](a, ConnectionTypeTCP)
+	right := NewAsymmetricTypedConnection[R, S](b, ConnectionTypeTCP)
+
+	return &left, &right
+}
+
+func TestRelayTransform(t *testing.T) {
+	client, relayIn := newTestPipe[testMessage, testMessage]()
+	relayOut, server := newTestPipe[otherTestMessage, otherTestMessage]()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := RelayTransform(context.Background(), relayIn, relayOut, func(value testMessage) (otherTestMessage, bool, error) {
+			if strings.HasPrefix(value.Text, "skip") {
+				return otherTestMessage{}, false, nil
+			}
+
+			return otherTestMessage{Number: len(value.Text)}, true, nil
+		})
+		done <- err
+	}()
+
+	go func() {
+		for _, text := range []string{"a", "skip me", "abc"} {
+			_, _ = client.Send(testMessage{Text: text})
+		}
+		_ = client.Close()
+	}()
+
+	for _, want := range []int{1, 3} {
+		var got otherTestMessage
+		if _, err := server.Receive(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Number != want {
+			t.Fatalf("received %d, want %d", got.Number, want)
+		}
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("relay returned %v after a clean close", err)
+	}
+}
+
+func TestRelayCancel(t *testing.T) {
+	_, relayIn := newTestPipe[testMessage, testMessage]()
+	relayOut, _ := newTestPipe[testMessage, testMessage]()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := Relay(ctx, relayIn, relayOut, nil)
+		done <- err
+	}()
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("relay returned %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,165 @@
+package netutils
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver looks up the addresses of host names for the Dial functions. It is satisfied
+// by *net.Resolver, *CachingResolver, and ResolverFunc. See DialOptions.Resolver.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// ResolverFunc adapts a function into a Resolver, which is useful to override resolution
+// in tests without a DNS server.
+type ResolverFunc func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+// LookupIPAddr calls rf.
+func (rf ResolverFunc) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return rf(ctx, host)
+}
+
+// CachingResolverOptions is a struct used by NewCachingResolver to define certain optional
+// parameters.
+type CachingResolverOptions struct {
+	// Resolver performs the lookups that are cached. If nil, net.DefaultResolver is used.
+	Resolver Resolver
+
+	// TTL is how long successful lookups are cached for. The records' own TTLs are not
+	// available from the net package, so the same TTL is used for every host.
+	TTL time.Duration
+
+	// NegativeTTL is how long failed lookups are cached for. If zero, failures are not
+	// cached.
+	NegativeTTL time.Duration
+}
+
+func defaultCachingResolverOptions() CachingResolverOptions {
+	return CachingResolverOptions{
+		Resolver: net.DefaultResolver,
+		TTL:      time.Minute,
+	}
+}
+
+type resolverEntry struct {
+	ready   chan struct{}
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// CachingResolver is a Resolver that caches the lookups of another, so that repeated dials
+// to the same host do not each wait on DNS. Concurrent lookups of a host that is not
+// cached share one lookup. A CachingResolver is safe for concurrent use, and is meant to
+// be shared between dials through DialOptions.Resolver.
+type CachingResolver struct {
+	options CachingResolverOptions
+
+	mu      sync.Mutex
+	entries map[string]*resolverEntry
+}
+
+// NewCachingResolver creates a new, empty *CachingResolver.
+//
+// This takes a variadic parameter of type CachingResolverOptions. If no
+// CachingResolverOptions are supplied, then the defaults are used. If more than one
+// CachingResolverOptions are supplied then only the first will be used.
+func NewCachingResolver(opts ...CachingResolverOptions) *CachingResolver {
+	options := defaultCachingResolverOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Resolver == nil {
+		options.Resolver = net.DefaultResolver
+	}
+
+	return &CachingResolver{
+		options: options,
+		entries: make(map[string]*resolverEntry),
+	}
+}
+
+// LookupIPAddr returns the addresses of host, from the cache if they have not expired.
+func (cr *CachingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	key := strings.ToLower(strings.TrimSuffix(host, "."))
+
+	cr.mu.Lock()
+	if entry, ok := cr.entries[key]; ok {
+		select {
+		case <-entry.ready:
+			if time.Now().Before(entry.expires) {
+				cr.mu.Unlock()
+				return slices.Clone(entry.addrs), entry.err
+			}
+		default:
+			// Another caller is looking the host up, so share its result.
+			cr.mu.Unlock()
+
+			select {
+			case <-entry.ready:
+				return slices.Clone(entry.addrs), entry.err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	entry := &resolverEntry{ready: make(chan struct{})}
+	cr.entries[key] = entry
+	cr.mu.Unlock()
+
+	// The lookup is shared, so it must not be cut short by the context of whichever
+	// caller happened to start it.
+	go cr.lookup(context.WithoutCancel(ctx), key, host, entry)
+
+	select {
+	case <-entry.ready:
+		return slices.Clone(entry.addrs), entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (cr *CachingResolver) lookup(ctx context.Context, key, host string, entry *resolverEntry) {
+	addrs, err := cr.options.Resolver.LookupIPAddr(ctx, host)
+
+	ttl := cr.options.TTL
+	if err != nil {
+		ttl = cr.options.NegativeTTL
+	}
+
+	entry.addrs, entry.err = addrs, err
+	entry.expires = time.Now().Add(ttl)
+	close(entry.ready)
+
+	if ttl <= 0 {
+		cr.mu.Lock()
+		if cr.entries[key] == entry {
+			delete(cr.entries, key)
+		}
+		cr.mu.Unlock()
+	}
+}
+
+// Forget removes host from the cache, so that it is looked up again by the next dial.
+func (cr *CachingResolver) Forget(host string) {
+	key := strings.ToLower(strings.TrimSuffix(host, "."))
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	delete(cr.entries, key)
+}
+
+// Flush removes every host from the cache.
+func (cr *CachingResolver) Flush() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	clear(cr.entries)
+}
@@ -0,0 +1,69 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachingResolverDial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	var lookups atomic.Int32
+	resolver := NewCachingResolver(CachingResolverOptions{
+		Resolver: ResolverFunc(func(_ context.Context, host string) ([]net.IPAddr, error) {
+			lookups.Add(1)
+			if !strings.EqualFold(strings.TrimSuffix(host, "."), "service.test") {
+				return nil, errors.New("no such host")
+			}
+
+			// The first address refuses connections, so the second must be tried.
+			return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 2)}, {IP: net.IPv4(127, 0, 0, 1)}}, nil
+		}),
+		TTL: time.Minute,
+	})
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	for range 3 {
+		conn, err := DialTCP[testMessage]("service.test", port, DialOptions{Resolver: resolver})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Fatalf("resolved %d times, want 1", n)
+	}
+
+	if _, err := DialTCP[testMessage]("unknown.test", port, DialOptions{Resolver: resolver}); err == nil {
+		t.Fatal("expected dialing an unknown host to fail")
+	}
+
+	resolver.Forget("service.test")
+	conn, err := DialTCP[testMessage]("SERVICE.test.", port, DialOptions{Resolver: resolver, ConnectionAttemptDelay: DefaultConnectionAttemptDelay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if n := lookups.Load(); n != 3 {
+		t.Fatalf("resolved %d times, want 3", n)
+	}
+}
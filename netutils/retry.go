@@ -0,0 +1,125 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// RetryPolicy describes how an operation that failed with a transient error is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum amount of times the operation is attempted, including
+	// the first attempt. A value of 1 or less disables retries.
+	MaxAttempts int
+	// Backoff spaces out the attempts.
+	Backoff Backoff
+	// Retryable classifies the errors that are worth retrying. If nil, IsTransientError
+	// is used.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy that makes up to 3 attempts using the default
+// Backoff and IsTransientError.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     defaultBackoff(),
+	}
+}
+
+// IsTransientError is the default error classifier used by RetryPolicy. It reports
+// whether err is likely to go away on its own, such as a connection being reset or
+// refused while a peer restarts, a timeout, or a ReconnectingConnection that is
+// currently disconnected.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrDisconnected) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+
+	for _, errno := range transientErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (rp RetryPolicy) retryable(err error) bool {
+	if rp.Retryable != nil {
+		return rp.Retryable(err)
+	}
+
+	return IsTransientError(err)
+}
+
+// retry calls operation until it succeeds, fails with an error that is not retryable,
+// the policy runs out of attempts, or ctx is done. A nil policy calls operation once.
+func retry[T any](ctx context.Context, policy *RetryPolicy, operation func() (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := operation()
+		if err == nil || policy == nil || attempt+1 >= policy.MaxAttempts || !policy.retryable(err) {
+			return result, err
+		}
+
+		timer := time.NewTimer(policy.Backoff.Delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, errors.Join(err, ctx.Err())
+		}
+	}
+}
+
+// SetRetryPolicy makes Write and Send retry writes that fail with a transient error
+// according to policy. Only writes that failed before any of the value was written are
+// retried, as retrying a partial write would corrupt the stream. Passing nil, the
+// default, disables retries.
+//
+// Retries are mostly useful on connections that can recover by themselves, such as
+// those wrapping a net.Conn that re-dials underneath, or for datagram connections.
+func (tc *AsymmetricTypedConnection[S, R]) SetRetryPolicy(policy *RetryPolicy) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	tc.state.retry = policy
+}
+
+// retryWrite runs write according to the retry policy of the connection.
+func (tc *AsymmetricTypedConnection[S, R]) retryWrite(write func() (int, error)) (int, error) {
+	var policy *RetryPolicy
+	if tc.state != nil {
+		tc.state.mu.Lock()
+		policy = tc.state.retry
+		tc.state.mu.Unlock()
+	}
+
+	if policy == nil {
+		return write()
+	}
+
+	var written int
+
+	return retry(context.Background(), &RetryPolicy{
+		MaxAttempts: policy.MaxAttempts,
+		Backoff:     policy.Backoff,
+		Retryable:   func(err error) bool { return written == 0 && policy.retryable(err) },
+	}, func() (int, error) {
+		n, err := write()
+		written = n
+
+		return n, err
+	})
+}
@@ -0,0 +1,8 @@
+//go:build !plan9
+
+package netutils
+
+import "syscall"
+
+// transientErrnos are the system errors that IsTransientError treats as transient.
+var transientErrnos = []error{syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.ECONNABORTED, syscall.EPIPE}
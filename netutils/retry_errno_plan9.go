@@ -0,0 +1,7 @@
+//go:build plan9
+
+package netutils
+
+// transientErrnos are the system errors that IsTransientError treats as transient. Plan 9
+// reports network errors as strings, so there are none.
+var transientErrnos []error
@@ -0,0 +1,45 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// ErrReusePortUnsupported is returned by the reuse-port listener constructors on
+// platforms that do not support SO_REUSEPORT.
+var ErrReusePortUnsupported = errors.New("SO_REUSEPORT is not supported on this platform")
+
+// NewReusePortTCPSocketListener creates a new *TCPSocketListener bound to host:port with
+// SO_REUSEPORT set, which allows multiple processes to listen on the same port and have
+// the operating system balance incoming connections between them. On platforms without
+// SO_REUSEPORT, an error wrapping ErrReusePortUnsupported is returned.
+func NewReusePortTCPSocketListener[T Convertable](host, port string) (*TCPSocketListener[T], error) {
+	config := net.ListenConfig{Control: reusePortControl}
+
+	listener, err := config.Listen(context.Background(), "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTypedTCPSocketListener[T](listener.(*net.TCPListener)), nil
+}
+
+// NewReusePortUDPSocketListener creates a new *UDPSocketListener bound to the given port
+// with SO_REUSEPORT set. Like NewTypedUDPSocketListener, this assumes that the host will be
+// local, and assigns to 0.0.0.0. On platforms without SO_REUSEPORT, an error wrapping
+// ErrReusePortUnsupported is returned.
+func NewReusePortUDPSocketListener[T Convertable](port string) (*UDPSocketListener[T], error) {
+	config := net.ListenConfig{Control: reusePortControl}
+
+	conn, err := config.ListenPacket(context.Background(), "udp", net.JoinHostPort("0.0.0.0", port))
+	if err != nil {
+		return nil, err
+	}
+
+	return &UDPSocketListener[T]{
+			connection:       NewUDPTypedConnection[T](conn.(*net.UDPConn)),
+			startedListening: true,
+		},
+		nil
+}
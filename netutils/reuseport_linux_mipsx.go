@@ -0,0 +1,6 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le)
+
+package netutils
+
+// The syscall package does not define SO_REUSEPORT for Linux.
+const soReusePort = 0x200
@@ -0,0 +1,53 @@
+package netutils
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestReusePortTCP(t *testing.T) {
+	first, err := NewReusePortTCPSocketListener[testMessage]("127.0.0.1", "0")
+	if errors.Is(err, ErrReusePortUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	// A second listener can bind to the same port while the first is still open.
+	port := strconv.Itoa(first.Addr().(*net.TCPAddr).Port)
+	second, err := NewReusePortTCPSocketListener[testMessage]("127.0.0.1", port)
+	if err != nil {
+		t.Fatalf("binding a second listener to port %s should succeed, got %v", port, err)
+	}
+	defer second.Close()
+}
+
+func TestReusePortUDP(t *testing.T) {
+	first, err := NewReusePortUDPSocketListener[testMessage]("0")
+	if errors.Is(err, ErrReusePortUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := first.Conn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	port := strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+	second, err := NewReusePortUDPSocketListener[testMessage](port)
+	if err != nil {
+		t.Fatalf("binding a second listener to port %s should succeed, got %v", port, err)
+	}
+	other, err := second.Conn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+}
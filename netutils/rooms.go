@@ -0,0 +1,159 @@
+package netutils
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Join adds the connection registered under id to room, creating the room if it does not
+// exist yet. A connection can be a member of any amount of rooms, and leaves all of them
+// when it is closed or removed from the manager.
+func (cm *ConnectionManager[T]) Join(id, room string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if _, ok := cm.conns[id]; !ok {
+		return fmt.Errorf("no connection with id %q", id)
+	}
+
+	members, ok := cm.rooms[room]
+	if !ok {
+		members = make(map[string]struct{})
+		cm.rooms[room] = members
+	}
+	members[id] = struct{}{}
+
+	return nil
+}
+
+// Leave removes the connection registered under id from room. Rooms without any members
+// are deleted.
+func (cm *ConnectionManager[T]) Leave(id, room string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.leave(id, room)
+}
+
+func (cm *ConnectionManager[T]) leave(id, room string) {
+	members, ok := cm.rooms[room]
+	if !ok {
+		return
+	}
+
+	delete(members, id)
+	if len(members) == 0 {
+		delete(cm.rooms, room)
+	}
+}
+
+// leaveAll removes id from every room. cm.mu must be held for writing.
+func (cm *ConnectionManager[T]) leaveAll(id string) {
+	for room := range cm.rooms {
+		cm.leave(id, room)
+	}
+}
+
+// Rooms returns the sorted names of all rooms with at least one member.
+func (cm *ConnectionManager[T]) Rooms() []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	rooms := make([]string, 0, len(cm.rooms))
+	for room := range cm.rooms {
+		rooms = append(rooms, room)
+	}
+	slices.Sort(rooms)
+
+	return rooms
+}
+
+// Members returns the sorted IDs of all connections in room.
+func (cm *ConnectionManager[T]) Members(room string) []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	members := make([]string, 0, len(cm.rooms[room]))
+	for id := range cm.rooms[room] {
+		members = append(members, id)
+	}
+	slices.Sort(members)
+
+	return members
+}
+
+// RoomsOf returns the sorted names of all rooms that the connection registered under id
+// is a member of.
+func (cm *ConnectionManager[T]) RoomsOf(id string) []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	var rooms []string
+	for room, members := range cm.rooms {
+		if _, ok := members[id]; ok {
+			rooms = append(rooms, room)
+		}
+	}
+	slices.Sort(rooms)
+
+	return rooms
+}
+
+// SendToRoom writes value to every connection in room. See SendToRoomExcept.
+func (cm *ConnectionManager[T]) SendToRoom(room string, value T) (int, error) {
+	return cm.sendToRoom(room, value, nil)
+}
+
+// SendToRoomExcept writes value to every connection in room apart from the one
+// registered under id, which is useful for relaying a message from one member to the
+// rest of the room. The value is marshalled only once, and connections are written to
+// concurrently, so one slow member does not hold up the others. Connections that fail to
+// be written to are closed and removed from the manager. It returns the amount of
+// connections that were successfully written to; an error is only returned if value
+// could not be marshalled.
+func (cm *ConnectionManager[T]) SendToRoomExcept(room, id string, value T) (int, error) {
+	return cm.sendToRoom(room, value, &id)
+}
+
+func (cm *ConnectionManager[T]) sendToRoom(room string, value T, except *string) (int, error) {
+	frame, err := marshalFrame(value)
+	if err != nil {
+		return 0, err
+	}
+
+	cm.mu.RLock()
+	targets := make([]*TCPTypedConnection[T], 0, len(cm.rooms[room]))
+	for id := range cm.rooms[room] {
+		if except == nil || id != *except {
+			targets = append(targets, cm.conns[id])
+		}
+	}
+	cm.mu.RUnlock()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		delivered int
+	)
+
+	for _, conn := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := conn.writeFrame(frame); err != nil {
+				_ = conn.Close()
+				return
+			}
+
+			mu.Lock()
+			delivered++
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return delivered, nil
+}
@@ -0,0 +1,162 @@
+package netutils
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRooms(t *testing.T) {
+	cm := NewConnectionManager[testMessage]()
+
+	for _, id := range []string{"alice", "bob"} {
+		conn, _ := newTestManagerConn(t)
+		if err := cm.Add(id, conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cm.Join("carol", "lobby"); err == nil {
+		t.Error("joining with an unknown id should fail")
+	}
+	for _, join := range [][2]string{{"alice", "lobby"}, {"bob", "lobby"}, {"alice", "game"}} {
+		if err := cm.Join(join[0], join[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rooms := cm.Rooms(); !slices.Equal(rooms, []string{"game", "lobby"}) {
+		t.Errorf("rooms should be [game lobby], got %v", rooms)
+	}
+	if members := cm.Members("lobby"); !slices.Equal(members, []string{"alice", "bob"}) {
+		t.Errorf("lobby should have [alice bob], got %v", members)
+	}
+	if rooms := cm.RoomsOf("alice"); !slices.Equal(rooms, []string{"game", "lobby"}) {
+		t.Errorf("alice should be in [game lobby], got %v", rooms)
+	}
+
+	// Rooms are deleted once their last member leaves.
+	cm.Leave("alice", "game")
+	if rooms := cm.Rooms(); !slices.Equal(rooms, []string{"lobby"}) {
+		t.Errorf("empty room should be deleted, got %v", rooms)
+	}
+
+	// Removed connections leave all of their rooms.
+	cm.Remove("bob")
+	if members := cm.Members("lobby"); !slices.Equal(members, []string{"alice"}) {
+		t.Errorf("removed connection should leave its rooms, got %v", members)
+	}
+}
+
+func TestSendToRoom(t *testing.T) {
+	cm := NewConnectionManager[testMessage]()
+
+	clients := make(map[string]*TCPTypedConnection[testMessage])
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		conn, client := newTestManagerConn(t)
+		clients[id] = client
+		if err := cm.Add(id, conn); err != nil {
+			t.Fatal(err)
+		}
+		if id != "dave" {
+			if err := cm.Join(id, "lobby"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	received := make(chan string, len(clients))
+	for _, id := range []string{"bob", "carol"} {
+		go func() {
+			var message testMessage
+			if _, err := clients[id].Receive(&message); err == nil {
+				received <- id + ":" + message.Text
+			}
+		}()
+	}
+
+	delivered, err := cm.SendToRoomExcept("lobby", "alice", testMessage{Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 2 {
+		t.Errorf("expected 2 deliveries, got %d", delivered)
+	}
+
+	got := []string{<-received, <-received}
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"bob:hello", "carol:hello"}) {
+		t.Errorf("only the rest of the room should receive the message, got %v", got)
+	}
+
+	// Members that fail to be written to are closed and removed.
+	_ = clients["bob"].Close()
+	_ = clients["carol"].Close()
+	go func() {
+		var message testMessage
+		_, _ = clients["alice"].Receive(&message)
+	}()
+
+	delivered, err = cm.SendToRoom("lobby", testMessage{Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 1 {
+		t.Errorf("expected 1 delivery, got %d", delivered)
+	}
+	if members := cm.Members("lobby"); !slices.Equal(members, []string{"alice"}) {
+		t.Errorf("failed members should be removed, got %v", members)
+	}
+}
+
+func TestSendToRoomConcurrent(t *testing.T) {
+	cm := NewConnectionManager[testMessage]()
+
+	ids := []string{"alice", "bob", "carol", "dave", "erin", "frank"}
+	clients := make([]*TCPTypedConnection[testMessage], 0, len(ids))
+	for _, id := range ids {
+		conn, client := newTestManagerConn(t)
+		clients = append(clients, client)
+		if err := cm.Add(id, conn); err != nil {
+			t.Fatal(err)
+		}
+		if err := cm.Join(id, "lobby"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each member only reads once the one before it has received the message, so sending
+	// to the members one after the other would block forever unless it happened to be in
+	// that order every time.
+	for range 10 {
+		previous := make(chan struct{})
+		close(previous)
+		for _, client := range clients {
+			wait, received := previous, make(chan struct{})
+			go func() {
+				<-wait
+
+				var message testMessage
+				if _, err := client.Receive(&message); err == nil {
+					close(received)
+				}
+			}()
+			previous = received
+		}
+
+		sent := make(chan int, 1)
+		go func() {
+			delivered, _ := cm.SendToRoom("lobby", testMessage{Text: "hello"})
+			sent <- delivered
+		}()
+
+		select {
+		case delivered := <-sent:
+			if delivered != len(ids) {
+				t.Fatalf("expected %d deliveries, got %d", len(ids), delivered)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("a member that had not read yet held up the rest of the room")
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Header keys used by the RPC layer on top of Envelope.
+const (
+	HeaderCorrelationID = "correlation-id"
+	HeaderRPCError      = "rpc-error"
+)
+
+// DefaultRPCTimeout is the per-call timeout used when no RPCOptions are supplied.
+const DefaultRPCTimeout = 30 * time.Second
+
+var (
+	// ErrRPCClientClosed is returned from Call once the RPCClient has been closed or its
+	// connection has failed.
+	ErrRPCClientClosed = errors.New("rpc client is closed")
+
+	// ErrRPCTimeout is joined with context.DeadlineExceeded when a call times out after its
+	// request was sent, in which case the server may still have handled it.
+	ErrRPCTimeout = errors.New("rpc call timed out waiting for a response")
+)
+
+// RPCError is returned from Call when the remote handler returned an error.
+type RPCError struct {
+	Message string
+}
+
+func (re *RPCError) Error() string {
+	return "remote handler returned an error: " + re.Message
+}
+
+// RPCOptions is a struct used by NewRPCClient and NewRPCServer to define certain optional
+// parameters.
+type RPCOptions struct {
+	// Timeout is applied to each call that doesn't already have a deadline set on its
+	// context. A zero or negative Timeout disables the per-call timeout.
+	Timeout time.Duration
+
+	// ReadOptions are passed to every Receive on the underlying connection.
+	ReadOptions ReadOptions
+
+	// Tracer, if not nil, is used to record a span for every call on the client and every
+	// request handled by the server. The trace context of the client is propagated to the
+	// server in the request headers. The Tracer is also attached to the underlying
+	// connection; see AsymmetricTypedConnection.SetTracer.
+	Tracer Tracer
+
+	// Retry, if not nil, is the policy used by RPCClient.Call to retry calls that fail
+	// with a transient error. It is not used by RPCServer.
+	Retry *RetryPolicy
+
+	// RetryTimeouts also retries calls that time out after their request was sent, which
+	// fail with ErrRPCTimeout. The server may already have handled such a request, so
+	// this is only safe for idempotent requests, which are then handled at least once
+	// rather than at most once.
+	RetryTimeouts bool
+}
+
+func defaultRPCOptions() RPCOptions {
+	return RPCOptions{
+		Timeout:     DefaultRPCTimeout,
+		ReadOptions: defaultReadOptions(),
+	}
+}
+
+// RPCClient is the client side of a request/response RPC layer built on top of an
+// AsymmetricTypedConnection. Each request is wrapped in an Envelope carrying a
+// correlation ID, which the server copies onto its response so that calls can be
+// issued concurrently over the same connection.
+type RPCClient[Req, Resp Convertable] struct {
+	conn    AsymmetricTypedConnection[Envelope[Req], Envelope[Resp]]
+	options RPCOptions
+
+	mu      sync.Mutex
+	pending map[string]chan Envelope[Resp]
+	err     error
+	done    chan struct{}
+}
+
+// NewRPCClient creates a new *RPCClient over conn, and starts receiving responses in the
+// background.
+//
+// This takes a variadic parameter of type RPCOptions. If no RPCOptions are supplied, then
+// the defaults are used. If more than one RPCOptions are supplied then only the first
+// will be used.
+func NewRPCClient[Req, Resp Convertable](conn net.Conn, opts ...RPCOptions) *RPCClient[Req, Resp] {
+	options := defaultRPCOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	client := &RPCClient[Req, Resp]{
+		conn:    NewAsymmetricTypedConnection[Envelope[Req], Envelope[Resp]](conn, ConnectionTypeTCP),
+		options: options,
+		pending: make(map[string]chan Envelope[Resp]),
+		done:    make(chan struct{}),
+	}
+	client.conn.SetTracer(options.Tracer)
+
+	go client.receive()
+
+	return client
+}
+
+// DialRPC attempts to connect to a given TCP socket at host:port, and creates a new
+// *RPCClient over the connection on success. On failure, an error is returned.
+func DialRPC[Req, Resp Convertable](host, port string, opts ...RPCOptions) (*RPCClient[Req, Resp], error) {
+	conn, err := dial("tcp", host, port, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRPCClient[Req, Resp](conn, opts...), nil
+}
+
+func (rc *RPCClient[Req, Resp]) receive() {
+	for {
+		var response Envelope[Resp]
+		if _, err := rc.conn.Receive(&response, WithReadOptions(rc.options.ReadOptions)); err != nil {
+			rc.fail(err)
+			return
+		}
+
+		id := response.Header(HeaderCorrelationID)
+
+		rc.mu.Lock()
+		waiter, ok := rc.pending[id]
+		delete(rc.pending, id)
+		rc.mu.Unlock()
+
+		if ok {
+			waiter <- response
+		}
+	}
+}
+
+func (rc *RPCClient[Req, Resp]) fail(err error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.err != nil {
+		return
+	}
+
+	rc.err = errors.Join(ErrRPCClientClosed, err)
+	close(rc.done)
+}
+
+// Call sends request to the server and waits for its response. The call is abandoned
+// when ctx is done or the per-call timeout elapses, whichever happens first, which also
+// applies to writing the request to the connection. If the remote handler failed, the
+// returned error is an *RPCError.
+//
+// If a Retry policy has been set, failed attempts are retried for as long as ctx is not
+// done, each with its own per-call timeout. Errors from the remote handler and failures
+// of the connection itself are never retried, as the client cannot recover from the
+// latter. Neither are attempts that time out waiting for a response, unless
+// RetryTimeouts is set, as the server may already have handled the request.
+func (rc *RPCClient[Req, Resp]) Call(ctx context.Context, request Req) (response Resp, err error) {
+	if rc.options.Tracer != nil {
+		var span Span
+		ctx, span = rc.options.Tracer.Start(ctx, "netutils.RPCClient.Call")
+		defer func() { span.End(err) }()
+	}
+
+	var policy *RetryPolicy
+	if rc.options.Retry != nil {
+		policy = &RetryPolicy{
+			MaxAttempts: rc.options.Retry.MaxAttempts,
+			Backoff:     rc.options.Retry.Backoff,
+			Retryable: func(err error) bool {
+				var rpcErr *RPCError
+				if errors.As(err, &rpcErr) || errors.Is(err, ErrRPCClientClosed) || ctx.Err() != nil {
+					return false
+				}
+				if errors.Is(err, ErrRPCTimeout) && !rc.options.RetryTimeouts {
+					return false
+				}
+
+				return rc.options.Retry.retryable(err)
+			},
+		}
+	}
+
+	return retry(ctx, policy, func() (Resp, error) { return rc.call(ctx, request) })
+}
+
+// call makes a single attempt at Call.
+func (rc *RPCClient[Req, Resp]) call(ctx context.Context, request Req) (Resp, error) {
+	var response Resp
+
+	if _, ok := ctx.Deadline(); !ok && rc.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rc.options.Timeout)
+		defer cancel()
+	}
+
+	envelope := NewEnvelope(request)
+	id := envelope.MessageID()
+	envelope.SetHeader(HeaderCorrelationID, id)
+	if rc.options.Tracer != nil {
+		rc.options.Tracer.Inject(ctx, envelope.Headers)
+	}
+
+	waiter := make(chan Envelope[Resp], 1)
+
+	rc.mu.Lock()
+	if rc.err != nil {
+		err := rc.err
+		rc.mu.Unlock()
+
+		return response, err
+	}
+	rc.pending[id] = waiter
+	rc.mu.Unlock()
+
+	abandon := func() {
+		rc.mu.Lock()
+		delete(rc.pending, id)
+		rc.mu.Unlock()
+	}
+
+	if n, err := rc.conn.SendContext(ctx, envelope); err != nil {
+		abandon()
+
+		// A request cut off partway through by ctx leaves the stream unusable.
+		if n > 0 {
+			_ = rc.Close()
+		}
+
+		return response, errors.Join(errors.New("could not send request"), err)
+	}
+
+	select {
+	case reply := <-waiter:
+		if message := reply.Header(HeaderRPCError); message != "" {
+			return response, &RPCError{Message: message}
+		}
+
+		return reply.Payload, nil
+	case <-rc.done:
+		abandon()
+
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+
+		return response, rc.err
+	case <-ctx.Done():
+		abandon()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return response, errors.Join(ErrRPCTimeout, ctx.Err())
+		}
+
+		return response, ctx.Err()
+	}
+}
+
+// Close closes the underlying connection. Any in-flight calls return an error wrapping
+// ErrRPCClientClosed.
+func (rc *RPCClient[Req, Resp]) Close() error {
+	err := rc.conn.Close()
+	rc.fail(net.ErrClosed)
+
+	return err
+}
+
+// RPCHandler handles a single request received by an RPCServer. The context is cancelled
+// when the per-call timeout elapses or the connection is closed.
+type RPCHandler[Req, Resp Convertable] func(ctx context.Context, request Req) (Resp, error)
+
+// RPCServer is the server side of the request/response RPC layer used by RPCClient.
+// Requests are handled concurrently, and responses are written back as soon as their
+// handler returns.
+type RPCServer[Req, Resp Convertable] struct {
+	mu      sync.RWMutex
+	handler RPCHandler[Req, Resp]
+	options RPCOptions
+}
+
+// NewRPCServer creates a new *RPCServer which dispatches all requests to handler.
+//
+// This takes a variadic parameter of type RPCOptions. If no RPCOptions are supplied, then
+// the defaults are used. If more than one RPCOptions are supplied then only the first
+// will be used.
+func NewRPCServer[Req, Resp Convertable](handler RPCHandler[Req, Resp], opts ...RPCOptions) *RPCServer[Req, Resp] {
+	options := defaultRPCOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	return &RPCServer[Req, Resp]{handler: handler, options: options}
+}
+
+// Handle registers handler as the handler for all subsequent requests, replacing the
+// previous handler.
+func (rs *RPCServer[Req, Resp]) Handle(handler RPCHandler[Req, Resp]) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.handler = handler
+}
+
+// Serve accepts connections from listener and serves each one in its own goroutine until
+// ctx is done or the listener fails. The listener is closed when Serve returns.
+func (rs *RPCServer[Req, Resp]) Serve(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { _ = listener.Close() })
+	defer stop()
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		go func() { _ = rs.ServeConn(ctx, conn) }()
+	}
+}
+
+// ServeConn reads requests from conn and dispatches them to the registered handler until
+// ctx is done or the connection fails. The connection is closed when ServeConn returns.
+func (rs *RPCServer[Req, Resp]) ServeConn(ctx context.Context, conn net.Conn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	tc := NewAsymmetricTypedConnection[Envelope[Resp], Envelope[Req]](conn, ConnectionTypeTCP)
+	tc.SetTracer(rs.options.Tracer)
+
+	for {
+		var request Envelope[Req]
+		if _, err := tc.Receive(&request, WithReadOptions(rs.options.ReadOptions)); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		go rs.dispatch(ctx, &tc, request)
+	}
+}
+
+func (rs *RPCServer[Req, Resp]) dispatch(ctx context.Context, tc *AsymmetricTypedConnection[Envelope[Resp], Envelope[Req]], request Envelope[Req]) {
+	if rs.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rs.options.Timeout)
+		defer cancel()
+	}
+
+	rs.mu.RLock()
+	handler := rs.handler
+	rs.mu.RUnlock()
+
+	var (
+		response Envelope[Resp]
+		result   Resp
+		err      error
+	)
+
+	if rs.options.Tracer != nil {
+		var span Span
+		ctx, span = rs.options.Tracer.Start(rs.options.Tracer.Extract(ctx, request.Headers), "netutils.RPCServer.Handle")
+		defer func() { span.End(err) }()
+	}
+
+	if handler == nil {
+		err = errors.New("no handler has been registered")
+	} else {
+		result, err = handler(ctx, request.Payload)
+	}
+
+	if err != nil {
+		// The zero value of Resp is not sent, as it may not be marshalable.
+		response.SetHeader(HeaderRPCError, err.Error())
+		response.SetHeader(HeaderNoPayload, "true")
+	} else {
+		response.Payload = result
+	}
+	response.SetHeader(HeaderCorrelationID, request.Header(HeaderCorrelationID))
+
+	_, _ = tc.SendContext(ctx, response)
+}
@@ -0,0 +1,98 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRPCCall(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+
+	server := NewRPCServer(func(_ context.Context, request testMessage) (testMessage, error) {
+		if request.Text == "fail" {
+			return testMessage{}, errors.New("asked to fail")
+		}
+
+		return testMessage{Text: strings.ToUpper(request.Text)}, nil
+	})
+	go func() { _ = server.ServeConn(context.Background(), serverConn) }()
+
+	client := NewRPCClient[testMessage, testMessage](clientConn)
+	defer client.Close()
+
+	response, err := client.Call(context.Background(), testMessage{Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Text != "HELLO" {
+		t.Errorf("response should be HELLO, got %q", response.Text)
+	}
+
+	var rpcErr *RPCError
+	if _, err := client.Call(context.Background(), testMessage{Text: "fail"}); !errors.As(err, &rpcErr) {
+		t.Errorf("failing handler should return an *RPCError, got %v", err)
+	}
+}
+
+func TestRPCCallTimeoutRetries(t *testing.T) {
+	for _, retryTimeouts := range []bool{false, true} {
+		clientConn, serverConn := net.Pipe()
+
+		var calls atomic.Int32
+		server := NewRPCServer(func(_ context.Context, request testMessage) (testMessage, error) {
+			// Only the first attempt is too slow.
+			if calls.Add(1) == 1 {
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			return request, nil
+		})
+		go func() { _ = server.ServeConn(context.Background(), serverConn) }()
+
+		client := NewRPCClient[testMessage, testMessage](clientConn, RPCOptions{
+			Timeout:       20 * time.Millisecond,
+			ReadOptions:   defaultReadOptions(),
+			Retry:         &RetryPolicy{MaxAttempts: 3, Backoff: Backoff{Initial: time.Millisecond}},
+			RetryTimeouts: retryTimeouts,
+		})
+
+		_, err := client.Call(context.Background(), testMessage{Text: "hello"})
+		switch {
+		case !retryTimeouts && (!errors.Is(err, ErrRPCTimeout) || !errors.Is(err, context.DeadlineExceeded)):
+			t.Errorf("expected ErrRPCTimeout, got %v", err)
+		case !retryTimeouts && calls.Load() != 1:
+			t.Errorf("expected the timed out request not to be sent again, got %d calls", calls.Load())
+		case retryTimeouts && (err != nil || calls.Load() < 2):
+			t.Errorf("expected the call to be retried, got %v after %d calls", err, calls.Load())
+		}
+
+		_ = client.Close()
+	}
+}
+
+func TestRPCErrorWithoutPayload(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+
+	// The zero value of a pointer response cannot be marshalled, so errors must be sent
+	// without one.
+	server := NewRPCServer(func(context.Context, testMessage) (*pointerMessage, error) {
+		return nil, errors.New("asked to fail")
+	})
+	go func() { _ = server.ServeConn(context.Background(), serverConn) }()
+
+	client := NewRPCClient[testMessage, *pointerMessage](clientConn)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var rpcErr *RPCError
+	if _, err := client.Call(ctx, testMessage{Text: "fail"}); !errors.As(err, &rpcErr) {
+		t.Fatalf("failing handler should return an *RPCError, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package netutils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ScanOptions is a struct used by ScanPorts to define certain optional parameters.
+type ScanOptions struct {
+	// Network is the network to scan on, such as "tcp" or "udp6".
+	Network string
+
+	// Timeout is how long to wait for each port to answer before it is considered
+	// closed. For UDP, it is instead how long to wait for a refusal before the port is
+	// considered open.
+	Timeout time.Duration
+}
+
+func defaultScanOptions() ScanOptions {
+	return ScanOptions{
+		Network: "tcp",
+		Timeout: time.Second,
+	}
+}
+
+// ScanPorts probes every port from from to to inclusive on host, with up to concurrency
+// probes running at once, and returns the sorted list of open ports. Ports are probed in
+// the same way as by WaitForPort, so UDP ports that silently drop datagrams are reported
+// as open. If ctx is done before the scan finishes, the ports found so far are returned
+// alongside ctx.Err().
+//
+// This takes a variadic parameter of type ScanOptions. If no ScanOptions are supplied,
+// then the defaults are used. If more than one ScanOptions are supplied then only the
+// first will be used.
+func ScanPorts(ctx context.Context, host string, from, to, concurrency int, opts ...ScanOptions) ([]int, error) {
+	options := defaultScanOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultScanOptions().Timeout
+	}
+
+	if from < 0 || to > 65535 || from > to {
+		return nil, fmt.Errorf("invalid port range %d-%d", from, to)
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var probe func(ctx context.Context, address string) error
+	switch options.Network {
+	case "tcp", "tcp4", "tcp6":
+		probe = func(ctx context.Context, address string) error {
+			return probeTCP(ctx, options.Network, address)
+		}
+	case "udp", "udp4", "udp6":
+		probe = func(ctx context.Context, address string) error {
+			return probeUDP(ctx, options.Network, address, options.Timeout)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported network %q", options.Network)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		open []int
+	)
+
+	ports := make(chan int)
+	for range min(concurrency, to-from+1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for port := range ports {
+				ctx, cancel := context.WithTimeout(ctx, options.Timeout)
+				err := probe(ctx, net.JoinHostPort(host, strconv.Itoa(port)))
+				cancel()
+
+				if err == nil {
+					mu.Lock()
+					open = append(open, port)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for port := from; port <= to; port++ {
+		select {
+		case ports <- port:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(ports)
+	wg.Wait()
+
+	slices.Sort(open)
+
+	return open, ctx.Err()
+}
@@ -0,0 +1,32 @@
+package netutils
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestScanPorts(t *testing.T) {
+	listener, port, err := ListenFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	open, err := ScanPorts(ctx, "127.0.0.1", port-4, min(port+4, 65535), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Contains(open, port) {
+		t.Errorf("expected %d to be open, got %v", port, open)
+	}
+
+	if _, err := ScanPorts(ctx, "127.0.0.1", 10, 5, 1); err == nil {
+		t.Error("expected an error for an invalid range")
+	}
+}
@@ -0,0 +1,859 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Header keys used by the session protocol spoken between SessionServer and
+// SessionClient.
+const (
+	headerSessionOperation = "session-op"
+	headerSessionToken     = "session-token"
+	headerSessionSequence  = "session-seq"
+	headerSessionAck       = "session-ack"
+	headerSessionResumed   = "session-resumed"
+)
+
+const (
+	sessionHello   = "hello"
+	sessionWelcome = "welcome"
+	sessionMessage = "message"
+	sessionAck     = "ack"
+	sessionBye     = "bye"
+)
+
+// ErrSessionExpired is returned by Session.Receive and Session.Send once the client has
+// been disconnected for longer than the session expiry without resuming.
+var ErrSessionExpired = errors.New("session expired")
+
+type sessionFrame[T Convertable] struct {
+	sequence uint64
+	payload  T
+}
+
+// sessionEndpoint is one side of a session. It numbers outgoing messages and keeps them
+// until the peer acknowledges them, so that they can be resent after a reconnect, and it
+// tracks the incoming messages that have already been delivered so that resent
+// duplicates are dropped.
+type sessionEndpoint[T Convertable] struct {
+	mu     sync.Mutex
+	conn   *TypedConnection[Envelope[T]]
+	notify chan struct{}
+	closed bool
+	err    error
+
+	sent     uint64
+	outbox   []sessionFrame[T]
+	received uint64
+	unacked  int
+
+	bufferSize  int
+	readOptions ReadOptions
+
+	// disconnected is called, without mu held, whenever conn fails.
+	disconnected func(err error)
+}
+
+func newSessionEndpoint[T Convertable](bufferSize int, readOptions ReadOptions) *sessionEndpoint[T] {
+	return &sessionEndpoint[T]{
+		notify:      make(chan struct{}),
+		bufferSize:  max(bufferSize, 1),
+		readOptions: readOptions,
+	}
+}
+
+// wake wakes up every Receive that is waiting for a connection. se.mu must be held.
+func (se *sessionEndpoint[T]) wake() {
+	close(se.notify)
+	se.notify = make(chan struct{})
+}
+
+// acknowledged drops every outgoing message up to and including sequence. se.mu must be
+// held.
+func (se *sessionEndpoint[T]) acknowledged(sequence uint64) {
+	i := 0
+	for i < len(se.outbox) && se.outbox[i].sequence <= sequence {
+		i++
+	}
+
+	se.outbox = se.outbox[i:]
+}
+
+// attach makes conn the current connection of the session, replacing any previous one,
+// and resends every message that the peer has not acknowledged. If reset is true, the
+// peer started a new session, so nothing is resent and both sequences start over.
+func (se *sessionEndpoint[T]) attach(conn *TypedConnection[Envelope[T]], peerAck uint64, reset bool) error {
+	se.mu.Lock()
+	if se.closed {
+		se.mu.Unlock()
+		_ = conn.Close()
+
+		return errors.Join(net.ErrClosed, se.err)
+	}
+
+	if se.conn != nil {
+		_ = se.conn.Close()
+	}
+
+	if reset {
+		se.sent, se.received, se.outbox = 0, 0, nil
+	} else {
+		se.acknowledged(peerAck)
+	}
+
+	se.conn = conn
+	se.wake()
+
+	var err error
+	for _, frame := range se.outbox {
+		if err = se.write(conn, frame); err != nil {
+			break
+		}
+	}
+	se.mu.Unlock()
+
+	if err != nil {
+		se.detach(conn, err)
+	}
+
+	return nil
+}
+
+// detach drops conn after it has failed, unless it has already been replaced.
+func (se *sessionEndpoint[T]) detach(conn *TypedConnection[Envelope[T]], err error) {
+	se.mu.Lock()
+	if se.conn != conn || se.closed {
+		se.mu.Unlock()
+		return
+	}
+
+	_ = conn.Close()
+	se.conn = nil
+	se.wake()
+	se.mu.Unlock()
+
+	if se.disconnected != nil {
+		se.disconnected(err)
+	}
+}
+
+// write sends frame on conn, acknowledging every message received so far. se.mu must be
+// held.
+func (se *sessionEndpoint[T]) write(conn *TypedConnection[Envelope[T]], frame sessionFrame[T]) error {
+	envelope := Envelope[T]{Payload: frame.payload}
+	envelope.SetHeader(headerSessionOperation, sessionMessage)
+	envelope.SetHeader(headerSessionSequence, strconv.FormatUint(frame.sequence, 10))
+	envelope.SetHeader(headerSessionAck, strconv.FormatUint(se.received, 10))
+	se.unacked = 0
+
+	_, err := conn.Send(envelope)
+	return err
+}
+
+// control sends a message with no payload to the peer. se.mu must be held.
+func (se *sessionEndpoint[T]) control(conn *TypedConnection[Envelope[T]], operation string) error {
+	var envelope Envelope[T]
+	envelope.SetHeader(headerSessionOperation, operation)
+	envelope.SetHeader(headerSessionAck, strconv.FormatUint(se.received, 10))
+	se.unacked = 0
+
+	_, err := conn.Send(envelope)
+	return err
+}
+
+func (se *sessionEndpoint[T]) closedErr() error {
+	if se.err != nil {
+		return se.err
+	}
+
+	return net.ErrClosed
+}
+
+func (se *sessionEndpoint[T]) send(value T) error {
+	se.mu.Lock()
+	if se.closed {
+		defer se.mu.Unlock()
+		return se.closedErr()
+	}
+	if len(se.outbox) >= se.bufferSize {
+		se.mu.Unlock()
+		return ErrWriteBufferFull
+	}
+
+	se.sent++
+	frame := sessionFrame[T]{sequence: se.sent, payload: value}
+	se.outbox = append(se.outbox, frame)
+
+	conn := se.conn
+	var err error
+	if conn != nil {
+		err = se.write(conn, frame)
+	}
+	se.mu.Unlock()
+
+	// The message stays in the outbox, so it is resent once the session resumes.
+	if err != nil {
+		se.detach(conn, err)
+	}
+
+	return nil
+}
+
+func (se *sessionEndpoint[T]) receive(data *T) error {
+	for {
+		se.mu.Lock()
+		if se.closed {
+			defer se.mu.Unlock()
+			return se.closedErr()
+		}
+
+		conn := se.conn
+		if conn == nil {
+			notify := se.notify
+			se.mu.Unlock()
+
+			<-notify
+			continue
+		}
+		se.mu.Unlock()
+
+		var envelope Envelope[T]
+		if _, err := conn.Receive(&envelope, WithReadOptions(se.readOptions)); err != nil {
+			if errors.Is(err, ErrUnmarshal) {
+				return err
+			}
+
+			se.detach(conn, err)
+			continue
+		}
+
+		ack, _ := strconv.ParseUint(envelope.Header(headerSessionAck), 10, 64)
+		sequence, _ := strconv.ParseUint(envelope.Header(headerSessionSequence), 10, 64)
+
+		se.mu.Lock()
+		se.acknowledged(ack)
+
+		switch envelope.Header(headerSessionOperation) {
+		case sessionBye:
+			se.closeLocked(io.EOF)
+			se.mu.Unlock()
+
+			return io.EOF
+		case sessionMessage:
+			if sequence <= se.received {
+				se.mu.Unlock()
+				continue
+			}
+
+			se.received = sequence
+			se.unacked++
+
+			// Acknowledge regularly even if nothing is being sent back, so that the
+			// outbox of the peer does not fill up.
+			var err error
+			if se.unacked >= max(se.bufferSize/4, 1) && se.conn == conn {
+				err = se.control(conn, sessionAck)
+			}
+			se.mu.Unlock()
+
+			if err != nil {
+				se.detach(conn, err)
+			}
+
+			*data = envelope.Payload
+
+			return nil
+		default:
+			se.mu.Unlock()
+		}
+	}
+}
+
+// closeLocked closes the session with err. se.mu must be held.
+func (se *sessionEndpoint[T]) closeLocked(err error) {
+	if se.closed {
+		return
+	}
+
+	se.closed = true
+	se.err = err
+	se.outbox = nil
+	if se.conn != nil {
+		_ = se.conn.Close()
+		se.conn = nil
+	}
+	se.wake()
+}
+
+// close tells the peer that the session is over if it is connected, and then closes it.
+func (se *sessionEndpoint[T]) close() error {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+
+	if se.closed {
+		return nil
+	}
+
+	var err error
+	if se.conn != nil {
+		err = se.control(se.conn, sessionBye)
+	}
+	se.closeLocked(nil)
+
+	return err
+}
+
+// SessionServerOptions is a struct used by NewSessionServer to define certain optional
+// parameters.
+type SessionServerOptions struct {
+	// Expiry is how long a session is kept after its client disconnects. If the client
+	// does not reconnect within this time, the session is closed with
+	// ErrSessionExpired. If it is zero or negative, 2 minutes is used.
+	Expiry time.Duration
+
+	// BufferSize is the amount of messages sent to each client that are kept until they
+	// are acknowledged. Send fails with ErrWriteBufferFull once this is reached. If it is
+	// zero or negative, 256 is used.
+	BufferSize int
+
+	// HandshakeTimeout is how long a new connection has to present its session token. If
+	// it is zero, 10 seconds is used, and if it is negative, there is no timeout.
+	HandshakeTimeout time.Duration
+
+	// ReadOptions are passed to every Receive on client connections.
+	ReadOptions ReadOptions
+}
+
+func defaultSessionServerOptions() SessionServerOptions {
+	return SessionServerOptions{
+		Expiry:           2 * time.Minute,
+		BufferSize:       256,
+		HandshakeTimeout: 10 * time.Second,
+		ReadOptions:      defaultReadOptions(),
+	}
+}
+
+// withDefaults fills in the fields of so that have been left at their zero value, so
+// that a partially filled in SessionServerOptions behaves sensibly.
+func (so SessionServerOptions) withDefaults() SessionServerOptions {
+	defaults := defaultSessionServerOptions()
+	if so.Expiry <= 0 {
+		so.Expiry = defaults.Expiry
+	}
+	if so.BufferSize <= 0 {
+		so.BufferSize = defaults.BufferSize
+	}
+	if so.HandshakeTimeout == 0 {
+		so.HandshakeTimeout = defaults.HandshakeTimeout
+	}
+	so.ReadOptions = so.ReadOptions.withDefaults()
+
+	return so
+}
+
+// SessionServer issues sessions to SessionClients. Each session outlives the connection
+// that it was created on: when a client reconnects and presents its session token, the
+// new connection is attached to the existing *Session, messages that were not delivered
+// in either direction are resent, and any state stored with Session.SetValue is kept.
+//
+// All messages are acknowledged by the receiving side, which is done by Receive, so
+// sessions must be read from regularly.
+type SessionServer[T Convertable] struct {
+	mu       sync.Mutex
+	sessions map[string]*Session[T]
+	options  SessionServerOptions
+}
+
+// NewSessionServer creates a new *SessionServer with no sessions.
+//
+// This takes a variadic parameter of type SessionServerOptions. If no
+// SessionServerOptions are supplied, then the defaults are used. If more than one
+// SessionServerOptions are supplied then only the first will be used.
+func NewSessionServer[T Convertable](opts ...SessionServerOptions) *SessionServer[T] {
+	options := defaultSessionServerOptions()
+	if opts != nil {
+		options = opts[0].withDefaults()
+	}
+
+	return &SessionServer[T]{
+		sessions: make(map[string]*Session[T]),
+		options:  options,
+	}
+}
+
+// Accept performs the session handshake on conn. If the client presented the token of a
+// session that has not expired, conn is attached to that session and it is returned with
+// resumed set to true; the goroutine that is already serving the session carries on
+// with the new connection. Otherwise, a new session is created and returned with
+// resumed set to false, and the caller should start serving it. On failure, conn is
+// closed and an error is returned.
+func (ss *SessionServer[T]) Accept(conn net.Conn) (*Session[T], bool, error) {
+	tc := NewTypedConnection[Envelope[T]](conn, ConnectionTypeTCP)
+
+	if ss.options.HandshakeTimeout > 0 {
+		_ = tc.SetDeadline(time.Now().Add(ss.options.HandshakeTimeout))
+	}
+
+	var hello Envelope[T]
+	if _, err := tc.Receive(&hello, WithReadOptions(ss.options.ReadOptions)); err != nil {
+		_ = tc.Close()
+		return nil, false, fmt.Errorf("could not receive session hello: %w", err)
+	}
+	if hello.Header(headerSessionOperation) != sessionHello {
+		_ = tc.Close()
+		return nil, false, errors.New("expected session hello")
+	}
+
+	ss.mu.Lock()
+	session, resumed := ss.sessions[hello.Header(headerSessionToken)]
+	if resumed {
+		session.generation++
+		if session.expiry != nil {
+			session.expiry.Stop()
+		}
+	} else {
+		session = &Session[T]{
+			token:    newMessageID(),
+			server:   ss,
+			endpoint: newSessionEndpoint[T](ss.options.BufferSize, ss.options.ReadOptions),
+		}
+		session.endpoint.disconnected = func(error) { ss.disconnected(session) }
+		ss.sessions[session.token] = session
+	}
+	ss.mu.Unlock()
+
+	session.endpoint.mu.Lock()
+	received := session.endpoint.received
+	session.endpoint.mu.Unlock()
+
+	var welcome Envelope[T]
+	welcome.SetHeader(headerSessionOperation, sessionWelcome)
+	welcome.SetHeader(headerSessionToken, session.token)
+	welcome.SetHeader(headerSessionResumed, strconv.FormatBool(resumed))
+	welcome.SetHeader(headerSessionAck, strconv.FormatUint(received, 10))
+
+	if _, err := tc.Send(welcome); err != nil {
+		_ = tc.Close()
+		ss.disconnected(session)
+
+		return nil, false, fmt.Errorf("could not send session welcome: %w", err)
+	}
+	_ = tc.SetDeadline(time.Time{})
+
+	peerAck, _ := strconv.ParseUint(hello.Header(headerSessionAck), 10, 64)
+	if err := session.endpoint.attach(&tc, peerAck, false); err != nil {
+		return nil, false, err
+	}
+
+	return session, resumed, nil
+}
+
+// Serve accepts connections from listener until ctx is done or the listener fails,
+// calling handle in a new goroutine for every new session. Resumed sessions are attached
+// to their existing session instead. The listener is closed when Serve returns.
+func (ss *SessionServer[T]) Serve(ctx context.Context, listener net.Listener, handle func(session *Session[T])) error {
+	stop := context.AfterFunc(ctx, func() { _ = listener.Close() })
+	defer stop()
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		go func() {
+			session, resumed, err := ss.Accept(conn)
+			if err == nil && !resumed {
+				handle(session)
+			}
+		}()
+	}
+}
+
+// disconnected starts the expiry timer of session.
+func (ss *SessionServer[T]) disconnected(session *Session[T]) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.sessions[session.token] != session {
+		return
+	}
+
+	generation := session.generation
+	if session.expiry != nil {
+		session.expiry.Stop()
+	}
+	session.expiry = time.AfterFunc(ss.options.Expiry, func() { ss.expire(session, generation) })
+}
+
+// expire closes session if it has not been resumed since generation.
+func (ss *SessionServer[T]) expire(session *Session[T], generation uint64) {
+	ss.mu.Lock()
+	if ss.sessions[session.token] != session || session.generation != generation {
+		ss.mu.Unlock()
+		return
+	}
+	delete(ss.sessions, session.token)
+	ss.mu.Unlock()
+
+	session.endpoint.mu.Lock()
+	session.endpoint.closeLocked(ErrSessionExpired)
+	session.endpoint.mu.Unlock()
+}
+
+func (ss *SessionServer[T]) remove(session *Session[T]) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.sessions[session.token] == session {
+		delete(ss.sessions, session.token)
+	}
+	if session.expiry != nil {
+		session.expiry.Stop()
+	}
+}
+
+// Len returns the amount of sessions, both connected and awaiting resumption.
+func (ss *SessionServer[T]) Len() int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	return len(ss.sessions)
+}
+
+// Close closes every session.
+func (ss *SessionServer[T]) Close() error {
+	ss.mu.Lock()
+	sessions := ss.sessions
+	ss.sessions = make(map[string]*Session[T])
+	ss.mu.Unlock()
+
+	var errs []error
+	for _, session := range sessions {
+		if err := session.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Session is the server side of a session with a SessionClient, created by
+// SessionServer.Accept.
+type Session[T Convertable] struct {
+	token    string
+	server   *SessionServer[T]
+	endpoint *sessionEndpoint[T]
+
+	// generation and expiry are guarded by the mutex of the server.
+	generation uint64
+	expiry     *time.Timer
+
+	valueMu sync.Mutex
+	value   any
+}
+
+// Token returns the token that identifies the session.
+func (s *Session[T]) Token() string {
+	return s.token
+}
+
+// Value returns the value stored with SetValue, or nil if there is none.
+func (s *Session[T]) Value() any {
+	s.valueMu.Lock()
+	defer s.valueMu.Unlock()
+
+	return s.value
+}
+
+// SetValue stores per-session state, which is kept when the client resumes the session.
+func (s *Session[T]) SetValue(value any) {
+	s.valueMu.Lock()
+	defer s.valueMu.Unlock()
+
+	s.value = value
+}
+
+// Send sends value to the client. If the client is currently disconnected, value is kept
+// and sent when the session is resumed.
+func (s *Session[T]) Send(value T) error {
+	return s.endpoint.send(value)
+}
+
+// Receive blocks until a value is received from the client, waiting for the session to
+// be resumed if the client is currently disconnected. io.EOF is returned once the client
+// closes the session, and ErrSessionExpired once it fails to resume in time.
+func (s *Session[T]) Receive(data *T) error {
+	err := s.endpoint.receive(data)
+	if errors.Is(err, io.EOF) {
+		s.server.remove(s)
+	}
+
+	return err
+}
+
+// Close ends the session, telling the client if it is connected.
+func (s *Session[T]) Close() error {
+	s.server.remove(s)
+	return s.endpoint.close()
+}
+
+// SessionClientOptions is a struct used by NewSessionClient to define certain optional
+// parameters.
+type SessionClientOptions struct {
+	// Backoff spaces out the reconnects. If it is the zero Backoff, the default is used.
+	Backoff Backoff
+
+	// MaxAttempts is the amount of consecutive failed reconnects after which the client
+	// gives up and closes itself. A zero or negative MaxAttempts retries forever.
+	MaxAttempts int
+
+	// BufferSize is the amount of sent messages that are kept until they are
+	// acknowledged by the server. Send fails with ErrWriteBufferFull once this is
+	// reached. If it is zero or negative, 256 is used.
+	BufferSize int
+
+	// HandshakeTimeout is how long the server has to answer the session handshake. If it
+	// is zero, 10 seconds is used, and if it is negative, there is no timeout.
+	HandshakeTimeout time.Duration
+
+	// OnReconnect, if not nil, is called after every reconnect. resumed is false if the
+	// server no longer knew the session, in which case a new session was started and
+	// messages that had not been delivered in either direction were lost.
+	OnReconnect func(resumed bool)
+
+	// ReadOptions are passed to every Receive on the underlying connection.
+	ReadOptions ReadOptions
+}
+
+func defaultSessionClientOptions() SessionClientOptions {
+	return SessionClientOptions{
+		Backoff:          defaultBackoff(),
+		BufferSize:       256,
+		HandshakeTimeout: 10 * time.Second,
+		ReadOptions:      defaultReadOptions(),
+	}
+}
+
+// withDefaults fills in the fields of co that have been left at their zero value, so
+// that a partially filled in SessionClientOptions does not reconnect in a hot loop or
+// fail every Send after the first unacknowledged message.
+func (co SessionClientOptions) withDefaults() SessionClientOptions {
+	defaults := defaultSessionClientOptions()
+	if co.Backoff == (Backoff{}) {
+		co.Backoff = defaults.Backoff
+	}
+	if co.BufferSize <= 0 {
+		co.BufferSize = defaults.BufferSize
+	}
+	if co.HandshakeTimeout == 0 {
+		co.HandshakeTimeout = defaults.HandshakeTimeout
+	}
+	co.ReadOptions = co.ReadOptions.withDefaults()
+
+	return co
+}
+
+// SessionClient is the client side of a session with a SessionServer. When its
+// connection fails, it re-dials and resumes the session, so that messages sent in
+// either direction while disconnected are delivered exactly once, in order. As with the
+// server, acknowledgements are processed by Receive, so the client must be read from
+// regularly.
+//
+// A SessionClient is safe for concurrent use.
+type SessionClient[T Convertable] struct {
+	dial     func() (net.Conn, error)
+	options  SessionClientOptions
+	endpoint *sessionEndpoint[T]
+
+	mu           sync.Mutex
+	token        string
+	reconnecting bool
+}
+
+// NewSessionClient creates a new *SessionClient which uses dial to open connections. The
+// first connection is dialled and a new session is started immediately, and its error
+// is returned if either fails.
+//
+// This takes a variadic parameter of type SessionClientOptions. If no
+// SessionClientOptions are supplied, then the defaults are used. If more than one
+// SessionClientOptions are supplied then only the first will be used.
+func NewSessionClient[T Convertable](dial func() (net.Conn, error), opts ...SessionClientOptions) (*SessionClient[T], error) {
+	options := defaultSessionClientOptions()
+	if opts != nil {
+		options = opts[0].withDefaults()
+	}
+
+	sc := &SessionClient[T]{
+		dial:     dial,
+		options:  options,
+		endpoint: newSessionEndpoint[T](options.BufferSize, options.ReadOptions),
+	}
+	sc.endpoint.disconnected = sc.disconnected
+
+	if _, err := sc.connect(); err != nil {
+		return nil, err
+	}
+
+	return sc, nil
+}
+
+// DialTCPSession attempts to connect to a SessionServer listening at host:port, and
+// creates a new *SessionClient that re-dials the same address whenever the connection
+// fails. See NewSessionClient.
+func DialTCPSession[T Convertable](host, port string, opts ...SessionClientOptions) (*SessionClient[T], error) {
+	return NewSessionClient[T](func() (net.Conn, error) {
+		return dial("tcp", host, port, nil)
+	}, opts...)
+}
+
+// connect dials a connection and performs the session handshake on it.
+func (sc *SessionClient[T]) connect() (bool, error) {
+	conn, err := sc.dial()
+	if err != nil {
+		return false, err
+	}
+
+	tc := NewTypedConnection[Envelope[T]](conn, ConnectionTypeTCP)
+	if sc.options.HandshakeTimeout > 0 {
+		_ = tc.SetDeadline(time.Now().Add(sc.options.HandshakeTimeout))
+	}
+
+	sc.mu.Lock()
+	token := sc.token
+	sc.mu.Unlock()
+
+	sc.endpoint.mu.Lock()
+	received := sc.endpoint.received
+	sc.endpoint.mu.Unlock()
+
+	var hello Envelope[T]
+	hello.SetHeader(headerSessionOperation, sessionHello)
+	hello.SetHeader(headerSessionToken, token)
+	hello.SetHeader(headerSessionAck, strconv.FormatUint(received, 10))
+
+	if _, err := tc.Send(hello); err != nil {
+		_ = tc.Close()
+		return false, fmt.Errorf("could not send session hello: %w", err)
+	}
+
+	var welcome Envelope[T]
+	if _, err := tc.Receive(&welcome, WithReadOptions(sc.options.ReadOptions)); err != nil {
+		_ = tc.Close()
+		return false, fmt.Errorf("could not receive session welcome: %w", err)
+	}
+	if welcome.Header(headerSessionOperation) != sessionWelcome || welcome.Header(headerSessionToken) == "" {
+		_ = tc.Close()
+		return false, errors.New("expected session welcome")
+	}
+	_ = tc.SetDeadline(time.Time{})
+
+	resumed := welcome.Header(headerSessionResumed) == "true"
+
+	sc.mu.Lock()
+	sc.token = welcome.Header(headerSessionToken)
+	sc.mu.Unlock()
+
+	peerAck, _ := strconv.ParseUint(welcome.Header(headerSessionAck), 10, 64)
+	if err := sc.endpoint.attach(&tc, peerAck, !resumed); err != nil {
+		return false, err
+	}
+
+	return resumed, nil
+}
+
+func (sc *SessionClient[T]) disconnected(err error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if !sc.reconnecting {
+		sc.reconnecting = true
+		go sc.reconnect(err)
+	}
+}
+
+func (sc *SessionClient[T]) reconnect(cause error) {
+	defer func() {
+		sc.mu.Lock()
+		sc.reconnecting = false
+		sc.mu.Unlock()
+
+		// The new connection may have failed before reconnecting was cleared, in which
+		// case nobody else will start reconnecting again.
+		sc.endpoint.mu.Lock()
+		lost := sc.endpoint.conn == nil && !sc.endpoint.closed
+		sc.endpoint.mu.Unlock()
+
+		if lost {
+			sc.disconnected(cause)
+		}
+	}()
+
+	for attempt := 0; ; attempt++ {
+		sc.endpoint.mu.Lock()
+		closed := sc.endpoint.closed
+		sc.endpoint.mu.Unlock()
+		if closed {
+			return
+		}
+
+		resumed, err := sc.connect()
+		if err == nil {
+			if sc.options.OnReconnect != nil {
+				sc.options.OnReconnect(resumed)
+			}
+
+			return
+		}
+		cause = err
+
+		if sc.options.MaxAttempts > 0 && attempt+1 >= sc.options.MaxAttempts {
+			sc.endpoint.mu.Lock()
+			sc.endpoint.closeLocked(errors.Join(errors.New("gave up reconnecting"), cause))
+			sc.endpoint.mu.Unlock()
+
+			return
+		}
+
+		time.Sleep(sc.options.Backoff.Delay(attempt))
+	}
+}
+
+// Token returns the token of the current session.
+func (sc *SessionClient[T]) Token() string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.token
+}
+
+// Send sends value to the server. If the client is currently disconnected, value is kept
+// and sent once the session has been resumed.
+func (sc *SessionClient[T]) Send(value T) error {
+	return sc.endpoint.send(value)
+}
+
+// Receive blocks until a value is received from the server, waiting for the session to
+// be resumed if the client is currently disconnected. io.EOF is returned once the server
+// closes the session.
+func (sc *SessionClient[T]) Receive(data *T) error {
+	return sc.endpoint.receive(data)
+}
+
+// Close ends the session, telling the server if the client is connected, and stops any
+// reconnection attempts.
+func (sc *SessionClient[T]) Close() error {
+	return sc.endpoint.close()
+}
@@ -0,0 +1,233 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cuttableDialer dials address and remembers the last connection, so that tests can
+// simulate the connection dropping.
+type cuttableDialer struct {
+	address string
+
+	mu   sync.Mutex
+	conn net.Conn
+	fail bool
+}
+
+func (cd *cuttableDialer) dial() (net.Conn, error) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	if cd.fail {
+		return nil, errors.New("dial disabled")
+	}
+
+	conn, err := net.Dial("tcp", cd.address)
+	cd.conn = conn
+
+	return conn, err
+}
+
+func (cd *cuttableDialer) cut(fail bool) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	cd.fail = fail
+	_ = cd.conn.Close()
+}
+
+func TestSessionResumption(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewSessionServer[testMessage]()
+	defer server.Close()
+
+	received := make(chan string, 16)
+	sessions := make(chan *Session[testMessage], 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = server.Serve(ctx, listener, func(session *Session[testMessage]) {
+			session.SetValue("state")
+			sessions <- session
+
+			for {
+				var message testMessage
+				if err := session.Receive(&message); err != nil {
+					close(received)
+					return
+				}
+
+				received <- message.Text
+				_ = session.Send(testMessage{Text: "echo " + message.Text})
+			}
+		})
+	}()
+
+	dialer := &cuttableDialer{address: listener.Addr().String()}
+	reconnected := make(chan bool, 1)
+	client, err := NewSessionClient[testMessage](dialer.dial, SessionClientOptions{
+		Backoff:     Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond},
+		BufferSize:  16,
+		OnReconnect: func(resumed bool) { reconnected <- resumed },
+		ReadOptions: defaultReadOptions(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	session := <-sessions
+	if session.Token() != client.Token() {
+		t.Fatalf("expected token %q, got %q", session.Token(), client.Token())
+	}
+
+	expect := func(want string) {
+		t.Helper()
+
+		var reply testMessage
+		if err := client.Receive(&reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Text != want {
+			t.Fatalf("expected %q, got %q", want, reply.Text)
+		}
+	}
+
+	if err := client.Send(testMessage{Text: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	expect("echo one")
+
+	dialer.cut(false)
+	if err := client.Send(testMessage{Text: "two"}); err != nil {
+		t.Fatal(err)
+	}
+	expect("echo two")
+
+	select {
+	case resumed := <-reconnected:
+		if !resumed {
+			t.Fatal("expected the session to be resumed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reconnect")
+	}
+
+	for _, want := range []string{"one", "two"} {
+		if got := <-received; got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+	if server.Len() != 1 || session.Value() != "state" {
+		t.Errorf("expected the session and its state to be kept")
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-received; ok {
+		t.Error("expected no more messages")
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	server := NewSessionServer[testMessage](SessionServerOptions{Expiry: 50 * time.Millisecond, BufferSize: 16})
+
+	accepted := make(chan *Session[testMessage], 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		session, _, err := server.Accept(conn)
+		if err == nil {
+			accepted <- session
+		}
+	}()
+
+	dialer := &cuttableDialer{address: listener.Addr().String()}
+	client, err := NewSessionClient[testMessage](dialer.dial, SessionClientOptions{
+		Backoff:     Backoff{Initial: time.Millisecond},
+		MaxAttempts: 1,
+		BufferSize:  16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	session := <-accepted
+	dialer.cut(true)
+
+	var message testMessage
+	if err := session.Receive(&message); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("expected ErrSessionExpired, got %v", err)
+	}
+	if server.Len() != 0 {
+		t.Errorf("expected the expired session to be removed, got %d sessions", server.Len())
+	}
+}
+
+func TestSessionPartialOptions(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Fields left at zero are defaulted, rather than leaving a buffer of a single message.
+	server := NewSessionServer[testMessage](SessionServerOptions{Expiry: time.Minute})
+	defer server.Close()
+
+	accepted := make(chan *Session[testMessage], 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		session, _, err := server.Accept(conn)
+		if err == nil {
+			accepted <- session
+		}
+	}()
+
+	dialer := &cuttableDialer{address: listener.Addr().String()}
+	client, err := NewSessionClient[testMessage](dialer.dial, SessionClientOptions{MaxAttempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	session := <-accepted
+	for range 3 {
+		if err := client.Send(testMessage{Text: "hello"}); err != nil {
+			t.Fatalf("unacknowledged sends should be buffered, got %v", err)
+		}
+		if err := session.Send(testMessage{Text: "hello"}); err != nil {
+			t.Fatalf("unacknowledged sends should be buffered, got %v", err)
+		}
+	}
+
+	if client.options.Backoff != defaultBackoff() {
+		t.Errorf("zero backoff should default to %v, got %v", defaultBackoff(), client.options.Backoff)
+	}
+}
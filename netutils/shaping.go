@@ -0,0 +1,178 @@
+package netutils
+
+import (
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// ShapeOptions is a struct used by NewShapedConn to define certain optional parameters.
+type ShapeOptions struct {
+	// Latency is the one-way delay added to everything written to the connection.
+	Latency time.Duration
+	// Jitter is the maximum amount, in either direction, by which the latency of each
+	// write randomly varies. Writes are never reordered by jitter.
+	Jitter time.Duration
+	// BytesPerSecond caps the throughput of the connection. Write blocks for as long as
+	// the data would take to be sent at this rate. A zero or negative value means that
+	// the throughput is not capped.
+	BytesPerSecond int
+	// Seed seeds the random source used for jitter. If zero, a random seed is used.
+	Seed uint64
+}
+
+type shapedWrite struct {
+	data      []byte
+	deliverAt time.Time
+}
+
+// ShapedConn is a net.Conn that simulates the latency, jitter, and limited bandwidth of
+// a real network on the data written to it, so that code such as game or state
+// synchronisation can be tested under realistic conditions locally. It is used by
+// wrapping a connection before creating a typed connection over it:
+//
+//	conn := netutils.NewTypedConnection[T](netutils.NewShapedConn(raw, options), netutils.ConnectionTypeTCP)
+//
+// Latency is pipelined, as on a real network: Write only blocks for the time the data
+// takes to be sent at the capped throughput, and the data then arrives at the peer after
+// the latency has elapsed. Shaping is only applied to data written to the ShapedConn;
+// wrap both ends of a connection to shape both directions.
+type ShapedConn struct {
+	net.Conn
+
+	options ShapeOptions
+	queue   chan shapedWrite
+	done    chan struct{}
+
+	mu            sync.Mutex
+	rand          *rand.Rand
+	nextDeparture time.Time
+	lastDelivery  time.Time
+	err           error
+	closeOnce     sync.Once
+}
+
+// NewShapedConn creates a new *ShapedConn that writes to conn.
+//
+// This takes a variadic parameter of type ShapeOptions. If no ShapeOptions are supplied,
+// then no shaping is applied. If more than one ShapeOptions are supplied then only the
+// first will be used.
+func NewShapedConn(conn net.Conn, opts ...ShapeOptions) *ShapedConn {
+	var options ShapeOptions
+	if opts != nil {
+		options = opts[0]
+	}
+
+	seed := options.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+
+	sc := &ShapedConn{
+		Conn:    conn,
+		options: options,
+		queue:   make(chan shapedWrite, 256),
+		done:    make(chan struct{}),
+		rand:    rand.New(rand.NewPCG(seed, seed)),
+	}
+
+	go sc.deliver()
+
+	return sc
+}
+
+// Write queues p to be written to the underlying connection once its simulated delay has
+// elapsed. An error from an earlier delayed write is returned by the next Write.
+func (sc *ShapedConn) Write(p []byte) (int, error) {
+	// The queue may have room even once the connection is closed, so this is checked
+	// first rather than left to the selects below.
+	select {
+	case <-sc.done:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	sc.mu.Lock()
+	if sc.err != nil {
+		err := sc.err
+		sc.mu.Unlock()
+
+		return 0, err
+	}
+
+	now := time.Now()
+	departure := now
+	if sc.nextDeparture.After(departure) {
+		departure = sc.nextDeparture
+	}
+	if sc.options.BytesPerSecond > 0 {
+		departure = departure.Add(time.Duration(len(p)) * time.Second / time.Duration(sc.options.BytesPerSecond))
+	}
+	sc.nextDeparture = departure
+
+	latency := sc.options.Latency
+	if sc.options.Jitter > 0 {
+		latency += time.Duration(sc.rand.Int64N(int64(2*sc.options.Jitter)+1)) - sc.options.Jitter
+	}
+	deliverAt := departure.Add(max(latency, 0))
+	if deliverAt.Before(sc.lastDelivery) {
+		deliverAt = sc.lastDelivery
+	}
+	sc.lastDelivery = deliverAt
+	sc.mu.Unlock()
+
+	if wait := time.Until(departure); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-sc.done:
+			timer.Stop()
+			return 0, net.ErrClosed
+		}
+	}
+
+	select {
+	case sc.queue <- shapedWrite{data: append([]byte(nil), p...), deliverAt: deliverAt}:
+		return len(p), nil
+	case <-sc.done:
+		return 0, net.ErrClosed
+	}
+}
+
+func (sc *ShapedConn) deliver() {
+	timer := time.NewTimer(0)
+	<-timer.C
+
+	for {
+		var write shapedWrite
+		select {
+		case write = <-sc.queue:
+		case <-sc.done:
+			return
+		}
+
+		if wait := time.Until(write.deliverAt); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-sc.done:
+				return
+			}
+		}
+
+		if _, err := sc.Conn.Write(write.data); err != nil {
+			sc.mu.Lock()
+			sc.err = err
+			sc.mu.Unlock()
+
+			return
+		}
+	}
+}
+
+// Close closes the underlying connection. Data that is still in flight is discarded.
+func (sc *ShapedConn) Close() error {
+	sc.closeOnce.Do(func() { close(sc.done) })
+	return sc.Conn.Close()
+}
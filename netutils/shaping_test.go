@@ -0,0 +1,101 @@
+package netutils
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestShapedConnLatency(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	const latency = 50 * time.Millisecond
+	shaped := NewShapedConn(a, ShapeOptions{Latency: latency})
+	defer shaped.Close()
+
+	start := time.Now()
+	if _, err := shaped.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= latency {
+		t.Errorf("Write should not wait for the latency, took %s", elapsed)
+	}
+
+	buffer := make([]byte, 5)
+	if _, err := io.ReadFull(b, buffer); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("data should arrive after %s, arrived after %s", latency, elapsed)
+	}
+	if string(buffer) != "hello" {
+		t.Errorf("expected hello, got %q", buffer)
+	}
+}
+
+func TestShapedConnBandwidth(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	go func() { _, _ = io.Copy(io.Discard, b) }()
+
+	shaped := NewShapedConn(a, ShapeOptions{BytesPerSecond: 1000})
+	defer shaped.Close()
+
+	// Two writes of 50 bytes at 1000 bytes per second take 100ms to be sent.
+	start := time.Now()
+	for range 2 {
+		if _, err := shaped.Write(make([]byte, 50)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("writes should be throttled to 1000 bytes per second, took %s", elapsed)
+	}
+}
+
+func TestShapedConnJitterKeepsOrder(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	shaped := NewShapedConn(a, ShapeOptions{Latency: 10 * time.Millisecond, Jitter: 10 * time.Millisecond, Seed: 1})
+	defer shaped.Close()
+
+	const writes = 20
+	go func() {
+		for i := range writes {
+			if _, err := shaped.Write([]byte{byte(i)}); err != nil {
+				return
+			}
+		}
+	}()
+
+	buffer := make([]byte, writes)
+	if _, err := io.ReadFull(b, buffer); err != nil {
+		t.Fatal(err)
+	}
+	for i, value := range buffer {
+		if value != byte(i) {
+			t.Fatalf("writes should not be reordered by jitter, got %v", buffer)
+		}
+	}
+}
+
+func TestShapedConnClose(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	shaped := NewShapedConn(a, ShapeOptions{Latency: time.Hour})
+	if _, err := shaped.Write([]byte("lost")); err != nil {
+		t.Fatal(err)
+	}
+	if err := shaped.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := shaped.Write([]byte("hello")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("writing after closing should fail with net.ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package netutils
+
+import (
+	"errors"
+	"net"
+)
+
+// SocketOptions describes OS-level tuning for TCP sockets. Zero-valued fields leave the
+// corresponding setting at its default.
+type SocketOptions struct {
+	// Nagle enables Nagle's algorithm, which coalesces small writes at the cost of
+	// latency. Go disables it (i.e. sets TCP_NODELAY) by default, which suits most typed
+	// traffic.
+	Nagle bool
+
+	// ReadBuffer and WriteBuffer set the size of the operating system's receive and send
+	// buffers (SO_RCVBUF and SO_SNDBUF) in bytes.
+	ReadBuffer  int
+	WriteBuffer int
+
+	// Linger sets SO_LINGER, if not nil. See net.TCPConn.SetLinger for its meaning.
+	Linger *int
+}
+
+func (ttc *TCPTypedConnection[T]) tcpConn() (*net.TCPConn, error) {
+	conn, ok := ttc.conn.(*net.TCPConn)
+	if !ok {
+		return nil, errors.New("conn is an invalid connection type for this method")
+	}
+
+	return conn, nil
+}
+
+// SetNoDelay is a wrapper over net.TCPConn.SetNoDelay(), which controls whether Nagle's
+// algorithm is disabled.
+func (ttc *TCPTypedConnection[T]) SetNoDelay(noDelay bool) error {
+	conn, err := ttc.tcpConn()
+	if err != nil {
+		return err
+	}
+
+	return conn.SetNoDelay(noDelay)
+}
+
+// SetReadBuffer is a wrapper over net.TCPConn.SetReadBuffer().
+func (ttc *TCPTypedConnection[T]) SetReadBuffer(bytes int) error {
+	conn, err := ttc.tcpConn()
+	if err != nil {
+		return err
+	}
+
+	return conn.SetReadBuffer(bytes)
+}
+
+// SetWriteBuffer is a wrapper over net.TCPConn.SetWriteBuffer().
+func (ttc *TCPTypedConnection[T]) SetWriteBuffer(bytes int) error {
+	conn, err := ttc.tcpConn()
+	if err != nil {
+		return err
+	}
+
+	return conn.SetWriteBuffer(bytes)
+}
+
+// SetLinger is a wrapper over net.TCPConn.SetLinger().
+func (ttc *TCPTypedConnection[T]) SetLinger(seconds int) error {
+	conn, err := ttc.tcpConn()
+	if err != nil {
+		return err
+	}
+
+	return conn.SetLinger(seconds)
+}
+
+// Tune applies all of the given socket options to the connection, stopping at the first
+// one that fails.
+func (ttc *TCPTypedConnection[T]) Tune(opts SocketOptions) error {
+	if err := ttc.SetNoDelay(!opts.Nagle); err != nil {
+		return err
+	}
+	if opts.ReadBuffer > 0 {
+		if err := ttc.SetReadBuffer(opts.ReadBuffer); err != nil {
+			return err
+		}
+	}
+	if opts.WriteBuffer > 0 {
+		if err := ttc.SetWriteBuffer(opts.WriteBuffer); err != nil {
+			return err
+		}
+	}
+	if opts.Linger != nil {
+		if err := ttc.SetLinger(*opts.Linger); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetSocketOptions sets the socket options that are applied to every connection returned
+// by Accept from then on. Passing nil stops options from being applied.
+func (tsl *TCPSocketListener[T]) SetSocketOptions(opts *SocketOptions) {
+	tsl.socketOptions = opts
+}
@@ -0,0 +1,73 @@
+package netutils
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// newTestTCPListener listens on a random loopback port.
+func newTestTCPListener(t *testing.T) *TCPSocketListener[testMessage] {
+	t.Helper()
+
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tsl := NewTypedTCPSocketListener[testMessage](listener)
+	t.Cleanup(func() { _ = tsl.Close() })
+
+	return tsl
+}
+
+func TestTune(t *testing.T) {
+	listener := newTestTCPListener(t)
+
+	client, err := DialTCPAddr[testMessage](listener.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	linger := 5
+	if err := client.Tune(SocketOptions{Nagle: true, ReadBuffer: 64 * 1024, WriteBuffer: 64 * 1024, Linger: &linger}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	pipe := NewTCPTypedConnection[testMessage](a)
+	if err := pipe.Tune(SocketOptions{}); err == nil {
+		t.Error("tuning a connection that is not a *net.TCPConn should fail")
+	}
+}
+
+func TestListenerSocketOptions(t *testing.T) {
+	listener := newTestTCPListener(t)
+
+	// A linger of zero makes closing the connection reset it, so the peer sees an error
+	// other than io.EOF.
+	linger := 0
+	listener.SetSocketOptions(&SocketOptions{Linger: &linger})
+
+	client, err := DialTCPAddr[testMessage](listener.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	var message testMessage
+	if _, err := client.Receive(&message); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("accepted connection should have been reset on close, got %v", err)
+	}
+}
@@ -0,0 +1,400 @@
+package netutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"iter"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSEPublisherOptions is a struct used by NewSSEPublisher to define certain optional
+// parameters.
+type SSEPublisherOptions struct {
+	// BufferSize is the amount of events that can be waiting to be written to each
+	// subscriber. Subscribers that fall further behind than this are disconnected, so
+	// that they do not hold up the publisher.
+	BufferSize int
+
+	// KeepAlive is how often a comment is sent to idle subscribers, to stop proxies
+	// from closing the stream. If zero or less, no comments are sent.
+	KeepAlive time.Duration
+
+	// Base64 encodes each payload with standard base64, which is needed for encodings
+	// that are not line-based text, such as binary ones. Subscribers must use the same
+	// setting.
+	Base64 bool
+}
+
+func defaultSSEPublisherOptions() SSEPublisherOptions {
+	return SSEPublisherOptions{
+		BufferSize: 64,
+		KeepAlive:  15 * time.Second,
+	}
+}
+
+// SSEPublisher streams values to HTTP clients as Server-Sent Events, for clients such as
+// browser dashboards that cannot hold raw TCP connections open. It is an http.Handler;
+// each request to it subscribes to every value published from then on. Values are
+// marshalled using their Convertable implementation, and sent as the data of an event.
+//
+// An SSEPublisher is safe for concurrent use.
+type SSEPublisher[T Convertable] struct {
+	options SSEPublisherOptions
+
+	mu          sync.Mutex
+	subscribers map[chan []byte]struct{}
+	nextID      uint64
+	closed      bool
+	done        chan struct{}
+}
+
+// NewSSEPublisher creates a new *SSEPublisher with no subscribers.
+//
+// This takes a variadic parameter of type SSEPublisherOptions. If no SSEPublisherOptions
+// are supplied, then the defaults are used. If more than one SSEPublisherOptions are
+// supplied then only the first will be used.
+func NewSSEPublisher[T Convertable](opts ...SSEPublisherOptions) *SSEPublisher[T] {
+	options := defaultSSEPublisherOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	return &SSEPublisher[T]{
+		options:     options,
+		subscribers: make(map[chan []byte]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// ServeHTTP subscribes the client to the publisher, and streams events to it until
+// either the client disconnects or the publisher is closed.
+func (sp *SSEPublisher[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events := make(chan []byte, max(sp.options.BufferSize, 1))
+
+	sp.mu.Lock()
+	if sp.closed {
+		sp.mu.Unlock()
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+
+		return
+	}
+	sp.subscribers[events] = struct{}{}
+	sp.mu.Unlock()
+
+	defer sp.unsubscribe(events)
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var keepAlive <-chan time.Time
+	if sp.options.KeepAlive > 0 {
+		ticker := time.NewTicker(sp.options.KeepAlive)
+		defer ticker.Stop()
+		keepAlive = ticker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sp.done:
+			return
+		case event, ok := <-events:
+			if !ok {
+				// The subscriber fell too far behind.
+				return
+			}
+			_, err = w.Write(event)
+		case <-keepAlive:
+			_, err = w.Write([]byte(":\n\n"))
+		}
+
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func (sp *SSEPublisher[T]) unsubscribe(events chan []byte) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	delete(sp.subscribers, events)
+}
+
+// Publish sends value to every subscriber as an event without a name, returning the
+// amount of subscribers that it was queued for. See PublishEvent.
+func (sp *SSEPublisher[T]) Publish(value T) (int, error) {
+	return sp.PublishEvent("", value)
+}
+
+// PublishEvent sends value to every subscriber as an event with the given name, returning
+// the amount of subscribers that it was queued for. Each event is given an increasing ID.
+// Subscribers whose buffers are full are disconnected.
+func (sp *SSEPublisher[T]) PublishEvent(event string, value T) (int, error) {
+	if strings.ContainsAny(event, "\r\n") {
+		return 0, fmt.Errorf("invalid event name %q", event)
+	}
+
+	payload, err := appendMarshal(nil, value)
+	if err != nil {
+		return 0, err
+	}
+	if sp.options.Base64 {
+		payload = []byte(base64.StdEncoding.EncodeToString(payload))
+	} else if bytes.ContainsRune(payload, '\r') {
+		return 0, errors.New("payload contains a carriage return, which SSE cannot carry without Base64")
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.closed {
+		return 0, net.ErrClosed
+	}
+
+	sp.nextID++
+
+	var frame bytes.Buffer
+	fmt.Fprintf(&frame, "id: %d\n", sp.nextID)
+	if event != "" {
+		fmt.Fprintf(&frame, "event: %s\n", event)
+	}
+	for _, line := range bytes.Split(payload, []byte("\n")) {
+		frame.WriteString("data: ")
+		frame.Write(line)
+		frame.WriteByte('\n')
+	}
+	frame.WriteByte('\n')
+
+	sent := 0
+	for events := range sp.subscribers {
+		select {
+		case events <- frame.Bytes():
+			sent++
+		default:
+			delete(sp.subscribers, events)
+			close(events)
+		}
+	}
+
+	return sent, nil
+}
+
+// Len returns the amount of current subscribers.
+func (sp *SSEPublisher[T]) Len() int {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	return len(sp.subscribers)
+}
+
+// Close disconnects all subscribers, and rejects any new ones.
+func (sp *SSEPublisher[T]) Close() error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if !sp.closed {
+		sp.closed = true
+		close(sp.done)
+	}
+
+	return nil
+}
+
+// SSEEvent is a value received from a Server-Sent Events stream by SubscribeSSE.
+type SSEEvent[T Convertable] struct {
+	// ID and Event are the id and name of the event, which are empty if the publisher
+	// did not send them.
+	ID    string
+	Event string
+
+	Value T
+}
+
+// SSESubscribeOptions is a struct used by SubscribeSSE to define certain optional
+// parameters.
+type SSESubscribeOptions struct {
+	// Client is used to send the request. If nil, http.DefaultClient is used. Its
+	// Timeout should be zero, as it would otherwise cut off the stream.
+	Client *http.Client
+
+	// Header holds any extra headers to send with the request.
+	Header http.Header
+
+	// Base64 decodes each payload from standard base64. It must match the setting of
+	// the publisher.
+	Base64 bool
+
+	// MaxEventSize is the largest event that is accepted. If zero,
+	// DefaultMaxFrameSize is used.
+	MaxEventSize int
+}
+
+func defaultSSESubscribeOptions() SSESubscribeOptions {
+	return SSESubscribeOptions{
+		Client:       http.DefaultClient,
+		MaxEventSize: DefaultMaxFrameSize,
+	}
+}
+
+// SubscribeSSE connects to the Server-Sent Events stream at url, such as one served by an
+// SSEPublisher, and returns an iterator over its events, for use with range:
+//
+//	for event, err := range netutils.SubscribeSSE[T](ctx, url) {
+//		if err != nil {
+//			// handle err
+//			break
+//		}
+//		// use event.Value
+//	}
+//
+// Iteration ends silently when the server ends the stream. Any other error, including
+// an event that cannot be unmarshalled, is yielded once, after which iteration ends. If
+// ctx is done, the stream is closed and ctx.Err() is yielded.
+//
+// This takes a variadic parameter of type SSESubscribeOptions. If no SSESubscribeOptions
+// are supplied, then the defaults are used. If more than one SSESubscribeOptions are
+// supplied then only the first will be used.
+func SubscribeSSE[T Convertable](ctx context.Context, url string, opts ...SSESubscribeOptions) iter.Seq2[SSEEvent[T], error] {
+	options := defaultSSESubscribeOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	if options.MaxEventSize <= 0 {
+		options.MaxEventSize = DefaultMaxFrameSize
+	}
+
+	return func(yield func(SSEEvent[T], error) bool) {
+		fail := func(err error) {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+
+			yield(SSEEvent[T]{}, err)
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			fail(err)
+			return
+		}
+		for name, values := range options.Header {
+			request.Header[name] = values
+		}
+		request.Header.Set("Accept", "text/event-stream")
+
+		response, err := options.Client.Do(request)
+		if err != nil {
+			fail(err)
+			return
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			fail(fmt.Errorf("could not subscribe to event stream: %s", response.Status))
+			return
+		}
+
+		scanner := bufio.NewScanner(response.Body)
+		scanner.Buffer(make([]byte, 0, 4096), options.MaxEventSize)
+
+		var (
+			id, event string
+			data      []byte
+			hasData   bool
+		)
+		for scanner.Scan() {
+			line := strings.TrimSuffix(scanner.Text(), "\r")
+
+			if line == "" {
+				if !hasData {
+					event = ""
+					continue
+				}
+
+				value, err := decodeSSEData[T](data, options.Base64)
+				if err != nil {
+					fail(err)
+					return
+				}
+				if !yield(SSEEvent[T]{ID: id, Event: event, Value: value}, nil) {
+					return
+				}
+
+				event, data, hasData = "", data[:0], false
+
+				continue
+			}
+
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+
+			switch field {
+			case "":
+				// A comment, which is used for keep-alives.
+			case "id":
+				id = value
+			case "event":
+				event = value
+			case "data":
+				if hasData {
+					data = append(data, '\n')
+				}
+				if len(data)+len(value) > options.MaxEventSize {
+					fail(ErrFrameTooLarge)
+					return
+				}
+				data = append(data, value...)
+				hasData = true
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			fail(err)
+		} else if ctx.Err() != nil {
+			fail(ctx.Err())
+		}
+	}
+}
+
+func decodeSSEData[T Convertable](data []byte, encoded bool) (T, error) {
+	var value T
+
+	if encoded {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return value, errors.Join(ErrUnmarshal, err)
+		}
+		data = decoded
+	}
+
+	if err := value.Unmarshal(&value, data); err != nil {
+		return value, errors.Join(ErrUnmarshal, err)
+	}
+
+	return value, nil
+}
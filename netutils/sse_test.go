@@ -0,0 +1,54 @@
+package netutils
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	publisher := NewSSEPublisher[testMessage]()
+	server := httptest.NewServer(publisher)
+	defer server.Close()
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan SSEEvent[testMessage])
+	go func() {
+		defer close(events)
+		for event, err := range SubscribeSSE[testMessage](ctx, server.URL) {
+			if err != nil {
+				return
+			}
+			events <- event
+		}
+	}()
+
+	for publisher.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := publisher.Publish(testMessage{Text: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := publisher.PublishEvent("named", testMessage{Text: "two\nlines"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []SSEEvent[testMessage]{
+		{ID: "1", Value: testMessage{Text: "one"}},
+		{ID: "2", Event: "named", Value: testMessage{Text: "two\nlines"}},
+	}
+	for _, w := range want {
+		got, ok := <-events
+		if !ok {
+			t.Fatal("stream ended early")
+		}
+		if got != w {
+			t.Fatalf("received %+v, want %+v", got, w)
+		}
+	}
+}
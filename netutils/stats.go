@@ -0,0 +1,91 @@
+package netutils
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// Stats is a snapshot of the activity on a connection, as returned by Stats.
+type Stats struct {
+	BytesRead       uint64
+	BytesWritten    uint64
+	MessagesRead    uint64
+	MessagesWritten uint64
+
+	// ReadErrors and WriteErrors count the operations that failed, not including reads
+	// that failed because the peer closed the connection.
+	ReadErrors  uint64
+	WriteErrors uint64
+
+	// Created is when the connection was wrapped, and Uptime is the time since then.
+	Created time.Time
+	Uptime  time.Duration
+
+	// LastRead and LastWrite are when a value was last read from or written to the
+	// connection, or zero if that has never happened.
+	LastRead  time.Time
+	LastWrite time.Time
+}
+
+// connectionStats holds the counters behind Stats. It is guarded by connectionState.mu.
+type connectionStats struct {
+	bytesRead, bytesWritten       uint64
+	messagesRead, messagesWritten uint64
+	readErrors, writeErrors       uint64
+	lastRead, lastWrite           time.Time
+}
+
+// Stats returns a snapshot of the activity on the connection. Only values read or written
+// through the typed methods of the connection are counted; heartbeat frames are not.
+func (tc *AsymmetricTypedConnection[S, R]) Stats() Stats {
+	if tc.state == nil {
+		return Stats{}
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	stats := tc.state.stats
+
+	return Stats{
+		BytesRead:       stats.bytesRead,
+		BytesWritten:    stats.bytesWritten,
+		MessagesRead:    stats.messagesRead,
+		MessagesWritten: stats.messagesWritten,
+		ReadErrors:      stats.readErrors,
+		WriteErrors:     stats.writeErrors,
+		Created:         tc.state.created,
+		Uptime:          time.Since(tc.state.created),
+		LastRead:        stats.lastRead,
+		LastWrite:       stats.lastWrite,
+	}
+}
+
+// recordStats counts the outcome of an operation that transferred messages values in n
+// bytes.
+func (tc *AsymmetricTypedConnection[S, R]) recordStats(dir direction, messages, n int, err error) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	stats := &tc.state.stats
+
+	switch {
+	case dir == readDirection && err == nil:
+		stats.bytesRead += uint64(n)
+		stats.messagesRead += uint64(messages)
+		stats.lastRead = time.Now()
+	case dir == readDirection && !errors.Is(err, io.EOF):
+		stats.readErrors++
+	case dir == writeDirection && err == nil:
+		stats.bytesWritten += uint64(n)
+		stats.messagesWritten += uint64(messages)
+		stats.lastWrite = time.Now()
+	case dir == writeDirection:
+		stats.writeErrors++
+	}
+}
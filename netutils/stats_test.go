@@ -0,0 +1,73 @@
+package netutils
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	a, b := net.Pipe()
+	client := NewTypedConnection[testMessage](a, ConnectionTypeTCP)
+	server := NewTypedConnection[testMessage](b, ConnectionTypeTCP)
+	defer server.Close()
+
+	if stats := client.Stats(); stats.MessagesWritten != 0 || !stats.LastWrite.IsZero() || stats.Created.IsZero() {
+		t.Errorf("new connection should have no activity, got %+v", stats)
+	}
+
+	sent := make(chan int, 2)
+	go func() {
+		for _, text := range []string{"hello", "world"} {
+			n, err := client.Send(testMessage{Text: text})
+			if err != nil {
+				return
+			}
+			sent <- n
+		}
+
+		// A frame that does not unmarshal, followed by a clean close.
+		_, _ = client.conn.Write(append(binary.BigEndian.AppendUint32(nil, 1), '{'))
+		_ = client.Close()
+	}()
+
+	var read uint64
+	for range 2 {
+		var message testMessage
+		n, err := server.Receive(&message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read += uint64(n)
+	}
+	written := uint64(<-sent + <-sent)
+
+	var message testMessage
+	if _, err := server.Receive(&message); err == nil {
+		t.Fatal("receiving a malformed frame should fail")
+	}
+	if _, err := server.Receive(&message); err == nil {
+		t.Fatal("receiving from a closed connection should fail")
+	}
+
+	stats := server.Stats()
+	if stats.MessagesRead != 2 || stats.BytesRead != read {
+		t.Errorf("expected 2 messages and %d bytes to be read, got %+v", read, stats)
+	}
+	if stats.ReadErrors != 1 {
+		t.Errorf("only the malformed frame should count as a read error, got %d", stats.ReadErrors)
+	}
+	if stats.LastRead.IsZero() || stats.Uptime <= 0 {
+		t.Errorf("expected the last read and uptime to be set, got %+v", stats)
+	}
+
+	stats = client.Stats()
+	if stats.MessagesWritten != 2 || stats.BytesWritten != written || stats.WriteErrors != 0 {
+		t.Errorf("expected 2 messages and %d bytes to be written, got %+v", written, stats)
+	}
+
+	var zero TypedConnection[testMessage]
+	if stats := zero.Stats(); stats != (Stats{}) {
+		t.Errorf("connection that was not created with a constructor should have no stats, got %+v", stats)
+	}
+}
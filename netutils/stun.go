@@ -0,0 +1,226 @@
+package netutils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// DefaultSTUNPort is the port used for STUN servers that are given without one.
+const DefaultSTUNPort = "3478"
+
+const (
+	stunMagicCookie uint32 = 0x2112A442
+	stunHeaderSize         = 20
+
+	stunBindingRequest uint16 = 0x0001
+	stunBindingSuccess uint16 = 0x0101
+	stunBindingError   uint16 = 0x0111
+
+	stunMappedAddress uint16 = 0x0001
+	stunErrorCode     uint16 = 0x0009
+	stunXORMappedAddr uint16 = 0x0020
+
+	// The retransmission schedule recommended by RFC 5389.
+	stunInitialRTO      = 500 * time.Millisecond
+	stunMaxTransmission = 7
+)
+
+// ErrSTUNNoResponse is returned by QuerySTUN if the STUN server did not answer any of the
+// binding requests sent to it.
+var ErrSTUNNoResponse = errors.New("no response from STUN server")
+
+// QuerySTUN sends a STUN binding request, as described by RFC 5389, from conn to server
+// and returns the address that the server saw it come from. When conn is behind a NAT,
+// this is the public address and port that the NAT has mapped conn to, which can be
+// given to a peer so that it can send to conn directly.
+//
+// The server is given as "host:port", or as a bare host in which case DefaultSTUNPort is
+// used. Requests are retransmitted with exponential backoff until a response is received,
+// ctx is done, or no response has been received after the last retransmission, in which
+// case ErrSTUNNoResponse is returned.
+//
+// QuerySTUN reads from conn, so nothing else should read from it at the same time.
+// Datagrams that are not the expected response are discarded. The read deadline of conn
+// is reset once QuerySTUN returns.
+func QuerySTUN(ctx context.Context, conn net.PacketConn, server string) (*net.UDPAddr, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, DefaultSTUNPort)
+	}
+
+	serverAddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve STUN server %q: %w", server, err)
+	}
+
+	var transaction [12]byte
+	if _, err := rand.Read(transaction[:]); err != nil {
+		return nil, err
+	}
+
+	request := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(request[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:], stunMagicCookie)
+	copy(request[8:], transaction[:])
+
+	defer conn.SetReadDeadline(time.Time{})
+
+	buffer := make([]byte, 1500)
+	rto := stunInitialRTO
+	for range stunMaxTransmission {
+		if _, err := conn.WriteTo(request, serverAddr); err != nil {
+			return nil, fmt.Errorf("could not send STUN request: %w", err)
+		}
+
+		deadline := time.Now().Add(rto)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+
+		for {
+			n, _, err := conn.ReadFrom(buffer)
+			if err != nil {
+				if !errors.Is(err, os.ErrDeadlineExceeded) {
+					return nil, err
+				}
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+
+				break
+			}
+
+			addr, err := parseSTUNResponse(buffer[:n], transaction)
+			if errors.Is(err, errNotSTUNResponse) {
+				continue
+			}
+
+			return addr, err
+		}
+
+		rto *= 2
+	}
+
+	return nil, ErrSTUNNoResponse
+}
+
+var errNotSTUNResponse = errors.New("not a response to the STUN request")
+
+// parseSTUNResponse returns the mapped address from a binding response to the request
+// with the given transaction ID.
+func parseSTUNResponse(msg []byte, transaction [12]byte) (*net.UDPAddr, error) {
+	if len(msg) < stunHeaderSize || binary.BigEndian.Uint32(msg[4:]) != stunMagicCookie || [12]byte(msg[8:20]) != transaction {
+		return nil, errNotSTUNResponse
+	}
+
+	kind := binary.BigEndian.Uint16(msg[0:])
+	if kind != stunBindingSuccess && kind != stunBindingError {
+		return nil, errNotSTUNResponse
+	}
+
+	length := int(binary.BigEndian.Uint16(msg[2:]))
+	if stunHeaderSize+length > len(msg) {
+		return nil, errors.New("truncated STUN response")
+	}
+
+	var mapped *net.UDPAddr
+	attributes := msg[stunHeaderSize : stunHeaderSize+length]
+	for len(attributes) >= 4 {
+		attribute := binary.BigEndian.Uint16(attributes[0:])
+		size := int(binary.BigEndian.Uint16(attributes[2:]))
+		if 4+size > len(attributes) {
+			return nil, errors.New("truncated STUN attribute")
+		}
+		value := attributes[4 : 4+size]
+
+		switch attribute {
+		case stunXORMappedAddr:
+			addr, err := parseSTUNAddress(value, msg[4:20])
+			if err != nil {
+				return nil, err
+			}
+
+			// XOR-MAPPED-ADDRESS takes precedence over MAPPED-ADDRESS, which is only
+			// sent by old servers.
+			return addr, nil
+		case stunMappedAddress:
+			addr, err := parseSTUNAddress(value, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			mapped = addr
+		case stunErrorCode:
+			if kind == stunBindingError && size >= 4 {
+				code := int(value[2])*100 + int(value[3])
+				return nil, fmt.Errorf("STUN server returned error %d: %s", code, value[4:])
+			}
+		}
+
+		// Attributes are padded to a multiple of 4 bytes.
+		attributes = attributes[min(len(attributes), 4+(size+3)&^3):]
+	}
+
+	if kind == stunBindingError {
+		return nil, errors.New("STUN server returned an error")
+	}
+	if mapped == nil {
+		return nil, errors.New("STUN response did not contain a mapped address")
+	}
+
+	return mapped, nil
+}
+
+// parseSTUNAddress parses a MAPPED-ADDRESS attribute, or an XOR-MAPPED-ADDRESS attribute
+// if key, being the magic cookie followed by the transaction ID, is not nil.
+func parseSTUNAddress(value, key []byte) (*net.UDPAddr, error) {
+	if len(value) < 4 {
+		return nil, errors.New("malformed STUN address")
+	}
+
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil, fmt.Errorf("unknown STUN address family %d", value[1])
+	}
+	if len(value) < 4+size {
+		return nil, errors.New("malformed STUN address")
+	}
+
+	port := binary.BigEndian.Uint16(value[2:])
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+
+	if key != nil {
+		port ^= uint16(stunMagicCookie >> 16)
+		for i := range ip {
+			ip[i] ^= key[i]
+		}
+	}
+
+	return &net.UDPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// PublicAddress queries the STUN server for the public address of the connection. See
+// QuerySTUN. This only works on connections that are not connected to a single peer,
+// such as the connection of a UDPSocketListener.
+func (utc *UDPTypedConnection[T]) PublicAddress(ctx context.Context, server string) (*net.UDPAddr, error) {
+	conn, ok := utc.conn.(net.PacketConn)
+	if !ok {
+		return nil, errors.New("conn is an invalid connection type for this method")
+	}
+
+	return QuerySTUN(ctx, conn, server)
+}
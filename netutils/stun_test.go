@@ -0,0 +1,68 @@
+package netutils
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveSTUN answers a single binding request on conn with an XOR-MAPPED-ADDRESS holding
+// the address that the request came from.
+func serveSTUN(t *testing.T, conn net.PacketConn) {
+	t.Helper()
+
+	buffer := make([]byte, 1500)
+	n, from, err := conn.ReadFrom(buffer)
+	if err != nil || n < stunHeaderSize {
+		return
+	}
+	addr := from.(*net.UDPAddr)
+
+	value := []byte{0, 0x01, 0, 0}
+	binary.BigEndian.PutUint16(value[2:], uint16(addr.Port)^uint16(stunMagicCookie>>16))
+	ip := addr.IP.To4()
+	for i := range ip {
+		value = append(value, ip[i]^buffer[4+i])
+	}
+
+	response := make([]byte, stunHeaderSize, stunHeaderSize+4+len(value))
+	binary.BigEndian.PutUint16(response[0:], stunBindingSuccess)
+	binary.BigEndian.PutUint16(response[2:], uint16(4+len(value)))
+	copy(response[4:], buffer[4:stunHeaderSize])
+	response = binary.BigEndian.AppendUint16(response, stunXORMappedAddr)
+	response = binary.BigEndian.AppendUint16(response, uint16(len(value)))
+	response = append(response, value...)
+
+	_, _ = conn.WriteTo(response, from)
+}
+
+func TestQuerySTUN(t *testing.T) {
+	server, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go serveSTUN(t, server)
+
+	listener, err := NewTypedUDPSocketListener[testMessage]("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _ := listener.Conn()
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addr, err := conn.PublicAddress(ctx, server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := conn.LocalAddr().(*net.UDPAddr)
+	if !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) || addr.Port != local.Port {
+		t.Errorf("expected 127.0.0.1:%d, got %s", local.Port, addr)
+	}
+}
@@ -0,0 +1,98 @@
+package netutils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// acmeTLSProtocol is the ALPN protocol used by the TLS-ALPN-01 ACME challenge, which
+// must be offered for certificate managers such as autocert to complete it.
+const acmeTLSProtocol = "acme-tls/1"
+
+// CertificateSource obtains certificates during TLS handshakes, with the same signature
+// as the GetCertificate field of tls.Config. This is satisfied by *autocert.Manager from
+// golang.org/x/crypto/acme/autocert, which obtains certificates from an ACME certificate
+// authority such as Let's Encrypt the first time that they are needed and renews them
+// before they expire, so typed-socket servers can be exposed publicly over TLS without
+// handling certificates manually.
+type CertificateSource interface {
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
+// AutocertOptions is a struct used by NewAutocertTCPSocketListener to define certain
+// optional parameters.
+type AutocertOptions struct {
+	// TLSConfig, if not nil, is used as the base of the TLS configuration of the
+	// listener. Its GetCertificate field is replaced.
+	TLSConfig *tls.Config
+}
+
+func defaultAutocertOptions() AutocertOptions {
+	return AutocertOptions{}
+}
+
+// AutocertTLSConfig returns a TLS configuration that obtains its certificates from
+// certs. Handshakes for server names other than domains are rejected before certs is
+// asked for a certificate, which stops a certificate manager from being made to request
+// certificates for arbitrary names. If no domains are given, every server name is
+// passed through to certs.
+func AutocertTLSConfig(certs CertificateSource, domains []string, opts ...AutocertOptions) *tls.Config {
+	options := defaultAutocertOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if options.TLSConfig != nil {
+		config = options.TLSConfig.Clone()
+	}
+
+	allowed := make([]string, len(domains))
+	for i, domain := range domains {
+		allowed[i] = strings.ToLower(strings.TrimSuffix(domain, "."))
+	}
+
+	config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+		if len(allowed) > 0 && !slices.Contains(allowed, name) {
+			return nil, fmt.Errorf("no certificate for server name %q", hello.ServerName)
+		}
+
+		return certs.GetCertificate(hello)
+	}
+
+	if !slices.Contains(config.NextProtos, acmeTLSProtocol) {
+		config.NextProtos = append(slices.Clone(config.NextProtos), acmeTLSProtocol)
+	}
+
+	return config
+}
+
+// NewAutocertTCPSocketListener creates a new *TCPSocketListener bound to host:port that
+// serves TLS, using certificates obtained from certs for the given domains. See
+// AutocertTLSConfig. On success, the new listener is returned. On failure, an error is
+// returned.
+//
+// When certs is an *autocert.Manager, the TLS-ALPN-01 challenge is answered by this
+// listener, so it must be reachable on port 443 of each domain; otherwise the manager's
+// HTTPHandler must be served on port 80 for the HTTP-01 challenge.
+//
+// This takes a variadic parameter of type AutocertOptions. If no AutocertOptions are
+// supplied, then the defaults are used. If more than one AutocertOptions are supplied
+// then only the first will be used.
+func NewAutocertTCPSocketListener[T Convertable](host, port string, certs CertificateSource, domains []string, opts ...AutocertOptions) (*TCPSocketListener[T], error) {
+	host, port, err := normaliseHostPort(host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTypedListener[T](tls.NewListener(listener, AutocertTLSConfig(certs, domains, opts...))), nil
+}
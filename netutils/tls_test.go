@@ -0,0 +1,129 @@
+package netutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type staticCertificateSource struct {
+	certificate *tls.Certificate
+	requests    atomic.Int32
+}
+
+func (scs *staticCertificateSource) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	scs.requests.Add(1)
+	return scs.certificate, nil
+}
+
+func selfSignedCertificate(t *testing.T, domain string) (*tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func TestAutocertTCPSocketListener(t *testing.T) {
+	certificate, pool := selfSignedCertificate(t, "example.test")
+	source := &staticCertificateSource{certificate: certificate}
+
+	listener, err := NewAutocertTCPSocketListener[testMessage]("127.0.0.1", "0", source, []string{"example.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				var message testMessage
+				if _, err := conn.Receive(&message); err == nil {
+					_, _ = conn.Send(message)
+				}
+			}()
+		}
+	}()
+
+	dial := func(serverName string) (*TCPTypedConnection[testMessage], error) {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: serverName, RootCAs: pool}}
+		return DialTCPAddr[testMessage](listener.Addr(), DialOptions{Timeout: 5 * time.Second, DialFunc: dialer.DialContext})
+	}
+
+	conn, err := dial("example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Send(testMessage{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var reply testMessage
+	if _, err := conn.Receive(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Text != "hello" {
+		t.Errorf("expected hello, got %q", reply.Text)
+	}
+
+	if _, err := dial("other.test"); err == nil {
+		t.Error("expected the handshake for another domain to fail")
+	}
+	if n := source.requests.Load(); n != 1 {
+		t.Errorf("expected the certificate source to be asked once, got %d", n)
+	}
+}
+
+func TestAutocertTLSConfigNextProtos(t *testing.T) {
+	base := &tls.Config{NextProtos: []string{"h2"}}
+	config := AutocertTLSConfig(&staticCertificateSource{}, nil, AutocertOptions{TLSConfig: base})
+
+	if len(config.NextProtos) != 2 || config.NextProtos[1] != acmeTLSProtocol {
+		t.Errorf("expected %s to be offered, got %v", acmeTLSProtocol, config.NextProtos)
+	}
+	if len(base.NextProtos) != 1 {
+		t.Error("expected the base configuration to be left untouched")
+	}
+}
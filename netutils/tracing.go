@@ -0,0 +1,93 @@
+package netutils
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+)
+
+// Span is a single traced operation, as started by a Tracer.
+type Span interface {
+	// SetAttributes records attrs on the span.
+	SetAttributes(attrs ...slog.Attr)
+	// End finishes the span. err is the error the operation failed with, if any.
+	End(err error)
+}
+
+// Tracer is the interface through which typed connections and the RPC layer report
+// spans. It is deliberately small so that it can be backed by OpenTelemetry or any other
+// tracing library with a thin adapter.
+type Tracer interface {
+	// Start starts a new span called name as a child of any span in ctx, returning a
+	// context containing the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+	// Inject writes the trace context of ctx into headers, which are sent along with an
+	// RPC request.
+	Inject(ctx context.Context, headers map[string]string)
+	// Extract returns a copy of ctx carrying the trace context found in headers, if any.
+	Extract(ctx context.Context, headers map[string]string) context.Context
+}
+
+// Attribute keys recorded on spans.
+const (
+	AttributeMessageSize = "messaging.message.body.size"
+	AttributeCodec       = "netutils.codec"
+	AttributeNetwork     = "network.transport"
+)
+
+// SetTracer attaches tracer to the connection, causing every Read, Write, Send, and
+// Receive to be recorded as a span with the size of the message and the type used to
+// encode it. The spans of ReadContext, WriteContext, SendContext, and ReceiveContext are
+// children of any span in their context. Passing nil detaches the current tracer.
+func (tc *AsymmetricTypedConnection[S, R]) SetTracer(tracer Tracer) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	tc.state.tracer = tracer
+}
+
+func (tc *AsymmetricTypedConnection[S, R]) tracer() Tracer {
+	if tc.state == nil {
+		return nil
+	}
+
+	tc.state.mu.Lock()
+	defer tc.state.mu.Unlock()
+
+	return tc.state.tracer
+}
+
+// startSpan starts a span for an operation on the connection as a child of any span in
+// ctx, or returns nil if the connection has no tracer.
+func (tc *AsymmetricTypedConnection[S, R]) startSpan(ctx context.Context, name string, dir direction) Span {
+	tracer := tc.tracer()
+	if tracer == nil {
+		return nil
+	}
+
+	codec := reflect.TypeFor[S]()
+	if dir == readDirection {
+		codec = reflect.TypeFor[R]()
+	}
+
+	_, span := tracer.Start(ctx, "netutils."+name)
+	span.SetAttributes(
+		slog.String(AttributeCodec, codec.String()),
+		slog.String(AttributeNetwork, tc.connectionType.String()),
+	)
+
+	return span
+}
+
+func endSpan(span Span, n int, err error) {
+	if span == nil {
+		return
+	}
+
+	span.SetAttributes(slog.Int(AttributeMessageSize, n))
+	span.End(err)
+}
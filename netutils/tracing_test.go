@@ -0,0 +1,125 @@
+package netutils
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type testSpanKey struct{}
+
+type testSpan struct {
+	id, parent string
+}
+
+func (*testSpan) SetAttributes(...slog.Attr) {}
+func (*testSpan) End(error)                  {}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans map[string]*testSpan
+}
+
+func (tt *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	span := &testSpan{id: strconv.Itoa(len(tt.spans))}
+	if parent, ok := ctx.Value(testSpanKey{}).(string); ok {
+		span.parent = parent
+	}
+	tt.spans[name] = span
+
+	return context.WithValue(ctx, testSpanKey{}, span.id), span
+}
+
+func (*testTracer) Inject(ctx context.Context, headers map[string]string) {
+	if id, ok := ctx.Value(testSpanKey{}).(string); ok {
+		headers["test-span"] = id
+	}
+}
+
+func (*testTracer) Extract(ctx context.Context, headers map[string]string) context.Context {
+	if id, ok := headers["test-span"]; ok {
+		return context.WithValue(ctx, testSpanKey{}, id)
+	}
+
+	return ctx
+}
+
+func TestRPCTracePropagation(t *testing.T) {
+	tracer := &testTracer{spans: make(map[string]*testSpan)}
+	options := defaultRPCOptions()
+	options.Tracer = tracer
+
+	clientConn, serverConn := net.Pipe()
+
+	server := NewRPCServer(func(_ context.Context, request testMessage) (testMessage, error) {
+		return request, nil
+	}, options)
+	go func() { _ = server.ServeConn(context.Background(), serverConn) }()
+
+	client := NewRPCClient[testMessage, testMessage](clientConn, options)
+	defer client.Close()
+
+	if _, err := client.Call(context.Background(), testMessage{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+
+	call, handle := tracer.spans["netutils.RPCClient.Call"], tracer.spans["netutils.RPCServer.Handle"]
+	if call == nil || handle == nil {
+		t.Fatalf("expected call and handle spans, got %v", tracer.spans)
+	}
+	if handle.parent != call.id {
+		t.Errorf("handle span should be a child of the call span %q, got parent %q", call.id, handle.parent)
+	}
+	if tracer.spans["netutils.send"] == nil || tracer.spans["netutils.receive"] == nil {
+		t.Errorf("expected send and receive spans, got %v", tracer.spans)
+	}
+}
+
+func TestConnectionSpansAreChildren(t *testing.T) {
+	tracer := &testTracer{spans: make(map[string]*testSpan)}
+
+	client, server := Pipe[testMessage]()
+	defer client.Close()
+	defer server.Close()
+	client.SetTracer(tracer)
+	server.SetTracer(tracer)
+
+	sendCtx, _ := tracer.Start(context.Background(), "sender")
+	receiveCtx, _ := tracer.Start(context.Background(), "receiver")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.SendContext(sendCtx, testMessage{Text: "hello"})
+		done <- err
+	}()
+
+	var message testMessage
+	if _, err := server.ReceiveContext(receiveCtx, &message); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+
+	for name, parent := range map[string]string{"netutils.send": "sender", "netutils.receive": "receiver"} {
+		span := tracer.spans[name]
+		if span == nil {
+			t.Fatalf("expected a %s span, got %v", name, tracer.spans)
+		}
+		if span.parent != tracer.spans[parent].id {
+			t.Errorf("%s span should be a child of the %s span, got parent %q", name, parent, span.parent)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// ErrNoHandler is passed to TypedServerOptions.OnError when a TypedServer receives a
+// message whose type has no handler.
+var ErrNoHandler = errors.New("no handler registered for message type")
+
+// TypedServerOptions is a struct used by NewTypedServer to define certain optional
+// parameters.
+type TypedServerOptions struct {
+	// ReadOptions are passed to every Receive on accepted connections.
+	ReadOptions ReadOptions
+
+	// OnConnect, if not nil, is called with each accepted connection before any of its
+	// messages are handled. If it returns an error, the connection is closed.
+	OnConnect func(ctx context.Context, conn *TCPTypedConnection[Message]) error
+
+	// OnDisconnect, if not nil, is called once a connection has stopped being served,
+	// with the error that stopped it, which is nil if the peer closed it cleanly.
+	OnDisconnect func(conn *TCPTypedConnection[Message], err error)
+
+	// OnError, if not nil, is called with errors that do not stop a connection from
+	// being served, such as a message that no handler was registered for, which wraps
+	// ErrNoHandler.
+	OnError func(conn *TCPTypedConnection[Message], err error)
+}
+
+func defaultTypedServerOptions() TypedServerOptions {
+	return TypedServerOptions{ReadOptions: defaultReadOptions()}
+}
+
+type typedServerHandler func(ctx context.Context, conn *TCPTypedConnection[Message], value Convertable)
+
+// TypedServer is a TCP server that routes each message that it receives to a handler
+// registered for the message's type, with Handle. It combines a listener, the serve loop,
+// and the Message type registry, so that a server for a protocol with several message
+// types only needs its handlers to be written.
+//
+// Connections carry Message values, so each message type must be registered with
+// DefaultMessageRegistry, such as with RegisterMessage, on both the server and its
+// clients. Handlers reply by sending a Message on the connection that they are given.
+//
+// The messages of a single connection are handled one at a time, in the order that they
+// were received, so handlers that take a long time should start their own goroutine.
+// A TypedServer is safe for concurrent use.
+type TypedServer struct {
+	options TypedServerOptions
+
+	mu       sync.RWMutex
+	handlers map[reflect.Type]typedServerHandler
+}
+
+// NewTypedServer creates a new *TypedServer with no handlers.
+//
+// This takes a variadic parameter of type TypedServerOptions. If no TypedServerOptions
+// are supplied, then the defaults are used. If more than one TypedServerOptions are
+// supplied then only the first will be used.
+func NewTypedServer(opts ...TypedServerOptions) *TypedServer {
+	options := defaultTypedServerOptions()
+	if opts != nil {
+		options = opts[0]
+	}
+
+	return &TypedServer{
+		options:  options,
+		handlers: make(map[reflect.Type]typedServerHandler),
+	}
+}
+
+// Handle registers handler for messages of type T received by server. T must have been
+// registered with DefaultMessageRegistry, and may only have one handler, otherwise an
+// error is returned.
+func Handle[T Convertable](server *TypedServer, handler func(ctx context.Context, conn *TCPTypedConnection[Message], message T)) error {
+	var zero T
+	if _, ok := DefaultMessageRegistry.Tag(zero); !ok {
+		return fmt.Errorf("%w: %T", ErrUnregisteredType, zero)
+	}
+
+	typ := reflect.TypeFor[T]()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if _, ok := server.handlers[typ]; ok {
+		return fmt.Errorf("a handler for %s has already been registered", typ)
+	}
+
+	server.handlers[typ] = func(ctx context.Context, conn *TCPTypedConnection[Message], value Convertable) {
+		handler(ctx, conn, value.(T))
+	}
+
+	return nil
+}
+
+// ListenAndServe listens on host:port and serves connections until ctx is done or the
+// listener fails. See Serve.
+func (ts *TypedServer) ListenAndServe(ctx context.Context, host, port string) error {
+	listener, err := ListenTCP[Message](host, port)
+	if err != nil {
+		return err
+	}
+
+	return ts.Serve(ctx, listener)
+}
+
+// Serve accepts connections from listener and serves each one in its own goroutine until
+// ctx is done or the listener fails. The listener is closed when Serve returns, and the
+// connections are closed once ctx is done.
+func (ts *TypedServer) Serve(ctx context.Context, listener *TCPSocketListener[Message]) error {
+	stop := context.AfterFunc(ctx, func() { _ = listener.Close() })
+	defer stop()
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		go func() { _ = ts.ServeConn(ctx, conn) }()
+	}
+}
+
+// ServeConn receives messages from conn and dispatches them to the registered handlers
+// until ctx is done or the connection fails. The connection is closed when ServeConn
+// returns. A clean close by the peer is not reported as an error.
+func (ts *TypedServer) ServeConn(ctx context.Context, conn *TCPTypedConnection[Message]) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	if ts.options.OnDisconnect != nil {
+		defer func() { ts.options.OnDisconnect(conn, err) }()
+	}
+
+	if ts.options.OnConnect != nil {
+		if err := ts.options.OnConnect(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var message Message
+		if _, err := conn.Receive(&message, WithReadOptions(ts.options.ReadOptions)); err != nil {
+			switch {
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case errors.Is(err, io.EOF):
+				return nil
+			case errors.Is(err, ErrUnmarshal):
+				// The frame was read in full, so the connection is still usable.
+				ts.error(conn, err)
+				continue
+			}
+
+			return err
+		}
+
+		ts.mu.RLock()
+		handler, ok := ts.handlers[message.Type()]
+		ts.mu.RUnlock()
+
+		if !ok {
+			ts.error(conn, fmt.Errorf("%w: %s", ErrNoHandler, message.Type()))
+			continue
+		}
+
+		handler(ctx, conn, message.Value)
+	}
+}
+
+func (ts *TypedServer) error(conn *TCPTypedConnection[Message], err error) {
+	if ts.options.OnError != nil {
+		ts.options.OnError(conn, err)
+	}
+}
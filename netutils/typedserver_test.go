@@ -0,0 +1,70 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+)
+
+var registerTestMessages = sync.OnceFunc(func() {
+	_ = RegisterMessage[testMessage]("test")
+	_ = RegisterMessage[otherTestMessage]("other")
+})
+
+func TestTypedServer(t *testing.T) {
+	registerTestMessages()
+
+	unhandled := make(chan error, 1)
+	server := NewTypedServer(TypedServerOptions{
+		ReadOptions: defaultReadOptions(),
+		OnError: func(_ *TCPTypedConnection[Message], err error) {
+			unhandled <- err
+		},
+	})
+
+	err := Handle(server, func(_ context.Context, conn *TCPTypedConnection[Message], message testMessage) {
+		_, _ = conn.Send(Message{Value: otherTestMessage{Number: len(message.Text)}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Handle(server, func(context.Context, *TCPTypedConnection[Message], testMessage) {}); err == nil {
+		t.Fatal("registering a second handler for a type should fail")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = server.Serve(ctx, NewTypedListener[Message](listener)) }()
+
+	conn, err := DialTCPAddr[Message](listener.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// otherTestMessage has no handler, so it is reported and then skipped.
+	if _, err := conn.Send(Message{Value: otherTestMessage{Number: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Send(Message{Value: testMessage{Text: "hello"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var reply Message
+	if _, err := conn.Receive(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if number, ok := MessageAs[otherTestMessage](reply); !ok || number.Number != 5 {
+		t.Fatalf("received %v, want a reply of 5", reply)
+	}
+	if err := <-unhandled; !errors.Is(err, ErrNoHandler) {
+		t.Fatalf("OnError received %v, want %v", err, ErrNoHandler)
+	}
+}
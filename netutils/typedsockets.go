@@ -1,17 +1,21 @@
 package netutils
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
+	"sync"
 	"time"
 )
 
 // Convertable describes a type that can be converted using any Marshal/Unmarshal methods.
 // These can act as literal wrappers over the "encoding/json" MarshalJSON/UnmarshalJSON
 // functions, for example, as they do not need to implement any other behaviours. See:
-// state.State for an example.
+// state.State for an example. Types can also implement AppendMarshaler to avoid an
+// intermediate copy when they are sent.
 type Convertable interface {
 	fmt.Stringer
 
@@ -23,17 +27,32 @@ type Convertable interface {
 	Unmarshal(v any, data []byte) error
 }
 
-// ReadOptions is a struct used for all Read and ReadFrom implementations to define
-// certain optional parameters.
+// ErrUnmarshal is joined with the error returned by Unmarshal when a value that was read
+// from a connection could not be converted.
+var ErrUnmarshal = errors.New("unmarshal of data returned an error")
+
+// ReadOptions is a struct that holds the optional parameters of all Read, Receive, and
+// ReadFrom implementations, which are given them as ReadOption values such as WithBufferSize.
+// It can be built from those options with NewReadOptions, which validates the values, and
+// can be set as the default of a connection with SetReadOptions.
 type ReadOptions struct {
 	BufferSize int
 	ChunkSize  int
+
+	// MaxFrameSize is the largest frame that Receive will accept. If zero,
+	// DefaultMaxFrameSize is used.
+	MaxFrameSize int
+
+	// Timeout, if greater than zero, bounds each Read and Receive, which then fail with
+	// an error wrapping context.DeadlineExceeded once it elapses.
+	Timeout time.Duration
 }
 
 func defaultReadOptions() ReadOptions {
 	return ReadOptions{
-		BufferSize: 4096,
-		ChunkSize:  256,
+		BufferSize:   4096,
+		ChunkSize:    256,
+		MaxFrameSize: DefaultMaxFrameSize,
 	}
 }
 
@@ -58,50 +77,132 @@ func (ct ConnectionType) String() string {
 // Ensure that ConnectionType implements Stringer correctly.
 var _ fmt.Stringer = ConnectionTypeTCP
 
+// AsymmetricTypedConnection is a type-safe wrapper over a TCP/UDP connection that sends
+// values of type S and receives values of type R. This is useful for protocols where
+// each peer speaks a different message type, for example a client that sends Command
+// values and receives Event values. TypedConnection is the symmetric form of this type.
+type AsymmetricTypedConnection[S, R Convertable] struct {
+	conn           net.Conn
+	connectionType ConnectionType
+	state          *connectionState
+	interceptors   *interceptors[S, R]
+}
+
+// connectionState holds the parts of a connection that must be shared between all copies
+// of its typed wrapper.
+type connectionState struct {
+	id      ConnectionID
+	logger  *slog.Logger
+	tracer  Tracer
+	metrics Metrics
+
+	created time.Time
+	stats   connectionStats
+	dump    *frameDumper
+
+	bufferPool BufferPool
+	sendSize   int
+
+	retry *RetryPolicy
+
+	mu      sync.Mutex
+	closed  bool
+	onClose []func()
+
+	// err is the reason the package closed the connection on the user's behalf, such as
+	// a heartbeat timeout, which is surfaced by the next operation on the connection.
+	err error
+
+	rtt              time.Duration
+	outstandingPings int
+	heartbeatStop    chan struct{}
+
+	idleTimeout  time.Duration
+	idleTimer    *time.Timer
+	lastActivity time.Time
+
+	// readDeadline and writeDeadline are the deadlines set by the user, which are
+	// restored after a context-bound operation. readCtxMu and writeCtxMu serialise those
+	// operations, so that they do not restore each other's deadlines.
+	readDeadline  time.Time
+	writeDeadline time.Time
+	readCtxMu     sync.Mutex
+	writeCtxMu    sync.Mutex
+
+	// writeMu serialises writes to the connection, so that the frames of concurrent
+	// writers are not interleaved on connections that split a frame into several writes.
+	writeMu sync.Mutex
+
+	// readOptions are the defaults set with SetReadOptions, if any.
+	readOptions *ReadOptions
+
+	readLimiter  *rateLimiter
+	writeLimiter *rateLimiter
+}
+
+// NewAsymmetricTypedConnection creates a new AsymmetricTypedConnection that sends values
+// of type S and receives values of type R over conn.
+func NewAsymmetricTypedConnection[S, R Convertable](conn net.Conn, connectionType ConnectionType) AsymmetricTypedConnection[S, R] {
+	return AsymmetricTypedConnection[S, R]{
+		conn:           conn,
+		connectionType: connectionType,
+		state:          &connectionState{id: ConnectionID(connectionIDs.Add(1)), created: time.Now()},
+		interceptors:   &interceptors[S, R]{},
+	}
+}
+
 // TypedConnection is a type-safe wrapper over a TCP/UDP connection. It is not recommended
 // to use this type directly, but to use either TCPTypedConnection or UDPTypedConnection
 // if possible.
 type TypedConnection[T Convertable] struct {
-	conn           net.Conn
-	connectionType ConnectionType
+	AsymmetricTypedConnection[T, T]
 }
 
 func NewTypedConnection[T Convertable](conn net.Conn, connectionType ConnectionType) TypedConnection[T] {
-	return TypedConnection[T]{conn: conn, connectionType: connectionType}
+	return TypedConnection[T]{NewAsymmetricTypedConnection[T, T](conn, connectionType)}
 }
 
-func (tc *TypedConnection[T]) ConnectionType() ConnectionType {
+func (tc *AsymmetricTypedConnection[S, R]) ConnectionType() ConnectionType {
 	return tc.connectionType
 }
 
-// Reads from the connection, attempting to read a T from the buffer by converting using
-// T's Convertable interface. If successful, the function will populate the given data
+// Reads from the connection, attempting to read a R from the buffer by converting using
+// R's Convertable interface. If successful, the function will populate the given data
 // pointer with the read data. On failure, it will return an error.
 //
-// This takes a variadic parameter of type ReadOptions, which can be used to set the chunk
-// size and buffer size to be used. If no ReadOptions are supplied, then the defaults are
-// used using the private defaultReadFromOptions function. If more than one ReadOptions
-// are supplied then only the first will be used.
-func (tc *TypedConnection[T]) Read(data *T, opts ...ReadOptions) (int, error) {
+// This takes a variadic parameter of type ReadOption, which can be used to set the chunk
+// size and buffer size to be used. The options are applied over the defaults of the
+// connection; see SetReadOptions. If any of them are invalid, nothing is read and an
+// error is returned.
+func (tc *AsymmetricTypedConnection[S, R]) Read(data *R, opts ...ReadOption) (int, error) {
+	return tc.ReadContext(context.Background(), data, opts...)
+}
+
+// read implements Read, without any interceptors.
+func (tc *AsymmetricTypedConnection[S, R]) read(data *R, readOpts ReadOptions) (int, error) {
 	if data == nil {
 		return 0, errors.New("data pointer was nil")
 	}
 
-	var readOpts ReadOptions
-	if opts == nil {
-		readOpts = defaultReadOptions()
-	} else {
-		readOpts = opts[0]
-	}
-
-	buffer := make([]byte, 0, readOpts.BufferSize)
-	chunk := make([]byte, readOpts.ChunkSize)
+	pool := tc.bufferPool()
+	buffer := getBuffer(pool, readOpts.BufferSize)[:0]
+	chunk := getBuffer(pool, readOpts.ChunkSize)
+	defer func() {
+		putBuffer(pool, buffer)
+		putBuffer(pool, chunk)
+	}()
 
 	for {
 		amount, err := tc.conn.Read(chunk)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
-				return amount, err
+				return amount, tc.wrapError(err)
+			}
+
+			// The connection was closed by the package, e.g. by an idle timeout, so
+			// there is no point in trying to unmarshal whatever has been read so far.
+			if wrapped := tc.wrapError(err); wrapped != err {
+				return 0, wrapped
 			}
 
 			break
@@ -110,54 +211,331 @@ func (tc *TypedConnection[T]) Read(data *T, opts ...ReadOptions) (int, error) {
 		buffer = append(buffer, chunk[:amount]...)
 	}
 
-	var newData T
+	var newData R
 	err := newData.Unmarshal(&newData, buffer)
 	if err != nil {
-		return 0, errors.Join(errors.New("unmarshal of data returned an error"), err)
+		return 0, errors.Join(ErrUnmarshal, err)
 	}
 
 	*data = newData
+	tc.touch()
+	tc.waitRead(len(buffer))
 
 	return len(buffer), nil
 }
 
-// Write attempts to write to the connection the data of type T. On success, it returns
+// Write attempts to write to the connection the data of type S. On success, it returns
 // the amount of bytes that were written. On failure, it returns an error.
-func (tc *TypedConnection[T]) Write(data T) (int, error) {
-	buffer, err := data.Marshal()
+func (tc *AsymmetricTypedConnection[S, R]) Write(data S) (int, error) {
+	return tc.writeContext(context.Background(), data)
+}
+
+// writeContext implements Write, recording its span as a child of any span in ctx.
+func (tc *AsymmetricTypedConnection[S, R]) writeContext(ctx context.Context, data S) (int, error) {
+	return tc.retryWrite(func() (int, error) {
+		return tc.observe(ctx, "write", writeDirection, func() (int, error) {
+			return tc.interceptWrite(tc.write)(data)
+		})
+	})
+}
+
+// write implements Write, without any interceptors.
+func (tc *AsymmetricTypedConnection[S, R]) write(data S) (int, error) {
+	buffer, err := appendMarshal(nil, data)
 	if err != nil {
 		return 0, errors.Join(errors.New("could not marshal data to write"), err)
 	}
 
-	return tc.conn.Write(buffer)
+	tc.waitWrite(len(buffer))
+
+	unlock := tc.lockWrite()
+	n, err := tc.conn.Write(buffer)
+	unlock()
+	if err != nil {
+		return n, tc.wrapError(err)
+	}
+	tc.touch()
+
+	return n, nil
+}
+
+// Send writes data to the connection as a single length-prefixed frame. Unlike Write,
+// this allows the receiving side to separate consecutive messages on a stream connection
+// by using Receive. On stream connections, the length prefix and the payload are written
+// together using vectored I/O (writev) rather than being copied into one buffer. On
+// success, it returns the amount of bytes that were written, including the length
+// prefix. On failure, it returns an error.
+func (tc *AsymmetricTypedConnection[S, R]) Send(data S) (int, error) {
+	return tc.sendContext(context.Background(), data)
+}
+
+// sendContext implements Send, recording its span as a child of any span in ctx.
+func (tc *AsymmetricTypedConnection[S, R]) sendContext(ctx context.Context, data S) (int, error) {
+	return tc.retryWrite(func() (int, error) {
+		return tc.observe(ctx, "send", writeDirection, func() (int, error) {
+			return tc.interceptWrite(tc.send)(data)
+		})
+	})
+}
+
+// send implements Send, without any interceptors.
+func (tc *AsymmetricTypedConnection[S, R]) send(data S) (int, error) {
+	// Datagrams must be written in one call, and values that marshal directly are
+	// already written behind their length prefix, so only the remaining values are
+	// written as separate buffers.
+	if tc.connectionType == ConnectionTypeUDP || marshalsDirectly(data) {
+		pool := tc.bufferPool()
+
+		var frame []byte
+		if pool != nil {
+			frame = pool.Get(tc.sendSizeHint())[:0]
+		}
+
+		frame, err := appendValueFrame(frame, data)
+		if err != nil {
+			return 0, err
+		}
+		if pool != nil {
+			tc.setSendSizeHint(len(frame))
+			defer pool.Put(frame)
+		}
+
+		return tc.writeFrame(frame)
+	}
+
+	buffers, total, err := appendFrameBuffers(nil, data)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := tc.writeBuffers(buffers, total)
+	return int(n), err
 }
 
-// Close is a wrapper over net.Conn.Close().
-func (tc *TypedConnection[T]) Close() error {
-	return tc.conn.Close()
+// writeFrame writes an already marshalled frame to the connection, applying the write
+// limit and recording activity for the idle timeout.
+func (tc *AsymmetricTypedConnection[S, R]) writeFrame(frame []byte) (int, error) {
+	tc.waitWrite(len(frame))
+
+	unlock := tc.lockWrite()
+	n, err := tc.conn.Write(frame)
+	unlock()
+	if err != nil {
+		return n, tc.wrapError(err)
+	}
+	tc.touch()
+	tc.dumpFrames(writeDirection, frame)
+
+	return n, nil
+}
+
+// lockWrite locks the connection for writing, see connectionState.writeMu, and returns
+// the function that unlocks it.
+func (tc *AsymmetricTypedConnection[S, R]) lockWrite() func() {
+	if tc.state == nil {
+		return func() {}
+	}
+
+	tc.state.writeMu.Lock()
+	return tc.state.writeMu.Unlock
+}
+
+// Receive reads a single length-prefixed frame written by Send from the connection and
+// converts it into a R using R's Convertable interface. If successful, the function will
+// populate the given data pointer with the read data and return the size of the frame's
+// payload. On failure, it will return an error and the data pointer is left untouched.
+//
+// This takes a variadic parameter of type ReadOption, of which only WithMaxSize and
+// WithTimeout have an effect. The options are applied over the defaults of the
+// connection; see SetReadOptions. If any of them are invalid, nothing is read and an
+// error is returned.
+func (tc *AsymmetricTypedConnection[S, R]) Receive(data *R, opts ...ReadOption) (int, error) {
+	return tc.ReceiveContext(context.Background(), data, opts...)
+}
+
+// receive implements Receive, without any interceptors.
+func (tc *AsymmetricTypedConnection[S, R]) receive(data *R, readOpts ReadOptions) (int, error) {
+	if data == nil {
+		return 0, errors.New("data pointer was nil")
+	}
+
+	pool := tc.bufferPool()
+	buffer, err := tc.receiveFrame(readOpts.maxFrameSize(), pool)
+	if err != nil {
+		return 0, err
+	}
+	defer putBuffer(pool, buffer)
+
+	var newData R
+	err = newData.Unmarshal(&newData, buffer)
+	if err != nil {
+		return 0, errors.Join(ErrUnmarshal, err)
+	}
+
+	*data = newData
+
+	return len(buffer), nil
+}
+
+// receiveFrame reads frames from the connection until a regular frame is found, handling
+// any control frames that are read before it. Payloads are taken from pool, if it is not
+// nil, and the payload of the returned frame is owned by the caller.
+func (tc *AsymmetricTypedConnection[S, R]) receiveFrame(maxFrameSize int, pool BufferPool) ([]byte, error) {
+	for {
+		payload, control, err := readFrame(tc.conn, maxFrameSize, pool)
+		if err != nil {
+			return nil, tc.wrapError(err)
+		}
+
+		tc.dumpFrame(readDirection, control, payload)
+
+		if !control {
+			tc.touch()
+			tc.waitRead(len(payload))
+
+			return payload, nil
+		}
+
+		err = tc.handleControlFrame(payload)
+		putBuffer(pool, payload)
+		if err != nil {
+			return nil, tc.wrapError(err)
+		}
+	}
+}
+
+// direction is whether an operation reads from or writes to the connection.
+type direction int
+
+const (
+	readDirection direction = iota
+	writeDirection
+)
+
+// observe runs operation, reporting it to the tracer, metrics, statistics, and logger
+// of the connection. The span of the operation is a child of any span in ctx.
+func (tc *AsymmetricTypedConnection[S, R]) observe(ctx context.Context, name string, dir direction, operation func() (int, error)) (int, error) {
+	start := time.Now()
+	span := tc.startSpan(ctx, name, dir)
+	n, err := operation()
+	endSpan(span, n, err)
+	tc.recordMetrics(dir, start, n, err)
+	tc.recordStats(dir, 1, n, err)
+	tc.logTransfer(name, n, err)
+
+	return n, err
+}
+
+// closeWithError closes the connection, recording err as the reason so that it can be
+// surfaced by the next operation on the connection.
+func (tc *AsymmetricTypedConnection[S, R]) closeWithError(err error) {
+	if tc.state != nil {
+		tc.state.mu.Lock()
+		if tc.state.err == nil {
+			tc.state.err = err
+		}
+		tc.state.mu.Unlock()
+	}
+
+	tc.log(slog.LevelWarn, "netutils: closing connection", slog.Any("reason", err))
+	_ = tc.Close()
+}
+
+// wrapError joins err with the reason the package closed the connection, if any.
+func (tc *AsymmetricTypedConnection[S, R]) wrapError(err error) error {
+	if err == nil || tc.state == nil {
+		return err
+	}
+
+	tc.state.mu.Lock()
+	reason := tc.state.err
+	tc.state.mu.Unlock()
+
+	if reason == nil {
+		return err
+	}
+
+	return errors.Join(reason, err)
+}
+
+// OnClose registers f to be called after the connection has been closed by Close. Each
+// registered function is called only once, even if Close is called multiple times. If
+// the connection has already been closed, f is called immediately.
+func (tc *AsymmetricTypedConnection[S, R]) OnClose(f func()) {
+	if tc.state == nil {
+		return
+	}
+
+	tc.state.mu.Lock()
+	if tc.state.closed {
+		tc.state.mu.Unlock()
+		f()
+
+		return
+	}
+	tc.state.onClose = append(tc.state.onClose, f)
+	tc.state.mu.Unlock()
+}
+
+// Close is a wrapper over net.Conn.Close(), which also calls any functions registered
+// with OnClose.
+func (tc *AsymmetricTypedConnection[S, R]) Close() error {
+	err := tc.conn.Close()
+	if err != nil {
+		tc.log(slog.LevelError, "netutils: close failed", slog.Any("error", err))
+	} else {
+		tc.log(slog.LevelInfo, "netutils: closed")
+	}
+
+	if tc.state != nil {
+		tc.state.mu.Lock()
+		hooks := tc.state.onClose
+		tc.state.onClose = nil
+		if !tc.state.closed && tc.state.metrics != nil {
+			metrics := tc.state.metrics
+			hooks = append(hooks, func() { metrics.ConnectionClosed(tc.connectionType.String()) })
+		}
+		tc.state.closed = true
+		if tc.state.idleTimer != nil {
+			tc.state.idleTimer.Stop()
+			tc.state.idleTimer = nil
+		}
+		tc.state.mu.Unlock()
+
+		for _, hook := range hooks {
+			hook()
+		}
+	}
+
+	return err
 }
 
 // LocalAddr is a wrapper over net.Conn.LocalAddr().
-func (tc *TypedConnection[T]) LocalAddr() net.Addr {
+func (tc *AsymmetricTypedConnection[S, R]) LocalAddr() net.Addr {
 	return tc.conn.LocalAddr()
 }
 
 // RemoteAddr is a wrapper over net.Conn.RemoteAddr().
-func (tc *TypedConnection[T]) RemoteAddr() net.Addr {
+func (tc *AsymmetricTypedConnection[S, R]) RemoteAddr() net.Addr {
 	return tc.conn.RemoteAddr()
 }
 
 // SetDeadline is a wrapper over net.Conn.SetDeadline().
-func (tc *TypedConnection[T]) SetDeadline(t time.Time) error {
+func (tc *AsymmetricTypedConnection[S, R]) SetDeadline(t time.Time) error {
+	tc.rememberDeadline(readDirection, t)
+	tc.rememberDeadline(writeDirection, t)
+
 	return tc.conn.SetDeadline(t)
 }
 
 // SetReadDeadline is a wrapper over net.Conn.SetReadDeadline().
-func (tc *TypedConnection[T]) SetReadDeadline(t time.Time) error {
+func (tc *AsymmetricTypedConnection[S, R]) SetReadDeadline(t time.Time) error {
+	tc.rememberDeadline(readDirection, t)
 	return tc.conn.SetReadDeadline(t)
 }
 
 // SetWriteDeadline is a wrapper over net.Conn.SetWriteDeadline().
-func (tc *TypedConnection[T]) SetWriteDeadline(t time.Time) error {
+func (tc *AsymmetricTypedConnection[S, R]) SetWriteDeadline(t time.Time) error {
+	tc.rememberDeadline(writeDirection, t)
 	return tc.conn.SetWriteDeadline(t)
 }
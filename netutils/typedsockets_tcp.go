@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"reflect"
 )
@@ -16,7 +17,7 @@ type TCPTypedConnection[T Convertable] struct {
 
 // NewTCPTypedConnection creates a new TCPTypedConnection specialised for T.
 func NewTCPTypedConnection[T Convertable](conn net.Conn) TCPTypedConnection[T] {
-	return TCPTypedConnection[T]{TypedConnection[T]{conn: conn, connectionType: ConnectionTypeTCP}}
+	return TCPTypedConnection[T]{NewTypedConnection[T](conn, ConnectionTypeTCP)}
 }
 
 // ReadFrom reads from the inner connection, attempting to read a T from the connection
@@ -24,18 +25,29 @@ func NewTCPTypedConnection[T Convertable](conn net.Conn) TCPTypedConnection[T] {
 // with the read data from the connection. On failure, the amount of bytes read is still
 // returned alongside an error. The data pointer is left untouched.
 //
-// This takes a variadic parameter of type ReadOptions, which can be used to set the chunk
-// size and buffer size to be used. If no ReadOptions are supplied, then the defaults are
-// used using the private defaultReadFromOptions function. If more than one ReadOptions
-// are supplied then only the first will be used.
-func (ttc *TCPTypedConnection[T]) ReadFrom(data *T, opts ...ReadOptions) (int64, error) {
-	var readOpts ReadOptions
-	if opts == nil {
-		readOpts = defaultReadOptions()
-	} else {
-		readOpts = opts[0]
+// This takes a variadic parameter of type ReadOption, which can be used to set the chunk
+// size and buffer size to be used. The options are applied over the defaults of the
+// connection; see SetReadOptions. If any of them are invalid, nothing is read and an
+// error is returned.
+func (ttc *TCPTypedConnection[T]) ReadFrom(data *T, opts ...ReadOption) (int64, error) {
+	readOpts, err := ttc.readOptions(opts)
+	if err != nil {
+		return 0, err
 	}
 
+	var amountRead int64
+	_, err = ttc.interceptRead(func(data *T) (int, error) {
+		n, err := ttc.readFrom(data, readOpts)
+		amountRead = n
+
+		return int(n), err
+	})(data)
+
+	return amountRead, err
+}
+
+// readFrom implements ReadFrom, without any interceptors.
+func (ttc *TCPTypedConnection[T]) readFrom(data *T, readOpts ReadOptions) (int64, error) {
 	switch conn := ttc.conn.(type) {
 	case *net.TCPConn:
 		buffer := make([]byte, readOpts.BufferSize)
@@ -62,21 +74,46 @@ func (ttc *TCPTypedConnection[T]) ReadFrom(data *T, opts ...ReadOptions) (int64,
 }
 
 // DialTCP attempts to connect to a given TCP socket at host:port, and creates a new
-// TCPTypedConnection[T] on success. On failure, an error is returned.
-func DialTCP[T Convertable](host, port string) (*TCPTypedConnection[T], error) {
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", host, port))
+// TCPTypedConnection[T] on success. On failure, an error is returned. IPv6 hosts may be
+// given with or without brackets, and host may also be a full "host:port" address if
+// port is empty. See DialTCPAddr for other forms of address.
+//
+// This takes a variadic parameter of type DialOptions, which can be used to set the
+// connect timeout, keep-alive period, and local address. If no DialOptions are supplied,
+// then the defaults are used. If more than one DialOptions are supplied then only the
+// first will be used.
+func DialTCP[T Convertable](host, port string, opts ...DialOptions) (*TCPTypedConnection[T], error) {
+	conn, err := dial("tcp", host, port, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	tc := NewTCPTypedConnection[T](conn)
+	tc.SetLogger(dialLogger(opts))
+
+	return &tc, nil
+}
+
+// DialTCPAsymmetric attempts to connect to a given TCP socket at host:port, and creates a
+// new AsymmetricTypedConnection[S, R] on success, which sends S and receives R. On
+// failure, an error is returned. See DialTCP for details on opts.
+func DialTCPAsymmetric[S, R Convertable](host, port string, opts ...DialOptions) (*AsymmetricTypedConnection[S, R], error) {
+	conn, err := dial("tcp", host, port, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := NewAsymmetricTypedConnection[S, R](conn, ConnectionTypeTCP)
+	tc.SetLogger(dialLogger(opts))
 
 	return &tc, nil
 }
 
 // TCPSocketListener is a type-safe wrapper over *net.TCPSocketListener
 type TCPSocketListener[T Convertable] struct {
-	listener *net.TCPListener
+	listener      net.Listener
+	socketOptions *SocketOptions
+	logger        *slog.Logger
 }
 
 // NewTypedTCPSocketListener creates a *TCPSocketListener from a pre-existing
@@ -85,26 +122,85 @@ func NewTypedTCPSocketListener[T Convertable](listener *net.TCPListener) *TCPSoc
 	return &TCPSocketListener[T]{listener: listener}
 }
 
+// NewTypedListener creates a *TCPSocketListener from any pre-existing stream
+// net.Listener, such as one returned by tls.NewListener, a Unix socket listener, or a
+// fake listener in tests. Socket options set with SetSocketOptions can only be applied
+// to connections that are *net.TCPConns, and Accept fails for any other connection if
+// they have been set.
+func NewTypedListener[T Convertable](listener net.Listener) *TCPSocketListener[T] {
+	return &TCPSocketListener[T]{listener: listener}
+}
+
 // Accept starts listening on the inner TCPListener, and creates a *TCPTypedConnection
-// from the listener. On success, the new *TCPTypedConnection is returned. On failure, an
-// error is returned.
+// from the listener. Any socket options set with SetSocketOptions are applied to the new
+// connection. On success, the new *TCPTypedConnection is returned. On failure, an error
+// is returned.
 func (tsl *TCPSocketListener[T]) Accept() (*TCPTypedConnection[T], error) {
-	conn, err := tsl.listener.Accept()
+	tc, err := AcceptTCPAsymmetric[T, T](tsl)
 	if err != nil {
 		return nil, err
 	}
 
-	tc := NewTCPTypedConnection[T](conn)
+	return &TCPTypedConnection[T]{TypedConnection[T]{*tc}}, nil
+}
+
+// Acceptor is a listener that typed connections of any type can be accepted from,
+// regardless of the type that the listener was created for. *TCPSocketListener[T] is an
+// Acceptor for every T.
+type Acceptor interface {
+	// acceptConn accepts the next connection, with the socket options of the listener
+	// applied, and returns it alongside the logger that it should inherit.
+	acceptConn() (net.Conn, *slog.Logger, error)
+}
+
+// AcceptTCPAsymmetric is like TCPSocketListener.Accept, but creates a new
+// *AsymmetricTypedConnection[S, R], which sends S and receives R. This is the server
+// side of a connection dialled with DialTCPAsymmetric[R, S].
+func AcceptTCPAsymmetric[S, R Convertable](listener Acceptor) (*AsymmetricTypedConnection[S, R], error) {
+	conn, logger, err := listener.acceptConn()
+	if err != nil {
+		return nil, err
+	}
+
+	tc := NewAsymmetricTypedConnection[S, R](conn, ConnectionTypeTCP)
+	tc.SetLogger(logger)
+	tc.log(slog.LevelInfo, "netutils: accepted")
 
 	return &tc, nil
 }
 
-// Addr wraps the net.TCPListener.Addr function.
+func (tsl *TCPSocketListener[T]) acceptConn() (net.Conn, *slog.Logger, error) {
+	conn, err := tsl.listener.Accept()
+	if err != nil {
+		tsl.log(slog.LevelError, "netutils: accept failed", slog.Any("error", err))
+		return nil, nil, err
+	}
+
+	if tsl.socketOptions != nil {
+		// The tuner is never used as a connection, so it is not given an ID or a logger.
+		tuner := TCPTypedConnection[T]{TypedConnection[T]{AsymmetricTypedConnection[T, T]{conn: conn}}}
+		if err := tuner.Tune(*tsl.socketOptions); err != nil {
+			_ = conn.Close()
+			return nil, nil, errors.Join(errors.New("could not apply socket options"), err)
+		}
+	}
+
+	return conn, tsl.logger, nil
+}
+
+// Addr wraps the net.Listener.Addr function.
 func (tsl *TCPSocketListener[T]) Addr() net.Addr {
 	return tsl.listener.Addr()
 }
 
-// Close wraps the net.TCPListener.Close function.
+// Close wraps the net.Listener.Close function.
 func (tsl *TCPSocketListener[T]) Close() error {
-	return tsl.listener.Close()
+	err := tsl.listener.Close()
+	if err != nil {
+		tsl.log(slog.LevelError, "netutils: listener close failed", slog.Any("error", err))
+	} else {
+		tsl.log(slog.LevelInfo, "netutils: listener closed")
+	}
+
+	return err
 }
@@ -0,0 +1,100 @@
+package netutils
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestAsymmetricTCPRoundTrip(t *testing.T) {
+	listener, err := ListenTCP[testMessage]("127.0.0.1", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The server receives testMessages and replies with otherTestMessages.
+	served := make(chan error, 1)
+	go func() {
+		conn, err := AcceptTCPAsymmetric[otherTestMessage, testMessage](listener)
+		if err != nil {
+			served <- err
+			return
+		}
+		defer conn.Close()
+
+		var request testMessage
+		if _, err := conn.Receive(&request); err != nil {
+			served <- err
+			return
+		}
+
+		_, err = conn.Send(otherTestMessage{Number: len(request.Text)})
+		served <- err
+	}()
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := DialTCPAsymmetric[testMessage, otherTestMessage](host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.Send(testMessage{Text: strings.Repeat("a", 7)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var reply otherTestMessage
+	if _, err := client.Receive(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Number != 7 {
+		t.Errorf("reply should be 7, got %d", reply.Number)
+	}
+
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pipeListener is a net.Listener that accepts a single end of a net.Pipe.
+type pipeListener struct {
+	conn net.Conn
+}
+
+func (pl *pipeListener) Accept() (net.Conn, error) {
+	if pl.conn == nil {
+		return nil, net.ErrClosed
+	}
+
+	conn := pl.conn
+	pl.conn = nil
+
+	return conn, nil
+}
+
+func (pl *pipeListener) Close() error   { return nil }
+func (pl *pipeListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+func TestAcceptTCPAsymmetricSocketOptions(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	listener := NewTypedListener[testMessage](&pipeListener{conn: server})
+	listener.SetSocketOptions(&SocketOptions{})
+
+	// Socket options can only be applied to *net.TCPConns, so the accepted connection is
+	// closed instead of being returned.
+	if _, err := AcceptTCPAsymmetric[otherTestMessage, otherTestMessage](listener); err == nil {
+		t.Fatal("expected the socket options to fail to apply")
+	}
+	if _, err := client.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the accepted connection to be closed, got %v", err)
+	}
+}
@@ -16,13 +16,18 @@ type UDPTypedConnection[T Convertable] struct {
 
 // NewUDPTypedConnection creates a new UDPTypedConnections specialised for T.
 func NewUDPTypedConnection[T Convertable](conn net.Conn) UDPTypedConnection[T] {
-	return UDPTypedConnection[T]{TypedConnection[T]{conn: conn, connectionType: ConnectionTypeUDP}}
+	return UDPTypedConnection[T]{NewTypedConnection[T](conn, ConnectionTypeUDP)}
 }
 
 // WriteTo writes to the inner connection. It attempts to write the given data T. On
 // success, it will return the amount of bytes written. On failure, it will return an
 // error.
 func (utc *UDPTypedConnection[T]) WriteTo(data T, addr net.Addr) (int, error) {
+	return utc.interceptWrite(func(data T) (int, error) { return utc.writeTo(data, addr) })(data)
+}
+
+// writeTo implements WriteTo, without any interceptors.
+func (utc *UDPTypedConnection[T]) writeTo(data T, addr net.Addr) (int, error) {
 	switch conn := utc.conn.(type) {
 	case *net.UDPConn:
 		buffer, err := data.Marshal()
@@ -41,18 +46,29 @@ func (utc *UDPTypedConnection[T]) WriteTo(data T, addr net.Addr) (int, error) {
 // with the read data from the connection. On failure, the amount of bytes read is still
 // returned but so is an error. The data parameter is left untouched.
 //
-// This takes a variadic parameter of type ReadOptions, which can be used to set the chunk
-// size and buffer size to be used. If no ReadOptions are supplied, then the defaults are
-// used using the private defaultReadFromOptions function. If more than one ReadOptions
-// are supplied then only the first will be used.
-func (utc *UDPTypedConnection[T]) ReadFrom(data *T, opts ...ReadOptions) (int, net.Addr, error) {
-	var readOpts ReadOptions
-	if opts == nil {
-		readOpts = defaultReadOptions()
-	} else {
-		readOpts = opts[0]
+// This takes a variadic parameter of type ReadOption, which can be used to set the chunk
+// size and buffer size to be used. The options are applied over the defaults of the
+// connection; see SetReadOptions. If any of them are invalid, nothing is read and an
+// error is returned.
+func (utc *UDPTypedConnection[T]) ReadFrom(data *T, opts ...ReadOption) (int, net.Addr, error) {
+	readOpts, err := utc.readOptions(opts)
+	if err != nil {
+		return 0, nil, err
 	}
 
+	var addr net.Addr
+	n, err := utc.interceptRead(func(data *T) (int, error) {
+		n, from, err := utc.readFrom(data, readOpts)
+		addr = from
+
+		return n, err
+	})(data)
+
+	return n, addr, err
+}
+
+// readFrom implements ReadFrom, without any interceptors.
+func (utc *UDPTypedConnection[T]) readFrom(data *T, readOpts ReadOptions) (int, net.Addr, error) {
 	switch conn := utc.conn.(type) {
 	case *net.UDPConn:
 		buffer := make([]byte, readOpts.BufferSize)
@@ -82,14 +98,20 @@ func (utc *UDPTypedConnection[T]) ReadFrom(data *T, opts ...ReadOptions) (int, n
 }
 
 // DialUDP attempts to connect to a given UDP socket at host:port and creates a new
-// UDPTypedConnection[T] on success. On failure, an error is returned.
-func DialUDP[T Convertable](host, port string) (*UDPTypedConnection[T], error) {
-	conn, err := net.Dial("udp", fmt.Sprintf("%s:%s", host, port))
+// UDPTypedConnection[T] on success. On failure, an error is returned. host and port are
+// normalised in the same way as for DialTCP.
+//
+// This takes a variadic parameter of type DialOptions, which can be used to set the
+// local address. If no DialOptions are supplied, then the defaults are used. If more
+// than one DialOptions are supplied then only the first will be used.
+func DialUDP[T Convertable](host, port string, opts ...DialOptions) (*UDPTypedConnection[T], error) {
+	conn, err := dial("udp", host, port, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	tc := NewUDPTypedConnection[T](conn)
+	tc.SetLogger(dialLogger(opts))
 
 	return &tc, nil
 }
@@ -122,6 +144,16 @@ func NewTypedUDPSocketListener[T Convertable](port string) (*UDPSocketListener[T
 		nil
 }
 
+// NewTypedUDPSocketListenerFromConn creates a new *UDPSocketListener from a
+// pre-existing connection, usually a *net.UDPConn that has been configured by the
+// caller, or a fake connection in tests.
+func NewTypedUDPSocketListenerFromConn[T Convertable](conn net.Conn) *UDPSocketListener[T] {
+	return &UDPSocketListener[T]{
+		connection:       NewUDPTypedConnection[T](conn),
+		startedListening: true,
+	}
+}
+
 // Conn returns the inner type-safe connection of the listener.
 func (usl *UDPSocketListener[T]) Conn() (*UDPTypedConnection[T], error) {
 	if !usl.startedListening {
@@ -0,0 +1,335 @@
+package netutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// upnpServiceTypes are the UPnP services that can create port mappings, in order of
+// preference.
+var upnpServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// lazyUPnPMapper discovers a UPnP gateway the first time that it is used.
+type lazyUPnPMapper struct {
+	mu     sync.Mutex
+	mapper *upnpMapper
+}
+
+func (*lazyUPnPMapper) String() string { return "UPnP" }
+
+func (lm *lazyUPnPMapper) get(ctx context.Context) (*upnpMapper, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if lm.mapper == nil {
+		location, err := discoverUPnPGateway(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if lm.mapper, err = newUPnPMapper(ctx, location); err != nil {
+			return nil, err
+		}
+	}
+
+	return lm.mapper, nil
+}
+
+func (lm *lazyUPnPMapper) addMapping(ctx context.Context, protocol string, internal, external int, lifetime time.Duration, description string) (int, error) {
+	mapper, err := lm.get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return mapper.addMapping(ctx, protocol, internal, external, lifetime, description)
+}
+
+func (lm *lazyUPnPMapper) removeMapping(ctx context.Context, protocol string, internal, external int) error {
+	mapper, err := lm.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	return mapper.removeMapping(ctx, protocol, internal, external)
+}
+
+func (lm *lazyUPnPMapper) externalIP(ctx context.Context) (net.IP, error) {
+	mapper, err := lm.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapper.externalIP(ctx)
+}
+
+// discoverUPnPGateway searches for an Internet Gateway Device using SSDP, returning the
+// location of its device description.
+func discoverUPnPGateway(ctx context.Context) (string, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	request := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n\r\n"
+
+	deadline := time.Now().Add(3 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return "", err
+	}
+
+	if _, err := conn.WriteToUDP([]byte(request), ssdpGroup); err != nil {
+		return "", fmt.Errorf("could not send SSDP search: %w", err)
+	}
+
+	buffer := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+
+			return "", fmt.Errorf("no UPnP gateway found: %w", err)
+		}
+
+		response, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buffer[:n])), nil)
+		if err != nil {
+			continue
+		}
+		_ = response.Body.Close()
+
+		if location := response.Header.Get("Location"); response.StatusCode == http.StatusOK && location != "" {
+			return location, nil
+		}
+	}
+}
+
+// upnpMapper maps ports using the SOAP control interface of a UPnP Internet Gateway
+// Device.
+type upnpMapper struct {
+	controlURL  string
+	serviceType string
+	localIP     net.IP
+	client      *http.Client
+}
+
+func (*upnpMapper) String() string { return "UPnP" }
+
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// findService searches the device tree for the most preferred of upnpServiceTypes.
+func (ud upnpDevice) findService() (serviceType, controlURL string) {
+	best := len(upnpServiceTypes)
+
+	var walk func(device upnpDevice)
+	walk = func(device upnpDevice) {
+		for _, service := range device.Services {
+			for i, kind := range upnpServiceTypes {
+				if i < best && strings.EqualFold(service.ServiceType, kind) {
+					best, serviceType, controlURL = i, service.ServiceType, service.ControlURL
+				}
+			}
+		}
+
+		for _, child := range device.Devices {
+			walk(child)
+		}
+	}
+	walk(ud)
+
+	return serviceType, controlURL
+}
+
+// newUPnPMapper fetches the device description at location and finds the control URL of
+// its port mapping service.
+func newUPnPMapper(ctx context.Context, location string) (*upnpMapper, error) {
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPnP device location %q: %w", location, err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch UPnP device description: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch UPnP device description: %s", response.Status)
+	}
+
+	var description struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&description); err != nil {
+		return nil, fmt.Errorf("could not parse UPnP device description: %w", err)
+	}
+
+	serviceType, controlPath := description.Device.findService()
+	if serviceType == "" {
+		return nil, errors.New("UPnP device does not support port mapping")
+	}
+
+	if description.URLBase != "" {
+		if base, err = url.Parse(description.URLBase); err != nil {
+			return nil, fmt.Errorf("invalid UPnP URL base %q: %w", description.URLBase, err)
+		}
+	}
+
+	control, err := base.Parse(controlPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPnP control URL %q: %w", controlPath, err)
+	}
+
+	// The local address that routes to the gateway is the one that mappings should
+	// forward to. Dialing UDP sends nothing, so any port will do.
+	conn, err := net.Dial("udp4", net.JoinHostPort(base.Hostname(), "1"))
+	if err != nil {
+		return nil, fmt.Errorf("could not find local address for UPnP gateway: %w", err)
+	}
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	_ = conn.Close()
+
+	return &upnpMapper{
+		controlURL:  control.String(),
+		serviceType: serviceType,
+		localIP:     localIP,
+		client:      client,
+	}, nil
+}
+
+// call invokes action on the gateway with the given arguments, which are pairs of names
+// and values, and returns the body of the response.
+func (um *upnpMapper) call(ctx context.Context, action string, arguments ...string) ([]byte, error) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, html.EscapeString(um.serviceType))
+	for i := 0; i+1 < len(arguments); i += 2 {
+		fmt.Fprintf(&body, "<%s>%s</%s>", arguments[i], html.EscapeString(arguments[i+1]), arguments[i])
+	}
+	fmt.Fprintf(&body, "</u:%s></s:Body></s:Envelope>", action)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, um.controlURL, strings.NewReader(body.String()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	request.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, um.serviceType, action))
+
+	response, err := um.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		var fault struct {
+			Code        int    `xml:"Body>Fault>detail>UPnPError>errorCode"`
+			Description string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+		}
+		if xml.Unmarshal(data, &fault) == nil && fault.Code != 0 {
+			return nil, fmt.Errorf("%s failed with UPnP error %d: %s", action, fault.Code, fault.Description)
+		}
+
+		return nil, fmt.Errorf("%s failed: %s", action, response.Status)
+	}
+
+	return data, nil
+}
+
+func (um *upnpMapper) addMapping(ctx context.Context, protocol string, internal, external int, lifetime time.Duration, description string) (int, error) {
+	_, err := um.call(ctx, "AddPortMapping",
+		"NewRemoteHost", "",
+		"NewExternalPort", strconv.Itoa(external),
+		"NewProtocol", strings.ToUpper(protocol),
+		"NewInternalPort", strconv.Itoa(internal),
+		"NewInternalClient", um.localIP.String(),
+		"NewEnabled", "1",
+		"NewPortMappingDescription", description,
+		"NewLeaseDuration", strconv.Itoa(int(lifetime/time.Second)),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return external, nil
+}
+
+func (um *upnpMapper) removeMapping(ctx context.Context, protocol string, _, external int) error {
+	_, err := um.call(ctx, "DeletePortMapping",
+		"NewRemoteHost", "",
+		"NewExternalPort", strconv.Itoa(external),
+		"NewProtocol", strings.ToUpper(protocol),
+	)
+
+	return err
+}
+
+func (um *upnpMapper) externalIP(ctx context.Context) (net.IP, error) {
+	data, err := um.call(ctx, "GetExternalIPAddress")
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Address string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	if err := xml.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("could not parse GetExternalIPAddress response: %w", err)
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(response.Address))
+	if ip == nil {
+		return nil, fmt.Errorf("gateway returned invalid external address %q", response.Address)
+	}
+
+	return ip, nil
+}